@@ -17,26 +17,27 @@ package acceptance
 
 const (
 	// Buildpack identifiers used to verify that buildpacks were or were not used.
-	entrypoint      = "google.config.entrypoint"
-	dotnetFF        = "google.dotnet.functions-framework"
-	dotnetPublish   = "google.dotnet.publish"
-	dotnetRuntime   = "google.dotnet.runtime"
-	goBuild         = "google.go.build"
-	goClearSource   = "google.go.clear_source"
-	goFF            = "google.go.functions-framework"
-	goPath          = "google.go.gopath"
-	goRuntime       = "google.go.runtime"
-	javaClearSource = "google.java.clear_source"
-	javaEntrypoint  = "google.java.entrypoint"
-	javaExplodedJar = "google.java.exploded-jar"
-	javaGradle      = "google.java.gradle"
-	javaMaven       = "google.java.maven"
-	javaRuntime     = "google.java.runtime"
-	nodeFF          = "google.nodejs.functions-framework"
-	nodeNPM         = "google.nodejs.npm"
-	nodeRuntime     = "google.nodejs.runtime"
-	nodeYarn        = "google.nodejs.yarn"
-	pythonFF        = "google.python.functions-framework"
-	pythonPIP       = "google.python.pip"
-	pythonRuntime   = "google.python.runtime"
+	entrypoint       = "google.config.entrypoint"
+	configValidation = "google.config.validation"
+	dotnetFF         = "google.dotnet.functions-framework"
+	dotnetPublish    = "google.dotnet.publish"
+	dotnetRuntime    = "google.dotnet.runtime"
+	goBuild          = "google.go.build"
+	goClearSource    = "google.go.clear_source"
+	goFF             = "google.go.functions-framework"
+	goPath           = "google.go.gopath"
+	goRuntime        = "google.go.runtime"
+	javaClearSource  = "google.java.clear_source"
+	javaEntrypoint   = "google.java.entrypoint"
+	javaExplodedJar  = "google.java.exploded-jar"
+	javaGradle       = "google.java.gradle"
+	javaMaven        = "google.java.maven"
+	javaRuntime      = "google.java.runtime"
+	nodeFF           = "google.nodejs.functions-framework"
+	nodeNPM          = "google.nodejs.npm"
+	nodeRuntime      = "google.nodejs.runtime"
+	nodeYarn         = "google.nodejs.yarn"
+	pythonFF         = "google.python.functions-framework"
+	pythonPIP        = "google.python.pip"
+	pythonRuntime    = "google.python.runtime"
 )
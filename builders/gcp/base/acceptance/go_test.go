@@ -90,6 +90,36 @@ func TestAcceptanceGo(t *testing.T) {
 			FilesMustExist:    []string{"/layers/google.go.build/bin/main"},
 			FilesMustNotExist: []string{"/layers/google.go.runtime", "/workspace/main.go"},
 		},
+		{
+			// GOMEMLIMIT is 90% of the 256 MiB memory limit.
+			Name:    "GOMEMLIMIT from container limits",
+			App:     "go/simple",
+			Path:    "/env?name=GOMEMLIMIT&want=241591910",
+			RunArgs: []string{"--memory=256m"},
+			MustUse: []string{goRuntime, goBuild},
+		},
+		{
+			Name:    "GOMEMLIMIT set by the user",
+			App:     "go/simple",
+			Path:    "/env?name=GOMEMLIMIT&want=100MiB",
+			RunEnv:  []string{"GOMEMLIMIT=100MiB"},
+			RunArgs: []string{"--memory=256m"},
+			MustUse: []string{goRuntime, goBuild},
+		},
+		{
+			Name:    "container limits disabled",
+			App:     "go/simple",
+			Path:    "/env?name=GOMEMLIMIT&want=",
+			Env:     []string{"GOOGLE_GO_CONTAINER_LIMITS=false"},
+			RunArgs: []string{"--memory=256m"},
+			MustUse: []string{goRuntime, goBuild},
+		},
+		{
+			Name:    "config schema satisfied",
+			App:     "go/config_schema",
+			RunEnv:  []string{"DATABASE_URL=postgres://db.example.com/app", "WORKERS=4"},
+			MustUse: []string{configValidation, goRuntime, goBuild},
+		},
 	}
 	for _, tc := range testCases {
 		tc := tc
@@ -137,3 +167,44 @@ func TestFailuresGo(t *testing.T) {
 		})
 	}
 }
+
+func TestStartFailuresGo(t *testing.T) {
+	builder, cleanup := acceptance.CreateBuilder(t)
+	t.Cleanup(cleanup)
+
+	testCases := []acceptance.StartFailureTest{
+		{
+			Name:      "config schema required var missing",
+			App:       "go/config_schema",
+			MustMatch: `Refusing to start: invalid configuration declared in app\.config\.yaml:\s+DATABASE_URL is required but not set`,
+		},
+		{
+			Name:      "config schema malformed var",
+			App:       "go/config_schema",
+			RunEnv:    []string{"DATABASE_URL=postgres://db.example.com/app", "WORKERS=many"},
+			MustMatch: `WORKERS="many" is not a valid int`,
+		},
+		{
+			Name:      "malformed secret reference",
+			App:       "go/simple",
+			RunEnv:    []string{"API_KEY=sm://api-key"},
+			MustMatch: `Refusing to start: .*invalid secret reference "sm://api-key"`,
+		},
+		{
+			// The secret does not exist, and outside Google Cloud the metadata server is unreachable.
+			Name:      "unresolvable secret reference",
+			App:       "go/simple",
+			RunEnv:    []string{"API_KEY=sm://projects/buildpacks-acceptance/secrets/missing/versions/1"},
+			MustMatch: `Refusing to start: resolving API_KEY`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+
+			acceptance.TestStartFailure(t, builder, tc)
+		})
+	}
+}
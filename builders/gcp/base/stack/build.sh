@@ -15,8 +15,9 @@
 
 # The build.sh script builds stack images for the gcp/base builder.
 #
-# The script builds the following two images:
+# The script builds the following three images:
 #   gcr.io/buildpacks/gcp/run:$tag
+#   gcr.io/buildpacks/gcp/run-minimal:$tag
 #   gcr.io/buildpacks/gcp/build:$tag
 #
 # It also validates that the build image includes all required licenses.
@@ -45,5 +46,7 @@ echo "> Building gcp/base common image"
 docker build -t "common" - < "${DIR}/parent.Dockerfile"
 echo "> Building gcr.io/buildpacks/gcp/run:$TAG"
 docker build --build-arg "from_image=common" -t "gcr.io/buildpacks/gcp/run:$TAG" - < "${DIR}/run.Dockerfile"
+echo "> Building gcr.io/buildpacks/gcp/run-minimal:$TAG"
+docker build -t "gcr.io/buildpacks/gcp/run-minimal:$TAG" - < "${DIR}/minimal-run.Dockerfile"
 echo "> Building gcr.io/buildpacks/gcp/build:$TAG"
 docker build --build-arg "from_image=common" -t "gcr.io/buildpacks/gcp/build:$TAG" -f "${DIR}/build.Dockerfile" "${TEMP}"
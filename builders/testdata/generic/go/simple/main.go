@@ -18,6 +18,7 @@ package main
 import (
 	"fmt"
 	"net/http"
+	"os"
 	"runtime"
 )
 
@@ -40,8 +41,23 @@ func version(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// env checks that the env var ?name is set to ?want.
+func env(w http.ResponseWriter, r *http.Request) {
+	name, want := r.URL.Query().Get("name"), r.URL.Query().Get("want")
+	if name == "" {
+		fmt.Fprintf(w, "FAIL: ?name must be set to an env var")
+		return
+	}
+	if got := os.Getenv(name); got != want {
+		fmt.Fprintf(w, "FAIL: %s=%q, want %q", name, got, want)
+	} else {
+		fmt.Fprintf(w, "PASS")
+	}
+}
+
 func main() {
 	http.HandleFunc("/", handler)
 	http.HandleFunc("/version", version)
+	http.HandleFunc("/env", env)
 	http.ListenAndServe(":8080", nil)
 }
@@ -0,0 +1,60 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements config/validation buildpack.
+// The validation buildpack records the application's configuration schema and validates the environment at launch.
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/appconfig"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	layerName = "config-schema"
+	// validator is the exec.d executable shipped alongside the buildpack binary.
+	validator = "validate-config"
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) error {
+	if !ctx.FileExists(appconfig.SchemaFile) {
+		ctx.OptOut("%s not found", appconfig.SchemaFile)
+	}
+	return nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	data := ctx.ReadFile(filepath.Join(ctx.ApplicationRoot(), appconfig.SchemaFile))
+	schema, err := appconfig.Parse(data)
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	ctx.Logf("Found %d configuration env vars in %s, %d required", len(schema.Env), appconfig.SchemaFile, len(schema.Required()))
+
+	// Keep a copy of the schema in the layer so that validation works even if the source is cleared.
+	l := ctx.Layer(layerName, gcp.LaunchLayer)
+	schemaPath := filepath.Join(l.Path, appconfig.SchemaFile)
+	ctx.WriteFile(schemaPath, data, 0644)
+	ctx.SetMetadata(l, "required", strings.Join(schema.Required(), ","))
+	l.LaunchEnvironment.Default(appconfig.SchemaPathEnv, schemaPath)
+
+	return ctx.AddExecD(l, filepath.Join(ctx.BuildpackRoot(), "bin", validator))
+}
@@ -0,0 +1,37 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements the exec.d executable that validates the launch environment against the application's configuration schema.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/appconfig"
+)
+
+func main() {
+	path := os.Getenv(appconfig.SchemaPathEnv)
+	if path == "" {
+		log.Fatalf("%s is not set", appconfig.SchemaPathEnv)
+	}
+	schema, err := appconfig.ReadFile(path)
+	if err != nil {
+		log.Fatalf("Unable to read configuration schema: %v", err)
+	}
+	if err := schema.Validate(os.LookupEnv); err != nil {
+		log.Fatalf("Refusing to start: %v", err)
+	}
+}
@@ -98,6 +98,9 @@ func buildFn(ctx *gcp.Context) error {
 	}
 
 	ctx.Exec(cmd, gcp.WithEnv("DOTNET_CLI_TELEMETRY_OPTOUT=true"), gcp.WithUserAttribution)
+	if err := ctx.ExportArtifacts("bin"); err != nil {
+		return err
+	}
 
 	// Infer the entrypoint in case an explicit override was not provided.
 	entrypoint := os.Getenv(env.Entrypoint)
@@ -23,6 +23,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/dotnet"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
@@ -34,8 +35,8 @@ import (
 const (
 	sdkLayer       = "sdk"
 	runtimeLayer   = "runtime"
-	sdkURL         = "https://dotnetcli.azureedge.net/dotnet/Sdk/%[1]s/dotnet-sdk-%[1]s-linux-x64.tar.gz"
-	uncachedSdkURL = "https://dotnetcli.blob.core.windows.net/dotnet/Sdk/%[1]s/dotnet-sdk-%[1]s-linux-x64.tar.gz"
+	sdkURL         = "https://dotnetcli.azureedge.net/dotnet/Sdk/%[1]s/dotnet-sdk-%[1]s-%[2]s"
+	uncachedSdkURL = "https://dotnetcli.blob.core.windows.net/dotnet/Sdk/%[1]s/dotnet-sdk-%[1]s-%[2]s"
 	versionURL     = "https://dotnetcli.blob.core.windows.net/dotnet/Sdk/LTS/latest.version"
 	versionKey     = "version"
 )
@@ -59,9 +60,15 @@ func buildFn(ctx *gcp.Context) error {
 	if err != nil {
 		return err
 	}
+	if err := ctx.RecordFact(gcp.FactRuntimeVersion, version); err != nil {
+		return err
+	}
 
 	sdkl := ctx.Layer(sdkLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayerIfDevMode)
 	rtl := ctx.Layer(runtimeLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayer)
+	// The runtime layer holds the dotnet CLI and runtime from the same SDK archive.
+	ctx.AddTool(sdkl, "dotnet-sdk", version)
+	ctx.AddTool(rtl, "dotnet", version)
 
 	// Check the metadata in the cache layer to determine if we need to proceed.
 	// Each SDK is associated with one Core version, but the reverse is not true.
@@ -87,15 +94,21 @@ func buildFn(ctx *gcp.Context) error {
 	}
 
 	ctx.Logf("Installing .NET SDK v%s", version)
-	// Ensure there's a symlink from runtime/sdk dir to the sdk layer.
-	// TODO(b/150893022): remove the symlink in the final image.
-	ctx.Exec([]string{"ln", "--symbolic", "--force", sdkl.Path, filepath.Join(rtl.Path, "sdk")})
-
-	// With --keep-directory-symlink, the SDK will be unpacked into /runtime/sdk,
-	// which is symlinked to the SDK layer. This is needed because the dotnet CLI
-	// needs an sdk directory in the same directory as the dotnet executable.
-	command := fmt.Sprintf("curl --fail --show-error --silent --location --retry 3 %s | tar xz --directory %s --keep-directory-symlink --strip-components=1", archiveURL, rtl.Path)
-	ctx.Exec([]string{"bash", "-c", command}, gcp.WithUserAttribution)
+	if ctx.Platform() == gcp.Windows {
+		// Without directory symlinks to unpack through, the SDK stays in the runtime layer. Unlike the
+		// Linux tarballs, the zip archives have no leading ./ to strip.
+		ctx.InstallArchive(archiveURL, rtl.Path, 0)
+	} else {
+		installLinuxSDK(ctx, archiveURL, sdkl, rtl)
+	}
+	dotnet := filepath.Join(rtl.Path, "dotnet")
+	if ctx.Platform() == gcp.Windows {
+		dotnet += ".exe"
+	}
+	// The SDK needs ICU, which is provided by the stack, unless globalization is made invariant.
+	if err := runtime.SmokeTest(ctx, ".NET", []string{dotnet, "--info"}); err != nil {
+		return err
+	}
 
 	// Keep the SDK layer for launch in devmode because we use `dotnet watch`.
 	ctx.SetMetadata(sdkl, versionKey, version)
@@ -112,6 +125,20 @@ func buildFn(ctx *gcp.Context) error {
 	return nil
 }
 
+// installLinuxSDK unpacks the SDK archive at archiveURL into the runtime layer, with the sdk
+// directory symlinked to the SDK layer.
+func installLinuxSDK(ctx *gcp.Context, archiveURL string, sdkl, rtl *libcnb.Layer) {
+	// Ensure there's a symlink from runtime/sdk dir to the sdk layer.
+	// TODO(b/150893022): remove the symlink in the final image.
+	ctx.Exec([]string{"ln", "--symbolic", "--force", sdkl.Path, filepath.Join(rtl.Path, "sdk")})
+
+	// With --keep-directory-symlink, the SDK will be unpacked into /runtime/sdk,
+	// which is symlinked to the SDK layer. This is needed because the dotnet CLI
+	// needs an sdk directory in the same directory as the dotnet executable.
+	command := fmt.Sprintf("curl --fail --show-error --silent --location --retry 3 %s | tar xz --directory %s --keep-directory-symlink --strip-components=1", archiveURL, rtl.Path)
+	ctx.Exec([]string{"bash", "-c", command}, gcp.WithUserAttribution)
+}
+
 // globalJSON represents the contents of a global.json file.
 type globalJSON struct {
 	Sdk struct {
@@ -145,22 +172,34 @@ func runtimeVersion(ctx *gcp.Context) (string, error) {
 	}
 
 	// Use the latest LTS version.
-	command := fmt.Sprintf("curl --fail --show-error --silent --location %s | tail -n 1", versionURL)
-	result := ctx.Exec([]string{"bash", "-c", command}, gcp.WithUserAttribution)
-	version = result.Stdout
+	body, err := ctx.FetchMetadata(versionURL)
+	if err != nil {
+		return "", gcp.UserErrorf("getting the latest LTS version of .NET Core SDK: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	version = strings.TrimSpace(lines[len(lines)-1])
 	ctx.Logf("Using the latest LTS version of .NET Core SDK: %s", version)
 	return version, nil
 }
 
+// archiveSuffix returns the suffix of the names of the .NET SDK archives for the platform.
+func archiveSuffix(p gcp.Platform) string {
+	if p == gcp.Windows {
+		return "win-x64.zip"
+	}
+	return "linux-x64.tar.gz"
+}
+
 // archiveURL returns the URL to fetch the .NET SDK.
 func archiveURL(ctx *gcp.Context, version string) (string, error) {
-	url := fmt.Sprintf(sdkURL, version)
+	suffix := archiveSuffix(ctx.Platform())
+	url := fmt.Sprintf(sdkURL, version, suffix)
 	if code := ctx.HTTPStatus(url); code == http.StatusOK {
 		return url, nil
 	}
 
 	// Retry with the uncached URL.
-	url = fmt.Sprintf(uncachedSdkURL, version)
+	url = fmt.Sprintf(uncachedSdkURL, version, suffix)
 	if code := ctx.HTTPStatus(url); code != http.StatusOK {
 		return "", gcp.UserErrorf("Runtime version %s does not exist at %s (status %d). You can specify the version with %s.", version, url, code, env.RuntimeVersion)
 	}
@@ -19,18 +19,34 @@ package main
 import (
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/conformance"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/golang"
+	"github.com/buildpacks/libcnb"
 )
 
 const (
 	noGoFileError         = "no Go files in"
 	cannotFindModuleError = "cannot find module"
+	// raceLabel marks images compiled with the race detector.
+	raceLabel = "go_race"
+	// cacheLayer holds GOCACHE, the compiled packages of earlier builds.
+	cacheLayer   = "gocache"
+	goVersionKey = "go_version"
+)
+
+var (
+	// majorVersionRegexp matches the major version suffix of a module path, such as v2.
+	majorVersionRegexp = regexp.MustCompile(`^v[0-9]+$`)
+	// processTypeRegexp matches the process types allowed in launch.toml.
+	processTypeRegexp = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
 )
 
 func main() {
@@ -45,8 +61,9 @@ func detectFn(ctx *gcp.Context) error {
 }
 
 func buildFn(ctx *gcp.Context) error {
-	// Keep GOCACHE in Devmode for faster rebuilds.
-	cl := ctx.Layer("gocache", gcp.BuildLayer, gcp.LaunchLayerIfDevMode)
+	// Keep GOCACHE between builds, and in Devmode, for faster rebuilds.
+	cl := ctx.Layer(cacheLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayerIfDevMode)
+	useBuildCache(ctx, cl)
 	if devmode.Enabled(ctx) {
 		cl.LaunchEnvironment.Override("GOCACHE", cl.Path)
 	}
@@ -57,25 +74,193 @@ func buildFn(ctx *gcp.Context) error {
 	bl.LaunchEnvironment.PrependPath("PATH", bl.Path)
 	outBin := filepath.Join(bl.Path, golang.OutBin)
 
-	buildable, err := goBuildable(ctx)
-	if err != nil {
-		return fmt.Errorf("unable to find a valid buildable: %w", err)
+	// Catch divergence between vendor/ and go.mod/go.sum before it surfaces as compile errors.
+	if ctx.FileExists("vendor") && golang.SupportsAutoVendor(ctx) {
+		if err := golang.CheckVendorConsistency(ctx, ctx.ApplicationRoot()); err != nil {
+			return err
+		}
+	}
+
+	// Apps may build several main packages, each into a binary named after its directory.
+	var buildables, names []string
+	outBins := []string{outBin}
+	if v, ok := os.LookupEnv(env.Buildables); ok {
+		var err error
+		if buildables, err = listBuildables(ctx, v); err != nil {
+			return err
+		}
+		if names, err = binaryNames(buildables, repoName(golang.ModulePath(ctx))); err != nil {
+			return err
+		}
+		outBins = nil
+		for _, name := range names {
+			outBins = append(outBins, filepath.Join(bl.Path, name))
+		}
+		ctx.Logf("Building %d binaries: %s", len(names), strings.Join(names, ", "))
+	} else {
+		buildable, err := goBuildable(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to find a valid buildable: %w", err)
+		}
+		buildables = []string{buildable}
 	}
 
 	// Build the application.
-	bld := []string{"go", "build"}
-	bld = append(bld, goBuildFlags()...)
-	bld = append(bld, "-o", outBin)
-	bld = append(bld, buildable)
-	// BuildDirEnv should only be set by App Engine buildpacks.
+	var stamp []string
+	if ok, err := env.Bool(env.GoStamp, true); err != nil {
+		return gcp.UserErrorf("%v", err)
+	} else if ok {
+		if stamp, err = golang.StampFlags(ctx); err != nil {
+			return err
+		}
+	}
+	flags, err := goBuildFlags(stamp)
+	if err != nil {
+		return err
+	}
+	// BuildDirEnv should only be set by App Engine and functions buildpacks.
 	workdir := os.Getenv(golang.BuildDirEnv)
 	if workdir == "" {
 		workdir = ctx.ApplicationRoot()
 	}
-	ctx.Exec(bld, gcp.WithEnv("GOCACHE="+cl.Path), gcp.WithWorkDir(workdir), gcp.WithMessageProducer(printTipsAndKeepStderrTail(ctx)), gcp.WithUserAttribution)
+	bldEnv := []string{"GOCACHE=" + cl.Path}
+	target, err := golang.BuildTarget()
+	if err != nil {
+		return err
+	}
+	bldEnv = append(bldEnv, target.Env()...)
+	// Parsing cannot fail here, as goBuildFlags already parsed env.GoRace.
+	race, _ := env.Bool(env.GoRace, false)
+	if target.Cross() {
+		if race {
+			return gcp.UserErrorf("%s is not supported when cross-compiling for %s", env.GoRace, target)
+		}
+		if devmode.Enabled(ctx) {
+			return gcp.UserErrorf("dev mode is not supported when cross-compiling for %s", target)
+		}
+		ctx.Logf("Cross-compiling for %s", target)
+		if _, ok := os.LookupEnv("CGO_ENABLED"); !ok {
+			// The C toolchain of the builder only targets the builder's architecture.
+			bldEnv = append(bldEnv, "CGO_ENABLED=0")
+		}
+		golang.LabelTarget(ctx, target)
+	}
+	if race {
+		ctx.AddLabel(raceLabel, "true")
+		ctx.Warnf("Compiling with the race detector, which slows the app down and increases its memory usage; do not use %s in production.", env.GoRace)
+	}
+	cgo, cgoSet, err := golang.CgoSetting()
+	if err != nil {
+		return err
+	}
+	var cgoPkgs []string
+	if !target.Cross() && !race && !cgoSet {
+		cgoPkgs = golang.CgoPackages(ctx, workdir, buildables...)
+	}
+	minimal, err := env.Bool(env.GoMinimal, false)
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	static, err := staticBuild(ctx, minimal, race, cgo, cgoPkgs)
+	if err != nil {
+		return err
+	}
+	if minimal {
+		flags = append(flags, golang.StaticTagsFlag())
+	}
+	var blds [][]string
+	for i, buildable := range buildables {
+		bld := []string{"go", "build"}
+		bld = append(bld, flags...)
+		bld = append(bld, "-o", outBins[i])
+		bld = append(bld, buildable)
+		blds = append(blds, bld)
+	}
+	switch {
+	case static:
+		bldEnv = append(bldEnv, "CGO_ENABLED=0")
+	case target.Cross():
+		// A C toolchain for another target must come from the builder image.
+	case race || cgo || len(cgoPkgs) > 0:
+		// The race detector requires cgo, even if CGO_ENABLED=0 is set.
+		if len(cgoPkgs) > 0 {
+			ctx.Logf("Compiling with cgo, as these packages use it: %s", strings.Join(cgoPkgs, ", "))
+		}
+		cgoEnv, err := golang.ProvisionCToolchain(ctx)
+		if err != nil {
+			return err
+		}
+		bldEnv = append(bldEnv, cgoEnv...)
+		bldEnv = append(bldEnv, "CGO_ENABLED=1")
+	case cgoSet:
+		// CGO_ENABLED=0 is set.
+	default:
+		// Without cgo, the binary does not link against the libc of the run image.
+		bldEnv = append(bldEnv, "CGO_ENABLED=0")
+	}
+	purge := gcp.WithCorruptCacheRetry(func() error {
+		ctx.ClearLayer(cl)
+		return nil
+	})
+	if mainTest, ok := ctx.Handoff(gcp.HandoffGoMainTest); ok {
+		if target.Cross() {
+			ctx.Warnf("Skipping the self-test of the generated main, as the function is compiled for %s", target)
+		} else {
+			ctx.Logf("Running the self-test of the generated main")
+			test := append([]string{"go", "test"}, flags...)
+			test = append(test, "-count=1", "-run", "^TestGeneratedMain$", ".")
+			ctx.Exec(test, gcp.WithEnv(bldEnv...), gcp.WithWorkDir(filepath.Dir(mainTest)), gcp.WithCombinedTail, purge, gcp.WithUserAttribution)
+		}
+	}
+	// Functions are analyzed by the functions_framework buildpack, as only the generated main is built here.
+	if _, ok := os.LookupEnv(env.FunctionTarget); !ok {
+		if err := golang.Analyze(ctx, workdir, bldEnv); err != nil {
+			return err
+		}
+	}
+	for _, bld := range blds {
+		ctx.Exec(bld, gcp.WithEnv(bldEnv...), gcp.WithWorkDir(workdir), gcp.WithMessageProducer(printTipsAndKeepStderrTail(ctx)), purge, gcp.WithUserAttribution)
+	}
+	if err := ctx.ExportArtifacts(outBins...); err != nil {
+		return err
+	}
+	if minimal {
+		golang.ConfigureMinimalImage(ctx)
+	} else if static {
+		golang.ConfigureStaticImage(ctx)
+	} else if tz, err := golang.UsesTimeZones(ctx, workdir); err != nil {
+		return err
+	} else if tz {
+		golang.AddTimeZoneData(ctx)
+	}
+
+	// Functions are built as applications by the functions_framework buildpack, so they can be booted here.
+	if _, ok := os.LookupEnv(env.FunctionTarget); ok {
+		if ok, err := env.Bool(env.FunctionsConformance, false); err != nil {
+			return gcp.UserErrorf("%v", err)
+		} else if ok && target.Cross() {
+			ctx.Warnf("Skipping the conformance check, as the function was compiled for %s", target)
+		} else if ok {
+			if err := conformance.Check(ctx, []string{outBin}); err != nil {
+				return err
+			}
+		}
+	}
+
+	nonRoot, err := env.Bool(env.GoNonRoot, false)
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	if nonRoot {
+		golang.ConfigureNonRoot(ctx)
+	}
 
 	// Configure the entrypoint for production. Use the full path to save `skaffold debug`
 	// from fetching the remote container image (tens to hundreds of megabytes), which is slow.
+	if names != nil {
+		addProcesses(ctx, names, outBins)
+		return nil
+	}
 	if !devmode.Enabled(ctx) {
 		ctx.AddWebProcess([]string{outBin})
 		return nil
@@ -83,7 +268,7 @@ func buildFn(ctx *gcp.Context) error {
 
 	// Configure the entrypoint and metadata for dev mode.
 	devmode.AddFileWatcherProcess(ctx, devmode.Config{
-		BuildCmd: bld,
+		BuildCmd: blds[0],
 		RunCmd:   []string{outBin},
 		Ext:      devmode.GoWatchedExtensions,
 	})
@@ -93,6 +278,75 @@ func buildFn(ctx *gcp.Context) error {
 	return nil
 }
 
+// useBuildCache keeps the build cache of earlier builds with the same Go version, so that unchanged
+// packages are not compiled again. Entries of other Go versions are never used again, so the cache is
+// cleared when the version changes; the go command trims entries that are unused for days.
+func useBuildCache(ctx *gcp.Context, cl *libcnb.Layer) {
+	version := golang.GoVersion(ctx)
+	metaVersion := ctx.GetMetadata(cl, goVersionKey)
+	if version == metaVersion {
+		ctx.CacheHit(cacheLayer)
+		return
+	}
+	if metaVersion != "" {
+		ctx.ExplainCacheMiss(cacheLayer, "Go version changed from %s to %s", metaVersion, version)
+	}
+	ctx.CacheMiss(cacheLayer)
+	ctx.ClearLayer(cl)
+	ctx.SetMetadata(cl, goVersionKey, version)
+}
+
+// staticBuild returns true if the app is built as a static binary, as requested with env.GoStatic,
+// unless it requires cgo or the Go toolchain at launch. In minimal mode, the app must be built as a
+// static binary, as it would not run on the minimal run image otherwise. Neither mode supports
+// env.SourceSubdir.
+func staticBuild(ctx *gcp.Context, minimal, race, cgo bool, cgoPkgs []string) (bool, error) {
+	if minimal {
+		switch {
+		case race:
+			return false, gcp.UserErrorf("%s is not supported with %s, as the race detector requires cgo", env.GoRace, env.GoMinimal)
+		case cgo:
+			return false, gcp.UserErrorf("CGO_ENABLED=1 is not supported with %s, which builds a fully static binary", env.GoMinimal)
+		case devmode.Enabled(ctx):
+			return false, gcp.UserErrorf("dev mode is not supported with %s, as it rebuilds the app at launch", env.GoMinimal)
+		case os.Getenv(env.SourceSubdir) != "":
+			return false, gcp.UserErrorf("%s is not supported with %s, as starting the app in a subdirectory requires a shell, which the minimal run image does not have", env.SourceSubdir, env.GoMinimal)
+		case len(cgoPkgs) > 0:
+			return false, gcp.UserErrorf("%s requires a fully static binary, but these packages use cgo: %s", env.GoMinimal, strings.Join(cgoPkgs, ", "))
+		}
+		ctx.Logf("Building a fully static binary for the minimal run image")
+		return true, nil
+	}
+	static, err := env.Bool(env.GoStatic, false)
+	if err != nil {
+		return false, gcp.UserErrorf("%v", err)
+	}
+	if !static {
+		return false, nil
+	}
+	// The launcher of this lifecycle cannot set the working directory of a process, so processes start
+	// in a subdirectory through a shell, which the scratch-like images a static image is rebased onto lack.
+	if os.Getenv(env.SourceSubdir) != "" {
+		return false, gcp.UserErrorf("%s is not supported with %s, as starting the app in a subdirectory requires a shell, which a static image does not have", env.SourceSubdir, env.GoStatic)
+	}
+	switch {
+	case race:
+		ctx.Warnf("Not building a static binary, as %s requires cgo", env.GoRace)
+		return false, nil
+	case cgo:
+		ctx.Warnf("Not building a static binary, as CGO_ENABLED=1 is set")
+		return false, nil
+	case devmode.Enabled(ctx):
+		ctx.Warnf("Not building a static binary, as dev mode rebuilds the app at launch")
+		return false, nil
+	case len(cgoPkgs) > 0:
+		ctx.Warnf("Not building a static binary, as these packages use cgo: %s", strings.Join(cgoPkgs, ", "))
+		return false, nil
+	}
+	ctx.Logf("Building a static binary")
+	return true, nil
+}
+
 func goBuildable(ctx *gcp.Context) (string, error) {
 	// The user tells us what to build.
 	if buildable, ok := os.LookupEnv(env.Buildable); ok {
@@ -102,23 +356,130 @@ func goBuildable(ctx *gcp.Context) (string, error) {
 	// We have to guess which package/file to build.
 	// `go build` will by default build the `.` package
 	// but we try to be smarter by searching for a valid buildable.
-	buildables, err := searchBuildables(ctx)
+	buildables, err := searchBuildables(ctx, "./...")
 	if err != nil {
 		return "", err
 	}
 
-	if len(buildables) == 1 {
+	return chooseBuildable(buildables, repoName(golang.ModulePath(ctx)))
+}
+
+// chooseBuildable picks the main package to build among buildables. The `.` package is preferred,
+// as it is what `go build` builds by default, followed by the ./cmd/<repo-name> convention.
+func chooseBuildable(buildables []string, repo string) (string, error) {
+	switch len(buildables) {
+	case 0:
+		// Let Go build the default package and report why it cannot.
+		return ".", nil
+	case 1:
 		return buildables[0], nil
 	}
 
-	// Found no buildable or multiple buildables. Let Go build the default package.
-	return ".", nil
+	for _, b := range buildables {
+		if filepath.Clean(b) == "." {
+			return ".", nil
+		}
+	}
+	if repo != "" {
+		for _, b := range buildables {
+			if filepath.Clean(b) == filepath.Join("cmd", repo) {
+				return b, nil
+			}
+		}
+	}
+	return "", gcp.UserErrorf("found multiple main packages: %s; set %s to the package to build", strings.Join(buildables, ", "), env.Buildable)
 }
 
-// searchBuildables searches the source for all the files that contain
+// listBuildables returns the main packages listed in v, the value of env.Buildables. Patterns ending
+// in "..." are expanded to the main packages they match.
+func listBuildables(ctx *gcp.Context, v string) ([]string, error) {
+	if _, ok := os.LookupEnv(env.Buildable); ok {
+		return nil, gcp.UserErrorf("%s and %s cannot both be set", env.Buildable, env.Buildables)
+	}
+	if _, ok := os.LookupEnv(env.FunctionTarget); ok {
+		return nil, gcp.UserErrorf("%s is not supported for functions, which are built into a single binary", env.Buildables)
+	}
+	if devmode.Enabled(ctx) {
+		return nil, gcp.UserErrorf("dev mode is not supported with %s, as it rebuilds and runs a single binary", env.Buildables)
+	}
+	var buildables []string
+	for _, b := range strings.Split(v, ",") {
+		b = strings.TrimSpace(b)
+		if b == "" {
+			continue
+		}
+		if !strings.HasSuffix(b, "...") {
+			buildables = append(buildables, b)
+			continue
+		}
+		found, err := searchBuildables(ctx, b)
+		if err != nil {
+			return nil, err
+		}
+		if len(found) == 0 {
+			return nil, gcp.UserErrorf("%s: no main packages match %q", env.Buildables, b)
+		}
+		buildables = append(buildables, found...)
+	}
+	if len(buildables) == 0 {
+		return nil, gcp.UserErrorf("%s=%q does not list any package", env.Buildables, v)
+	}
+	return buildables, nil
+}
+
+// binaryNames returns the names of the binaries built from buildables, which are also their process
+// types: the last element of each package path, or repo for the root package.
+func binaryNames(buildables []string, repo string) ([]string, error) {
+	var names []string
+	seen := map[string]string{}
+	for _, b := range buildables {
+		name := path.Base(filepath.ToSlash(filepath.Clean(b)))
+		if name == "." {
+			name = repo
+		}
+		if name == "" || name == "." {
+			name = golang.OutBin
+		}
+		if !processTypeRegexp.MatchString(name) {
+			return nil, gcp.UserErrorf("%s: %q is not a valid process type for %s; process types may only contain letters, numbers, '.', '_' and '-'", env.Buildables, name, b)
+		}
+		if other, ok := seen[name]; ok {
+			return nil, gcp.UserErrorf("%s: %s and %s would both build a binary named %q", env.Buildables, other, b, name)
+		}
+		seen[name] = b
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// addProcesses registers each binary as a process of the type of its name. The binary named web is the
+// default process if there is one, and the first binary otherwise.
+func addProcesses(ctx *gcp.Context, names, bins []string) {
+	hasWeb := false
+	for i, name := range names {
+		ctx.AddProcess(name, []string{bins[i]})
+		hasWeb = hasWeb || name == "web"
+	}
+	if !hasWeb {
+		ctx.AddWebProcess([]string{bins[0]})
+		ctx.Logf("Using %s as the default web process", names[0])
+	}
+}
+
+// repoName returns the last element of a module path, ignoring a major version suffix.
+func repoName(modulePath string) string {
+	elems := strings.Split(modulePath, "/")
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && majorVersionRegexp.MatchString(name) {
+		name = elems[len(elems)-2]
+	}
+	return name
+}
+
+// searchBuildables searches the packages matching pattern for all the files that contain
 // a `main()` entrypoint.
-func searchBuildables(ctx *gcp.Context) ([]string, error) {
-	result := ctx.Exec([]string{"go", "list", "-f", `{{if eq .Name "main"}}{{.Dir}}{{end}}`, "./..."}, gcp.WithUserAttribution)
+func searchBuildables(ctx *gcp.Context, pattern string) ([]string, error) {
+	result := ctx.Exec([]string{"go", "list", "-f", `{{if eq .Name "main"}}{{.Dir}}{{end}}`, pattern}, gcp.WithUserAttribution)
 
 	var buildables []string
 
@@ -134,15 +495,40 @@ func searchBuildables(ctx *gcp.Context) ([]string, error) {
 	return buildables, nil
 }
 
-func goBuildFlags() []string {
+// goBuildFlags returns the flags of go build. The linker flags are stamp, followed by env.GoLDFlags
+// and env.GoLinkerFlags, as go build only uses the last -ldflags; stamp is dropped if env.GoLDFlags
+// applies to a package pattern, such as all=-s, which cannot be combined with other flags.
+func goBuildFlags(stamp []string) ([]string, error) {
 	var flags []string
+	race, err := env.Bool(env.GoRace, false)
+	if err != nil {
+		return nil, gcp.UserErrorf("%v", err)
+	}
+	if race {
+		flags = append(flags, "-race")
+	}
 	if v := os.Getenv(env.GoGCFlags); v != "" {
 		flags = append(flags, "-gcflags", v)
 	}
+	linkerFlags, err := golang.LinkerFlags()
+	if err != nil {
+		return nil, err
+	}
+	ldflags := stamp
 	if v := os.Getenv(env.GoLDFlags); v != "" {
-		flags = append(flags, "-ldflags", v)
+		if !strings.HasPrefix(v, "-") {
+			if len(linkerFlags) > 0 {
+				return nil, gcp.UserErrorf("%s cannot be combined with %s=%q, which applies to a package pattern", env.GoLinkerFlags, env.GoLDFlags, v)
+			}
+			ldflags = nil
+		}
+		ldflags = append(ldflags, v)
+	}
+	ldflags = append(ldflags, linkerFlags...)
+	if len(ldflags) > 0 {
+		flags = append(flags, "-ldflags", strings.Join(ldflags, " "))
 	}
-	return flags
+	return flags, nil
 }
 
 func printTipsAndKeepStderrTail(ctx *gcp.Context) gcp.MessageProducer {
@@ -155,6 +541,6 @@ func printTipsAndKeepStderrTail(ctx *gcp.Context) gcp.MessageProducer {
 			}
 		}
 
-		return gcp.KeepStderrTail(result)
+		return golang.KeepStderrTailWithSumDBHelp(result)
 	}
 }
@@ -15,12 +15,19 @@
 package main
 
 import (
+	"fmt"
+	"io/ioutil"
+	"log"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runner"
+	"github.com/buildpacks/libcnb"
 )
 
 func TestDetect(t *testing.T) {
@@ -57,7 +64,9 @@ func TestGoBuildFlags(t *testing.T) {
 	testCases := []struct {
 		name     string
 		env      []string
+		stamp    []string
 		expected []string
+		wantErr  bool
 	}{
 		{
 			name:     "no GOOGLE_GOGCFLAGS or GOOGLE_GOLDFLAGS",
@@ -78,11 +87,56 @@ func TestGoBuildFlags(t *testing.T) {
 			env:      []string{"GOOGLE_GOGCFLAGS=gcflags1 gcflags2", "GOOGLE_GOLDFLAGS=ldflags1 ldflags2"},
 			expected: []string{"-gcflags", "gcflags1 gcflags2", "-ldflags", "ldflags1 ldflags2"},
 		},
+		{
+			name:     "with GOOGLE_GO_RACE",
+			env:      []string{"GOOGLE_GO_RACE=true", "GOOGLE_GOLDFLAGS=ldflags"},
+			expected: []string{"-race", "-ldflags", "ldflags"},
+		},
+		{
+			name:     "with GOOGLE_GO_RACE disabled",
+			env:      []string{"GOOGLE_GO_RACE=false"},
+			expected: nil,
+		},
+		{
+			name:    "with invalid GOOGLE_GO_RACE",
+			env:     []string{"GOOGLE_GO_RACE=sometimes"},
+			wantErr: true,
+		},
+		{
+			name:     "with stamp",
+			stamp:    []string{"-X", "main.BuildTime=2020-01-01T00:00:00Z"},
+			expected: []string{"-ldflags", "-X main.BuildTime=2020-01-01T00:00:00Z"},
+		},
+		{
+			name:     "with stamp, GOOGLE_GOLDFLAGS and GOOGLE_GO_LDFLAGS",
+			env:      []string{"GOOGLE_GOLDFLAGS=-linkmode=internal", "GOOGLE_GO_LDFLAGS=-s -w -X main.env=prod"},
+			stamp:    []string{"-X", "main.BuildTime=2020-01-01T00:00:00Z"},
+			expected: []string{"-ldflags", "-X main.BuildTime=2020-01-01T00:00:00Z -linkmode=internal -s -w -X main.env=prod"},
+		},
+		{
+			name:     "with GOOGLE_GOLDFLAGS package pattern",
+			env:      []string{"GOOGLE_GOLDFLAGS=all=-s"},
+			stamp:    []string{"-X", "main.BuildTime=2020-01-01T00:00:00Z"},
+			expected: []string{"-ldflags", "all=-s"},
+		},
+		{
+			name:    "with GOOGLE_GOLDFLAGS package pattern and GOOGLE_GO_LDFLAGS",
+			env:     []string{"GOOGLE_GOLDFLAGS=all=-s", "GOOGLE_GO_LDFLAGS=-w"},
+			wantErr: true,
+		},
+		{
+			name:    "with disallowed GOOGLE_GO_LDFLAGS",
+			env:     []string{"GOOGLE_GO_LDFLAGS=-extld=/tmp/evil"},
+			wantErr: true,
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			clearAndSetEnv(tc.env)
-			result := goBuildFlags()
+			result, err := goBuildFlags(tc.stamp)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("goBuildFlags() got error: %v, want error: %t", err, tc.wantErr)
+			}
 			if !reflect.DeepEqual(tc.expected, result) {
 				t.Errorf("goBuildFlags() = %v, want %v", result, tc.expected)
 			}
@@ -90,6 +144,229 @@ func TestGoBuildFlags(t *testing.T) {
 	}
 }
 
+func TestChooseBuildable(t *testing.T) {
+	testCases := []struct {
+		name       string
+		buildables []string
+		repo       string
+		want       string
+		wantErr    bool
+	}{
+		{
+			name: "no main package",
+			want: ".",
+		},
+		{
+			name:       "single main package",
+			buildables: []string{"./cmd/server"},
+			want:       "./cmd/server",
+		},
+		{
+			name:       "root main package",
+			buildables: []string{"./.", "./cmd/tool"},
+			want:       ".",
+		},
+		{
+			name:       "cmd repo name",
+			buildables: []string{"./cmd/myapp", "./cmd/tool"},
+			repo:       "myapp",
+			want:       "./cmd/myapp",
+		},
+		{
+			name:       "ambiguous",
+			buildables: []string{"./cmd/server", "./cmd/tool"},
+			repo:       "myapp",
+			wantErr:    true,
+		},
+		{
+			name:       "ambiguous without module",
+			buildables: []string{"./cmd/server", "./cmd/tool"},
+			wantErr:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := chooseBuildable(tc.buildables, tc.repo)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("chooseBuildable(%v, %q) got error: %v, want error: %t", tc.buildables, tc.repo, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("chooseBuildable(%v, %q) = %q, want %q", tc.buildables, tc.repo, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRepoName(t *testing.T) {
+	testCases := []struct {
+		modulePath string
+		want       string
+	}{
+		{modulePath: "", want: ""},
+		{modulePath: "myapp", want: "myapp"},
+		{modulePath: "github.com/org/myapp", want: "myapp"},
+		{modulePath: "github.com/org/myapp/v2", want: "myapp"},
+	}
+	for _, tc := range testCases {
+		if got := repoName(tc.modulePath); got != tc.want {
+			t.Errorf("repoName(%q) = %q, want %q", tc.modulePath, got, tc.want)
+		}
+	}
+}
+
+func TestBinaryNames(t *testing.T) {
+	testCases := []struct {
+		name       string
+		buildables []string
+		repo       string
+		want       []string
+		wantErr    bool
+	}{
+		{
+			name:       "cmd packages",
+			buildables: []string{"./cmd/server", "./cmd/worker/"},
+			want:       []string{"server", "worker"},
+		},
+		{
+			name:       "root package",
+			buildables: []string{".", "./cmd/tool"},
+			repo:       "myapp",
+			want:       []string{"myapp", "tool"},
+		},
+		{
+			name:       "root package without module",
+			buildables: []string{"."},
+			want:       []string{"main"},
+		},
+		{
+			name:       "import path",
+			buildables: []string{"example.com/myapp/cmd/server"},
+			want:       []string{"server"},
+		},
+		{
+			name:       "duplicate name",
+			buildables: []string{"./cmd/server", "./internal/server"},
+			wantErr:    true,
+		},
+		{
+			name:       "invalid process type",
+			buildables: []string{"./cmd/my@server"},
+			wantErr:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := binaryNames(tc.buildables, tc.repo)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("binaryNames(%v, %q) got error: %v, want error: %t", tc.buildables, tc.repo, err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("binaryNames(%v, %q) = %v, want %v", tc.buildables, tc.repo, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestListBuildables(t *testing.T) {
+	testCases := []struct {
+		name    string
+		value   string
+		env     []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "list",
+			value: "./cmd/server, ./cmd/worker,",
+			want:  []string{"./cmd/server", "./cmd/worker"},
+		},
+		{
+			name:    "empty",
+			value:   " , ",
+			wantErr: true,
+		},
+		{
+			name:    "with buildable",
+			value:   "./cmd/server",
+			env:     []string{"GOOGLE_BUILDABLE=./cmd/worker"},
+			wantErr: true,
+		},
+		{
+			name:    "function",
+			value:   "./cmd/server",
+			env:     []string{"GOOGLE_FUNCTION_TARGET=HelloWorld"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer clearAndSetEnv(os.Environ())
+			clearAndSetEnv(tc.env)
+			ctx := gcp.NewContextForTests(libcnb.BuildpackInfo{ID: "google.go.build", Version: "0.0.1"}, "")
+
+			got, err := listBuildables(ctx, tc.value)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("listBuildables(%q) got error: %v, want error: %t", tc.value, err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("listBuildables(%q) = %v, want %v", tc.value, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAddProcesses(t *testing.T) {
+	testCases := []struct {
+		name  string
+		names []string
+		want  map[string]string
+	}{
+		{
+			name:  "first is web",
+			names: []string{"server", "worker"},
+			want:  map[string]string{"server": "/bin/server", "worker": "/bin/worker", "web": "/bin/server"},
+		},
+		{
+			name:  "named web",
+			names: []string{"worker", "web"},
+			want:  map[string]string{"worker": "/bin/worker", "web": "/bin/web"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			layers, err := ioutil.TempDir("", "layers")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(layers)
+			var bins []string
+			for _, name := range tc.names {
+				bins = append(bins, "/bin/"+name)
+			}
+
+			result, err := runner.Build(runner.Config{
+				Buildpack:  libcnb.BuildpackInfo{ID: "google.go.build", Version: "0.0.1"},
+				LayersRoot: layers,
+				Logger:     log.New(ioutil.Discard, "", 0),
+			}, func(ctx *gcp.Context) error {
+				addProcesses(ctx, tc.names, bins)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("addProcesses() got error: %v", err)
+			}
+
+			got := map[string]string{}
+			for _, p := range result.Processes {
+				got[p.Type] = p.Command
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("addProcesses(%v) processes = %v, want %v", tc.names, got, tc.want)
+			}
+		})
+	}
+}
+
 func clearAndSetEnv(env []string) {
 	os.Clearenv()
 	for _, p := range env {
@@ -99,3 +376,131 @@ func clearAndSetEnv(env []string) {
 		}
 	}
 }
+
+// versionExecutor answers `go version` with a fixed version.
+type versionExecutor struct {
+	version string
+}
+
+func (e versionExecutor) Run(cmd *exec.Cmd) (int, error) {
+	fmt.Fprintf(cmd.Stdout, "go version go%s linux/amd64", e.version)
+	return 0, nil
+}
+
+func TestUseBuildCache(t *testing.T) {
+	testCases := []struct {
+		name        string
+		cached      string
+		wantCleared bool
+	}{
+		{
+			name:   "same version",
+			cached: "1.22.3",
+		},
+		{
+			name:        "version changed",
+			cached:      "1.21.0",
+			wantCleared: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			layers, err := ioutil.TempDir("", "layers")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(layers)
+			entry := filepath.Join(layers, cacheLayer, "00", "entry-a")
+			if err := os.MkdirAll(filepath.Dir(entry), 0755); err != nil {
+				t.Fatalf("creating cache: %v", err)
+			}
+			if err := ioutil.WriteFile(entry, []byte("compiled"), 0644); err != nil {
+				t.Fatalf("writing cache entry: %v", err)
+			}
+			toml := fmt.Sprintf("cache = true\n\n[metadata]\n  %s = %q\n", goVersionKey, tc.cached)
+			if err := ioutil.WriteFile(filepath.Join(layers, cacheLayer+".toml"), []byte(toml), 0644); err != nil {
+				t.Fatalf("writing layer metadata: %v", err)
+			}
+
+			var got string
+			_, err = runner.Build(runner.Config{
+				Buildpack:  libcnb.BuildpackInfo{ID: "google.go.build", Version: "0.0.1"},
+				LayersRoot: layers,
+				Executor:   versionExecutor{version: "1.22.3"},
+				Logger:     log.New(ioutil.Discard, "", 0),
+			}, func(ctx *gcp.Context) error {
+				cl := ctx.Layer(cacheLayer, gcp.BuildLayer, gcp.CacheLayer)
+				useBuildCache(ctx, cl)
+				got = ctx.GetMetadata(cl, goVersionKey)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("useBuildCache() got error: %v", err)
+			}
+
+			if got != "1.22.3" {
+				t.Errorf("%s metadata = %q, want %q", goVersionKey, got, "1.22.3")
+			}
+			_, err = os.Stat(entry)
+			if cleared := os.IsNotExist(err); cleared != tc.wantCleared {
+				t.Errorf("cache cleared = %t, want %t", cleared, tc.wantCleared)
+			}
+		})
+	}
+}
+
+func TestStaticBuild(t *testing.T) {
+	testCases := []struct {
+		name    string
+		minimal bool
+		env     map[string]string
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "default",
+		},
+		{
+			name: "static",
+			env:  map[string]string{"GOOGLE_GO_STATIC": "true"},
+			want: true,
+		},
+		{
+			name:    "minimal",
+			minimal: true,
+			want:    true,
+		},
+		{
+			name:    "static with source subdirectory",
+			env:     map[string]string{"GOOGLE_GO_STATIC": "true", "GOOGLE_SOURCE_SUBDIR": "api"},
+			wantErr: true,
+		},
+		{
+			name:    "minimal with source subdirectory",
+			minimal: true,
+			env:     map[string]string{"GOOGLE_SOURCE_SUBDIR": "api"},
+			wantErr: true,
+		},
+		{
+			name: "source subdirectory",
+			env:  map[string]string{"GOOGLE_SOURCE_SUBDIR": "api"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				os.Setenv(k, v)
+				defer os.Unsetenv(k)
+			}
+			ctx := gcp.NewContextForTests(libcnb.BuildpackInfo{ID: "google.go.build", Version: "0.0.1"}, "")
+
+			got, err := staticBuild(ctx, tc.minimal, false, false, nil)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("staticBuild() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("staticBuild() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
@@ -18,13 +18,28 @@ package main
 import (
 	"flag"
 	"fmt"
+	"go/ast"
 	"go/parser"
 	"go/token"
 	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// functionsPackage is the functions framework package with which functions register themselves.
+	functionsPackage = "github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	// invalidTargetCode is the exit code with which -target reports an invalid function target.
+	invalidTargetCode = 3
 )
 
 var (
-	dir = flag.String("dir", "", "Directory containing *.go files from which to extract a package name.")
+	dir           = flag.String("dir", "", "Directory containing *.go files from which to extract a package name.")
+	registrations = flag.Bool("registrations", false, "Print the names of the functions registered with the functions package instead, one per line.")
+	middleware    = flag.Bool("middleware", false, "Print the name of the middleware declared by the package, FunctionMiddleware, if any, instead.")
+	target        = flag.String("target", "", "Validate the function target, optionally qualified with the directory of its package as in subpkg.Handler, and print its kind (http, cloudevent, event, declarative or unknown), package directory and name instead, separated by spaces.")
 )
 
 // extract extracts the name of the package in the specified directory.
@@ -57,6 +72,65 @@ func extract(source string) (string, error) {
 	return packageName, nil
 }
 
+// extractRegistrations returns the names of the functions that the package in the specified directory
+// registers declaratively with functions.HTTP or functions.CloudEvent, sorted.
+func extractRegistrations(source string) ([]string, error) {
+	fset := token.NewFileSet()
+	notTest := func(fi os.FileInfo) bool { return !strings.HasSuffix(fi.Name(), "_test.go") }
+	pkgs, err := parser.ParseDir(fset, source, notTest, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse source in %s: %v", source, err)
+	}
+
+	var names []string
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Files {
+			alias := functionsImportName(f)
+			if alias == "" {
+				continue
+			}
+			ast.Inspect(f, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok || len(call.Args) == 0 {
+					return true
+				}
+				sel, ok := call.Fun.(*ast.SelectorExpr)
+				if !ok || (sel.Sel.Name != "HTTP" && sel.Sel.Name != "CloudEvent") {
+					return true
+				}
+				if x, ok := sel.X.(*ast.Ident); !ok || x.Name != alias {
+					return true
+				}
+				lit, ok := call.Args[0].(*ast.BasicLit)
+				if !ok || lit.Kind != token.STRING {
+					return true
+				}
+				if name, err := strconv.Unquote(lit.Value); err == nil {
+					names = append(names, name)
+				}
+				return true
+			})
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// functionsImportName returns the name with which f refers to the functions package, or "" if f
+// does not import it.
+func functionsImportName(f *ast.File) string {
+	for _, imp := range f.Imports {
+		if path, err := strconv.Unquote(imp.Path.Value); err != nil || path != functionsPackage {
+			continue
+		}
+		if imp.Name != nil {
+			return imp.Name.Name
+		}
+		return "functions"
+	}
+	return ""
+}
+
 func main() {
 	flag.Parse()
 
@@ -64,6 +138,45 @@ func main() {
 		log.Fatalf("No directory specified.")
 	}
 
+	if *target != "" {
+		t, err := validateTarget(*dir, *target)
+		// The converter is built with the function's Go version, which may predate errors.As.
+		if te, ok := err.(*targetError); ok {
+			// Invalid targets are reported on stderr without a timestamp, for the buildpack to show to users.
+			fmt.Fprintln(os.Stderr, te.msg)
+			os.Exit(invalidTargetCode)
+		}
+		if err != nil {
+			log.Fatalf("Unable to validate function target: %v.", err)
+		}
+		fmt.Printf("%s %s %s", t.kind, t.pkgDir, t.name)
+		return
+	}
+
+	if *middleware {
+		found, err := findMiddleware(*dir)
+		if te, ok := err.(*targetError); ok {
+			fmt.Fprintln(os.Stderr, te.msg)
+			os.Exit(invalidTargetCode)
+		}
+		if err != nil {
+			log.Fatalf("Unable to find middleware: %v.", err)
+		}
+		if found {
+			fmt.Print(middlewareName)
+		}
+		return
+	}
+
+	if *registrations {
+		names, err := extractRegistrations(*dir)
+		if err != nil {
+			log.Fatalf("Unable to extract function registrations: %v.", err)
+		}
+		fmt.Print(strings.Join(names, "\n"))
+		return
+	}
+
 	pkg, err := extract(*dir)
 	if err != nil {
 		log.Fatalf("Unable to extract package name: %v.", err)
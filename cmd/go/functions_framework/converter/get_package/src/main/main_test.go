@@ -18,6 +18,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -122,3 +123,86 @@ func TestExtractPackageNameFromDirErrors(t *testing.T) {
 		})
 	}
 }
+
+func TestExtractRegistrations(t *testing.T) {
+	tcs := []struct {
+		name  string
+		files map[string]string
+		want  []string
+	}{
+		{
+			name:  "no registrations",
+			files: map[string]string{"foo.go": "package foo\n\nfunc HelloWorld() {}"},
+		},
+		{
+			name: "http and cloudevent",
+			files: map[string]string{
+				"foo.go": `package foo
+
+import "github.com/GoogleCloudPlatform/functions-framework-go/functions"
+
+func init() {
+	functions.HTTP("HelloHTTP", helloHTTP)
+	functions.CloudEvent("HelloEvent", helloEvent)
+}`,
+			},
+			want: []string{"HelloEvent", "HelloHTTP"},
+		},
+		{
+			name: "renamed import",
+			files: map[string]string{
+				"foo.go": `package foo
+
+import ff "github.com/GoogleCloudPlatform/functions-framework-go/functions"
+
+func init() { ff.HTTP("HelloHTTP", helloHTTP) }`,
+			},
+			want: []string{"HelloHTTP"},
+		},
+		{
+			name: "other functions package",
+			files: map[string]string{
+				"foo.go": `package foo
+
+import "example.com/functions"
+
+func init() { functions.HTTP("HelloHTTP", helloHTTP) }`,
+			},
+		},
+		{
+			name: "test files",
+			files: map[string]string{
+				"foo.go": "package foo",
+				"foo_test.go": `package foo
+
+import "github.com/GoogleCloudPlatform/functions-framework-go/functions"
+
+func init() { functions.HTTP("TestOnly", helloHTTP) }`,
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "golang_bp_test")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+
+			for f, c := range tc.files {
+				if err := ioutil.WriteFile(filepath.Join(dir, f), []byte(c), 0644); err != nil {
+					t.Fatalf("writing file %s: %v", f, err)
+				}
+			}
+
+			got, err := extractRegistrations(dir)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("incorrect registrations: got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"strings"
+)
+
+const (
+	// middlewareName is the function with which a function package wraps the handler that serves it,
+	// e.g. for request logging, authentication or panic recovery.
+	middlewareName = "FunctionMiddleware"
+	// middlewareSignature is the signature required of middlewareName.
+	middlewareSignature = "func(http.Handler) http.Handler"
+)
+
+// findMiddleware reports whether the package in the specified directory declares middlewareName,
+// and fails if it is declared with a signature other than middlewareSignature.
+func findMiddleware(source string) (bool, error) {
+	fset := token.NewFileSet()
+	notTest := func(fi os.FileInfo) bool { return !strings.HasSuffix(fi.Name(), "_test.go") }
+	pkgs, err := parser.ParseDir(fset, source, notTest, 0)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse source in %s: %v", source, err)
+	}
+
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Files {
+			for _, decl := range f.Decls {
+				switch d := decl.(type) {
+				case *ast.FuncDecl:
+					if d.Recv == nil && d.Name.Name == middlewareName {
+						return true, checkMiddleware(fset, f, d.Type)
+					}
+				case *ast.GenDecl:
+					for _, spec := range d.Specs {
+						vs, ok := spec.(*ast.ValueSpec)
+						if !ok {
+							continue
+						}
+						for _, name := range vs.Names {
+							if name.Name != middlewareName {
+								continue
+							}
+							// Untyped variables would require type checking; they are left to the compiler.
+							if ft, ok := vs.Type.(*ast.FuncType); ok {
+								return true, checkMiddleware(fset, f, ft)
+							}
+							if d.Tok == token.VAR && vs.Type == nil {
+								return true, nil
+							}
+							return true, targetErrorf("%s must be a function with the signature %s", middlewareName, middlewareSignature)
+						}
+					}
+				}
+			}
+		}
+	}
+	return false, nil
+}
+
+// checkMiddleware checks that the middleware of type ft, declared in f, has middlewareSignature.
+func checkMiddleware(fset *token.FileSet, f *ast.File, ft *ast.FuncType) error {
+	params := fieldTypes(ft.Params)
+	results := fieldTypes(ft.Results)
+	httpName := importName(f, "net/http")
+	if len(params) == 1 && len(results) == 1 && isSelector(params[0], httpName, "Handler") && isSelector(results[0], httpName, "Handler") {
+		return nil
+	}
+	return targetErrorf("%s has the unsupported signature %s; it must have the signature %s", middlewareName, nodeString(fset, ft), middlewareSignature)
+}
@@ -0,0 +1,119 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindMiddleware(t *testing.T) {
+	tcs := []struct {
+		name    string
+		src     string
+		want    bool
+		wantErr string
+	}{
+		{
+			name: "none",
+			src: `package foo
+
+import "net/http"
+
+func HelloWorld(w http.ResponseWriter, r *http.Request) {}`,
+		},
+		{
+			name: "function",
+			src: `package foo
+
+import "net/http"
+
+func FunctionMiddleware(next http.Handler) http.Handler { return next }`,
+			want: true,
+		},
+		{
+			name: "renamed import",
+			src: `package foo
+
+import h "net/http"
+
+func FunctionMiddleware(next h.Handler) h.Handler { return next }`,
+			want: true,
+		},
+		{
+			name: "variable of call result",
+			src: `package foo
+
+var FunctionMiddleware = newMiddleware()`,
+			want: true,
+		},
+		{
+			name: "method",
+			src: `package foo
+
+import "net/http"
+
+type server struct{}
+
+func (server) FunctionMiddleware(next http.Handler) http.Handler { return next }`,
+		},
+		{
+			name: "wrong signature",
+			src: `package foo
+
+import "net/http"
+
+func FunctionMiddleware(next http.HandlerFunc) http.HandlerFunc { return next }`,
+			wantErr: "unsupported signature func(next http.HandlerFunc) http.HandlerFunc",
+		},
+		{
+			name: "constant",
+			src: `package foo
+
+const FunctionMiddleware = "logging"`,
+			wantErr: "must be a function",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "golang_bp_test")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			if err := ioutil.WriteFile(filepath.Join(dir, "foo.go"), []byte(tc.src), 0644); err != nil {
+				t.Fatalf("writing file: %v", err)
+			}
+
+			got, err := findMiddleware(dir)
+
+			if tc.wantErr != "" {
+				if _, ok := err.(*targetError); !ok || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("findMiddleware() got error: %v, want a target error containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("findMiddleware() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("findMiddleware() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,339 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Kinds of function targets, printed by -target.
+const (
+	kindHTTP        = "http"
+	kindCloudEvent  = "cloudevent"
+	kindEvent       = "event"
+	kindDeclarative = "declarative"
+	// kindUnknown is a function value whose signature cannot be determined without type checking,
+	// such as the result of a function call; it is left to the compiler.
+	kindUnknown = "unknown"
+)
+
+const (
+	cloudEventsPackage      = "github.com/cloudevents/sdk-go/v2"
+	cloudEventsEventPackage = "github.com/cloudevents/sdk-go/v2/event"
+)
+
+// supportedSignatures describes the signatures accepted for function targets.
+const supportedSignatures = "func(http.ResponseWriter, *http.Request) for HTTP functions, func(context.Context, cloudevents.Event) error for CloudEvent functions, or func(context.Context, E) error for background functions of event type E"
+
+// targetError is a problem with the function target that the user must fix.
+type targetError struct {
+	msg string
+}
+
+func (e *targetError) Error() string {
+	return e.msg
+}
+
+func targetErrorf(format string, args ...interface{}) error {
+	return &targetError{msg: fmt.Sprintf(format, args...)}
+}
+
+// resolvedTarget is a valid function target.
+type resolvedTarget struct {
+	// kind is the kind of the function.
+	kind string
+	// pkgDir is the slash-separated directory of the package that defines or registers the function,
+	// relative to the source directory, or "." for the package in the source directory.
+	pkgDir string
+	// name is the name of the function within its package.
+	name string
+}
+
+// validateTarget checks that target is registered with the functions package, or is an exported
+// function with a supported signature, of the package in the specified directory or, for targets
+// qualified with a package directory such as subpkg.Handler or sub/pkg.Handler, of the package in
+// that subdirectory. Only the syntax of the package is inspected, since its dependencies are not
+// available to the converter.
+func validateTarget(source, target string) (resolvedTarget, error) {
+	registered, err := extractRegistrations(source)
+	if err != nil {
+		return resolvedTarget{}, err
+	}
+	for _, name := range registered {
+		if name == target {
+			return resolvedTarget{kind: kindDeclarative, pkgDir: ".", name: target}, nil
+		}
+	}
+
+	if i := strings.LastIndex(target, "."); i >= 0 {
+		pkgDir, name := target[:i], target[i+1:]
+		dir, err := subpackageDir(source, pkgDir)
+		if err != nil {
+			return resolvedTarget{}, err
+		}
+		if name == "" {
+			return resolvedTarget{}, targetErrorf("%s must name a function after the package directory, as in %s.Handler", target, pkgDir)
+		}
+		t, err := validateTarget(dir, name)
+		if err != nil {
+			return resolvedTarget{}, err
+		}
+		t.pkgDir = path.Join(pkgDir, t.pkgDir)
+		return t, nil
+	}
+
+	kind, err := validateSymbol(source, target, registered)
+	if err != nil {
+		return resolvedTarget{}, err
+	}
+	return resolvedTarget{kind: kind, pkgDir: ".", name: target}, nil
+}
+
+// subpackageDir returns the directory of the package in the slash-separated directory pkgDir, relative
+// to source, if the generated main can import it.
+func subpackageDir(source, pkgDir string) (string, error) {
+	if pkgDir == "" || path.IsAbs(pkgDir) || path.Clean(pkgDir) != pkgDir {
+		return "", targetErrorf("package directory %q must be a clean relative path such as sub/pkg", pkgDir)
+	}
+	for _, elem := range strings.Split(pkgDir, "/") {
+		switch {
+		case elem == "..":
+			return "", targetErrorf("package directory %q must be within the function source", pkgDir)
+		case elem == "internal":
+			return "", targetErrorf("package directory %q is an internal package, which cannot be imported by the generated main", pkgDir)
+		case elem == "vendor" || elem == "testdata" || strings.HasPrefix(elem, ".") || strings.HasPrefix(elem, "_"):
+			return "", targetErrorf("package directory %q is ignored by the go command", pkgDir)
+		}
+	}
+	dir := filepath.Join(source, filepath.FromSlash(pkgDir))
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		return "", targetErrorf("package directory %s not found in the function source", pkgDir)
+	}
+	return dir, nil
+}
+
+// validateSymbol checks that target is an exported function of the package in the specified
+// directory with a supported signature, and returns its kind.
+func validateSymbol(source, target string, registered []string) (string, error) {
+	fset := token.NewFileSet()
+	notTest := func(fi os.FileInfo) bool { return !strings.HasSuffix(fi.Name(), "_test.go") }
+	pkgs, err := parser.ParseDir(fset, source, notTest, 0)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse source in %s: %v", source, err)
+	}
+
+	for pkgName, pkg := range pkgs {
+		for _, f := range pkg.Files {
+			for _, decl := range f.Decls {
+				if kind, found, err := checkDecl(fset, f, pkg, decl, target); found {
+					return kind, err
+				}
+			}
+		}
+
+		var similar []string
+		for _, f := range pkg.Files {
+			for _, name := range topLevelNames(f) {
+				if strings.EqualFold(name, target) {
+					similar = append(similar, name)
+				}
+			}
+		}
+		if len(similar) > 0 {
+			return "", targetErrorf("function %s not found in package %s, did you mean %s?", target, pkgName, strings.Join(similar, " or "))
+		}
+		if len(registered) > 0 {
+			return "", targetErrorf("function %s not found in package %s, which registers %s with the functions package", target, pkgName, strings.Join(registered, ", "))
+		}
+		return "", targetErrorf("function %s not found in package %s", target, pkgName)
+	}
+	return "", fmt.Errorf("unable to find Go package in %s", source)
+}
+
+// checkDecl reports whether decl declares target and, if so, its kind or why it is not a valid target.
+func checkDecl(fset *token.FileSet, f *ast.File, pkg *ast.Package, decl ast.Decl, target string) (string, bool, error) {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Recv != nil || d.Name.Name != target {
+			return "", false, nil
+		}
+		if !d.Name.IsExported() {
+			return "", true, notExported(target)
+		}
+		kind, err := checkSignature(fset, f, target, d.Type)
+		return kind, true, err
+	case *ast.GenDecl:
+		for _, spec := range d.Specs {
+			vs, ok := spec.(*ast.ValueSpec)
+			if !ok {
+				continue
+			}
+			for i, name := range vs.Names {
+				if name.Name != target {
+					continue
+				}
+				if !name.IsExported() {
+					return "", true, notExported(target)
+				}
+				if d.Tok != token.VAR {
+					return "", true, targetErrorf("%s must be a function, found a constant", target)
+				}
+				if ft, ok := vs.Type.(*ast.FuncType); ok {
+					kind, err := checkSignature(fset, f, target, ft)
+					return kind, true, err
+				}
+				if vs.Type == nil && i < len(vs.Values) {
+					kind, err := checkValue(fset, f, pkg, target, vs.Values[i])
+					return kind, true, err
+				}
+				// Variables of named types, such as http.HandlerFunc, are not supported by the generated main.
+				return "", true, targetErrorf("%s must be a function with one of the signatures %s, found a variable of type %s", target, supportedSignatures, nodeString(fset, vs.Type))
+			}
+		}
+	}
+	return "", false, nil
+}
+
+// checkValue returns the kind of the untyped function variable target initialized with value.
+func checkValue(fset *token.FileSet, f *ast.File, pkg *ast.Package, target string, value ast.Expr) (string, error) {
+	switch v := value.(type) {
+	case *ast.FuncLit:
+		return checkSignature(fset, f, target, v.Type)
+	case *ast.Ident:
+		// A variable initialized with a function of the same package.
+		for _, pf := range pkg.Files {
+			for _, decl := range pf.Decls {
+				if fd, ok := decl.(*ast.FuncDecl); ok && fd.Recv == nil && fd.Name.Name == v.Name {
+					return checkSignature(fset, pf, target, fd.Type)
+				}
+			}
+		}
+	case *ast.BasicLit, *ast.CompositeLit:
+		return "", targetErrorf("%s must be a function with one of the signatures %s", target, supportedSignatures)
+	}
+	return kindUnknown, nil
+}
+
+// checkSignature returns the kind of the function target of type ft, declared in f.
+func checkSignature(fset *token.FileSet, f *ast.File, target string, ft *ast.FuncType) (string, error) {
+	params := fieldTypes(ft.Params)
+	results := fieldTypes(ft.Results)
+	if len(params) == 2 && len(results) == 0 &&
+		isSelector(params[0], importName(f, "net/http"), "ResponseWriter") {
+		if star, ok := params[1].(*ast.StarExpr); ok && isSelector(star.X, importName(f, "net/http"), "Request") {
+			return kindHTTP, nil
+		}
+	}
+	if len(params) == 2 && len(results) == 1 &&
+		isSelector(params[0], importName(f, "context"), "Context") && isIdent(results[0], "error") {
+		if isSelector(params[1], importName(f, cloudEventsPackage), "Event") || isSelector(params[1], importName(f, cloudEventsEventPackage), "Event") {
+			return kindCloudEvent, nil
+		}
+		return kindEvent, nil
+	}
+	return "", targetErrorf("function %s has the unsupported signature %s; functions must have the signature %s", target, nodeString(fset, ft), supportedSignatures)
+}
+
+func notExported(target string) error {
+	return targetErrorf("function %s is not exported; rename it to %s", target, strings.ToUpper(target[:1])+target[1:])
+}
+
+// fieldTypes returns the type of every parameter or result in fl, repeating the type of grouped names.
+func fieldTypes(fl *ast.FieldList) []ast.Expr {
+	if fl == nil {
+		return nil
+	}
+	var types []ast.Expr
+	for _, field := range fl.List {
+		n := len(field.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			types = append(types, field.Type)
+		}
+	}
+	return types
+}
+
+// importName returns the name with which f refers to the package with the given path, or "" if f
+// does not import it.
+func importName(f *ast.File, path string) string {
+	for _, imp := range f.Imports {
+		if p, err := strconv.Unquote(imp.Path.Value); err != nil || p != path {
+			continue
+		}
+		if imp.Name != nil {
+			return imp.Name.Name
+		}
+		return path[strings.LastIndex(path, "/")+1:]
+	}
+	return ""
+}
+
+func isSelector(e ast.Expr, pkg, name string) bool {
+	sel, ok := e.(*ast.SelectorExpr)
+	if !ok || pkg == "" || sel.Sel.Name != name {
+		return false
+	}
+	x, ok := sel.X.(*ast.Ident)
+	return ok && x.Name == pkg
+}
+
+func isIdent(e ast.Expr, name string) bool {
+	id, ok := e.(*ast.Ident)
+	return ok && id.Name == name
+}
+
+// topLevelNames returns the names of the functions and variables declared at the top level of f.
+func topLevelNames(f *ast.File) []string {
+	var names []string
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil {
+				names = append(names, d.Name.Name)
+			}
+		case *ast.GenDecl:
+			if d.Tok != token.VAR {
+				continue
+			}
+			for _, spec := range d.Specs {
+				for _, name := range spec.(*ast.ValueSpec).Names {
+					names = append(names, name.Name)
+				}
+			}
+		}
+	}
+	return names
+}
+
+func nodeString(fset *token.FileSet, n ast.Node) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, n); err != nil {
+		return fmt.Sprintf("%T", n)
+	}
+	return buf.String()
+}
@@ -0,0 +1,344 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateTarget(t *testing.T) {
+	tcs := []struct {
+		name   string
+		target string
+		src    string
+		// files are written in addition to src, which is written to foo.go.
+		files    map[string]string
+		want     string
+		wantDir  string
+		wantName string
+		wantErr  string
+		internal bool
+	}{
+		{
+			name:   "http",
+			target: "HelloWorld",
+			src: `package foo
+
+import "net/http"
+
+func HelloWorld(w http.ResponseWriter, r *http.Request) {}`,
+			want: kindHTTP,
+		},
+		{
+			name:   "http with renamed import",
+			target: "HelloWorld",
+			src: `package foo
+
+import h "net/http"
+
+func HelloWorld(_ h.ResponseWriter, _ *h.Request) {}`,
+			want: kindHTTP,
+		},
+		{
+			name:   "cloudevent",
+			target: "HelloEvent",
+			src: `package foo
+
+import (
+	"context"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+func HelloEvent(ctx context.Context, e cloudevents.Event) error { return nil }`,
+			want: kindCloudEvent,
+		},
+		{
+			name:   "cloudevent from event package",
+			target: "HelloEvent",
+			src: `package foo
+
+import (
+	"context"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+func HelloEvent(ctx context.Context, e event.Event) error { return nil }`,
+			want: kindCloudEvent,
+		},
+		{
+			name:   "background event",
+			target: "HelloPubSub",
+			src: `package foo
+
+import "context"
+
+type PubSubMessage struct{ Data []byte }
+
+func HelloPubSub(ctx context.Context, m PubSubMessage) error { return nil }`,
+			want: kindEvent,
+		},
+		{
+			name:   "function literal variable",
+			target: "HelloWorld",
+			src: `package foo
+
+import "net/http"
+
+var HelloWorld = func(w http.ResponseWriter, r *http.Request) {}`,
+			want: kindHTTP,
+		},
+		{
+			name:   "variable of function",
+			target: "HelloWorld",
+			src: `package foo
+
+import "net/http"
+
+var HelloWorld = helloWorld
+
+func helloWorld(w http.ResponseWriter, r *http.Request) {}`,
+			want: kindHTTP,
+		},
+		{
+			name:   "variable of call result",
+			target: "HelloWorld",
+			src: `package foo
+
+var HelloWorld = newHandler()`,
+			want: kindUnknown,
+		},
+		{
+			name:   "declarative",
+			target: "Hello",
+			src: `package foo
+
+import "github.com/GoogleCloudPlatform/functions-framework-go/functions"
+
+func init() { functions.HTTP("Hello", hello) }`,
+			want: kindDeclarative,
+		},
+		{
+			name:   "missing",
+			target: "HelloWorld",
+			src: `package foo
+
+func Goodbye() {}`,
+			wantErr: "function HelloWorld not found in package foo",
+		},
+		{
+			name:   "wrong case",
+			target: "Helloworld",
+			src: `package foo
+
+import "net/http"
+
+func HelloWorld(w http.ResponseWriter, r *http.Request) {}`,
+			wantErr: "did you mean HelloWorld?",
+		},
+		{
+			name:   "method",
+			target: "HelloWorld",
+			src: `package foo
+
+import "net/http"
+
+type s struct{}
+
+func (s) HelloWorld(w http.ResponseWriter, r *http.Request) {}`,
+			wantErr: "function HelloWorld not found",
+		},
+		{
+			name:   "not exported",
+			target: "helloWorld",
+			src: `package foo
+
+import "net/http"
+
+func helloWorld(w http.ResponseWriter, r *http.Request) {}`,
+			wantErr: "rename it to HelloWorld",
+		},
+		{
+			name:   "wrong http signature",
+			target: "HelloWorld",
+			src: `package foo
+
+import "net/http"
+
+func HelloWorld(r *http.Request) {}`,
+			wantErr: "unsupported signature func(r *http.Request)",
+		},
+		{
+			name:   "event without error",
+			target: "HelloPubSub",
+			src: `package foo
+
+import "context"
+
+func HelloPubSub(ctx context.Context, m []byte) {}`,
+			wantErr: "unsupported signature",
+		},
+		{
+			name:   "named function type",
+			target: "HelloWorld",
+			src: `package foo
+
+import "net/http"
+
+var HelloWorld http.HandlerFunc = func(w http.ResponseWriter, r *http.Request) {}`,
+			wantErr: "found a variable of type http.HandlerFunc",
+		},
+		{
+			name:   "constant",
+			target: "HelloWorld",
+			src: `package foo
+
+const HelloWorld = "hello"`,
+			wantErr: "found a constant",
+		},
+		{
+			name:   "qualified",
+			target: "sub/pkg.Hello",
+			src:    "package foo",
+			files: map[string]string{
+				"sub/pkg/hello.go": `package pkg
+
+import "net/http"
+
+func Hello(w http.ResponseWriter, r *http.Request) {}`,
+			},
+			want:     kindHTTP,
+			wantDir:  "sub/pkg",
+			wantName: "Hello",
+		},
+		{
+			name:   "qualified declarative",
+			target: "sub.Hello",
+			src:    "package foo",
+			files: map[string]string{
+				"sub/hello.go": `package sub
+
+import "github.com/GoogleCloudPlatform/functions-framework-go/functions"
+
+func init() { functions.HTTP("Hello", hello) }`,
+			},
+			want:     kindDeclarative,
+			wantDir:  "sub",
+			wantName: "Hello",
+		},
+		{
+			name:   "registered name with dot",
+			target: "hello.world",
+			src: `package foo
+
+import "github.com/GoogleCloudPlatform/functions-framework-go/functions"
+
+func init() { functions.HTTP("hello.world", hello) }`,
+			want:     kindDeclarative,
+			wantDir:  ".",
+			wantName: "hello.world",
+		},
+		{
+			name:    "qualified missing package",
+			target:  "sub.Hello",
+			src:     "package foo",
+			wantErr: "package directory sub not found",
+		},
+		{
+			name:    "qualified outside source",
+			target:  "../sub.Hello",
+			src:     "package foo",
+			wantErr: "must be within the function source",
+		},
+		{
+			name:   "qualified internal package",
+			target: "internal/sub.Hello",
+			src:    "package foo",
+			files: map[string]string{
+				"internal/sub/hello.go": "package sub",
+			},
+			wantErr: "is an internal package",
+		},
+		{
+			name:   "qualified missing function",
+			target: "sub.Hello",
+			src:    "package foo",
+			files: map[string]string{
+				"sub/hello.go": "package sub",
+			},
+			wantErr: "function Hello not found in package sub",
+		},
+		{
+			name:     "bad file",
+			target:   "HelloWorld",
+			src:      "not a go file",
+			internal: true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "golang_bp_test")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			if err := ioutil.WriteFile(filepath.Join(dir, "foo.go"), []byte(tc.src), 0644); err != nil {
+				t.Fatalf("writing file: %v", err)
+			}
+			for f, c := range tc.files {
+				if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, f)), 0755); err != nil {
+					t.Fatalf("creating dir for %s: %v", f, err)
+				}
+				if err := ioutil.WriteFile(filepath.Join(dir, f), []byte(c), 0644); err != nil {
+					t.Fatalf("writing file %s: %v", f, err)
+				}
+			}
+
+			got, err := validateTarget(dir, tc.target)
+
+			if tc.internal {
+				if _, ok := err.(*targetError); err == nil || ok {
+					t.Errorf("validateTarget() got error: %v, want an internal error", err)
+				}
+				return
+			}
+			if tc.wantErr != "" {
+				if _, ok := err.(*targetError); !ok || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("validateTarget() got error: %v, want a target error containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateTarget() got error: %v", err)
+			}
+			want := resolvedTarget{kind: tc.want, pkgDir: tc.wantDir, name: tc.wantName}
+			if want.pkgDir == "" {
+				want.pkgDir = "."
+			}
+			if want.name == "" {
+				want.name = tc.target
+			}
+			if got != want {
+				t.Errorf("validateTarget() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
@@ -19,11 +19,15 @@ package main
 import (
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/gcloudignore"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/golang"
 	"github.com/buildpacks/libcnb"
@@ -37,18 +41,103 @@ const (
 	functionsFrameworkVersion = "v1.1.0"
 	appName                   = "serverless_function_app"
 	fnSourceDir               = "serverless_function_source_code"
+	h2cModule                 = "golang.org/x/net"
+	h2cPackage                = h2cModule + "/http2/h2c"
+	h2cVersion                = "v0.0.0-20200822124328-c89045814202"
+	goProxy                   = "https://proxy.golang.org"
+	cloudEventsModule         = "github.com/cloudevents/sdk-go/v2"
+	// cloudEventVersion is the first framework version that supports CloudEvent functions.
+	cloudEventVersion = "v1.1.0"
+	// declarativeVersion is the first framework version that supports declarative function registration.
+	declarativeVersion = "v1.6.0"
+	// registryVersion is the first framework version that registers functions in its own registry,
+	// which only funcframework.Start serves, rather than on http.DefaultServeMux.
+	registryVersion = "v1.6.0"
+	// converterInvalidTargetCode is the exit code with which the converter reports an invalid function
+	// target; it must match invalidTargetCode in the converter.
+	converterInvalidTargetCode = 3
+	// middlewareName is the function with which a function package wraps the handler of the generated
+	// main; it must match middlewareName in the converter.
+	middlewareName = "FunctionMiddleware"
+	// googleDirsPattern matches the Google directories of the source, which are not relocated.
+	googleDirsPattern = ".google*"
+	// exclusionsMetadataKey is the layer metadata key of the exclusion set of the function source, in
+	// .dockerignore format.
+	exclusionsMetadataKey = "source_exclusions"
+	// mainLayerName is the layer holding the main module of functions built in place.
+	mainLayerName = "main"
+	// converterLayerName is the cache layer holding the converter binary and its GOCACHE.
+	converterLayerName = "converter"
 )
 
 var (
 	googleDirs = []string{fnSourceDir, ".googlebuild", ".googleconfig"}
-	tmplV0     = template.Must(template.New("mainV0").Parse(mainTextTemplateV0))
-	tmplV1_1   = template.Must(template.New("mainV1_1").Parse(mainTextTemplateV1_1))
+	tmplV0     = template.Must(template.Must(template.New("mainV0").Parse(mainTextTemplateV0)).Parse(serverTemplates))
+	tmplV1_1   = template.Must(template.Must(template.New("mainV1_1").Parse(mainTextTemplateV1_1)).Parse(serverTemplates))
+	// tmplRegistry registers the functions and starts the framework, which serves them from its registry.
+	tmplRegistry = template.Must(template.New("mainRegistry").Parse(mainTextTemplateRegistry))
+	// tmplDeclarative starts the framework, which serves the functions registered in the user's init functions.
+	tmplDeclarative = template.Must(template.New("mainDeclarative").Parse(mainTextTemplateDeclarative))
+	tmplMainTest    = template.Must(template.New("mainTest").Parse(mainTestTextTemplate))
 )
 
 type fnInfo struct {
 	Source  string
 	Target  string
 	Package string
+	// Subpackage is the slash-separated directory of the package that defines the target, relative to
+	// Source, for targets qualified with a package directory such as subpkg.Handler; otherwise it is empty.
+	Subpackage string
+	// Routes are the paths at which the functions are served, if the image serves several functions.
+	Routes []route
+	Server serverOptions
+	// Declarative is true if the package registers the target with the functions package.
+	Declarative bool
+	// CloudEvent is true if the targets are CloudEvent functions, as declared with
+	// GOOGLE_FUNCTION_SIGNATURE_TYPE=cloudevent.
+	CloudEvent bool
+	// FrameworkVersion is the framework version required when the function does not pin one.
+	FrameworkVersion string
+	// Registry is true if the framework version registers functions in its registry, so that the
+	// generated main must start the framework rather than serve http.DefaultServeMux.
+	Registry bool
+	// Middleware is true if the function package declares FunctionMiddleware, which the generated main
+	// wraps around the handler that serves the functions.
+	Middleware bool
+	// Toolchain is the Go release required by the function's go.mod, if it is newer than the installed Go.
+	Toolchain string
+	// SelfTest is true if a test of the generated main is generated alongside it, as requested with
+	// env.FunctionSelfTest.
+	SelfTest bool
+}
+
+// route is a path at which the generated main serves a function.
+type route struct {
+	Path   string
+	Target string
+}
+
+// Handlers returns the routes that the generated main registers: Routes, or the target at the root.
+func (fn fnInfo) Handlers() []route {
+	if len(fn.Routes) > 0 {
+		return fn.Routes
+	}
+	return []route{{Path: "/", Target: fn.Target}}
+}
+
+// serverOptions configures the HTTP server started by the generated main.
+type serverOptions struct {
+	ReadHeaderTimeout time.Duration
+	MaxHeaderBytes    int
+	H2C               bool
+	// PathPrefix is the sub-path at which the functions are served, or empty to serve them at the root.
+	PathPrefix string
+}
+
+// Custom returns true if any server option was set at build time. Such options are compiled into the
+// generated main, so they are not supported by the declarative template, which calls funcframework.Start.
+func (o serverOptions) Custom() bool {
+	return o.ReadHeaderTimeout > 0 || o.MaxHeaderBytes > 0 || o.H2C || o.PathPrefix != ""
 }
 
 func main() {
@@ -67,29 +156,137 @@ func buildFn(ctx *gcp.Context) error {
 	l := ctx.Layer(layerName)
 	ctx.Setenv("GOPATH", l.Path)
 	ctx.SetFunctionsEnvVars(l)
+	// The credentials are removed before the layers are exported.
+	defer golang.UsePrivateModuleCredentials(ctx)()
 
 	fnTarget := os.Getenv(env.FunctionTarget)
+	cloudEvent := os.Getenv(env.FunctionSignatureType) == kindCloudEvent
 
-	// Move the function source code into a subdirectory in order to construct the app in the main application root.
-	ctx.RemoveAll(fnSourceDir)
-	ctx.MkdirAll(fnSourceDir, 0755)
-	// mindepth=1 excludes '.', '+' collects all file names before running the command.
-	// Exclude serverless_function_source_code and .google* dir e.g. .googlebuild, .googleconfig
-	command := fmt.Sprintf("find . -mindepth 1 -not -name %[1]s -prune -not -name %[2]q -prune -exec mv -t %[1]s {} +", fnSourceDir, ".google*")
-	ctx.Exec([]string{"bash", "-c", command}, gcp.WithUserTimingAttribution)
+	inPlace, err := env.Bool(env.FunctionBuildInPlace, false)
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	srcRoot := ctx.ApplicationRoot()
+	if inPlace {
+		ctx.Logf("Building the function in place, without moving its source to %s", fnSourceDir)
+		if err := checkInPlace(ctx, srcRoot); err != nil {
+			return err
+		}
+	} else {
+		// Move the function source code into a subdirectory in order to construct the app in the main application root.
+		srcRoot = filepath.Join(ctx.ApplicationRoot(), fnSourceDir)
+		ctx.RemoveAll(fnSourceDir)
+		ctx.MkdirAll(fnSourceDir, 0755)
+		// Exclude .google* dirs, e.g. .googlebuild, .googleconfig.
+		ctx.MoveContents(ctx.ApplicationRoot(), srcRoot, googleDirsPattern)
+	}
+	if err := removeExcluded(ctx, l, srcRoot, !inPlace); err != nil {
+		return err
+	}
 
-	fnSource := filepath.Join(ctx.ApplicationRoot(), fnSourceDir)
+	server, err := serverOptionsFromEnv()
+	if err != nil {
+		return err
+	}
+	frameworkVersion, err := frameworkVersionFromEnv()
+	if err != nil {
+		return err
+	}
+
+	fnSource, err := functionSource(srcRoot)
+	if err != nil {
+		return err
+	}
+	names, err := functionTargets(fnTarget)
+	if err != nil {
+		return err
+	}
+	conv := buildConverter(ctx)
+	var targets []resolvedTarget
+	for _, name := range names {
+		t, err := conv.validateTarget(ctx, fnSource, name)
+		if err != nil {
+			return err
+		}
+		if cloudEvent {
+			if err := checkCloudEventTarget(ctx, t); err != nil {
+				return err
+			}
+		}
+		targets = append(targets, t)
+	}
+	routes, err := targetRoutes(targets)
+	if err != nil {
+		return err
+	}
+	target := targets[0]
 	fn := fnInfo{
-		Source:  fnSource,
-		Target:  fnTarget,
-		Package: extractPackageNameInDir(ctx, fnSource),
+		Source:           fnSource,
+		Target:           target.name,
+		Package:          conv.extractPackageNameInDir(ctx, fnSource),
+		Subpackage:       target.subpackage,
+		Routes:           routes,
+		Server:           server,
+		Declarative:      target.declarative,
+		CloudEvent:       cloudEvent,
+		FrameworkVersion: frameworkVersion,
+	}
+	if fn.Subpackage != "" {
+		ctx.Logf("Found function %s in package directory %s", fn.Target, fn.Subpackage)
+	}
+	if len(routes) > 1 {
+		for _, r := range routes {
+			ctx.Logf("Serving function %s at %s", r.Target, r.Path)
+		}
+	}
+	if fn.Middleware, err = conv.findMiddleware(ctx, filepath.Join(fn.Source, filepath.FromSlash(fn.Subpackage))); err != nil {
+		return err
+	}
+	if fn.Declarative {
+		ctx.Logf("Found declarative registration of function %s", fnTarget)
+		if fn.Server.Custom() {
+			return gcp.UserErrorf("%s, %s, %s and %s are not supported for functions registered with the functions package", env.FunctionReadHeaderTimeout, env.FunctionMaxHeaderBytes, env.FunctionH2C, env.FunctionPath)
+		}
+		if fn.Middleware {
+			return gcp.UserErrorf("%s is not supported for functions registered with the functions package; wrap the handler passed to the functions package instead", middlewareName)
+		}
+	}
+	if fn.Middleware {
+		ctx.Logf("Wrapping the function handler with %s", middlewareName)
+	}
+	if fn.SelfTest, err = env.Bool(env.FunctionSelfTest, false); err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+
+	test, err := env.Bool(env.GoTest, false)
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	if test {
+		if err := runTests(ctx, fn.Source); err != nil {
+			return err
+		}
+	}
+	if err := analyzeFunction(ctx, fn.Source); err != nil {
+		return err
 	}
 
 	goMod := filepath.Join(fn.Source, "go.mod")
+	if ctx.FileExists(goMod) {
+		if fn.Toolchain, err = golang.RequiredToolchain(ctx, fn.Source); err != nil {
+			return err
+		}
+		if fn.Toolchain != "" {
+			if ctx.FileExists(fn.Source, "vendor") {
+				return gcp.UserErrorf("go.mod requires Go %s, which is newer than the installed Go; vendored functions are built without downloading toolchains, so set %s=%s", fn.Toolchain, env.RuntimeVersion, fn.Toolchain)
+			}
+			useToolchain(ctx, l, fn.Toolchain)
+		}
+	}
 	if !ctx.FileExists(goMod) {
 		// We require a go.mod file in all versions 1.14+.
 		if !golang.SupportsNoGoMod(ctx) {
-			return gcp.UserErrorf("function build requires go.mod file")
+			return gcp.UserErrorMsg(gcp.MsgGoModRequired)
 		}
 		if err := createMainVendored(ctx, l, fn); err != nil {
 			return err
@@ -97,9 +294,20 @@ func buildFn(ctx *gcp.Context) error {
 	} else if info, err := os.Stat(goMod); err == nil && info.Mode().Perm()&0200 == 0 {
 		// Preempt an obscure failure mode: if go.mod is not writable then `go list -m` can fail saying:
 		//     go: updates to go.sum needed, disabled by -mod=readonly
-		return gcp.UserErrorf("go.mod exists but is not writable")
+		return gcp.UserErrorMsg(gcp.MsgGoModNotWritable)
+	} else if ctx.FileExists(fn.Source, "vendor") {
+		if err := createMainGoModVendored(ctx, l, fn); err != nil {
+			return err
+		}
 	} else {
-		if err := createMainGoMod(ctx, fn); err != nil {
+		appDir := ctx.ApplicationRoot()
+		if inPlace {
+			// The main module is generated in a layer, which the go build buildpack builds in.
+			ml := ctx.Layer(mainLayerName, gcp.BuildLayer)
+			ml.BuildEnvironment.Override(golang.BuildDirEnv, ml.Path)
+			appDir = ml.Path
+		}
+		if err := createMainGoMod(ctx, l, fn, srcRoot, appDir); err != nil {
 			return err
 		}
 	}
@@ -108,36 +316,161 @@ func buildFn(ctx *gcp.Context) error {
 	return nil
 }
 
-func createMainGoMod(ctx *gcp.Context, fn fnInfo) error {
-	ctx.Exec([]string{"go", "mod", "init", appName})
+// useToolchain makes the go command of this and later buildpacks download and run the Go release.
+func useToolchain(ctx *gcp.Context, l *libcnb.Layer, release string) {
+	ctx.Logf("go.mod requires Go %s, which is newer than the installed Go; the go command downloads it", release)
+	ctx.Setenv("GOTOOLCHAIN", "go"+release)
+	l.Build = true
+	l.BuildEnvironment.Override("GOTOOLCHAIN", "go"+release)
+}
+
+// createMainGoMod creates the main module in appDir, which requires the function module from its
+// source and replaces it with the source directory. Workspaces are searched for up to srcRoot.
+func createMainGoMod(ctx *gcp.Context, l *libcnb.Layer, fn fnInfo, srcRoot, appDir string) error {
+	work := golang.FindWorkspace(fn.Source, srcRoot)
+	if work != "" && !golang.SupportsWorkspaces(ctx) {
+		return gcp.UserErrorf("the function is in the Go workspace %s, which requires Go 1.18 or later", work)
+	}
+
+	inApp := gcp.WithWorkDir(appDir)
+	ctx.Exec([]string{"go", "mod", "init", appName}, inApp)
+	// The app module declares at least the language version of the function module, and the toolchain
+	// it requires, so that the build does not fail late with "go.mod requires go >= X".
+	fnGo, _ := golang.GoModDirectives(ctx, fn.Source)
+	if appGo, _ := golang.GoModDirectives(ctx, appDir); fnGo != "" && golang.VersionLess(appGo, fnGo) {
+		ctx.Exec([]string{"go", "mod", "edit", "-go=" + fnGo}, inApp)
+	}
+	if fn.Toolchain != "" {
+		ctx.Exec([]string{"go", "mod", "edit", "-toolchain=go" + fn.Toolchain}, inApp)
+	}
 
-	fnMod := ctx.Exec([]string{"go", "list", "-m"}, gcp.WithWorkDir(fn.Source)).Stdout
+	// In a workspace, `go list -m` lists every workspace module, so only consider the function's go.mod.
+	fnMod := ctx.Exec([]string{"go", "list", "-m"}, gcp.WithWorkDir(fn.Source), gcp.WithEnv("GOWORK=off")).Stdout
 	// golang.org/ref/mod requires that package names in a replace contains at least one dot.
 	if parts := strings.Split(fnMod, "/"); len(parts) > 0 && !strings.Contains(parts[0], ".") {
 		return gcp.UserErrorf("the module path in the function's go.mod must contain a dot in the first path element before a slash, e.g. example.com/module, found: %s", fnMod)
 	}
 	// Add the module name to the the package name, such that go build will be able to find it,
 	// if a directory with the package name is not at the app root. Otherwise, assume the package is at the module root.
-	if ctx.FileExists(ctx.ApplicationRoot(), fn.Package) {
+	if ctx.FileExists(appDir, fn.Package) {
 		fn.Package = fmt.Sprintf("%s/%s", fnMod, fn.Package)
 	} else {
 		fn.Package = fnMod
 	}
 
-	ctx.Exec([]string{"go", "mod", "edit", "-require", fmt.Sprintf("%s@v0.0.0", fnMod)})
-	ctx.Exec([]string{"go", "mod", "edit", "-replace", fmt.Sprintf("%s@v0.0.0=%s", fnMod, fn.Source)})
+	ctx.Exec([]string{"go", "mod", "edit", "-require", fmt.Sprintf("%s@v0.0.0", fnMod)}, inApp)
+	ctx.Exec([]string{"go", "mod", "edit", "-replace", fmt.Sprintf("%s@v0.0.0=%s", fnMod, fn.Source)}, inApp)
+	if work != "" {
+		if err := createAppWorkspace(ctx, fn.Source, work, appDir); err != nil {
+			return err
+		}
+	}
+
+	// GOPATH is the functions-framework layer, which holds the module cache.
+	purge := gcp.WithCorruptCacheRetry(func() error { return golang.PurgeModCache(ctx, os.Getenv("GOPATH")) })
 
-	// If the framework is not present in the function's go.mod, we require the current version.
+	// If the framework is not present in the function's go.mod, we require the requested version.
 	version, err := frameworkSpecifiedVersion(ctx, fn.Source)
 	if err != nil {
 		return fmt.Errorf("checking for functions framework dependency in go.mod: %w", err)
 	}
 	if version == "" {
-		ctx.Exec([]string{"go", "get", fmt.Sprintf("%s@%s", functionsFrameworkModule, functionsFrameworkVersion)}, gcp.WithUserAttribution)
-		version = functionsFrameworkVersion
+		ctx.Exec([]string{"go", "get", fmt.Sprintf("%s@%s", functionsFrameworkModule, fn.FrameworkVersion)}, inApp, gcp.WithTransientRetry, purge, gcp.WithUserAttribution)
+		version = fn.FrameworkVersion
+	} else if _, ok := os.LookupEnv(env.FunctionsFrameworkVersion); ok && version != fn.FrameworkVersion {
+		ctx.Warnf("Ignoring %s=%s because go.mod requires %s %s", env.FunctionsFrameworkVersion, fn.FrameworkVersion, functionsFrameworkModule, version)
+	}
+
+	// Likewise, h2c support requires golang.org/x/net; prefer the function's version if it has one.
+	if fn.Server.H2C {
+		netVersion, err := moduleSpecifiedVersion(ctx, fn.Source, h2cModule)
+		if err != nil {
+			return fmt.Errorf("checking for %s dependency in go.mod: %w", h2cModule, err)
+		}
+		if netVersion == "" {
+			ctx.Exec([]string{"go", "get", fmt.Sprintf("%s@%s", h2cModule, h2cVersion)}, inApp, gcp.WithTransientRetry, purge, gcp.WithUserAttribution)
+		}
+	}
+
+	// The generated main of CloudEvent functions imports the cloudevents SDK.
+	if fn.CloudEvent && !fn.Declarative {
+		if err := requireCloudEvents(ctx, fn.Source, appDir); err != nil {
+			return err
+		}
+	}
+
+	main := filepath.Join(appDir, "main.go")
+	return createMain(ctx, l, fn, main, version)
+}
+
+// createMainGoModVendored creates the main package for functions with a go.mod and a vendor
+// directory inside the function module, so that it builds with -mod=vendor from the vendored
+// dependencies, including the functions framework, without touching the network. This is the
+// go.mod route for Go 1.14+, which handles vendored go.mod files natively.
+func createMainGoModVendored(ctx *gcp.Context, l *libcnb.Layer, fn fnInfo) error {
+	if err := golang.CheckVendorConsistency(ctx, fn.Source); err != nil {
+		return err
+	}
+	version, ok := golang.VendoredModuleVersion(ctx, fn.Source, functionsFrameworkModule)
+	if !ok {
+		return gcp.UserErrorf("the vendor directory does not contain the functions framework; vendored functions are built without downloading modules, so run `go get %s@%s && go mod vendor` and deploy again", functionsFrameworkModule, fn.FrameworkVersion)
+	}
+	if version == "" {
+		// The framework is replaced at every version, so its version is unknown.
+		version = "v0.0.0"
+	}
+	ctx.Logf("Found function with vendored dependencies including functions-framework %s", version)
+	if fn.Server.H2C && !ctx.FileExists(fn.Source, "vendor", h2cPackage) {
+		return gcp.UserErrorf("%s requires %s to be vendored alongside the functions framework", env.FunctionH2C, h2cPackage)
+	}
+
+	if mod, ok := golang.GoFlagValue(os.Getenv("GOFLAGS"), "mod"); ok && mod != "vendor" {
+		ctx.Warnf("Overriding -mod=%s in GOFLAGS with -mod=vendor, as functions with a vendor directory are built from it", mod)
 	}
+	vendorFlags := golang.AppendGoFlags("-mod=vendor")
+	vendorEnv := []string{"GOFLAGS=" + vendorFlags, "GOPROXY=off"}
+	fn.Package = ctx.Exec([]string{"go", "list", "-m"}, gcp.WithWorkDir(fn.Source), gcp.WithEnv(append(vendorEnv, "GOWORK=off")...)).Stdout
 
-	return createMainGoFile(ctx, fn, filepath.Join(ctx.ApplicationRoot(), "main.go"), version)
+	// The main package is generated inside the function module, where the vendor directory applies.
+	appPath := filepath.Join(fn.Source, appName)
+	ctx.MkdirAll(appPath, 0755)
+	l.Build = true
+	l.BuildEnvironment.Override(golang.BuildDirEnv, fn.Source)
+	l.BuildEnvironment.Override(env.Buildable, "./"+appName)
+	l.BuildEnvironment.Override("GOFLAGS", vendorFlags)
+	l.BuildEnvironment.Override("GOPROXY", "off")
+
+	main := filepath.Join(appPath, "main.go")
+	return createMain(ctx, l, fn, main, version)
+}
+
+// createAppWorkspace creates a go.work in appDir that uses the app module and every module of the
+// function's workspace, with the workspace's replacements, so that the function module builds
+// against the other modules of the workspace rather than their published versions.
+func createAppWorkspace(ctx *gcp.Context, fnSource, work, appDir string) error {
+	ws, err := golang.ReadWorkspace(ctx, work)
+	if err != nil {
+		return err
+	}
+	if !ws.Uses(fnSource) {
+		return gcp.UserErrorf("the function module in %s is not used by the Go workspace %s; add it with `go work use`", fnSource, work)
+	}
+	ctx.Logf("Building the function in the Go workspace %s", work)
+
+	inApp := gcp.WithWorkDir(appDir)
+	ctx.Exec([]string{"go", "work", "init", "."}, inApp)
+	use := []string{"go", "work", "use"}
+	for _, u := range ws.Use {
+		use = append(use, u.DiskPath)
+	}
+	ctx.Exec(use, inApp)
+	for _, r := range ws.Replace {
+		ctx.Exec([]string{"go", "work", "edit", "-replace", fmt.Sprintf("%s=%s", r.Old, r.New)}, inApp)
+	}
+	if sum := filepath.Join(filepath.Dir(work), golang.WorkSumFile); ctx.FileExists(sum) {
+		ctx.WriteFile(filepath.Join(appDir, golang.WorkSumFile), ctx.ReadFile(sum), 0644)
+	}
+	return nil
 }
 
 // createMainVendored creates the main.go file for vendored functions.
@@ -171,30 +504,117 @@ func createMainVendored(ctx *gcp.Context, l *libcnb.Layer, fn fnInfo) error {
 	if ctx.FileExists(fnFrameworkVendoredPath) {
 		ctx.Logf("Found function with vendored dependencies including functions-framework")
 		ctx.Exec([]string{"cp", "-r", fnVendoredPath, appPath}, gcp.WithUserTimingAttribution)
+		if fn.Server.H2C && !ctx.FileExists(fnVendoredPath, h2cPackage) {
+			return gcp.UserErrorf("%s requires %s to be vendored alongside the functions framework", env.FunctionH2C, h2cPackage)
+		}
 	} else {
 		// If the framework isn't in the user-provided vendor directory, we need to fetch it ourselves.
 		ctx.Logf("Found function with vendored dependencies excluding functions-framework")
 		ctx.Warnf("Your vendored dependencies do not contain the functions framework (%s). If there are conflicts between the vendored packages and the dependencies of the framework, you may see encounter unexpected issues.", functionsFrameworkPackage)
+		fetchFramework(ctx, gopathSrc, fn)
+		// Since the user didn't pin it, we want the requested or current version of the framework.
+		requestedFrameworkVersion = fn.FrameworkVersion
+	}
+
+	main := filepath.Join(appPath, "main.go")
+	return createMain(ctx, l, fn, main, requestedFrameworkVersion)
+}
 
-		// Create a temporary GOCACHE directory so GOPATH go get works.
-		cache := ctx.TempDir("", appName)
-		defer ctx.RemoveAll(cache)
+// fetchFramework downloads the functions framework at the requested version, and h2c if the server
+// requires it, with their dependencies through the module proxy, and unpacks their packages into
+// the GOPATH source directory gopathSrc. Unlike a GOPATH-mode `go get`, this does not need git, and
+// the go command verifies the downloaded modules.
+func fetchFramework(ctx *gcp.Context, gopathSrc string, fn fnInfo) {
+	work := ctx.TempDir("", appName)
+	defer ctx.RemoveAll(work)
+	cache := ctx.TempDir("", appName)
+	defer ctx.RemoveAll(cache)
 
-		// The gopath version of `go get` doesn't allow tags, but does checkout the whole repo so we
-		// can checkout the appropriate tag ourselves.
-		ctx.Exec([]string{"go", "get", functionsFrameworkPackage}, gcp.WithEnv("GOPATH="+gopath, "GOCACHE="+cache), gcp.WithUserAttribution)
-		ctx.Exec([]string{"git", "checkout", functionsFrameworkVersion}, gcp.WithWorkDir(filepath.Join(gopathSrc, functionsFrameworkModule)), gcp.WithUserAttribution)
-		// Since the user didn't pin it, we want the current version of the framework.
-		requestedFrameworkVersion = functionsFrameworkVersion
+	imports := []string{functionsFrameworkPackage}
+	requires := []string{fmt.Sprintf("%s@%s", functionsFrameworkModule, fn.FrameworkVersion)}
+	if fn.Server.H2C {
+		imports = append(imports, h2cPackage)
+		requires = append(requires, fmt.Sprintf("%s@%s", h2cModule, h2cVersion))
+	}
+	// `go mod vendor` copies the packages imported by the module, with their dependencies.
+	var src strings.Builder
+	src.WriteString("package main\n\nimport (\n")
+	for _, imp := range imports {
+		fmt.Fprintf(&src, "\t_ %q\n", imp)
 	}
+	src.WriteString(")\n\nfunc main() {}\n")
+	ctx.WriteFile(filepath.Join(work, "main.go"), []byte(src.String()), 0644)
 
-	return createMainGoFile(ctx, fn, filepath.Join(appPath, "main.go"), requestedFrameworkVersion)
+	// GOPATH is the functions-framework layer, which holds the module cache.
+	modEnv := []string{"GO111MODULE=on", "GOCACHE=" + cache}
+	if os.Getenv("GOPROXY") == "" {
+		// Go 1.11 downloads modules from their repositories unless told otherwise.
+		modEnv = append(modEnv, "GOPROXY="+goProxy)
+	}
+	purge := gcp.WithCorruptCacheRetry(func() error { return golang.PurgeModCache(ctx, os.Getenv("GOPATH")) })
+	ctx.Exec([]string{"go", "mod", "init", appName}, gcp.WithWorkDir(work), gcp.WithEnv(modEnv...))
+	ctx.Exec(append([]string{"go", "get"}, requires...), gcp.WithWorkDir(work), gcp.WithEnv(modEnv...), gcp.WithTransientRetry, purge, gcp.WithUserAttribution)
+	ctx.Exec([]string{"go", "mod", "vendor"}, gcp.WithWorkDir(work), gcp.WithEnv(modEnv...), gcp.WithTransientRetry, purge, gcp.WithUserAttribution)
+
+	// The vendor directory is laid out by import path, like the GOPATH source directory.
+	vendor := filepath.Join(work, "vendor")
+	ctx.RemoveAll(filepath.Join(vendor, "modules.txt"))
+	ctx.Exec([]string{"cp", "-r", vendor + "/.", gopathSrc}, gcp.WithUserTimingAttribution)
+}
+
+// createMain creates the main.go file at main and, if requested, its self-test, and publishes them
+// and the function package for the go build buildpack.
+func createMain(ctx *gcp.Context, l *libcnb.Layer, fn fnInfo, main, version string) error {
+	mainTest := filepath.Join(filepath.Dir(main), "main_test.go")
+	// The generated files replace any the function source has at the same paths.
+	defer ctx.PreserveFiles(main, mainTest)()
+	ctx.PublishHandoff(l, gcp.HandoffGoMain, main)
+	// The function package, rather than the generated main, declares the variables it wants stamped.
+	l.BuildEnvironment.Override(golang.StampPackageEnv, path.Join(fn.Package, fn.Subpackage))
+	if err := createMainGoFile(ctx, fn, main, version); err != nil {
+		return err
+	}
+	if !fn.SelfTest {
+		return nil
+	}
+	if fn.Declarative {
+		ctx.Warnf("Not generating a self-test, as %s is not supported for functions registered with the functions package", env.FunctionSelfTest)
+		return nil
+	}
+	if registry, err := servesFromRegistry(version); err != nil {
+		return err
+	} else if registry {
+		ctx.Warnf("Not generating a self-test, as %s is not supported with %s %s or later, found %s", env.FunctionSelfTest, functionsFrameworkModule, registryVersion, version)
+		return nil
+	}
+	if err := createMainTestFile(ctx, fn, mainTest); err != nil {
+		return err
+	}
+	ctx.PublishHandoff(l, gcp.HandoffGoMainTest, mainTest)
+	return nil
+}
+
+// createMainTestFile creates the self-test of the main.go generated for fn, which the go build
+// buildpack runs before building the function.
+func createMainTestFile(ctx *gcp.Context, fn fnInfo, mainTest string) error {
+	f := ctx.CreateFile(mainTest)
+	defer f.Close()
+
+	// fn.Package is the import path of the package in the function source.
+	fn.Package = path.Join(fn.Package, fn.Subpackage)
+	if err := tmplMainTest.Execute(f, fn); err != nil {
+		return fmt.Errorf("executing template: %v", err)
+	}
+	return nil
 }
 
 func createMainGoFile(ctx *gcp.Context, fn fnInfo, main, version string) error {
 	f := ctx.CreateFile(main)
 	defer f.Close()
 
+	// fn.Package is the import path of the package in the function source.
+	fn.Package = path.Join(fn.Package, fn.Subpackage)
+
 	requestedVersion, err := semver.ParseTolerant(version)
 	if err != nil {
 		return fmt.Errorf("unable to parse framework version string %s: %w", version, err)
@@ -203,13 +623,43 @@ func createMainGoFile(ctx *gcp.Context, fn fnInfo, main, version string) error {
 	// By default, use the v0 template.
 	// For framework versions greater than or equal to v1.1.0, use the v1_1 template.
 	tmpl := tmplV0
-	v1_1, err := semver.ParseTolerant("v1.1.0")
+	v1_1, err := semver.ParseTolerant(cloudEventVersion)
 	if err != nil {
-		return fmt.Errorf("unable to parse framework version string v1.1.0: %v", err)
+		return fmt.Errorf("unable to parse framework version string %s: %v", cloudEventVersion, err)
 	}
 	if requestedVersion.GE(v1_1) {
 		tmpl = tmplV1_1
 	}
+	if fn.CloudEvent && !fn.Declarative {
+		// Vendored builds without go.mod request v0.0.0 since their version is unknown.
+		if version != "v0.0.0" && requestedVersion.LT(v1_1) {
+			return gcp.UserErrorf("CloudEvent functions require %s %s or later, found %s", functionsFrameworkModule, cloudEventVersion, version)
+		}
+		tmpl = tmplV1_1
+	}
+	if fn.Registry, err = servesFromRegistry(version); err != nil {
+		return err
+	}
+	if fn.Registry && !fn.Declarative {
+		if fn.Server.Custom() {
+			return gcp.UserErrorf("%s, %s, %s and %s are not supported with %s %s or later, found %s, which serves the functions itself; require an earlier version", env.FunctionReadHeaderTimeout, env.FunctionMaxHeaderBytes, env.FunctionH2C, env.FunctionPath, functionsFrameworkModule, registryVersion, version)
+		}
+		if fn.Middleware {
+			return gcp.UserErrorf("%s is not supported with %s %s or later, found %s, which serves the functions itself; require an earlier version", middlewareName, functionsFrameworkModule, registryVersion, version)
+		}
+		tmpl = tmplRegistry
+	}
+	if fn.Declarative {
+		minVersion, err := semver.ParseTolerant(declarativeVersion)
+		if err != nil {
+			return fmt.Errorf("unable to parse framework version string %s: %v", declarativeVersion, err)
+		}
+		// Vendored builds without go.mod request v0.0.0 since their version is unknown.
+		if version != "v0.0.0" && requestedVersion.LT(minVersion) {
+			return gcp.UserErrorf("function %s is registered with the functions package, which requires %s %s or later, found %s", fn.Target, functionsFrameworkModule, declarativeVersion, version)
+		}
+		tmpl = tmplDeclarative
+	}
 
 	if err := tmpl.Execute(f, fn); err != nil {
 		return fmt.Errorf("executing template: %v", err)
@@ -217,29 +667,420 @@ func createMainGoFile(ctx *gcp.Context, fn fnInfo, main, version string) error {
 	return nil
 }
 
+// servesFromRegistry returns true if the framework at version registers functions in its registry,
+// which only funcframework.Start serves. Vendored builds without go.mod request v0.0.0 since their
+// version is unknown, which is served as an earlier version.
+func servesFromRegistry(version string) (bool, error) {
+	v, err := semver.ParseTolerant(version)
+	if err != nil {
+		return false, fmt.Errorf("unable to parse framework version string %s: %w", version, err)
+	}
+	min, err := semver.ParseTolerant(registryVersion)
+	if err != nil {
+		return false, fmt.Errorf("unable to parse framework version string %s: %v", registryVersion, err)
+	}
+	return v.GE(min), nil
+}
+
 // If a framework is specified, return the version. If unspecified, return an empty string.
 func frameworkSpecifiedVersion(ctx *gcp.Context, fnSource string) (string, error) {
-	res, err := ctx.ExecWithErr([]string{"go", "list", "-m", "-f", "{{.Version}}", functionsFrameworkModule}, gcp.WithWorkDir(fnSource))
-	if err == nil {
-		v := strings.TrimSpace(res.Stdout)
+	v, err := moduleSpecifiedVersion(ctx, fnSource, functionsFrameworkModule)
+	if err != nil {
+		return "", err
+	}
+	if v == "" {
+		ctx.Logf("No framework version specified, using default")
+	} else {
 		ctx.Logf("Found framework version %s", v)
-		return v, nil
+	}
+	return v, nil
+}
+
+// moduleSpecifiedVersion returns the version of module required by the go.mod in fnSource, or an empty string if it is not required.
+func moduleSpecifiedVersion(ctx *gcp.Context, fnSource, module string) (string, error) {
+	res, err := ctx.ExecWithErr([]string{"go", "list", "-m", "-f", "{{.Version}}", module}, gcp.WithWorkDir(fnSource))
+	if err == nil {
+		return strings.TrimSpace(res.Stdout), nil
 	}
 	if res != nil && strings.Contains(res.Stderr, "not a known dependency") {
-		ctx.Logf("No framework version specified, using default")
 		return "", nil
 	}
 	return "", err
 }
 
-// extractPackageNameInDir builds the script that does the extraction, and then runs it with the
-// specified source directory.
-// The parser is dependent on the language version being used, and it's highly likely that the buildpack binary
-// will be built with a different version of the language than the function deployment. Building this script ensures
-// that the version of Go used to build the function app will be the same as the version used to parse it.
-func extractPackageNameInDir(ctx *gcp.Context, source string) string {
-	scriptDir := filepath.Join(ctx.BuildpackRoot(), "converter", "get_package")
-	cacheDir := ctx.TempDir("", appName)
-	defer ctx.RemoveAll(cacheDir)
-	return ctx.Exec([]string{"go", "run", "main", "-dir", source}, gcp.WithEnv("GOPATH="+scriptDir, "GOCACHE="+cacheDir), gcp.WithWorkDir(scriptDir), gcp.WithUserAttribution).Stdout
+// functionSource returns the directory of the function within root, the moved source code, which is
+// the subdirectory selected with env.FunctionSource, if any, so that functions in a subdirectory of a
+// repository can be built with their own go.mod.
+func functionSource(root string) (string, error) {
+	sub := os.Getenv(env.FunctionSource)
+	if sub == "" {
+		return root, nil
+	}
+	clean := filepath.Clean(sub)
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", gcp.UserErrorf("%s=%q must be a relative path within the source", env.FunctionSource, sub)
+	}
+	dir := filepath.Join(root, clean)
+	fi, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return "", gcp.UserErrorf("%s=%q does not exist in the source", env.FunctionSource, sub)
+	}
+	if err != nil {
+		return "", fmt.Errorf("checking %s: %v", dir, err)
+	}
+	if !fi.IsDir() {
+		return "", gcp.UserErrorf("%s=%q must be the directory containing the function package", env.FunctionSource, sub)
+	}
+	return dir, nil
+}
+
+// checkInPlace returns an error if the function in root cannot be built in place. When building in
+// place root is the application root, so it must be called before any file is removed from it.
+func checkInPlace(ctx *gcp.Context, root string) error {
+	fnSource, err := functionSource(root)
+	if err != nil {
+		return err
+	}
+	if !ctx.FileExists(fnSource, "go.mod") || ctx.FileExists(fnSource, "vendor") {
+		return gcp.UserErrorf("%s is only supported for functions with a go.mod file and without a vendor directory", env.FunctionBuildInPlace)
+	}
+	return nil
+}
+
+// sourceExclusions returns the exclusion set of the function source: the Google directories, which
+// are not relocated, the .gcloudignore file of the source, which gcloud does not upload, and
+// env.FunctionSourceExclude, in increasing precedence.
+func sourceExclusions(fnSource string, relocated bool) (*gcloudignore.Matcher, error) {
+	m := &gcloudignore.Matcher{}
+	if relocated {
+		if err := m.Add("default", "/"+googleDirsPattern); err != nil {
+			return nil, gcp.InternalErrorf("%v", err)
+		}
+	}
+	if _, err := m.AddFile(fnSource); err != nil {
+		return nil, gcp.UserErrorf("%v", err)
+	}
+	if v := os.Getenv(env.FunctionSourceExclude); v != "" {
+		if err := m.Add(env.FunctionSourceExclude, strings.Split(v, ",")...); err != nil {
+			return nil, gcp.UserErrorf("%v", err)
+		}
+	}
+	return m, nil
+}
+
+// removeExcluded removes the files excluded from the function source, so that they are not built into
+// the image, and records the exclusion set in the metadata of l.
+func removeExcluded(ctx *gcp.Context, l *libcnb.Layer, fnSource string, relocated bool) error {
+	m, err := sourceExclusions(fnSource, relocated)
+	if err != nil {
+		return err
+	}
+	ctx.SetMetadata(l, exclusionsMetadataKey, m.String())
+	paths, err := m.Excluded(fnSource)
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	show, err := env.Bool(env.PrintSourceExclusions, false)
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	if show {
+		printExclusions(ctx, m, fnSource, paths)
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	ctx.Logf("Removing %d files and directories excluded from the function source", len(paths))
+	for _, p := range paths {
+		ctx.RemoveAll(p)
+	}
+	return nil
+}
+
+// printExclusions logs the exclusion set, the paths it excludes, and the size of each top-level
+// entry of the function source that is kept.
+func printExclusions(ctx *gcp.Context, m *gcloudignore.Matcher, fnSource string, excluded []string) {
+	ctx.Logf("Function source exclusions:\n%s", m.String())
+	skip := map[string]bool{}
+	for _, p := range excluded {
+		skip[p] = true
+		ctx.Logf("Excluded: %s", strings.TrimPrefix(p, fnSource+string(filepath.Separator)))
+	}
+	for _, p := range ctx.Glob(filepath.Join(fnSource, "*")) {
+		if skip[p] {
+			continue
+		}
+		var size int64
+		filepath.Walk(p, func(path string, info os.FileInfo, err error) error {
+			if err != nil || skip[path] {
+				if err == nil && info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			size += info.Size()
+			return nil
+		})
+		ctx.Logf("Kept: %s (%d bytes)", filepath.Base(p), size)
+	}
+}
+
+// serverOptionsFromEnv reads the HTTP server hardening options for the generated main.
+func serverOptionsFromEnv() (serverOptions, error) {
+	var o serverOptions
+	if v := os.Getenv(env.FunctionReadHeaderTimeout); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return o, gcp.UserErrorf("%s must be a positive duration such as 10s, got %q", env.FunctionReadHeaderTimeout, v)
+		}
+		o.ReadHeaderTimeout = d
+	}
+	if v := os.Getenv(env.FunctionMaxHeaderBytes); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return o, gcp.UserErrorf("%s must be a positive number of bytes, got %q", env.FunctionMaxHeaderBytes, v)
+		}
+		o.MaxHeaderBytes = n
+	}
+	h2c, err := env.Bool(env.FunctionH2C, false)
+	if err != nil {
+		return o, gcp.UserErrorf("%v", err)
+	}
+	o.H2C = h2c
+	prefix, err := env.FunctionPathPrefix()
+	if err != nil {
+		return o, gcp.UserErrorf("%v", err)
+	}
+	o.PathPrefix = prefix
+	return o, nil
+}
+
+// runTests runs the tests of the function module in fnSource, before the app is generated, so that
+// the build fails if they fail.
+func runTests(ctx *gcp.Context, fnSource string) error {
+	if !ctx.FileExists(fnSource, "go.mod") {
+		return gcp.UserErrorf("%s requires the function to have a go.mod file", env.GoTest)
+	}
+	cache := ctx.TempDir("", "gocache")
+	defer ctx.RemoveAll(cache)
+	ctx.Logf("Running the tests of the function module")
+	ctx.Exec([]string{"go", "test", "./..."}, gcp.WithWorkDir(fnSource), gcp.WithEnv("GOCACHE="+cache), gcp.WithCombinedTail, gcp.WithUserAttribution)
+	return nil
+}
+
+// analyzeFunction runs the analyses requested with env.GoVet and env.GoStaticcheck on the function
+// module in fnSource. The go/build buildpack does not analyze functions, as it builds the generated main.
+func analyzeFunction(ctx *gcp.Context, fnSource string) error {
+	vet, err := env.Bool(env.GoVet, false)
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	staticcheck, err := env.Bool(env.GoStaticcheck, false)
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	if !vet && !staticcheck {
+		return nil
+	}
+	if !ctx.FileExists(fnSource, "go.mod") {
+		return gcp.UserErrorf("%s and %s require the function to have a go.mod file", env.GoVet, env.GoStaticcheck)
+	}
+	return golang.Analyze(ctx, fnSource, nil)
+}
+
+// frameworkVersionFromEnv returns the framework version requested with env.FunctionsFrameworkVersion,
+// or functionsFrameworkVersion if none was requested. Versions must be full semantic versions and
+// are returned with the "v" prefix of Go module versions.
+func frameworkVersionFromEnv() (string, error) {
+	v := os.Getenv(env.FunctionsFrameworkVersion)
+	if v == "" {
+		return functionsFrameworkVersion, nil
+	}
+	version, err := semver.Parse(strings.TrimPrefix(v, "v"))
+	if err != nil {
+		return "", gcp.UserErrorf("%s must be a semantic version such as %s, got %q: %v", env.FunctionsFrameworkVersion, functionsFrameworkVersion, v, err)
+	}
+	return "v" + version.String(), nil
+}
+
+// extractPackageNameInDir runs the converter to extract the name of the package in the specified
+// source directory.
+func (c *converter) extractPackageNameInDir(ctx *gcp.Context, source string) string {
+	return c.run(ctx, "-dir", source)
+}
+
+// functionTargets splits the comma-separated list of function targets in v, with which one image
+// serves several functions.
+func functionTargets(v string) ([]string, error) {
+	var names []string
+	seen := map[string]bool{}
+	for _, name := range strings.Split(v, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, gcp.UserErrorf("%s=%q must be a function name or a comma-separated list of function names", env.FunctionTarget, v)
+		}
+		if seen[name] {
+			return nil, gcp.UserErrorf("%s=%q lists function %s more than once", env.FunctionTarget, v, name)
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// targetRoutes returns the routes of the functions of an image that serves several functions, each
+// at the path of its name, such as /HelloWorld, or nil for a single function, which is served at the root.
+func targetRoutes(targets []resolvedTarget) ([]route, error) {
+	if len(targets) < 2 {
+		return nil, nil
+	}
+	var routes []route
+	for _, t := range targets {
+		if t.declarative {
+			return nil, gcp.UserErrorf("function %s is registered with the functions package, which serves a single function; list only one function in %s", t.name, env.FunctionTarget)
+		}
+		if t.subpackage != targets[0].subpackage {
+			return nil, gcp.UserErrorf("the functions listed in %s must be in the same package, found %s and %s", env.FunctionTarget, packageDir(targets[0]), packageDir(t))
+		}
+		routes = append(routes, route{Path: "/" + t.name, Target: t.name})
+	}
+	return routes, nil
+}
+
+// packageDir returns the package directory of t for messages.
+func packageDir(t resolvedTarget) string {
+	if t.subpackage == "" {
+		return "."
+	}
+	return t.subpackage
+}
+
+// Function kinds reported by the converter.
+const (
+	kindCloudEvent = "cloudevent"
+	kindUnknown    = "unknown"
+)
+
+// resolvedTarget is a function target validated by the converter.
+type resolvedTarget struct {
+	// kind is the kind of function reported by the converter, such as http or cloudevent.
+	kind string
+	// declarative is true if the package registers the function with functions.HTTP or
+	// functions.CloudEvent, as supported by framework v1.6.0 and later.
+	declarative bool
+	// subpackage is the slash-separated directory of the function's package relative to the function
+	// source, or empty for the package in the function source.
+	subpackage string
+	// name is the name of the function within its package.
+	name string
+}
+
+// validateTarget checks that the target is an exported function with a signature supported by the
+// generated main, or a function registered declaratively, of the package in the source directory or,
+// for targets qualified with a package directory, such as subpkg.Handler, of the package in that
+// subdirectory. Mismatches are thereby reported before they surface as compile errors in the
+// generated main.
+func (c *converter) validateTarget(ctx *gcp.Context, source, target string) (resolvedTarget, error) {
+	result, err := c.exec(ctx, "-dir", source, "-target", target)
+	if err != nil {
+		if result != nil && result.ExitCode == converterInvalidTargetCode {
+			return resolvedTarget{}, gcp.UserErrorf("invalid %s: %s", env.FunctionTarget, result.Stderr)
+		}
+		return resolvedTarget{}, err
+	}
+	// The converter prints the kind, package directory and name of the function.
+	fields := strings.Fields(result.Stdout)
+	if len(fields) != 3 {
+		return resolvedTarget{}, gcp.InternalErrorf("unexpected output validating function target %s: %q", target, result.Stdout)
+	}
+	kind, pkgDir, name := fields[0], fields[1], fields[2]
+	if kind == kindUnknown {
+		ctx.Debugf("Unable to determine the signature of function %s, leaving it to the compiler", target)
+	}
+	t := resolvedTarget{kind: kind, declarative: kind == "declarative", name: name}
+	if pkgDir != "." {
+		t.subpackage = pkgDir
+	}
+	return t, nil
+}
+
+// checkCloudEventTarget checks that t has the signature of a CloudEvent function, as declared with
+// GOOGLE_FUNCTION_SIGNATURE_TYPE=cloudevent. Declarative functions declare their own kind.
+func checkCloudEventTarget(ctx *gcp.Context, t resolvedTarget) error {
+	switch {
+	case t.declarative, t.kind == kindCloudEvent:
+		return nil
+	case t.kind == kindUnknown:
+		ctx.Warnf("Unable to check that function %s is a CloudEvent function", t.name)
+		return nil
+	}
+	return gcp.UserErrorf("function %s is a %s function, but %s=%s requires the signature func(context.Context, cloudevents.Event) error", t.name, t.kind, env.FunctionSignatureType, kindCloudEvent)
+}
+
+// requireCloudEvents adds the cloudevents SDK to the requirements of the app module in appDir, at the
+// version selected by the functions framework, unless the function requires its own version.
+func requireCloudEvents(ctx *gcp.Context, fnSource, appDir string) error {
+	v, err := moduleSpecifiedVersion(ctx, fnSource, cloudEventsModule)
+	if err != nil {
+		return fmt.Errorf("checking for %s dependency in go.mod: %w", cloudEventsModule, err)
+	}
+	if v != "" {
+		return nil
+	}
+	// Requiring the selected version, rather than a fixed one, cannot downgrade the framework's dependencies.
+	v = ctx.Exec([]string{"go", "list", "-m", "-f", "{{.Version}}", cloudEventsModule}, gcp.WithWorkDir(appDir)).Stdout
+	if v == "" {
+		return gcp.InternalErrorf("%s does not depend on %s", functionsFrameworkModule, cloudEventsModule)
+	}
+	ctx.Exec([]string{"go", "mod", "edit", "-require", fmt.Sprintf("%s@%s", cloudEventsModule, v)}, gcp.WithWorkDir(appDir))
+	return nil
+}
+
+// findMiddleware reports whether the package in dir declares middlewareName with the signature
+// func(http.Handler) http.Handler. Other declarations are reported before they surface as compile
+// errors in the generated main.
+func (c *converter) findMiddleware(ctx *gcp.Context, dir string) (bool, error) {
+	result, err := c.exec(ctx, "-dir", dir, "-middleware")
+	if err != nil {
+		if result != nil && result.ExitCode == converterInvalidTargetCode {
+			return false, gcp.UserErrorf("invalid function middleware: %s", result.Stderr)
+		}
+		return false, err
+	}
+	return strings.TrimSpace(result.Stdout) == middlewareName, nil
+}
+
+// converter runs the converter/get_package script, which parses the function source.
+type converter struct {
+	bin string
+}
+
+// buildConverter builds the converter script once per build. The parser is dependent on the language
+// version being used, and it's highly likely that the buildpack binary will be built with a different
+// version of the language than the function deployment. Building this script ensures that the version
+// of Go used to build the function app will be the same as the version used to parse it. Its GOCACHE
+// is kept between builds, so that rebuilding it only links the binary.
+func buildConverter(ctx *gcp.Context) *converter {
+	l := ctx.Layer(converterLayerName, gcp.CacheLayer)
+	return buildConverterInDir(ctx, filepath.Join(ctx.BuildpackRoot(), "converter", "get_package"), l.Path)
+}
+
+// buildConverterInDir builds the converter script in the GOPATH scriptDir into dir.
+func buildConverterInDir(ctx *gcp.Context, scriptDir, dir string) *converter {
+	bin := filepath.Join(dir, "bin", "converter")
+	ctx.Exec([]string{"go", "build", "-o", bin, "main"}, gcp.WithEnv("GO111MODULE=off", "GOPATH="+scriptDir, "GOCACHE="+filepath.Join(dir, "gocache")), gcp.WithWorkDir(scriptDir))
+	return &converter{bin: bin}
+}
+
+// run runs the converter with args and returns its output.
+func (c *converter) run(ctx *gcp.Context, args ...string) string {
+	result, err := c.exec(ctx, args...)
+	if err != nil {
+		ctx.Exit(1, err)
+	}
+	return result.Stdout
+}
+
+func (c *converter) exec(ctx *gcp.Context, args ...string) (*gcp.ExecResult, *gcp.Error) {
+	return ctx.ExecWithErr(append([]string{c.bin}, args...), gcp.WithUserAttribution)
 }
@@ -15,9 +15,31 @@
 package main
 
 import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"text/template"
+	"time"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/blang/semver"
+	"github.com/buildpacks/libcnb"
+)
+
+var (
+	// matrixFrameworkVersion is set by tools/bump-framework to compile the generated mains against a
+	// framework release. The compatibility matrix needs network access, so it is skipped by default.
+	matrixFrameworkVersion = flag.String("framework-version", "", "Functions framework version to compile the generated mains against.")
 )
 
 func TestDetect(t *testing.T) {
@@ -44,3 +66,1226 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestServerOptionsFromEnv(t *testing.T) {
+	testCases := []struct {
+		name    string
+		env     []string
+		want    serverOptions
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+		},
+		{
+			name: "all options",
+			env:  []string{"GOOGLE_FUNCTION_READ_HEADER_TIMEOUT=10s", "GOOGLE_FUNCTION_MAX_HEADER_BYTES=65536", "GOOGLE_FUNCTION_H2C=true"},
+			want: serverOptions{ReadHeaderTimeout: 10 * time.Second, MaxHeaderBytes: 65536, H2C: true},
+		},
+		{
+			name:    "invalid timeout",
+			env:     []string{"GOOGLE_FUNCTION_READ_HEADER_TIMEOUT=10"},
+			wantErr: true,
+		},
+		{
+			name:    "negative max header bytes",
+			env:     []string{"GOOGLE_FUNCTION_MAX_HEADER_BYTES=-1"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid h2c",
+			env:     []string{"GOOGLE_FUNCTION_H2C=yes please"},
+			wantErr: true,
+		},
+		{
+			name: "path prefix",
+			env:  []string{"GOOGLE_FUNCTION_PATH=/api/orders/"},
+			want: serverOptions{PathPrefix: "/api/orders"},
+		},
+		{
+			name:    "invalid path prefix",
+			env:     []string{"GOOGLE_FUNCTION_PATH=orders"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clearAndSetEnv(tc.env)
+			got, err := serverOptionsFromEnv()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("serverOptionsFromEnv() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("serverOptionsFromEnv() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSourceExclusions(t *testing.T) {
+	testCases := []struct {
+		name         string
+		gcloudignore string
+		env          []string
+		inPlace      bool
+		want         string
+		wantErr      bool
+	}{
+		{
+			name: "defaults",
+			want: "# default\n/.google*\n",
+		},
+		{
+			name:         "gcloudignore and env",
+			gcloudignore: "#!include:.gitignore\ndata/\n",
+			env:          []string{"GOOGLE_FUNCTION_SOURCE_EXCLUDE=!data/,*.bin"},
+			want:         "# default\n/.google*\n# .gcloudignore\ndata/\n# GOOGLE_FUNCTION_SOURCE_EXCLUDE\n!data/\n*.bin\n",
+		},
+		{
+			name:         "in place",
+			gcloudignore: "data/\n",
+			inPlace:      true,
+			want:         "# .gcloudignore\ndata/\n",
+		},
+		{
+			name:    "invalid env pattern",
+			env:     []string{"GOOGLE_FUNCTION_SOURCE_EXCLUDE=[abc"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "fn")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			if tc.gcloudignore != "" {
+				if err := ioutil.WriteFile(filepath.Join(dir, ".gcloudignore"), []byte(tc.gcloudignore), 0644); err != nil {
+					t.Fatalf("writing .gcloudignore: %v", err)
+				}
+			}
+			clearAndSetEnv(tc.env)
+
+			got, err := sourceExclusions(dir, !tc.inPlace)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("sourceExclusions() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if err == nil && got.String() != tc.want {
+				t.Errorf("sourceExclusions() = %q, want %q", got.String(), tc.want)
+			}
+		})
+	}
+}
+
+func TestFrameworkVersionFromEnv(t *testing.T) {
+	testCases := []struct {
+		name    string
+		env     []string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "default",
+			want: functionsFrameworkVersion,
+		},
+		{
+			name: "with v prefix",
+			env:  []string{"GOOGLE_FUNCTIONS_FRAMEWORK_VERSION=v1.2.0"},
+			want: "v1.2.0",
+		},
+		{
+			name: "without v prefix",
+			env:  []string{"GOOGLE_FUNCTIONS_FRAMEWORK_VERSION=1.6.1"},
+			want: "v1.6.1",
+		},
+		{
+			name: "prerelease",
+			env:  []string{"GOOGLE_FUNCTIONS_FRAMEWORK_VERSION=v1.7.0-rc.1"},
+			want: "v1.7.0-rc.1",
+		},
+		{
+			name:    "partial version",
+			env:     []string{"GOOGLE_FUNCTIONS_FRAMEWORK_VERSION=v1.2"},
+			wantErr: true,
+		},
+		{
+			name:    "branch",
+			env:     []string{"GOOGLE_FUNCTIONS_FRAMEWORK_VERSION=master"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clearAndSetEnv(tc.env)
+			got, err := frameworkVersionFromEnv()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("frameworkVersionFromEnv() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("frameworkVersionFromEnv() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFunctionSource(t *testing.T) {
+	root, err := ioutil.TempDir("", "source")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(root)
+	if err := os.MkdirAll(filepath.Join(root, "functions", "hello"), 0755); err != nil {
+		t.Fatalf("creating dir: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "go.mod"), []byte("module example.com/hello"), 0644); err != nil {
+		t.Fatalf("writing go.mod: %v", err)
+	}
+
+	testCases := []struct {
+		name    string
+		env     []string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "unset",
+			want: root,
+		},
+		{
+			name: "subdirectory",
+			env:  []string{"GOOGLE_FUNCTION_SOURCE=functions/hello/"},
+			want: filepath.Join(root, "functions", "hello"),
+		},
+		{
+			name:    "outside source",
+			env:     []string{"GOOGLE_FUNCTION_SOURCE=../hello"},
+			wantErr: true,
+		},
+		{
+			name:    "absolute",
+			env:     []string{"GOOGLE_FUNCTION_SOURCE=/functions/hello"},
+			wantErr: true,
+		},
+		{
+			name:    "missing",
+			env:     []string{"GOOGLE_FUNCTION_SOURCE=functions/goodbye"},
+			wantErr: true,
+		},
+		{
+			name:    "file",
+			env:     []string{"GOOGLE_FUNCTION_SOURCE=go.mod"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clearAndSetEnv(tc.env)
+			got, err := functionSource(root)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("functionSource() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("functionSource() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMainTemplates(t *testing.T) {
+	testCases := []struct {
+		name        string
+		server      serverOptions
+		middleware  bool
+		wantStrings []string
+		wantMissing []string
+	}{
+		{
+			name:        "default server",
+			wantStrings: []string{`"os/signal"`, "signal.Notify(sigs, syscall.SIGTERM, os.Interrupt)", `durationFromEnv("FUNCTION_SHUTDOWN_TIMEOUT", 10*time.Second)`, "server.Shutdown(ctx)", "server.ListenAndServe()"},
+			wantMissing: []string{"funcframework.Start(port)", "h2c", "ReadHeaderTimeout", "MaxHeaderBytes", "FunctionMiddleware", "withPathPrefix"},
+		},
+		{
+			name:        "hardened server",
+			server:      serverOptions{ReadHeaderTimeout: 5 * time.Second, MaxHeaderBytes: 1024},
+			wantStrings: []string{`"time"`, "ReadHeaderTimeout: time.Duration(5000000000), // 5s", "MaxHeaderBytes: 1024,", "server.ListenAndServe()"},
+			wantMissing: []string{"funcframework.Start(port)", "h2c"},
+		},
+		{
+			name:        "h2c server",
+			server:      serverOptions{H2C: true},
+			wantStrings: []string{`"golang.org/x/net/http2/h2c"`, "h2c.NewHandler(handler, &http2.Server{})"},
+			wantMissing: []string{"funcframework.Start(port)", "ReadHeaderTimeout"},
+		},
+		{
+			name:        "middleware",
+			server:      serverOptions{H2C: true},
+			middleware:  true,
+			wantStrings: []string{"handler = userfunction.FunctionMiddleware(handler)\n\thandler = h2c.NewHandler(handler, &http2.Server{})"},
+		},
+		{
+			name:        "path prefix",
+			server:      serverOptions{PathPrefix: "/api/orders"},
+			middleware:  true,
+			wantStrings: []string{"func withPathPrefix(prefix string, h http.Handler) http.Handler {", "handler = userfunction.FunctionMiddleware(handler)\n\thandler = withPathPrefix(\"/api/orders\", handler)", `log.Printf("Serving function on port %s at /api/orders", port)`},
+			wantMissing: []string{"funcframework.Start(port)", "h2c"},
+		},
+	}
+	for _, tc := range testCases {
+		for name, tmpl := range map[string]*template.Template{"v0": tmplV0, "v1_1": tmplV1_1} {
+			t.Run(tc.name+"/"+name, func(t *testing.T) {
+				var buf bytes.Buffer
+				fn := fnInfo{Target: "HelloWorld", Package: "example.com/hello", Server: tc.server, Middleware: tc.middleware}
+				if err := tmpl.Execute(&buf, fn); err != nil {
+					t.Fatalf("executing template: %v", err)
+				}
+				main := buf.String()
+				if _, err := parser.ParseFile(token.NewFileSet(), "main.go", main, parser.AllErrors); err != nil {
+					t.Fatalf("generated main.go does not parse: %v\n%s", err, main)
+				}
+				for _, s := range tc.wantStrings {
+					if !strings.Contains(main, s) {
+						t.Errorf("generated main.go does not contain %q:\n%s", s, main)
+					}
+				}
+				for _, s := range tc.wantMissing {
+					if strings.Contains(main, s) {
+						t.Errorf("generated main.go unexpectedly contains %q:\n%s", s, main)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestMainTemplatesRoutes(t *testing.T) {
+	fn := fnInfo{
+		Target:  "Hello",
+		Package: "example.com/hello",
+		Routes:  []route{{Path: "/Hello", Target: "Hello"}, {Path: "/Goodbye", Target: "Goodbye"}},
+	}
+	for name, tmpl := range map[string]*template.Template{"v0": tmplV0, "v1_1": tmplV1_1} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tmpl.Execute(&buf, fn); err != nil {
+				t.Fatalf("executing template: %v", err)
+			}
+			main := buf.String()
+			if _, err := parser.ParseFile(token.NewFileSet(), "main.go", main, parser.AllErrors); err != nil {
+				t.Fatalf("generated main.go does not parse: %v\n%s", err, main)
+			}
+			for _, s := range []string{`register("/Hello", userfunction.Hello)`, `register("/Goodbye", userfunction.Goodbye)`} {
+				if !strings.Contains(main, s) {
+					t.Errorf("generated main.go does not contain %q:\n%s", s, main)
+				}
+			}
+			if strings.Contains(main, `register("/",`) {
+				t.Errorf("generated main.go unexpectedly registers a function at the root:\n%s", main)
+			}
+		})
+	}
+}
+
+func TestFunctionTargets(t *testing.T) {
+	testCases := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{value: "Hello", want: []string{"Hello"}},
+		{value: "Hello,Goodbye", want: []string{"Hello", "Goodbye"}},
+		{value: " Hello , sub.Goodbye ", want: []string{"Hello", "sub.Goodbye"}},
+		{value: "Hello,", wantErr: true},
+		{value: "Hello,Hello", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			got, err := functionTargets(tc.value)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("functionTargets(%q) got error: %v, want error: %t", tc.value, err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("functionTargets(%q) = %q, want %q", tc.value, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTargetRoutes(t *testing.T) {
+	testCases := []struct {
+		name    string
+		targets []resolvedTarget
+		want    []route
+		wantErr bool
+	}{
+		{
+			name:    "single target",
+			targets: []resolvedTarget{{name: "Hello"}},
+		},
+		{
+			name:    "single declarative target",
+			targets: []resolvedTarget{{name: "Hello", declarative: true}},
+		},
+		{
+			name:    "several targets",
+			targets: []resolvedTarget{{name: "Hello", subpackage: "sub"}, {name: "Goodbye", subpackage: "sub"}},
+			want:    []route{{Path: "/Hello", Target: "Hello"}, {Path: "/Goodbye", Target: "Goodbye"}},
+		},
+		{
+			name:    "declarative target among several",
+			targets: []resolvedTarget{{name: "Hello"}, {name: "Goodbye", declarative: true}},
+			wantErr: true,
+		},
+		{
+			name:    "different packages",
+			targets: []resolvedTarget{{name: "Hello"}, {name: "Goodbye", subpackage: "sub"}},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := targetRoutes(tc.targets)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("targetRoutes() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("targetRoutes() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDeclarativeTemplate(t *testing.T) {
+	var buf bytes.Buffer
+	fn := fnInfo{Target: "HelloWorld", Package: "example.com/hello", Declarative: true}
+	if err := tmplDeclarative.Execute(&buf, fn); err != nil {
+		t.Fatalf("executing template: %v", err)
+	}
+	main := buf.String()
+	if _, err := parser.ParseFile(token.NewFileSet(), "main.go", main, parser.AllErrors); err != nil {
+		t.Fatalf("generated main.go does not parse: %v\n%s", err, main)
+	}
+	for _, s := range []string{`_ "example.com/hello"`, "funcframework.Start(port)"} {
+		if !strings.Contains(main, s) {
+			t.Errorf("generated main.go does not contain %q:\n%s", s, main)
+		}
+	}
+	if strings.Contains(main, "HelloWorld") {
+		t.Errorf("generated main.go unexpectedly refers to the target symbol:\n%s", main)
+	}
+}
+
+func TestRegistryTemplate(t *testing.T) {
+	testCases := []struct {
+		name        string
+		fn          fnInfo
+		wantStrings []string
+	}{
+		{
+			name:        "function",
+			fn:          fnInfo{Target: "HelloWorld", Package: "example.com/hello"},
+			wantStrings: []string{`register("/", userfunction.HelloWorld)`},
+		},
+		{
+			name:        "cloudevent",
+			fn:          fnInfo{Target: "HelloWorld", Package: "example.com/hello", CloudEvent: true},
+			wantStrings: []string{`funcframework.RegisterCloudEventFunctionContext(context.Background(), "/", userfunction.HelloWorld)`},
+		},
+		{
+			name:        "routes",
+			fn:          fnInfo{Target: "Hello", Package: "example.com/hello", Routes: []route{{Path: "/Hello", Target: "Hello"}, {Path: "/Goodbye", Target: "Goodbye"}}},
+			wantStrings: []string{`register("/Hello", userfunction.Hello)`, `register("/Goodbye", userfunction.Goodbye)`},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tmplRegistry.Execute(&buf, tc.fn); err != nil {
+				t.Fatalf("executing template: %v", err)
+			}
+			main := buf.String()
+			if _, err := parser.ParseFile(token.NewFileSet(), "main.go", main, parser.AllErrors); err != nil {
+				t.Fatalf("generated main.go does not parse: %v\n%s", err, main)
+			}
+			want := append([]string{`os.Unsetenv("FUNCTION_TARGET")`, "funcframework.Start(port)"}, tc.wantStrings...)
+			for _, s := range want {
+				if !strings.Contains(main, s) {
+					t.Errorf("generated main.go does not contain %q:\n%s", s, main)
+				}
+			}
+			for _, s := range []string{"http.DefaultServeMux", "server.ListenAndServe()", "signal.Notify"} {
+				if strings.Contains(main, s) {
+					t.Errorf("generated main.go unexpectedly contains %q:\n%s", s, main)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateMainGoFileRegistry(t *testing.T) {
+	testCases := []struct {
+		name      string
+		version   string
+		fn        fnInfo
+		wantStart bool
+		wantErr   bool
+	}{
+		{
+			name:    "earlier version",
+			version: "v1.5.2",
+			fn:      fnInfo{Target: "Hello", Package: "example.com/hello"},
+		},
+		{
+			name:    "unknown vendored version",
+			version: "v0.0.0",
+			fn:      fnInfo{Target: "Hello", Package: "example.com/hello"},
+		},
+		{
+			name:      "registry version",
+			version:   "v1.6.1",
+			fn:        fnInfo{Target: "Hello", Package: "example.com/hello"},
+			wantStart: true,
+		},
+		{
+			name:      "later registry version",
+			version:   "v1.8.1",
+			fn:        fnInfo{Target: "Hello", Package: "example.com/hello", CloudEvent: true},
+			wantStart: true,
+		},
+		{
+			name:    "server options",
+			version: "v1.6.1",
+			fn:      fnInfo{Target: "Hello", Package: "example.com/hello", Server: serverOptions{PathPrefix: "/orders"}},
+			wantErr: true,
+		},
+		{
+			name:    "middleware",
+			version: "v1.6.1",
+			fn:      fnInfo{Target: "Hello", Package: "example.com/hello", Middleware: true},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "fn")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			ctx := gcp.NewContextForTests(libcnb.BuildpackInfo{}, dir)
+			path := filepath.Join(dir, "main.go")
+
+			err = createMainGoFile(ctx, tc.fn, path, tc.version)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("createMainGoFile() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+
+			main, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatalf("reading generated main.go: %v", err)
+			}
+			if gotStart := strings.Contains(string(main), "funcframework.Start(port)"); gotStart != tc.wantStart {
+				t.Errorf("generated main.go calls funcframework.Start: %t, want %t:\n%s", gotStart, tc.wantStart, main)
+			}
+		})
+	}
+}
+
+func TestMainTestTemplate(t *testing.T) {
+	testCases := []struct {
+		name        string
+		fn          fnInfo
+		wantStrings []string
+		wantMissing []string
+	}{
+		{
+			name:        "http",
+			fn:          fnInfo{Target: "HelloWorld", Package: "example.com/hello"},
+			wantStrings: []string{`userfunction "example.com/hello"`, `register("/", userfunction.HelloWorld)`, `checkServes(t, handler, "/", "HelloWorld", userfunction.HelloWorld)`, "var handler http.Handler = http.DefaultServeMux\n"},
+			wantMissing: []string{"funcframework", "FunctionMiddleware", "\t\"context\"\n", `"ce-specversion"`},
+		},
+		{
+			name:        "cloudevent",
+			fn:          fnInfo{Target: "HelloWorld", Package: "example.com/hello", CloudEvent: true},
+			wantStrings: []string{`funcframework.RegisterCloudEventFunctionContext(context.Background(), "/", userfunction.HelloWorld)`, `"ce-specversion"`, `"subscription"`},
+			wantMissing: []string{"register(", `"eventType"`},
+		},
+		{
+			name: "routes with middleware",
+			fn: fnInfo{
+				Target:     "Hello",
+				Package:    "example.com/hello",
+				Routes:     []route{{Path: "/Hello", Target: "Hello"}, {Path: "/Goodbye", Target: "Goodbye"}},
+				Middleware: true,
+			},
+			wantStrings: []string{`register("/Hello", userfunction.Hello)`, `register("/Goodbye", userfunction.Goodbye)`, `checkServes(t, handler, "/Goodbye", "Goodbye", userfunction.Goodbye)`, "handler = userfunction.FunctionMiddleware(handler)"},
+			wantMissing: []string{`register("/",`},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tmplMainTest.Execute(&buf, tc.fn); err != nil {
+				t.Fatalf("executing template: %v", err)
+			}
+			mainTest := buf.String()
+			if _, err := parser.ParseFile(token.NewFileSet(), "main_test.go", mainTest, parser.AllErrors); err != nil {
+				t.Fatalf("generated main_test.go does not parse: %v\n%s", err, mainTest)
+			}
+			for _, s := range tc.wantStrings {
+				if !strings.Contains(mainTest, s) {
+					t.Errorf("generated main_test.go does not contain %q:\n%s", s, mainTest)
+				}
+			}
+			for _, s := range tc.wantMissing {
+				if strings.Contains(mainTest, s) {
+					t.Errorf("generated main_test.go unexpectedly contains %q:\n%s", s, mainTest)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateMainSelfTest(t *testing.T) {
+	testCases := []struct {
+		name        string
+		selfTest    bool
+		declarative bool
+		version     string
+		wantTest    bool
+	}{
+		{
+			name:    "not requested",
+			version: "v1.2.0",
+		},
+		{
+			name:     "requested",
+			selfTest: true,
+			version:  "v1.2.0",
+			wantTest: true,
+		},
+		{
+			name:        "declarative",
+			selfTest:    true,
+			declarative: true,
+			version:     "v1.6.0",
+		},
+		{
+			name:     "registry version",
+			selfTest: true,
+			version:  "v1.6.1",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "fn")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			ctx := gcp.NewContextForTests(libcnb.BuildpackInfo{}, dir)
+			l := &libcnb.Layer{Path: filepath.Join(dir, "layer"), BuildEnvironment: libcnb.Environment{}}
+			fn := fnInfo{Target: "Hello", Package: "example.com/hello", SelfTest: tc.selfTest, Declarative: tc.declarative}
+
+			if err := createMain(ctx, l, fn, filepath.Join(dir, "main.go"), tc.version); err != nil {
+				t.Fatalf("createMain() got error: %v", err)
+			}
+
+			_, err = os.Stat(filepath.Join(dir, "main_test.go"))
+			if gotTest := err == nil; gotTest != tc.wantTest {
+				t.Errorf("createMain() generated main_test.go: %t, want %t", gotTest, tc.wantTest)
+			}
+			_, published := l.Metadata["handoff."+string(gcp.HandoffGoMainTest)]
+			if published != tc.wantTest {
+				t.Errorf("createMain() published %s: %t, want %t", gcp.HandoffGoMainTest, published, tc.wantTest)
+			}
+		})
+	}
+}
+
+func TestCreateMainGoFileSubpackage(t *testing.T) {
+	testCases := []struct {
+		name        string
+		fn          fnInfo
+		wantStrings []string
+	}{
+		{
+			name:        "function",
+			fn:          fnInfo{Target: "Hello", Package: "example.com/hello", Subpackage: "sub/pkg"},
+			wantStrings: []string{`userfunction "example.com/hello/sub/pkg"`, `register("/", userfunction.Hello)`},
+		},
+		{
+			name:        "declarative",
+			fn:          fnInfo{Target: "Hello", Package: "example.com/hello", Subpackage: "sub", Declarative: true},
+			wantStrings: []string{`_ "example.com/hello/sub"`, `os.Getenv("FUNCTION_TARGET") == "sub.Hello"`, `os.Setenv("FUNCTION_TARGET", "Hello")`},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "fn")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			ctx := gcp.NewContextForTests(libcnb.BuildpackInfo{}, dir)
+			path := filepath.Join(dir, "main.go")
+
+			if err := createMainGoFile(ctx, tc.fn, path, "v1.6.0"); err != nil {
+				t.Fatalf("createMainGoFile() got error: %v", err)
+			}
+
+			main, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatalf("reading generated main.go: %v", err)
+			}
+			if _, err := parser.ParseFile(token.NewFileSet(), "main.go", main, parser.AllErrors); err != nil {
+				t.Fatalf("generated main.go does not parse: %v\n%s", err, main)
+			}
+			for _, s := range tc.wantStrings {
+				if !strings.Contains(string(main), s) {
+					t.Errorf("generated main.go does not contain %q:\n%s", s, main)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateMainGoFileCloudEvent(t *testing.T) {
+	testCases := []struct {
+		name    string
+		version string
+		wantErr bool
+	}{
+		{
+			name:    "supported version",
+			version: "v1.2.0",
+		},
+		{
+			name:    "unknown vendored version",
+			version: "v0.0.0",
+		},
+		{
+			name:    "unsupported version",
+			version: "v1.0.0",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "fn")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			ctx := gcp.NewContextForTests(libcnb.BuildpackInfo{}, dir)
+			path := filepath.Join(dir, "main.go")
+			fn := fnInfo{Target: "Hello", Package: "example.com/hello", CloudEvent: true}
+
+			err = createMainGoFile(ctx, fn, path, tc.version)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("createMainGoFile() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+
+			main, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatalf("reading generated main.go: %v", err)
+			}
+			if _, err := parser.ParseFile(token.NewFileSet(), "main.go", main, parser.AllErrors); err != nil {
+				t.Fatalf("generated main.go does not parse: %v\n%s", err, main)
+			}
+			if want := `funcframework.RegisterCloudEventFunctionContext(context.Background(), "/", userfunction.Hello)`; !strings.Contains(string(main), want) {
+				t.Errorf("generated main.go does not contain %q:\n%s", want, main)
+			}
+		})
+	}
+}
+
+func TestCheckCloudEventTarget(t *testing.T) {
+	testCases := []struct {
+		name    string
+		target  resolvedTarget
+		wantErr bool
+	}{
+		{
+			name:   "cloudevent",
+			target: resolvedTarget{kind: "cloudevent", name: "Hello"},
+		},
+		{
+			name:   "declarative",
+			target: resolvedTarget{kind: "declarative", declarative: true, name: "Hello"},
+		},
+		{
+			name:   "unknown",
+			target: resolvedTarget{kind: "unknown", name: "Hello"},
+		},
+		{
+			name:    "http",
+			target:  resolvedTarget{kind: "http", name: "Hello"},
+			wantErr: true,
+		},
+		{
+			name:    "event",
+			target:  resolvedTarget{kind: "event", name: "Hello"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := gcp.NewContextForTests(libcnb.BuildpackInfo{}, "")
+
+			err := checkCloudEventTarget(ctx, tc.target)
+
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("checkCloudEventTarget(%v) got error: %v, want error: %t", tc.target, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestCreateMainGoModVendored(t *testing.T) {
+	testCases := []struct {
+		name       string
+		modulesTxt string
+		wantErr    bool
+	}{
+		{
+			name:       "vendored framework",
+			modulesTxt: "# github.com/GoogleCloudPlatform/functions-framework-go v1.2.0\n## explicit\ngithub.com/GoogleCloudPlatform/functions-framework-go/funcframework\n",
+		},
+		{
+			name:       "framework not vendored",
+			modulesTxt: "# example.com/dep v1.0.0\n## explicit\nexample.com/dep\n",
+			wantErr:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			src, err := ioutil.TempDir("", "fn")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(src)
+			files := map[string]string{
+				"go.mod":             "module example.com/fn\n\ngo 1.14\n",
+				"fn.go":              "package fn\n",
+				"vendor/modules.txt": tc.modulesTxt,
+			}
+			for name, content := range files {
+				path := filepath.Join(src, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating dir: %v", err)
+				}
+				if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatalf("writing %s: %v", name, err)
+				}
+			}
+			ctx := gcp.NewContextForTests(libcnb.BuildpackInfo{}, src)
+			gcp.WithExecutor(goListExecutor{module: "example.com/fn"})(ctx)
+			l := &libcnb.Layer{BuildEnvironment: libcnb.Environment{}}
+
+			err = createMainGoModVendored(ctx, l, fnInfo{Source: src, Target: "HelloWorld", Package: "fn"})
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("createMainGoModVendored() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			main, err := ioutil.ReadFile(filepath.Join(src, appName, "main.go"))
+			if err != nil {
+				t.Fatalf("reading generated main.go: %v", err)
+			}
+			if want := `userfunction "example.com/fn"`; !strings.Contains(string(main), want) {
+				t.Errorf("generated main.go does not contain %q:\n%s", want, main)
+			}
+			if got, want := l.BuildEnvironment["GOFLAGS.override"], "-mod=vendor"; got != want {
+				t.Errorf("GOFLAGS = %q, want %q", got, want)
+			}
+			if got, want := l.BuildEnvironment["GOOGLE_BUILDABLE.override"], "./"+appName; got != want {
+				t.Errorf("GOOGLE_BUILDABLE = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestCreateMainGoModInPlace(t *testing.T) {
+	src, err := ioutil.TempDir("", "fn")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(src)
+	appDir, err := ioutil.TempDir("", "main")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(appDir)
+	ctx := gcp.NewContextForTests(libcnb.BuildpackInfo{}, src)
+	x := &modExecutor{module: "example.com/fn", version: "v1.2.0"}
+	gcp.WithExecutor(x)(ctx)
+	l := &libcnb.Layer{BuildEnvironment: libcnb.Environment{}, LaunchEnvironment: libcnb.Environment{}}
+
+	if err := createMainGoMod(ctx, l, fnInfo{Source: src, Target: "HelloWorld", Package: "fn"}, src, appDir); err != nil {
+		t.Fatalf("createMainGoMod() got error: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(appDir, "main.go")); err != nil {
+		t.Errorf("main.go not generated in %s: %v", appDir, err)
+	}
+	if _, err := os.Stat(filepath.Join(src, "main.go")); !os.IsNotExist(err) {
+		t.Errorf("main.go generated in the function source, want only in %s", appDir)
+	}
+	wantReplace := []string{"go", "mod", "edit", "-replace", "example.com/fn@v0.0.0=" + src}
+	var gotReplace bool
+	for i, args := range x.commands {
+		// Only commands that read the function's go.mod run in the function source.
+		if args[1] == "list" && x.dirs[i] == src {
+			continue
+		}
+		if x.dirs[i] != appDir {
+			t.Errorf("createMainGoMod() ran %q in %s, want %s", args, x.dirs[i], appDir)
+		}
+		if reflect.DeepEqual(args, wantReplace) {
+			gotReplace = true
+		}
+	}
+	if !gotReplace {
+		t.Errorf("createMainGoMod() ran %q, want %q", x.commands, wantReplace)
+	}
+}
+
+func TestCheckInPlace(t *testing.T) {
+	testCases := []struct {
+		name    string
+		files   []string
+		wantErr bool
+	}{
+		{
+			name:  "go.mod",
+			files: []string{"go.mod", "fn.go"},
+		},
+		{
+			name:    "no go.mod",
+			files:   []string{"fn.go"},
+			wantErr: true,
+		},
+		{
+			name:    "vendor",
+			files:   []string{"go.mod", "fn.go", "vendor/modules.txt"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			src, err := ioutil.TempDir("", "fn")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(src)
+			for _, f := range tc.files {
+				path := filepath.Join(src, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating dir for %s: %v", f, err)
+				}
+				if err := ioutil.WriteFile(path, []byte{}, 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+			clearAndSetEnv(nil)
+			ctx := gcp.NewContextForTests(libcnb.BuildpackInfo{}, src)
+
+			err = checkInPlace(ctx, src)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("checkInPlace() got error: %v, want error: %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunTests(t *testing.T) {
+	testCases := []struct {
+		name    string
+		goMod   bool
+		wantErr bool
+	}{
+		{
+			name:  "go.mod",
+			goMod: true,
+		},
+		{
+			name:    "no go.mod",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			src, err := ioutil.TempDir("", "fn")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(src)
+			if tc.goMod {
+				if err := ioutil.WriteFile(filepath.Join(src, "go.mod"), []byte("module example.com/fn\n"), 0644); err != nil {
+					t.Fatalf("writing go.mod: %v", err)
+				}
+			}
+			ctx := gcp.NewContextForTests(libcnb.BuildpackInfo{}, src)
+			x := &modExecutor{}
+			gcp.WithExecutor(x)(ctx)
+
+			err = runTests(ctx, src)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("runTests() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			want := [][]string{{"go", "test", "./..."}}
+			if !reflect.DeepEqual(x.commands, want) || x.dirs[0] != src {
+				t.Errorf("runTests() ran %q in %q, want %q in %s", x.commands, x.dirs, want, src)
+			}
+		})
+	}
+}
+
+func TestBuildConverter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "converter")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	ctx := gcp.NewContextForTests(libcnb.BuildpackInfo{}, dir)
+	x := &modExecutor{}
+	gcp.WithExecutor(x)(ctx)
+
+	c := buildConverterInDir(ctx, "/bp/converter/get_package", dir)
+	c.extractPackageNameInDir(ctx, "/src")
+	if _, err := c.findMiddleware(ctx, "/src"); err != nil {
+		t.Fatalf("findMiddleware() got error: %v", err)
+	}
+
+	bin := filepath.Join(dir, "bin", "converter")
+	want := [][]string{
+		{"go", "build", "-o", bin, "main"},
+		{bin, "-dir", "/src"},
+		{bin, "-dir", "/src", "-middleware"},
+	}
+	if !reflect.DeepEqual(x.commands, want) {
+		t.Errorf("converter ran %q, want %q", x.commands, want)
+	}
+	if x.dirs[0] != "/bp/converter/get_package" {
+		t.Errorf("converter was built in %q, want %q", x.dirs[0], "/bp/converter/get_package")
+	}
+}
+
+func TestFetchFramework(t *testing.T) {
+	testCases := []struct {
+		name    string
+		h2c     bool
+		wantGet []string
+	}{
+		{
+			name:    "framework",
+			wantGet: []string{"go", "get", "github.com/GoogleCloudPlatform/functions-framework-go@v1.2.0"},
+		},
+		{
+			name:    "framework and h2c",
+			h2c:     true,
+			wantGet: []string{"go", "get", "github.com/GoogleCloudPlatform/functions-framework-go@v1.2.0", "golang.org/x/net@" + h2cVersion},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "fn")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			ctx := gcp.NewContextForTests(libcnb.BuildpackInfo{}, dir)
+			x := &recordingExecutor{}
+			gcp.WithExecutor(x)(ctx)
+
+			fetchFramework(ctx, filepath.Join(dir, "src"), fnInfo{FrameworkVersion: "v1.2.0", Server: serverOptions{H2C: tc.h2c}})
+
+			var gotGet bool
+			for _, args := range x.commands {
+				if args[0] == "git" {
+					t.Errorf("fetchFramework() ran %q, want no git commands", args)
+				}
+				if reflect.DeepEqual(args, tc.wantGet) {
+					gotGet = true
+				}
+			}
+			if !gotGet {
+				t.Errorf("fetchFramework() ran %q, want %q", x.commands, tc.wantGet)
+			}
+		})
+	}
+}
+
+// recordingExecutor records the commands it is asked to run without running them.
+type recordingExecutor struct {
+	commands [][]string
+}
+
+func (e *recordingExecutor) Run(cmd *exec.Cmd) (int, error) {
+	e.commands = append(e.commands, cmd.Args)
+	return 0, nil
+}
+
+// goListExecutor answers `go list -m` with module without running go.
+type goListExecutor struct {
+	module string
+}
+
+func (e goListExecutor) Run(cmd *exec.Cmd) (int, error) {
+	io.WriteString(cmd.Stdout, e.module+"\n")
+	return 0, nil
+}
+
+// modExecutor answers `go list -m` with module and `go list -m -f {{.Version}}` with version, and
+// records the commands it is asked to run with their directories.
+type modExecutor struct {
+	module   string
+	version  string
+	commands [][]string
+	dirs     []string
+}
+
+func (e *modExecutor) Run(cmd *exec.Cmd) (int, error) {
+	e.commands = append(e.commands, cmd.Args)
+	e.dirs = append(e.dirs, cmd.Dir)
+	switch {
+	case reflect.DeepEqual(cmd.Args, []string{"go", "list", "-m"}):
+		io.WriteString(cmd.Stdout, e.module+"\n")
+	case len(cmd.Args) > 3 && cmd.Args[1] == "list":
+		io.WriteString(cmd.Stdout, e.version+"\n")
+	}
+	return 0, nil
+}
+
+func clearAndSetEnv(env []string) {
+	os.Clearenv()
+	for _, p := range env {
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) == 2 {
+			os.Setenv(kv[0], kv[1])
+		}
+	}
+}
+
+// matrixFunctions are function sources of each signature type, keyed by fnInfo kind.
+var matrixFunctions = map[string]string{
+	"http": `package hello
+
+import "net/http"
+
+func Hello(w http.ResponseWriter, r *http.Request) {}
+`,
+	"event": `package hello
+
+import "context"
+
+func Hello(ctx context.Context, m struct{ Data []byte }) error { return nil }
+`,
+	"cloudevent": `package hello
+
+import (
+	"context"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+func Hello(ctx context.Context, e event.Event) error { return nil }
+`,
+	"declarative": `package hello
+
+import (
+	"net/http"
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+)
+
+func init() {
+	functions.HTTP("Hello", func(w http.ResponseWriter, r *http.Request) {})
+}
+`,
+}
+
+// TestFrameworkCompatibility compiles the main generated for every signature type and server option
+// against the framework version set with -framework-version, to catch changes of the framework API
+// that the templates rely on.
+func TestFrameworkCompatibility(t *testing.T) {
+	if *matrixFrameworkVersion == "" {
+		t.Skip("-framework-version is not set")
+	}
+	version := *matrixFrameworkVersion
+	v, err := semver.ParseTolerant(version)
+	if err != nil {
+		t.Fatalf("parsing -framework-version: %v", err)
+	}
+	for _, kind := range []string{"http", "event", "cloudevent", "declarative"} {
+		for _, server := range []serverOptions{{}, {ReadHeaderTimeout: time.Second, H2C: true}} {
+			name := fmt.Sprintf("%s/custom_server=%t", kind, server.Custom())
+			fn := fnInfo{
+				Target:      "Hello",
+				Package:     "example.com/hello",
+				Server:      server,
+				CloudEvent:  kind == "cloudevent",
+				Declarative: kind == "declarative",
+			}
+			switch {
+			case fn.CloudEvent && v.LT(semver.MustParse(strings.TrimPrefix(cloudEventVersion, "v"))),
+				fn.Declarative && v.LT(semver.MustParse(strings.TrimPrefix(declarativeVersion, "v"))),
+				server.Custom() && v.GE(semver.MustParse(strings.TrimPrefix(registryVersion, "v"))):
+				continue
+			}
+			t.Run(name, func(t *testing.T) {
+				dir, err := ioutil.TempDir("", "matrix")
+				if err != nil {
+					t.Fatalf("creating temp dir: %v", err)
+				}
+				defer os.RemoveAll(dir)
+				fnDir, appDir := filepath.Join(dir, "fn"), filepath.Join(dir, "app")
+				for _, d := range []string{fnDir, appDir} {
+					if err := os.MkdirAll(d, 0755); err != nil {
+						t.Fatalf("creating %s: %v", d, err)
+					}
+				}
+				files := map[string]string{
+					filepath.Join(fnDir, "go.mod"):   "module example.com/hello\n\ngo 1.13\n",
+					filepath.Join(fnDir, "hello.go"): matrixFunctions[kind],
+					filepath.Join(appDir, "go.mod"): fmt.Sprintf("module %s\n\ngo 1.13\n\nrequire (\n\t%s %s\n\texample.com/hello v0.0.0\n)\n\nreplace example.com/hello => ../fn\n",
+						appName, functionsFrameworkModule, version),
+				}
+				for path, content := range files {
+					if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+						t.Fatalf("writing %s: %v", path, err)
+					}
+				}
+				ctx := gcp.NewContextForTests(libcnb.BuildpackInfo{}, appDir)
+				if err := createMainGoFile(ctx, fn, filepath.Join(appDir, "main.go"), version); err != nil {
+					t.Fatalf("createMainGoFile() got error: %v", err)
+				}
+
+				for _, cmd := range [][]string{{"go", "get", functionsFrameworkModule + "@" + version}, {"go", "mod", "tidy"}, {"go", "build", "./..."}} {
+					for _, d := range []string{fnDir, appDir} {
+						if cmd[1] == "build" && d == fnDir {
+							continue
+						}
+						c := exec.Command(cmd[0], cmd[1:]...)
+						c.Dir = d
+						if out, err := c.CombinedOutput(); err != nil {
+							main, _ := ioutil.ReadFile(filepath.Join(appDir, "main.go"))
+							t.Fatalf("%s in %s failed: %v\n%s\ngenerated main.go:\n%s", strings.Join(cmd, " "), d, err, out, main)
+						}
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+const mainTextTemplateDeclarative = `// Binary main file implements an HTTP server that runs the functions that the
+// user's package registers with the functions framework in its init functions.
+// The framework serves the function named by the FUNCTION_TARGET env var.
+package main
+
+import (
+	"log"
+	"os"
+
+	_ "{{.Package}}"
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/funcframework"
+)
+
+func main() {
+{{- if .Subpackage}}
+	// The function is registered in its package under its unqualified name.
+	if os.Getenv("FUNCTION_TARGET") == {{printf "%q" (printf "%s.%s" .Subpackage .Target)}} {
+		os.Setenv("FUNCTION_TARGET", {{printf "%q" .Target}})
+	}
+{{- end}}
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	if err := funcframework.Start(port); err != nil {
+		log.Fatalf("Function failed to start: %v\n", err)
+	}
+}`
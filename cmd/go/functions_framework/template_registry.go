@@ -0,0 +1,92 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+// mainTextTemplateRegistry is the main of framework versions that register functions in the
+// framework's registry, from which funcframework.Start builds the handler, rather than on
+// http.DefaultServeMux. The framework serves the handler with http.ListenAndServe and exposes neither
+// the handler nor the server, so the generated main cannot stop accepting connections on SIGTERM:
+// with these versions, the process exits on SIGTERM without draining in-flight requests, and
+// FUNCTION_SHUTDOWN_TIMEOUT has no effect.
+const mainTextTemplateRegistry = `// Binary main file implements an HTTP server that loads and runs user's code
+// on incoming HTTP requests.
+// As this file must compile statically alongside the user code, this file
+// will be copied into the function image and the 'FUNCTION_TARGET' and
+// 'FUNCTION_PACKAGE' strings will be replaced by the relevant function and
+// package names. That edited file will then be compiled as with the user's
+// function code to produce an executable app binary that launches the HTTP
+// server.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"net/http"
+
+	userfunction "{{.Package}}"
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/funcframework"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+func register(path string, fn interface{}) error {
+	ctx := context.Background()
+	if fnHTTP, ok := fn.(func (http.ResponseWriter, *http.Request)); ok {
+		if err := funcframework.RegisterHTTPFunctionContext(ctx, path, fnHTTP); err != nil {
+			return fmt.Errorf("Function failed to register: %v\n", err)
+		}
+	} else if fnCloudEvent, ok := fn.(func (context.Context, cloudevents.Event) error); ok {
+		if err := funcframework.RegisterCloudEventFunctionContext(ctx, path, fnCloudEvent); err != nil {
+			return fmt.Errorf("Function failed to register: %v\n", err)
+		}
+	} else {
+		if err := funcframework.RegisterEventFunctionContext(ctx, path, fn); err != nil {
+			return fmt.Errorf("Function failed to register: %v\n", err)
+		}
+	}
+	return nil
+}
+
+func main() {
+{{- range .Handlers}}
+{{- if $.CloudEvent}}
+	if err := funcframework.RegisterCloudEventFunctionContext(context.Background(), {{printf "%q" .Path}}, userfunction.{{.Target}}); err != nil {
+			log.Fatalf("Function failed to register: %v\n", err)
+	}
+{{- else}}
+	if err := register({{printf "%q" .Path}}, userfunction.{{.Target}}); err != nil {
+			log.Fatalf("Function failed to register: %v\n", err)
+	}
+{{- end}}
+{{- end}}
+
+	// The framework only serves the function named by FUNCTION_TARGET, at the root, if it is set;
+	// unset, it serves every function at the path registered above.
+	if target, ok := os.LookupEnv("FUNCTION_TARGET"); ok {
+		log.Printf("Ignoring FUNCTION_TARGET=%q; serving the functions built into this binary", target)
+		os.Unsetenv("FUNCTION_TARGET")
+	}
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	log.Printf("Serving function on port %s", port)
+	if err := funcframework.Start(port); err != nil {
+		log.Fatalf("Function failed to start: %v\n", err)
+	}
+}`
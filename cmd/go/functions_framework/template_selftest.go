@@ -0,0 +1,92 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+// mainTestTextTemplate is the self-test generated alongside the main of the v0 and v1_1 templates. It
+// registers the functions as the generated main does and serves a request to each through the
+// framework's handler, so that a function whose signature the framework no longer accepts fails the
+// build rather than every request after deployment.
+const mainTestTextTemplate = `// Binary main test checks that the user's functions register with the
+// functions framework and serve a basic request.
+package main
+
+import ({{if .CloudEvent}}
+	"context"{{end}}
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	userfunction "{{.Package}}"
+{{- if .CloudEvent}}
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/funcframework"
+{{- end}}
+)
+
+// payload is the body of the requests sent to event functions.
+{{- if .CloudEvent}}
+const payload = ` + "`" + `{"message": {"data": "dGVzdCBtZXNzYWdl", "messageId": "1144231683168617"}, "subscription": "projects/sample-project/subscriptions/gcf-test"}` + "`" + `
+{{- else}}
+const payload = ` + "`" + `{"context": {"eventId": "1144231683168617", "timestamp": "2020-05-06T07:33:34.556Z", "eventType": "google.pubsub.topic.publish", "resource": {"service": "pubsub.googleapis.com", "name": "projects/sample-project/topics/gcf-test", "type": "type.googleapis.com/google.pubsub.v1.PubsubMessage"}}, "data": {"@type": "type.googleapis.com/google.pubsub.v1.PubsubMessage", "data": "dGVzdCBtZXNzYWdl"}}` + "`" + `
+{{- end}}
+
+// selfTestRequest returns the request sent to the function fn at path.
+func selfTestRequest(path string, fn interface{}) *http.Request {
+	if _, ok := fn.(func(http.ResponseWriter, *http.Request)); ok {
+		return httptest.NewRequest(http.MethodGet, path, nil)
+	}
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+{{- if .CloudEvent}}
+	req.Header.Set("ce-specversion", "1.0")
+	req.Header.Set("ce-type", "google.cloud.pubsub.topic.v1.messagePublished")
+	req.Header.Set("ce-source", "//pubsub.googleapis.com/projects/sample-project/topics/gcf-test")
+	req.Header.Set("ce-id", "1144231683168617")
+	req.Header.Set("ce-time", "2020-05-06T07:33:34.556Z")
+{{- end}}
+	return req
+}
+
+// checkServes sends a request to the function fn, which handler serves at path, and fails if the
+// function responds with a server error.
+func checkServes(t *testing.T, handler http.Handler, path, target string, fn interface{}) {
+	req := selfTestRequest(path, fn)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code >= http.StatusInternalServerError {
+		t.Errorf("Function %s responded to %s %s with status %d: %s", target, req.Method, path, rec.Code, rec.Body.String())
+	}
+}
+
+func TestGeneratedMain(t *testing.T) {
+{{- range .Handlers}}
+{{- if $.CloudEvent}}
+	if err := funcframework.RegisterCloudEventFunctionContext(context.Background(), {{printf "%q" .Path}}, userfunction.{{.Target}}); err != nil {
+		t.Fatalf("Function {{.Target}} failed to register: %v", err)
+	}
+{{- else}}
+	if err := register({{printf "%q" .Path}}, userfunction.{{.Target}}); err != nil {
+		t.Fatalf("Function {{.Target}} failed to register: %v", err)
+	}
+{{- end}}
+{{- end}}
+	var handler http.Handler = http.DefaultServeMux{{if .Middleware}}
+	handler = userfunction.FunctionMiddleware(handler){{end}}
+{{- range .Handlers}}
+	checkServes(t, handler, {{printf "%q" .Path}}, {{printf "%q" .Target}}, userfunction.{{.Target}})
+{{- end}}
+}
+`
@@ -0,0 +1,101 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+// serverTemplates defines the "serverImports", "serverFuncs" and "startServer" templates shared by the
+// main templates. With the v0 and v1_1 templates, the generated main serves http.DefaultServeMux,
+// where framework versions before registryVersion register the functions, from its own
+// http.Server, which it shuts down gracefully on SIGTERM: it stops accepting connections and waits
+// for in-flight requests for up to FUNCTION_SHUTDOWN_TIMEOUT, so that instances that are scaled down
+// do not drop requests. The handler is wrapped with the function package's FunctionMiddleware, if
+// any, and served at the path prefix, if any. Main templates must import "context" and the function
+// package as userfunction.
+const serverTemplates = `{{define "serverImports"}}
+	"os/signal"
+	"syscall"
+	"time"{{if .Server.H2C}}
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"{{end}}{{end}}
+
+{{define "serverFuncs"}}
+// durationFromEnv returns the duration in the env var name, or def if it is unset or invalid.
+func durationFromEnv(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		log.Printf("Ignoring %s=%q, which is not a duration such as 10s; using %v", name, v, def)
+		return def
+	}
+	return d
+}{{if .Server.PathPrefix}}
+
+// withPathPrefix serves h at prefix and below, with prefix stripped from the request path, and
+// responds with 404 to requests outside prefix.
+func withPathPrefix(prefix string, h http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(prefix+"/", http.StripPrefix(prefix, h))
+	mux.HandleFunc(prefix, func(w http.ResponseWriter, r *http.Request) {
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = "/"
+		r2.URL.RawPath = ""
+		h.ServeHTTP(w, r2)
+	})
+	return mux
+}{{end}}
+{{end}}
+
+{{define "startServer"}}	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	var handler http.Handler = http.DefaultServeMux{{if .Middleware}}
+	handler = userfunction.FunctionMiddleware(handler){{end}}{{if .Server.PathPrefix}}
+	handler = withPathPrefix({{printf "%q" .Server.PathPrefix}}, handler){{end}}{{if .Server.H2C}}
+	handler = h2c.NewHandler(handler, &http2.Server{}){{end}}
+	server := &http.Server{
+		Addr:         ":" + port,
+		Handler:      handler,
+		ReadTimeout:  durationFromEnv("FUNCTION_READ_TIMEOUT", 0),
+		WriteTimeout: durationFromEnv("FUNCTION_WRITE_TIMEOUT", 0),{{if .Server.ReadHeaderTimeout}}
+		ReadHeaderTimeout: time.Duration({{.Server.ReadHeaderTimeout.Nanoseconds}}), // {{.Server.ReadHeaderTimeout}}{{end}}{{if .Server.MaxHeaderBytes}}
+		MaxHeaderBytes: {{.Server.MaxHeaderBytes}},{{end}}
+	}
+
+	shutdownTimeout := durationFromEnv("FUNCTION_SHUTDOWN_TIMEOUT", 10*time.Second)
+	stopped := make(chan struct{})
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGTERM, os.Interrupt)
+		sig := <-sigs
+		log.Printf("Received %v, draining in-flight requests for up to %v", sig, shutdownTimeout)
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("Function server did not shut down gracefully: %v", err)
+		}
+		close(stopped)
+	}()
+
+	log.Printf("Serving function on port %s{{if .Server.PathPrefix}} at {{.Server.PathPrefix}}{{end}}", port)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatalf("Function failed to start: %v\n", err)
+	}
+	<-stopped
+	log.Printf("Function server shut down")
+{{- end}}`
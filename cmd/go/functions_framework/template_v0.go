@@ -25,39 +25,35 @@ const mainTextTemplateV0 = `// Binary main file implements an HTTP server that l
 package main
 
 import (
+	"context"
 	"log"
 	"os"
-	"net/http"
+	"net/http"{{template "serverImports" .}}
 
 	userfunction "{{.Package}}"
 
 	"github.com/GoogleCloudPlatform/functions-framework-go/funcframework"
 )
 
-func register(fn interface{}) error {
+func register(path string, fn interface{}) error {
 	if fnHTTP, ok := fn.(func (http.ResponseWriter, *http.Request)); ok {
-		funcframework.RegisterHTTPFunction("/", fnHTTP)
+		funcframework.RegisterHTTPFunction(path, fnHTTP)
 	} else {
-		funcframework.RegisterEventFunction("/", fn)
+		funcframework.RegisterEventFunction(path, fn)
 	}
 	return nil
 }
-
-
+{{template "serverFuncs" .}}
 func main() {
-	if err := register(userfunction.{{.Target}}); err != nil {
+{{- range .Handlers}}
+	if err := register({{printf "%q" .Path}}, userfunction.{{.Target}}); err != nil {
 			log.Fatalf("Function failed to register: %v\n", err)
 	}
+{{- end}}
 
 	// Don't invoke the function for reserved URLs.
 	http.HandleFunc("/robots.txt", http.NotFound)
 	http.HandleFunc("/favicon.ico", http.NotFound)
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-	if err := funcframework.Start(port); err != nil {
-		log.Fatalf("Function failed to start: %v\n", err)
-	}
+{{template "startServer" .}}
 }`
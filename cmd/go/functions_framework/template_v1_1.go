@@ -29,7 +29,7 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"net/http"
+	"net/http"{{template "serverImports" .}}
 
 	userfunction "{{.Package}}"
 
@@ -37,38 +37,40 @@ import (
 	cloudevents "github.com/cloudevents/sdk-go/v2"
 )
 
-func register(fn interface{}) error {
+func register(path string, fn interface{}) error {
 	ctx := context.Background()
 	if fnHTTP, ok := fn.(func (http.ResponseWriter, *http.Request)); ok {
-		if err := funcframework.RegisterHTTPFunctionContext(ctx, "/", fnHTTP); err != nil {
+		if err := funcframework.RegisterHTTPFunctionContext(ctx, path, fnHTTP); err != nil {
 			return fmt.Errorf("Function failed to register: %v\n", err)
 		}
 	} else if fnCloudEvent, ok := fn.(func (context.Context, cloudevents.Event) error); ok {
-		if err := funcframework.RegisterCloudEventFunctionContext(ctx, "/", fnCloudEvent); err != nil {
+		if err := funcframework.RegisterCloudEventFunctionContext(ctx, path, fnCloudEvent); err != nil {
 			return fmt.Errorf("Function failed to register: %v\n", err)
 		}
 	} else {
-		if err := funcframework.RegisterEventFunctionContext(ctx, "/", fn); err != nil {
+		if err := funcframework.RegisterEventFunctionContext(ctx, path, fn); err != nil {
 			return fmt.Errorf("Function failed to register: %v\n", err)
 		}
 	}
 	return nil
 }
-
+{{template "serverFuncs" .}}
 func main() {
-	if err := register(userfunction.{{.Target}}); err != nil {
+{{- range .Handlers}}
+{{- if $.CloudEvent}}
+	if err := funcframework.RegisterCloudEventFunctionContext(context.Background(), {{printf "%q" .Path}}, userfunction.{{.Target}}); err != nil {
+			log.Fatalf("Function failed to register: %v\n", err)
+	}
+{{- else}}
+	if err := register({{printf "%q" .Path}}, userfunction.{{.Target}}); err != nil {
 			log.Fatalf("Function failed to register: %v\n", err)
 	}
+{{- end}}
+{{- end}}
 
 	// Don't invoke the function for reserved URLs.
 	http.HandleFunc("/robots.txt", http.NotFound)
 	http.HandleFunc("/favicon.ico", http.NotFound)
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-	if err := funcframework.Start(port); err != nil {
-		log.Fatalf("Function failed to start: %v\n", err)
-	}
+{{template "startServer" .}}
 }`
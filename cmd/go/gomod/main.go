@@ -17,12 +17,21 @@
 package main
 
 import (
+	"crypto/sha256"
+	"fmt"
 	"os"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/golang"
 )
 
+const (
+	// gopathLayer holds the module cache, which is kept while go.sum does not change.
+	gopathLayer  = "gopath"
+	goSumHashKey = "go_sum_sha256"
+)
+
 func main() {
 	gcp.Main(detectFn, buildFn)
 }
@@ -35,15 +44,13 @@ func detectFn(ctx *gcp.Context) error {
 }
 
 func buildFn(ctx *gcp.Context) error {
-	l := ctx.Layer("gopath", gcp.BuildLayer, gcp.LaunchLayerIfDevMode)
+	l := ctx.Layer(gopathLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayerIfDevMode)
 	l.BuildEnvironment.Override("GOPATH", l.Path)
 	l.BuildEnvironment.Override("GO111MODULE", "on")
 	// Set GOPROXY to ensure no additional dependency is downloaded at built time.
 	// All of them are downloaded here.
 	l.BuildEnvironment.Override("GOPROXY", "off")
 
-	// TODO(b/145604612): Investigate caching the modules layer.
-
 	// When there's a vendor folder and go is 1.14+, we shouldn't download the modules
 	// and let go build use the vendored dependencies.
 	if ctx.FileExists("vendor") {
@@ -58,20 +65,61 @@ func buildFn(ctx *gcp.Context) error {
 	if info, err := os.Stat("go.mod"); err == nil && info.Mode().Perm()&0200 == 0 {
 		return gcp.UserErrorf("go.mod exists but is not writable")
 	}
+	if err := golang.CheckSumDB(ctx, ctx.ApplicationRoot()); err != nil {
+		return err
+	}
+	strict, err := env.Bool(env.GoSumDBStrict, false)
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	// The credentials are removed before the layers are exported.
+	defer golang.UsePrivateModuleCredentials(ctx)()
+
+	sumDBHelp := gcp.WithMessageProducer(golang.KeepStderrTailWithSumDBHelp)
+	purge := gcp.WithCorruptCacheRetry(func() error { return golang.PurgeModCache(ctx, l.Path) })
 	env := []string{"GOPATH=" + l.Path, "GO111MODULE=on"}
-	if golang.VersionMatches(ctx, ">=1.15.0") {
+	go115 := golang.VersionMatches(ctx, ">=1.15.0")
+	// Respect proxies configured by the user, such as a private module proxy.
+	if go115 && os.Getenv("GOPROXY") == "" {
 		env = append(env, "GOPROXY=https://proxy.golang.org|direct")
-		ctx.Exec([]string{"go", "mod", "download"}, gcp.WithEnv(env...), gcp.WithUserAttribution)
+	}
+	hash := goSumHash(ctx)
+	if metaHash := ctx.GetMetadata(l, goSumHashKey); hash == metaHash {
+		// The module cache holds every module of go.sum, so none are downloaded.
+		ctx.CacheHit(gopathLayer)
 	} else {
-		_, err := ctx.ExecWithErr([]string{"go", "mod", "download"}, gcp.WithEnv(env...), gcp.WithUserAttribution)
-		if err != nil {
-			ctx.Warnf("go mod download failed. Retrying with GOSUMDB=off GOPROXY=direct. Error: %v", err)
-			ctx.Exec([]string{"go", "mod", "download"}, gcp.WithEnv(append(env, "GOSUMDB=off", "GOPROXY=direct")...), gcp.WithUserAttribution)
+		if metaHash != "" {
+			// The modules that are still required are kept, so only new ones are downloaded.
+			ctx.ExplainCacheMiss(gopathLayer, "go.sum changed")
+		}
+		ctx.CacheMiss(gopathLayer)
+		if go115 {
+			ctx.Exec([]string{"go", "mod", "download"}, gcp.WithEnv(env...), gcp.WithTransientRetry, purge, sumDBHelp, gcp.WithUserAttribution)
+		} else if strict {
+			// The fallback below bypasses the checksum database.
+			ctx.Exec([]string{"go", "mod", "download"}, gcp.WithEnv(env...), gcp.WithTransientRetry, purge, sumDBHelp, gcp.WithUserAttribution)
+		} else {
+			_, err := ctx.ExecWithErr([]string{"go", "mod", "download"}, gcp.WithEnv(env...), gcp.WithTransientRetry, purge, gcp.WithUserAttribution)
+			if err != nil {
+				ctx.Warnf("go mod download failed. Retrying with GOSUMDB=off GOPROXY=direct, which bypasses the checksum database. Error: %v", err)
+				ctx.Exec([]string{"go", "mod", "download"}, gcp.WithEnv(append(env, "GOSUMDB=off", "GOPROXY=direct")...), gcp.WithTransientRetry, purge, gcp.WithUserAttribution)
+			}
 		}
+		ctx.SetMetadata(l, goSumHashKey, hash)
 	}
 
 	// go build -mod=readonly requires a complete graph of modules which `go mod download` does not produce in all cases (https://golang.org/issue/35832).
-	ctx.Exec([]string{"go", "mod", "tidy"}, gcp.WithEnv(env...), gcp.WithUserAttribution)
+	ctx.Exec([]string{"go", "mod", "tidy"}, gcp.WithEnv(env...), purge, sumDBHelp, gcp.WithUserAttribution)
 
 	return nil
 }
+
+// goSumHash returns the SHA-256 hash of go.sum, which lists every module that is downloaded, or of
+// an empty file if there is none.
+func goSumHash(ctx *gcp.Context) string {
+	var content []byte
+	if ctx.FileExists("go.sum") {
+		content = ctx.ReadFile("go.sum")
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(content))
+}
@@ -15,9 +15,12 @@
 package main
 
 import (
+	"io/ioutil"
+	"os"
 	"testing"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
 )
 
 func TestDetect(t *testing.T) {
@@ -45,3 +48,34 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestGoSumHash(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomod")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	ctx := gcp.NewContextForTests(libcnb.BuildpackInfo{}, dir)
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getting working directory: %v", err)
+	}
+	defer os.Chdir(cwd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("changing to %s: %v", dir, err)
+	}
+
+	missing := goSumHash(ctx)
+	if err := ioutil.WriteFile("go.sum", []byte("golang.org/x/text v0.3.0 h1:abc=\n"), 0644); err != nil {
+		t.Fatalf("writing go.sum: %v", err)
+	}
+	first := goSumHash(ctx)
+	if err := ioutil.WriteFile("go.sum", []byte("golang.org/x/text v0.3.2 h1:def=\n"), 0644); err != nil {
+		t.Fatalf("writing go.sum: %v", err)
+	}
+	second := goSumHash(ctx)
+
+	if missing == first || first == second {
+		t.Errorf("goSumHash() = %q without go.sum, %q and %q for different go.sum files, want different hashes", missing, first, second)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements the exec.d executable that sets GOMEMLIMIT and GOMAXPROCS from the memory and CPU limits
+// of the container, unless they are already set.
+package main
+
+import (
+	"log"
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+
+	"github.com/BurntSushi/toml"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cgroup"
+)
+
+const (
+	// envFD is the file descriptor on which the launcher reads env vars to set, as TOML.
+	envFD = 3
+
+	// memoryLimitRatio is the share of the memory limit set as GOMEMLIMIT, which leaves headroom for
+	// memory that the Go runtime does not manage, such as cgo allocations and the binary itself.
+	memoryLimitRatio = 0.9
+)
+
+func main() {
+	// Failing to read the limits leaves the Go defaults in place rather than preventing the app from
+	// starting.
+	limits, err := cgroup.Read(cgroup.Root)
+	if err != nil {
+		log.Printf("Not setting GOMEMLIMIT and GOMAXPROCS from the container limits: %v", err)
+		return
+	}
+	vars := envVars(limits, runtime.NumCPU(), os.LookupEnv)
+	if len(vars) == 0 {
+		return
+	}
+	if err := toml.NewEncoder(os.NewFile(envFD, "env")).Encode(vars); err != nil {
+		log.Printf("Writing GOMEMLIMIT and GOMAXPROCS: %v", err)
+	}
+}
+
+// envVars returns GOMEMLIMIT and GOMAXPROCS for the limits of a container on a machine with numCPU
+// CPUs, skipping the vars that are already set and those whose Go default is already right.
+func envVars(limits cgroup.Limits, numCPU int, lookupEnv func(string) (string, bool)) map[string]string {
+	vars := map[string]string{}
+	if _, ok := lookupEnv("GOMEMLIMIT"); !ok && limits.MemoryBytes > 0 {
+		vars["GOMEMLIMIT"] = strconv.FormatInt(int64(float64(limits.MemoryBytes)*memoryLimitRatio), 10)
+	}
+	if _, ok := lookupEnv("GOMAXPROCS"); !ok && limits.CPUs > 0 {
+		// Fractional quotas round up so that the app can use all of its quota.
+		if procs := int(math.Ceil(limits.CPUs)); procs < numCPU {
+			vars["GOMAXPROCS"] = strconv.Itoa(procs)
+		}
+	}
+	return vars
+}
@@ -0,0 +1,71 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cgroup"
+)
+
+func TestEnvVars(t *testing.T) {
+	testCases := []struct {
+		name   string
+		limits cgroup.Limits
+		env    map[string]string
+		want   map[string]string
+	}{
+		{
+			name:   "limited",
+			limits: cgroup.Limits{MemoryBytes: 512 * 1024 * 1024, CPUs: 2},
+			want:   map[string]string{"GOMEMLIMIT": "483183820", "GOMAXPROCS": "2"},
+		},
+		{
+			name:   "fractional CPU",
+			limits: cgroup.Limits{CPUs: 0.5},
+			want:   map[string]string{"GOMAXPROCS": "1"},
+		},
+		{
+			name:   "CPU limit above machine",
+			limits: cgroup.Limits{CPUs: 16},
+			want:   map[string]string{},
+		},
+		{
+			name: "unlimited",
+			want: map[string]string{},
+		},
+		{
+			name:   "already set",
+			limits: cgroup.Limits{MemoryBytes: 512 * 1024 * 1024, CPUs: 2},
+			env:    map[string]string{"GOMEMLIMIT": "100MiB", "GOMAXPROCS": "4"},
+			want:   map[string]string{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lookupEnv := func(name string) (string, bool) {
+				v, ok := tc.env[name]
+				return v, ok
+			}
+
+			got := envVars(tc.limits, 8, lookupEnv)
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("envVars(%+v) = %v, want %v", tc.limits, got, tc.want)
+			}
+		})
+	}
+}
@@ -20,13 +20,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/golang"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/warmcache"
 )
 
 const (
@@ -35,6 +36,11 @@ const (
 	goURL        = "https://dl.google.com/go/go%s.linux-amd64.tar.gz"
 	goLayer      = "go"
 	versionKey   = "version"
+	limitsLayer  = "container-limits"
+	// limitsExecD sets GOMEMLIMIT and GOMAXPROCS from the limits of the container at launch.
+	limitsExecD = "go-limits"
+	// helloWorld is the program run to check that the installed Go works.
+	helloWorld = "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println(\"hello world\") }\n"
 )
 
 func main() {
@@ -51,17 +57,36 @@ func detectFn(ctx *gcp.Context) error {
 }
 
 func buildFn(ctx *gcp.Context) error {
-	version, err := runtimeVersion(ctx)
+	channel, err := runtime.Channel()
+	if err != nil {
+		return err
+	}
+	policy, err := runtime.PatchPolicy()
+	if err != nil {
+		return err
+	}
+	version, request, err := runtimeVersion(ctx, channel)
 	if err != nil {
 		return err
 	}
 	grl := ctx.Layer(goLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayerIfDevMode)
+	version = runtime.ApplyPatchPolicy(ctx, grl, "Go", policy, request, version)
+	if channel != runtime.ChannelStable {
+		runtime.UsePrerelease(ctx, "Go", channel, version)
+	}
+	if err := ctx.RecordFact(gcp.FactRuntimeVersion, version); err != nil {
+		return err
+	}
+	ctx.AddTool(grl, "go", version)
 
 	// Check metadata layer to see if correct version of Go is already installed.
 	metaVersion := ctx.GetMetadata(grl, versionKey)
 	if version == metaVersion {
 		ctx.CacheHit(goLayer)
 	} else {
+		if metaVersion != "" {
+			ctx.ExplainCacheMiss(goLayer, "Go version changed from %s to %s", metaVersion, version)
+		}
 		ctx.CacheMiss(goLayer)
 		ctx.ClearLayer(grl)
 
@@ -72,28 +97,128 @@ func buildFn(ctx *gcp.Context) error {
 
 		// Download and install Go in layer.
 		ctx.Logf("Installing Go v%s", version)
-		command := fmt.Sprintf("curl --fail --show-error --silent --location --retry 3 %s | tar xz --directory %s --strip-components=1", archiveURL, grl.Path)
+		limitArgs, release := ctx.DownloadSlot(archiveURL)
+		command := fmt.Sprintf("%s | tar xz --directory %s --strip-components=1", warmcache.DownloadCommand(archiveURL, limitArgs...), grl.Path)
 		ctx.Exec([]string{"bash", "-c", command}, gcp.WithUserAttribution)
+		release()
+		if err := smokeTest(ctx, grl.Path); err != nil {
+			return err
+		}
 		ctx.SetMetadata(grl, versionKey, version)
 	}
 
-	return nil
+	limits, err := env.Bool(env.GoContainerLimits, true)
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	if limits {
+		// The limits are read when the container starts, as they are only known to the platform.
+		l := ctx.Layer(limitsLayer, gcp.LaunchLayer)
+		if err := ctx.AddExecD(l, filepath.Join(ctx.BuildpackRoot(), "bin", limitsExecD)); err != nil {
+			return err
+		}
+	}
+
+	return golang.ConfigureToolchainFlags(ctx, grl.Path, version)
+}
+
+// smokeTest compiles and runs a hello world program with the Go installed in goRoot. The flags of the
+// build are not used, as they are validated later.
+func smokeTest(ctx *gcp.Context, goRoot string) error {
+	dir := ctx.TempDir("", "smoketest")
+	defer ctx.RemoveAll(dir)
+	hello := filepath.Join(dir, "hello.go")
+	ctx.WriteFile(hello, []byte(helloWorld), 0644)
+	return runtime.SmokeTest(ctx, "Go", []string{"env", "GOFLAGS=", "GOCACHE=" + filepath.Join(dir, "cache"), filepath.Join(goRoot, "bin", "go"), "run", hello})
 }
 
-func runtimeVersion(ctx *gcp.Context) (string, error) {
-	if version := os.Getenv(env.RuntimeVersion); version != "" {
-		ctx.Logf("Using runtime version from %s: %s", env.RuntimeVersion, version)
-		return version, nil
+// runtimeVersion returns the version of Go to install, and the constraint or "latest" from which it was
+// resolved, or "" if it was requested exactly. Versions from env vars may be constraints,
+// resolved to the newest matching release, as are the versions allowed by go.mod. Versions from
+// channels other than runtime.ChannelStable take precedence over go.mod.
+func runtimeVersion(ctx *gcp.Context, channel string) (string, string, error) {
+	cv, err := ctx.ResolveConfig("Go version",
+		gcp.EnvConfig(env.RuntimeVersion),
+		gcp.EnvConfig(env.GoVersion),
+		runtime.ChannelConfig(channel),
+		gcp.ConfigSource{Name: "go.mod", Value: goModVersion},
+	)
+	if err != nil {
+		return "", "", err
 	}
-	if version := golang.GoModVersion(ctx); version != "" {
+	switch cv.Source {
+	case env.RuntimeVersion, env.GoVersion:
+		version, err := resolveVersion(ctx, cv.Value)
+		if err != nil {
+			return "", "", gcp.UserErrorf("resolving %s=%q: %v", cv.Source, cv.Value, err)
+		}
+		ctx.Logf("Using runtime version from %s: %s", cv.Source, version)
+		return version, constraintRequest(cv.Value), nil
+	case env.RuntimeChannel:
+		if channel == runtime.ChannelNightly {
+			// Go publishes no nightly archives, only release candidates and betas.
+			return "", "", gcp.UserErrorf("%s=%s is not supported for Go, use %s", env.RuntimeChannel, channel, runtime.ChannelBeta)
+		}
+		version, err := latestGoVersion(ctx, true)
+		if err != nil {
+			return "", "", fmt.Errorf("getting latest pre-release version: %w", err)
+		}
+		ctx.Logf("Using latest pre-release runtime version: %s", version)
+		return version, "", nil
+	case "go.mod":
+		version, err := resolveVersion(ctx, cv.Value)
+		if err != nil {
+			// The first release that builds the module exists, but the list of releases may be unavailable.
+			version = golang.RequiredVersion(ctx, ctx.ApplicationRoot())
+			ctx.Warnf("Resolving the newest Go release for go.mod (%s): %v; using %s", cv.Value, err, version)
+		}
 		ctx.Logf("Using runtime version from go.mod: %s", version)
-		return version, nil
+		return version, constraintRequest(cv.Value), nil
 	}
-	version, err := latestGoVersion(ctx)
+	version, err := latestGoVersion(ctx, false)
 	if err != nil {
-		return "", fmt.Errorf("getting latest version: %w", err)
+		return "", "", fmt.Errorf("getting latest version: %w", err)
 	}
 	ctx.Logf("Using latest runtime version: %s", version)
+	return version, "latest", nil
+}
+
+// constraintRequest returns v if it is a version constraint, or "" if it is an exact version.
+func constraintRequest(v string) string {
+	if golang.IsConstraint(v) {
+		return v
+	}
+	return ""
+}
+
+// goModVersion returns the versions allowed by the go.mod of the application.
+func goModVersion(ctx *gcp.Context) (string, bool, error) {
+	v := golang.GoModConstraint(ctx, ctx.ApplicationRoot())
+	return v, v != "", nil
+}
+
+// resolveVersion returns v if it is an exact version, or else the newest stable release that
+// satisfies the constraint v.
+func resolveVersion(ctx *gcp.Context, v string) (string, error) {
+	if !golang.IsConstraint(v) {
+		return v, nil
+	}
+	c, err := golang.ParseConstraint(v)
+	if err != nil {
+		return "", err
+	}
+	body, err := ctx.FetchMetadata(goVersionURL + "&include=all")
+	if err != nil {
+		return "", err
+	}
+	versions, err := parseVersionsJSON(string(body))
+	if err != nil {
+		return "", err
+	}
+	version, ok := golang.BestMatch(c, versions)
+	if !ok {
+		return "", fmt.Errorf("no Go release satisfies %s", c)
+	}
 	return version, nil
 }
 
@@ -102,20 +227,44 @@ type goReleases []struct {
 	Stable  bool   `json:"stable"`
 }
 
-// latestGoVersion returns the latest version of Go
-func latestGoVersion(ctx *gcp.Context) (string, error) {
-	result := ctx.Exec([]string{"curl", "--fail", "--show-error", "--silent", "--location", goVersionURL}, gcp.WithUserAttribution)
-	return parseVersionJSON(result.Stdout)
+// latestGoVersion returns the latest stable version of Go, or the latest version including
+// pre-releases if prerelease is true.
+func latestGoVersion(ctx *gcp.Context, prerelease bool) (string, error) {
+	url := goVersionURL
+	if prerelease {
+		// By default, the list only includes the supported releases and the current pre-release.
+		url += "&include=all"
+	}
+	body, err := ctx.FetchMetadata(url)
+	if err != nil {
+		return "", err
+	}
+	return parseVersionJSON(string(body), prerelease)
+}
+
+// parseVersionsJSON returns every version in the list.
+func parseVersionsJSON(jsonStr string) ([]string, error) {
+	releases := goReleases{}
+	if err := json.Unmarshal([]byte(jsonStr), &releases); err != nil {
+		return nil, fmt.Errorf("parsing JSON response from URL %q: %v", goVersionURL, err)
+	}
+	var versions []string
+	for _, release := range releases {
+		versions = append(versions, strings.TrimPrefix(release.Version, "go"))
+	}
+	return versions, nil
 }
 
-func parseVersionJSON(jsonStr string) (string, error) {
+// parseVersionJSON returns the first version in the list, skipping unstable versions unless
+// prerelease is true. The list is ordered from the newest version.
+func parseVersionJSON(jsonStr string, prerelease bool) (string, error) {
 	releases := goReleases{}
 	if err := json.Unmarshal([]byte(jsonStr), &releases); err != nil {
 		return "", fmt.Errorf("parsing JSON response from URL %q: %v", goVersionURL, err)
 	}
 
 	for _, release := range releases {
-		if !release.Stable {
+		if !release.Stable && !prerelease {
 			continue
 		}
 		if v := strings.TrimPrefix(release.Version, "go"); v != "" {
@@ -15,6 +15,7 @@
 package main
 
 import (
+	"reflect"
 	"testing"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
@@ -87,9 +88,10 @@ func TestDetect(t *testing.T) {
 
 func TestJSONVersionParse(t *testing.T) {
 	testCases := []struct {
-		name string
-		want string
-		json string
+		name       string
+		want       string
+		json       string
+		prerelease bool
 	}{
 		{
 			name: "all_stable",
@@ -119,17 +121,44 @@ func TestJSONVersionParse(t *testing.T) {
   "version": "go1.12.12",
   "stable": true
  }
+]`,
+		},
+		{
+			name:       "prerelease",
+			want:       "1.14rc1",
+			prerelease: true,
+			json: `
+[
+ {
+  "version": "go1.14rc1",
+  "stable": false
+ },
+ {
+  "version": "go1.13.3",
+  "stable": true
+ }
 ]`,
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			if v, err := parseVersionJSON(tc.json); err != nil {
-				t.Fatalf("parseVersionJSON() failed: %v", tc.name, err)
+			if v, err := parseVersionJSON(tc.json, tc.prerelease); err != nil {
+				t.Fatalf("parseVersionJSON() failed: %v", err)
 			} else if v != tc.want {
-				t.Errorf("parseVersionJSON() = %q, want %q", tc.name, v, tc.want)
+				t.Errorf("parseVersionJSON() = %q, want %q", v, tc.want)
 			}
 		})
 	}
 }
+
+func TestParseVersionsJSON(t *testing.T) {
+	json := `[{"version": "go1.22rc1", "stable": false}, {"version": "go1.21.6", "stable": true}]`
+	got, err := parseVersionsJSON(json)
+	if err != nil {
+		t.Fatalf("parseVersionsJSON() failed: %v", err)
+	}
+	if want := []string{"1.22rc1", "1.21.6"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseVersionsJSON() = %q, want %q", got, want)
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"fmt"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/frameworks"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/java"
 )
@@ -40,6 +41,14 @@ func buildFn(ctx *gcp.Context) error {
 
 	command := []string{"java", "-jar", executable}
 
+	fw, err := frameworks.Detect(ctx, "java")
+	if err != nil {
+		return err
+	}
+	if fw != nil {
+		fw.ConfigureLaunch(ctx, ctx.Layer("framework", gcp.LaunchLayer))
+	}
+
 	// Configure the entrypoint and metadata for dev mode.
 	if devmode.Enabled(ctx) {
 		devmode.AddSyncMetadata(ctx, devmode.JavaSyncRules)
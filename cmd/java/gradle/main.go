@@ -91,6 +91,9 @@ func buildFn(ctx *gcp.Context) error {
 	}
 
 	ctx.Exec(command, gcp.WithUserAttribution)
+	if err := java.ExportJar(ctx); err != nil {
+		return err
+	}
 
 	// Store the build steps in a script to be run on each file change.
 	if devmode.Enabled(ctx) {
@@ -108,6 +111,7 @@ func gradleInstalled(ctx *gcp.Context) bool {
 // installGradle installs Gradle and returns the path of the gradle binary
 func installGradle(ctx *gcp.Context) (string, error) {
 	gradlel := ctx.Layer(gradleLayer, gcp.CacheLayer, gcp.BuildLayer, gcp.LaunchLayerIfDevMode)
+	ctx.AddTool(gradlel, "gradle", gradleVersion)
 
 	metaVersion := ctx.GetMetadata(gradlel, versionKey)
 	// Check the metadata in the cache layer to determine if we need to proceed.
@@ -25,6 +25,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/depaudit"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
@@ -96,6 +97,12 @@ func buildFn(ctx *gcp.Context) error {
 	}
 
 	ctx.Exec(command, gcp.WithStdoutTail, gcp.WithUserAttribution)
+	if err := java.ExportJar(ctx); err != nil {
+		return err
+	}
+	if err := depaudit.RecordMaven(ctx, mvn); err != nil {
+		return err
+	}
 
 	// Store the build steps in a script to be run on each file change.
 	if devmode.Enabled(ctx) {
@@ -137,6 +144,7 @@ func mvnInstalled(ctx *gcp.Context) bool {
 // installMaven installs Maven and returns the path of the mvn binary
 func installMaven(ctx *gcp.Context) (string, error) {
 	mvnl := ctx.Layer(mavenLayer, gcp.CacheLayer, gcp.BuildLayer, gcp.LaunchLayerIfDevMode)
+	ctx.AddTool(mvnl, "maven", mavenVersion)
 
 	// Check the metadata in the cache layer to determine if we need to proceed.
 	metaVersion := ctx.GetMetadata(mvnl, versionKey)
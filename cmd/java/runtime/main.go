@@ -13,26 +13,36 @@
 // limitations under the License.
 
 // Implements java/runtime buildpack.
-// The runtime buildpack installs the JDK.
+// The runtime buildpack installs the JDK, or a separate JDK and Java runtime when the build
+// compiles with a newer JDK than the Java release it targets.
 package main
 
 import (
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/java"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
 	"github.com/buildpacks/libcnb"
 )
 
 const (
-	javaLayer             = "java"
-	javaVersionURL        = "https://api.adoptopenjdk.net/v3/assets/feature_releases/%s/ga?architecture=x64&heap_size=normal&image_type=jdk&jvm_impl=hotspot&os=linux&page=0&page_size=1&project=jdk&sort_order=DESC&vendor=adoptopenjdk"
+	javaLayer = "java"
+	// jdkLayer holds the JDK that compiles the application when it differs from the Java runtime.
+	jdkLayer              = "jdk"
+	javaVersionURL        = "https://api.adoptopenjdk.net/v3/assets/feature_releases/%s/ga?architecture=x64&heap_size=normal&image_type=%s&jvm_impl=hotspot&os=linux&page=0&page_size=1&project=jdk&sort_order=DESC&vendor=adoptopenjdk"
 	defaultFeatureVersion = "11"
 	versionKey            = "version"
+	imageTypeKey          = "image_type"
+
+	imageJDK = "jdk"
+	imageJRE = "jre"
 )
 
 func main() {
@@ -55,51 +65,130 @@ func detectFn(ctx *gcp.Context) error {
 }
 
 func buildFn(ctx *gcp.Context) error {
-	featureVersion := defaultFeatureVersion
-	if v := os.Getenv(env.RuntimeVersion); v != "" {
-		featureVersion = v
-		ctx.Logf("Using requested runtime feature version: %s", featureVersion)
-	} else {
+	tc, err := java.ReadToolchain(ctx.ApplicationRoot())
+	if err != nil {
+		return err
+	}
+	requested := os.Getenv(env.RuntimeVersion)
+	switch {
+	case requested != "":
+		ctx.Logf("Using requested runtime feature version: %s", requested)
+	case tc.Release != "":
+		ctx.Logf("Using runtime feature version %s, the Java release that the build targets", tc.Release)
+	default:
 		ctx.Logf("Using latest Java %s runtime version. You can specify a different version with %s: https://github.com/GoogleCloudPlatform/buildpacks#configuration", defaultFeatureVersion, env.RuntimeVersion)
 	}
+	runVersion, compileVersion, err := featureVersions(requested, tc)
+	if err != nil {
+		return err
+	}
 
-	releaseURL := fmt.Sprintf(javaVersionURL, featureVersion)
-	if code := ctx.HTTPStatus(releaseURL); code != http.StatusOK {
-		return gcp.UserErrorf("Java feature version %s does not exist at %s (status %d). You can specify the feature version with %s. See available feature runtime versions at https://api.adoptopenjdk.net/v3/info/available_releases", featureVersion, releaseURL, code, env.RuntimeVersion)
+	if compileVersion == runVersion {
+		l := ctx.Layer(javaLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayer)
+		return installJava(ctx, l, runVersion, imageJDK, true)
 	}
 
-	result := ctx.Exec([]string{"curl", "--fail", "--show-error", "--silent", "--location", releaseURL}, gcp.WithUserAttribution)
-	release, err := parseVersionJSON(result.Stdout)
+	// The application is compiled with a different JDK than the one it runs with, so the JDK is only
+	// available during the build and the image only contains the runtime.
+	ctx.Logf("Compiling with JDK %s and running with Java %s", compileVersion, runVersion)
+	jdk := ctx.Layer(jdkLayer, gcp.BuildLayer, gcp.CacheLayer)
+	jdk.BuildEnvironment.Override("JAVA_HOME", jdk.Path)
+	if err := installJava(ctx, jdk, compileVersion, imageJDK, false); err != nil {
+		return err
+	}
+	jre := ctx.Layer(javaLayer, gcp.CacheLayer, gcp.LaunchLayer)
+	return installJava(ctx, jre, runVersion, imageJRE, true)
+}
+
+// featureVersions returns the Java feature versions that the application runs and compiles with,
+// given the requested runtime version and the toolchain that the build requires.
+func featureVersions(requested string, tc java.Toolchain) (string, string, error) {
+	run := defaultFeatureVersion
+	if requested != "" {
+		run = requested
+	} else if tc.Release != "" {
+		run = tc.Release
+	}
+	compile := tc.Compiler
+	if compile == "" {
+		compile = run
+	}
+	if tc.Release != "" {
+		if olderThan(run, tc.Release) {
+			return "", "", gcp.UserErrorf("Java %s cannot run classes compiled for Java release %s; set %s to %s or later, or lower the release in the build", run, tc.Release, env.RuntimeVersion, tc.Release)
+		}
+		if olderThan(compile, tc.Release) {
+			return "", "", gcp.UserErrorf("the build compiles with JDK %s, which cannot compile for Java release %s", compile, tc.Release)
+		}
+	}
+	return run, compile, nil
+}
+
+// olderThan returns true if feature version a is older than b. Versions that cannot be compared are not older.
+func olderThan(a, b string) bool {
+	av, aerr := strconv.Atoi(java.FeatureVersion(a))
+	bv, berr := strconv.Atoi(java.FeatureVersion(b))
+	return aerr == nil && berr == nil && av < bv
+}
+
+// installJava installs the latest release of the given feature version and image type, jdk or jre,
+// in layer l, unless it is already installed. isRuntime records the version as the runtime version.
+func installJava(ctx *gcp.Context, l *libcnb.Layer, featureVersion, imageType string, isRuntime bool) error {
+	releaseURL := fmt.Sprintf(javaVersionURL, featureVersion, imageType)
+	body, err := ctx.FetchMetadata(releaseURL)
+	if err != nil {
+		return gcp.UserErrorf("Java feature version %s is not available: %v. You can specify the feature version with %s. See available feature runtime versions at https://api.adoptopenjdk.net/v3/info/available_releases", featureVersion, err, env.RuntimeVersion)
+	}
+	release, err := parseVersionJSON(string(body))
 	if err != nil {
 		return fmt.Errorf("parsing JSON returned by %s: %w", releaseURL, err)
 	}
 
-	version, archiveURL, err := extractRelease(release)
+	version, archiveURL, err := extractRelease(release, imageType)
 	if err != nil {
 		return fmt.Errorf("extracting release returned by %s: %w", releaseURL, err)
 	}
+	if isRuntime {
+		if err := ctx.RecordFact(gcp.FactRuntimeVersion, version); err != nil {
+			return err
+		}
+	}
+	ctx.AddTool(l, "java-"+imageType, version)
 
-	// Check the metadata in the cache layer to determine if we need to proceed.
-	l := ctx.Layer(javaLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayer)
+	// Check the metadata in the cache layer to determine if we need to proceed. Layers cached before
+	// the image type was recorded hold a JDK.
 	metaVersion := ctx.GetMetadata(l, versionKey)
-	if version == metaVersion {
-		ctx.CacheHit(javaLayer)
+	metaImageType := ctx.GetMetadata(l, imageTypeKey)
+	if metaImageType == "" {
+		metaImageType = imageJDK
+	}
+	if version == metaVersion && imageType == metaImageType {
+		ctx.CacheHit(l.Name)
 		return nil
 	}
-	ctx.CacheMiss(javaLayer)
+	if metaVersion != "" {
+		ctx.ExplainCacheMiss(l.Name, "Java changed from %s %s to %s %s", strings.ToUpper(metaImageType), metaVersion, strings.ToUpper(imageType), version)
+	}
+	ctx.CacheMiss(l.Name)
 	ctx.ClearLayer(l)
 
 	// Download and install Java in layer.
-	ctx.Logf("Installing Java v%s", version)
+	ctx.Logf("Installing Java %s v%s", strings.ToUpper(imageType), version)
 
 	command := fmt.Sprintf("curl --fail --show-error --silent --location --retry 3 %s | tar xz --directory %s --strip-components=1", archiveURL, l.Path)
 	ctx.Exec([]string{"bash", "-c", command}, gcp.WithUserAttribution)
+	if err := runtime.SmokeTest(ctx, "Java", []string{filepath.Join(l.Path, "bin", "java"), "-version"}); err != nil {
+		return err
+	}
 
 	ctx.SetMetadata(l, versionKey, version)
-	ctx.AddBuildpackPlanEntry(libcnb.BuildpackPlanEntry{
-		Name:     javaLayer,
-		Metadata: map[string]interface{}{"version": version},
-	})
+	ctx.SetMetadata(l, imageTypeKey, imageType)
+	if isRuntime {
+		ctx.AddBuildpackPlanEntry(libcnb.BuildpackPlanEntry{
+			Name:     javaLayer,
+			Metadata: map[string]interface{}{"version": version},
+		})
+	}
 	return nil
 }
 
@@ -135,17 +224,17 @@ func parseVersionJSON(jsonStr string) (javaRelease, error) {
 	return releases[0], nil
 }
 
-// extractRelease returns the version name and archiveURL from a javaRelease.
-func extractRelease(release javaRelease) (string, string, error) {
+// extractRelease returns the version name and archiveURL of the given image type from a javaRelease.
+func extractRelease(release javaRelease, imageType string) (string, string, error) {
 	if len(release.Binaries) == 0 {
 		return "", "", fmt.Errorf("no binaries in given release %s", release.VersionData.Semver)
 	}
 
 	for _, binary := range release.Binaries {
-		if binary.ImageType == "jdk" && binary.OS == "linux" && binary.Architecture == "x64" {
+		if binary.ImageType == imageType && binary.OS == "linux" && binary.Architecture == "x64" {
 			return release.VersionData.Semver, binary.BinaryPkg.Link, nil
 		}
 	}
 
-	return "", "", fmt.Errorf("jdk/linux/x64 binary not found in release %s", release.VersionData.Semver)
+	return "", "", fmt.Errorf("%s/linux/x64 binary not found in release %s", imageType, release.VersionData.Semver)
 }
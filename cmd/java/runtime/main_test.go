@@ -19,6 +19,7 @@ import (
 	"testing"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/java"
 )
 
 func TestDetect(t *testing.T) {
@@ -272,7 +273,7 @@ func TestExtractRelease(t *testing.T) {
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			gotVersion, gotBinaryLink, err := extractRelease(tc.javaRelease)
+			gotVersion, gotBinaryLink, err := extractRelease(tc.javaRelease, "jdk")
 			if err != nil {
 				t.Fatalf("extractRelease() returned error: %v", err)
 			}
@@ -321,10 +322,74 @@ func TestExtractReleaseFail(t *testing.T) {
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			_, _, err := extractRelease(tc.javaRelease)
+			_, _, err := extractRelease(tc.javaRelease, "jdk")
 			if err == nil {
 				t.Error("extractRelease() did not return error.")
 			}
 		})
 	}
 }
+
+func TestFeatureVersions(t *testing.T) {
+	testCases := []struct {
+		name        string
+		requested   string
+		toolchain   java.Toolchain
+		wantRun     string
+		wantCompile string
+		wantErr     bool
+	}{
+		{
+			name:        "default",
+			wantRun:     defaultFeatureVersion,
+			wantCompile: defaultFeatureVersion,
+		},
+		{
+			name:        "requested",
+			requested:   "8",
+			wantRun:     "8",
+			wantCompile: "8",
+		},
+		{
+			name:        "release",
+			toolchain:   java.Toolchain{Release: "17"},
+			wantRun:     "17",
+			wantCompile: "17",
+		},
+		{
+			name:        "newer compiler",
+			toolchain:   java.Toolchain{Release: "17", Compiler: "21"},
+			wantRun:     "17",
+			wantCompile: "21",
+		},
+		{
+			name:        "requested newer runtime",
+			requested:   "21",
+			toolchain:   java.Toolchain{Release: "17"},
+			wantRun:     "21",
+			wantCompile: "21",
+		},
+		{
+			name:      "requested older runtime",
+			requested: "11",
+			toolchain: java.Toolchain{Release: "17"},
+			wantErr:   true,
+		},
+		{
+			name:      "older compiler",
+			toolchain: java.Toolchain{Release: "17", Compiler: "11"},
+			wantErr:   true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRun, gotCompile, err := featureVersions(tc.requested, tc.toolchain)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("featureVersions(%q, %+v) got error: %v, want error: %t", tc.requested, tc.toolchain, err, tc.wantErr)
+			}
+			if gotRun != tc.wantRun || gotCompile != tc.wantCompile {
+				t.Errorf("featureVersions(%q, %+v) = %q, %q, want %q, %q", tc.requested, tc.toolchain, gotRun, gotCompile, tc.wantRun, tc.wantCompile)
+			}
+		})
+	}
+}
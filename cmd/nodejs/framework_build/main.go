@@ -0,0 +1,191 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements nodejs/framework_build buildpack.
+// The framework_build buildpack runs the production build of Next.js, Nuxt and Remix applications.
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/frameworks"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	cacheTag = "dev dependencies"
+)
+
+// buildTools maps the frameworks built by this buildpack to their build executable, the directory,
+// relative to the application root, in which the executable keeps its incremental build cache, and
+// the directory to which it writes the build output.
+var buildTools = map[string]struct {
+	bin       string
+	cacheDir  string
+	outputDir string
+}{
+	"nextjs": {bin: "next", cacheDir: ".next/cache", outputDir: ".next"},
+	"nuxt":   {bin: "nuxt", outputDir: ".output"},
+	"remix":  {bin: "remix", cacheDir: ".cache", outputDir: "build"},
+}
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) error {
+	if !ctx.FileExists("package.json") {
+		ctx.OptOut("package.json not found.")
+	}
+	p, err := nodejs.ReadPackageJSON(ctx.ApplicationRoot())
+	if err != nil {
+		return fmt.Errorf("reading package.json: %w", err)
+	}
+	if p.Scripts.GCPBuild != "" {
+		ctx.OptOut("gcp-build script found in package.json.")
+	}
+	fw, err := frameworks.Detect(ctx, "nodejs")
+	if err != nil {
+		return err
+	}
+	if fw == nil {
+		ctx.OptOut("No Node.js framework detected.")
+	}
+	if _, ok := buildTools[fw.Name]; !ok {
+		ctx.OptOut("%s does not require a build.", fw.Name)
+	}
+	return nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	fw, err := frameworks.Detect(ctx, "nodejs")
+	if err != nil {
+		return err
+	}
+	tool := buildTools[fw.Name]
+	p, err := nodejs.ReadPackageJSON(ctx.ApplicationRoot())
+	if err != nil {
+		return fmt.Errorf("reading package.json: %w", err)
+	}
+
+	yarn := ctx.FileExists(nodejs.YarnLock)
+	if err := installDevDependencies(ctx, yarn); err != nil {
+		return err
+	}
+
+	// Restore the framework's incremental build cache from the previous build.
+	var cl *libcnb.Layer
+	if tool.cacheDir != "" {
+		cl = ctx.Layer("build-cache", gcp.CacheLayer)
+		if ctx.FileExists(cl.Path, "cache") {
+			ctx.MkdirAll(filepath.Dir(tool.cacheDir), 0755)
+			ctx.Exec([]string{"cp", "--archive", filepath.Join(cl.Path, "cache"), tool.cacheDir}, gcp.WithUserTimingAttribution)
+		}
+	}
+
+	build := []string{filepath.Join("node_modules", ".bin", tool.bin), "build"}
+	if p.Scripts.Build != "" {
+		build = []string{"npm", "run", "build"}
+		if yarn {
+			build = []string{"yarn", "run", "build"}
+		}
+	}
+	ctx.Exec(build, gcp.WithEnv("NODE_ENV="+nodejs.EnvProduction, "NEXT_TELEMETRY_DISABLED=1"), gcp.WithUserAttribution)
+
+	// Keep the build cache out of the image.
+	if cl != nil && ctx.FileExists(tool.cacheDir) {
+		ctx.ClearLayer(cl)
+		ctx.Exec([]string{"cp", "--archive", tool.cacheDir, filepath.Join(cl.Path, "cache")}, gcp.WithUserTimingAttribution)
+		ctx.RemoveAll(tool.cacheDir)
+	}
+	if ctx.FileExists(tool.outputDir) {
+		if err := ctx.ExportArtifacts(tool.outputDir); err != nil {
+			return err
+		}
+	}
+
+	if fw.Name == "nextjs" {
+		splitNextStatic(ctx)
+	}
+
+	// Production dependencies are installed by the npm or yarn buildpack.
+	ctx.RemoveAll("node_modules")
+	return nil
+}
+
+// installDevDependencies installs all dependencies, including devDependencies needed to build, into node_modules.
+func installDevDependencies(ctx *gcp.Context, yarn bool) error {
+	l := ctx.Layer("node_modules", gcp.CacheLayer)
+	nm := filepath.Join(l.Path, "node_modules")
+	ctx.RemoveAll("node_modules")
+
+	lockfile := nodejs.YarnLock
+	if !yarn {
+		lockfile = nodejs.EnsureLockfile(ctx)
+	}
+	nodeEnv := nodejs.EnvDevelopment
+	cached, err := nodejs.CheckCache(ctx, l, cache.WithStrings(nodeEnv), cache.WithFiles("package.json", lockfile))
+	if err != nil {
+		return fmt.Errorf("checking cache: %w", err)
+	}
+	if cached {
+		ctx.CacheHit(cacheTag)
+		ctx.Exec([]string{"cp", "--archive", nm, "node_modules"}, gcp.WithUserTimingAttribution)
+		return nil
+	}
+	ctx.CacheMiss(cacheTag)
+	ctx.ClearLayer(l)
+	install := []string{"npm", nodejs.NPMInstallCommand(ctx), "--quiet"}
+	if yarn {
+		install = []string{"yarn", "install", "--non-interactive"}
+		if lf := nodejs.LockfileFlag(ctx); lf != "" {
+			install = append(install, lf)
+		}
+	}
+	ctx.Exec(install, gcp.WithEnv("NODE_ENV="+nodeEnv), gcp.WithTransientRetry, gcp.WithUserAttribution)
+	ctx.MkdirAll("node_modules", 0755)
+	ctx.Exec([]string{"cp", "--archive", "node_modules", nm}, gcp.WithUserTimingAttribution)
+	return nil
+}
+
+// splitNextStatic moves .next/static, which changes independently of the server bundle, into its
+// own launch layer and links it back into place, including into the standalone server if there is one.
+func splitNextStatic(ctx *gcp.Context) {
+	static := filepath.Join(".next", "static")
+	if !ctx.FileExists(static) {
+		return
+	}
+	l := ctx.Layer("next-static", gcp.LaunchLayer)
+	ctx.ClearLayer(l)
+	dst := filepath.Join(l.Path, "static")
+	ctx.Exec([]string{"cp", "--archive", static, dst}, gcp.WithUserTimingAttribution)
+	ctx.RemoveAll(static)
+	ctx.Symlink(dst, static)
+
+	// The standalone server does not serve .next/static or public unless they are copied next to it.
+	standalone := filepath.Dir(frameworks.NextStandaloneServer)
+	if !ctx.FileExists(standalone) {
+		return
+	}
+	ctx.MkdirAll(filepath.Join(standalone, ".next"), 0755)
+	ctx.RemoveAll(standalone, ".next", "static")
+	ctx.Symlink(dst, filepath.Join(standalone, ".next", "static"))
+	if ctx.FileExists("public") && !ctx.FileExists(standalone, "public") {
+		ctx.Symlink(filepath.Join(ctx.ApplicationRoot(), "public"), filepath.Join(standalone, "public"))
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Runs the functions framework script given as the first argument in a cluster of
+// up to FUNCTION_CONCURRENCY processes, but no more than one per CPU, which share
+// the port of the function server. With a single process, the framework runs in
+// this process.
+'use strict';
+
+const cluster = require('cluster');
+const os = require('os');
+const path = require('path');
+
+const concurrency = parseInt(process.env.FUNCTION_CONCURRENCY, 10) || 1;
+const size = Math.min(concurrency, os.cpus().length);
+
+if (cluster.isMaster && size > 1) {
+  console.log(`Starting ${size} function processes`);
+  for (let i = 0; i < size; i++) {
+    cluster.fork();
+  }
+  let stopping = false;
+  // A crashed worker leaves the instance with less capacity than it was deployed with, so the
+  // instance exits for the platform to replace it.
+  cluster.on('exit', (worker, code, signal) => {
+    if (stopping) {
+      if (Object.keys(cluster.workers).length === 0) {
+        process.exit(0);
+      }
+      return;
+    }
+    console.error(`Function process ${worker.process.pid} exited with ${signal || code}`);
+    process.exit(1);
+  });
+  for (const sig of ['SIGTERM', 'SIGINT']) {
+    process.on(sig, () => {
+      stopping = true;
+      for (const id in cluster.workers) {
+        cluster.workers[id].process.kill(sig);
+      }
+    });
+  }
+} else {
+  // The framework reads its options from the arguments that follow the script.
+  const script = path.resolve(process.argv[2]);
+  process.argv.splice(1, 2, script);
+  require(script);
+}
@@ -0,0 +1,45 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Preloaded with `node --require` to serve the functions framework at the sub-path
+// in FUNCTION_PATH: requests at or below the path reach the framework with the
+// path stripped, and other requests get a 404.
+'use strict';
+
+const http = require('http');
+
+const prefix = (process.env.FUNCTION_PATH || '').replace(/\/+$/, '');
+
+if (prefix) {
+  const createServer = http.createServer;
+  // The framework passes its request listener to http.createServer.
+  http.createServer = function(...args) {
+    const i = args.findIndex((arg) => typeof arg === 'function');
+    if (i >= 0) {
+      const listener = args[i];
+      args[i] = function(req, res) {
+        const q = req.url.indexOf('?');
+        const path = q < 0 ? req.url : req.url.slice(0, q);
+        if (path !== prefix && !path.startsWith(prefix + '/')) {
+          res.statusCode = 404;
+          res.end('Not Found');
+          return;
+        }
+        req.url = (path.slice(prefix.length) || '/') + (q < 0 ? '' : req.url.slice(q));
+        return listener.call(this, req, res);
+      };
+    }
+    return createServer.apply(this, args);
+  };
+}
@@ -20,8 +20,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/clearsource"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/conformance"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
@@ -29,6 +32,10 @@ import (
 
 const (
 	layerName = "functions-framework"
+	// clusterScript runs the framework in a cluster sized by env.FunctionConcurrencyLaunch.
+	clusterScript = "cluster.js"
+	// pathScript is preloaded to serve the framework at the sub-path in env.FunctionPathLaunch.
+	pathScript = "function_path.js"
 )
 
 func main() {
@@ -47,6 +54,10 @@ func buildFn(ctx *gcp.Context) error {
 	if _, ok := os.LookupEnv(env.FunctionSource); ok {
 		return gcp.UserErrorf("%s is not currently supported for Node.js buildpacks", env.FunctionSource)
 	}
+	prefix, err := env.FunctionPathPrefix()
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
 
 	// Function source code should be defined in the "main" field in package.json, index.js or function.js.
 	// https://cloud.google.com/functions/docs/writing#structuring_source_code
@@ -101,7 +112,7 @@ func buildFn(ctx *gcp.Context) error {
 		ctx.ClearLayer(l)
 		// NPM expects package.json and the lock file in the prefix directory.
 		ctx.Exec([]string{"cp", "-t", l.Path, pjs, pljs}, gcp.WithUserTimingAttribution)
-		ctx.Exec([]string{"npm", nodejs.NPMInstallCommand(ctx), "--quiet", "--production", "--prefix", l.Path}, gcp.WithUserAttribution)
+		ctx.Exec([]string{"npm", nodejs.NPMInstallCommand(ctx), "--quiet", "--production", "--prefix", l.Path}, gcp.WithTransientRetry, gcp.WithUserAttribution)
 	}
 
 	// Determine the path to the executable file to start functions-framework.
@@ -109,18 +120,74 @@ func buildFn(ctx *gcp.Context) error {
 	// be installed in node_modules.
 	// Else, it is installed in functions-framework layer's node_modules.
 	ff := filepath.Join(".bin", "functions-framework")
+	var ffEnv []string
 	if hasFrameworkDependency {
 		ff = filepath.Join("node_modules", ff)
 	} else {
 		ff = filepath.Join(nm, ff)
+	}
 
-		// Add user's node_modules to NODE_PATH so functions-framework can always find user's packages.
+	bundle, err := env.Bool(env.NodeJSBundle, false)
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	if bundle {
+		source, err := nodejs.Bundle(ctx, fnFile)
+		if err != nil {
+			return fmt.Errorf("bundling %s: %w", fnFile, err)
+		}
+		ffEnv = append(ffEnv, env.FunctionSourceLaunch+"="+source)
+	}
+
+	// Add user's node_modules to NODE_PATH so functions-framework, and bundles that live outside the
+	// application, can always find user's packages.
+	if !hasFrameworkDependency || bundle {
 		unm := filepath.Join(ctx.ApplicationRoot(), "node_modules")
 		if ctx.FileExists(unm) {
 			l.LaunchEnvironment.PrependPath("NODE_PATH", unm)
+			ffEnv = append(ffEnv, "NODE_PATH="+unm)
+		}
+	}
+
+	if err := clearsource.ClearTestSources(ctx); err != nil {
+		return err
+	}
+
+	// node runs the framework script, if the framework is not run directly.
+	var node []string
+	if prefix != "" {
+		ctx.Exec([]string{"cp", filepath.Join(ctx.BuildpackRoot(), "converter", pathScript), l.Path}, gcp.WithUserTimingAttribution)
+		node = []string{"node", "--require", filepath.Join(l.Path, pathScript)}
+		ctx.Logf("Serving the function at %s", prefix)
+	}
+
+	if ok, err := env.Bool(env.FunctionsConformance, false); err != nil {
+		return gcp.UserErrorf("%v", err)
+	} else if ok {
+		cmd := append(append([]string{}, node...), ff)
+		if err := conformance.Check(ctx, cmd, ffEnv...); err != nil {
+			return err
 		}
 	}
 
+	concurrency, err := env.FunctionConcurrencyHint()
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	if concurrency > 0 {
+		// Node.js serves concurrent requests on one CPU per process, so functions deployed to serve
+		// several requests at once run a process per CPU, up to the concurrency.
+		ctx.Exec([]string{"cp", filepath.Join(ctx.BuildpackRoot(), "converter", clusterScript), l.Path}, gcp.WithUserTimingAttribution)
+		if node == nil {
+			node = []string{"node"}
+		}
+		// Workers inherit the options of the cluster process, such as the preloaded pathScript.
+		node = append(node, filepath.Join(l.Path, clusterScript))
+	}
+	if node != nil {
+		ff = strings.Join(append(node, ff), " ")
+	}
+
 	ctx.SetFunctionsEnvVars(l)
 	ctx.AddWebProcess([]string{"/bin/bash", "-c", ff})
 	return nil
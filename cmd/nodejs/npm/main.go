@@ -19,9 +19,13 @@ package main
 import (
 	"fmt"
 	"path/filepath"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/depaudit"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/frameworks"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
 )
@@ -49,10 +53,32 @@ func buildFn(ctx *gcp.Context) error {
 	lockfile := nodejs.EnsureLockfile(ctx)
 
 	nodeEnv := nodejs.NodeEnv()
-	cached, err := nodejs.CheckCache(ctx, ml, cache.WithStrings(nodeEnv), cache.WithFiles("package.json", lockfile))
+	ignoreScripts, err := env.Bool(env.NPMIgnoreScripts, false)
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	var installFlags []string
+	if ignoreScripts {
+		installFlags = append(installFlags, "--ignore-scripts")
+	}
+	// Dependencies installed with a different script policy may be missing build outputs.
+	cacheKeys := []string{nodeEnv}
+	if ignoreScripts {
+		cacheKeys = append(cacheKeys, strings.Join(append(installFlags, nodejs.AllowedScripts()...), ","))
+	}
+	cached, err := nodejs.CheckCache(ctx, ml, cache.WithStrings(cacheKeys...), cache.WithFiles("package.json", lockfile))
 	if err != nil {
 		return fmt.Errorf("checking cache: %w", err)
 	}
+	purged := false
+	purge := gcp.WithCorruptCacheRetry(func() error {
+		purged = true
+		ctx.ClearLayer(ml)
+		ctx.RemoveAll("node_modules")
+		return nodejs.PurgeNPMCache(ctx)
+	})
+	// npm install may rewrite package.json and the lockfile.
+	saveOriginals := ctx.PreserveFiles("package.json", lockfile)
 	if cached {
 		ctx.CacheHit(cacheTag)
 		// Restore cached node_modules.
@@ -60,27 +86,54 @@ func buildFn(ctx *gcp.Context) error {
 
 		// Always run npm install to run preinstall/postinstall scripts.
 		// Otherwise it should be a no-op because the lockfile is unchanged.
-		ctx.Exec([]string{"npm", "install", "--quiet"}, gcp.WithEnv("NODE_ENV="+nodeEnv), gcp.WithUserAttribution)
+		ctx.Exec(append([]string{"npm", "install", "--quiet"}, installFlags...), gcp.WithEnv("NODE_ENV="+nodeEnv), gcp.WithTransientRetry, purge, gcp.WithUserAttribution)
+		if purged {
+			// The cached node_modules were purged, so cache the reinstalled ones.
+			ctx.MkdirAll("node_modules", 0755)
+			ctx.Exec([]string{"cp", "--archive", "node_modules", nm}, gcp.WithUserTimingAttribution)
+		}
 	} else {
 		ctx.CacheMiss(cacheTag)
 		// Clear cached node_modules to ensure we don't end up with outdated dependencies after copying.
 		ctx.ClearLayer(ml)
 
-		ctx.Exec([]string{"npm", nodejs.NPMInstallCommand(ctx), "--quiet"}, gcp.WithEnv("NODE_ENV="+nodeEnv), gcp.WithUserAttribution)
+		ctx.Exec(append([]string{"npm", nodejs.NPMInstallCommand(ctx), "--quiet"}, installFlags...), gcp.WithEnv("NODE_ENV="+nodeEnv), gcp.WithTransientRetry, purge, gcp.WithUserAttribution)
+		if ignoreScripts {
+			nodejs.RebuildAllowed(ctx, nodeEnv)
+		}
 
 		// Ensure node_modules exists even if no dependencies were installed.
 		ctx.MkdirAll("node_modules", 0755)
 		ctx.Exec([]string{"cp", "--archive", "node_modules", nm}, gcp.WithUserTimingAttribution)
 	}
+	saveOriginals()
+	if err := depaudit.RecordNPM(ctx, nodeEnv); err != nil {
+		return err
+	}
+	if err := nodejs.VerifySignatures(ctx); err != nil {
+		return err
+	}
 
 	el := ctx.Layer("env", gcp.BuildLayer, gcp.LaunchLayer)
 	el.SharedEnvironment.PrependPath("PATH", filepath.Join(ctx.ApplicationRoot(), "node_modules", ".bin"))
 	el.SharedEnvironment.Default("NODE_ENV", nodeEnv)
 
+	fw, err := frameworks.Detect(ctx, "nodejs")
+	if err != nil {
+		return err
+	}
+	if fw != nil {
+		fw.ConfigureLaunch(ctx, el)
+	}
+
 	// Configure the entrypoint for production.
 	cmd := []string{"npm", "start"}
 
 	if !devmode.Enabled(ctx) {
+		if fw != nil && len(fw.Command) > 0 {
+			ctx.Logf("Using the default entrypoint for %s", fw.Name)
+			cmd = fw.Command
+		}
 		ctx.AddWebProcess(cmd)
 		return nil
 	}
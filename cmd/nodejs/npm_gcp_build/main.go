@@ -76,6 +76,9 @@ func buildFn(ctx *gcp.Context) error {
 	}
 
 	ctx.Exec([]string{"npm", "run", "gcp-build"}, gcp.WithUserAttribution)
+	if err := nodejs.ExportBuildOutput(ctx); err != nil {
+		return err
+	}
 	ctx.RemoveAll("node_modules")
 	return nil
 }
@@ -17,9 +17,14 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
@@ -29,9 +34,28 @@ import (
 )
 
 const (
-	nodeLayer  = "node"
-	nodeURL    = "https://nodejs.org/dist/v%[1]s/node-v%[1]s-linux-x64.tar.xz"
-	versionKey = "version"
+	nodeLayer      = "node"
+	icuLayer       = "icu"
+	nodeURL        = "%[1]s/v%[2]s/node-v%[2]s-linux-x64.tar.xz"
+	windowsNodeURL = "%[1]s/v%[2]s/node-v%[2]s-win-x64.zip"
+	semverURL      = "http://semver.io/node/resolve"
+	versionKey     = "version"
+	nvmrcFile      = ".nvmrc"
+)
+
+var (
+	// nvmrcVersionRegexp matches the versions of .nvmrc that are semver ranges, such as 20, v18.17.1 or 20.x.
+	nvmrcVersionRegexp = regexp.MustCompile(`^v?\d+(\.(\d+|x)){0,2}$`)
+	// exactVersionRegexp matches the version ranges that only allow a single version, such as 20.11.1.
+	exactVersionRegexp = regexp.MustCompile(`^v?\d+\.\d+\.\d+$`)
+
+	// distURLs are the download directories of the runtime channels, each with an index.json listing
+	// its versions from the newest.
+	distURLs = map[string]string{
+		runtime.ChannelStable:  "https://nodejs.org/dist",
+		runtime.ChannelBeta:    "https://nodejs.org/download/rc",
+		runtime.ChannelNightly: "https://nodejs.org/download/nightly",
+	}
 )
 
 func main() {
@@ -53,60 +77,207 @@ func detectFn(ctx *gcp.Context) error {
 }
 
 func buildFn(ctx *gcp.Context) error {
-	version, err := runtimeVersion(ctx)
+	channel, err := runtime.Channel()
+	if err != nil {
+		return err
+	}
+	policy, err := runtime.PatchPolicy()
+	if err != nil {
+		return err
+	}
+	version, request, err := runtimeVersion(ctx, channel)
 	if err != nil {
 		return err
 	}
+	nrl := ctx.Layer(nodeLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayer)
+	version = runtime.ApplyPatchPolicy(ctx, nrl, "Node.js", policy, request, version)
+	if channel != runtime.ChannelStable {
+		runtime.UsePrerelease(ctx, "Node.js", channel, version)
+	}
+	if err := ctx.RecordFact(gcp.FactRuntimeVersion, version); err != nil {
+		return err
+	}
 
 	// Check the metadata in the cache layer to determine if we need to proceed.
-	nrl := ctx.Layer(nodeLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayer)
+	ctx.AddTool(nrl, "node", version)
+	if ctx.Platform() == gcp.Windows {
+		// Windows archives have node.exe at their root rather than in bin.
+		nrl.SharedEnvironment.PrependPath("PATH", nrl.Path)
+	}
 	metaVersion := ctx.GetMetadata(nrl, versionKey)
 	if version == metaVersion {
 		ctx.CacheHit(nodeLayer)
 		ctx.Logf("Runtime cache hit, skipping installation.")
-		return nil
+		addBundledNPM(ctx, nrl)
+		return addFullICU(ctx, nrl, version)
 	}
 	ctx.CacheMiss(nodeLayer)
 	ctx.ClearLayer(nrl)
 
-	archiveURL := fmt.Sprintf(nodeURL, version)
+	archiveURL := nodeArchiveURL(ctx.Platform(), channel, version)
 	if code := ctx.HTTPStatus(archiveURL); code != http.StatusOK {
 		return gcp.UserErrorf("Runtime version %s does not exist at %s (status %d). You can specify the version with %s.", version, archiveURL, code, env.RuntimeVersion)
 	}
 
 	// Download and install Node.js in layer.
 	ctx.Logf("Installing Node.js v%s", version)
-	command := fmt.Sprintf("curl --fail --show-error --silent --location --retry 3 %s | tar xJ --directory %s --strip-components=1", archiveURL, nrl.Path)
-	ctx.Exec([]string{"bash", "-c", command}, gcp.WithUserAttribution)
+	ctx.InstallArchive(archiveURL, nrl.Path, 1)
+	node := filepath.Join(nrl.Path, "bin", "node")
+	if ctx.Platform() == gcp.Windows {
+		node = filepath.Join(nrl.Path, "node.exe")
+	}
+	if err := runtime.SmokeTest(ctx, "Node.js", []string{node, "-e", "console.log(process.version)"}); err != nil {
+		return err
+	}
 
 	ctx.SetMetadata(nrl, versionKey, version)
 	ctx.AddBuildpackPlanEntry(libcnb.BuildpackPlanEntry{
 		Name:     nodeLayer,
 		Metadata: map[string]interface{}{"version": version},
 	})
+	addBundledNPM(ctx, nrl)
+	return addFullICU(ctx, nrl, version)
+}
+
+// addFullICU installs the full ICU data into a launch layer and points NODE_ICU_DATA to it, if the
+// Node.js binary in nrl only has English ICU data and the app uses the Intl API.
+func addFullICU(ctx *gcp.Context, nrl *libcnb.Layer, version string) error {
+	if ctx.Platform() == gcp.Windows {
+		return nil
+	}
+	uses, err := nodejs.UsesIntl(ctx.ApplicationRoot())
+	if err != nil {
+		return gcp.InternalErrorf("searching for uses of the Intl API: %v", err)
+	}
+	if !uses || !nodejs.SmallICU(ctx, filepath.Join(nrl.Path, "bin", "node")) {
+		return nil
+	}
+
+	il := ctx.Layer(icuLayer, gcp.CacheLayer, gcp.LaunchLayer)
+	icuData := filepath.Join(il.Path, "node_modules", "full-icu")
+	il.LaunchEnvironment.Default("NODE_ICU_DATA", icuData)
+	if ctx.GetMetadata(il, versionKey) == version {
+		ctx.CacheHit(icuLayer)
+		return nil
+	}
+	ctx.CacheMiss(icuLayer)
+	ctx.ClearLayer(il)
+	ctx.Logf("Installing the full ICU data, as Node.js v%s only has English locale data and the app uses the Intl API", version)
+	// The full-icu install script downloads the ICU data matching the node binary on the PATH.
+	path := filepath.Join(nrl.Path, "bin") + string(os.PathListSeparator) + os.Getenv("PATH")
+	ctx.Exec([]string{filepath.Join(nrl.Path, "bin", "npm"), "install", "--prefix", il.Path, "--no-save", "--no-package-lock", "full-icu"}, gcp.WithEnv("PATH="+path), gcp.WithUserAttribution)
+	ctx.SetMetadata(il, versionKey, version)
 	return nil
 }
 
-// runtimeVersion returns the version of the runtime to install.
-// The version is read from env var if set or determined based on the `engines` field in package.json.
-func runtimeVersion(ctx *gcp.Context) (string, error) {
-	if version := os.Getenv(env.RuntimeVersion); version != "" {
-		ctx.Logf("Using runtime version from %s: %s", env.RuntimeVersion, version)
-		return version, nil
+// nodeArchiveURL returns the URL of the Node.js archive of the channel for the platform.
+func nodeArchiveURL(p gcp.Platform, channel, version string) string {
+	if p == gcp.Windows {
+		return fmt.Sprintf(windowsNodeURL, distURLs[channel], version)
 	}
-	// The default empty range returns the latest version.
-	var versionRange string
-	if ctx.FileExists("package.json") {
-		pjs, err := nodejs.ReadPackageJSON(ctx.ApplicationRoot())
+	return fmt.Sprintf(nodeURL, distURLs[channel], version)
+}
+
+// addBundledNPM records the version of npm that is bundled with Node.js in layer l.
+func addBundledNPM(ctx *gcp.Context, l *libcnb.Layer) {
+	npm := filepath.Join(l.Path, "lib", "node_modules", "npm")
+	if ctx.Platform() == gcp.Windows {
+		npm = filepath.Join(l.Path, "node_modules", "npm")
+	}
+	pjs, err := nodejs.ReadPackageJSON(npm)
+	if err != nil {
+		ctx.Debugf("Not recording the npm version: %v", err)
+		return
+	}
+	ctx.AddTool(l, "npm", pjs.Version)
+}
+
+// runtimeVersion returns the version of the runtime to install, and the range or "latest" from which
+// it was resolved, or "" if it was requested exactly.
+// The version is read from env var if set, is the latest version of channels other than
+// runtime.ChannelStable, or is determined based on the `engines` field in package.json, or else
+// .nvmrc.
+func runtimeVersion(ctx *gcp.Context, channel string) (string, string, error) {
+	cv, err := ctx.ResolveConfig("Node.js version",
+		gcp.EnvConfig(env.RuntimeVersion),
+		runtime.ChannelConfig(channel),
+		gcp.ConfigSource{Name: "engines.node", Value: enginesNode},
+		gcp.ConfigSource{Name: nvmrcFile, Value: nvmrc},
+	)
+	if err != nil {
+		return "", "", err
+	}
+	switch cv.Source {
+	case env.RuntimeVersion:
+		return cv.Value, "", nil
+	case env.RuntimeChannel:
+		body, err := ctx.FetchMetadata(distURLs[channel] + "/index.json")
+		if err != nil {
+			return "", "", err
+		}
+		version, err := parseIndexJSON(body)
 		if err != nil {
-			return "", fmt.Errorf("reading package.json: %w", err)
+			return "", "", gcp.InternalErrorf("parsing the Node.js %s versions: %v", channel, err)
 		}
-		versionRange = pjs.Engines.Node
+		ctx.Logf("Using latest %s runtime version: %s", channel, version)
+		return version, "", nil
 	}
-	// Use package.json and semver.io to determine best-fit Node.js version.
+	// The default empty range returns the latest version.
+	versionRange := cv.Value
+	// Use semver.io to determine best-fit Node.js version.
 	ctx.Logf("Resolving Node.js version based on semver %q", versionRange)
-	result := ctx.Exec([]string{"curl", "--fail", "--show-error", "--silent", "--location", "--get", "--data-urlencode", fmt.Sprintf("range=%s", versionRange), "http://semver.io/node/resolve"}, gcp.WithUserAttribution)
-	version := result.Stdout
-	ctx.Logf("Using resolved runtime version from package.json: %s", version)
-	return version, nil
+	body, err := ctx.FetchMetadata(semverURL + "?" + url.Values{"range": {versionRange}}.Encode())
+	if err != nil {
+		return "", "", gcp.UserErrorf("resolving Node.js version %q: %v", versionRange, err)
+	}
+	version := strings.TrimSpace(string(body))
+	ctx.Logf("Using resolved runtime version from %s: %s", cv.Source, version)
+	switch {
+	case versionRange == "":
+		return version, "latest", nil
+	case exactVersionRegexp.MatchString(versionRange):
+		return version, "", nil
+	}
+	return version, versionRange, nil
+}
+
+// enginesNode returns the version range of the `engines.node` field of package.json.
+func enginesNode(ctx *gcp.Context) (string, bool, error) {
+	if !ctx.FileExists("package.json") {
+		return "", false, nil
+	}
+	pjs, err := nodejs.ReadPackageJSON(ctx.ApplicationRoot())
+	if err != nil {
+		return "", false, fmt.Errorf("reading package.json: %w", err)
+	}
+	return pjs.Engines.Node, pjs.Engines.Node != "", nil
+}
+
+// nvmrc returns the version declared in .nvmrc. Aliases, such as lts/*, are not supported and are
+// ignored.
+func nvmrc(ctx *gcp.Context) (string, bool, error) {
+	path := filepath.Join(ctx.ApplicationRoot(), nvmrcFile)
+	if !ctx.FileExists(path) {
+		return "", false, nil
+	}
+	v := strings.TrimSpace(string(ctx.ReadFile(path)))
+	if !nvmrcVersionRegexp.MatchString(v) {
+		ctx.Warnf("Ignoring %s: %q is not a Node.js version", nvmrcFile, v)
+		return "", false, nil
+	}
+	return strings.TrimPrefix(v, "v"), true, nil
+}
+
+// parseIndexJSON returns the newest version listed in the index.json of a download directory.
+func parseIndexJSON(body []byte) (string, error) {
+	var releases []struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(body, &releases); err != nil {
+		return "", err
+	}
+	if len(releases) == 0 || releases[0].Version == "" {
+		return "", fmt.Errorf("no versions found")
+	}
+	return strings.TrimPrefix(releases[0].Version, "v"), nil
 }
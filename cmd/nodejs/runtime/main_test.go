@@ -55,3 +55,58 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestNodeArchiveURL(t *testing.T) {
+	testCases := []struct {
+		platform gcp.Platform
+		channel  string
+		version  string
+		want     string
+	}{
+		{platform: gcp.Linux, channel: "stable", version: "14.4.0", want: "https://nodejs.org/dist/v14.4.0/node-v14.4.0-linux-x64.tar.xz"},
+		{platform: gcp.Windows, channel: "stable", version: "14.4.0", want: "https://nodejs.org/dist/v14.4.0/node-v14.4.0-win-x64.zip"},
+		{platform: gcp.Linux, channel: "beta", version: "15.0.0-rc.1", want: "https://nodejs.org/download/rc/v15.0.0-rc.1/node-v15.0.0-rc.1-linux-x64.tar.xz"},
+		{platform: gcp.Linux, channel: "nightly", version: "15.0.0-nightly20200901abcdef", want: "https://nodejs.org/download/nightly/v15.0.0-nightly20200901abcdef/node-v15.0.0-nightly20200901abcdef-linux-x64.tar.xz"},
+	}
+	for _, tc := range testCases {
+		if got := nodeArchiveURL(tc.platform, tc.channel, tc.version); got != tc.want {
+			t.Errorf("nodeArchiveURL(%q, %q) = %q, want %q", tc.platform, tc.channel, got, tc.want)
+		}
+	}
+}
+
+func TestParseIndexJSON(t *testing.T) {
+	testCases := []struct {
+		name    string
+		json    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "newest first",
+			json: `[{"version":"v15.0.0-rc.1","date":"2020-09-01"},{"version":"v14.9.0-rc.0","date":"2020-08-18"}]`,
+			want: "15.0.0-rc.1",
+		},
+		{
+			name:    "empty",
+			json:    `[]`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid",
+			json:    `{`,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseIndexJSON([]byte(tc.json))
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("parseIndexJSON() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("parseIndexJSON() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -23,6 +23,7 @@ import (
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/frameworks"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
 	"github.com/buildpacks/libcnb"
@@ -79,22 +80,47 @@ func buildFn(ctx *gcp.Context) error {
 	if lf := nodejs.LockfileFlag(ctx); lf != "" {
 		cmd = append(cmd, lf)
 	}
-	ctx.Exec(cmd, gcp.WithEnv("NODE_ENV="+nodeEnv), gcp.WithUserAttribution)
+	purged := false
+	purge := gcp.WithCorruptCacheRetry(func() error {
+		purged = true
+		ctx.ClearLayer(ml)
+		ctx.RemoveAll("node_modules")
+		return nodejs.PurgeYarnCache(ctx)
+	})
+	// yarn install may rewrite package.json and yarn.lock.
+	saveOriginals := ctx.PreserveFiles("package.json", nodejs.YarnLock)
+	ctx.Exec(cmd, gcp.WithEnv("NODE_ENV="+nodeEnv), gcp.WithTransientRetry, purge, gcp.WithUserAttribution)
+	saveOriginals()
 
-	if !cached {
+	if !cached || purged {
 		// Ensure node_modules exists even if no dependencies were installed.
 		ctx.MkdirAll("node_modules", 0755)
 		ctx.Exec([]string{"cp", "--archive", "node_modules", nm}, gcp.WithUserTimingAttribution)
 	}
+	if err := nodejs.VerifySignatures(ctx); err != nil {
+		return err
+	}
 
 	el := ctx.Layer("env", gcp.BuildLayer, gcp.LaunchLayer)
 	el.SharedEnvironment.PrependPath("PATH", filepath.Join(ctx.ApplicationRoot(), "node_modules", ".bin"))
 	el.SharedEnvironment.Default("NODE_ENV", nodeEnv)
 
+	fw, err := frameworks.Detect(ctx, "nodejs")
+	if err != nil {
+		return err
+	}
+	if fw != nil {
+		fw.ConfigureLaunch(ctx, el)
+	}
+
 	// Configure the entrypoint for production.
 	cmd = []string{"yarn", "run", "start"}
 
 	if !devmode.Enabled(ctx) {
+		if fw != nil && len(fw.Command) > 0 {
+			ctx.Logf("Using the default entrypoint for %s", fw.Name)
+			cmd = fw.Command
+		}
 		ctx.AddWebProcess(cmd)
 		return nil
 	}
@@ -118,6 +144,7 @@ func installYarn(ctx *gcp.Context) error {
 
 	yarnLayer := "yarn_install"
 	yrl := ctx.Layer(yarnLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayer)
+	ctx.AddTool(yrl, "yarn", yarnVersion)
 
 	// Check the metadata in the cache layer to determine if we need to proceed.
 	metaVersion := ctx.GetMetadata(yrl, versionKey)
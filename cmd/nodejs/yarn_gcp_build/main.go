@@ -84,6 +84,9 @@ func buildFn(ctx *gcp.Context) error {
 	}
 
 	ctx.Exec([]string{"yarn", "run", "gcp-build"}, gcp.WithUserAttribution)
+	if err := nodejs.ExportBuildOutput(ctx); err != nil {
+		return err
+	}
 	ctx.RemoveAll("node_modules")
 	return nil
 }
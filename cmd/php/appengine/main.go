@@ -17,10 +17,18 @@
 package main
 
 import (
+	"path"
+
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/appengine"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/frameworks"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 )
 
+const (
+	// frontController is the script that handles requests not matching a static file.
+	frontController = "index.php"
+)
+
 func main() {
 	gcp.Main(detectFn, buildFn)
 }
@@ -31,5 +39,24 @@ func detectFn(ctx *gcp.Context) error {
 }
 
 func buildFn(ctx *gcp.Context) error {
-	return appengine.Build(ctx, "php", nil)
+	return appengine.Build(ctx, "php", entrypoint)
+}
+
+// entrypoint passes the front controller of a detected framework to serve, which configures the
+// document root and rewrite rules of the generated nginx config from it.
+func entrypoint(ctx *gcp.Context) (*appengine.Entrypoint, error) {
+	fw, err := frameworks.Detect(ctx, "php")
+	if err != nil {
+		return nil, err
+	}
+	if fw == nil || fw.DocumentRoot == "" {
+		return &appengine.Entrypoint{
+			Type:    appengine.EntrypointDefault.String(),
+			Command: appengine.DefaultCommand,
+		}, nil
+	}
+	return &appengine.Entrypoint{
+		Type:    appengine.EntrypointGenerated.String(),
+		Command: appengine.DefaultCommand + " " + path.Join(fw.DocumentRoot, frontController),
+	}, nil
 }
@@ -0,0 +1,106 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements php/framework buildpack.
+// The framework buildpack prepares Laravel and Symfony applications for production.
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/frameworks"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// requiredConfig lists, per framework, env vars the application cannot run without.
+var requiredConfig = map[string][]string{
+	"laravel": {"APP_KEY"},
+	"symfony": {"APP_SECRET"},
+}
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) error {
+	fw, err := frameworks.Detect(ctx, "php")
+	if err != nil {
+		return err
+	}
+	if fw == nil {
+		ctx.OptOut("Laravel or Symfony application not found")
+	}
+	return nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	fw, err := frameworks.Detect(ctx, "php")
+	if err != nil {
+		return err
+	}
+	if fw == nil {
+		return gcp.InternalErrorf("framework detected at detect time but not at build time")
+	}
+	fw.ConfigureLaunch(ctx, ctx.Layer("framework", gcp.LaunchLayer))
+
+	missing := missingConfig(ctx, requiredConfig[fw.Name])
+	for _, name := range missing {
+		ctx.Warnf("%s is not set in the build environment or .env; %s will fail at runtime unless it is set in the deployment environment.", name, fw.Name)
+	}
+
+	switch fw.Name {
+	case "laravel":
+		// config:cache bakes the build environment into the cache, so it would hide configuration
+		// that is only provided at runtime.
+		if len(missing) == 0 {
+			ctx.Exec([]string{"php", "artisan", "config:cache"}, gcp.WithUserAttribution)
+		} else {
+			ctx.Logf("Skipping config:cache because configuration is incomplete at build time.")
+		}
+		ctx.Exec([]string{"php", "artisan", "route:cache"}, gcp.WithUserAttribution)
+		ctx.Exec([]string{"php", "artisan", "view:cache"}, gcp.WithUserAttribution)
+	case "symfony":
+		ctx.Exec([]string{"php", "bin/console", "cache:warmup", "--no-debug"}, gcp.WithEnv("APP_ENV="+envOr("APP_ENV", fw.Env["APP_ENV"])), gcp.WithUserAttribution)
+	}
+	return nil
+}
+
+// missingConfig returns the names that are set neither in the build environment nor in the
+// .env file of the application.
+func missingConfig(ctx *gcp.Context, names []string) []string {
+	var dotenv []byte
+	if p := filepath.Join(ctx.ApplicationRoot(), ".env"); ctx.FileExists(p) {
+		dotenv = ctx.ReadFile(p)
+	}
+	var missing []string
+	for _, name := range names {
+		if os.Getenv(name) != "" {
+			continue
+		}
+		if regexp.MustCompile(`(?m)^` + name + `=\S`).Match(dotenv) {
+			continue
+		}
+		missing = append(missing, name)
+	}
+	return missing
+}
+
+func envOr(name, fallback string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,116 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  int
+	}{
+		{
+			name: "laravel",
+			files: map[string]string{
+				"artisan":       "",
+				"composer.json": `{"require": {"laravel/framework": "^8.0"}}`,
+			},
+			want: 0,
+		},
+		{
+			name: "symfony",
+			files: map[string]string{
+				"bin/console":   "",
+				"composer.json": `{"require": {"symfony/framework-bundle": "5.1.*"}}`,
+			},
+			want: 0,
+		},
+		{
+			name: "composer without framework",
+			files: map[string]string{
+				"index.php":     "",
+				"composer.json": `{"require": {"monolog/monolog": "^2.0"}}`,
+			},
+			want: 100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gcp.TestDetect(t, detectFn, tc.name, tc.files, []string{}, tc.want)
+		})
+	}
+}
+
+func TestMissingConfig(t *testing.T) {
+	testCases := []struct {
+		name   string
+		dotenv string
+		env    map[string]string
+		want   []string
+	}{
+		{
+			name: "missing",
+			want: []string{"APP_KEY"},
+		},
+		{
+			name:   "empty in .env",
+			dotenv: "APP_NAME=Laravel\nAPP_KEY=\n",
+			want:   []string{"APP_KEY"},
+		},
+		{
+			name:   "set in .env",
+			dotenv: "APP_KEY=base64:abc\n",
+		},
+		{
+			name: "set in environment",
+			env:  map[string]string{"APP_KEY": "base64:abc"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "framework")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			if tc.dotenv != "" {
+				if err := ioutil.WriteFile(filepath.Join(dir, ".env"), []byte(tc.dotenv), 0644); err != nil {
+					t.Fatalf("writing .env: %v", err)
+				}
+			}
+			for k, v := range tc.env {
+				if err := os.Setenv(k, v); err != nil {
+					t.Fatalf("setting env %s: %v", k, err)
+				}
+				defer os.Unsetenv(k)
+			}
+			ctx := gcp.NewContextForTests(libcnb.BuildpackInfo{}, dir)
+
+			if got := missingConfig(ctx, []string{"APP_KEY"}); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("missingConfig() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
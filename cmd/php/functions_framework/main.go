@@ -21,6 +21,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/clearsource"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/php"
@@ -82,6 +83,10 @@ func buildFn(ctx *gcp.Context) error {
 		}
 	}
 
+	if err := clearsource.ClearTestSources(ctx); err != nil {
+		return err
+	}
+
 	ctx.AddWebProcess([]string{"/bin/bash", "-c", fmt.Sprintf("php -S 0.0.0.0:${PORT} %s", routerScript)})
 
 	l := ctx.Layer("functions-framework", gcp.BuildLayer, gcp.LaunchLayer)
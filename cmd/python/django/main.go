@@ -0,0 +1,107 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements python/django buildpack.
+// The django buildpack validates the settings module of a Django project and collects its static files.
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/frameworks"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// staticRootScript prints the STATIC_ROOT of the configured settings module.
+	staticRootScript = "from django.conf import settings; print(settings.STATIC_ROOT or '')"
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) error {
+	fw, err := frameworks.Detect(ctx, "python")
+	if err != nil {
+		return err
+	}
+	if fw == nil || fw.Name != "django" {
+		ctx.OptOut("Django project not found")
+	}
+	return nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	module := frameworks.DjangoSettingsModule(ctx)
+	if module == "" {
+		return gcp.UserErrorf("DJANGO_SETTINGS_MODULE is not set and manage.py does not set a default")
+	}
+	if frameworks.DjangoSettingsFile(ctx, module) == "" {
+		return gcp.UserErrorf("DJANGO_SETTINGS_MODULE %q does not refer to a module in the application", module)
+	}
+	ctx.Logf("Using Django settings module %s", module)
+
+	l := ctx.Layer("django", gcp.LaunchLayer)
+	l.LaunchEnvironment.Default("DJANGO_SETTINGS_MODULE", module)
+	settingsEnv := "DJANGO_SETTINGS_MODULE=" + module
+
+	checkDeploy, err := env.Bool(env.DjangoCheckDeploy, false)
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	if checkDeploy {
+		ctx.Exec([]string{"python3", "manage.py", "check", "--deploy", "--fail-level", "WARNING"}, gcp.WithEnv(settingsEnv), gcp.WithUserAttribution)
+	}
+
+	collectStatic(ctx, settingsEnv)
+	return nil
+}
+
+// collectStatic runs collectstatic and moves STATIC_ROOT into a launch layer, leaving a symlink
+// in its place so that the application and source-clearing buildpacks are unaffected.
+func collectStatic(ctx *gcp.Context, settingsEnv string) {
+	result, err := ctx.ExecWithErr([]string{"python3", "manage.py", "shell", "-c", staticRootScript}, gcp.WithEnv(settingsEnv), gcp.WithUserAttribution)
+	if err != nil {
+		// Settings frequently depend on secrets or databases that are only available at runtime.
+		ctx.Warnf("Skipping collectstatic, unable to load Django settings: %v", err)
+		return
+	}
+	root := strings.TrimSpace(result.Stdout)
+	if root == "" {
+		ctx.Logf("STATIC_ROOT is not set, skipping collectstatic.")
+		return
+	}
+	ctx.Exec([]string{"python3", "manage.py", "collectstatic", "--noinput"}, gcp.WithEnv(settingsEnv), gcp.WithUserAttribution)
+
+	if !filepath.IsAbs(root) {
+		root = filepath.Join(ctx.ApplicationRoot(), root)
+	}
+	if !staticInApplication(ctx.ApplicationRoot(), root) || !ctx.FileExists(root) {
+		return
+	}
+	sl := ctx.Layer("static", gcp.LaunchLayer)
+	dst := filepath.Join(sl.Path, filepath.Base(root))
+	ctx.Exec([]string{"cp", "--archive", root, dst}, gcp.WithUserTimingAttribution)
+	ctx.RemoveAll(root)
+	ctx.Symlink(dst, root)
+}
+
+// staticInApplication returns true if root is a directory below the application root.
+func staticInApplication(appRoot, root string) bool {
+	rel, err := filepath.Rel(appRoot, root)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, "../")
+}
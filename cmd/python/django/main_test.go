@@ -0,0 +1,79 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  int
+	}{
+		{
+			name: "django project",
+			files: map[string]string{
+				"manage.py":        `os.environ.setdefault("DJANGO_SETTINGS_MODULE", "mysite.settings")`,
+				"requirements.txt": "Django==3.1\n",
+			},
+			want: 0,
+		},
+		{
+			name: "django without manage.py",
+			files: map[string]string{
+				"main.py":          "",
+				"requirements.txt": "Django==3.1\n",
+			},
+			want: 100,
+		},
+		{
+			name: "flask project",
+			files: map[string]string{
+				"main.py":          "",
+				"requirements.txt": "flask\n",
+			},
+			want: 100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gcp.TestDetect(t, detectFn, tc.name, tc.files, []string{}, tc.want)
+		})
+	}
+}
+
+func TestStaticInApplication(t *testing.T) {
+	testCases := []struct {
+		root string
+		want bool
+	}{
+		{root: "/workspace/staticfiles", want: true},
+		{root: "/workspace/mysite/static", want: true},
+		{root: "/workspace", want: false},
+		{root: "/var/www/static", want: false},
+		{root: "/workspace/../static", want: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.root, func(t *testing.T) {
+			if got := staticInApplication("/workspace", tc.root); got != tc.want {
+				t.Errorf("staticInApplication(%q) = %t, want %t", tc.root, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+# Copyright 2020 Google LLC
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+"""Serves the functions framework at the sub-path in FUNCTION_PATH.
+
+Requests at or below the path reach the framework with the path moved to
+SCRIPT_NAME, as WSGI applications mounted at a sub-path expect, and other
+requests get a 404.
+"""
+
+import os
+
+import functions_framework
+
+
+def create_app():
+  """Returns the framework's app, mounted at FUNCTION_PATH if it is set."""
+  app = functions_framework.create_app()
+  prefix = os.environ.get("FUNCTION_PATH", "").rstrip("/")
+  if not prefix:
+    return app
+
+  def serve(environ, start_response):
+    path = environ.get("PATH_INFO", "")
+    if path != prefix and not path.startswith(prefix + "/"):
+      start_response("404 Not Found", [("Content-Type", "text/plain")])
+      return [b"Not Found"]
+    environ["SCRIPT_NAME"] = environ.get("SCRIPT_NAME", "") + prefix
+    environ["PATH_INFO"] = path[len(prefix):] or "/"
+    return app(environ, start_response)
+
+  return serve
@@ -22,6 +22,8 @@ import (
 	"path/filepath"
 	"regexp"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/clearsource"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/conformance"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/python"
@@ -29,6 +31,11 @@ import (
 
 const (
 	layerName = "functions-framework"
+	// gunicornCommand serves an app with a thread per concurrent request, or as many threads as the
+	// framework's own server starts if the concurrency is not set.
+	gunicornCommand = "exec gunicorn --bind :$PORT --workers 1 --threads ${FUNCTION_CONCURRENCY:-8} --timeout 0"
+	// pathModule serves the framework's app at the sub-path in env.FunctionPathLaunch.
+	pathModule = "function_path"
 )
 
 var (
@@ -53,6 +60,14 @@ func buildFn(ctx *gcp.Context) error {
 	if err := validateSource(ctx); err != nil {
 		return err
 	}
+	prefix, err := env.FunctionPathPrefix()
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	concurrency, err := env.FunctionConcurrencyHint()
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
 
 	// Check for syntax errors.
 	ctx.Exec([]string{"python3", "-m", "compileall", "-f", "-q", "."}, gcp.WithStdoutTail, gcp.WithUserAttribution)
@@ -66,6 +81,7 @@ func buildFn(ctx *gcp.Context) error {
 
 	// Install functions-framework.
 	l := ctx.Layer(layerName, gcp.LaunchLayer, gcp.BuildLayer)
+	var ffEnv []string
 	if hasFrameworkDependency {
 		ctx.Logf("Handling functions with dependency on functions-framework.")
 		ctx.ClearLayer(l)
@@ -73,16 +89,54 @@ func buildFn(ctx *gcp.Context) error {
 		ctx.Logf("Handling functions without dependency on functions-framework.")
 		cvt := filepath.Join(ctx.BuildpackRoot(), "converter")
 		req := filepath.Join(cvt, "requirements.txt")
-		if _, err := python.InstallRequirements(ctx, l, req); err != nil {
+		path, err := python.InstallRequirements(ctx, l, req)
+		if err != nil {
 			return fmt.Errorf("installing framework: %w", err)
 		}
+		// The layer is only on the paths of later buildpacks, so the conformance check needs it explicitly.
+		ffEnv = append(ffEnv,
+			"PYTHONPATH="+path+string(os.PathListSeparator)+os.Getenv("PYTHONPATH"),
+			"PATH="+filepath.Join(l.Path, "bin")+string(os.PathListSeparator)+os.Getenv("PATH"))
+	}
+
+	if err := clearsource.ClearTestSources(ctx); err != nil {
+		return err
+	}
+
+	// The wrapper module is copied into its own directory, so that only it is added to the module path.
+	pathDir := filepath.Join(l.Path, pathModule)
+	if prefix != "" {
+		ctx.MkdirAll(pathDir, 0755)
+		ctx.Exec([]string{"cp", filepath.Join(ctx.BuildpackRoot(), "converter", pathModule+".py"), pathDir}, gcp.WithUserTimingAttribution)
+		ctx.Logf("Serving the function at %s", prefix)
+	}
+	cmd := serveCommand(prefix != "", concurrency, pathDir)
+
+	if ok, err := env.Bool(env.FunctionsConformance, false); err != nil {
+		return gcp.UserErrorf("%v", err)
+	} else if ok {
+		if err := conformance.Check(ctx, cmd, ffEnv...); err != nil {
+			return err
+		}
 	}
 
 	ctx.SetFunctionsEnvVars(l)
-	ctx.AddWebProcess([]string{"functions-framework"})
+	ctx.AddWebProcess(cmd)
 	return nil
 }
 
+// serveCommand returns the command that serves the function: gunicorn if the function is served at a
+// sub-path, with the pathModule in pathDir, or with a set concurrency, and the framework otherwise.
+func serveCommand(hasPrefix bool, concurrency int, pathDir string) []string {
+	switch {
+	case hasPrefix:
+		return []string{"/bin/bash", "-c", gunicornCommand + " --pythonpath " + pathDir + " '" + pathModule + ":create_app()'"}
+	case concurrency > 0:
+		return []string{"/bin/bash", "-c", gunicornCommand + " 'functions_framework:create_app()'"}
+	}
+	return []string{"functions-framework"}
+}
+
 func validateSource(ctx *gcp.Context) error {
 	// Fail if the default|custom source file doesn't exist, otherwise the app will fail at runtime but still build here.
 	fnSource, ok := os.LookupEnv(env.FunctionSource)
@@ -15,6 +15,7 @@
 package main
 
 import (
+	"reflect"
 	"testing"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
@@ -92,3 +93,36 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestServeCommand(t *testing.T) {
+	testCases := []struct {
+		name        string
+		hasPrefix   bool
+		concurrency int
+		want        []string
+	}{
+		{
+			name: "framework",
+			want: []string{"functions-framework"},
+		},
+		{
+			name:        "concurrency",
+			concurrency: 80,
+			want:        []string{"/bin/bash", "-c", "exec gunicorn --bind :$PORT --workers 1 --threads ${FUNCTION_CONCURRENCY:-8} --timeout 0 'functions_framework:create_app()'"},
+		},
+		{
+			name:        "path prefix",
+			hasPrefix:   true,
+			concurrency: 80,
+			want:        []string{"/bin/bash", "-c", "exec gunicorn --bind :$PORT --workers 1 --threads ${FUNCTION_CONCURRENCY:-8} --timeout 0 --pythonpath /layers/ff/function_path 'function_path:create_app()'"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := serveCommand(tc.hasPrefix, tc.concurrency, "/layers/ff/function_path")
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("serveCommand(%t, %d) = %q, want %q", tc.hasPrefix, tc.concurrency, got, tc.want)
+			}
+		})
+	}
+}
@@ -13,14 +13,17 @@
 // limitations under the License.
 
 // Implements python/missing-entrypoint buildpack.
-// This buildpack's goal is to display a clear error message when
-// no entrypoint is defined on a Python application.
+// This buildpack's goal is to use the default entrypoint of the detected
+// framework, or display a clear error message when no entrypoint is defined
+// on a Python application.
 package main
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/frameworks"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 )
 
@@ -33,5 +36,18 @@ func detectFn(ctx *gcp.Context) error {
 }
 
 func buildFn(ctx *gcp.Context) error {
+	fw, err := frameworks.Detect(ctx, "python")
+	if err != nil {
+		return err
+	}
+	if fw != nil && len(fw.Command) > 0 {
+		ctx.Logf("Using the default entrypoint for %s", fw.Name)
+		fw.ConfigureLaunch(ctx, ctx.Layer("framework", gcp.LaunchLayer))
+		ctx.AddWebProcess(fw.Command)
+		return nil
+	}
+	if fw != nil && len(fw.Candidates) > 0 {
+		return gcp.UserErrorf("found multiple %s application objects: %s; choose one with %q env var or by creating a %q file", fw.Name, strings.Join(fw.Candidates, ", "), env.Entrypoint, "Procfile")
+	}
 	return fmt.Errorf("for Python, an entrypoint must be manually set, either with %q env var or by creating a %q file", env.Entrypoint, "Procfile")
 }
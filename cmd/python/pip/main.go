@@ -21,6 +21,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/depaudit"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/python"
 )
@@ -54,6 +55,9 @@ func buildFn(ctx *gcp.Context) error {
 	if err != nil {
 		return fmt.Errorf("installing dependencies: %w", err)
 	}
+	if err := depaudit.RecordPip(ctx, path); err != nil {
+		return err
+	}
 
 	ctx.Logf("Checking for incompatible dependencies.")
 	result, err := ctx.ExecWithErr([]string{"python3", "-m", "pip", "check"}, gcp.WithEnv("PYTHONPATH="+path+":"+os.Getenv("PYTHONPATH")), gcp.WithUserAttribution)
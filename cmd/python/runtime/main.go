@@ -19,13 +19,13 @@ package main
 import (
 	"fmt"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/warmcache"
 	"github.com/buildpacks/libcnb"
 )
 
@@ -35,7 +35,12 @@ const (
 	// TODO(b/148375706): Add mapping for stable/beta versions.
 	versionURL  = "https://storage.googleapis.com/gcp-buildpacks/python/latest.version"
 	versionFile = ".python-version"
-	versionKey  = "version"
+	// runtimeTxtFile declares the version on other platforms; .python-version takes precedence.
+	runtimeTxtFile = "runtime.txt"
+	versionKey     = "version"
+
+	// channelVersionURL holds the latest version of a channel other than runtime.ChannelStable.
+	channelVersionURL = "https://storage.googleapis.com/gcp-buildpacks/python/%s.version"
 )
 
 func main() {
@@ -52,12 +57,28 @@ func detectFn(ctx *gcp.Context) error {
 }
 
 func buildFn(ctx *gcp.Context) error {
-	version, err := runtimeVersion(ctx)
+	channel, err := runtime.Channel()
+	if err != nil {
+		return err
+	}
+	policy, err := runtime.PatchPolicy()
+	if err != nil {
+		return err
+	}
+	version, request, err := runtimeVersion(ctx, channel)
 	if err != nil {
 		return fmt.Errorf("determining runtime version: %w", err)
 	}
-
 	l := ctx.Layer(pythonLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayer)
+	version = runtime.ApplyPatchPolicy(ctx, l, "Python", policy, request, version)
+	if channel != runtime.ChannelStable {
+		runtime.UsePrerelease(ctx, "Python", channel, version)
+	}
+	if err := ctx.RecordFact(gcp.FactRuntimeVersion, version); err != nil {
+		return err
+	}
+
+	ctx.AddTool(l, "python", version)
 
 	// Check the metadata in the cache layer to determine if we need to proceed.
 	metaVersion := ctx.GetMetadata(l, versionKey)
@@ -74,11 +95,17 @@ func buildFn(ctx *gcp.Context) error {
 	}
 
 	ctx.Logf("Installing Python v%s", version)
-	command := fmt.Sprintf("curl --fail --show-error --silent --location --retry 3 %s | tar xz --directory %s", archiveURL, l.Path)
+	limitArgs, release := ctx.DownloadSlot(archiveURL)
+	command := fmt.Sprintf("%s | tar xz --directory %s", warmcache.DownloadCommand(archiveURL, limitArgs...), l.Path)
 	ctx.Exec([]string{"bash", "-c", command})
+	release()
+	path := filepath.Join(l.Path, "bin/python3")
+	// Importing ssl loads the shared libraries that pip needs to download packages.
+	if err := runtime.SmokeTest(ctx, "Python", []string{path, "-c", "import ssl; print(ssl.OPENSSL_VERSION)"}); err != nil {
+		return err
+	}
 
 	ctx.Logf("Upgrading pip to the latest version and installing build tools")
-	path := filepath.Join(l.Path, "bin/python3")
 	ctx.Exec([]string{path, "-m", "pip", "install", "--upgrade", "pip", "setuptools", "wheel"}, gcp.WithUserAttribution)
 
 	// Force stdout/stderr streams to be unbuffered so that log messages appear immediately in the logs.
@@ -93,22 +120,51 @@ func buildFn(ctx *gcp.Context) error {
 	return nil
 }
 
-func runtimeVersion(ctx *gcp.Context) (string, error) {
-	if v := os.Getenv(env.RuntimeVersion); v != "" {
-		ctx.Logf("Using runtime version from %s: %s", env.RuntimeVersion, v)
-		return v, nil
+// runtimeVersion returns the version of Python to install, and "latest" if it is the latest release
+// rather than a version requested exactly. Versions from channels other than runtime.ChannelStable
+// take precedence over the version file.
+func runtimeVersion(ctx *gcp.Context, channel string) (string, string, error) {
+	cv, err := ctx.ResolveConfig("Python version",
+		gcp.EnvConfig(env.RuntimeVersion),
+		runtime.ChannelConfig(channel),
+		gcp.FileConfig(versionFile),
+		gcp.ConfigSource{Name: runtimeTxtFile, Value: runtimeTxt},
+	)
+	if err != nil {
+		return "", "", err
 	}
-	if ctx.FileExists(versionFile) {
-		raw := ctx.ReadFile(versionFile)
-		v := strings.TrimSpace(string(raw))
-		if v != "" {
-			ctx.Logf("Using runtime version from %s: %s", versionFile, v)
-			return v, nil
+	switch cv.Source {
+	case env.RuntimeChannel:
+		// Intentionally no user-attributed becase the URL is provided by Google.
+		body, err := ctx.FetchMetadata(fmt.Sprintf(channelVersionURL, channel))
+		if err != nil {
+			return "", "", err
+		}
+		v := strings.TrimSpace(string(body))
+		ctx.Logf("Using latest %s runtime version: %s", channel, v)
+		return v, "", nil
+	case gcp.ConfigDefault:
+		// Intentionally no user-attributed becase the URL is provided by Google.
+		body, err := ctx.FetchMetadata(versionURL)
+		if err != nil {
+			return "", "", err
 		}
-		return "", gcp.UserErrorf("%s exists but does not specify a version", versionFile)
+		v := strings.TrimSpace(string(body))
+		ctx.Logf("Using latest runtime version: %s", v)
+		return v, "latest", nil
+	}
+	return cv.Value, "", nil
+}
+
+// runtimeTxt returns the version declared in runtime.txt, in the python-3.9.1 form of other platforms.
+func runtimeTxt(ctx *gcp.Context) (string, bool, error) {
+	path := filepath.Join(ctx.ApplicationRoot(), runtimeTxtFile)
+	if !ctx.FileExists(path) {
+		return "", false, nil
+	}
+	v := strings.TrimSpace(string(ctx.ReadFile(path)))
+	if !strings.HasPrefix(v, "python-") {
+		return "", false, gcp.UserErrorf("%s must declare a version of the form python-3.9.1, found %q", runtimeTxtFile, v)
 	}
-	// Intentionally no user-attributed becase the URL is provided by Google.
-	v := ctx.Exec([]string{"curl", "--fail", "--show-error", "--silent", "--location", versionURL}).Stdout
-	ctx.Logf("Using latest runtime version: %s", v)
-	return v, nil
+	return strings.TrimPrefix(v, "python-"), true, nil
 }
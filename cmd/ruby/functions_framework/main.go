@@ -23,6 +23,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/clearsource"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 )
@@ -70,6 +71,10 @@ func buildFn(ctx *gcp.Context) error {
 		ctx.Warnf("a deprecated version of functions_framework is in use; consider updating your Gemfile to use functions_framework %d.%d or later.", recommendMajor, recommendMinor)
 	}
 
+	if err := clearsource.ClearTestSources(ctx); err != nil {
+		return err
+	}
+
 	ctx.AddWebProcess([]string{"bundle", "exec", "functions-framework-ruby"})
 
 	return nil
@@ -17,9 +17,7 @@
 package main
 
 import (
-	"os"
 	"path/filepath"
-	"strconv"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
@@ -35,13 +33,10 @@ func main() {
 
 func detectFn(ctx *gcp.Context) error {
 	// Fail archiving source when users want to clear source from the final container.
-	if cs, ok := os.LookupEnv(env.ClearSource); ok {
-		c, err := strconv.ParseBool(cs)
-		if err != nil {
-			ctx.Warnf("Failed to parse %q: %v", env.ClearSource, err)
-		} else if c {
-			ctx.OptOut("%s is incompatible with archive source", env.ClearSource)
-		}
+	if c, err := env.Bool(env.ClearSource, false); err != nil {
+		ctx.Warnf("%v", err)
+	} else if c {
+		ctx.OptOut("%s is incompatible with archive source", env.ClearSource)
 	}
 	return nil
 }
@@ -0,0 +1,183 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements utils/config-render buildpack.
+// The config-render buildpack renders *.tmpl files in the application using allowlisted build env vars.
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	templateExt = ".tmpl"
+	// renderedLayer records the hashes of rendered files so that a file written by a previous build
+	// can be told apart from one that is part of the application source.
+	renderedLayer = "rendered"
+)
+
+var (
+	// placeholderRegexp matches ${NAME} placeholders. Bare $NAME is left untouched because it is
+	// meaningful in many configuration formats, e.g. nginx variables.
+	placeholderRegexp = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+	// skippedDirs are never searched for templates.
+	skippedDirs = map[string]bool{".git": true, "node_modules": true, "vendor": true}
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) error {
+	if os.Getenv(env.ConfigRenderEnv) == "" {
+		ctx.OptOut("%s not set", env.ConfigRenderEnv)
+	}
+	templates, err := findTemplates(ctx.ApplicationRoot())
+	if err != nil {
+		return err
+	}
+	if len(templates) == 0 {
+		ctx.OptOut("No *%s files found", templateExt)
+	}
+	return nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	allowed := allowedEnv(os.Getenv(env.ConfigRenderEnv))
+	l := ctx.Layer(renderedLayer, gcp.CacheLayer)
+
+	templates, err := findTemplates(ctx.ApplicationRoot())
+	if err != nil {
+		return err
+	}
+	for _, tmpl := range templates {
+		out := strings.TrimSuffix(tmpl, templateExt)
+		rel, err := filepath.Rel(ctx.ApplicationRoot(), out)
+		if err != nil {
+			return fmt.Errorf("getting relative path of %s: %w", out, err)
+		}
+		rendered, err := render(string(ctx.ReadFile(tmpl)), allowed)
+		if err != nil {
+			return gcp.UserErrorf("rendering %s%s: %v", rel, templateExt, err)
+		}
+		if ctx.FileExists(out) {
+			generated, err := isGenerated(out, ctx.GetMetadata(l, rel))
+			if err != nil {
+				return err
+			}
+			if !generated {
+				return gcp.UserErrorf("%s already exists and was not rendered from %s%s; remove it from the source or rename the template", rel, rel, templateExt)
+			}
+		}
+		info, err := os.Stat(tmpl)
+		if err != nil {
+			return fmt.Errorf("stat %s: %w", tmpl, err)
+		}
+		ctx.WriteFile(out, []byte(rendered), info.Mode().Perm())
+		ctx.SetMetadata(l, rel, contentHash([]byte(rendered)))
+		ctx.Logf("Rendered %s", out)
+	}
+	return nil
+}
+
+// isGenerated reports whether the file at path has the hash recorded when it was last rendered.
+func isGenerated(path, hash string) (bool, error) {
+	if hash == "" {
+		return false, nil
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return contentHash(content) == hash, nil
+}
+
+// contentHash returns the hex-encoded sha256 hash of content.
+func contentHash(content []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(content))
+}
+
+// allowedEnv returns the values of the env vars named in the comma-separated allowlist.
+func allowedEnv(allowlist string) map[string]string {
+	allowed := make(map[string]string)
+	for _, name := range strings.Split(allowlist, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if v, ok := os.LookupEnv(name); ok {
+			allowed[name] = v
+		}
+	}
+	return allowed
+}
+
+// findTemplates returns the paths of all template files under root.
+func findTemplates(root string) ([]string, error) {
+	var templates []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if skippedDirs[info.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(info.Name(), templateExt) && info.Name() != templateExt {
+			templates = append(templates, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("searching for templates in %s: %w", root, err)
+	}
+	return templates, nil
+}
+
+// render substitutes ${NAME} placeholders with allowed values. Placeholders referencing env vars
+// that are not allowlisted or not set are reported as an error rather than silently blanked.
+func render(content string, allowed map[string]string) (string, error) {
+	missing := make(map[string]bool)
+	rendered := placeholderRegexp.ReplaceAllStringFunc(content, func(m string) string {
+		name := placeholderRegexp.FindStringSubmatch(m)[1]
+		v, ok := allowed[name]
+		if !ok {
+			missing[name] = true
+			return m
+		}
+		return v
+	})
+	if len(missing) > 0 {
+		var names []string
+		for n := range missing {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("env vars %s are referenced but not set or not listed in %s", strings.Join(names, ", "), env.ConfigRenderEnv)
+	}
+	return rendered, nil
+}
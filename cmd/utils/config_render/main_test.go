@@ -0,0 +1,179 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		env   []string
+		want  int
+	}{
+		{
+			name: "with template",
+			files: map[string]string{
+				"nginx.conf.tmpl": "listen ${PORT};",
+			},
+			env:  []string{"GOOGLE_CONFIG_RENDER_ENV=PORT"},
+			want: 0,
+		},
+		{
+			name: "with nested template",
+			files: map[string]string{
+				"config/app.yaml.tmpl": "env: ${ENV}",
+			},
+			env:  []string{"GOOGLE_CONFIG_RENDER_ENV=ENV"},
+			want: 0,
+		},
+		{
+			name: "without env",
+			files: map[string]string{
+				"nginx.conf.tmpl": "listen ${PORT};",
+			},
+			want: 100,
+		},
+		{
+			name: "without template",
+			files: map[string]string{
+				"nginx.conf": "listen 8080;",
+			},
+			env:  []string{"GOOGLE_CONFIG_RENDER_ENV=PORT"},
+			want: 100,
+		},
+		{
+			name: "template in skipped dir",
+			files: map[string]string{
+				"node_modules/pkg/config.json.tmpl": "{}",
+				"vendor/pkg/config.yaml.tmpl":       "a: b",
+				".git/hooks/hook.tmpl":              "",
+			},
+			env:  []string{"GOOGLE_CONFIG_RENDER_ENV=PORT"},
+			want: 100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gcp.TestDetect(t, detectFn, tc.name, tc.files, tc.env, tc.want)
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		allowed map[string]string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "no placeholders",
+			content: "listen 8080;",
+			want:    "listen 8080;",
+		},
+		{
+			name:    "substitutes allowed",
+			content: "listen ${PORT};\nserver_name ${HOST};",
+			allowed: map[string]string{"PORT": "8080", "HOST": "example.com"},
+			want:    "listen 8080;\nserver_name example.com;",
+		},
+		{
+			name:    "keeps bare dollar variables",
+			content: "proxy_set_header Host $host; listen ${PORT};",
+			allowed: map[string]string{"PORT": "8080"},
+			want:    "proxy_set_header Host $host; listen 8080;",
+		},
+		{
+			name:    "substitutes empty value",
+			content: "prefix=${PREFIX}",
+			allowed: map[string]string{"PREFIX": ""},
+			want:    "prefix=",
+		},
+		{
+			name:    "not allowed",
+			content: "secret=${SECRET}",
+			allowed: map[string]string{"PORT": "8080"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := render(tc.content, tc.allowed)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("render() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("render() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIsGenerated(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		hash    string
+		want    bool
+	}{
+		{
+			name:    "no recorded hash",
+			content: "listen 8080;",
+			want:    false,
+		},
+		{
+			name:    "matching hash",
+			content: "listen 8080;",
+			hash:    contentHash([]byte("listen 8080;")),
+			want:    true,
+		},
+		{
+			name:    "modified since rendered",
+			content: "listen 9090;",
+			hash:    contentHash([]byte("listen 8080;")),
+			want:    false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "config-render-")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "nginx.conf")
+			if err := ioutil.WriteFile(path, []byte(tc.content), 0644); err != nil {
+				t.Fatalf("writing %s: %v", path, err)
+			}
+
+			got, err := isGenerated(path, tc.hash)
+			if err != nil {
+				t.Fatalf("isGenerated() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("isGenerated() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
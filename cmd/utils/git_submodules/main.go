@@ -0,0 +1,157 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements utils/git-submodules buildpack.
+// The git-submodules buildpack fetches Git submodules that are declared in .gitmodules but missing from the source.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	gitModulesFile = ".gitmodules"
+
+	// credentialsBindingType is the binding type providing Git credentials, in git-credential format under the credentialsKey key.
+	credentialsBindingType = "git-credentials"
+	credentialsKey         = "credentials"
+)
+
+var (
+	sectionRegexp = regexp.MustCompile(`^\[submodule\s+"([^"]+)"\]$`)
+	keyRegexp     = regexp.MustCompile(`^(\w+)\s*=\s*(.*)$`)
+)
+
+type submodule struct {
+	name string
+	path string
+	url  string
+}
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) error {
+	if !ctx.FileExists(gitModulesFile) {
+		ctx.OptOut("%s not found", gitModulesFile)
+	}
+	return nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	submodules, err := parseGitModules(ctx.ReadFile(gitModulesFile))
+	if err != nil {
+		return gcp.UserErrorf("parsing %s: %v", gitModulesFile, err)
+	}
+	missing, err := missingSubmodules(ctx.ApplicationRoot(), submodules)
+	if err != nil {
+		return fmt.Errorf("checking submodules: %w", err)
+	}
+	if len(missing) == 0 {
+		ctx.Logf("All %d submodule(s) declared in %s are present.", len(submodules), gitModulesFile)
+		return nil
+	}
+
+	var paths []string
+	for _, s := range missing {
+		paths = append(paths, s.path)
+	}
+	creds, ok := gitCredentials(ctx.Bindings())
+	if !ok {
+		return gcp.UserErrorf("submodule(s) %s declared in %s are missing from the source. Run `git submodule update --init --recursive` before deploying, or provide a binding of type %q so they can be fetched during the build", strings.Join(paths, ", "), gitModulesFile, credentialsBindingType)
+	}
+	if !ctx.FileExists(".git") {
+		return gcp.UserErrorf("submodule(s) %s declared in %s are missing from the source, and they cannot be fetched because the source does not include the .git directory that pins their commits. Run `git submodule update --init --recursive` before deploying", strings.Join(paths, ", "), gitModulesFile)
+	}
+
+	ctx.Logf("Fetching missing submodule(s): %s", strings.Join(paths, ", "))
+	helper := fmt.Sprintf(`credential.helper=!f() { test "$1" = get && cat %q; }; f`, creds)
+	cmd := []string{"git", "-c", helper, "submodule", "update", "--init", "--recursive", "--depth", "1", "--"}
+	cmd = append(cmd, paths...)
+	ctx.Exec(cmd, gcp.WithEnv("GIT_TERMINAL_PROMPT=0"), gcp.WithTransientRetry, gcp.WithUserAttribution)
+	return nil
+}
+
+// parseGitModules returns the submodules declared in a .gitmodules file.
+func parseGitModules(content []byte) ([]submodule, error) {
+	var submodules []submodule
+	var current *submodule
+	s := bufio.NewScanner(bytes.NewReader(content))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if m := sectionRegexp.FindStringSubmatch(line); m != nil {
+			submodules = append(submodules, submodule{name: m[1]})
+			current = &submodules[len(submodules)-1]
+			continue
+		}
+		m := keyRegexp.FindStringSubmatch(line)
+		if m == nil || current == nil {
+			return nil, fmt.Errorf("unexpected line %q", line)
+		}
+		switch m[1] {
+		case "path":
+			current.path = m[2]
+		case "url":
+			current.url = m[2]
+		}
+	}
+	for _, sm := range submodules {
+		if sm.path == "" {
+			return nil, fmt.Errorf("submodule %q has no path", sm.name)
+		}
+	}
+	return submodules, s.Err()
+}
+
+// missingSubmodules returns the submodules whose path under dir does not exist or is empty.
+func missingSubmodules(dir string, submodules []submodule) ([]submodule, error) {
+	var missing []submodule
+	for _, sm := range submodules {
+		files, err := ioutil.ReadDir(filepath.Join(dir, sm.path))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if len(files) == 0 {
+			missing = append(missing, sm)
+		}
+	}
+	return missing, nil
+}
+
+// gitCredentials returns the path of the credentials file provided by a git-credentials binding.
+func gitCredentials(bindings libcnb.Bindings) (string, bool) {
+	for _, b := range bindings {
+		if b.Type != credentialsBindingType {
+			continue
+		}
+		if _, ok := b.Secret[credentialsKey]; ok {
+			return filepath.Join(b.Path, credentialsKey), true
+		}
+	}
+	return "", false
+}
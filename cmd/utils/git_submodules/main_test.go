@@ -0,0 +1,145 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  int
+	}{
+		{
+			name: "with .gitmodules",
+			files: map[string]string{
+				".gitmodules": "[submodule \"lib\"]\n\tpath = lib\n\turl = https://example.com/lib.git\n",
+			},
+			want: 0,
+		},
+		{
+			name: "without .gitmodules",
+			files: map[string]string{
+				"main.go": "",
+			},
+			want: 100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gcp.TestDetect(t, detectFn, tc.name, tc.files, []string{}, tc.want)
+		})
+	}
+}
+
+func TestParseGitModules(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		want    []submodule
+		wantErr bool
+	}{
+		{
+			name: "multiple submodules",
+			content: `# comment
+[submodule "lib"]
+	path = third_party/lib
+	url = https://example.com/lib.git
+[submodule "docs"]
+	path = docs
+	url = git@example.com:docs.git
+	branch = main
+`,
+			want: []submodule{
+				{name: "lib", path: "third_party/lib", url: "https://example.com/lib.git"},
+				{name: "docs", path: "docs", url: "git@example.com:docs.git"},
+			},
+		},
+		{
+			name: "empty",
+		},
+		{
+			name:    "key outside section",
+			content: "path = lib\n",
+			wantErr: true,
+		},
+		{
+			name:    "missing path",
+			content: "[submodule \"lib\"]\n\turl = https://example.com/lib.git\n",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseGitModules([]byte(tc.content))
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("parseGitModules() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if !tc.wantErr && !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parseGitModules() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMissingSubmodules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "submodules")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.MkdirAll(filepath.Join(dir, "empty"), 0755); err != nil {
+		t.Fatalf("creating dir: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "present"), 0755); err != nil {
+		t.Fatalf("creating dir: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "present", "README"), []byte{}, 0644); err != nil {
+		t.Fatalf("writing file: %v", err)
+	}
+
+	submodules := []submodule{{name: "a", path: "present"}, {name: "b", path: "empty"}, {name: "c", path: "absent"}}
+	got, err := missingSubmodules(dir, submodules)
+	if err != nil {
+		t.Fatalf("missingSubmodules() returned error: %v", err)
+	}
+	want := []submodule{{name: "b", path: "empty"}, {name: "c", path: "absent"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("missingSubmodules() = %+v, want %+v", got, want)
+	}
+}
+
+func TestGitCredentials(t *testing.T) {
+	bindings := libcnb.Bindings{
+		{Name: "maven", Type: "maven", Path: "/platform/bindings/maven", Secret: map[string]string{"settings.xml": ""}},
+		{Name: "git", Type: "git-credentials", Path: "/platform/bindings/git", Secret: map[string]string{"credentials": ""}},
+	}
+	got, ok := gitCredentials(bindings)
+	if !ok || got != "/platform/bindings/git/credentials" {
+		t.Errorf("gitCredentials() = %q, %t, want %q, true", got, ok, "/platform/bindings/git/credentials")
+	}
+	if _, ok := gitCredentials(bindings[:1]); ok {
+		t.Errorf("gitCredentials() without git-credentials binding returned ok")
+	}
+}
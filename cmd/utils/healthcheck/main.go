@@ -0,0 +1,90 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements utils/healthcheck buildpack.
+// The healthcheck buildpack installs a command that checks that the application serves HTTP, for
+// platforms that run command healthchecks rather than HTTP probes.
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// binary is the name of the healthcheck command, which is packaged next to the buildpack binary.
+	binary = "healthcheck"
+	// processType is the type of the process that runs the healthcheck command.
+	processType = "healthcheck"
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) error {
+	if _, ok := os.LookupEnv(env.HealthcheckPath); !ok {
+		ctx.OptOut("%s not set", env.HealthcheckPath)
+	}
+	return nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	launchEnv, err := healthcheckEnv()
+	if err != nil {
+		return err
+	}
+
+	l := ctx.Layer("healthcheck", gcp.LaunchLayer)
+	bin := filepath.Join(l.Path, "bin")
+	ctx.MkdirAll(bin, 0755)
+	ctx.Exec([]string{"cp", filepath.Join(ctx.BuildpackRoot(), "bin", binary), bin})
+	for k, v := range launchEnv {
+		// Defaults allow the values to be changed when the container is run.
+		l.LaunchEnvironment.Default(k, v)
+	}
+	cmd := filepath.Join(bin, binary)
+	ctx.AddProcess(processType, []string{cmd})
+	ctx.Logf("Added the %s process, which requests %s", processType, launchEnv[env.HealthcheckPathLaunch])
+	return nil
+}
+
+// healthcheckEnv validates the healthcheck configuration and returns the launch env vars read by the
+// healthcheck command.
+func healthcheckEnv() (map[string]string, error) {
+	path := os.Getenv(env.HealthcheckPath)
+	if !strings.HasPrefix(path, "/") {
+		return nil, gcp.UserErrorf("%s must be an absolute URL path such as /healthz, got %q", env.HealthcheckPath, path)
+	}
+	e := map[string]string{env.HealthcheckPathLaunch: path}
+	if v := os.Getenv(env.HealthcheckPort); v != "" {
+		if port, err := strconv.Atoi(v); err != nil || port < 1 || port > 65535 {
+			return nil, gcp.UserErrorf("%s must be a port number, got %q", env.HealthcheckPort, v)
+		}
+		e[env.HealthcheckPortLaunch] = v
+	}
+	if v := os.Getenv(env.HealthcheckTimeout); v != "" {
+		if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+			return nil, gcp.UserErrorf("%s must be a positive duration such as 5s, got %q", env.HealthcheckTimeout, v)
+		}
+		e[env.HealthcheckTimeoutLaunch] = v
+	}
+	return e, nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name string
+		env  []string
+		want int
+	}{
+		{
+			name: "with path",
+			env:  []string{"GOOGLE_HEALTHCHECK_PATH=/healthz"},
+			want: 0,
+		},
+		{
+			name: "without path",
+			env:  []string{},
+			want: 100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gcp.TestDetect(t, detectFn, tc.name, map[string]string{}, tc.env, tc.want)
+		})
+	}
+}
+
+func TestHealthcheckEnv(t *testing.T) {
+	testCases := []struct {
+		name    string
+		env     map[string]string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "path",
+			env:  map[string]string{env.HealthcheckPath: "/healthz"},
+			want: map[string]string{env.HealthcheckPathLaunch: "/healthz"},
+		},
+		{
+			name: "all options",
+			env:  map[string]string{env.HealthcheckPath: "/", env.HealthcheckPort: "8081", env.HealthcheckTimeout: "5s"},
+			want: map[string]string{env.HealthcheckPathLaunch: "/", env.HealthcheckPortLaunch: "8081", env.HealthcheckTimeoutLaunch: "5s"},
+		},
+		{
+			name:    "relative path",
+			env:     map[string]string{env.HealthcheckPath: "healthz"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid port",
+			env:     map[string]string{env.HealthcheckPath: "/", env.HealthcheckPort: "http"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid timeout",
+			env:     map[string]string{env.HealthcheckPath: "/", env.HealthcheckTimeout: "-1s"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, k := range []string{env.HealthcheckPath, env.HealthcheckPort, env.HealthcheckTimeout} {
+				os.Unsetenv(k)
+			}
+			for k, v := range tc.env {
+				os.Setenv(k, v)
+				defer os.Unsetenv(k)
+			}
+
+			got, err := healthcheckEnv()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("healthcheckEnv() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if !tc.wantErr && !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("healthcheckEnv() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
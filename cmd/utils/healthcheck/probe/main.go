@@ -0,0 +1,88 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The healthcheck binary requests the application's healthcheck path on localhost and exits with a
+// non-zero status unless the response is successful. It is installed by the healthcheck buildpack and
+// configured at launch with the HEALTHCHECK_PATH, HEALTHCHECK_PORT and HEALTHCHECK_TIMEOUT env vars.
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+const (
+	defaultPort    = "8080"
+	defaultTimeout = time.Second
+)
+
+func main() {
+	url, timeout, err := config(os.Getenv)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "healthcheck: %v\n", err)
+		os.Exit(2)
+	}
+	if err := check(url, timeout); err != nil {
+		fmt.Fprintf(os.Stderr, "healthcheck: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// config returns the URL to request and how long to wait for the response.
+func config(getenv func(string) string) (string, time.Duration, error) {
+	path := getenv(env.HealthcheckPathLaunch)
+	if path == "" {
+		path = "/"
+	}
+	port := getenv(env.HealthcheckPortLaunch)
+	if port == "" {
+		port = getenv("PORT")
+	}
+	if port == "" {
+		port = defaultPort
+	}
+	timeout := defaultTimeout
+	if v := getenv(env.HealthcheckTimeoutLaunch); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return "", 0, fmt.Errorf("parsing %s: %v", env.HealthcheckTimeoutLaunch, err)
+		}
+		timeout = d
+	}
+	return fmt.Sprintf("http://127.0.0.1:%s%s", port, path), timeout, nil
+}
+
+// check requests url and returns an error unless the response status is 2xx or 3xx. Redirects are
+// not followed, as they often lead to other hosts.
+func check(url string, timeout time.Duration) error {
+	client := http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("GET %s returned status %d", url, resp.StatusCode)
+	}
+	return nil
+}
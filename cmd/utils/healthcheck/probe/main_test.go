@@ -0,0 +1,98 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestConfig(t *testing.T) {
+	testCases := []struct {
+		name        string
+		env         map[string]string
+		wantURL     string
+		wantTimeout time.Duration
+		wantErr     bool
+	}{
+		{
+			name:        "defaults",
+			wantURL:     "http://127.0.0.1:8080/",
+			wantTimeout: time.Second,
+		},
+		{
+			name:        "PORT",
+			env:         map[string]string{"PORT": "9000", "HEALTHCHECK_PATH": "/healthz"},
+			wantURL:     "http://127.0.0.1:9000/healthz",
+			wantTimeout: time.Second,
+		},
+		{
+			name:        "healthcheck port and timeout",
+			env:         map[string]string{"PORT": "9000", "HEALTHCHECK_PORT": "8081", "HEALTHCHECK_TIMEOUT": "3s"},
+			wantURL:     "http://127.0.0.1:8081/",
+			wantTimeout: 3 * time.Second,
+		},
+		{
+			name:    "invalid timeout",
+			env:     map[string]string{"HEALTHCHECK_TIMEOUT": "3"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			url, timeout, err := config(func(k string) string { return tc.env[k] })
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("config() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if url != tc.wantURL || timeout != tc.wantTimeout {
+				t.Errorf("config() = %q, %v, want %q, %v", url, timeout, tc.wantURL, tc.wantTimeout)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		name    string
+		status  int
+		delay   time.Duration
+		wantErr bool
+	}{
+		{name: "ok", status: http.StatusOK},
+		{name: "redirect", status: http.StatusFound},
+		{name: "server error", status: http.StatusServiceUnavailable, wantErr: true},
+		{name: "not found", status: http.StatusNotFound, wantErr: true},
+		{name: "timeout", status: http.StatusOK, delay: time.Second, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(tc.delay)
+				if tc.status == http.StatusFound {
+					w.Header().Set("Location", "https://example.com/")
+				}
+				w.WriteHeader(tc.status)
+			}))
+			defer srv.Close()
+
+			err := check(srv.URL+"/healthz", 100*time.Millisecond)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("check() got error: %v, want error: %t", err, tc.wantErr)
+			}
+		})
+	}
+}
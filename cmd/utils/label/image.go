@@ -0,0 +1,133 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// runAsUserLabel, runAsGroupLabel and runAsNonRootLabel let platforms run the container as the
+	// user, for example with the runAsUser, runAsGroup and runAsNonRoot security context fields of
+	// Kubernetes. They are the labels that language buildpacks set for their own non-root modes, which
+	// env.ImageUser overrides, as this buildpack runs last.
+	runAsUserLabel    = "run_as_user"
+	runAsGroupLabel   = "run_as_group"
+	runAsNonRootLabel = "run_as_non_root"
+	// workdirLabel lets platforms set the working directory of the container, as the working directory
+	// of processes cannot be set in launch metadata.
+	workdirLabel = "workdir"
+)
+
+// imageUser is a user of the run image. It is identified by numeric IDs, as user names cannot be
+// resolved in the run image at build time.
+type imageUser struct {
+	uid int
+	// gid is -1 if the group is not set, in which case the primary group of the user applies.
+	gid int
+}
+
+// parseImageUser parses v, the value of env.ImageUser, in the form UID or UID:GID.
+func parseImageUser(v string) (imageUser, error) {
+	parts := strings.SplitN(v, ":", 2)
+	u := imageUser{gid: -1}
+	var err error
+	if u.uid, err = parseID(parts[0]); err == nil && len(parts) == 2 {
+		u.gid, err = parseID(parts[1])
+	}
+	if err != nil {
+		return imageUser{}, gcp.UserErrorf("invalid %s %q, must be a numeric user ID such as 65532 or a user and group ID such as 65532:65532; user names cannot be resolved in the run image at build time", env.ImageUser, v)
+	}
+	return u, nil
+}
+
+func parseID(s string) (int, error) {
+	id, err := strconv.Atoi(s)
+	if err == nil && id < 0 {
+		err = strconv.ErrRange
+	}
+	return id, err
+}
+
+// configureImage validates env.ImageUser and env.ImageWorkdir and records them in the image labels.
+func configureImage(ctx *gcp.Context) error {
+	if v := os.Getenv(env.ImageUser); v != "" {
+		u, err := parseImageUser(v)
+		if err != nil {
+			return err
+		}
+		if err := checkUser(ctx, u); err != nil {
+			return err
+		}
+		ctx.AddLabel(runAsUserLabel, strconv.Itoa(u.uid))
+		if u.gid >= 0 {
+			ctx.AddLabel(runAsGroupLabel, strconv.Itoa(u.gid))
+		}
+		ctx.AddLabel(runAsNonRootLabel, strconv.FormatBool(u.uid != 0))
+		ctx.Logf("Configured the image to run as %s", v)
+	}
+	if v := os.Getenv(env.ImageWorkdir); v != "" {
+		workdir, err := checkWorkdir(ctx, v)
+		if err != nil {
+			return err
+		}
+		ctx.AddLabel(workdirLabel, workdir)
+		ctx.Logf("Configured the image to run in %s", workdir)
+	}
+	return nil
+}
+
+// checkUser fails if the user cannot run the app on the run image. The application directory is
+// owned by the user of the run image, CNB_USER_ID, so other users must be able to read it.
+func checkUser(ctx *gcp.Context, u imageUser) error {
+	if u.uid == 0 {
+		ctx.Warnf("%s runs the image as root; prefer a non-root user, such as the user of the run image", env.ImageUser)
+	}
+	if cnbUID := os.Getenv("CNB_USER_ID"); cnbUID == "" || cnbUID == strconv.Itoa(u.uid) || u.uid == 0 {
+		return nil
+	}
+	fi, err := os.Stat(ctx.ApplicationRoot())
+	if err != nil {
+		return gcp.InternalErrorf("reading the application directory: %v", err)
+	}
+	if fi.Mode().Perm()&0005 != 0005 {
+		return gcp.UserErrorf("%s=%d cannot read the application directory %s, which is owned by the user of the run image, %s, with mode %v; use that user or make the application directory readable by all users", env.ImageUser, u.uid, ctx.ApplicationRoot(), os.Getenv("CNB_USER_ID"), fi.Mode().Perm())
+	}
+	return nil
+}
+
+// checkWorkdir returns the clean absolute path of v, the value of env.ImageWorkdir, and fails unless
+// it is the application directory or a directory below it: the run image only holds the
+// application directory and the layers, whose contents buildpacks may change between builds.
+func checkWorkdir(ctx *gcp.Context, v string) (string, error) {
+	if !filepath.IsAbs(v) {
+		return "", gcp.UserErrorf("invalid %s %q, must be an absolute path", env.ImageWorkdir, v)
+	}
+	workdir := filepath.Clean(v)
+	root := filepath.Clean(ctx.ApplicationRoot())
+	if workdir != root && !strings.HasPrefix(workdir, root+string(filepath.Separator)) {
+		return "", gcp.UserErrorf("invalid %s %q, must be the application directory %s or a directory below it", env.ImageWorkdir, v, root)
+	}
+	if fi, err := os.Stat(workdir); err != nil || !fi.IsDir() {
+		return "", gcp.UserErrorf("invalid %s %q, the directory does not exist in the application", env.ImageWorkdir, v)
+	}
+	return workdir, nil
+}
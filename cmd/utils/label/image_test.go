@@ -0,0 +1,171 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runner"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestParseImageUser(t *testing.T) {
+	testCases := []struct {
+		value   string
+		want    imageUser
+		wantErr bool
+	}{
+		{value: "65532", want: imageUser{uid: 65532, gid: -1}},
+		{value: "1000:1001", want: imageUser{uid: 1000, gid: 1001}},
+		{value: "0", want: imageUser{uid: 0, gid: -1}},
+		{value: "nonroot", wantErr: true},
+		{value: "1000:", wantErr: true},
+		{value: "-1", wantErr: true},
+		{value: "1000:staff", wantErr: true},
+	}
+	for _, tc := range testCases {
+		got, err := parseImageUser(tc.value)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("parseImageUser(%q) got error: %v, want error: %t", tc.value, err, tc.wantErr)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("parseImageUser(%q) = %+v, want %+v", tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestConfigureImage(t *testing.T) {
+	testCases := []struct {
+		name    string
+		env     map[string]string
+		appMode os.FileMode
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "unset",
+			want: map[string]string{},
+		},
+		{
+			name: "user of the run image",
+			env:  map[string]string{env.ImageUser: "1000:1000"},
+			want: map[string]string{"google.run-as-user": "1000", "google.run-as-group": "1000", "google.run-as-non-root": "true"},
+		},
+		{
+			name: "other user with readable application",
+			env:  map[string]string{env.ImageUser: "65532"},
+			want: map[string]string{"google.run-as-user": "65532", "google.run-as-non-root": "true"},
+		},
+		{
+			name:    "other user with private application",
+			env:     map[string]string{env.ImageUser: "65532"},
+			appMode: 0750,
+			wantErr: true,
+		},
+		{
+			name: "root",
+			env:  map[string]string{env.ImageUser: "0"},
+			want: map[string]string{"google.run-as-user": "0", "google.run-as-non-root": "false"},
+		},
+		{
+			name: "workdir",
+			env:  map[string]string{env.ImageWorkdir: "APP/sub/"},
+			want: map[string]string{"google.workdir": "APP/sub"},
+		},
+		{
+			name:    "relative workdir",
+			env:     map[string]string{env.ImageWorkdir: "sub"},
+			wantErr: true,
+		},
+		{
+			name:    "workdir outside the application",
+			env:     map[string]string{env.ImageWorkdir: "/usr/local"},
+			wantErr: true,
+		},
+		{
+			name:    "missing workdir",
+			env:     map[string]string{env.ImageWorkdir: "APP/missing"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			app, err := ioutil.TempDir("", "app")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(app)
+			if err := os.Mkdir(filepath.Join(app, "sub"), 0755); err != nil {
+				t.Fatalf("creating app dir: %v", err)
+			}
+			mode := tc.appMode
+			if mode == 0 {
+				mode = 0755
+			}
+			if err := os.Chmod(app, mode); err != nil {
+				t.Fatalf("changing app mode: %v", err)
+			}
+			layers, err := ioutil.TempDir("", "layers")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(layers)
+			vars := map[string]string{"CNB_USER_ID": "1000", env.ImageUser: "", env.ImageWorkdir: ""}
+			for k, v := range tc.env {
+				vars[k] = strings.Replace(v, "APP", app, 1)
+			}
+			for k, v := range vars {
+				defer os.Setenv(k, os.Getenv(k))
+				os.Setenv(k, v)
+			}
+
+			result, err := runner.Build(runner.Config{
+				Buildpack:       libcnb.BuildpackInfo{ID: "google.utils.label", Version: "0.0.1"},
+				ApplicationRoot: app,
+				LayersRoot:      layers,
+				Logger:          log.New(ioutil.Discard, "", 0),
+			}, func(ctx *gcp.Context) error {
+				return configureImage(ctx)
+			})
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("configureImage() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+
+			got := map[string]string{}
+			for _, l := range result.Labels {
+				got[l.Key] = l.Value
+			}
+			want := map[string]string{}
+			for k, v := range tc.want {
+				want[k] = strings.Replace(v, "APP", app, 1)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("configureImage() labels = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -46,5 +46,9 @@ func buildFn(ctx *gcp.Context) error {
 		}
 		ctx.AddLabel(key, value)
 	}
-	return nil
+	if err := configureImage(ctx); err != nil {
+		return err
+	}
+	// This buildpack runs last, after every fact has been recorded.
+	return ctx.CheckAssertions()
 }
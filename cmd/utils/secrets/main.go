@@ -0,0 +1,43 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements utils/secrets buildpack.
+// The secrets buildpack installs an exec.d executable that resolves Secret Manager references in env vars at launch.
+package main
+
+import (
+	"path/filepath"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	layerName = "secrets"
+	// resolver is the exec.d executable shipped alongside the buildpack binary.
+	resolver = "resolve-secrets"
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) error {
+	// Secret references are usually only set at deploy time, so always opt in.
+	return nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	l := ctx.Layer(layerName, gcp.LaunchLayer)
+	return ctx.AddExecD(l, filepath.Join(ctx.BuildpackRoot(), "bin", resolver))
+}
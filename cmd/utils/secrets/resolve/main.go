@@ -0,0 +1,44 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements the exec.d executable that replaces Secret Manager references in the launch environment with their plaintext.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/BurntSushi/toml"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/secretmanager"
+)
+
+// envFD is the file descriptor on which the launcher reads env vars to set, as TOML.
+const envFD = 3
+
+func main() {
+	refs, err := secretmanager.References(os.Environ())
+	if err != nil {
+		log.Fatalf("Refusing to start: %v", err)
+	}
+	if len(refs) == 0 {
+		return
+	}
+	values, err := secretmanager.NewClient().Resolve(refs)
+	if err != nil {
+		log.Fatalf("Refusing to start: %v", err)
+	}
+	if err := toml.NewEncoder(os.NewFile(envFD, "env")).Encode(values); err != nil {
+		log.Fatalf("Writing resolved secrets: %v", err)
+	}
+}
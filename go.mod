@@ -8,4 +8,5 @@ require (
 	github.com/blang/semver v3.5.2-0.20180723201105-3c1074078d32+incompatible
 	github.com/buildpacks/libcnb v1.15.2
 	github.com/google/go-licenses v0.0.0-20200602185517-f29a4c695c3d // indirect
+	gopkg.in/yaml.v2 v2.3.0
 )
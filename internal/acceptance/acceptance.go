@@ -21,6 +21,7 @@ package acceptance
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/json"
 	"flag"
@@ -49,6 +50,8 @@ const (
 	cacheHitMessage = "***** CACHE HIT:"
 	// cacheMissMessage is emitted by ctx.CacheMiss(). Must match gcpbuildpack value.
 	cacheMissMessage = "***** CACHE MISS:"
+	// startFailureTimeout is how long a container that must fail to start may run.
+	startFailureTimeout = 60 * time.Second
 )
 
 var (
@@ -128,6 +131,8 @@ type Test struct {
 	MustMatch string
 	// RunEnv specifies run environment variables as KEY=VALUE strings.
 	RunEnv []string
+	// RunArgs specifies additional arguments to docker run, such as resource limits.
+	RunArgs []string
 	// SkipCacheTest skips testing of cached builds for this test case.
 	SkipCacheTest bool
 	// MustUse specifies the IDs of the buildpacks that must be used during the build.
@@ -272,11 +277,83 @@ func TestBuildFailure(t *testing.T, builder string, cfg FailureTest) {
 	}
 }
 
+// StartFailureTest describes a test of an application that builds but must fail to start.
+type StartFailureTest struct {
+	// Name specifies the name of the application, if not provided App will be used.
+	Name string
+	// App specifies the path to the application in testdata.
+	App string
+	// Env specifies build environment variables as KEY=VALUE strings.
+	Env []string
+	// RunEnv specifies run environment variables as KEY=VALUE strings.
+	RunEnv []string
+	// MustMatch specifies a regexp that must match the output of the container.
+	MustMatch string
+}
+
+// TestStartFailure builds an application and ensures that its container exits with a non-zero exit
+// code and output matching MustMatch, as it does when an exec.d executable refuses to start it.
+func TestStartFailure(t *testing.T, builder string, cfg StartFailureTest) {
+	t.Helper()
+
+	env := envSliceAsMap(t, cfg.Env)
+	env["GOOGLE_DEBUG"] = "true"
+
+	if cfg.Name == "" {
+		cfg.Name = cfg.App
+	}
+	image := fmt.Sprintf("%s-%s", strings.ToLower(specialChars.ReplaceAllString(cfg.Name, "-")), builder)
+
+	// Delete the docker image and volumes created by pack during the build.
+	defer func() {
+		cleanUpVolumes(t, image)
+		cleanUpImage(t, image)
+	}()
+
+	buildApp(t, filepath.Join(testData, cfg.App), image, builder, env, false, Test{})
+
+	name := fmt.Sprintf("%s-%s", image, randString(8))
+	command := []string{"docker", "run", "--name", name}
+	for _, e := range cfg.RunEnv {
+		command = append(command, "--env", e)
+	}
+	if cloudbuild {
+		command = append(command, "--network=cloudbuild")
+	}
+	command = append(command, image)
+	defer func() {
+		if _, err := runOutput("docker", "rm", "-f", name); err != nil {
+			t.Logf("Failed to clean up container: %v", err)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), startFailureTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, command[0], command[1:]...).CombinedOutput()
+	if ctx.Err() != nil {
+		t.Fatalf("Container is still running after %v, but should have failed to start; output:\n%s", startFailureTimeout, out)
+	}
+	if err == nil {
+		t.Fatalf("Container exited successfully, but should have failed to start; output:\n%s", out)
+	}
+	if _, ok := err.(*exec.ExitError); !ok {
+		t.Fatalf("Running container %q: %v", command, err)
+	}
+
+	r, err := regexp.Compile(cfg.MustMatch)
+	if err != nil {
+		t.Fatalf("regexp %q failed to compile: %v", cfg.MustMatch, err)
+	}
+	if !r.Match(out) {
+		t.Errorf("Expected regexp %q not found in container output:\n%s", r, out)
+	}
+}
+
 // invokeApp performs an HTTP GET on the app.
 func invokeApp(t *testing.T, cfg Test, image string, cache bool) {
 	t.Helper()
 
-	containerID, host, port, cleanup := startContainer(t, image, cfg.RunEnv, cache)
+	containerID, host, port, cleanup := startContainer(t, image, cfg.RunEnv, cfg.RunArgs, cache)
 	defer cleanup()
 
 	// Check that the application responds with `PASS`.
@@ -816,7 +893,7 @@ func verifyBuildMetadata(t *testing.T, image string, mustUse, mustNotUse []strin
 
 // startContainer starts a container for the given app and exposes port 8080.
 // The function returns the containerID, the host and port at which the app is reachable and a cleanup function.
-func startContainer(t *testing.T, image string, env []string, cache bool) (string, string, int, func()) {
+func startContainer(t *testing.T, image string, env, args []string, cache bool) (string, string, int, func()) {
 	t.Helper()
 
 	// Start docker container and get its id.
@@ -824,6 +901,7 @@ func startContainer(t *testing.T, image string, env []string, cache bool) (strin
 	for _, e := range env {
 		command = append(command, "--env", e)
 	}
+	command = append(command, args...)
 	if cloudbuild {
 		command = append(command, "--network=cloudbuild")
 	}
@@ -0,0 +1,154 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package appconfig parses and validates configuration schemas declared by applications.
+package appconfig
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// SchemaFile is the name of the configuration schema file at the application root.
+	SchemaFile = "app.config.yaml"
+
+	// SchemaPathEnv is a launch-time environment variable that holds the path to the recorded schema.
+	SchemaPathEnv = "GOOGLE_INTERNAL_CONFIG_SCHEMA"
+)
+
+// supportedTypes maps each supported variable type to a function that validates a value of that type.
+var supportedTypes = map[string]func(string) error{
+	"string": func(string) error { return nil },
+	"int": func(v string) error {
+		_, err := strconv.ParseInt(v, 10, 64)
+		return err
+	},
+	"float": func(v string) error {
+		_, err := strconv.ParseFloat(v, 64)
+		return err
+	},
+	"bool": func(v string) error {
+		_, err := strconv.ParseBool(v)
+		return err
+	},
+	"url": func(v string) error {
+		u, err := url.Parse(v)
+		if err != nil {
+			return err
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("missing scheme or host")
+		}
+		return nil
+	},
+}
+
+// Var declares a single environment variable expected by the application.
+type Var struct {
+	Name        string `yaml:"name"`
+	Type        string `yaml:"type"`
+	Required    bool   `yaml:"required"`
+	Description string `yaml:"description"`
+}
+
+// Schema is the parsed content of an application configuration schema file.
+type Schema struct {
+	Env []Var `yaml:"env"`
+}
+
+// Parse parses and checks a configuration schema.
+func Parse(data []byte) (*Schema, error) {
+	var s Schema
+	if err := yaml.UnmarshalStrict(data, &s); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", SchemaFile, err)
+	}
+	seen := make(map[string]bool)
+	for i, v := range s.Env {
+		if v.Name == "" {
+			return nil, fmt.Errorf("env entry %d in %s has no name", i, SchemaFile)
+		}
+		if seen[v.Name] {
+			return nil, fmt.Errorf("env var %s is declared more than once in %s", v.Name, SchemaFile)
+		}
+		seen[v.Name] = true
+		if v.Type == "" {
+			s.Env[i].Type = "string"
+		} else if _, ok := supportedTypes[v.Type]; !ok {
+			return nil, fmt.Errorf("env var %s has unsupported type %q in %s, must be one of %s", v.Name, v.Type, SchemaFile, typeNames())
+		}
+	}
+	return &s, nil
+}
+
+// ReadFile reads and parses the configuration schema at path.
+func ReadFile(path string) (*Schema, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", path, err)
+	}
+	return Parse(data)
+}
+
+// Required returns the names of the variables that must be set.
+func (s *Schema) Required() []string {
+	var names []string
+	for _, v := range s.Env {
+		if v.Required {
+			names = append(names, v.Name)
+		}
+	}
+	return names
+}
+
+// Validate checks the values returned by lookup against the schema.
+// All violations are reported in a single error.
+func (s *Schema) Validate(lookup func(string) (string, bool)) error {
+	var problems []string
+	for _, v := range s.Env {
+		val, ok := lookup(v.Name)
+		if !ok || val == "" {
+			if v.Required {
+				problem := fmt.Sprintf("%s is required but not set", v.Name)
+				if v.Description != "" {
+					problem += fmt.Sprintf(" (%s)", v.Description)
+				}
+				problems = append(problems, problem)
+			}
+			continue
+		}
+		if err := supportedTypes[v.Type](val); err != nil {
+			problems = append(problems, fmt.Sprintf("%s=%q is not a valid %s: %v", v.Name, val, v.Type, err))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid configuration declared in %s:\n  %s", SchemaFile, strings.Join(problems, "\n  "))
+}
+
+func typeNames() string {
+	var names []string
+	for t := range supportedTypes {
+		names = append(names, t)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,176 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appconfig
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name    string
+		data    string
+		want    []Var
+		wantErr bool
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "defaults to string",
+			data: `
+env:
+- name: FOO
+  required: true
+`,
+			want: []Var{{Name: "FOO", Type: "string", Required: true}},
+		},
+		{
+			name: "typed",
+			data: `
+env:
+- name: PORT_OFFSET
+  type: int
+- name: DATABASE_URL
+  type: url
+  required: true
+  description: Connection string for the database
+`,
+			want: []Var{
+				{Name: "PORT_OFFSET", Type: "int"},
+				{Name: "DATABASE_URL", Type: "url", Required: true, Description: "Connection string for the database"},
+			},
+		},
+		{
+			name: "missing name",
+			data: `
+env:
+- type: int
+`,
+			wantErr: true,
+		},
+		{
+			name: "duplicate name",
+			data: `
+env:
+- name: FOO
+- name: FOO
+`,
+			wantErr: true,
+		},
+		{
+			name: "unsupported type",
+			data: `
+env:
+- name: FOO
+  type: duration
+`,
+			wantErr: true,
+		},
+		{
+			name: "unknown field",
+			data: `
+env:
+- name: FOO
+  requried: true
+`,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Parse([]byte(tc.data))
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("Parse() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(got.Env) != len(tc.want) {
+				t.Fatalf("Parse() got %d vars, want %d", len(got.Env), len(tc.want))
+			}
+			for i := range tc.want {
+				if got.Env[i] != tc.want[i] {
+					t.Errorf("Parse() var %d = %+v, want %+v", i, got.Env[i], tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	schema := &Schema{Env: []Var{
+		{Name: "DATABASE_URL", Type: "url", Required: true},
+		{Name: "WORKERS", Type: "int"},
+		{Name: "DEBUG", Type: "bool"},
+	}}
+	testCases := []struct {
+		name        string
+		env         map[string]string
+		wantErrSubs []string
+	}{
+		{
+			name: "all set",
+			env:  map[string]string{"DATABASE_URL": "postgres://db:5432/app", "WORKERS": "4", "DEBUG": "false"},
+		},
+		{
+			name: "optional unset",
+			env:  map[string]string{"DATABASE_URL": "postgres://db:5432/app"},
+		},
+		{
+			name:        "required missing",
+			env:         map[string]string{"WORKERS": "4"},
+			wantErrSubs: []string{"DATABASE_URL is required"},
+		},
+		{
+			name:        "required empty",
+			env:         map[string]string{"DATABASE_URL": ""},
+			wantErrSubs: []string{"DATABASE_URL is required"},
+		},
+		{
+			name:        "multiple problems",
+			env:         map[string]string{"WORKERS": "four", "DEBUG": "maybe"},
+			wantErrSubs: []string{"DATABASE_URL is required", `WORKERS="four" is not a valid int`, `DEBUG="maybe" is not a valid bool`},
+		},
+		{
+			name:        "invalid url",
+			env:         map[string]string{"DATABASE_URL": "localhost"},
+			wantErrSubs: []string{`DATABASE_URL="localhost" is not a valid url`},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := schema.Validate(func(k string) (string, bool) {
+				v, ok := tc.env[k]
+				return v, ok
+			})
+			if len(tc.wantErrSubs) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() got unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() got nil error, want error containing %q", tc.wantErrSubs)
+			}
+			for _, sub := range tc.wantErrSubs {
+				if !strings.Contains(err.Error(), sub) {
+					t.Errorf("Validate() got error %q, want substring %q", err, sub)
+				}
+			}
+		})
+	}
+}
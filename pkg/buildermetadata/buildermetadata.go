@@ -0,0 +1,330 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package buildermetadata composes and validates builder descriptors from the metadata of the
+// buildpacks in this repository.
+package buildermetadata
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+const (
+	// BuildpackDescriptor is the name of the buildpack descriptor file.
+	BuildpackDescriptor = "buildpack.toml"
+	// BuilderDescriptor is the name of the builder descriptor file.
+	BuilderDescriptor = "builder.toml"
+)
+
+var (
+	// neutralPrefixes identify buildpacks that are not specific to a language and are kept in
+	// single-language builders.
+	neutralPrefixes = []string{"google.config.", "google.utils."}
+)
+
+// BuildpackStack is a stack supported by a buildpack.
+type BuildpackStack struct {
+	ID     string   `toml:"id"`
+	Mixins []string `toml:"mixins,omitempty"`
+}
+
+// Buildpack is the metadata of a single buildpack, read from its descriptor.
+type Buildpack struct {
+	API  string `toml:"api"`
+	Info struct {
+		ID      string `toml:"id"`
+		Version string `toml:"version"`
+		Name    string `toml:"name"`
+	} `toml:"buildpack"`
+	Stacks   []BuildpackStack `toml:"stacks"`
+	Metadata struct {
+		// Compatibility is shared by buildpacks of the same release; see pkg/gcpbuildpack.
+		Compatibility string `toml:"compatibility"`
+	} `toml:"metadata"`
+
+	// Dir is the directory containing the descriptor, relative to the repository root.
+	Dir string `toml:"-"`
+}
+
+// SupportsStack returns the stack entry for id, or nil if the buildpack does not support it.
+func (b *Buildpack) SupportsStack(id string) *BuildpackStack {
+	for i, s := range b.Stacks {
+		if s.ID == id || s.ID == "*" {
+			return &b.Stacks[i]
+		}
+	}
+	return nil
+}
+
+// Registry maps buildpack IDs to their metadata.
+type Registry map[string]*Buildpack
+
+// LoadRegistry reads every buildpack descriptor under dir, which is relative to root.
+// It returns an error if two buildpacks share an ID.
+func LoadRegistry(root, dir string) (Registry, error) {
+	r := make(Registry)
+	err := filepath.Walk(filepath.Join(root, dir), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || info.Name() != BuildpackDescriptor {
+			return nil
+		}
+		var bp Buildpack
+		if _, err := toml.DecodeFile(path, &bp); err != nil {
+			return fmt.Errorf("decoding %s: %v", path, err)
+		}
+		rel, err := filepath.Rel(root, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		bp.Dir = rel
+		if bp.Info.ID == "" || bp.Info.Version == "" {
+			return fmt.Errorf("%s must set buildpack id and version", path)
+		}
+		if other, ok := r[bp.Info.ID]; ok {
+			return fmt.Errorf("buildpack %s is declared in both %s and %s", bp.Info.ID, other.Dir, bp.Dir)
+		}
+		r[bp.Info.ID] = &bp
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// BuilderBuildpack is a buildpack included in a builder.
+type BuilderBuildpack struct {
+	ID      string `toml:"id"`
+	Version string `toml:"version,omitempty"`
+	URI     string `toml:"uri"`
+}
+
+// GroupEntry is a buildpack in an order group.
+type GroupEntry struct {
+	ID       string `toml:"id"`
+	Optional bool   `toml:"optional,omitempty"`
+}
+
+// Order is a group of buildpacks that is detected together.
+type Order struct {
+	Group []GroupEntry `toml:"group"`
+}
+
+// Stack is the stack a builder is based on.
+type Stack struct {
+	ID         string `toml:"id"`
+	BuildImage string `toml:"build-image"`
+	RunImage   string `toml:"run-image"`
+	// Mixins lists mixins provided by the stack images; it is used only for validation.
+	Mixins []string `toml:"mixins,omitempty"`
+}
+
+// Lifecycle is the lifecycle version used by a builder.
+type Lifecycle struct {
+	Version string `toml:"version"`
+}
+
+// Builder is a builder descriptor.
+type Builder struct {
+	Description string             `toml:"description,omitempty"`
+	Buildpacks  []BuilderBuildpack `toml:"buildpacks"`
+	Order       []Order            `toml:"order"`
+	Stack       Stack              `toml:"stack"`
+	Lifecycle   Lifecycle          `toml:"lifecycle"`
+}
+
+// ReadBuilder reads a builder descriptor.
+func ReadBuilder(path string) (*Builder, error) {
+	var b Builder
+	if _, err := toml.DecodeFile(path, &b); err != nil {
+		return nil, fmt.Errorf("decoding %s: %v", path, err)
+	}
+	return &b, nil
+}
+
+// Encode writes the builder descriptor in TOML.
+func (b *Builder) Encode(w io.Writer) error {
+	return toml.NewEncoder(w).Encode(b)
+}
+
+// Compose returns a builder with the given order groups, stack and lifecycle version. The
+// buildpacks of the builder are the buildpacks referenced by the groups, in order of first use,
+// with URIs returned by uri. The result is validated against r.
+func Compose(r Registry, order []Order, stack Stack, lifecycle string, uri func(*Buildpack) string) (*Builder, error) {
+	b := &Builder{Order: order, Stack: stack, Lifecycle: Lifecycle{Version: lifecycle}}
+	seen := make(map[string]bool)
+	for _, o := range order {
+		for _, e := range o.Group {
+			if seen[e.ID] {
+				continue
+			}
+			seen[e.ID] = true
+			bp, ok := r[e.ID]
+			if !ok {
+				return nil, fmt.Errorf("buildpack %s not found", e.ID)
+			}
+			b.Buildpacks = append(b.Buildpacks, BuilderBuildpack{ID: e.ID, URI: uri(bp)})
+		}
+	}
+	if err := b.Validate(r); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Validate checks that the builder is consistent with the buildpacks in r: every buildpack exists
+// and supports the stack with the mixins it provides, pinned versions match, IDs are unique, all
+// buildpacks share a compatibility version, and every buildpack in the order is included in the
+// builder. All problems are reported in a single error.
+func (b *Builder) Validate(r Registry) error {
+	var problems []string
+	if b.Stack.ID == "" {
+		problems = append(problems, "stack id is not set")
+	}
+	if b.Lifecycle.Version == "" {
+		problems = append(problems, "lifecycle version is not set")
+	}
+	mixins := make(map[string]bool)
+	for _, m := range b.Stack.Mixins {
+		mixins[m] = true
+	}
+	included := make(map[string]bool)
+	compatibility := make(map[string][]string)
+	for _, bb := range b.Buildpacks {
+		if included[bb.ID] {
+			problems = append(problems, fmt.Sprintf("buildpack %s is included more than once", bb.ID))
+			continue
+		}
+		included[bb.ID] = true
+		bp, ok := r[bb.ID]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("buildpack %s not found", bb.ID))
+			continue
+		}
+		compatibility[bp.Metadata.Compatibility] = append(compatibility[bp.Metadata.Compatibility], bb.ID)
+		if bb.Version != "" && bb.Version != bp.Info.Version {
+			problems = append(problems, fmt.Sprintf("buildpack %s is pinned to version %s but %s declares %s", bb.ID, bb.Version, bp.Dir, bp.Info.Version))
+		}
+		s := bp.SupportsStack(b.Stack.ID)
+		if s == nil {
+			problems = append(problems, fmt.Sprintf("buildpack %s does not support stack %s", bb.ID, b.Stack.ID))
+			continue
+		}
+		for _, m := range s.Mixins {
+			if !mixins[m] {
+				problems = append(problems, fmt.Sprintf("buildpack %s requires mixin %s, which stack %s does not provide", bb.ID, m, b.Stack.ID))
+			}
+		}
+	}
+	if len(compatibility) > 1 {
+		var versions []string
+		for v, ids := range compatibility {
+			versions = append(versions, fmt.Sprintf("%q (%s)", v, strings.Join(ids, ", ")))
+		}
+		sort.Strings(versions)
+		problems = append(problems, fmt.Sprintf("buildpacks declare different compatibility versions: %s", strings.Join(versions, "; ")))
+	}
+	for i, o := range b.Order {
+		if len(o.Group) == 0 {
+			problems = append(problems, fmt.Sprintf("order group %d is empty", i+1))
+		}
+		for _, e := range o.Group {
+			if !included[e.ID] {
+				problems = append(problems, fmt.Sprintf("order group %d references %s, which is not included in the builder", i+1, e.ID))
+			}
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid builder:\n  %s", strings.Join(problems, "\n  "))
+}
+
+// Slim returns a builder containing only the order groups that build applications in language,
+// for example "python", together with the language-neutral buildpacks those groups use.
+func (b *Builder) Slim(r Registry, language string) (*Builder, error) {
+	uris := make(map[string]string)
+	for _, bb := range b.Buildpacks {
+		uris[bb.ID] = bb.URI
+	}
+	prefix := "google." + language + "."
+	var order []Order
+	for _, o := range b.Order {
+		if groupInLanguage(o, prefix) {
+			order = append(order, o)
+		}
+	}
+	if len(order) == 0 {
+		return nil, fmt.Errorf("no order groups for language %q, must be one of %s", language, strings.Join(b.Languages(), ", "))
+	}
+	slim, err := Compose(r, order, b.Stack, b.Lifecycle.Version, func(bp *Buildpack) string { return uris[bp.Info.ID] })
+	if err != nil {
+		return nil, err
+	}
+	slim.Description = b.Description
+	return slim, nil
+}
+
+// Languages returns the sorted languages of the language-specific buildpacks in the builder order.
+func (b *Builder) Languages() []string {
+	seen := make(map[string]bool)
+	var languages []string
+	for _, o := range b.Order {
+		for _, e := range o.Group {
+			if isNeutral(e.ID) {
+				continue
+			}
+			parts := strings.SplitN(e.ID, ".", 3)
+			if len(parts) == 3 && !seen[parts[1]] {
+				seen[parts[1]] = true
+				languages = append(languages, parts[1])
+			}
+		}
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// groupInLanguage returns true if the group has at least one buildpack with the prefix and all
+// other buildpacks are language-neutral.
+func groupInLanguage(o Order, prefix string) bool {
+	found := false
+	for _, e := range o.Group {
+		switch {
+		case strings.HasPrefix(e.ID, prefix):
+			found = true
+		case !isNeutral(e.ID):
+			return false
+		}
+	}
+	return found
+}
+
+func isNeutral(id string) bool {
+	for _, p := range neutralPrefixes {
+		if strings.HasPrefix(id, p) {
+			return true
+		}
+	}
+	return false
+}
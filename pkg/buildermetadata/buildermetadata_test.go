@@ -0,0 +1,215 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildermetadata
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeBuildpack(t *testing.T, root, dir, descriptor string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+		t.Fatalf("creating %s: %v", dir, err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, dir, BuildpackDescriptor), []byte(descriptor), 0644); err != nil {
+		t.Fatalf("writing descriptor in %s: %v", dir, err)
+	}
+}
+
+func testRegistry(t *testing.T) Registry {
+	t.Helper()
+	root, err := ioutil.TempDir("", "buildermetadata")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(root)
+	writeBuildpack(t, root, "cmd/python/runtime", "[buildpack]\nid = \"google.python.runtime\"\nversion = \"0.9.1\"\n[[stacks]]\nid = \"google\"\n")
+	writeBuildpack(t, root, "cmd/python/pip", "[buildpack]\nid = \"google.python.pip\"\nversion = \"0.9.0\"\n[[stacks]]\nid = \"google\"\nmixins = [\"libpq\"]\n")
+	writeBuildpack(t, root, "cmd/nodejs/runtime", "[buildpack]\nid = \"google.nodejs.runtime\"\nversion = \"0.9.0\"\n[[stacks]]\nid = \"google\"\n")
+	writeBuildpack(t, root, "cmd/utils/label", "[buildpack]\nid = \"google.utils.label\"\nversion = \"0.0.1\"\n[[stacks]]\nid = \"google\"\n")
+	writeBuildpack(t, root, "cmd/ruby/runtime", "[buildpack]\nid = \"google.ruby.runtime\"\nversion = \"0.9.0\"\n[[stacks]]\nid = \"google\"\n[metadata]\ncompatibility = \"2\"\n")
+	writeBuildpack(t, root, "cmd/dotnet/runtime", "[buildpack]\nid = \"google.dotnet.runtime\"\nversion = \"0.9.0\"\n[[stacks]]\nid = \"google.dotnet3\"\n")
+	r, err := LoadRegistry(root, "cmd")
+	if err != nil {
+		t.Fatalf("LoadRegistry() got error: %v", err)
+	}
+	return r
+}
+
+func TestLoadRegistryDuplicate(t *testing.T) {
+	root, err := ioutil.TempDir("", "buildermetadata")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(root)
+	writeBuildpack(t, root, "cmd/a", "[buildpack]\nid = \"google.a\"\nversion = \"1\"\n")
+	writeBuildpack(t, root, "cmd/b", "[buildpack]\nid = \"google.a\"\nversion = \"1\"\n")
+
+	if _, err := LoadRegistry(root, "cmd"); err == nil {
+		t.Error("LoadRegistry() got nil error, want error for duplicate id")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	r := testRegistry(t)
+	stack := Stack{ID: "google", Mixins: []string{"libpq"}}
+	testCases := []struct {
+		name    string
+		builder Builder
+		want    []string
+	}{
+		{
+			name: "valid",
+			builder: Builder{
+				Buildpacks: []BuilderBuildpack{{ID: "google.python.runtime", Version: "0.9.1"}, {ID: "google.python.pip"}},
+				Order:      []Order{{Group: []GroupEntry{{ID: "google.python.runtime"}, {ID: "google.python.pip", Optional: true}}}},
+				Stack:      stack,
+				Lifecycle:  Lifecycle{Version: "0.9.1"},
+			},
+		},
+		{
+			name: "mixed compatibility",
+			builder: Builder{
+				Buildpacks: []BuilderBuildpack{{ID: "google.python.runtime"}, {ID: "google.ruby.runtime"}},
+				Order:      []Order{{Group: []GroupEntry{{ID: "google.python.runtime"}}}, {Group: []GroupEntry{{ID: "google.ruby.runtime"}}}},
+				Stack:      stack,
+				Lifecycle:  Lifecycle{Version: "0.9.1"},
+			},
+			want: []string{`different compatibility versions: "" (google.python.runtime); "2" (google.ruby.runtime)`},
+		},
+		{
+			name: "all problems",
+			builder: Builder{
+				Buildpacks: []BuilderBuildpack{
+					{ID: "google.python.runtime", Version: "0.9.0"},
+					{ID: "google.python.runtime"},
+					{ID: "google.python.pip"},
+					{ID: "google.dotnet.runtime"},
+					{ID: "google.go.runtime"},
+				},
+				Order: []Order{{Group: []GroupEntry{{ID: "google.nodejs.runtime"}}}, {}},
+				Stack: Stack{ID: "google"},
+			},
+			want: []string{
+				"lifecycle version is not set",
+				"pinned to version 0.9.0",
+				"google.python.runtime is included more than once",
+				"requires mixin libpq",
+				"google.dotnet.runtime does not support stack google",
+				"google.go.runtime not found",
+				"references google.nodejs.runtime",
+				"order group 2 is empty",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.builder.Validate(r)
+			if len(tc.want) == 0 {
+				if err != nil {
+					t.Errorf("Validate() got error: %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() got nil error, want %v", tc.want)
+			}
+			for _, w := range tc.want {
+				if !strings.Contains(err.Error(), w) {
+					t.Errorf("Validate() = %q, want it to contain %q", err, w)
+				}
+			}
+		})
+	}
+}
+
+func TestSlim(t *testing.T) {
+	r := testRegistry(t)
+	b := &Builder{
+		Description: "Base builder",
+		Buildpacks: []BuilderBuildpack{
+			{ID: "google.nodejs.runtime", URI: "nodejs/runtime.tgz"},
+			{ID: "google.python.runtime", URI: "python/runtime.tgz"},
+			{ID: "google.python.pip", URI: "python/pip.tgz"},
+			{ID: "google.utils.label", URI: "label.tgz"},
+		},
+		Order: []Order{
+			{Group: []GroupEntry{{ID: "google.python.runtime"}, {ID: "google.python.pip", Optional: true}, {ID: "google.utils.label"}}},
+			{Group: []GroupEntry{{ID: "google.nodejs.runtime"}, {ID: "google.utils.label"}}},
+			{Group: []GroupEntry{{ID: "google.nodejs.runtime"}, {ID: "google.python.runtime"}}},
+		},
+		Stack:     Stack{ID: "google", Mixins: []string{"libpq"}},
+		Lifecycle: Lifecycle{Version: "0.9.1"},
+	}
+
+	got, err := b.Slim(r, "python")
+	if err != nil {
+		t.Fatalf("Slim() got error: %v", err)
+	}
+	want := &Builder{
+		Description: "Base builder",
+		Buildpacks: []BuilderBuildpack{
+			{ID: "google.python.runtime", URI: "python/runtime.tgz"},
+			{ID: "google.python.pip", URI: "python/pip.tgz"},
+			{ID: "google.utils.label", URI: "label.tgz"},
+		},
+		Order:     b.Order[:1],
+		Stack:     b.Stack,
+		Lifecycle: b.Lifecycle,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Slim() = %+v, want %+v", got, want)
+	}
+
+	if _, err := b.Slim(r, "ruby"); err == nil {
+		t.Error("Slim(ruby) got nil error, want error")
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	b := &Builder{
+		Buildpacks: []BuilderBuildpack{{ID: "google.python.runtime", URI: "python/runtime.tgz"}},
+		Order:      []Order{{Group: []GroupEntry{{ID: "google.python.runtime"}, {ID: "google.utils.label", Optional: true}}}},
+		Stack:      Stack{ID: "google", BuildImage: "gcr.io/buildpacks/gcp/build:v1", RunImage: "gcr.io/buildpacks/gcp/run:v1"},
+		Lifecycle:  Lifecycle{Version: "0.9.1"},
+	}
+	var buf bytes.Buffer
+	if err := b.Encode(&buf); err != nil {
+		t.Fatalf("Encode() got error: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "buildermetadata")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, BuilderDescriptor)
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("writing %s: %v", path, err)
+	}
+
+	got, err := ReadBuilder(path)
+	if err != nil {
+		t.Fatalf("ReadBuilder() got error: %v", err)
+	}
+	if !reflect.DeepEqual(got, b) {
+		t.Errorf("ReadBuilder(Encode(b)) = %+v, want %+v", got, b)
+	}
+}
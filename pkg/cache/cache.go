@@ -18,11 +18,20 @@ package cache
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
 	"io/ioutil"
+	"os"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 )
 
+var (
+	// packageDB lists the packages installed in the build image. It changes with every update of the
+	// stack, so it stands in for the image digests when the platform does not provide them.
+	packageDB = "/var/lib/dpkg/status"
+)
+
 // Option is a function that returns strings to be hashed when computing a cache key.
 type Option func() ([]string, error)
 
@@ -55,6 +64,15 @@ func Hash(ctx *gcp.Context, opts ...Option) (result string, err error) {
 	h.Write([]byte(ctx.BuildpackID()))
 	h.Write([]byte(ctx.BuildpackVersion()))
 
+	stack, err := stackDigest()
+	if err != nil {
+		return "", err
+	}
+	// Keys are unchanged when the stack is unknown.
+	if stack != "" {
+		h.Write([]byte(stack))
+	}
+
 	for _, opt := range opts {
 		strings, err := opt()
 		if err != nil {
@@ -67,3 +85,25 @@ func Hash(ctx *gcp.Context, opts ...Option) (result string, err error) {
 
 	return hex.EncodeToString(h.Sum(nil)), nil
 }
+
+// stackDigest returns a value that changes when the builder or run image changes: the digests
+// passed by the platform, or else a hash of the packages installed in the build image.
+func stackDigest() (string, error) {
+	ignore, err := env.Bool(env.CacheIgnoreStack, false)
+	if err != nil {
+		return "", gcp.UserErrorf("%v", err)
+	}
+	if ignore {
+		return "", nil
+	}
+	builder, run := os.Getenv(env.BuilderDigest), os.Getenv(env.RunImageDigest)
+	if builder != "" || run != "" {
+		return fmt.Sprintf("builder=%s,run=%s", builder, run), nil
+	}
+	b, err := ioutil.ReadFile(packageDB)
+	if err != nil {
+		// Not a Debian-based stack.
+		return "", nil
+	}
+	return fmt.Sprintf("packages=%x", sha256.Sum256(b)), nil
+}
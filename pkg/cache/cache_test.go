@@ -21,10 +21,17 @@ import (
 	"sort"
 	"testing"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/buildpacks/libcnb"
 )
 
+func TestMain(m *testing.M) {
+	// Keep hashes independent of the packages installed where the tests run.
+	packageDB = "/does/not/exist"
+	os.Exit(m.Run())
+}
+
 func TestWithStrings(t *testing.T) {
 	testCases := []struct {
 		name    string
@@ -196,6 +203,53 @@ func TestHash_Uniqueness(t *testing.T) {
 	}
 }
 
+func TestHashStack(t *testing.T) {
+	temp, err := ioutil.TempDir("", "test-sha-stack-")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(temp)
+	oldPackageDB := packageDB
+	defer func() { packageDB = oldPackageDB }()
+	for _, e := range []string{env.BuilderDigest, env.RunImageDigest, env.CacheIgnoreStack} {
+		if v, ok := os.LookupEnv(e); ok {
+			defer os.Setenv(e, v)
+		} else {
+			defer os.Unsetenv(e)
+		}
+		os.Unsetenv(e)
+	}
+
+	ctx := gcp.NewContext(libcnb.BuildpackInfo{ID: "id", Version: "version", Name: "name"})
+	unknown := computeHash(t, ctx)
+
+	packageDB = writeFile(t, temp, "status", "Package: libc6\nVersion: 2.27-3ubuntu1.2\n")
+	packagesV1 := computeHash(t, ctx)
+	writeFile(t, temp, "status", "Package: libc6\nVersion: 2.27-3ubuntu1.3\n")
+	packagesV2 := computeHash(t, ctx)
+
+	os.Setenv(env.BuilderDigest, "sha256:builder")
+	os.Setenv(env.RunImageDigest, "sha256:run1")
+	run1 := computeHash(t, ctx)
+	os.Setenv(env.RunImageDigest, "sha256:run2")
+	run2 := computeHash(t, ctx)
+
+	hashes := []string{unknown, packagesV1, packagesV2, run1, run2}
+	if cleaned := removeDuplicates(t, hashes); len(cleaned) != len(hashes) {
+		t.Errorf("hashes were not unique %v", hashes)
+	}
+
+	os.Setenv(env.CacheIgnoreStack, "true")
+	if got := computeHash(t, ctx); got != unknown {
+		t.Errorf("Hash() with %s = %q, want %q", env.CacheIgnoreStack, got, unknown)
+	}
+
+	os.Setenv(env.CacheIgnoreStack, "sometimes")
+	if _, err := Hash(ctx); err == nil {
+		t.Errorf("Hash() with invalid %s got err=nil, want err", env.CacheIgnoreStack)
+	}
+}
+
 func writeFile(t *testing.T, tempDir, name, contents string) string {
 	t.Helper()
 	fullName := filepath.Join(tempDir, name)
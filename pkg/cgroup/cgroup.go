@@ -0,0 +1,144 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cgroup reads the memory and CPU limits that cgroups impose on a container.
+package cgroup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Root is where the cgroup file systems are mounted in containers.
+	Root = "/sys/fs/cgroup"
+
+	// unlimitedV1Memory is the smallest memory limit that cgroup v1 reports for unlimited cgroups,
+	// which is the largest int64 rounded down to the page size.
+	unlimitedV1Memory = 1 << 62
+)
+
+// Limits are the resource limits of a cgroup.
+type Limits struct {
+	// MemoryBytes is the memory limit in bytes, or 0 if memory is not limited.
+	MemoryBytes int64
+	// CPUs is the CPU quota in CPUs, or 0 if CPU is not limited.
+	CPUs float64
+}
+
+// Read returns the limits of the cgroup of the container, whose cgroup file systems are mounted at
+// root. Both the unified cgroup v2 hierarchy and the cgroup v1 hierarchies are supported; limits
+// whose files do not exist are reported as unlimited.
+func Read(root string) (Limits, error) {
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err == nil {
+		return readV2(root)
+	}
+	return readV1(root)
+}
+
+func readV2(root string) (Limits, error) {
+	var l Limits
+	mem, ok, err := readFile(filepath.Join(root, "memory.max"))
+	if err != nil {
+		return Limits{}, err
+	}
+	if ok && mem != "max" {
+		if l.MemoryBytes, err = strconv.ParseInt(mem, 10, 64); err != nil {
+			return Limits{}, fmt.Errorf("parsing memory.max: %v", err)
+		}
+	}
+
+	cpu, ok, err := readFile(filepath.Join(root, "cpu.max"))
+	if err != nil {
+		return Limits{}, err
+	}
+	if ok {
+		// cpu.max holds the quota, or max, and the period, in microseconds.
+		fields := strings.Fields(cpu)
+		if len(fields) != 2 {
+			return Limits{}, fmt.Errorf("parsing cpu.max: unexpected content %q", cpu)
+		}
+		if fields[0] != "max" {
+			if l.CPUs, err = quota(fields[0], fields[1]); err != nil {
+				return Limits{}, fmt.Errorf("parsing cpu.max: %v", err)
+			}
+		}
+	}
+	return l, nil
+}
+
+func readV1(root string) (Limits, error) {
+	var l Limits
+	mem, ok, err := readFile(filepath.Join(root, "memory", "memory.limit_in_bytes"))
+	if err != nil {
+		return Limits{}, err
+	}
+	if ok {
+		if l.MemoryBytes, err = strconv.ParseInt(mem, 10, 64); err != nil {
+			return Limits{}, fmt.Errorf("parsing memory.limit_in_bytes: %v", err)
+		}
+		if l.MemoryBytes >= unlimitedV1Memory {
+			l.MemoryBytes = 0
+		}
+	}
+
+	q, okQuota, err := readFile(filepath.Join(root, "cpu", "cpu.cfs_quota_us"))
+	if err != nil {
+		return Limits{}, err
+	}
+	p, okPeriod, err := readFile(filepath.Join(root, "cpu", "cpu.cfs_period_us"))
+	if err != nil {
+		return Limits{}, err
+	}
+	// A quota of -1 means that CPU is not limited.
+	if okQuota && okPeriod && q != "-1" {
+		if l.CPUs, err = quota(q, p); err != nil {
+			return Limits{}, fmt.Errorf("parsing cpu.cfs_quota_us: %v", err)
+		}
+	}
+	return l, nil
+}
+
+// quota returns the number of CPUs that a quota of q microseconds of every period of p microseconds
+// allows.
+func quota(q, p string) (float64, error) {
+	quota, err := strconv.ParseInt(q, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	period, err := strconv.ParseInt(p, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if quota <= 0 || period <= 0 {
+		return 0, fmt.Errorf("invalid quota %d and period %d", quota, period)
+	}
+	return float64(quota) / float64(period), nil
+}
+
+// readFile returns the trimmed content of the file at path, and false if it does not exist.
+func readFile(path string) (string, bool, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return strings.TrimSpace(string(b)), true, nil
+}
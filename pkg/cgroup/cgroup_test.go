@@ -0,0 +1,110 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cgroup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	testCases := []struct {
+		name    string
+		files   map[string]string
+		want    Limits
+		wantErr bool
+	}{
+		{
+			name: "v2 limited",
+			files: map[string]string{
+				"cgroup.controllers": "cpu memory",
+				"memory.max":         "536870912\n",
+				"cpu.max":            "200000 100000\n",
+			},
+			want: Limits{MemoryBytes: 536870912, CPUs: 2},
+		},
+		{
+			name: "v2 unlimited",
+			files: map[string]string{
+				"cgroup.controllers": "cpu memory",
+				"memory.max":         "max\n",
+				"cpu.max":            "max 100000\n",
+			},
+		},
+		{
+			name: "v2 without controllers",
+			files: map[string]string{
+				"cgroup.controllers": "",
+			},
+		},
+		{
+			name: "v2 invalid",
+			files: map[string]string{
+				"cgroup.controllers": "cpu",
+				"cpu.max":            "100000",
+			},
+			wantErr: true,
+		},
+		{
+			name: "v1 limited",
+			files: map[string]string{
+				"memory/memory.limit_in_bytes": "1073741824\n",
+				"cpu/cpu.cfs_quota_us":         "50000\n",
+				"cpu/cpu.cfs_period_us":        "100000\n",
+			},
+			want: Limits{MemoryBytes: 1073741824, CPUs: 0.5},
+		},
+		{
+			name: "v1 unlimited",
+			files: map[string]string{
+				"memory/memory.limit_in_bytes": "9223372036854771712\n",
+				"cpu/cpu.cfs_quota_us":         "-1\n",
+				"cpu/cpu.cfs_period_us":        "100000\n",
+			},
+		},
+		{
+			name: "none",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root, err := ioutil.TempDir("", "cgroup")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(root)
+			for name, content := range tc.files {
+				path := filepath.Join(root, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating dir: %v", err)
+				}
+				if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatalf("writing %s: %v", name, err)
+				}
+			}
+
+			got, err := Read(root)
+
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("Read() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("Read() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
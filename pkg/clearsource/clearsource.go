@@ -17,9 +17,7 @@ package clearsource
 
 import (
 	"fmt"
-	"os"
 	"path/filepath"
-	"strconv"
 	"time"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/appengine"
@@ -38,15 +36,12 @@ func DetectFn(ctx *gcp.Context) error {
 		ctx.OptOut("Development mode enabled")
 	}
 
-	if clearSource, ok := os.LookupEnv(env.ClearSource); ok {
-		clear, err := strconv.ParseBool(clearSource)
-		if err != nil {
-			return fmt.Errorf("parsing %q: %v", env.ClearSource, err)
-		}
-
-		if clear {
-			return nil
-		}
+	clear, err := env.Bool(env.ClearSource, false)
+	if err != nil {
+		return err
+	}
+	if clear {
+		return nil
 	}
 	ctx.OptOut("%s not set", env.ClearSource)
 	return nil
@@ -0,0 +1,135 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clearsource
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+var (
+	// defaultTestPatterns are removed when env.ClearTestSources is enabled and
+	// env.ClearTestSourcesPatterns is not set. A trailing slash matches directories only.
+	defaultTestPatterns = []string{"__tests__/", "test/", "tests/", "fixtures/", "__fixtures__/"}
+
+	// skippedDirs are never searched for test sources; their contents are managed by package managers.
+	skippedDirs = map[string]bool{".git": true, "node_modules": true, "vendor": true}
+)
+
+// ClearTestSources removes tests and test fixtures from the application directory
+// so they are not shipped in the final image. It is a no-op unless env.ClearTestSources is enabled.
+func ClearTestSources(ctx *gcp.Context) error {
+	clear, err := env.Bool(env.ClearTestSources, false)
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	if !clear {
+		return nil
+	}
+
+	defer func(now time.Time) {
+		ctx.Span("Clear test sources", now, gcp.StatusOk)
+	}(time.Now())
+
+	patterns := defaultTestPatterns
+	if v := os.Getenv(env.ClearTestSourcesPatterns); v != "" {
+		patterns = splitPatterns(v)
+	}
+	paths, err := testPaths(ctx.ApplicationRoot(), patterns)
+	if err != nil {
+		return fmt.Errorf("finding test sources: %w", err)
+	}
+	ctx.Logf("Clearing %d test source path(s) matching %s", len(paths), strings.Join(patterns, ","))
+	for _, path := range paths {
+		ctx.Debugf("Removing %s", path)
+		ctx.RemoveAll(path)
+	}
+	return nil
+}
+
+// splitPatterns splits a comma-separated pattern list, dropping empty entries.
+func splitPatterns(s string) []string {
+	var patterns []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// testPaths returns the files and directories under dir matching any of patterns.
+// Patterns without a slash are matched against base names at any depth; patterns
+// containing a slash are matched against the path relative to dir.
+func testPaths(dir string, patterns []string) ([]string, error) {
+	var paths []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		if info.IsDir() && skippedDirs[info.Name()] {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		match, err := matchesAny(rel, info.IsDir(), patterns)
+		if err != nil {
+			return err
+		}
+		if !match {
+			return nil
+		}
+		paths = append(paths, path)
+		if info.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return paths, err
+}
+
+func matchesAny(rel string, isDir bool, patterns []string) (bool, error) {
+	for _, p := range patterns {
+		if strings.HasSuffix(p, "/") {
+			if !isDir {
+				continue
+			}
+			p = strings.TrimSuffix(p, "/")
+		}
+		name := filepath.Base(rel)
+		if strings.Contains(p, "/") {
+			name = filepath.ToSlash(rel)
+		}
+		match, err := filepath.Match(p, name)
+		if err != nil {
+			return false, gcp.UserErrorf("invalid pattern %q in %s: %v", p, env.ClearTestSourcesPatterns, err)
+		}
+		if match {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clearsource
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestTestPaths(t *testing.T) {
+	testCases := []struct {
+		name     string
+		files    []string
+		patterns []string
+		want     []string
+	}{
+		{
+			name:     "default patterns",
+			files:    []string{"main.go", "main_test.go", "__tests__/a.js", "src/test/a.py", "fixtures/creds.json", "index.js"},
+			patterns: defaultTestPatterns,
+			want:     []string{"__tests__", "fixtures", "src/test"},
+		},
+		{
+			name:     "directory-only pattern ignores files",
+			files:    []string{"test", "tests/a.py"},
+			patterns: []string{"test/", "tests/"},
+			want:     []string{"tests"},
+		},
+		{
+			name:     "skips package manager directories",
+			files:    []string{"node_modules/foo/test/a.js", "vendor/bar/tests/a.php", "tests/a.php"},
+			patterns: defaultTestPatterns,
+			want:     []string{"tests"},
+		},
+		{
+			name:     "relative path pattern",
+			files:    []string{"spec/a_spec.rb", "lib/spec/b_spec.rb"},
+			patterns: []string{"spec/*_spec.rb"},
+			want:     []string{"spec/a_spec.rb"},
+		},
+		{
+			name:     "no matches",
+			files:    []string{"main.py", "requirements.txt"},
+			patterns: defaultTestPatterns,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "testpaths")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			for _, f := range tc.files {
+				path := filepath.Join(dir, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating dir for %s: %v", path, err)
+				}
+				if err := ioutil.WriteFile(path, []byte{}, 0644); err != nil {
+					t.Fatalf("writing to file %s: %v", path, err)
+				}
+			}
+
+			paths, err := testPaths(dir, tc.patterns)
+			if err != nil {
+				t.Fatalf("testPaths() returned error: %v", err)
+			}
+			var got []string
+			for _, p := range paths {
+				rel, err := filepath.Rel(dir, p)
+				if err != nil {
+					t.Fatalf("relative path of %s: %v", p, err)
+				}
+				got = append(got, filepath.ToSlash(rel))
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("testPaths() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSplitPatterns(t *testing.T) {
+	got := splitPatterns(" test/, ,*_spec.rb,")
+	want := []string{"test/", "*_spec.rb"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("splitPatterns() = %v, want %v", got, want)
+	}
+}
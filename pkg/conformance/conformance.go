@@ -0,0 +1,219 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package conformance boots a built function and sends it the requests that the functions framework
+// conformance client sends for the function's declared signature type, so that functions that would
+// fail every request after deployment fail the build instead.
+package conformance
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// maxOutputBytes is the amount of function output included in errors.
+	maxOutputBytes = 4096
+
+	legacyEventPayload = `{
+  "context": {
+    "eventId": "1144231683168617",
+    "timestamp": "2020-05-06T07:33:34.556Z",
+    "eventType": "google.pubsub.topic.publish",
+    "resource": {
+      "service": "pubsub.googleapis.com",
+      "name": "projects/sample-project/topics/gcf-test",
+      "type": "type.googleapis.com/google.pubsub.v1.PubsubMessage"
+    }
+  },
+  "data": {
+    "@type": "type.googleapis.com/google.pubsub.v1.PubsubMessage",
+    "attributes": {"attr1": "attr1-value"},
+    "data": "dGVzdCBtZXNzYWdlIDM="
+  }
+}`
+	cloudEventPayload = `{
+  "message": {
+    "attributes": {"attr1": "attr1-value"},
+    "data": "dGVzdCBtZXNzYWdlIDM=",
+    "messageId": "1144231683168617"
+  },
+  "subscription": "projects/sample-project/subscriptions/gcf-test"
+}`
+)
+
+var (
+	// startTimeout is how long the function server may take to listen on its port.
+	startTimeout = 30 * time.Second
+	// requestTimeout is how long the function may take to respond.
+	requestTimeout = 30 * time.Second
+)
+
+// Check starts the function server with cmd, with the given env vars in addition to the function env
+// vars, and sends it a request of the declared signature type. It fails if the server does not start
+// or responds with a server error.
+func Check(ctx *gcp.Context, cmd []string, extraEnv ...string) error {
+	sigType := os.Getenv(env.FunctionSignatureType)
+	if sigType == "" {
+		sigType = "http"
+	}
+	req, err := newRequest(sigType)
+	if err != nil {
+		return err
+	}
+	prefix, err := env.FunctionPathPrefix()
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	req.URL.Path = prefix + "/"
+	port, err := freePort()
+	if err != nil {
+		return fmt.Errorf("finding a free port: %v", err)
+	}
+
+	ctx.Logf("Running the %s conformance check against %q", sigType, strings.Join(cmd, " "))
+	var out bytes.Buffer
+	ecmd := exec.Command(cmd[0], cmd[1:]...)
+	ecmd.Dir = ctx.ApplicationRoot()
+	ecmd.Env = append(os.Environ(), functionEnv()...)
+	ecmd.Env = append(ecmd.Env, extraEnv...)
+	ecmd.Env = append(ecmd.Env, "PORT="+strconv.Itoa(port))
+	ecmd.Stdout = &out
+	ecmd.Stderr = &out
+	if err := ecmd.Start(); err != nil {
+		return fmt.Errorf("starting %q: %v", cmd, err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		ecmd.Wait()
+		close(exited)
+	}()
+	// stop stops the server and returns the tail of its output, which is only safe to read once it exited.
+	stopped := false
+	stop := func() string {
+		if !stopped {
+			ecmd.Process.Kill()
+			<-exited
+			stopped = true
+		}
+		return tail(out.String())
+	}
+	defer stop()
+
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+	if err := waitForPort(addr, exited); err != nil {
+		return gcp.UserErrorf("function failed the conformance check: %v; function output:\n%s", err, stop())
+	}
+
+	req.URL.Host = addr
+	client := http.Client{Timeout: requestTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return gcp.UserErrorf("function failed the %s conformance check: %v; function output:\n%s", sigType, err, stop())
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return gcp.UserErrorf("function failed the %s conformance check: the request returned status %d; check that %s matches the function signature; function output:\n%s", sigType, resp.StatusCode, env.FunctionSignatureType, stop())
+	}
+	ctx.Logf("Function passed the %s conformance check at %s with status %d", sigType, req.URL.Path, resp.StatusCode)
+	return nil
+}
+
+// newRequest returns the request sent to functions of the given signature type.
+func newRequest(sigType string) (*http.Request, error) {
+	switch sigType {
+	case "http":
+		return http.NewRequest(http.MethodGet, "http://localhost/", nil)
+	case "event":
+		req, err := http.NewRequest(http.MethodPost, "http://localhost/", strings.NewReader(legacyEventPayload))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	case "cloudevent":
+		req, err := http.NewRequest(http.MethodPost, "http://localhost/", strings.NewReader(cloudEventPayload))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("ce-specversion", "1.0")
+		req.Header.Set("ce-type", "google.cloud.pubsub.topic.v1.messagePublished")
+		req.Header.Set("ce-source", "//pubsub.googleapis.com/projects/sample-project/topics/gcf-test")
+		req.Header.Set("ce-id", "1144231683168617")
+		req.Header.Set("ce-time", "2020-05-06T07:33:34.556Z")
+		return req, nil
+	}
+	return nil, gcp.UserErrorf("unsupported %s %q for the conformance check, must be one of http, event, cloudevent", env.FunctionSignatureType, sigType)
+}
+
+// functionEnv returns the launch-time function env vars, which are not set during the build.
+func functionEnv() []string {
+	vars := map[string]string{
+		env.FunctionTargetLaunch:        env.FunctionTarget,
+		env.FunctionSignatureTypeLaunch: env.FunctionSignatureType,
+		env.FunctionSourceLaunch:        env.FunctionSource,
+		env.FunctionPathLaunch:          env.FunctionPath,
+	}
+	var e []string
+	for launch, build := range vars {
+		if v, ok := os.LookupEnv(build); ok {
+			e = append(e, launch+"="+v)
+		}
+	}
+	return e
+}
+
+func waitForPort(addr string, exited <-chan struct{}) error {
+	deadline := time.Now().Add(startTimeout)
+	for time.Now().Before(deadline) {
+		select {
+		case <-exited:
+			return fmt.Errorf("the function server exited before listening on $PORT")
+		default:
+		}
+		if conn, err := net.DialTimeout("tcp", addr, time.Second); err == nil {
+			conn.Close()
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("the function server did not listen on $PORT within %v", startTimeout)
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+func tail(s string) string {
+	if len(s) > maxOutputBytes {
+		return "..." + s[len(s)-maxOutputBytes:]
+	}
+	return s
+}
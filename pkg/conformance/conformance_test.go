@@ -0,0 +1,99 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conformance
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const statusEnv = "CONFORMANCE_TEST_STATUS"
+
+// TestHelperServer is not a real test: it is started by TestCheck as a function server that responds
+// with the status in statusEnv, or exits immediately if the status is "exit".
+func TestHelperServer(t *testing.T) {
+	status := os.Getenv(statusEnv)
+	if status == "" {
+		return
+	}
+	if status == "exit" {
+		os.Exit(1)
+	}
+	code, err := strconv.Atoi(status)
+	if err != nil {
+		os.Exit(2)
+	}
+	http.ListenAndServe(":"+os.Getenv("PORT"), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if os.Getenv(env.FunctionTargetLaunch) != "HelloWorld" || r.URL.Path != os.Getenv(env.FunctionPathLaunch)+"/" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		fmt.Println("handled request")
+		w.WriteHeader(code)
+	}))
+	os.Exit(3)
+}
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		status  string
+		sigType string
+		path    string
+		wantErr bool
+	}{
+		{status: "200"},
+		{status: "404"},
+		{status: "200", sigType: "event"},
+		{status: "200", sigType: "cloudevent"},
+		{status: "500", wantErr: true},
+		{status: "exit", wantErr: true},
+		{status: "200", sigType: "background", wantErr: true},
+		{status: "200", path: "/api/orders"},
+		{status: "200", path: "orders", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%s %s %s", tc.sigType, tc.path, tc.status), func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "conformance")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			defer os.Unsetenv(env.FunctionTarget)
+			os.Setenv(env.FunctionTarget, "HelloWorld")
+			defer os.Unsetenv(env.FunctionSignatureType)
+			if tc.sigType != "" {
+				os.Setenv(env.FunctionSignatureType, tc.sigType)
+			}
+			defer os.Unsetenv(env.FunctionPath)
+			if tc.path != "" {
+				os.Setenv(env.FunctionPath, tc.path)
+			}
+			ctx := gcp.NewContextForTests(libcnb.BuildpackInfo{}, dir)
+
+			err = Check(ctx, []string{os.Args[0], "-test.run=TestHelperServer"}, statusEnv+"="+tc.status)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("Check() got error: %v, want error: %t", err, tc.wantErr)
+			}
+		})
+	}
+}
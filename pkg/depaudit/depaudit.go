@@ -0,0 +1,322 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package depaudit records the fully resolved dependencies of a build in the image, with the
+// dependencies that required each of them, so that the dependencies of two images can be diffed.
+package depaudit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// LayerName is the name of the launch layer in which the report is written, as <ecosystem>.json.
+	LayerName = "dependency-audit"
+
+	// Ecosystems of the recorded dependencies.
+	Pip   = "pip"
+	NPM   = "npm"
+	Maven = "maven"
+)
+
+// Dependency is a resolved dependency.
+type Dependency struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// Direct is true if the application itself requires the dependency.
+	Direct bool `json:"direct"`
+	// RequiredBy lists the name@version of the dependencies that require this one.
+	RequiredBy []string `json:"requiredBy,omitempty"`
+}
+
+// Report is the audit trail of an ecosystem.
+type Report struct {
+	Ecosystem    string       `json:"ecosystem"`
+	Dependencies []Dependency `json:"dependencies"`
+}
+
+// Record writes the report of the ecosystem to the dependency-audit layer. Dependencies are sorted
+// so that reports of identical builds are identical.
+func Record(ctx *gcp.Context, ecosystem string, deps []Dependency) error {
+	r := Report{Ecosystem: ecosystem, Dependencies: normalize(deps)}
+	content, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling %s dependencies: %v", ecosystem, err)
+	}
+	l := ctx.Layer(LayerName, gcp.LaunchLayer)
+	fname := filepath.Join(l.Path, ecosystem+".json")
+	if err := ioutil.WriteFile(fname, content, 0644); err != nil {
+		return fmt.Errorf("writing %s: %v", fname, err)
+	}
+	ctx.Logf("Recorded %d resolved %s dependencies", len(r.Dependencies), ecosystem)
+	return nil
+}
+
+// RecordPip records the packages installed with pip in the site-packages directory path. Failures are
+// logged as warnings unless strict mode is enabled.
+func RecordPip(ctx *gcp.Context, path string) error {
+	return ctx.RunStep("pip dependency audit", gcp.Optional, func() error {
+		pythonPath := gcp.WithEnv("PYTHONPATH=" + path + string(filepath.ListSeparator) + os.Getenv("PYTHONPATH"))
+		result, err := ctx.ExecWithErr([]string{"python3", "-m", "pip", "list", "--format=freeze", "--path", path}, pythonPath)
+		if err != nil {
+			return err
+		}
+		var names []string
+		for _, line := range strings.Fields(result.Stdout) {
+			names = append(names, strings.SplitN(line, "==", 2)[0])
+		}
+		if len(names) == 0 {
+			return Record(ctx, Pip, nil)
+		}
+		result, err = ctx.ExecWithErr(append([]string{"python3", "-m", "pip", "show"}, names...), pythonPath)
+		if err != nil {
+			return err
+		}
+		return Record(ctx, Pip, ParsePip(result.Stdout))
+	})
+}
+
+// RecordNPM records the packages installed with npm in the application's node_modules. Failures
+// are logged as warnings unless strict mode is enabled.
+func RecordNPM(ctx *gcp.Context, nodeEnv string) error {
+	return ctx.RunStep("npm dependency audit", gcp.Optional, func() error {
+		// npm ls exits with an error for extraneous or missing packages, but still lists the others.
+		result, err := ctx.ExecWithErr([]string{"npm", "ls", "--json"}, gcp.WithEnv("NODE_ENV="+nodeEnv))
+		if result == nil {
+			return err
+		}
+		deps, perr := ParseNPM([]byte(result.Stdout))
+		if perr != nil {
+			if err != nil {
+				return err
+			}
+			return perr
+		}
+		return Record(ctx, NPM, deps)
+	})
+}
+
+// RecordMaven records the dependencies resolved by Maven for every module of the project, using the
+// given mvn command. Failures are logged as warnings unless strict mode is enabled.
+func RecordMaven(ctx *gcp.Context, mvn string) error {
+	return ctx.RunStep("maven dependency audit", gcp.Optional, func() error {
+		tmp, err := ioutil.TempDir("", "depaudit")
+		if err != nil {
+			return fmt.Errorf("creating temp dir: %v", err)
+		}
+		defer os.RemoveAll(tmp)
+		out := filepath.Join(tmp, "tree.tgf")
+		cmd := []string{mvn, "dependency:tree", "--batch-mode", "--quiet", "-DoutputType=tgf", "-DoutputFile=" + out, "-DappendOutput=true"}
+		if _, err := ctx.ExecWithErr(cmd); err != nil {
+			return err
+		}
+		content, err := ioutil.ReadFile(out)
+		if err != nil {
+			return fmt.Errorf("reading %s: %v", out, err)
+		}
+		return Record(ctx, Maven, ParseMavenTGF(string(content)))
+	})
+}
+
+// normalize merges duplicate dependencies and sorts dependencies and their requirers.
+func normalize(deps []Dependency) []Dependency {
+	byID := map[string]*Dependency{}
+	var ids []string
+	for _, d := range deps {
+		id := d.Name + "@" + d.Version
+		m, ok := byID[id]
+		if !ok {
+			m = &Dependency{Name: d.Name, Version: d.Version}
+			byID[id] = m
+			ids = append(ids, id)
+		}
+		m.Direct = m.Direct || d.Direct
+		m.RequiredBy = append(m.RequiredBy, d.RequiredBy...)
+	}
+	result := make([]Dependency, 0, len(ids))
+	for _, id := range ids {
+		d := byID[id]
+		d.RequiredBy = unique(d.RequiredBy)
+		result = append(result, *d)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Name != result[j].Name {
+			return result[i].Name < result[j].Name
+		}
+		return result[i].Version < result[j].Version
+	})
+	return result
+}
+
+func unique(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	sort.Strings(s)
+	result := s[:1]
+	for _, v := range s[1:] {
+		if v != result[len(result)-1] {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// npmNode is a node of the output of `npm ls --json`.
+type npmNode struct {
+	Version      string             `json:"version"`
+	Dependencies map[string]npmNode `json:"dependencies"`
+}
+
+// ParseNPM returns the dependencies in the output of `npm ls --json`. Dependencies that are missing
+// or were deduplicated without a version are skipped.
+func ParseNPM(out []byte) ([]Dependency, error) {
+	var root npmNode
+	if err := json.Unmarshal(out, &root); err != nil {
+		return nil, fmt.Errorf("parsing npm ls output: %v", err)
+	}
+	var deps []Dependency
+	var walk func(parent string, n npmNode)
+	walk = func(parent string, n npmNode) {
+		for name, child := range n.Dependencies {
+			if child.Version == "" {
+				continue
+			}
+			d := Dependency{Name: name, Version: child.Version, Direct: parent == ""}
+			if parent != "" {
+				d.RequiredBy = []string{parent}
+			}
+			deps = append(deps, d)
+			walk(name+"@"+child.Version, child)
+		}
+	}
+	walk("", root)
+	return deps, nil
+}
+
+// ParsePip returns the dependencies in the output of `pip show` for every installed package.
+// Packages that no other installed package requires are reported as direct.
+func ParsePip(out string) []Dependency {
+	var deps []Dependency
+	var requiredBy []string
+	cur := Dependency{}
+	flush := func() {
+		if cur.Name == "" {
+			return
+		}
+		cur.Direct = len(requiredBy) == 0
+		cur.RequiredBy = requiredBy
+		deps = append(deps, cur)
+		cur, requiredBy = Dependency{}, nil
+	}
+	versions := map[string]string{}
+	s := bufio.NewScanner(strings.NewReader(out))
+	for s.Scan() {
+		line := s.Text()
+		if line == "---" {
+			flush()
+			continue
+		}
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		v := strings.TrimSpace(kv[1])
+		switch kv[0] {
+		case "Name":
+			cur.Name = v
+		case "Version":
+			cur.Version = v
+		case "Required-by":
+			for _, r := range strings.Split(v, ",") {
+				if r = strings.TrimSpace(r); r != "" {
+					requiredBy = append(requiredBy, r)
+				}
+			}
+		}
+		if cur.Name != "" && cur.Version != "" {
+			versions[strings.ToLower(cur.Name)] = cur.Version
+		}
+	}
+	flush()
+	// pip show only lists the names of requirers; add their versions when they are known.
+	for i, d := range deps {
+		for j, r := range d.RequiredBy {
+			if v, ok := versions[strings.ToLower(r)]; ok {
+				deps[i].RequiredBy[j] = r + "@" + v
+			}
+		}
+	}
+	return deps
+}
+
+// ParseMavenTGF returns the dependencies in the Trivial Graph Format output of
+// `mvn dependency:tree -DoutputType=tgf`, which may contain the graphs of several modules. The first
+// node of each graph is the module itself, whose dependencies are reported as direct.
+func ParseMavenTGF(out string) []Dependency {
+	var deps []Dependency
+	var nodes map[string]Dependency
+	var root string
+	inEdges := false
+	s := bufio.NewScanner(strings.NewReader(out))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		switch {
+		case len(fields) == 1 && fields[0] == "#":
+			inEdges = true
+		case len(fields) == 2 && (inEdges || nodes == nil):
+			// A node after edges starts the graph of the next module.
+			nodes, root, inEdges = map[string]Dependency{}, fields[0], false
+			fallthrough
+		case len(fields) == 2:
+			nodes[fields[0]] = mavenDependency(fields[1])
+		case len(fields) >= 2 && inEdges:
+			from, ok := nodes[fields[0]]
+			to, ok2 := nodes[fields[1]]
+			if !ok || !ok2 {
+				continue
+			}
+			if fields[0] == root {
+				to.Direct = true
+			} else {
+				to.RequiredBy = []string{from.Name + "@" + from.Version}
+			}
+			deps = append(deps, to)
+		}
+	}
+	return deps
+}
+
+// mavenDependency parses a groupId:artifactId:type[:classifier]:version[:scope] label.
+func mavenDependency(label string) Dependency {
+	parts := strings.Split(label, ":")
+	if len(parts) < 4 {
+		return Dependency{Name: label}
+	}
+	d := Dependency{Name: parts[0] + ":" + parts[1], Version: parts[3]}
+	if len(parts) > 5 {
+		// The label has a classifier before the version.
+		d.Version = parts[4]
+	}
+	return d
+}
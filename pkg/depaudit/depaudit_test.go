@@ -0,0 +1,110 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package depaudit
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNPM(t *testing.T) {
+	out := `{
+  "name": "app",
+  "version": "1.0.0",
+  "dependencies": {
+    "express": {
+      "version": "4.17.1",
+      "from": "express@^4.17.1",
+      "dependencies": {
+        "accepts": {"version": "1.3.7"},
+        "debug": {"version": "2.6.9", "dependencies": {"ms": {"version": "2.0.0"}}}
+      }
+    },
+    "ms": {"version": "2.1.2"},
+    "missing": {"required": "^1.0.0", "missing": true}
+  }
+}`
+	got, err := ParseNPM([]byte(out))
+	if err != nil {
+		t.Fatalf("ParseNPM() got error: %v", err)
+	}
+	want := []Dependency{
+		{Name: "accepts", Version: "1.3.7", RequiredBy: []string{"express@4.17.1"}},
+		{Name: "debug", Version: "2.6.9", RequiredBy: []string{"express@4.17.1"}},
+		{Name: "express", Version: "4.17.1", Direct: true},
+		{Name: "ms", Version: "2.0.0", RequiredBy: []string{"debug@2.6.9"}},
+		{Name: "ms", Version: "2.1.2", Direct: true},
+	}
+	if got := normalize(got); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseNPM() = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseNPMInvalid(t *testing.T) {
+	if _, err := ParseNPM([]byte("npm ERR!")); err == nil {
+		t.Error("ParseNPM() got nil error, want error")
+	}
+}
+
+func TestParsePip(t *testing.T) {
+	out := `Name: Flask
+Version: 1.1.2
+Summary: A simple framework for building complex web applications.
+Requires: itsdangerous, Werkzeug
+Required-by: 
+---
+Name: itsdangerous
+Version: 1.1.0
+Requires: 
+Required-by: Flask
+---
+Name: Werkzeug
+Version: 1.0.1
+Requires: 
+Required-by: Flask, other
+`
+	want := []Dependency{
+		{Name: "Flask", Version: "1.1.2", Direct: true},
+		{Name: "Werkzeug", Version: "1.0.1", RequiredBy: []string{"Flask@1.1.2", "other"}},
+		{Name: "itsdangerous", Version: "1.1.0", RequiredBy: []string{"Flask@1.1.2"}},
+	}
+	if got := normalize(ParsePip(out)); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParsePip() = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseMavenTGF(t *testing.T) {
+	out := `1 com.example:app:jar:1.0
+2 com.google.guava:guava:jar:29.0-jre:compile
+3 com.google.guava:failureaccess:jar:1.0.1:compile
+4 io.netty:netty-tcnative:jar:linux-x86_64:2.0.31.Final:runtime
+#
+1 2 compile
+2 3 compile
+1 4 runtime
+10 com.example:lib:jar:1.0
+11 com.google.guava:guava:jar:29.0-jre:compile
+#
+10 11 compile
+`
+	want := []Dependency{
+		{Name: "com.google.guava:failureaccess", Version: "1.0.1", RequiredBy: []string{"com.google.guava:guava@29.0-jre"}},
+		{Name: "com.google.guava:guava", Version: "29.0-jre", Direct: true},
+		{Name: "io.netty:netty-tcnative", Version: "2.0.31.Final", Direct: true},
+	}
+	if got := normalize(ParseMavenTGF(out)); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseMavenTGF() = %+v, want %+v", got, want)
+	}
+}
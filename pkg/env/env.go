@@ -18,9 +18,15 @@ package env
 import (
 	"fmt"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
 )
 
+// functionPathRegexp matches the sub-paths at which functions can be served: slash-separated segments
+// of URL-safe characters.
+var functionPathRegexp = regexp.MustCompile(`^(/[A-Za-z0-9_~.-]+)+$`)
+
 const (
 	// Runtime is an env var used constrain autodetection in runtime buildpacks or to set runtime name in App Engine buildpacks.
 	// Runtime must be respected by each runtime buildpack.
@@ -31,15 +37,94 @@ const (
 	// RuntimeVersion must be respected by each runtime buildpack.
 	// Example: `13.7.0` for Node.js, `1.14.1` for Go.
 	RuntimeVersion = "GOOGLE_RUNTIME_VERSION"
+	// RuntimeChannel is an env var used to resolve the runtime version from a pre-release channel instead of
+	// the stable releases. It is ignored when RuntimeVersion is set, and takes precedence over versions
+	// declared in the application's files. Supported by the Go, Node.js and Python runtime buildpacks.
+	// Example: `beta` for release candidates, `nightly` for nightly builds.
+	RuntimeChannel = "GOOGLE_RUNTIME_CHANNEL"
+	// RuntimePatchPolicy is an env var used to choose how runtime versions resolved from a range or the latest
+	// release change between builds: `auto` installs the latest patch release, `pin` keeps the patch release
+	// installed by the previous build as long as the major and minor versions resolve the same. Exact versions
+	// are always installed as requested. Supported by the Go, Node.js and Python runtime buildpacks.
+	// Example: `pin` keeps Node.js 20.11.0 from the previous build when `engines.node` is `20.x` and 20.11.1 is released.
+	RuntimePatchPolicy = "GOOGLE_RUNTIME_PATCH_POLICY"
+
+	// SourceSubdir is an env var used to build the application in a subdirectory of the uploaded source,
+	// such as one application of a monorepo. Every buildpack detects and builds with the subdirectory
+	// as the application root, and processes start in it.
+	// Example: `services/api` builds the application in /workspace/services/api.
+	SourceSubdir = "GOOGLE_SOURCE_SUBDIR"
+
+	// ProcessArgs is the format of env vars used to append arguments to the command of a process, given
+	// the process type in upper case with dashes replaced by underscores. The value is split into
+	// arguments the way a shell would split it, honoring quotes and backslashes.
+	// Example: `GOOGLE_PROCESS_WEB_ARGS="--log-level debug"` appends two arguments to the web process.
+	ProcessArgs = "GOOGLE_PROCESS_%s_ARGS"
 
 	// DebugMode enables more verbose logging. The value is unused; only the presence of the env var is required to enable.
 	DebugMode = "GOOGLE_DEBUG"
 
+	// Strict is an env var used to fail the build when optional steps, such as recording build
+	// statistics, fail. By default such failures are logged as warnings.
+	// Example: `true`, `True`, `1` will enable strict mode.
+	Strict = "GOOGLE_STRICT"
+
+	// NormalizePermissions is an env var used to normalize the permissions of files in launch layers
+	// before they are exported. It is a comma-separated list of rules, or `all` for every rule:
+	// `setuid` strips setuid and setgid bits, `readonly` removes write bits from files, and `dirs`
+	// sets directory permissions to 0755.
+	// Example: `setuid,dirs` strips setuid bits and fixes directory permissions.
+	NormalizePermissions = "GOOGLE_NORMALIZE_PERMISSIONS"
+
+	// BuildPhase is an experimental env var used to split the build into two phases. In the `plan` phase,
+	// buildpacks only report which layers they would reuse from the cache and which they would rebuild,
+	// and why; the `apply` phase, the default, builds the image.
+	// Example: `plan` reports the layers that the build would rebuild without rebuilding them.
+	BuildPhase = "GOOGLE_BUILD_PHASE"
+
+	// EgressLog is an env var used to record the hosts that commands run by buildpacks contact during the
+	// build. Commands are pointed at a logging proxy with the standard proxy env vars, and each buildpack
+	// logs the hosts contacted during its build and reports them in the builder output.
+	// Example: `true`, `True`, `1` will enable egress logging.
+	EgressLog = "GOOGLE_EGRESS_LOG"
+
+	// ArtifactDir is an env var used to copy the artifacts that buildpacks compile, such as Go binaries,
+	// executable jars and npm build output, to a directory, usually mounted from the host, so that
+	// pipelines can publish them in addition to or instead of the image.
+	// Example: `/artifacts` with `pack build --volume $PWD/out:/artifacts`.
+	ArtifactDir = "GOOGLE_ARTIFACT_DIR"
+
+	// BuildLocale is an env var used to select the language of the user-facing errors and warnings in
+	// the message catalog of gcpbuildpack, such as those shown in localized consoles. Messages without a
+	// translation, and locales without a catalog, fall back to English.
+	// Example: `ja`, `ja-JP` or `ja_JP.UTF-8` shows messages in Japanese.
+	BuildLocale = "GOOGLE_BUILD_LOCALE"
+
+	// BuilderDigest and RunImageDigest are env vars used by platforms to pass the digests of the builder
+	// and run images, which are part of every cache key so that caches are rebuilt when they change.
+	// Example: `sha256:4f8a...`.
+	BuilderDigest  = "GOOGLE_BUILDER_DIGEST"
+	RunImageDigest = "GOOGLE_RUN_IMAGE_DIGEST"
+
+	// CacheIgnoreStack is an env var used to keep caches when the builder or run image changes.
+	// Example: `true`, `True`, `1` will leave the images out of cache keys.
+	CacheIgnoreStack = "GOOGLE_CACHE_IGNORE_STACK"
+
 	// DevMode is an env var used to enable development mode in buildpacks.
 	// DevMode should be respected by all buildpacks that are not product-specific.
 	// Example: `true`, `True`, `1` will enable development mode.
 	DevMode = "GOOGLE_DEVMODE"
 
+	// WorkstationMode is an env var used to speed up repeated builds of the same application in interactive
+	// environments, such as Cloud Workstations and Cloud Shell: detection results are reused while the
+	// application files are unchanged, and cached version manifests are used without revalidation.
+	// Example: `true`, `True`, `1` will enable workstation mode.
+	WorkstationMode = "GOOGLE_WORKSTATION_MODE"
+	// WorkstationCacheDir is an env var used to override the persistent directory, mounted into every build,
+	// in which workstation mode keeps detection results.
+	// Example: `/cache`; the default is `/var/cache/google-workstation`.
+	WorkstationCacheDir = "GOOGLE_WORKSTATION_CACHE_DIR"
+
 	// Entrypoint is an env var used to override the default entrypoint.
 	// Entrypoint should be respected by at least one buildpack in builders that are not product-specific.
 	// Example: `gunicorn -p :8080 main:app` for Python.
@@ -49,10 +134,21 @@ const (
 	// Buildpacks for Go and Java support clearing the source.
 	ClearSource = "GOOGLE_CLEAR_SOURCE"
 
+	// ClearTestSources is an env var used to remove tests and test fixtures from the final image.
+	// Functions Framework buildpacks for interpreted runtimes support clearing test sources.
+	// Example: `true`, `True`, `1` will remove `__tests__/`, `test/`, `tests/`, `fixtures/` and `__fixtures__/`.
+	ClearTestSources = "GOOGLE_CLEAR_TEST_SOURCES"
+	// ClearTestSourcesPatterns is an env var used to override the patterns removed by ClearTestSources.
+	// Example: `spec/,*_spec.rb` removes spec directories and RSpec files; a trailing slash matches directories only.
+	ClearTestSourcesPatterns = "GOOGLE_CLEAR_TEST_SOURCES_PATTERNS"
+
 	// Buildable is an env var used to specify the buildable unit to build.
 	// Buildable should be respected by buildpacks that build source.
 	// Example: `./maindir` for Go will build the package rooted at maindir.
 	Buildable = "GOOGLE_BUILDABLE"
+	// Buildables is an env var used to specify several buildable units, each built into its own binary.
+	// Example: `./cmd/...` for Go builds every main package under cmd and registers each as a process.
+	Buildables = "GOOGLE_BUILDABLES"
 
 	// BuildArgs is an env var used to append arguments to the build command.
 	// Example: `-Pprod` for Maven apps run "mvn clear package ... -Pprod" command.
@@ -75,6 +171,18 @@ const (
 	FunctionSource = "GOOGLE_FUNCTION_SOURCE"
 	// FunctionSourceLaunch is a launch time version of FunctionSource.
 	FunctionSourceLaunch = "FUNCTION_SOURCE"
+	// FunctionSourceExclude is an env var used to add comma-separated patterns, in .gcloudignore syntax,
+	// to the files excluded when the function source is relocated. They take precedence over .gcloudignore.
+	// Example: `data/,!data/schema.json` excludes the data directory except for its schema.
+	FunctionSourceExclude = "GOOGLE_FUNCTION_SOURCE_EXCLUDE"
+	// FunctionBuildInPlace is an env var used to build a Go function without moving its source into a
+	// subdirectory of the application root. The main module is generated in a layer instead.
+	// Example: `true`, `True`, `1` build the function in place.
+	FunctionBuildInPlace = "GOOGLE_FUNCTION_BUILD_IN_PLACE"
+	// PrintSourceExclusions is an env var used to print the effective exclusion set of the relocated
+	// function source, with the files it excludes and the sizes of the files it keeps.
+	// Example: `true`, `True`, `1` print the exclusions.
+	PrintSourceExclusions = "GOOGLE_PRINT_SOURCE_EXCLUSIONS"
 
 	// FunctionSignatureType is an env var used to specify function signature type.
 	// FunctionSignatureType must be respected by all functions-framework buildpacks.
@@ -83,12 +191,197 @@ const (
 	// FunctionSignatureTypeLaunch is a launch time version of FunctionSignatureType.
 	FunctionSignatureTypeLaunch = "FUNCTION_SIGNATURE_TYPE"
 
+	// FunctionConcurrency is an env var used to specify how many requests a function instance is deployed to
+	// serve concurrently, so that the function server is configured to match.
+	// Example: `80` labels the image with google.function-concurrency=80 and sizes the server's threads or processes.
+	FunctionConcurrency = "GOOGLE_FUNCTION_CONCURRENCY"
+	// FunctionConcurrencyLaunch is a launch time version of FunctionConcurrency.
+	FunctionConcurrencyLaunch = "FUNCTION_CONCURRENCY"
+
+	// FunctionPath is an env var used to serve a function at a sub-path instead of "/", so that functions
+	// sharing a domain can be routed by path. The prefix is stripped from the path the function sees.
+	// Example: `/orders` serves the function at /orders and /orders/... only.
+	FunctionPath = "GOOGLE_FUNCTION_PATH"
+	// FunctionPathLaunch is a launch time version of FunctionPath.
+	FunctionPathLaunch = "FUNCTION_PATH"
+
+	// FunctionReadHeaderTimeout is an env var used to set the ReadHeaderTimeout of the HTTP server in generated Go function mains.
+	// Example: `10s` closes connections that do not send request headers within 10 seconds.
+	FunctionReadHeaderTimeout = "GOOGLE_FUNCTION_READ_HEADER_TIMEOUT"
+	// FunctionMaxHeaderBytes is an env var used to set the MaxHeaderBytes of the HTTP server in generated Go function mains.
+	// Example: `65536` rejects requests whose headers exceed 64KiB.
+	FunctionMaxHeaderBytes = "GOOGLE_FUNCTION_MAX_HEADER_BYTES"
+	// FunctionH2C is an env var used to serve HTTP/2 over cleartext (h2c) in generated Go function mains.
+	// Example: `true`, `True`, `1` will accept h2c connections in addition to HTTP/1.1.
+	FunctionH2C = "GOOGLE_FUNCTION_H2C"
+	// FunctionsFrameworkVersion is an env var used to specify the version of the Go functions framework
+	// required by functions whose go.mod does not pin one.
+	// Example: `v1.2.0` builds the function with functions-framework-go v1.2.0.
+	FunctionsFrameworkVersion = "GOOGLE_FUNCTIONS_FRAMEWORK_VERSION"
+
+	// FunctionsConformance is an env var used to boot the built function during the build and send it a request
+	// of its signature type, failing the build if the function does not start or responds with a server error.
+	// Example: `true`, `True`, `1` will run the conformance check.
+	FunctionsConformance = "GOOGLE_FUNCTIONS_CONFORMANCE"
+
+	// NodeJSBundle is an env var used to bundle Node.js functions and their dependencies into a single
+	// minified file with esbuild, which loads faster than many small files.
+	// Example: `true`, `True`, `1` will bundle the function.
+	NodeJSBundle = "GOOGLE_NODEJS_BUNDLE"
+
+	// NodeJSVerifySignatures is an env var used to verify the registry signatures and provenance
+	// attestations of the installed npm packages with `npm audit signatures`. With `warn`, unverifiable
+	// packages are logged; with `fail`, they fail the build.
+	// Example: `fail` fails the build if a package has an invalid signature or attestation.
+	NodeJSVerifySignatures = "GOOGLE_NODEJS_VERIFY_SIGNATURES"
+	// NodeJSCriticalPackages is an env var used to limit the packages whose failed verification fails
+	// the build with NodeJSVerifySignatures=fail to a comma-separated list; others are only logged.
+	// Example: `express,jsonwebtoken` only fails the build if those packages cannot be verified.
+	NodeJSCriticalPackages = "GOOGLE_NODEJS_CRITICAL_PACKAGES"
+
+	// GoVersion is an env var used to specify the Go version to install, either exactly or as a constraint
+	// resolved to the newest matching stable release. It is ignored when RuntimeVersion is set.
+	// Example: `~1.22` or `1.22.x` installs the latest 1.22 patch release, `>=1.21 <1.23` the latest 1.22 release.
+	GoVersion = "GOOGLE_GO_VERSION"
 	// GoGCFlags is an env var used to pass through compilation flags to the Go compiler.
 	// Example: `-N -l` is used during debugging to disable optimizations and inlining.
 	GoGCFlags = "GOOGLE_GOGCFLAGS"
 	// GoLDFlags is an env var used to pass through linker flags to the Go linker.
 	// Example: `-s -w` is sometimes used to strip and reduce binary size.
 	GoLDFlags = "GOOGLE_GOLDFLAGS"
+	// GoLinkerFlags is an env var used to pass validated flags to the Go linker. Unlike GoLDFlags, only
+	// flags that do not change how the binary is linked or run external programs are allowed.
+	// Example: `-s -w -X main.env=prod` strips the binary and sets main.env.
+	GoLinkerFlags = "GOOGLE_GO_LDFLAGS"
+	// GoStamp is an env var used to disable stamping the VCS revision, build time and buildpack version into
+	// Go binaries with -X flags, which is enabled by default.
+	// Example: `false`, `False`, `0` disable stamping.
+	GoStamp = "GOOGLE_GO_STAMP"
+	// GoRace is an env var used to compile Go apps and functions with the race detector, e.g. for canaries.
+	// Example: `true`, `True`, `1` will pass -race to `go build` and label the image with google.go-race=true.
+	GoRace = "GOOGLE_GO_RACE"
+	// GoTest is an env var used to run the tests of a Go function module before the function is built.
+	// Example: `true`, `True`, `1` run `go test ./...` and fail the build if the tests fail.
+	GoTest = "GOOGLE_GO_TEST"
+	// GoVet is an env var used to run `go vet` on a Go module before it is built.
+	// Example: `true`, `True`, `1` run `go vet ./...` and fail the build on findings.
+	GoVet = "GOOGLE_GO_VET"
+	// GoStaticcheck is an env var used to run staticcheck on a Go module before it is built.
+	// Example: `true`, `True`, `1` run `staticcheck ./...` and fail the build on findings.
+	GoStaticcheck = "GOOGLE_GO_STATICCHECK"
+	// GoStaticcheckVersion is an env var used to specify the staticcheck release installed for GoStaticcheck.
+	// Example: `2023.1.7` installs staticcheck 2023.1.7, e.g. for apps built with an older Go.
+	GoStaticcheckVersion = "GOOGLE_GO_STATICCHECK_VERSION"
+	// FunctionSelfTest is an env var used to generate a test alongside the main package of a Go function and run
+	// it before the function is built, to check that the function registers and serves a request.
+	// Example: `true`, `True`, `1` generate and run main_test.go.
+	FunctionSelfTest = "GOOGLE_FUNCTION_SELF_TEST"
+	// GoOS and GoArch are env vars used to cross-compile Go apps for another operating system or architecture
+	// than the builder's, e.g. to build arm64 images on amd64 builders. They default to the target of the
+	// image set by the platform, and must match it when the platform sets one.
+	// Example: `linux` and `arm64`.
+	GoOS   = "GOOGLE_GOOS"
+	GoArch = "GOOGLE_GOARCH"
+	// GoExperiment is an env var used to enable Go toolchain experiments, validated against the installed Go,
+	// which must be 1.18 or later. It is appended to any GOEXPERIMENT set for the build.
+	// Example: `boringcrypto` links the app against BoringCrypto; `loopvar` and `arenas` enable language and runtime experiments.
+	GoExperiment = "GOOGLE_GOEXPERIMENT"
+	// GoFlags is an env var used to pass flags to every go command of the build, validated against the installed Go.
+	// It is appended to any GOFLAGS set for the build. Both may only set the flags of an allowlist that do not
+	// conflict with the flags the buildpacks pass to the go command, such as -trimpath, -buildvcs and -mod.
+	// Example: `-trimpath -tags=netgo` removes file system paths from the binary and builds with the netgo tag.
+	GoFlags = "GOOGLE_GOFLAGS"
+	// GoBuildTags is an env var used to enable build tags in every go command of the build, for apps,
+	// functions and the mains generated for functions. The tags are merged with those set in GOFLAGS.
+	// Example: `timetzdata,prod` builds the files guarded by the timetzdata and prod build tags.
+	GoBuildTags = "GOOGLE_GO_BUILD_TAGS"
+
+	// GoNonRoot is an env var used to configure compiled Go apps to run as a fixed non-root user.
+	// Example: `true`, `True`, `1` will add a minimal passwd entry and label the image with the user.
+	GoNonRoot = "GOOGLE_GO_NONROOT"
+
+	// GoContainerLimits is an env var used to disable setting GOMEMLIMIT and GOMAXPROCS from the memory
+	// and CPU limits of the container when Go apps start.
+	// Example: `false`, `False`, `0` leave the Go runtime defaults in place.
+	GoContainerLimits = "GOOGLE_GO_CONTAINER_LIMITS"
+
+	// GoStatic is an env var used to build Go apps as static binaries whose launch layers hold everything
+	// they read from the image: the binary, CA certificates and the time zone database. The build falls
+	// back to a dynamically linked binary if a package requires cgo.
+	// Example: `true`, `True`, `1` will build a static binary and label the image with google.go-static=true.
+	GoStatic = "GOOGLE_GO_STATIC"
+
+	// GoMinimal is an env var used to build Go apps as fully static binaries, with cgo disabled and the
+	// netgo and osusergo build tags, for the minimal run image. Unlike GoStatic, the build fails if the
+	// app cannot be built this way, as the binary would not run on the minimal run image.
+	// Example: `true`, `True`, `1` will build a fully static binary and label the image with google.run-image.
+	GoMinimal = "GOOGLE_GO_MINIMAL"
+
+	// GoSumDBStrict is an env var used to refuse to build Go modules when the checksum database is bypassed
+	// for any module of go.sum, for example with GOSUMDB=off, GONOSUMDB, GOPRIVATE or GOFLAGS=-mod=mod.
+	// Example: `true`, `True`, `1` will fail the build instead of warning.
+	GoSumDBStrict = "GOOGLE_GO_SUMDB_STRICT"
+
+	// DjangoCheckDeploy is an env var used to run `manage.py check --deploy` when building Django applications.
+	// Example: `true`, `True`, `1` will fail the build on any deployment check warning.
+	DjangoCheckDeploy = "GOOGLE_DJANGO_CHECK_DEPLOY"
+
+	// NPMIgnoreScripts is an env var used to install npm dependencies without running their lifecycle scripts.
+	// Example: `true`, `True`, `1` will pass --ignore-scripts to npm.
+	NPMIgnoreScripts = "GOOGLE_NPM_IGNORE_SCRIPTS"
+	// NPMAllowScripts is an env var used to list the packages whose install scripts run despite NPMIgnoreScripts,
+	// in a second pass after all dependencies are installed.
+	// Example: `bcrypt,sharp` builds the native modules of bcrypt and sharp.
+	NPMAllowScripts = "GOOGLE_NPM_ALLOW_SCRIPTS"
+
+	// PythonInstaller is an env var used to select the tool that installs Python dependencies: `pip`,
+	// the default, or `uv`, which is several times faster.
+	// Example: `uv` installs requirements.txt with `uv pip install`.
+	PythonInstaller = "GOOGLE_PYTHON_INSTALLER"
+
+	// ExecHeartbeat is an env var used to set how long a command may run without output before a
+	// "still running" line is logged, to avoid no-output timeouts on build platforms. `0` disables heartbeats.
+	// Example: `30s` logs a heartbeat after every 30 seconds of silence; the default is `1m`.
+	ExecHeartbeat = "GOOGLE_EXEC_HEARTBEAT"
+
+	// ProgressPipe is an env var used to name a named pipe or file to which buildpacks write progress
+	// events, one JSON object per line, for platforms that render build progress.
+	// Example: `/tmp/progress` receives events for build phases, downloads and warnings.
+	ProgressPipe = "GOOGLE_PROGRESS_PIPE"
+
+	// WarmCacheDir is an env var used to override where buildpacks look for artifacts pre-populated in the builder image.
+	// Example: `/opt/warmcache`; the default is `/var/cache/google-buildpacks`.
+	WarmCacheDir = "GOOGLE_WARM_CACHE_DIR"
+
+	// DownloadSlotsDir is an env var used to name a directory shared by the builds running on one worker,
+	// in which buildpacks coordinate concurrent downloads. It may contain a limits.json file with the
+	// worker-wide limits, such as {"maxConcurrency": 4, "rateLimit": "20M"}.
+	// Example: `/var/run/google-buildpacks/downloads`.
+	DownloadSlotsDir = "GOOGLE_DOWNLOAD_SLOTS_DIR"
+
+	// DownloadConcurrency is an env var used to lower the number of downloads that may run at once on the
+	// worker while this build downloads. It requires DownloadSlotsDir.
+	// Example: `2`.
+	DownloadConcurrency = "GOOGLE_DOWNLOAD_CONCURRENCY"
+
+	// DownloadRateLimit is an env var used to lower the bandwidth of each download, in bytes per second
+	// with an optional K, M or G suffix.
+	// Example: `10M` limits each download to 10 MiB/s.
+	DownloadRateLimit = "GOOGLE_DOWNLOAD_RATE_LIMIT"
+
+	// AssertPrefix is a prefix for env vars that assert properties of the build, which fails if they do not hold.
+	// The remainder names the property: `GOOGLE_ASSERT_RUNTIME_VERSION` and `GOOGLE_ASSERT_FRAMEWORK` are supported.
+	// Example: `GOOGLE_ASSERT_RUNTIME_VERSION=3.8` fails the build unless a Python 3.8.x runtime is installed.
+	AssertPrefix = "GOOGLE_ASSERT_"
+
+	// ImageUser is an env var used to set the user, and optionally the group, that the image runs as,
+	// by numeric ID. It is validated against the user of the run image and recorded in image labels.
+	// Example: `65532` or `65532:65532` label the image with google.run-as-user=65532 and google.run-as-non-root=true.
+	ImageUser = "GOOGLE_IMAGE_USER"
+	// ImageWorkdir is an env var used to set the working directory of the image, which must be the
+	// application directory or a directory below it. It is recorded in the google.workdir image label.
+	// Example: `/workspace/app` runs the processes of the image in the app subdirectory.
+	ImageWorkdir = "GOOGLE_IMAGE_WORKDIR"
 
 	// LabelPrefix is a prefix for values that will be added to the final
 	// built user container. The prefix is stripped and the remainder forms the
@@ -96,32 +389,94 @@ const (
 	// label on the final container of "abc=Some-Value". The label key itself is
 	// lowercased, underscores changed to dashes, and is prefixed with "google.".
 	LabelPrefix = "GOOGLE_LABEL_"
+
+	// HealthcheckPath is an env var used to install a healthcheck command, registered as the `healthcheck`
+	// process, that requests the path from the application and exits with a non-zero status unless the
+	// response is successful, for platforms that run command healthchecks rather than HTTP probes.
+	// Example: `/healthz`.
+	HealthcheckPath = "GOOGLE_HEALTHCHECK_PATH"
+	// HealthcheckPathLaunch is a launch time version of HealthcheckPath.
+	HealthcheckPathLaunch = "HEALTHCHECK_PATH"
+	// HealthcheckPort is an env var used to set the port requested by the healthcheck command. By default
+	// the command requests $PORT, or 8080 if PORT is not set.
+	// Example: `8081`.
+	HealthcheckPort = "GOOGLE_HEALTHCHECK_PORT"
+	// HealthcheckPortLaunch is a launch time version of HealthcheckPort.
+	HealthcheckPortLaunch = "HEALTHCHECK_PORT"
+	// HealthcheckTimeout is an env var used to set how long the healthcheck command waits for a response.
+	// Example: `5s`; the default is `1s`.
+	HealthcheckTimeout = "GOOGLE_HEALTHCHECK_TIMEOUT"
+	// HealthcheckTimeoutLaunch is a launch time version of HealthcheckTimeout.
+	HealthcheckTimeoutLaunch = "HEALTHCHECK_TIMEOUT"
+
+	// ConfigRenderEnv is an env var used to specify which env vars may be substituted into *.tmpl files.
+	// Example: `PORT,BACKEND_HOST` allows `${PORT}` and `${BACKEND_HOST}` in nginx.conf.tmpl.
+	ConfigRenderEnv = "GOOGLE_CONFIG_RENDER_ENV"
 )
 
-// IsDebugMode returns true if the buildpack debug mode is enabled.
-func IsDebugMode() (bool, error) {
-	val, found := os.LookupEnv(DebugMode)
+// Bool returns the boolean value of the env var name, or def if it is not set.
+func Bool(name string, def bool) (bool, error) {
+	val, found := os.LookupEnv(name)
 	if !found {
-		return false, nil
+		return def, nil
 	}
 	parsed, err := strconv.ParseBool(val)
 	if err != nil {
-		return false, fmt.Errorf("parsing %s: %v", DebugMode, err)
+		return false, fmt.Errorf("parsing %s: %v", name, err)
 	}
 	return parsed, nil
 }
 
-// IsDevMode indicates that the builder is running in Development mode.
-func IsDevMode() (bool, error) {
-	devMode, present := os.LookupEnv(DevMode)
-	if !present {
+// IsDebugMode returns true if the buildpack debug mode is enabled.
+func IsDebugMode() (bool, error) {
+	return Bool(DebugMode, false)
+}
+
+// IsPlanPhase returns true if the build only plans which layers it would rebuild, as requested with BuildPhase.
+// BuildPhase is set to plan or apply rather than to a boolean, so it is not parsed with Bool.
+func IsPlanPhase() (bool, error) {
+	switch v := os.Getenv(BuildPhase); v {
+	case "", "apply":
 		return false, nil
+	case "plan":
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid %s %q, must be plan or apply", BuildPhase, v)
 	}
+}
 
-	parsed, err := strconv.ParseBool(devMode)
-	if err != nil {
-		return false, fmt.Errorf("parsing %s: %v", DevMode, err)
+// IsDevMode indicates that the builder is running in Development mode.
+func IsDevMode() (bool, error) {
+	return Bool(DevMode, false)
+}
+
+// FunctionConcurrencyHint returns the number of concurrent requests set with FunctionConcurrency, or 0 if it is not set.
+func FunctionConcurrencyHint() (int, error) {
+	val, found := os.LookupEnv(FunctionConcurrency)
+	if !found {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid %s %q, must be a positive integer", FunctionConcurrency, val)
 	}
+	return n, nil
+}
 
-	return parsed, nil
+// FunctionPathPrefix returns the sub-path set with FunctionPath, without a trailing slash, or "" if the
+// function is served at the root.
+func FunctionPathPrefix() (string, error) {
+	val := strings.TrimSuffix(os.Getenv(FunctionPath), "/")
+	if val == "" {
+		return "", nil
+	}
+	if !functionPathRegexp.MatchString(val) {
+		return "", fmt.Errorf("invalid %s %q, must be a path such as /orders or /api/orders", FunctionPath, val)
+	}
+	for _, seg := range strings.Split(val[1:], "/") {
+		if seg == "." || seg == ".." {
+			return "", fmt.Errorf("invalid %s %q, must not contain . or .. segments", FunctionPath, val)
+		}
+	}
+	return val, nil
 }
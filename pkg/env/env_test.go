@@ -90,3 +90,234 @@ func TestIsDebugMode(t *testing.T) {
 		})
 	}
 }
+
+func TestBool(t *testing.T) {
+	const name = "GOOGLE_TEST_BOOL"
+	testCases := []struct {
+		name    string
+		notSet  bool
+		value   string
+		def     bool
+		wantErr bool
+		want    bool
+	}{
+		{
+			name:   "not set",
+			notSet: true,
+		},
+		{
+			name:   "not set with default",
+			notSet: true,
+			def:    true,
+			want:   true,
+		},
+		{
+			name:  "set to false with default",
+			value: "false",
+			def:   true,
+		},
+		{
+			name:  "set to true",
+			value: "true",
+			want:  true,
+		},
+		{
+			name:    "set to bad value",
+			value:   "yes",
+			def:     true,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.notSet {
+				os.Unsetenv(name)
+			} else {
+				os.Setenv(name, tc.value)
+				defer os.Unsetenv(name)
+			}
+
+			got, err := Bool(name, tc.def)
+
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("Bool(%q, %t) got error: %v, want error: %t", name, tc.def, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("Bool(%q, %t) = %t, want %t", name, tc.def, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIsPlanPhase(t *testing.T) {
+	testCases := []struct {
+		name    string
+		value   string
+		wantErr bool
+		want    bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:  "apply",
+			value: "apply",
+		},
+		{
+			name:  "plan",
+			value: "plan",
+			want:  true,
+		},
+		{
+			name:    "bad value",
+			value:   "dry-run",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := os.Setenv(BuildPhase, tc.value); err != nil {
+				t.Fatalf("Failed to set env: %v", err)
+			}
+			defer func() {
+				if err := os.Unsetenv(BuildPhase); err != nil {
+					t.Fatalf("Failed to unset env: %v", err)
+				}
+			}()
+
+			got, err := IsPlanPhase()
+
+			if err != nil != tc.wantErr {
+				t.Fatalf("got err=%t, want err=%t: %v", err != nil, tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("IsPlanPhase=%t, want=%t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFunctionConcurrencyHint(t *testing.T) {
+	testCases := []struct {
+		name    string
+		value   string
+		unset   bool
+		wantErr bool
+		want    int
+	}{
+		{
+			name:  "not set",
+			unset: true,
+		},
+		{
+			name:  "set",
+			value: "80",
+			want:  80,
+		},
+		{
+			name:    "zero",
+			value:   "0",
+			wantErr: true,
+		},
+		{
+			name:    "bad value",
+			value:   "many",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if !tc.unset {
+				if err := os.Setenv(FunctionConcurrency, tc.value); err != nil {
+					t.Fatalf("Failed to set env: %v", err)
+				}
+				defer func() {
+					if err := os.Unsetenv(FunctionConcurrency); err != nil {
+						t.Fatalf("Failed to unset env: %v", err)
+					}
+				}()
+			}
+
+			got, err := FunctionConcurrencyHint()
+
+			if err != nil != tc.wantErr {
+				t.Fatalf("got err=%t, want err=%t: %v", err != nil, tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("FunctionConcurrencyHint=%d, want=%d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFunctionPathPrefix(t *testing.T) {
+	testCases := []struct {
+		name    string
+		value   string
+		wantErr bool
+		want    string
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:  "root",
+			value: "/",
+		},
+		{
+			name:  "set",
+			value: "/orders",
+			want:  "/orders",
+		},
+		{
+			name:  "nested with trailing slash",
+			value: "/api/v1.2/orders/",
+			want:  "/api/v1.2/orders",
+		},
+		{
+			name:    "relative",
+			value:   "orders",
+			wantErr: true,
+		},
+		{
+			name:    "empty segment",
+			value:   "/api//orders",
+			wantErr: true,
+		},
+		{
+			name:    "dot segment",
+			value:   "/api/../orders",
+			wantErr: true,
+		},
+		{
+			name:    "pattern",
+			value:   "/orders/{id}",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := os.Setenv(FunctionPath, tc.value); err != nil {
+				t.Fatalf("Failed to set env: %v", err)
+			}
+			defer func() {
+				if err := os.Unsetenv(FunctionPath); err != nil {
+					t.Fatalf("Failed to unset env: %v", err)
+				}
+			}()
+
+			got, err := FunctionPathPrefix()
+
+			if err != nil != tc.wantErr {
+				t.Fatalf("got err=%t, want err=%t: %v", err != nil, tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("FunctionPathPrefix=%q, want=%q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package frameworks is a registry of web framework detectors.
+// Language buildpacks consult the registry to choose the web process command, launch env defaults,
+// and health endpoint for the framework an application uses, rather than hard-coding each framework.
+package frameworks
+
+import (
+	"fmt"
+	"sort"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// healthPathLabel is the label, prefixed with "google.", recording the framework's health endpoint.
+	healthPathLabel = "health_path"
+)
+
+// Framework describes the conventions of a framework detected in an application.
+type Framework struct {
+	// Name identifies the framework, e.g. "django".
+	Name string
+	// Command is the default web process command, or empty if the language default applies.
+	Command []string
+	// Env holds launch env var defaults; values set by the user take precedence.
+	Env map[string]string
+	// HealthPath is the HTTP path on which the framework serves health checks, or empty if none.
+	HealthPath string
+	// DocumentRoot is the directory served by the web server, relative to the application root,
+	// or empty if the application root is served.
+	DocumentRoot string
+	// Candidates lists the application objects found when more than one could be served, in which
+	// case Command is empty and the user must choose an entrypoint.
+	Candidates []string
+}
+
+// Detector recognizes a framework in an application.
+type Detector struct {
+	// Name identifies the framework, and must be unique within a language.
+	Name string
+	// Language is the language whose buildpacks consult the detector, e.g. "python".
+	Language string
+	// Detect returns the framework if the application uses it, or nil otherwise.
+	Detect func(ctx *gcp.Context) (*Framework, error)
+}
+
+var (
+	detectors = map[string][]Detector{}
+)
+
+// Register adds a detector to the registry. Detectors for a language are consulted in registration
+// order, so detectors for frameworks built on top of other frameworks must be registered first.
+// Register panics if a detector with the same name is already registered for the language.
+func Register(d Detector) {
+	for _, r := range detectors[d.Language] {
+		if r.Name == d.Name {
+			panic(fmt.Sprintf("frameworks: detector %q already registered for %s", d.Name, d.Language))
+		}
+	}
+	detectors[d.Language] = append(detectors[d.Language], d)
+}
+
+// Names returns the sorted names of the frameworks registered for language.
+func Names(language string) []string {
+	var names []string
+	for _, d := range detectors[language] {
+		names = append(names, d.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Detect returns the first framework detected in the application for language, or nil if none is.
+func Detect(ctx *gcp.Context, language string) (*Framework, error) {
+	for _, d := range detectors[language] {
+		fw, err := d.Detect(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("detecting %s: %w", d.Name, err)
+		}
+		if fw != nil {
+			if fw.Name == "" {
+				fw.Name = d.Name
+			}
+			ctx.Logf("Detected %s framework: %s", language, fw.Name)
+			if err := ctx.RecordFact(gcp.FactFramework, fw.Name); err != nil {
+				return nil, err
+			}
+			return fw, nil
+		}
+	}
+	return nil, nil
+}
+
+// ConfigureLaunch sets the framework's launch env defaults on l and labels the image with its health endpoint.
+func (fw *Framework) ConfigureLaunch(ctx *gcp.Context, l *libcnb.Layer) {
+	keys := make([]string, 0, len(fw.Env))
+	for k := range fw.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		l.LaunchEnvironment.Default(k, fw.Env[k])
+	}
+	if fw.HealthPath != "" {
+		ctx.AddLabel(healthPathLabel, fw.HealthPath)
+	}
+}
+
+// portCommand returns a web process command run by bash so that $PORT is expanded at launch.
+func portCommand(cmd string) []string {
+	return []string{"/bin/bash", "-c", "exec " + cmd}
+}
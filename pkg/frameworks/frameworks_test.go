@@ -0,0 +1,276 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frameworks
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name     string
+		language string
+		files    map[string]string
+		want     *Framework
+	}{
+		{
+			name:     "django with gunicorn",
+			language: "python",
+			files: map[string]string{
+				"manage.py":        `os.environ.setdefault("DJANGO_SETTINGS_MODULE", "mysite.settings")`,
+				"requirements.txt": "Django==3.1\ngunicorn\n",
+			},
+			want: &Framework{Name: "django", Env: map[string]string{"DJANGO_SETTINGS_MODULE": "mysite.settings"}, Command: []string{"/bin/bash", "-c", "exec gunicorn --bind :$PORT mysite.wsgi:application"}},
+		},
+		{
+			name:     "django with WSGI_APPLICATION",
+			language: "python",
+			files: map[string]string{
+				"manage.py":                     `os.environ.setdefault("DJANGO_SETTINGS_MODULE", "config.settings.production")`,
+				"config/settings/production.py": "WSGI_APPLICATION = 'config.wsgi.app'\n",
+				"requirements.txt":              "Django==3.1\ngunicorn\n",
+			},
+			want: &Framework{Name: "django", Env: map[string]string{"DJANGO_SETTINGS_MODULE": "config.settings.production"}, Command: []string{"/bin/bash", "-c", "exec gunicorn --bind :$PORT config.wsgi:app"}},
+		},
+		{
+			name:     "django without gunicorn",
+			language: "python",
+			files: map[string]string{
+				"manage.py":        `os.environ.setdefault("DJANGO_SETTINGS_MODULE", "mysite.settings")`,
+				"requirements.txt": "django>=3\n",
+			},
+			want: &Framework{Name: "django", Env: map[string]string{"DJANGO_SETTINGS_MODULE": "mysite.settings"}},
+		},
+		{
+			name:     "flask",
+			language: "python",
+			files: map[string]string{
+				"main.py":          "app = Flask(__name__)",
+				"requirements.txt": "Flask[async]\ngunicorn==20.0.4\n",
+			},
+			want: &Framework{Name: "flask", Command: []string{"/bin/bash", "-c", "exec gunicorn --bind :$PORT main:app"}},
+		},
+		{
+			name:     "flask app in package",
+			language: "python",
+			files: map[string]string{
+				"myapp/__init__.py": "import flask\n\napplication = flask.Flask(__name__)\n",
+				"myapp/views.py":    "from myapp import application\n",
+				"requirements.txt":  "flask\ngunicorn\n",
+			},
+			want: &Framework{Name: "flask", Command: []string{"/bin/bash", "-c", "exec gunicorn --bind :$PORT myapp:application"}},
+		},
+		{
+			name:     "flask ambiguous",
+			language: "python",
+			files: map[string]string{
+				"main.py":          "app = Flask(__name__)\n",
+				"admin/server.py":  "admin: Flask = Flask(__name__)\n",
+				"requirements.txt": "flask\ngunicorn\n",
+			},
+			want: &Framework{Name: "flask", Candidates: []string{"admin.server:admin", "main:app"}},
+		},
+		{
+			name:     "flask app in virtualenv ignored",
+			language: "python",
+			files: map[string]string{
+				"app.py":                    "app = Flask(__name__)\n",
+				"venv/lib/flask/example.py": "app = Flask(__name__)\n",
+				"requirements.txt":          "flask\ngunicorn\n",
+			},
+			want: &Framework{Name: "flask", Command: []string{"/bin/bash", "-c", "exec gunicorn --bind :$PORT app:app"}},
+		},
+		{
+			name:     "fastapi with uvicorn",
+			language: "python",
+			files: map[string]string{
+				"api/main.py":      "from fastapi import FastAPI\n\napi = FastAPI()\n",
+				"requirements.txt": "fastapi\nuvicorn[standard]\n",
+			},
+			want: &Framework{Name: "fastapi", Command: []string{"/bin/bash", "-c", "exec uvicorn --host 0.0.0.0 --port $PORT api.main:api"}},
+		},
+		{
+			name:     "fastapi with gunicorn",
+			language: "python",
+			files: map[string]string{
+				"main.py":          "app = fastapi.FastAPI(title=\"x\")\n",
+				"requirements.txt": "fastapi\nuvicorn\ngunicorn\n",
+			},
+			want: &Framework{Name: "fastapi", Command: []string{"/bin/bash", "-c", "exec gunicorn --bind :$PORT --worker-class uvicorn.workers.UvicornWorker main:app"}},
+		},
+		{
+			name:     "flask extension only",
+			language: "python",
+			files: map[string]string{
+				"requirements.txt": "flask-cors\n",
+			},
+		},
+		{
+			name:     "nextjs before express",
+			language: "nodejs",
+			files: map[string]string{
+				"package.json": `{"dependencies": {"express": "^4.17.1", "next": "10.0.0"}}`,
+			},
+			want: &Framework{Name: "nextjs", Env: map[string]string{"NEXT_TELEMETRY_DISABLED": "1"}, Command: []string{"/bin/bash", "-c", "exec next start -p ${PORT:-8080}"}},
+		},
+		{
+			name:     "nextjs with start script",
+			language: "nodejs",
+			files: map[string]string{
+				"package.json": `{"dependencies": {"next": "10.0.0"}, "scripts": {"start": "node server.js"}}`,
+			},
+			want: &Framework{Name: "nextjs", Env: map[string]string{"NEXT_TELEMETRY_DISABLED": "1"}},
+		},
+		{
+			name:     "nextjs standalone",
+			language: "nodejs",
+			files: map[string]string{
+				"package.json":               `{"dependencies": {"next": "12.0.0"}, "scripts": {"start": "next start"}}`,
+				".next/standalone/server.js": "",
+			},
+			want: &Framework{Name: "nextjs", Env: map[string]string{"NEXT_TELEMETRY_DISABLED": "1"}, Command: []string{"/bin/bash", "-c", "HOSTNAME=0.0.0.0 exec node .next/standalone/server.js"}},
+		},
+		{
+			name:     "nuxt nitro",
+			language: "nodejs",
+			files: map[string]string{
+				"package.json":             `{"devDependencies": {"nuxt": "3.0.0"}}`,
+				".output/server/index.mjs": "",
+			},
+			want: &Framework{Name: "nuxt", Env: map[string]string{"HOST": "0.0.0.0"}, Command: []string{"node", ".output/server/index.mjs"}},
+		},
+		{
+			name:     "nuxt 2",
+			language: "nodejs",
+			files: map[string]string{
+				"package.json": `{"dependencies": {"nuxt": "2.14.0"}}`,
+			},
+			want: &Framework{Name: "nuxt", Env: map[string]string{"HOST": "0.0.0.0"}, Command: []string{"/bin/bash", "-c", "exec nuxt start --port ${PORT:-8080}"}},
+		},
+		{
+			name:     "remix with remix-serve",
+			language: "nodejs",
+			files: map[string]string{
+				"package.json": `{"dependencies": {"@remix-run/react": "1.0.0", "@remix-run/serve": "1.0.0"}}`,
+			},
+			want: &Framework{Name: "remix", Command: []string{"remix-serve", "build"}},
+		},
+		{
+			name:     "express",
+			language: "nodejs",
+			files: map[string]string{
+				"package.json": `{"dependencies": {"express": "^4.17.1"}}`,
+			},
+			want: &Framework{Name: "express"},
+		},
+		{
+			name:     "express dev dependency only",
+			language: "nodejs",
+			files: map[string]string{
+				"package.json": `{"devDependencies": {"express": "^4.17.1"}}`,
+			},
+		},
+		{
+			name:     "spring boot with actuator",
+			language: "java",
+			files: map[string]string{
+				"pom.xml": "<artifactId>spring-boot-starter-web</artifactId><artifactId>spring-boot-starter-actuator</artifactId>",
+			},
+			want: &Framework{Name: "spring-boot", HealthPath: "/actuator/health"},
+		},
+		{
+			name:     "laravel",
+			language: "php",
+			files: map[string]string{
+				"artisan":       "#!/usr/bin/env php",
+				"composer.json": `{"require": {"php": "^7.3", "laravel/framework": "^8.0"}}`,
+			},
+			want: &Framework{Name: "laravel", DocumentRoot: "public", Env: map[string]string{"LOG_CHANNEL": "stderr"}},
+		},
+		{
+			name:     "symfony",
+			language: "php",
+			files: map[string]string{
+				"bin/console":   "#!/usr/bin/env php",
+				"composer.json": `{"require": {"symfony/framework-bundle": "5.1.*"}}`,
+			},
+			want: &Framework{Name: "symfony", DocumentRoot: "public", Env: map[string]string{"APP_ENV": "prod"}},
+		},
+		{
+			name:     "symfony components without console",
+			language: "php",
+			files: map[string]string{
+				"composer.json": `{"require": {"symfony/framework-bundle": "5.1.*"}}`,
+			},
+		},
+		{
+			name:     "unregistered language",
+			language: "cobol",
+			files: map[string]string{
+				"requirements.txt": "django\n",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "frameworks")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			for f, c := range tc.files {
+				if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(f)), 0755); err != nil {
+					t.Fatalf("creating dir for %s: %v", f, err)
+				}
+				if err := ioutil.WriteFile(filepath.Join(dir, f), []byte(c), 0644); err != nil {
+					t.Fatalf("writing file %s: %v", f, err)
+				}
+			}
+			ctx := gcp.NewContextForTests(libcnb.BuildpackInfo{}, dir)
+
+			got, err := Detect(ctx, tc.language)
+			if err != nil {
+				t.Fatalf("Detect() returned error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Detect() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRegisterDuplicate(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("Register() of a duplicate detector did not panic")
+		}
+	}()
+	Register(Detector{Name: "django", Language: "python", Detect: detectDjango})
+}
+
+func TestNames(t *testing.T) {
+	got := Names("python")
+	want := []string{"django", "fastapi", "flask"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Names(python) = %v, want %v", got, want)
+	}
+}
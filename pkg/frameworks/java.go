@@ -0,0 +1,49 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frameworks
+
+import (
+	"bytes"
+	"path/filepath"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+var (
+	javaBuildFiles = []string{"pom.xml", "build.gradle", "build.gradle.kts"}
+)
+
+func init() {
+	Register(Detector{Name: "spring-boot", Language: "java", Detect: detectSpringBoot})
+}
+
+func detectSpringBoot(ctx *gcp.Context) (*Framework, error) {
+	for _, f := range javaBuildFiles {
+		path := filepath.Join(ctx.ApplicationRoot(), f)
+		if !ctx.FileExists(path) {
+			continue
+		}
+		content := ctx.ReadFile(path)
+		if !bytes.Contains(content, []byte("spring-boot")) {
+			continue
+		}
+		fw := &Framework{Name: "spring-boot"}
+		if bytes.Contains(content, []byte("spring-boot-starter-actuator")) {
+			fw.HealthPath = "/actuator/health"
+		}
+		return fw, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frameworks
+
+import (
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+)
+
+const (
+	// NextStandaloneServer is the server emitted by `next build` when output is "standalone".
+	NextStandaloneServer = ".next/standalone/server.js"
+	// NuxtNitroServer is the server emitted by `nuxt build` for Nuxt 3 and later.
+	NuxtNitroServer = ".output/server/index.mjs"
+)
+
+func init() {
+	// Meta-frameworks commonly use Express for custom servers, so detect them first.
+	Register(Detector{Name: "nextjs", Language: "nodejs", Detect: detectNext})
+	Register(Detector{Name: "nuxt", Language: "nodejs", Detect: detectNuxt})
+	Register(Detector{Name: "remix", Language: "nodejs", Detect: detectRemix})
+	Register(Detector{Name: "express", Language: "nodejs", Detect: dependencyDetector("express", "express")})
+}
+
+func detectNext(ctx *gcp.Context) (*Framework, error) {
+	pjs, err := readPackageJSON(ctx)
+	if pjs == nil || err != nil || !hasDependency(pjs, "next") {
+		return nil, err
+	}
+	fw := &Framework{Name: "nextjs", Env: map[string]string{"NEXT_TELEMETRY_DISABLED": "1"}}
+	switch {
+	case ctx.FileExists(ctx.ApplicationRoot(), NextStandaloneServer):
+		// The standalone server binds to HOSTNAME, which container runtimes set to the container ID.
+		fw.Command = []string{"/bin/bash", "-c", "HOSTNAME=0.0.0.0 exec node " + NextStandaloneServer}
+	case pjs.Scripts.Start == "":
+		fw.Command = portCommand("next start -p ${PORT:-8080}")
+	}
+	return fw, nil
+}
+
+func detectNuxt(ctx *gcp.Context) (*Framework, error) {
+	pjs, err := readPackageJSON(ctx)
+	if pjs == nil || err != nil || !hasDependency(pjs, "nuxt") {
+		return nil, err
+	}
+	// Nuxt listens on localhost by default.
+	fw := &Framework{Name: "nuxt", Env: map[string]string{"HOST": "0.0.0.0"}}
+	switch {
+	case ctx.FileExists(ctx.ApplicationRoot(), NuxtNitroServer):
+		fw.Command = []string{"node", NuxtNitroServer}
+	case pjs.Scripts.Start == "":
+		fw.Command = portCommand("nuxt start --port ${PORT:-8080}")
+	}
+	return fw, nil
+}
+
+func detectRemix(ctx *gcp.Context) (*Framework, error) {
+	pjs, err := readPackageJSON(ctx)
+	if pjs == nil || err != nil || !hasDependency(pjs, "@remix-run/react") {
+		return nil, err
+	}
+	fw := &Framework{Name: "remix"}
+	if _, ok := pjs.Dependencies["@remix-run/serve"]; ok && pjs.Scripts.Start == "" {
+		fw.Command = []string{"remix-serve", "build"}
+	}
+	return fw, nil
+}
+
+// dependencyDetector detects a framework from a production dependency in package.json.
+func dependencyDetector(name, dependency string) func(*gcp.Context) (*Framework, error) {
+	return func(ctx *gcp.Context) (*Framework, error) {
+		pjs, err := readPackageJSON(ctx)
+		if pjs == nil || err != nil {
+			return nil, err
+		}
+		if _, ok := pjs.Dependencies[dependency]; !ok {
+			return nil, nil
+		}
+		return &Framework{Name: name}, nil
+	}
+}
+
+// readPackageJSON returns the application's package.json, or nil if there is none.
+func readPackageJSON(ctx *gcp.Context) (*nodejs.PackageJSON, error) {
+	if !ctx.FileExists(ctx.ApplicationRoot(), "package.json") {
+		return nil, nil
+	}
+	return nodejs.ReadPackageJSON(ctx.ApplicationRoot())
+}
+
+// hasDependency returns true if the package is a production or development dependency.
+// Meta-frameworks are often development dependencies since they are only needed to build.
+func hasDependency(pjs *nodejs.PackageJSON, name string) bool {
+	if _, ok := pjs.Dependencies[name]; ok {
+		return true
+	}
+	_, ok := pjs.DevDependencies[name]
+	return ok
+}
@@ -0,0 +1,55 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frameworks
+
+import (
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/php"
+)
+
+func init() {
+	Register(Detector{Name: "laravel", Language: "php", Detect: detectLaravel})
+	Register(Detector{Name: "symfony", Language: "php", Detect: detectSymfony})
+}
+
+func detectLaravel(ctx *gcp.Context) (*Framework, error) {
+	ok, err := composerRequires(ctx, "artisan", "laravel/framework")
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &Framework{Name: "laravel", DocumentRoot: "public", Env: map[string]string{"LOG_CHANNEL": "stderr"}}, nil
+}
+
+func detectSymfony(ctx *gcp.Context) (*Framework, error) {
+	ok, err := composerRequires(ctx, "bin/console", "symfony/framework-bundle")
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &Framework{Name: "symfony", DocumentRoot: "public", Env: map[string]string{"APP_ENV": "prod"}}, nil
+}
+
+// composerRequires returns true if the application contains the framework's console script and
+// composer.json requires the framework package.
+func composerRequires(ctx *gcp.Context, console, pkg string) (bool, error) {
+	if !ctx.FileExists(ctx.ApplicationRoot(), console) || !ctx.FileExists(ctx.ApplicationRoot(), "composer.json") {
+		return false, nil
+	}
+	cjs, err := php.ReadComposerJSON(ctx.ApplicationRoot())
+	if err != nil {
+		return false, err
+	}
+	_, ok := cjs.Require[pkg]
+	return ok, nil
+}
@@ -0,0 +1,213 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frameworks
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+var (
+	// settingsModuleRegexp extracts the default settings module from manage.py.
+	settingsModuleRegexp = regexp.MustCompile(`DJANGO_SETTINGS_MODULE['"]\s*,\s*['"]([\w.]+)['"]`)
+	// wsgiApplicationRegexp extracts the dotted path of the WSGI application from a settings module.
+	wsgiApplicationRegexp = regexp.MustCompile(`(?m)^WSGI_APPLICATION\s*=\s*['"]([\w.]+)\.(\w+)['"]`)
+	// flaskAppRegexp matches module-level assignments of Flask application objects.
+	flaskAppRegexp = appObjectRegexp("flask", "Flask")
+	// fastAPIAppRegexp matches module-level assignments of FastAPI application objects.
+	fastAPIAppRegexp = appObjectRegexp("fastapi", "FastAPI")
+
+	// skippedSourceDirs are not searched for application objects.
+	skippedSourceDirs = map[string]bool{".git": true, ".venv": true, "venv": true, "node_modules": true, "__pycache__": true, "site-packages": true}
+)
+
+func init() {
+	Register(Detector{Name: "django", Language: "python", Detect: detectDjango})
+	Register(Detector{Name: "fastapi", Language: "python", Detect: detectFastAPI})
+	Register(Detector{Name: "flask", Language: "python", Detect: detectFlask})
+}
+
+func detectDjango(ctx *gcp.Context) (*Framework, error) {
+	if !ctx.FileExists(ctx.ApplicationRoot(), "manage.py") || !requirementsContain(ctx, "django") {
+		return nil, nil
+	}
+	fw := &Framework{Name: "django"}
+	module := DjangoSettingsModule(ctx)
+	if module != "" {
+		fw.Env = map[string]string{"DJANGO_SETTINGS_MODULE": module}
+	}
+	if app := djangoWSGIApplication(ctx, module); app != "" && requirementsContain(ctx, "gunicorn") {
+		fw.Command = gunicornCommand(app)
+	}
+	return fw, nil
+}
+
+// DjangoSettingsModule returns the settings module of a Django project: DJANGO_SETTINGS_MODULE if
+// set, otherwise the default configured in manage.py, or "" if neither is present.
+func DjangoSettingsModule(ctx *gcp.Context) string {
+	if v := os.Getenv("DJANGO_SETTINGS_MODULE"); v != "" {
+		return v
+	}
+	path := filepath.Join(ctx.ApplicationRoot(), "manage.py")
+	if !ctx.FileExists(path) {
+		return ""
+	}
+	if m := settingsModuleRegexp.FindSubmatch(ctx.ReadFile(path)); m != nil {
+		return string(m[1])
+	}
+	return ""
+}
+
+// DjangoSettingsFile returns the source file of the given settings module relative to the
+// application root, or "" if the module does not exist in the application.
+func DjangoSettingsFile(ctx *gcp.Context, module string) string {
+	base := filepath.Join(strings.Split(module, ".")...)
+	for _, f := range []string{base + ".py", filepath.Join(base, "__init__.py")} {
+		if ctx.FileExists(ctx.ApplicationRoot(), f) {
+			return f
+		}
+	}
+	return ""
+}
+
+// djangoWSGIApplication returns the gunicorn application path of a Django project, preferring
+// WSGI_APPLICATION from the settings module over the <project>.wsgi convention of startproject.
+func djangoWSGIApplication(ctx *gcp.Context, module string) string {
+	if module == "" {
+		return ""
+	}
+	if f := DjangoSettingsFile(ctx, module); f != "" {
+		if m := wsgiApplicationRegexp.FindSubmatch(ctx.ReadFile(filepath.Join(ctx.ApplicationRoot(), f))); m != nil {
+			return fmt.Sprintf("%s:%s", m[1], m[2])
+		}
+	}
+	i := strings.LastIndex(module, ".")
+	if i < 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s.wsgi:application", module[:i])
+}
+
+func detectFlask(ctx *gcp.Context) (*Framework, error) {
+	if !requirementsContain(ctx, "flask") {
+		return nil, nil
+	}
+	fw := &Framework{Name: "flask"}
+	if !requirementsContain(ctx, "gunicorn") {
+		return fw, nil
+	}
+	apps, err := findAppObjects(ctx, flaskAppRegexp)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case len(apps) == 1:
+		fw.Command = gunicornCommand(apps[0])
+	case len(apps) > 1:
+		fw.Candidates = apps
+	case ctx.FileExists(ctx.ApplicationRoot(), "main.py"):
+		fw.Command = gunicornCommand("main:app")
+	}
+	return fw, nil
+}
+
+func detectFastAPI(ctx *gcp.Context) (*Framework, error) {
+	if !requirementsContain(ctx, "fastapi") {
+		return nil, nil
+	}
+	fw := &Framework{Name: "fastapi"}
+	if !requirementsContain(ctx, "uvicorn") {
+		return fw, nil
+	}
+	apps, err := findAppObjects(ctx, fastAPIAppRegexp)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case len(apps) == 1 && requirementsContain(ctx, "gunicorn"):
+		fw.Command = portCommand("gunicorn --bind :$PORT --worker-class uvicorn.workers.UvicornWorker " + apps[0])
+	case len(apps) == 1:
+		fw.Command = portCommand("uvicorn --host 0.0.0.0 --port $PORT " + apps[0])
+	case len(apps) > 1:
+		fw.Candidates = apps
+	}
+	return fw, nil
+}
+
+// appObjectRegexp matches module-level assignments such as `app = Flask(__name__)` or
+// `api: FastAPI = fastapi.FastAPI()`, capturing the variable name.
+func appObjectRegexp(module, class string) *regexp.Regexp {
+	return regexp.MustCompile(`(?m)^(\w+)\s*(?::\s*[\w.]+\s*)?=\s*(?:` + module + `\.)?` + class + `\(`)
+}
+
+// findAppObjects statically searches the application's Python sources for application objects
+// matched by re and returns them, sorted, as "module:variable" paths suitable for WSGI/ASGI servers.
+func findAppObjects(ctx *gcp.Context, re *regexp.Regexp) ([]string, error) {
+	var apps []string
+	err := filepath.Walk(ctx.ApplicationRoot(), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if skippedSourceDirs[info.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".py" {
+			return nil
+		}
+		rel, err := filepath.Rel(ctx.ApplicationRoot(), path)
+		if err != nil {
+			return err
+		}
+		module := strings.TrimSuffix(strings.TrimSuffix(filepath.ToSlash(rel), ".py"), "/__init__")
+		for _, m := range re.FindAllSubmatch(ctx.ReadFile(path), -1) {
+			apps = append(apps, fmt.Sprintf("%s:%s", strings.ReplaceAll(module, "/", "."), m[1]))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, gcp.InternalErrorf("searching for application objects: %v", err)
+	}
+	sort.Strings(apps)
+	return apps, nil
+}
+
+// requirementsContain returns true if requirements.txt lists the named distribution.
+func requirementsContain(ctx *gcp.Context, name string) bool {
+	path := filepath.Join(ctx.ApplicationRoot(), "requirements.txt")
+	if !ctx.FileExists(path) {
+		return false
+	}
+	return requirementRegexp(name).Match(ctx.ReadFile(path))
+}
+
+// requirementRegexp matches a requirements.txt line for the named distribution, ignoring case and
+// treating "-" and "_" as equivalent as pip does.
+func requirementRegexp(name string) *regexp.Regexp {
+	n := regexp.MustCompile(`[-_.]`).ReplaceAllString(regexp.QuoteMeta(name), `[-_.]`)
+	return regexp.MustCompile(`(?mi)^\s*` + n + `\s*([=<>~!\[;@]|$)`)
+}
+
+func gunicornCommand(app string) []string {
+	return portCommand("gunicorn --bind :$PORT " + app)
+}
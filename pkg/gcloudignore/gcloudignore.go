@@ -0,0 +1,255 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gcloudignore finds the files excluded from the source by a .gcloudignore file, which uses
+// the syntax of .gitignore files. As with gcloud, a line "#!include:.gitignore" includes the patterns
+// of another file in the same directory. Patterns from other origins, such as defaults and env vars,
+// can be merged into the same exclusion set.
+package gcloudignore
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// FileName is the name of the ignore file in the source root.
+	FileName = ".gcloudignore"
+
+	includeDirective = "#!include:"
+)
+
+// pattern is a single line of an ignore file.
+type pattern struct {
+	line    string
+	origin  string
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// Matcher matches slash-separated paths relative to the source root against ignore patterns. The zero
+// value excludes nothing.
+type Matcher struct {
+	patterns []pattern
+}
+
+// Add adds patterns in ignore file syntax from the given origin, such as an env var name. Later
+// patterns take precedence over earlier ones.
+func (m *Matcher) Add(origin string, lines ...string) error {
+	for _, line := range lines {
+		p, ok, err := parsePattern(line)
+		if err != nil {
+			return fmt.Errorf("%s: %v", origin, err)
+		}
+		if ok {
+			p.origin = origin
+			m.patterns = append(m.patterns, p)
+		}
+	}
+	return nil
+}
+
+// AddFile adds the patterns of the .gcloudignore file in dir. It returns false if dir has no
+// .gcloudignore file.
+func (m *Matcher) AddFile(dir string) (bool, error) {
+	if err := m.readFile(dir, FileName, true); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (m *Matcher) readFile(dir, name string, allowInclude bool) error {
+	f, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		line := s.Text()
+		if strings.HasPrefix(line, includeDirective) {
+			inc := strings.TrimSpace(strings.TrimPrefix(line, includeDirective))
+			// gcloud only includes files next to the .gcloudignore file, which also keeps the
+			// included file within the source.
+			if !allowInclude || inc != filepath.Base(inc) {
+				return fmt.Errorf("%s:%d: cannot include %q, only files in the same directory can be included from %s", name, n, inc, FileName)
+			}
+			// Missing included files are skipped, as with gcloud.
+			if err := m.readFile(dir, inc, false); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		p, ok, err := parsePattern(line)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", name, n, err)
+		}
+		if ok {
+			p.origin = name
+			m.patterns = append(m.patterns, p)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("reading %s: %v", name, err)
+	}
+	return nil
+}
+
+// parsePattern parses a line of an ignore file. It returns false for blank lines and comments.
+func parsePattern(line string) (pattern, bool, error) {
+	// Trailing spaces are ignored unless escaped.
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return pattern{}, false, nil
+	}
+	p := pattern{line: line}
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	// Patterns containing a slash are relative to the source root, others match at any depth.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return pattern{}, false, nil
+	}
+
+	var b strings.Builder
+	b.WriteString("^")
+	if !anchored {
+		b.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case strings.HasPrefix(line[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(line[i:], "**") && i+2 == len(line):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '\\' && i+1 < len(line):
+			i++
+			b.WriteString(regexp.QuoteMeta(line[i : i+1]))
+		case c == '[':
+			end := strings.IndexByte(line[i+1:], ']')
+			if end < 0 {
+				return pattern{}, false, fmt.Errorf("unterminated character class in %q", line)
+			}
+			class := line[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return pattern{}, false, fmt.Errorf("invalid pattern %q: %v", line, err)
+	}
+	p.re = re
+	return p, true, nil
+}
+
+// Match returns true if the slash-separated path, relative to the source root, is excluded. As
+// with gcloud, the last matching pattern decides.
+func (m *Matcher) Match(path string, isDir bool) bool {
+	excluded := false
+	for _, p := range m.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if p.re.MatchString(path) {
+			excluded = !p.negate
+		}
+	}
+	return excluded
+}
+
+// Empty returns true if the matcher has no patterns.
+func (m *Matcher) Empty() bool {
+	return len(m.patterns) == 0
+}
+
+// String returns the patterns in ignore file syntax, preceded by a comment naming their origin, in
+// the format of a .dockerignore file.
+func (m *Matcher) String() string {
+	var b strings.Builder
+	origin := ""
+	for _, p := range m.patterns {
+		if p.origin != origin {
+			origin = p.origin
+			fmt.Fprintf(&b, "# %s\n", origin)
+		}
+		b.WriteString(p.line + "\n")
+	}
+	return b.String()
+}
+
+// Excluded returns the paths in dir that are excluded. Excluded directories are returned without
+// their contents, which are excluded with them.
+func (m *Matcher) Excluded(dir string) ([]string, error) {
+	if m.Empty() {
+		return nil, nil
+	}
+	var paths []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if !m.Match(filepath.ToSlash(rel), info.IsDir()) {
+			return nil
+		}
+		paths = append(paths, path)
+		if info.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("finding excluded files: %v", err)
+	}
+	return paths, nil
+}
@@ -0,0 +1,183 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcloudignore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	testCases := []struct {
+		name     string
+		patterns []string
+		path     string
+		isDir    bool
+		want     bool
+	}{
+		{name: "name at root", patterns: []string{"secret.txt"}, path: "secret.txt", want: true},
+		{name: "name in subdir", patterns: []string{"secret.txt"}, path: "a/b/secret.txt", want: true},
+		{name: "different name", patterns: []string{"secret.txt"}, path: "secret.txt.bak"},
+		{name: "glob", patterns: []string{"*.log"}, path: "logs/app.log", want: true},
+		{name: "glob does not cross dirs", patterns: []string{"a/*.log"}, path: "a/b/app.log"},
+		{name: "anchored", patterns: []string{"/data"}, path: "data", isDir: true, want: true},
+		{name: "anchored in subdir", patterns: []string{"/data"}, path: "pkg/data", isDir: true},
+		{name: "path with slash is anchored", patterns: []string{"testdata/big"}, path: "pkg/testdata/big"},
+		{name: "dir only matches dir", patterns: []string{"node_modules/"}, path: "node_modules", isDir: true, want: true},
+		{name: "dir only skips file", patterns: []string{"node_modules/"}, path: "node_modules"},
+		{name: "leading double star", patterns: []string{"**/fixtures"}, path: "a/b/fixtures", isDir: true, want: true},
+		{name: "middle double star", patterns: []string{"a/**/z.bin"}, path: "a/z.bin", want: true},
+		{name: "trailing double star", patterns: []string{"a/**"}, path: "a/b/c", want: true},
+		{name: "question mark", patterns: []string{"v?.txt"}, path: "v1.txt", want: true},
+		{name: "character class", patterns: []string{"v[0-9].txt"}, path: "vx.txt"},
+		{name: "negated", patterns: []string{"*.txt", "!keep.txt"}, path: "keep.txt"},
+		{name: "last match wins", patterns: []string{"!keep.txt", "*.txt"}, path: "keep.txt", want: true},
+		{name: "comment", patterns: []string{"# secret.txt"}, path: "# secret.txt"},
+		{name: "escaped hash", patterns: []string{`\#notes`}, path: "#notes", want: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var m Matcher
+			if err := m.Add("test", tc.patterns...); err != nil {
+				t.Fatalf("Add(%q) got error: %v", tc.patterns, err)
+			}
+			if got := m.Match(tc.path, tc.isDir); got != tc.want {
+				t.Errorf("Match(%q, %t) with %q = %t, want %t", tc.path, tc.isDir, tc.patterns, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestExcluded(t *testing.T) {
+	testCases := []struct {
+		name     string
+		files    map[string]string
+		override []string
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name:  "no gcloudignore",
+			files: map[string]string{"main.go": "", "secret.txt": ""},
+		},
+		{
+			name: "files and dirs",
+			files: map[string]string{
+				FileName:           ".gcloudignore\nsecret.txt\ntestdata/\n",
+				"main.go":          "",
+				"secret.txt":       "",
+				"testdata/big.bin": "",
+			},
+			want: []string{FileName, "secret.txt", "testdata"},
+		},
+		{
+			name: "include gitignore",
+			files: map[string]string{
+				FileName:     "#!include:.gitignore\n!keep.log\n",
+				".gitignore": "*.log\n",
+				"app.log":    "",
+				"keep.log":   "",
+			},
+			want: []string{"app.log"},
+		},
+		{
+			name: "missing include",
+			files: map[string]string{
+				FileName:     "#!include:.gitignore\nsecret.txt\n",
+				"secret.txt": "",
+			},
+			want: []string{"secret.txt"},
+		},
+		{
+			name: "override",
+			files: map[string]string{
+				FileName:       "data/\n",
+				"data/a.csv":   "",
+				"data/b.csv":   "",
+				"model/w.bin":  "",
+				"model/cfg.js": "",
+			},
+			override: []string{"!data/", "*.bin"},
+			want:     []string{"model/w.bin"},
+		},
+		{
+			name: "include outside dir",
+			files: map[string]string{
+				FileName: "#!include:../.gitignore\n",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "gcloudignore")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			for name, content := range tc.files {
+				path := filepath.Join(dir, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating dir for %s: %v", name, err)
+				}
+				if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatalf("writing %s: %v", name, err)
+				}
+			}
+
+			var m Matcher
+			_, err = m.AddFile(dir)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("AddFile() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if err := m.Add("override", tc.override...); err != nil {
+				t.Fatalf("Add(%q) got error: %v", tc.override, err)
+			}
+
+			got, err := m.Excluded(dir)
+			if err != nil {
+				t.Fatalf("Excluded() got error: %v", err)
+			}
+			var want []string
+			for _, p := range tc.want {
+				want = append(want, filepath.Join(dir, p))
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Excluded() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	var m Matcher
+	if err := m.Add("default", "/.google*"); err != nil {
+		t.Fatalf("Add() got error: %v", err)
+	}
+	if err := m.Add("GOOGLE_FUNCTION_SOURCE_EXCLUDE", "data/", "", "!data/keep.csv  "); err != nil {
+		t.Fatalf("Add() got error: %v", err)
+	}
+
+	want := "# default\n/.google*\n# GOOGLE_FUNCTION_SOURCE_EXCLUDE\ndata/\n!data/keep.csv\n"
+	if got := m.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
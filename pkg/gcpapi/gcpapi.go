@@ -0,0 +1,116 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gcpapi calls Google Cloud REST APIs using the ambient credentials of the metadata server.
+package gcpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+	requestTimeout  = 10 * time.Second
+)
+
+// Client sends requests authenticated as the default service account of the instance.
+type Client struct {
+	// TokenURL is the metadata server endpoint that issues access tokens.
+	TokenURL string
+
+	http  *http.Client
+	token string
+}
+
+// NewClient creates a client using the default service account of the instance.
+func NewClient() *Client {
+	return &Client{
+		TokenURL: defaultTokenURL,
+		http:     &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// Get sends a GET request to url and decodes the JSON response into v.
+func (c *Client) Get(url string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	return c.authorizedDo(req, v)
+}
+
+// Post sends body encoded as JSON to url and decodes the JSON response into v.
+func (c *Client) Post(url string, body, v interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.authorizedDo(req, v)
+}
+
+func (c *Client) authorizedDo(req *http.Request, v interface{}) error {
+	token, err := c.accessToken()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return c.do(req, v)
+}
+
+// accessToken fetches, and caches for the lifetime of the client, an access token from the metadata server.
+func (c *Client) accessToken() (string, error) {
+	if c.token != "" {
+		return c.token, nil
+	}
+	req, err := http.NewRequest(http.MethodGet, c.TokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	var resp struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := c.do(req, &resp); err != nil {
+		return "", fmt.Errorf("fetching access token from the metadata server: %w", err)
+	}
+	c.token = resp.AccessToken
+	return c.token, nil
+}
+
+func (c *Client) do(req *http.Request, v interface{}) error {
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,89 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient(t *testing.T) {
+	tokenRequests := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			http.Error(w, "missing Metadata-Flavor", http.StatusForbidden)
+			return
+		}
+		tokenRequests++
+		fmt.Fprint(w, `{"access_token": "tok", "expires_in": 3600}`)
+	})
+	mux.HandleFunc("/v1/echo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			http.Error(w, "unauthenticated", http.StatusUnauthorized)
+			return
+		}
+		req := map[string]string{}
+		if r.Method == http.MethodPost {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]string{"method": r.Method, "msg": req["msg"]})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c := NewClient()
+	c.TokenURL = server.URL + "/token"
+
+	var got map[string]string
+	if err := c.Get(server.URL+"/v1/echo", &got); err != nil {
+		t.Fatalf("Get() returned error: %v", err)
+	}
+	if got["method"] != http.MethodGet {
+		t.Errorf("Get() sent method %q, want %q", got["method"], http.MethodGet)
+	}
+	if err := c.Post(server.URL+"/v1/echo", map[string]string{"msg": "hi"}, &got); err != nil {
+		t.Fatalf("Post() returned error: %v", err)
+	}
+	if got["method"] != http.MethodPost || got["msg"] != "hi" {
+		t.Errorf("Post() = %v, want method %q and msg %q", got, http.MethodPost, "hi")
+	}
+	if tokenRequests != 1 {
+		t.Errorf("Get() and Post() fetched %d tokens, want 1", tokenRequests)
+	}
+
+	if err := c.Get(server.URL+"/v1/missing", &got); err == nil {
+		t.Errorf("Get() of missing resource returned nil error")
+	}
+}
+
+func TestClientTokenError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	c := NewClient()
+	c.TokenURL = server.URL + "/token"
+
+	var got map[string]string
+	if err := c.Get(server.URL+"/v1/echo", &got); err == nil {
+		t.Errorf("Get() without access token returned nil error")
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+// ArtifactDir returns the directory to which compiled artifacts are exported, as set with
+// env.ArtifactDir, or "" if artifacts are not exported.
+func ArtifactDir() (string, error) {
+	dir := os.Getenv(env.ArtifactDir)
+	if dir == "" {
+		return "", nil
+	}
+	if !filepath.IsAbs(dir) {
+		return "", UserErrorf("%s must be an absolute path, got %q", env.ArtifactDir, dir)
+	}
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return "", UserErrorf("%s %q must be an existing directory, usually mounted with `pack build --volume`: %v", env.ArtifactDir, dir, err)
+	}
+	if !fi.IsDir() {
+		return "", UserErrorf("%s %q is not a directory", env.ArtifactDir, dir)
+	}
+	return dir, nil
+}
+
+// ExportArtifacts copies the files and directories at paths, relative to the application root unless
+// absolute, to the directory set with env.ArtifactDir under their base names, replacing previous
+// exports of the same name. Symlinks are followed, so that the artifacts are usable outside the image.
+// It does nothing if env.ArtifactDir is not set.
+func (ctx *Context) ExportArtifacts(paths ...string) error {
+	dir, err := ArtifactDir()
+	if err != nil || dir == "" {
+		return err
+	}
+	for _, p := range paths {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(ctx.ApplicationRoot(), p)
+		}
+		dst := filepath.Join(dir, filepath.Base(p))
+		ctx.RemoveAll(dst)
+		ctx.Exec([]string{"cp", "--recursive", "--dereference", "--preserve=mode,timestamps", p, dst}, WithUserTimingAttribution)
+		ctx.Logf("Exported %s to %s", p, dst)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestExportArtifacts(t *testing.T) {
+	app, err := ioutil.TempDir("", "app")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(app)
+	out, err := ioutil.TempDir("", "out")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(out)
+	if err := os.MkdirAll(filepath.Join(app, "dist"), 0755); err != nil {
+		t.Fatalf("creating dist: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(app, "dist", "index.js"), []byte("new"), 0644); err != nil {
+		t.Fatalf("writing index.js: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(app, "main"), []byte("binary"), 0755); err != nil {
+		t.Fatalf("writing main: %v", err)
+	}
+	// A previous export must be replaced, not copied into.
+	if err := os.MkdirAll(filepath.Join(out, "dist"), 0755); err != nil {
+		t.Fatalf("creating dist: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(out, "dist", "stale.js"), []byte("old"), 0644); err != nil {
+		t.Fatalf("writing stale.js: %v", err)
+	}
+	defer os.Unsetenv(env.ArtifactDir)
+	os.Setenv(env.ArtifactDir, out)
+	ctx := newBuildContext(libcnb.BuildContext{Application: libcnb.Application{Path: app}})
+
+	if err := ctx.ExportArtifacts("dist", filepath.Join(app, "main")); err != nil {
+		t.Fatalf("ExportArtifacts() got error: %v", err)
+	}
+
+	for _, f := range []string{"main", "dist/index.js"} {
+		if _, err := os.Stat(filepath.Join(out, f)); err != nil {
+			t.Errorf("%s was not exported: %v", f, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(out, "dist", "stale.js")); !os.IsNotExist(err) {
+		t.Errorf("stale export dist/stale.js was not removed: %v", err)
+	}
+	if fi, err := os.Stat(filepath.Join(out, "main")); err == nil && fi.Mode().Perm() != 0755 {
+		t.Errorf("exported main has mode %v, want %v", fi.Mode().Perm(), os.FileMode(0755))
+	}
+}
+
+func TestArtifactDir(t *testing.T) {
+	file, err := ioutil.TempFile("", "file")
+	if err != nil {
+		t.Fatalf("creating temp file: %v", err)
+	}
+	defer os.Remove(file.Name())
+	file.Close()
+
+	testCases := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "", want: ""},
+		{value: os.TempDir(), want: os.TempDir()},
+		{value: "out", wantErr: true},
+		{value: "/does/not/exist", wantErr: true},
+		{value: file.Name(), wantErr: true},
+	}
+	defer os.Unsetenv(env.ArtifactDir)
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			os.Setenv(env.ArtifactDir, tc.value)
+			got, err := ArtifactDir()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ArtifactDir() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("ArtifactDir() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
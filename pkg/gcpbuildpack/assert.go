@@ -0,0 +1,90 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+const (
+	// FactRuntimeVersion is the fact recorded by runtime buildpacks with the installed runtime version.
+	FactRuntimeVersion = "RUNTIME_VERSION"
+	// FactFramework is the fact recorded with the name of the detected web framework.
+	FactFramework = "FRAMEWORK"
+
+	// factPrefix prefixes the build env vars through which facts are passed to later buildpacks.
+	factPrefix = "X_GOOGLE_FACT_"
+	factsLayer = "facts"
+)
+
+// RecordFact records a property of the build, such as the installed runtime version, and fails
+// if it contradicts the assertion env var for it, e.g. GOOGLE_ASSERT_RUNTIME_VERSION.
+// Facts are passed to later buildpacks so that CheckAssertions can report assertions that no
+// buildpack recorded a fact for. RecordFact does nothing during detection.
+func (ctx *Context) RecordFact(name, value string) error {
+	// Detection also runs for groups that are not selected, so only facts about the build count.
+	if ctx.buildContext.Layers.Path == "" {
+		return nil
+	}
+	want, ok := os.LookupEnv(env.AssertPrefix + name)
+	if !ok {
+		return nil
+	}
+	if !factHolds(want, value) {
+		return assertionError(name, want, value)
+	}
+	ctx.Logf("Assertion %s%s=%q holds", env.AssertPrefix, name, want)
+	if ctx.facts == nil {
+		ctx.facts = ctx.Layer(factsLayer, BuildLayer)
+	}
+	ctx.facts.BuildEnvironment.Override(factPrefix+name, value)
+	return nil
+}
+
+// CheckAssertions fails if no earlier buildpack recorded a fact for any of the assertion env vars.
+// It is meant to run at the end of the build.
+func (ctx *Context) CheckAssertions() error {
+	var missing []string
+	for _, e := range os.Environ() {
+		if !strings.HasPrefix(e, env.AssertPrefix) {
+			continue
+		}
+		key := strings.SplitN(e, "=", 2)[0]
+		name := strings.TrimPrefix(key, env.AssertPrefix)
+		// Facts are checked against assertions when they are recorded.
+		if _, ok := os.LookupEnv(factPrefix + name); !ok {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return Errorf(StatusFailedPrecondition, "assertions %s do not hold: no buildpack in this build determined them", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// factHolds returns true if got equals want, ignoring case, or if want is a version prefix of got, e.g. 3.8 of 3.8.5.
+func factHolds(want, got string) bool {
+	want = strings.TrimSpace(want)
+	return strings.EqualFold(want, got) || strings.HasPrefix(got, want+".")
+}
+
+func assertionError(name, want, got string) *Error {
+	return Errorf(StatusFailedPrecondition, "assertion %s%s=%q does not hold: %s is %q", env.AssertPrefix, name, want, strings.ToLower(strings.ReplaceAll(name, "_", " ")), got)
+}
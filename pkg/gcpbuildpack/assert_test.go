@@ -0,0 +1,154 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestFactHolds(t *testing.T) {
+	testCases := []struct {
+		want string
+		got  string
+		ok   bool
+	}{
+		{want: "django", got: "django", ok: true},
+		{want: "Django", got: "django", ok: true},
+		{want: "3.8", got: "3.8.5", ok: true},
+		{want: "3.8.5", got: "3.8.5", ok: true},
+		{want: "3", got: "3.8.5", ok: true},
+		{want: "3.8", got: "3.9.0"},
+		{want: "3.8", got: "3.80.1"},
+		{want: "flask", got: "django"},
+		{want: "django", got: ""},
+	}
+	for _, tc := range testCases {
+		if got := factHolds(tc.want, tc.got); got != tc.ok {
+			t.Errorf("factHolds(%q, %q) = %t, want %t", tc.want, tc.got, got, tc.ok)
+		}
+	}
+}
+
+func TestRecordFact(t *testing.T) {
+	testCases := []struct {
+		name      string
+		assertion string
+		value     string
+		wantErr   bool
+		wantLayer bool
+	}{
+		{
+			name:  "no assertion",
+			value: "3.8.5",
+		},
+		{
+			name:      "assertion holds",
+			assertion: "3.8",
+			value:     "3.8.5",
+			wantLayer: true,
+		},
+		{
+			name:      "assertion does not hold",
+			assertion: "3.8",
+			value:     "3.9.0",
+			wantErr:   true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "layers")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			key := env.AssertPrefix + FactRuntimeVersion
+			defer os.Unsetenv(key)
+			if tc.assertion != "" {
+				os.Setenv(key, tc.assertion)
+			}
+			ctx := NewContext(libcnb.BuildpackInfo{ID: "id", Version: "version", Name: "name"})
+			ctx.buildContext.Layers = libcnb.Layers{Path: dir}
+
+			err = ctx.RecordFact(FactRuntimeVersion, tc.value)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("RecordFact() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if gotLayer := ctx.facts != nil; gotLayer != tc.wantLayer {
+				t.Fatalf("RecordFact() created facts layer: %t, want %t", gotLayer, tc.wantLayer)
+			}
+			if tc.wantLayer {
+				if got := ctx.facts.BuildEnvironment[factPrefix+FactRuntimeVersion+".override"]; got != tc.value {
+					t.Errorf("fact build env = %q, want %q", got, tc.value)
+				}
+			}
+		})
+	}
+}
+
+func TestRecordFactIgnoredDuringDetect(t *testing.T) {
+	key := env.AssertPrefix + FactFramework
+	defer os.Unsetenv(key)
+	os.Setenv(key, "django")
+	ctx := NewContext(libcnb.BuildpackInfo{ID: "id", Version: "version", Name: "name"})
+
+	if err := ctx.RecordFact(FactFramework, "flask"); err != nil {
+		t.Errorf("RecordFact() got error: %v, want nil", err)
+	}
+}
+
+func TestCheckAssertions(t *testing.T) {
+	testCases := []struct {
+		name    string
+		env     map[string]string
+		wantErr bool
+	}{
+		{
+			name: "no assertions",
+		},
+		{
+			name: "fact recorded",
+			env: map[string]string{
+				env.AssertPrefix + FactFramework: "django",
+				factPrefix + FactFramework:       "django",
+			},
+		},
+		{
+			name: "fact missing",
+			env: map[string]string{
+				env.AssertPrefix + FactFramework: "django",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				os.Setenv(k, v)
+				defer os.Unsetenv(k)
+			}
+			ctx := NewContext(libcnb.BuildpackInfo{ID: "id", Version: "version", Name: "name"})
+
+			err := ctx.CheckAssertions()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("CheckAssertions() got error: %v, want error: %t", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+const (
+	// backupLayer is the cache layer holding the originals of application files changed by the build.
+	// It is not a launch layer, so the backups are not part of the image.
+	backupLayer = "backup"
+	// backupDir is the directory, within backupLayer and the artifact directory, holding the backups
+	// under their paths relative to the application root.
+	backupDir = ".googlebuild/backup"
+)
+
+// PreserveFiles records the application files at paths, relative to the application root unless
+// absolute, before the buildpack replaces or modifies them. The returned function, called once the
+// files were written, saves the originals of the files that changed under .googlebuild/backup in a
+// cache layer, and in the directory set with env.ArtifactDir, and logs where to find them. Paths
+// that do not exist, or are outside the application root, are ignored.
+func (ctx *Context) PreserveFiles(paths ...string) func() {
+	originals := make(map[string][]byte)
+	var rels []string
+	for _, p := range paths {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(ctx.ApplicationRoot(), p)
+		}
+		rel, err := filepath.Rel(ctx.ApplicationRoot(), p)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+			continue
+		}
+		if fi, err := ctx.fs.Stat(p); err != nil || fi.IsDir() {
+			continue
+		}
+		originals[rel] = ctx.ReadFile(p)
+		rels = append(rels, rel)
+	}
+	return func() {
+		for _, rel := range rels {
+			p := filepath.Join(ctx.ApplicationRoot(), rel)
+			if ctx.FileExists(p) && bytes.Equal(ctx.ReadFile(p), originals[rel]) {
+				continue
+			}
+			ctx.backupFile(rel, originals[rel])
+		}
+	}
+}
+
+// backupFile saves content, the original of the application file at rel, to the backup layer and
+// the artifact directory.
+func (ctx *Context) backupFile(rel string, content []byte) {
+	if ctx.buildContext.Layers.Path != "" {
+		if ctx.backups == nil {
+			// Backups of previous builds would be mistaken for originals of this one.
+			ctx.backups = ctx.Layer(backupLayer, CacheLayer)
+			ctx.ClearLayer(ctx.backups)
+			ctx.Logf("The build changed application files; their originals are kept in the %s layer of %s, which is cached but not part of the image. Set %s to export them with the build artifacts.", backupLayer, ctx.BuildpackID(), env.ArtifactDir)
+		}
+		dst := filepath.Join(ctx.backups.Path, backupDir, rel)
+		ctx.MkdirAll(filepath.Dir(dst), 0755)
+		ctx.WriteFile(dst, content, 0644)
+		ctx.Logf("Saved the original %s to %s", rel, dst)
+	}
+
+	dir, err := ArtifactDir()
+	if err != nil {
+		ctx.Warnf("Not exporting the original %s: %v", rel, err)
+		return
+	}
+	if dir == "" {
+		return
+	}
+	dst := filepath.Join(dir, backupDir, rel)
+	ctx.MkdirAll(filepath.Dir(dst), 0755)
+	ctx.WriteFile(dst, content, 0644)
+	ctx.Logf("Exported the original %s to %s", rel, dst)
+}
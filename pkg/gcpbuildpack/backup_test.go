@@ -0,0 +1,112 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestPreserveFiles(t *testing.T) {
+	var dirs []string
+	for _, name := range []string{"app", "layers", "artifacts"} {
+		dir, err := ioutil.TempDir("", name)
+		if err != nil {
+			t.Fatalf("creating temp dir: %v", err)
+		}
+		defer os.RemoveAll(dir)
+		dirs = append(dirs, dir)
+	}
+	app, layers, artifacts := dirs[0], dirs[1], dirs[2]
+	defer os.Unsetenv(env.ArtifactDir)
+	if err := os.Setenv(env.ArtifactDir, artifacts); err != nil {
+		t.Fatalf("setting %s: %v", env.ArtifactDir, err)
+	}
+	files := map[string]string{
+		"package.json":        `{"name": "app"}`,
+		"package-lock.json":   `{"lockfileVersion": 2}`,
+		"fn/sub/main.go":      "package main",
+		"fn/sub/unchanged.go": "package sub",
+	}
+	for name, content := range files {
+		p := filepath.Join(app, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("creating dir: %v", err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatalf("writing %s: %v", name, err)
+		}
+	}
+	ctx := newBuildContext(libcnb.BuildContext{
+		Application: libcnb.Application{Path: app},
+		Layers:      libcnb.Layers{Path: layers},
+	})
+
+	save := ctx.PreserveFiles("package.json", "package-lock.json", filepath.Join(app, "fn/sub/main.go"), "fn/sub/unchanged.go", "missing.json", "/etc/hostname")
+	ctx.WriteFile(filepath.Join(app, "package.json"), []byte(`{"name": "app", "dependencies": {}}`), 0644)
+	ctx.WriteFile(filepath.Join(app, "fn/sub/main.go"), []byte("package main // generated"), 0644)
+	ctx.RemoveAll(filepath.Join(app, "package-lock.json"))
+	save()
+
+	for _, root := range []string{filepath.Join(layers, backupLayer), artifacts} {
+		for _, name := range []string{"package.json", "package-lock.json", "fn/sub/main.go"} {
+			got, err := ioutil.ReadFile(filepath.Join(root, backupDir, name))
+			if err != nil {
+				t.Errorf("reading backup of %s in %s: %v", name, root, err)
+				continue
+			}
+			if string(got) != files[name] {
+				t.Errorf("backup of %s in %s = %q, want %q", name, root, got, files[name])
+			}
+		}
+		if _, err := os.Stat(filepath.Join(root, backupDir, "fn/sub/unchanged.go")); !os.IsNotExist(err) {
+			t.Errorf("unchanged file was backed up in %s: %v", root, err)
+		}
+	}
+	if len(ctx.buildResult.Layers) != 1 {
+		t.Errorf("build result has %d layers, want 1", len(ctx.buildResult.Layers))
+	}
+}
+
+func TestPreserveFilesUnchanged(t *testing.T) {
+	app, err := ioutil.TempDir("", "app")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(app)
+	layers, err := ioutil.TempDir("", "layers")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(layers)
+	if err := ioutil.WriteFile(filepath.Join(app, "package.json"), []byte("{}"), 0644); err != nil {
+		t.Fatalf("writing package.json: %v", err)
+	}
+	ctx := newBuildContext(libcnb.BuildContext{
+		Application: libcnb.Application{Path: app},
+		Layers:      libcnb.Layers{Path: layers},
+	})
+
+	ctx.PreserveFiles("package.json")()
+
+	if ctx.FileExists(layers, backupLayer) {
+		t.Errorf("backup layer was created although no file changed")
+	}
+}
@@ -62,6 +62,13 @@ type builderStat struct {
 	BuildpackVersion string `json:"buildpackVersion"`
 	DurationMs       int64  `json:"totalDurationMs"`
 	UserDurationMs   int64  `json:"userDurationMs"`
+	Retries          int    `json:"retries,omitempty"`
+	CachePurges      int    `json:"cachePurges,omitempty"`
+	CPUTimeMs        int64  `json:"cpuTimeMs,omitempty"`
+	PeakMemoryBytes  int64  `json:"peakMemoryBytes,omitempty"`
+	// ThrottledDownloads is the number of downloads that waited for a download slot for DownloadWaitMs.
+	ThrottledDownloads int   `json:"throttledDownloads,omitempty"`
+	DownloadWaitMs     int64 `json:"downloadWaitMs,omitempty"`
 }
 
 func (e *Error) Error() string {
@@ -195,10 +202,11 @@ func generateErrorID(parts ...string) ErrorID {
 	return ErrorID(strings.ToLower(result[:errorIDLength]))
 }
 
-func (ctx *Context) saveSuccessOutput(duration time.Duration) {
+// saveSuccessOutput appends the statistics of the build to the builder output file, if appropriate.
+func (ctx *Context) saveSuccessOutput(duration time.Duration) error {
 	outputDir := os.Getenv(builderOutputEnv)
 	if outputDir == "" {
-		return
+		return nil
 	}
 
 	var bo builderOutput
@@ -207,33 +215,35 @@ func (ctx *Context) saveSuccessOutput(duration time.Duration) {
 	if ctx.FileExists(fname) {
 		content, err := ioutil.ReadFile(fname)
 		if err != nil {
-			ctx.Warnf("Failed to read %s, skipping statistics: %v", fname, err)
-			return
+			return fmt.Errorf("reading %s: %v", fname, err)
 		}
 		if err := json.Unmarshal(content, &bo); err != nil {
-			ctx.Warnf("Failed to unmarshal %s, skipping statistics: %v", fname, err)
-			return
+			return fmt.Errorf("unmarshalling %s: %v", fname, err)
 		}
 	}
 
 	bo.Stats = append(bo.Stats, builderStat{
-		BuildpackID:      ctx.BuildpackID(),
-		BuildpackVersion: ctx.BuildpackVersion(),
-		DurationMs:       duration.Milliseconds(),
-		UserDurationMs:   ctx.stats.user.Milliseconds(),
+		BuildpackID:        ctx.BuildpackID(),
+		BuildpackVersion:   ctx.BuildpackVersion(),
+		DurationMs:         duration.Milliseconds(),
+		UserDurationMs:     ctx.stats.user.Milliseconds(),
+		Retries:            ctx.stats.retries,
+		CachePurges:        ctx.stats.cachePurges,
+		CPUTimeMs:          ctx.stats.cpu.Milliseconds(),
+		PeakMemoryBytes:    ctx.stats.peakRSS,
+		ThrottledDownloads: ctx.stats.throttled,
+		DownloadWaitMs:     ctx.stats.downloadWait.Milliseconds(),
 	})
 
 	content, err := json.Marshal(&bo)
 	if err != nil {
-		ctx.Warnf("Failed to marshal stats, skipping statistics: %v", err)
-		return
+		return fmt.Errorf("marshalling stats: %v", err)
 	}
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		ctx.Warnf("Failed to create dir %s, skipping statistics: %v", outputDir, err)
-		return
+		return fmt.Errorf("creating dir %s: %v", outputDir, err)
 	}
 	if err := ioutil.WriteFile(fname, content, 0644); err != nil {
-		ctx.Warnf("Failed to write %s, skipping statistics: %v", fname, err)
-		return
+		return fmt.Errorf("writing %s: %v", fname, err)
 	}
+	return nil
 }
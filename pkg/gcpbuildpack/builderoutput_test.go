@@ -316,7 +316,9 @@ func TestSaveBuilderSuccessOutput(t *testing.T) {
 			ctx := NewContext(libcnb.BuildpackInfo{ID: buildpackID, Version: buildpackVersion, Name: "name"})
 			ctx.stats.user = userDur
 
-			ctx.saveSuccessOutput(dur)
+			if err := ctx.saveSuccessOutput(dur); err != nil {
+				t.Fatalf("saveSuccessOutput() got error: %v", err)
+			}
 
 			var got builderOutput
 			content, err := ioutil.ReadFile(fname)
@@ -0,0 +1,520 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"archive/tar"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/kms"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// cacheEncryptionBindingType is the binding type providing, under the cacheEncryptionKey key, the
+	// resource name of the Cloud KMS key with which cache layers are encrypted.
+	cacheEncryptionBindingType = "cache-encryption"
+	cacheEncryptionKey         = "kms-key"
+
+	// encryptedLayerFile holds the encrypted contents of a cache layer.
+	encryptedLayerFile = "contents.enc"
+	// encryptionKeyMetadata and encryptionDataKeyMetadata record, in the metadata of an encrypted
+	// layer, the KMS key and the data key, encrypted with the KMS key, that encrypt its contents.
+	encryptionKeyMetadata     = "encryption_kms_key"
+	encryptionDataKeyMetadata = "encryption_data_key"
+
+	// encryptionChunkSize is the amount of plaintext sealed at once, so that large layers are encrypted
+	// and decrypted without holding them in memory.
+	encryptionChunkSize = 1 << 20
+	// lastChunkFlag marks the last sealed chunk, so that truncated contents are detected.
+	lastChunkFlag byte = 1
+)
+
+var (
+	// newKeyWrapper creates the client that encrypts and decrypts data keys.
+	newKeyWrapper = func() keyWrapper { return kms.NewClient() }
+)
+
+// keyWrapper encrypts and decrypts data keys with a key of a key management service.
+type keyWrapper interface {
+	Encrypt(key string, plaintext []byte) ([]byte, error)
+	Decrypt(key string, ciphertext []byte) ([]byte, error)
+}
+
+// cacheEncryption holds the KMS key with which cache layers are encrypted.
+type cacheEncryption struct {
+	key     string
+	wrapper keyWrapper
+	// decrypted records the paths of the layers restored during this build, and whether they could be
+	// decrypted, as a layer may be requested more than once.
+	decrypted map[string]bool
+}
+
+// useCacheEncryption enables the encryption of cache layers at rest when a cache-encryption binding
+// provides a KMS key. Platforms provide the binding when they export caches to shared backends, such
+// as cache images or buckets, for customers whose data-handling rules forbid storing source-derived
+// artifacts in plaintext.
+func (ctx *Context) useCacheEncryption() *Error {
+	ctx.encryption = &cacheEncryption{decrypted: map[string]bool{}}
+	path := ""
+	for _, b := range ctx.Bindings() {
+		if _, ok := b.Secret[cacheEncryptionKey]; ok && b.Type == cacheEncryptionBindingType {
+			path = filepath.Join(b.Path, cacheEncryptionKey)
+			break
+		}
+	}
+	if path == "" {
+		return nil
+	}
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return UserErrorf("reading the %s binding: %v", cacheEncryptionBindingType, err)
+	}
+	key := strings.TrimSpace(string(raw))
+	if err := kms.ValidateKeyName(key); err != nil {
+		return UserErrorf("invalid %s binding: %v", cacheEncryptionBindingType, err)
+	}
+	ctx.encryption.key = key
+	ctx.encryption.wrapper = newKeyWrapper()
+	ctx.Debugf("Encrypting cache layers with %s", key)
+	return nil
+}
+
+// decryptLayer restores the contents of a cache layer that a previous build encrypted. Layers that
+// cannot be decrypted, for example because the key changed or encryption was turned off, are cleared
+// so that the buildpack rebuilds them as on a cache miss.
+func (ctx *Context) decryptLayer(l *libcnb.Layer) {
+	key, _ := l.Metadata[encryptionKeyMetadata].(string)
+	wrapped, _ := l.Metadata[encryptionDataKeyMetadata].(string)
+	// Layers are not restored when planning, as the plan does not write them back.
+	if wrapped == "" || ctx.plan || ctx.encryption == nil {
+		return
+	}
+	delete(l.Metadata, encryptionKeyMetadata)
+	delete(l.Metadata, encryptionDataKeyMetadata)
+
+	ok, seen := ctx.encryption.decrypted[l.Path]
+	if !seen {
+		err := ctx.encryption.decryptLayer(l.Path, key, wrapped)
+		if err != nil {
+			ctx.Warnf("Discarding cache layer %s: %v", l.Name, err)
+			ctx.ClearLayer(l)
+		} else {
+			ctx.Debugf("Decrypted cache layer %s", l.Name)
+		}
+		ok = err == nil
+		ctx.encryption.decrypted[l.Path] = ok
+	}
+	if !ok {
+		l.Metadata = map[string]interface{}{}
+	}
+}
+
+// encryptCacheLayers replaces the contents of the cache-only layers of the buildpack with an archive
+// encrypted with a new data key, which is recorded in the layer metadata encrypted with the KMS key.
+// Layers that later buildpacks or the image use are left readable.
+func (ctx *Context) encryptCacheLayers() error {
+	if ctx.encryption == nil || ctx.encryption.key == "" {
+		return nil
+	}
+	var layers []*libcnb.Layer
+	cacheOnly := map[string]bool{}
+	for _, lc := range ctx.buildResult.Layers {
+		c, ok := lc.(layerContributor)
+		if !ok || !c.l.Cache {
+			continue
+		}
+		layers = append(layers, c.l)
+		only, seen := cacheOnly[c.l.Path]
+		cacheOnly[c.l.Path] = (only || !seen) && !c.l.Build && !c.l.Launch
+	}
+
+	metadata := map[string]map[string]string{}
+	readable := map[string]bool{}
+	for _, l := range layers {
+		if !cacheOnly[l.Path] {
+			readable[l.Name] = true
+			continue
+		}
+		md, ok := metadata[l.Path]
+		if !ok {
+			var err error
+			if md, err = ctx.encryption.encryptLayer(l.Path); err != nil {
+				return InternalErrorf("encrypting cache layer %s: %v", l.Name, err)
+			}
+			metadata[l.Path] = md
+			ctx.Debugf("Encrypted cache layer %s", l.Name)
+		}
+		for k, v := range md {
+			l.Metadata[k] = v
+		}
+	}
+	if len(readable) > 0 {
+		var names []string
+		for name := range readable {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		ctx.Logf("Not encrypting cache layers %s, which later buildpacks or the image use", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// encryptLayer archives and encrypts the contents of the layer at dir, and returns the layer metadata
+// needed to decrypt them.
+func (e *cacheEncryption) encryptLayer(dir string) (map[string]string, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("generating data key: %v", err)
+	}
+	wrapped, err := e.wrapper.Encrypt(e.key, dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("encrypting data key with %s: %v", e.key, err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, encryptedLayerFile), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	w, err := newEncryptingWriter(f, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeTar(w, dir, encryptedLayerFile); err != nil {
+		return nil, fmt.Errorf("archiving: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, fi := range entries {
+		if fi.Name() == encryptedLayerFile {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(dir, fi.Name())); err != nil {
+			return nil, fmt.Errorf("removing plaintext: %v", err)
+		}
+	}
+	return map[string]string{
+		encryptionKeyMetadata:     e.key,
+		encryptionDataKeyMetadata: base64.StdEncoding.EncodeToString(wrapped),
+	}, nil
+}
+
+// decryptLayer restores the contents of the layer at dir, which were encrypted with a data key that key
+// encrypted into wrapped.
+func (e *cacheEncryption) decryptLayer(dir, key, wrapped string) error {
+	if e.key == "" {
+		return fmt.Errorf("it is encrypted with %s, but no %s binding was provided", key, cacheEncryptionBindingType)
+	}
+	if key != e.key {
+		return fmt.Errorf("it is encrypted with %s instead of %s", key, e.key)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil {
+		return fmt.Errorf("decoding data key: %v", err)
+	}
+	dataKey, err := e.wrapper.Decrypt(key, ciphertext)
+	if err != nil {
+		return fmt.Errorf("decrypting data key with %s: %v", key, err)
+	}
+
+	path := filepath.Join(dir, encryptedLayerFile)
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r, err := newDecryptingReader(f, dataKey)
+	if err != nil {
+		return err
+	}
+	if err := readTar(r, dir); err != nil {
+		return fmt.Errorf("extracting: %v", err)
+	}
+	// The archive ends before the last chunk; reading it authenticates the end of the contents.
+	if _, err := io.Copy(ioutil.Discard, r); err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(path)
+}
+
+// writeTar writes the files under root, except exclude, to w as a tar archive. Special files, such as
+// sockets, are not archived.
+func writeTar(w io.Writer, root, exclude string) error {
+	tw := tar.NewWriter(w)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." || rel == exclude {
+			return nil
+		}
+		link := ""
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		case !info.IsDir() && !info.Mode().IsRegular():
+			return nil
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// readTar extracts the tar archive read from r into root. Directory permissions are applied last, so
+// that files can be extracted into read-only directories.
+func readTar(r io.Reader, root string) error {
+	type dir struct {
+		path string
+		mode os.FileMode
+	}
+	var dirs []dir
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(root, filepath.FromSlash(hdr.Name))
+		if !strings.HasPrefix(path, root+string(filepath.Separator)) {
+			return fmt.Errorf("invalid path %q", hdr.Name)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, layerMode); err != nil {
+				return err
+			}
+			dirs = append(dirs, dir{path: path, mode: hdr.FileInfo().Mode().Perm()})
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), layerMode); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, hdr.FileInfo().Mode().Perm())
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return err
+			}
+			// Some caches, such as the Python bytecode cache, compare modification times.
+			if err := os.Chtimes(path, hdr.ModTime, hdr.ModTime); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.MkdirAll(filepath.Dir(path), layerMode); err != nil {
+				return err
+			}
+			if err := os.Symlink(hdr.Linkname, path); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported type %q of %q", hdr.Typeflag, hdr.Name)
+		}
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i].path, dirs[i].mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce returns the nonce of the chunk at index. Nonces are unique because every layer is
+// encrypted with a new data key.
+func chunkNonce(aead cipher.AEAD, index uint64) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], index)
+	return nonce
+}
+
+// encryptingWriter seals what is written to it with AES-256-GCM, in chunks of encryptionChunkSize.
+// Each sealed chunk is preceded by a flag, which marks the last chunk and is authenticated with it,
+// and by its big-endian 32-bit length.
+type encryptingWriter struct {
+	w     io.Writer
+	aead  cipher.AEAD
+	buf   []byte
+	index uint64
+}
+
+func newEncryptingWriter(w io.Writer, key []byte) (*encryptingWriter, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptingWriter{w: w, aead: aead, buf: make([]byte, 0, encryptionChunkSize)}, nil
+}
+
+func (e *encryptingWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		c := encryptionChunkSize - len(e.buf)
+		if c > len(p) {
+			c = len(p)
+		}
+		e.buf = append(e.buf, p[:c]...)
+		p = p[c:]
+		if len(e.buf) == encryptionChunkSize {
+			if err := e.seal(0); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Close seals the last chunk, which may be empty.
+func (e *encryptingWriter) Close() error {
+	return e.seal(lastChunkFlag)
+}
+
+func (e *encryptingWriter) seal(flag byte) error {
+	sealed := e.aead.Seal(nil, chunkNonce(e.aead, e.index), e.buf, []byte{flag})
+	var hdr [5]byte
+	hdr[0] = flag
+	binary.BigEndian.PutUint32(hdr[1:], uint32(len(sealed)))
+	if _, err := e.w.Write(hdr[:]); err != nil {
+		return err
+	}
+	if _, err := e.w.Write(sealed); err != nil {
+		return err
+	}
+	e.index++
+	e.buf = e.buf[:0]
+	return nil
+}
+
+// decryptingReader opens the chunks sealed by encryptingWriter.
+type decryptingReader struct {
+	r     io.Reader
+	aead  cipher.AEAD
+	buf   []byte
+	index uint64
+	last  bool
+}
+
+func newDecryptingReader(r io.Reader, key []byte) (*decryptingReader, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptingReader{r: r, aead: aead}, nil
+}
+
+func (d *decryptingReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.last {
+			return 0, io.EOF
+		}
+		if err := d.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+func (d *decryptingReader) open() error {
+	var hdr [5]byte
+	if _, err := io.ReadFull(d.r, hdr[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return errors.New("encrypted contents are truncated")
+		}
+		return err
+	}
+	n := binary.BigEndian.Uint32(hdr[1:])
+	if n > uint32(encryptionChunkSize+d.aead.Overhead()) {
+		return fmt.Errorf("invalid length %d of chunk %d", n, d.index)
+	}
+	sealed := make([]byte, n)
+	if _, err := io.ReadFull(d.r, sealed); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return errors.New("encrypted contents are truncated")
+		}
+		return err
+	}
+	plain, err := d.aead.Open(nil, chunkNonce(d.aead, d.index), sealed, hdr[:1])
+	if err != nil {
+		return fmt.Errorf("decrypting chunk %d: %v", d.index, err)
+	}
+	d.buf = plain
+	d.index++
+	d.last = hdr[0] == lastChunkFlag
+	return nil
+}
@@ -0,0 +1,242 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/buildpacks/libcnb"
+)
+
+const testKMSKey = "projects/p/locations/global/keyRings/r/cryptoKeys/k"
+
+// fakeKeyWrapper "encrypts" data keys by prefixing them with the name of the key.
+type fakeKeyWrapper struct{}
+
+func (fakeKeyWrapper) Encrypt(key string, plaintext []byte) ([]byte, error) {
+	return append([]byte(key+":"), plaintext...), nil
+}
+
+func (fakeKeyWrapper) Decrypt(key string, ciphertext []byte) ([]byte, error) {
+	if !bytes.HasPrefix(ciphertext, []byte(key+":")) {
+		return nil, fmt.Errorf("ciphertext was not encrypted with %s", key)
+	}
+	return ciphertext[len(key)+1:], nil
+}
+
+func TestEncryptingWriter(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	for _, size := range []int{0, 10, encryptionChunkSize, 2*encryptionChunkSize + 3} {
+		t.Run(fmt.Sprintf("%d bytes", size), func(t *testing.T) {
+			plain := bytes.Repeat([]byte("0123456789"), size/10+1)[:size]
+			var sealed bytes.Buffer
+			w, err := newEncryptingWriter(&sealed, key)
+			if err != nil {
+				t.Fatalf("newEncryptingWriter() got error: %v", err)
+			}
+			if _, err := w.Write(plain); err != nil {
+				t.Fatalf("Write() got error: %v", err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close() got error: %v", err)
+			}
+			if size > 0 && bytes.Contains(sealed.Bytes(), plain) {
+				t.Errorf("sealed contents contain the plaintext")
+			}
+
+			r, err := newDecryptingReader(bytes.NewReader(sealed.Bytes()), key)
+			if err != nil {
+				t.Fatalf("newDecryptingReader() got error: %v", err)
+			}
+			got, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatalf("reading decrypted contents got error: %v", err)
+			}
+			if !bytes.Equal(got, plain) {
+				t.Errorf("decrypted %d bytes, want the %d bytes written", len(got), len(plain))
+			}
+
+			truncated := sealed.Bytes()[:sealed.Len()-1]
+			r, err = newDecryptingReader(bytes.NewReader(truncated), key)
+			if err != nil {
+				t.Fatalf("newDecryptingReader() got error: %v", err)
+			}
+			if _, err := ioutil.ReadAll(r); err == nil {
+				t.Errorf("reading truncated contents got nil error")
+			}
+		})
+	}
+}
+
+func TestCacheLayerEncryption(t *testing.T) {
+	defer func(f func() keyWrapper) { newKeyWrapper = f }(newKeyWrapper)
+	newKeyWrapper = func() keyWrapper { return fakeKeyWrapper{} }
+	layers, err := ioutil.TempDir("", "layers")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(layers)
+
+	// Build writes a cache-only layer and a cache layer used by later buildpacks.
+	ctx := encryptionTestContext(t, layers, testKMSKey)
+	cache := ctx.Layer("cache", CacheLayer)
+	ctx.SetMetadata(cache, "version", "1")
+	files := map[string]string{
+		"pip/http/abc":  "wheel",
+		"pip/selfcheck": "{}",
+	}
+	writeFiles(t, cache.Path, files)
+	if err := os.Symlink("pip/selfcheck", filepath.Join(cache.Path, "link")); err != nil {
+		t.Fatalf("creating symlink: %v", err)
+	}
+	if err := os.Chmod(filepath.Join(cache.Path, "pip/http"), 0555); err != nil {
+		t.Fatalf("making dir read-only: %v", err)
+	}
+	deps := ctx.Layer("deps", BuildLayer, CacheLayer)
+	writeFiles(t, deps.Path, map[string]string{"lib.so": "lib"})
+
+	if err := ctx.encryptCacheLayers(); err != nil {
+		t.Fatalf("encryptCacheLayers() got error: %v", err)
+	}
+	if got, want := dirNames(t, cache.Path), []string{encryptedLayerFile}; !reflect.DeepEqual(got, want) {
+		t.Errorf("encrypted layer contains %v, want %v", got, want)
+	}
+	if got := cache.Metadata[encryptionKeyMetadata]; got != testKMSKey {
+		t.Errorf("layer metadata %s = %v, want %s", encryptionKeyMetadata, got, testKMSKey)
+	}
+	if got, want := dirNames(t, deps.Path), []string{"lib.so"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("build layer contains %v, want %v", got, want)
+	}
+	if _, ok := deps.Metadata[encryptionDataKeyMetadata]; ok {
+		t.Errorf("build layer metadata has %s, want none", encryptionDataKeyMetadata)
+	}
+	writeLayerMetadata(t, layers, cache)
+
+	// The next build restores the layer.
+	ctx = encryptionTestContext(t, layers, testKMSKey)
+	cache = ctx.Layer("cache", CacheLayer)
+	for name, want := range files {
+		got, err := ioutil.ReadFile(filepath.Join(cache.Path, name))
+		if err != nil {
+			t.Errorf("reading restored %s: %v", name, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("restored %s = %q, want %q", name, got, want)
+		}
+	}
+	if got, err := os.Readlink(filepath.Join(cache.Path, "link")); err != nil || got != "pip/selfcheck" {
+		t.Errorf("restored link points to %q (%v), want %q", got, err, "pip/selfcheck")
+	}
+	if fi, err := os.Stat(filepath.Join(cache.Path, "pip/http")); err != nil || fi.Mode().Perm() != 0555 {
+		t.Errorf("restored dir has mode %v (%v), want %v", fi.Mode().Perm(), err, os.FileMode(0555))
+	}
+	if _, err := os.Stat(filepath.Join(cache.Path, encryptedLayerFile)); !os.IsNotExist(err) {
+		t.Errorf("encrypted contents were not removed: %v", err)
+	}
+	if want := map[string]interface{}{"version": "1"}; !reflect.DeepEqual(cache.Metadata, want) {
+		t.Errorf("restored layer metadata = %v, want %v", cache.Metadata, want)
+	}
+	if err := os.Chmod(filepath.Join(cache.Path, "pip/http"), 0755); err != nil {
+		t.Fatalf("making dir writable: %v", err)
+	}
+	if err := ctx.encryptCacheLayers(); err != nil {
+		t.Fatalf("encryptCacheLayers() got error: %v", err)
+	}
+	writeLayerMetadata(t, layers, cache)
+
+	// A build with another key discards the layer.
+	ctx = encryptionTestContext(t, layers, "projects/p/locations/global/keyRings/r/cryptoKeys/other")
+	cache = ctx.Layer("cache", CacheLayer)
+	if got := dirNames(t, cache.Path); len(got) != 0 {
+		t.Errorf("layer encrypted with another key contains %v, want it cleared", got)
+	}
+	if len(cache.Metadata) != 0 {
+		t.Errorf("layer encrypted with another key has metadata %v, want none", cache.Metadata)
+	}
+}
+
+func encryptionTestContext(t *testing.T, layers, key string) *Context {
+	t.Helper()
+	binding, err := ioutil.TempDir("", "binding")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(binding)
+	if err := ioutil.WriteFile(filepath.Join(binding, cacheEncryptionKey), []byte(key+"\n"), 0644); err != nil {
+		t.Fatalf("writing binding: %v", err)
+	}
+	ctx := newBuildContext(libcnb.BuildContext{
+		Layers: libcnb.Layers{Path: layers},
+		Platform: libcnb.Platform{Bindings: libcnb.Bindings{{
+			Name:   "cache",
+			Path:   binding,
+			Type:   cacheEncryptionBindingType,
+			Secret: map[string]string{cacheEncryptionKey: key},
+		}}},
+	})
+	if err := ctx.useCacheEncryption(); err != nil {
+		t.Fatalf("useCacheEncryption() got error: %v", err)
+	}
+	return ctx
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("creating dir: %v", err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatalf("writing %s: %v", name, err)
+		}
+	}
+}
+
+// writeLayerMetadata writes the metadata of l the way libcnb does at the end of the build.
+func writeLayerMetadata(t *testing.T, layers string, l *libcnb.Layer) {
+	t.Helper()
+	var b strings.Builder
+	b.WriteString("cache = true\n\n[metadata]\n")
+	for k, v := range l.Metadata {
+		fmt.Fprintf(&b, "%s = %q\n", k, v)
+	}
+	if err := ioutil.WriteFile(filepath.Join(layers, l.Name+".toml"), []byte(b.String()), 0644); err != nil {
+		t.Fatalf("writing layer metadata: %v", err)
+	}
+}
+
+func dirNames(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("reading %s: %v", dir, err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
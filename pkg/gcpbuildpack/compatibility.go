@@ -0,0 +1,127 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// compatibilityKey is the buildpack.toml metadata key holding the compatibility version.
+	// Buildpacks with the same compatibility version agree on the env vars, layer metadata and
+	// labels they exchange, and are released together.
+	compatibilityKey = "compatibility"
+	// ownedPrefix identifies buildpacks maintained in this repository.
+	ownedPrefix = "google."
+)
+
+var (
+	// orderPath and buildpacksDir are where the builder stores its order and buildpacks.
+	orderPath     = "/cnb/order.toml"
+	buildpacksDir = "/cnb/buildpacks"
+)
+
+// builderOrder is the content of the builder's order.toml.
+type builderOrder struct {
+	Order []libcnb.BuildpackOrder `toml:"order"`
+}
+
+// compatibilityMetadata is the subset of buildpack.toml read to check compatibility.
+type compatibilityMetadata struct {
+	Metadata map[string]interface{} `toml:"metadata"`
+}
+
+// checkCompatibility verifies that every buildpack from this repository that shares a group with
+// the current buildpack declares the same compatibility version. Builders mixing buildpacks from
+// different releases fail at detect time rather than producing subtly broken images.
+func (ctx *Context) checkCompatibility() *Error {
+	own := compatibility(ctx.detectContext.Buildpack.Metadata)
+	if own == "" {
+		return nil
+	}
+	var order builderOrder
+	if _, err := toml.DecodeFile(orderPath, &order); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return InternalErrorf("decoding %s: %v", orderPath, err)
+	}
+
+	mismatched := make(map[string]bool)
+	for _, o := range order.Order {
+		if !inGroup(o, ctx.info.ID) {
+			continue
+		}
+		for _, bp := range o.Groups {
+			if bp.ID == ctx.info.ID || !strings.HasPrefix(bp.ID, ownedPrefix) {
+				continue
+			}
+			v, found, err := peerCompatibility(bp)
+			if err != nil {
+				return err
+			}
+			if found && v != own {
+				mismatched[fmt.Sprintf("%s@%s (compatibility %q)", bp.ID, bp.Version, v)] = true
+			}
+		}
+	}
+	if len(mismatched) == 0 {
+		return nil
+	}
+	var peers []string
+	for p := range mismatched {
+		peers = append(peers, p)
+	}
+	sort.Strings(peers)
+	return Errorf(StatusFailedPrecondition, "buildpack %s@%s (compatibility %q) is grouped with buildpacks from a different release: %s; build the builder from buildpacks of a single release", ctx.info.ID, ctx.info.Version, own, strings.Join(peers, ", "))
+}
+
+// peerCompatibility returns the compatibility version declared by a buildpack installed in the
+// builder, and whether the buildpack was found.
+func peerCompatibility(bp libcnb.BuildpackOrderBuildpack) (string, bool, *Error) {
+	path := filepath.Join(buildpacksDir, strings.ReplaceAll(bp.ID, "/", "_"), bp.Version, "buildpack.toml")
+	var md compatibilityMetadata
+	if _, err := toml.DecodeFile(path, &md); err != nil {
+		if os.IsNotExist(err) {
+			return "", false, nil
+		}
+		return "", false, InternalErrorf("decoding %s: %v", path, err)
+	}
+	return compatibility(md.Metadata), true, nil
+}
+
+func compatibility(metadata map[string]interface{}) string {
+	v, ok := metadata[compatibilityKey].(string)
+	if !ok {
+		return ""
+	}
+	return v
+}
+
+func inGroup(o libcnb.BuildpackOrder, id string) bool {
+	for _, bp := range o.Groups {
+		if bp.ID == id {
+			return true
+		}
+	}
+	return false
+}
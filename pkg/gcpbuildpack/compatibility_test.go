@@ -0,0 +1,126 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/buildpacks/libcnb"
+)
+
+func TestCheckCompatibility(t *testing.T) {
+	order := `
+[[order]]
+  [[order.group]]
+    id = "google.python.runtime"
+    version = "0.9.1"
+  [[order.group]]
+    id = "google.python.pip"
+    version = "0.9.0"
+  [[order.group]]
+    id = "other.buildpack"
+    version = "1.0.0"
+
+[[order]]
+  [[order.group]]
+    id = "google.nodejs.runtime"
+    version = "0.9.0"
+`
+	testCases := []struct {
+		name       string
+		own        string
+		peers      map[string]string
+		wantErrors []string
+	}{
+		{
+			name:  "same release",
+			own:   "1",
+			peers: map[string]string{"google.python.runtime": "1", "google.nodejs.runtime": "2"},
+		},
+		{
+			name:       "mixed release",
+			own:        "1",
+			peers:      map[string]string{"google.python.runtime": "2"},
+			wantErrors: []string{`google.python.runtime@0.9.1 (compatibility "2")`},
+		},
+		{
+			name:       "peer predates compatibility",
+			own:        "1",
+			peers:      map[string]string{"google.python.runtime": ""},
+			wantErrors: []string{`google.python.runtime@0.9.1 (compatibility "")`},
+		},
+		{
+			name:  "unstamped buildpack is not checked",
+			peers: map[string]string{"google.python.runtime": "2"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "compatibility")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			defer func(o, b string) { orderPath, buildpacksDir = o, b }(orderPath, buildpacksDir)
+			orderPath = filepath.Join(dir, "order.toml")
+			buildpacksDir = filepath.Join(dir, "buildpacks")
+			if err := ioutil.WriteFile(orderPath, []byte(order), 0644); err != nil {
+				t.Fatalf("writing order: %v", err)
+			}
+			versions := map[string]string{"google.python.runtime": "0.9.1", "google.nodejs.runtime": "0.9.0"}
+			for id, v := range tc.peers {
+				bpDir := filepath.Join(buildpacksDir, id, versions[id])
+				if err := os.MkdirAll(bpDir, 0755); err != nil {
+					t.Fatalf("creating %s: %v", bpDir, err)
+				}
+				descriptor := "[buildpack]\nid = \"" + id + "\"\n"
+				if v != "" {
+					descriptor += "[metadata]\ncompatibility = \"" + v + "\"\n"
+				}
+				if err := ioutil.WriteFile(filepath.Join(bpDir, "buildpack.toml"), []byte(descriptor), 0644); err != nil {
+					t.Fatalf("writing descriptor: %v", err)
+				}
+			}
+			bp := libcnb.Buildpack{Info: libcnb.BuildpackInfo{ID: "google.python.pip", Version: "0.9.0"}}
+			if tc.own != "" {
+				bp.Metadata = map[string]interface{}{compatibilityKey: tc.own}
+			}
+			ctx := newDetectContext(libcnb.DetectContext{Buildpack: bp})
+
+			cerr := ctx.checkCompatibility()
+			if len(tc.wantErrors) == 0 {
+				if cerr != nil {
+					t.Errorf("checkCompatibility() got error: %v, want nil", cerr)
+				}
+				return
+			}
+			if cerr == nil {
+				t.Fatalf("checkCompatibility() got nil error, want error")
+			}
+			if cerr.Status != StatusFailedPrecondition {
+				t.Errorf("checkCompatibility() status = %s, want %s", cerr.Status, StatusFailedPrecondition)
+			}
+			for _, w := range tc.wantErrors {
+				if !strings.Contains(cerr.Error(), w) {
+					t.Errorf("checkCompatibility() = %q, want it to contain %q", cerr, w)
+				}
+			}
+		})
+	}
+}
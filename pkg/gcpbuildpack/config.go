@@ -0,0 +1,116 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ConfigDefault is the source of settings that no ConfigSource sets.
+const ConfigDefault = "default"
+
+// ConfigSource is one place from which a setting, such as the runtime version, may be read.
+type ConfigSource struct {
+	// Name identifies the source in the provenance trace, e.g. GOOGLE_RUNTIME_VERSION or engines.node.
+	Name string
+	// Value returns the value of the setting in the source, and false if the source does not set it.
+	Value func(ctx *Context) (string, bool, error)
+}
+
+// EnvConfig returns a source that reads a setting from the env var name. Empty values are not set.
+func EnvConfig(name string) ConfigSource {
+	return ConfigSource{Name: name, Value: func(*Context) (string, bool, error) {
+		v := os.Getenv(name)
+		return v, v != "", nil
+	}}
+}
+
+// FileConfig returns a source that reads a setting from the content of the file at path, relative to
+// the application root, trimmed of spaces. A file that exists but is empty is a user error.
+func FileConfig(path string) ConfigSource {
+	return ConfigSource{Name: path, Value: func(ctx *Context) (string, bool, error) {
+		p := filepath.Join(ctx.ApplicationRoot(), path)
+		if !ctx.FileExists(p) {
+			return "", false, nil
+		}
+		v := strings.TrimSpace(string(ctx.ReadFile(p)))
+		if v == "" {
+			return "", false, UserErrorf("%s exists but does not specify a value", path)
+		}
+		return v, true, nil
+	}}
+}
+
+// ConfigValue is a resolved setting and where it came from.
+type ConfigValue struct {
+	// Setting names the setting, e.g. "Node.js version".
+	Setting string
+	// Value is the value of the setting, empty if Source is ConfigDefault.
+	Value string
+	// Source is the name of the source that set the value, or ConfigDefault.
+	Source string
+	// Overridden lists the lower-precedence sources that set the setting to a different value.
+	Overridden []ConfigValue
+}
+
+// String returns the provenance trace of the value, e.g.
+// `Node.js version "20" chosen from engines.node, overriding .nvmrc ("18")`.
+func (v ConfigValue) String() string {
+	if v.Source == ConfigDefault {
+		return fmt.Sprintf("%s not set, using the default", v.Setting)
+	}
+	s := fmt.Sprintf("%s %q chosen from %s", v.Setting, v.Value, v.Source)
+	var overridden []string
+	for _, o := range v.Overridden {
+		overridden = append(overridden, fmt.Sprintf("%s (%q)", o.Source, o.Value))
+	}
+	if len(overridden) > 0 {
+		s += ", overriding " + strings.Join(overridden, ", ")
+	}
+	return s
+}
+
+// ResolveConfig returns the value of setting from the first of sources, in decreasing order of
+// precedence, that sets it, and logs its provenance. Every source is read, so that conflicting
+// declarations are reported. If no source sets it, the returned value has Source ConfigDefault and
+// the caller applies its default.
+func (ctx *Context) ResolveConfig(setting string, sources ...ConfigSource) (ConfigValue, error) {
+	var found []ConfigValue
+	for _, s := range sources {
+		v, ok, err := s.Value(ctx)
+		if err != nil {
+			return ConfigValue{}, err
+		}
+		if ok {
+			found = append(found, ConfigValue{Setting: setting, Value: v, Source: s.Name})
+		}
+	}
+	if len(found) == 0 {
+		v := ConfigValue{Setting: setting, Source: ConfigDefault}
+		ctx.Logf("%s", v)
+		return v, nil
+	}
+	v := found[0]
+	for _, o := range found[1:] {
+		if o.Value != v.Value {
+			v.Overridden = append(v.Overridden, o)
+		}
+	}
+	ctx.Logf("%s", v)
+	return v, nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/buildpacks/libcnb"
+)
+
+func TestResolveConfig(t *testing.T) {
+	const envVar = "TEST_CONFIG_VERSION"
+	static := func(name, value string) ConfigSource {
+		return ConfigSource{Name: name, Value: func(*Context) (string, bool, error) { return value, value != "", nil }}
+	}
+	testCases := []struct {
+		name    string
+		env     string
+		files   map[string]string
+		sources []ConfigSource
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "env overrides files",
+			env:     "20",
+			files:   map[string]string{".nvmrc": "18\n"},
+			sources: []ConfigSource{EnvConfig(envVar), static("engines.node", "16"), FileConfig(".nvmrc")},
+			want:    `node version "20" chosen from TEST_CONFIG_VERSION, overriding engines.node ("16"), .nvmrc ("18")`,
+		},
+		{
+			name:    "first file",
+			files:   map[string]string{".nvmrc": "18\n"},
+			sources: []ConfigSource{EnvConfig(envVar), static("engines.node", "20"), FileConfig(".nvmrc")},
+			want:    `node version "20" chosen from engines.node, overriding .nvmrc ("18")`,
+		},
+		{
+			name:    "same value is not overridden",
+			files:   map[string]string{".nvmrc": "20"},
+			sources: []ConfigSource{static("engines.node", "20"), FileConfig(".nvmrc")},
+			want:    `node version "20" chosen from engines.node`,
+		},
+		{
+			name:    "default",
+			sources: []ConfigSource{EnvConfig(envVar), FileConfig(".nvmrc")},
+			want:    "node version not set, using the default",
+		},
+		{
+			name:    "empty file",
+			files:   map[string]string{".nvmrc": " \n"},
+			sources: []ConfigSource{FileConfig(".nvmrc")},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "config")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			for name, content := range tc.files {
+				if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatalf("writing %s: %v", name, err)
+				}
+			}
+			defer os.Unsetenv(envVar)
+			if err := os.Setenv(envVar, tc.env); err != nil {
+				t.Fatalf("setting %s: %v", envVar, err)
+			}
+			ctx := NewContextForTests(libcnb.BuildpackInfo{}, dir)
+
+			got, err := ctx.ResolveConfig("node version", tc.sources...)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ResolveConfig() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if !tc.wantErr && got.String() != tc.want {
+				t.Errorf("ResolveConfig() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
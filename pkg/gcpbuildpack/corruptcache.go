@@ -0,0 +1,54 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"regexp"
+)
+
+var (
+	// corruptCacheRegexps match output of package managers that indicates that a cached download is
+	// corrupted, as opposed to a problem with the user's dependencies.
+	corruptCacheRegexps = []*regexp.Regexp{
+		// Go module cache.
+		regexp.MustCompile(`verifying .*: checksum mismatch`),
+		regexp.MustCompile(`zip: not a valid zip file`),
+		// npm and yarn.
+		regexp.MustCompile(`\bEINTEGRITY\b`),
+		regexp.MustCompile(`(?i)\bintegrity check failed\b`),
+		regexp.MustCompile(`Incorrect integrity when fetching from the cache`),
+		regexp.MustCompile(`npm ERR! Unexpected end of JSON input while parsing`),
+	}
+)
+
+// isCorruptCacheError returns true if the output of a failed command indicates a corrupted cache.
+func isCorruptCacheError(output string) bool {
+	for _, re := range corruptCacheRegexps {
+		if re.MatchString(output) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithCorruptCacheRetry re-runs the command once, after purging the caches it uses with purge, if it
+// fails with an error that indicates a corrupted cache, such as a checksum mismatch of a cached
+// module. The command is not re-run if purge fails. Only use this for commands that are safe to
+// re-run, such as dependency installs.
+func WithCorruptCacheRetry(purge func() error) execOption {
+	return func(o *execParams) {
+		o.purgeCache = purge
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsCorruptCacheError(t *testing.T) {
+	testCases := []struct {
+		name   string
+		output string
+		want   bool
+	}{
+		{
+			name:   "go checksum mismatch",
+			output: "verifying github.com/foo/bar@v1.2.3: checksum mismatch\n\tdownloaded: h1:abc=\n\tgo.sum:     h1:def=",
+			want:   true,
+		},
+		{
+			name:   "go invalid zip",
+			output: "go: github.com/foo/bar@v1.2.3: zip: not a valid zip file",
+			want:   true,
+		},
+		{
+			name:   "npm integrity",
+			output: "npm ERR! code EINTEGRITY\nnpm ERR! sha512-abc integrity checksum failed when using sha512",
+			want:   true,
+		},
+		{
+			name:   "yarn integrity",
+			output: `error Incorrect integrity when fetching from the cache for "express".`,
+			want:   true,
+		},
+		{
+			name:   "npm missing package",
+			output: "npm ERR! code E404\nnpm ERR! 404 Not Found - GET https://registry.npmjs.org/does-not-exist",
+			want:   false,
+		},
+		{
+			name:   "go missing module",
+			output: "go: github.com/foo/bar@v1.2.3: reading github.com/foo/bar/go.mod at revision v1.2.3: unknown revision v1.2.3",
+			want:   false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isCorruptCacheError(tc.output); got != tc.want {
+				t.Errorf("isCorruptCacheError(%q) = %t, want %t", tc.output, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestExecWithCorruptCacheRetry(t *testing.T) {
+	testCases := []struct {
+		name       string
+		failures   int
+		output     string
+		purgeErr   error
+		wantErr    bool
+		wantPurges int
+	}{
+		{
+			name:       "succeeds after purge",
+			failures:   1,
+			output:     "npm ERR! code EINTEGRITY",
+			wantPurges: 1,
+		},
+		{
+			name:       "retries only once",
+			failures:   2,
+			output:     "npm ERR! code EINTEGRITY",
+			wantErr:    true,
+			wantPurges: 1,
+		},
+		{
+			name:     "does not retry other failures",
+			failures: 1,
+			output:   "npm ERR! code E404",
+			wantErr:  true,
+		},
+		{
+			name:     "does not retry if purge fails",
+			failures: 1,
+			output:   "npm ERR! code EINTEGRITY",
+			purgeErr: errors.New("permission denied"),
+			wantErr:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cleanUp := simpleContext(t)
+			defer cleanUp()
+
+			dir, err := ioutil.TempDir("", "purge-")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			counter := filepath.Join(dir, "attempts")
+			// Fail with the given output until the command has been run more than tc.failures times.
+			script := fmt.Sprintf(`echo x >> %[1]s; if [ $(wc -l < %[1]s) -le %[2]d ]; then echo %[3]q; exit 1; fi`, counter, tc.failures, tc.output)
+			purged := 0
+			purge := func() error {
+				purged++
+				return tc.purgeErr
+			}
+
+			_, gotErr := ctx.ExecWithErr([]string{"/bin/bash", "-c", script}, WithCorruptCacheRetry(purge))
+
+			if (gotErr != nil) != tc.wantErr {
+				t.Errorf("ExecWithErr() got error: %v, want error: %t", gotErr, tc.wantErr)
+			}
+			if ctx.stats.cachePurges != tc.wantPurges {
+				t.Errorf("ExecWithErr() retried %d times after purging, want %d", ctx.stats.cachePurges, tc.wantPurges)
+			}
+			if tc.wantPurges > 0 && purged != tc.wantPurges {
+				t.Errorf("purge called %d times, want %d", purged, tc.wantPurges)
+			}
+		})
+	}
+}
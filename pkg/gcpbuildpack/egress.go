@@ -0,0 +1,261 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+const (
+	// egressFilename is the file in the builder output directory to which the hosts contacted during
+	// the build are appended, one JSON object per host and buildpack.
+	egressFilename = "egress"
+)
+
+var (
+	// proxyEnvs are the env vars with which commands are pointed at the egress proxy.
+	proxyEnvs = []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"}
+	// hopHeaders are removed from requests forwarded by the egress proxy.
+	hopHeaders = []string{"Connection", "Proxy-Connection", "Proxy-Authorization", "Keep-Alive", "Te", "Trailer", "Upgrade"}
+)
+
+// egressHost is a host contacted during the build of a buildpack.
+type egressHost struct {
+	BuildpackID string `json:"buildpackId"`
+	Host        string `json:"host"`
+	Requests    int    `json:"requests"`
+}
+
+// egressProxy is an HTTP proxy that records the hosts that it connects to.
+type egressProxy struct {
+	listener  net.Listener
+	transport *http.Transport
+
+	mu    sync.Mutex
+	hosts map[string]int
+}
+
+// startEgressProxy starts an egress proxy on a free local port.
+func startEgressProxy() (*egressProxy, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("listening: %v", err)
+	}
+	p := &egressProxy{
+		listener:  l,
+		transport: &http.Transport{Proxy: nil, DialContext: (&net.Dialer{Timeout: 30 * time.Second}).DialContext},
+		hosts:     map[string]int{},
+	}
+	go http.Serve(l, p)
+	return p, nil
+}
+
+// URL returns the URL with which clients use the proxy.
+func (p *egressProxy) URL() string {
+	return "http://" + p.listener.Addr().String()
+}
+
+func (p *egressProxy) record(host string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.hosts[host]++
+}
+
+// ServeHTTP tunnels CONNECT requests, used for HTTPS, and forwards plain HTTP requests.
+func (p *egressProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		p.record(r.Host)
+		p.tunnel(w, r)
+		return
+	}
+	if r.URL.Host == "" {
+		http.Error(w, "not a proxy request", http.StatusBadRequest)
+		return
+	}
+	p.record(r.URL.Host)
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	for _, h := range hopHeaders {
+		out.Header.Del(h)
+	}
+	resp, err := p.transport.RoundTrip(out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	for k, vs := range resp.Header {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+func (p *egressProxy) tunnel(w http.ResponseWriter, r *http.Request) {
+	upstream, err := net.DialTimeout("tcp", r.Host, 30*time.Second)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		upstream.Close()
+		http.Error(w, "tunneling is not supported", http.StatusInternalServerError)
+		return
+	}
+	client, _, err := hj.Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	if _, err := io.WriteString(client, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		client.Close()
+		upstream.Close()
+		return
+	}
+	go func() {
+		io.Copy(upstream, client)
+		upstream.Close()
+	}()
+	go func() {
+		io.Copy(client, upstream)
+		client.Close()
+	}()
+}
+
+// Hosts returns the hosts contacted through the proxy and the number of requests to each.
+func (p *egressProxy) Hosts() map[string]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	hosts := make(map[string]int, len(p.hosts))
+	for h, n := range p.hosts {
+		hosts[h] = n
+	}
+	return hosts
+}
+
+// Close stops the proxy.
+func (p *egressProxy) Close() error {
+	return p.listener.Close()
+}
+
+// useEgressProxy points the commands that the buildpack runs at an egress proxy, if requested with
+// env.EgressLog. Hosts in NO_PROXY, and tools that ignore the proxy env vars, are not recorded.
+func (ctx *Context) useEgressProxy() *Error {
+	enabled, err := env.Bool(env.EgressLog, false)
+	if err != nil {
+		return UserErrorf("%v", err)
+	}
+	if !enabled {
+		return nil
+	}
+	for _, e := range proxyEnvs {
+		if v := os.Getenv(e); v != "" {
+			ctx.Warnf("Not logging egress: %s is already set to %q", e, v)
+			return nil
+		}
+	}
+	p, err := startEgressProxy()
+	if err != nil {
+		ctx.Warnf("Not logging egress: starting the egress proxy: %v", err)
+		return nil
+	}
+	for _, e := range proxyEnvs {
+		if err := os.Setenv(e, p.URL()); err != nil {
+			p.Close()
+			return Errorf(StatusInternal, "setting %s: %v", e, err)
+		}
+	}
+	ctx.Debugf("Logging egress through %s", p.URL())
+	ctx.egress = p
+	ctx.exiter = egressExiter{ctx: ctx, next: ctx.exiter}
+	return nil
+}
+
+// finishEgress stops the egress proxy, logs the hosts contacted during the build and appends them to
+// the builder output egress file.
+func (ctx *Context) finishEgress() {
+	if ctx.egress == nil {
+		return
+	}
+	p := ctx.egress
+	ctx.egress = nil
+	p.Close()
+	for _, e := range proxyEnvs {
+		os.Unsetenv(e)
+	}
+
+	counts := p.Hosts()
+	var hosts []egressHost
+	for h, n := range counts {
+		hosts = append(hosts, egressHost{BuildpackID: ctx.BuildpackID(), Host: h, Requests: n})
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Host < hosts[j].Host })
+	if len(hosts) == 0 {
+		ctx.Logf("Egress: no hosts contacted")
+	}
+	for _, h := range hosts {
+		ctx.Logf("Egress: %s, requests: %d", h.Host, h.Requests)
+	}
+	if err := saveEgress(hosts); err != nil {
+		ctx.Warnf("Failed to save the egress report: %v", err)
+	}
+}
+
+// saveEgress appends hosts to the egress file, if the platform provides a builder output directory.
+func saveEgress(hosts []egressHost) error {
+	dir := os.Getenv(builderOutputEnv)
+	if dir == "" || len(hosts) == 0 {
+		return nil
+	}
+	fname := filepath.Join(dir, egressFilename)
+	f, err := os.OpenFile(fname, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("opening %s: %v", fname, err)
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	for _, h := range hosts {
+		if err := enc.Encode(h); err != nil {
+			return fmt.Errorf("writing %s: %v", fname, err)
+		}
+	}
+	return nil
+}
+
+// egressExiter reports the hosts contacted during the build when the buildpack exits early, so that
+// failed builds are reported too.
+type egressExiter struct {
+	ctx  *Context
+	next Exiter
+}
+
+func (e egressExiter) Exit(exitCode int, be *Error) {
+	e.ctx.finishEgress()
+	e.next.Exit(exitCode, be)
+}
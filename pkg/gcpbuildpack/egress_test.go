@@ -0,0 +1,121 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/buildpacks/libcnb"
+)
+
+func TestEgressProxy(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) })
+	plain := httptest.NewServer(handler)
+	defer plain.Close()
+	secure := httptest.NewTLSServer(handler)
+	defer secure.Close()
+
+	p, err := startEgressProxy()
+	if err != nil {
+		t.Fatalf("startEgressProxy() got error: %v", err)
+	}
+	defer p.Close()
+	proxyURL, err := url.Parse(p.URL())
+	if err != nil {
+		t.Fatalf("parsing proxy URL: %v", err)
+	}
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyURL(proxyURL),
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+
+	for _, u := range []string{plain.URL, plain.URL + "/again", secure.URL} {
+		resp, err := client.Get(u)
+		if err != nil {
+			t.Fatalf("GET %s got error: %v", u, err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("reading response from %s: %v", u, err)
+		}
+		if string(body) != "ok" {
+			t.Errorf("GET %s = %q, want %q", u, body, "ok")
+		}
+	}
+
+	want := map[string]int{
+		strings.TrimPrefix(plain.URL, "http://"):   2,
+		strings.TrimPrefix(secure.URL, "https://"): 1,
+	}
+	if got := p.Hosts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Hosts() = %v, want %v", got, want)
+	}
+}
+
+func TestFinishEgress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "output")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv(builderOutputEnv, dir)
+	defer os.Unsetenv(builderOutputEnv)
+	p, err := startEgressProxy()
+	if err != nil {
+		t.Fatalf("startEgressProxy() got error: %v", err)
+	}
+	p.record("proxy.golang.org:443")
+	p.record("proxy.golang.org:443")
+	p.record("golang.org:443")
+	ctx := NewContext(libcnb.BuildpackInfo{ID: "my-buildpack"})
+	ctx.egress = p
+
+	ctx.finishEgress()
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, egressFilename))
+	if err != nil {
+		t.Fatalf("reading egress report: %v", err)
+	}
+	var got []egressHost
+	dec := json.NewDecoder(strings.NewReader(string(content)))
+	for dec.More() {
+		var h egressHost
+		if err := dec.Decode(&h); err != nil {
+			t.Fatalf("decoding egress report: %v", err)
+		}
+		got = append(got, h)
+	}
+	want := []egressHost{
+		{BuildpackID: "my-buildpack", Host: "golang.org:443", Requests: 1},
+		{BuildpackID: "my-buildpack", Host: "proxy.golang.org:443", Requests: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("egress report = %+v, want %+v", got, want)
+	}
+	if ctx.egress != nil {
+		t.Error("finishEgress() did not stop the proxy")
+	}
+}
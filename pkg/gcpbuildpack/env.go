@@ -16,17 +16,23 @@ package gcpbuildpack
 
 import (
 	"os"
+	"strconv"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	"github.com/buildpacks/libcnb"
 )
 
+const (
+	// functionConcurrencyLabel records env.FunctionConcurrency on function images.
+	functionConcurrencyLabel = "function_concurrency"
+)
+
 // SetFunctionsEnvVars sets launch-time functions environment variables.
 func (ctx *Context) SetFunctionsEnvVars(l *libcnb.Layer) {
 	if target := os.Getenv(env.FunctionTarget); target != "" {
 		l.LaunchEnvironment.Default(env.FunctionTargetLaunch, target)
 	} else {
-		ctx.Exit(1, UserErrorf("required env var %s not found", env.FunctionTarget))
+		ctx.Exit(1, UserErrorMsg(MsgFunctionTargetRequired, env.FunctionTarget))
 	}
 
 	if signature, ok := os.LookupEnv(env.FunctionSignatureType); ok {
@@ -36,4 +42,22 @@ func (ctx *Context) SetFunctionsEnvVars(l *libcnb.Layer) {
 	if source, ok := os.LookupEnv(env.FunctionSource); ok {
 		l.LaunchEnvironment.Default(env.FunctionSourceLaunch, source)
 	}
+
+	prefix, err := env.FunctionPathPrefix()
+	if err != nil {
+		ctx.Exit(1, UserErrorf("%v", err))
+	}
+	if prefix != "" {
+		l.LaunchEnvironment.Default(env.FunctionPathLaunch, prefix)
+	}
+
+	// The label lets platforms check that the deployed concurrency matches the server configuration.
+	concurrency, err := env.FunctionConcurrencyHint()
+	if err != nil {
+		ctx.Exit(1, UserErrorf("%v", err))
+	}
+	if concurrency > 0 {
+		l.LaunchEnvironment.Default(env.FunctionConcurrencyLaunch, strconv.Itoa(concurrency))
+		ctx.AddLabel(functionConcurrencyLabel, strconv.Itoa(concurrency))
+	}
 }
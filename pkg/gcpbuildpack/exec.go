@@ -45,6 +45,8 @@ type execParams struct {
 	userFailure     bool
 	userTiming      bool
 	messageProducer MessageProducer
+	retries         int
+	purgeCache      func() error
 }
 
 type execOption func(o *execParams)
@@ -79,6 +81,12 @@ var WithUserFailureAttribution = func(o *execParams) {
 	o.userFailure = true
 }
 
+// WithTransientRetry retries the command a bounded number of times if it fails with a transient network or registry error.
+// Only use this for commands that are safe to re-run, such as dependency downloads.
+var WithTransientRetry = func(o *execParams) {
+	o.retries = maxTransientRetries
+}
+
 // WithMessageProducer sets a custom MessageProducer to produce the error message.
 func WithMessageProducer(mp MessageProducer) execOption {
 	return func(o *execParams) {
@@ -128,7 +136,16 @@ func (ctx *Context) ExecWithErr(cmd []string, opts ...execOption) (*ExecResult,
 
 	start := time.Now()
 
-	result, err := ctx.configuredExec(params)
+	result, err := ctx.execWithTransientRetry(params)
+	if err != nil && result != nil && params.purgeCache != nil && isCorruptCacheError(result.Combined) {
+		ctx.Warnf("%q failed with an error that indicates a corrupted cache, purging the cache and retrying once", strings.Join(params.cmd, " "))
+		if perr := params.purgeCache(); perr != nil {
+			ctx.Warnf("Failed to purge the cache, not retrying: %v", perr)
+		} else {
+			ctx.stats.cachePurges++
+			result, err = ctx.execWithTransientRetry(params)
+		}
+	}
 
 	if params.userTiming {
 		ctx.stats.user += time.Since(start)
@@ -153,6 +170,17 @@ func (ctx *Context) ExecWithErr(cmd []string, opts ...execOption) (*ExecResult,
 	return result, be
 }
 
+func (ctx *Context) execWithTransientRetry(params execParams) (*ExecResult, error) {
+	result, err := ctx.configuredExec(params)
+	for attempt := 1; err != nil && result != nil && attempt <= params.retries && isTransientError(result.Combined); attempt++ {
+		ctx.Warnf("Retrying %q after transient error (attempt %d of %d)", strings.Join(params.cmd, " "), attempt, params.retries)
+		ctx.stats.retries++
+		time.Sleep(retryBackoff(attempt))
+		result, err = ctx.configuredExec(params)
+	}
+	return result, err
+}
+
 func (ctx *Context) configuredExec(params execParams) (*ExecResult, error) {
 	if len(params.cmd) < 1 {
 		return nil, fmt.Errorf("no command provided")
@@ -182,17 +210,24 @@ func (ctx *Context) configuredExec(params execParams) (*ExecResult, error) {
 	optionalLogf(divider)
 	optionalLogf("Running %q", readableCmd)
 
+	truncated := readableCmd
+	if len(truncated) > 60 {
+		truncated = truncated[:60] + "..."
+	}
 	status := StatusInternal
+	var u usage
 	defer func(start time.Time) {
-		truncated := readableCmd
-		if len(truncated) > 60 {
-			truncated = truncated[:60] + "..."
+		if u.cpu == 0 {
+			optionalLogf("Done %q (%v)", truncated, time.Since(start))
+		} else {
+			optionalLogf("Done %q (%v, %s)", truncated, time.Since(start), u)
 		}
-		optionalLogf("Done %q (%v)", truncated, time.Since(start))
-		ctx.Span(ctx.createSpanName(params.cmd), start, status)
+		ctx.span(ctx.createSpanName(params.cmd), start, status, map[string]interface{}{
+			"/cpu_time_ms":       u.cpu.Milliseconds(),
+			"/peak_memory_bytes": u.peakRSS,
+		})
 	}(time.Now())
 
-	exitCode := 0
 	ecmd := exec.Command(params.cmd[0], params.cmd[1:]...)
 
 	if params.dir != "" {
@@ -203,18 +238,19 @@ func (ctx *Context) configuredExec(params execParams) (*ExecResult, error) {
 		ecmd.Env = append(os.Environ(), params.env...)
 	}
 
+	act := &activity{last: time.Now()}
 	var outb, errb bytes.Buffer
-	combinedb := lockingBuffer{log: log}
+	combinedb := lockingBuffer{log: log, out: ctx.logger.Writer(), activity: act}
 	ecmd.Stdout = io.MultiWriter(&outb, &combinedb)
 	ecmd.Stderr = io.MultiWriter(&errb, &combinedb)
 
-	if err := ecmd.Run(); err != nil {
-		if ee, ok := err.(*exec.ExitError); ok {
-			// The command returned a non-zero result.
-			exitCode = ee.ExitCode()
-		} else {
-			return nil, fmt.Errorf("executing command %q: %v", readableCmd, err)
-		}
+	stopHeartbeat := ctx.startHeartbeat(truncated, time.Now(), act, ctx.heartbeatInterval())
+	exitCode, err := ctx.executor.Run(ecmd)
+	stopHeartbeat()
+	u = processUsage(ecmd.ProcessState)
+	ctx.stats.addUsage(truncated, u)
+	if err != nil {
+		return nil, fmt.Errorf("executing command %q: %v", readableCmd, err)
 	}
 
 	result := &ExecResult{
@@ -236,15 +272,21 @@ type lockingBuffer struct {
 	buf bytes.Buffer
 	sync.Mutex
 
-	// log tells the buffer to also log the output to stderr.
+	// log tells the buffer to also log the output to out.
 	log bool
+	out io.Writer
+	// activity, if set, records when output was last logged.
+	activity *activity
 }
 
 func (lb *lockingBuffer) Write(p []byte) (int, error) {
 	lb.Lock()
 	defer lb.Unlock()
 	if lb.log {
-		os.Stderr.Write(p)
+		lb.out.Write(p)
+		if lb.activity != nil {
+			lb.activity.touch()
+		}
 	}
 	return lb.buf.Write(p)
 }
@@ -15,8 +15,10 @@
 package gcpbuildpack
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
 	"regexp"
 	"strings"
@@ -635,3 +637,72 @@ func (e *fakeExiter) Exit(exitCode int, be *Error) {
 	e.code = exitCode
 	e.err = be
 }
+
+func TestExecWithTransientRetry(t *testing.T) {
+	oldBackoff := retryBackoff
+	retryBackoff = func(int) time.Duration { return 0 }
+	defer func() {
+		retryBackoff = oldBackoff
+	}()
+
+	testCases := []struct {
+		name        string
+		failures    int
+		output      string
+		opts        []execOption
+		wantErr     bool
+		wantRetries int
+	}{
+		{
+			name:        "succeeds after transient failure",
+			failures:    1,
+			output:      "503 Service Unavailable",
+			opts:        []execOption{WithTransientRetry},
+			wantRetries: 1,
+		},
+		{
+			name:        "gives up after max retries",
+			failures:    maxTransientRetries + 1,
+			output:      "503 Service Unavailable",
+			opts:        []execOption{WithTransientRetry},
+			wantErr:     true,
+			wantRetries: maxTransientRetries,
+		},
+		{
+			name:     "does not retry non-transient failure",
+			failures: 1,
+			output:   "404 Not Found",
+			opts:     []execOption{WithTransientRetry},
+			wantErr:  true,
+		},
+		{
+			name:     "does not retry without option",
+			failures: 1,
+			output:   "503 Service Unavailable",
+			wantErr:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cleanUp := simpleContext(t)
+			defer cleanUp()
+
+			dir, err := ioutil.TempDir("", "retry-")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			counter := filepath.Join(dir, "attempts")
+			// Fail with the given output until the command has been run more than tc.failures times.
+			script := fmt.Sprintf(`echo x >> %[1]s; if [ $(wc -l < %[1]s) -le %[2]d ]; then echo %[3]q; exit 1; fi`, counter, tc.failures, tc.output)
+			_, gotErr := ctx.ExecWithErr([]string{"/bin/bash", "-c", script}, tc.opts...)
+
+			if (gotErr != nil) != tc.wantErr {
+				t.Errorf("ExecWithErr() got error: %v, want error: %t", gotErr, tc.wantErr)
+			}
+			if ctx.stats.retries != tc.wantRetries {
+				t.Errorf("ExecWithErr() retried %d times, want %d", ctx.stats.retries, tc.wantRetries)
+			}
+		})
+	}
+}
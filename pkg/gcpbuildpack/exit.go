@@ -39,15 +39,21 @@ func (e defaultExiter) Exit(exitCode int, be *Error) {
 		e.ctx.saveErrorOutput(be)
 	}
 
-	if exitCode != 0 {
+	// Opting out of detection also exits with a non-zero code, but without an error.
+	if exitCode != 0 && be != nil {
 		e.ctx.Tipf(divider)
-		e.ctx.Tipf(`Sorry your project couldn't be built.`)
-		e.ctx.Tipf(`Our documentation explains ways to configure Buildpacks to better recognise your project:`)
+		e.ctx.Tipf(Message(MsgBuildFailed))
+		e.ctx.Tipf(Message(MsgReadDocs))
 		e.ctx.Tipf(` -> https://github.com/GoogleCloudPlatform/buildpacks/blob/main/README.md`)
-		e.ctx.Tipf(`If you think you've found an issue, please report it:`)
+		e.ctx.Tipf(Message(MsgReportIssue))
 		e.ctx.Tipf(` -> https://github.com/GoogleCloudPlatform/buildpacks/issues/new`)
 		e.ctx.Tipf(divider)
 	}
 
+	status := StatusOk
+	if be != nil {
+		status = be.Status
+	}
+	e.ctx.finishPhase(status)
 	os.Exit(exitCode)
 }
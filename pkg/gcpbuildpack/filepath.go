@@ -22,7 +22,7 @@ import (
 
 // Glob returns the names of all files matching pattern or nil if there is no matching file, exiting on any error.
 func (ctx *Context) Glob(pattern string) []string {
-	matches, err := filepath.Glob(pattern)
+	matches, err := ctx.fs.Glob(pattern)
 	if err != nil {
 		ctx.Exit(1, Errorf(StatusInternal, "globbing %s: %v", pattern, err))
 	}
@@ -38,7 +38,7 @@ func (ctx *Context) HasAtLeastOne(pattern string) bool {
 		return true
 	}
 
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+	err := ctx.fs.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			ctx.Exit(1, Errorf(StatusInternal, "walking through %s within %s: %v", path, dir, err))
 		}
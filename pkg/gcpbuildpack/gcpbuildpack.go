@@ -19,7 +19,6 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -62,8 +61,22 @@ type DetectFn func(*Context) error
 type BuildFn func(*Context) error
 
 type stats struct {
-	spans []*spanInfo
-	user  time.Duration
+	spans   []*spanInfo
+	user    time.Duration
+	retries int
+	// cachePurges is the number of commands re-run after purging a corrupted cache.
+	cachePurges int
+	skipped     []string
+	// cpu is the CPU time of the commands run by the buildpack, and peakRSS the highest peak memory
+	// of any of them, which was used by peakRSSCmd.
+	cpu        time.Duration
+	peakRSS    int64
+	peakRSSCmd string
+	// downloads is the number of archives downloaded, throttled the number that waited for a download
+	// slot, and downloadWait the total time they waited.
+	downloads    int
+	throttled    int
+	downloadWait time.Duration
 }
 
 // Context provides contextually aware functions for buildpack authors.
@@ -72,8 +85,17 @@ type Context struct {
 	applicationRoot string
 	buildpackRoot   string
 	debug           bool
+	strict          bool
+	plan            bool
+	workstation     bool
 	stats           stats
 	exiter          Exiter
+	fs              FileSystem
+	executor        Executor
+	logger          *log.Logger
+	platform        Platform
+	progress        *progressStream
+	downloadLimits  *downloadLimits
 
 	// detect items
 	detectContext libcnb.DetectContext
@@ -82,6 +104,14 @@ type Context struct {
 	// build items
 	buildContext libcnb.BuildContext
 	buildResult  libcnb.BuildResult
+	facts        *libcnb.Layer
+	httpCache    *libcnb.Layer
+	backups      *libcnb.Layer
+	encryption   *cacheEncryption
+	egress       *egressProxy
+	decisions    []layerDecision
+	tools        []tool
+	missReasons  map[string]string
 }
 
 // NewContext creates a context.
@@ -91,9 +121,31 @@ func NewContext(info libcnb.BuildpackInfo) *Context {
 		logger.Printf("Failed to parse debug mode: %v", err)
 		os.Exit(1)
 	}
+	strict, err := env.Bool(env.Strict, false)
+	if err != nil {
+		logger.Printf("Failed to parse strict mode: %v", err)
+		os.Exit(1)
+	}
+	plan, err := env.IsPlanPhase()
+	if err != nil {
+		logger.Printf("Failed to parse build phase: %v", err)
+		os.Exit(1)
+	}
+	workstation, err := env.Bool(env.WorkstationMode, false)
+	if err != nil {
+		logger.Printf("Failed to parse workstation mode: %v", err)
+		os.Exit(1)
+	}
 	ctx := &Context{
-		debug: debug,
-		info:  info,
+		debug:       debug,
+		strict:      strict,
+		plan:        plan,
+		info:        info,
+		workstation: workstation,
+		fs:          osFileSystem{},
+		executor:    osExecutor{},
+		logger:      logger,
+		platform:    hostPlatform,
 	}
 	ctx.exiter = defaultExiter{ctx: ctx}
 	return ctx
@@ -106,16 +158,22 @@ func NewContextForTests(info libcnb.BuildpackInfo, root string) *Context {
 	return ctx
 }
 
-func newDetectContext(detectContext libcnb.DetectContext) *Context {
+func newDetectContext(detectContext libcnb.DetectContext, opts ...ContextOption) *Context {
 	ctx := NewContext(detectContext.Buildpack.Info)
+	for _, o := range opts {
+		o(ctx)
+	}
 	ctx.detectContext = detectContext
 	ctx.applicationRoot = ctx.detectContext.Application.Path
 	ctx.buildpackRoot = ctx.detectContext.Buildpack.Path
 	return ctx
 }
 
-func newBuildContext(buildContext libcnb.BuildContext) *Context {
+func newBuildContext(buildContext libcnb.BuildContext, opts ...ContextOption) *Context {
 	ctx := NewContext(buildContext.Buildpack.Info)
+	for _, o := range opts {
+		o(ctx)
+	}
 	ctx.buildContext = buildContext
 	ctx.applicationRoot = ctx.buildContext.Application.Path
 	ctx.buildpackRoot = ctx.buildContext.Buildpack.Path
@@ -148,6 +206,11 @@ func (ctx *Context) BuildpackRoot() string {
 	return ctx.buildpackRoot
 }
 
+// Bindings returns the service bindings provided by the platform.
+func (ctx *Context) Bindings() libcnb.Bindings {
+	return ctx.buildContext.Platform.Bindings
+}
+
 // Debug returns whether debug mode is enabled.
 func (ctx *Context) Debug() bool {
 	return ctx.debug
@@ -168,15 +231,50 @@ func Main(d DetectFn, b BuildFn) {
 
 type gcpdetector struct {
 	detectFn DetectFn
+	opts     []ContextOption
 }
 
 func (gcpd gcpdetector) Detect(ldctx libcnb.DetectContext) (libcnb.DetectResult, error) {
-	ctx := newDetectContext(ldctx)
+	ctx := newDetectContext(ldctx, gcpd.opts...)
 	status := StatusInternal
 	defer func(now time.Time) {
 		ctx.Span(fmt.Sprintf("Buildpack Detect %s", ctx.info.ID), now, status)
 	}(time.Now())
 
+	if err := ctx.useSourceSubdir(); err != nil {
+		status = err.Status
+		return ctx.detectResult, err
+	}
+	o, err := ctx.loadOverrides()
+	if err != nil {
+		status = err.Status
+		return ctx.detectResult, err
+	}
+	if err := ctx.checkCompatibility(); err != nil {
+		status = err.Status
+		return ctx.detectResult, err
+	}
+	if pass, ok := o.detectOverride(ctx.info.ID); ok {
+		if pass {
+			ctx.Logf("Enabled in %s", OverridesFile)
+		} else {
+			ctx.Logf("Disabled in %s", OverridesFile)
+		}
+		ctx.detectResult.Pass = pass
+		status = StatusOk
+		return ctx.detectResult, nil
+	}
+
+	memo := ctx.newDetectMemo()
+	if r, ok := memo.read(ctx); ok {
+		ctx.Logf("Reusing the detection result of an earlier build with the same application files")
+		status = StatusOk
+		return r, nil
+	}
+	if memo != nil {
+		ctx.exiter = detectMemoExiter{ctx: ctx, memo: memo, next: ctx.exiter}
+	}
+
 	if err := gcpd.detectFn(ctx); err != nil {
 		msg := fmt.Sprintf("Failed to run /bin/detect: %v", err)
 		var be *Error
@@ -187,6 +285,7 @@ func (gcpd gcpdetector) Detect(ldctx libcnb.DetectContext) (libcnb.DetectResult,
 		return ctx.detectResult, Errorf(status, msg)
 	}
 	ctx.detectResult.Pass = true
+	memo.write(ctx, ctx.detectResult)
 
 	status = StatusOk
 	return ctx.detectResult, nil
@@ -200,18 +299,52 @@ func detect(detectFn DetectFn, opts ...libcnb.Option) {
 
 type gcpbuilder struct {
 	buildFn BuildFn
+	opts    []ContextOption
 }
 
 func (gcpb gcpbuilder) Build(lbctx libcnb.BuildContext) (libcnb.BuildResult, error) {
 	start := time.Now()
-	ctx := newBuildContext(lbctx)
+	ctx := newBuildContext(lbctx, gcpb.opts...)
 	ctx.Logf("=== %s (%s@%s) ===", ctx.BuildpackName(), ctx.BuildpackID(), ctx.BuildpackVersion())
+	ctx.openProgress()
+	ctx.startPhase("build")
 
 	status := StatusInternal
 	defer func(now time.Time) {
 		ctx.Span(fmt.Sprintf("Buildpack Build %s", ctx.BuildpackID()), now, status)
+		ctx.finishPhase(status)
 	}(time.Now())
 
+	if ctx.plan {
+		ctx.Logf("Planning the build of %s; layers are not rebuilt", ctx.BuildpackID())
+		ctx.exiter = planExiter{ctx: ctx, next: ctx.exiter}
+	}
+	if err := ctx.useCacheEncryption(); err != nil {
+		status = err.Status
+		ctx.Exit(1, err)
+	}
+	if err := ctx.useSourceSubdir(); err != nil {
+		status = err.Status
+		ctx.Exit(1, err)
+	}
+	if _, err := ctx.loadOverrides(); err != nil {
+		status = err.Status
+		ctx.Exit(1, err)
+	}
+	ctx.useWarmCache()
+	if err := ctx.useEgressProxy(); err != nil {
+		status = err.Status
+		ctx.Exit(1, err)
+	}
+
+	snapshot := ctx.takeSnapshot()
+	ctx.reportSnapshotDiff(snapshot)
+	appFiles, err := ctx.appFiles()
+	if err != nil {
+		ctx.Debugf("Not recording provenance, listing application files failed: %v", err)
+		appFiles = nil
+	}
+
 	if err := gcpb.buildFn(ctx); err != nil {
 		msg := fmt.Sprintf("Failed to run /bin/build: %v", err)
 		var be *Error
@@ -221,9 +354,52 @@ func (gcpb gcpbuilder) Build(lbctx libcnb.BuildContext) (libcnb.BuildResult, err
 		}
 		ctx.Exit(1, Errorf(status, msg))
 	}
+	if err := ctx.appendProcessArgs(); err != nil {
+		status = err.Status
+		ctx.Exit(1, err)
+	}
+	ctx.startProcessesInSourceSubdir()
+	ctx.finishEgress()
+	if ctx.plan {
+		ctx.finishPlan("")
+		status = StatusOk
+		return libcnb.NewBuildResult(), nil
+	}
 
+	if err := ctx.runStep("provenance", Optional, func() error { return ctx.recordProvenance(appFiles) }); err != nil {
+		status = err.Status
+		ctx.Exit(1, err)
+	}
+	if err := ctx.runStep("software bill of materials", Optional, ctx.writeSBOMs); err != nil {
+		status = err.Status
+		ctx.Exit(1, err)
+	}
+	if err := ctx.normalizePermissions(); err != nil {
+		status = err.Status
+		ctx.Exit(1, err)
+	}
+	if err := ctx.runStep("build snapshot", Optional, func() error { return ctx.saveSnapshot(snapshot) }); err != nil {
+		status = err.Status
+		ctx.Exit(1, err)
+	}
+	if err := ctx.runStep("cache encryption", Critical, ctx.encryptCacheLayers); err != nil {
+		status = err.Status
+		ctx.Exit(1, err)
+	}
+	if ctx.stats.retries > 0 {
+		ctx.Logf("Retried commands %d time(s) due to transient errors", ctx.stats.retries)
+	}
+	if ctx.stats.cachePurges > 0 {
+		ctx.Logf("Retried commands %d time(s) after purging corrupted caches", ctx.stats.cachePurges)
+	}
+	ctx.reportUsage()
+	ctx.reportDownloadThrottling()
+	if err := ctx.runStep("build statistics", Optional, func() error { return ctx.saveSuccessOutput(time.Since(start)) }); err != nil {
+		status = err.Status
+		ctx.Exit(1, err)
+	}
+	ctx.reportSkippedSteps()
 	status = StatusOk
-	ctx.saveSuccessOutput(time.Since(start))
 	return ctx.buildResult, nil
 }
 
@@ -240,18 +416,18 @@ func (ctx *Context) Exit(exitCode int, be *Error) {
 // OptOut is used during the detect phase to opt out of the build process.
 func (ctx *Context) OptOut(format string, args ...interface{}) {
 	ctx.Logf(format, args...)
-	os.Exit(failStatusCode)
+	ctx.exiter.Exit(failStatusCode, nil)
 }
 
 // OptIn is used during the detect phase to opt in to the build process.
 func (ctx *Context) OptIn(format string, args ...interface{}) {
 	ctx.Logf(format, args...)
-	os.Exit(passStatusCode)
+	ctx.exiter.Exit(passStatusCode, nil)
 }
 
 // Logf emits a structured logging line.
 func (ctx *Context) Logf(format string, args ...interface{}) {
-	logger.Printf(format, args...)
+	ctx.logger.Printf(format, args...)
 }
 
 // Debugf emits a structured logging line if the debug flag is set.
@@ -265,6 +441,7 @@ func (ctx *Context) Debugf(format string, args ...interface{}) {
 // Warnf emits a structured logging line for warnings.
 func (ctx *Context) Warnf(format string, args ...interface{}) {
 	ctx.Logf("WARNING: "+format, args...)
+	ctx.emitProgress(progressEvent{Type: progressWarning, Message: fmt.Sprintf(format, args...)})
 }
 
 // Tipf emits a structured logging line for usage tips.
@@ -278,21 +455,32 @@ func (ctx *Context) Tipf(format string, args ...interface{}) {
 // CacheHit records a cache hit debug message. This is used in acceptance test validation.
 func (ctx *Context) CacheHit(tag string) {
 	ctx.Debugf("%s %q", cacheHitMessage, tag)
+	ctx.planCacheHit(tag)
 }
 
 // CacheMiss records a cache miss debug message. This is used in acceptance test validation.
+// In the plan phase, it ends the build, which would go on to rebuild the layer.
 func (ctx *Context) CacheMiss(tag string) {
 	ctx.Debugf("%s %q", cacheMissMessage, tag)
+	ctx.planCacheMiss(tag)
 }
 
 // Span emits a structured Stackdriver span.
 func (ctx *Context) Span(label string, start time.Time, status Status) {
+	ctx.span(label, start, status, nil)
+}
+
+// span emits a structured Stackdriver span with extra attributes.
+func (ctx *Context) span(label string, start time.Time, status Status, extra map[string]interface{}) {
 	now := time.Now()
 	attributes := map[string]interface{}{
 		"/buildpack_id":      ctx.BuildpackID(),
 		"/buildpack_name":    ctx.BuildpackName(),
 		"/buildpack_version": ctx.BuildpackVersion(),
 	}
+	for k, v := range extra {
+		attributes[k] = v
+	}
 	si, err := newSpanInfo(label, start, now, attributes, status)
 	if err != nil {
 		ctx.Warnf("Invalid span dropped: %v", err)
@@ -329,17 +517,22 @@ func (ctx *Context) AddBuildpackPlanEntry(entry libcnb.BuildpackPlanEntry) {
 
 // AddWebProcess adds the given command as the web start process, overwriting any previous web start process.
 func (ctx *Context) AddWebProcess(cmd []string) {
+	ctx.AddProcess("web", cmd)
+}
+
+// AddProcess adds the given command as a process of the given type, overwriting any previous process of that type.
+func (ctx *Context) AddProcess(processType string, cmd []string) {
 	current := ctx.buildResult.Processes
 	ctx.buildResult.Processes = []libcnb.Process{}
 	for _, p := range current {
-		if p.Type == "web" {
-			ctx.Debugf("Overwriting existing web process %q.", p.Command)
+		if p.Type == processType {
+			ctx.Debugf("Overwriting existing %s process %q.", processType, p.Command)
 			continue // Do not add this item back to the ctx.processes; we are overwriting it.
 		}
 		ctx.buildResult.Processes = append(ctx.buildResult.Processes, p)
 	}
 	p := libcnb.Process{
-		Type:    "web",
+		Type:    processType,
 		Command: cmd[0],
 		Direct:  true, // Uses Exec (no shell).
 	}
@@ -351,10 +544,11 @@ func (ctx *Context) AddWebProcess(cmd []string) {
 
 // HTTPStatus returns the status code for a url.
 func (ctx *Context) HTTPStatus(url string) int {
-	res, err := http.Head(url)
+	res, err := httpClient.Head(url)
 	if err != nil {
 		ctx.Exit(1, UserErrorf("making a request to %s", url))
 	}
+	res.Body.Close()
 	return res.StatusCode
 }
 
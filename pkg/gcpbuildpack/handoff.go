@@ -0,0 +1,83 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// handoffEnvPrefix prefixes the build env vars through which published files are located.
+	handoffEnvPrefix = "GOOGLE_INTERNAL_HANDOFF_"
+	// handoffDir is the directory within a layer in which buildpacks write the files they publish.
+	handoffDir = "handoff"
+	// handoffMetadataPrefix prefixes the layer metadata keys recording the files a layer publishes.
+	handoffMetadataPrefix = "handoff."
+)
+
+// Handoff identifies a file that one buildpack generates and later buildpacks of the same build may
+// read or amend, such as the main package synthesized for a function. Buildpacks locate handoff
+// files through Handoff rather than assuming where an earlier buildpack wrote them.
+type Handoff string
+
+const (
+	// HandoffGoMain is the main.go generated for a Go function.
+	HandoffGoMain Handoff = "go-main"
+	// HandoffGoMainTest is the self-test generated alongside the main.go of a Go function.
+	HandoffGoMainTest Handoff = "go-main-test"
+	// HandoffNginxConfig is the nginx configuration generated for a web server.
+	HandoffNginxConfig Handoff = "nginx-config"
+)
+
+// envVar returns the build env var that holds the path of the file published as h.
+func (h Handoff) envVar() string {
+	return handoffEnvPrefix + strings.ToUpper(strings.ReplaceAll(string(h), "-", "_"))
+}
+
+// HandoffPath returns the well-known path in layer l of a file named name published as h, for files
+// that do not have to be in the application source. The directory is not created.
+func HandoffPath(l *libcnb.Layer, h Handoff, name string) string {
+	return filepath.Join(l.Path, handoffDir, string(h), name)
+}
+
+// PublishHandoff publishes the file at path as h for the later buildpacks of the build. It makes l a
+// build layer, whose environment carries the path, and records the publication in the layer metadata
+// and the buildpack plan.
+func (ctx *Context) PublishHandoff(l *libcnb.Layer, h Handoff, path string) {
+	l.Build = true
+	l.BuildEnvironment.Override(h.envVar(), path)
+	if l.Metadata == nil {
+		l.Metadata = make(map[string]interface{})
+	}
+	l.Metadata[handoffMetadataPrefix+string(h)] = ctx.BuildpackID()
+	ctx.AddBuildpackPlanEntry(libcnb.BuildpackPlanEntry{
+		Name:     handoffMetadataPrefix + string(h),
+		Metadata: map[string]interface{}{"path": path},
+	})
+	ctx.Debugf("Published %s as %s", path, h)
+}
+
+// Handoff returns the path of the file an earlier buildpack published as h, and whether it exists.
+func (ctx *Context) Handoff(h Handoff) (string, bool) {
+	path := os.Getenv(h.envVar())
+	if path == "" {
+		return "", false
+	}
+	return path, ctx.FileExists(path)
+}
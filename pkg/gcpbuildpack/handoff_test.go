@@ -0,0 +1,112 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/buildpacks/libcnb"
+)
+
+func TestHandoffEnvVar(t *testing.T) {
+	if got, want := HandoffGoMain.envVar(), "GOOGLE_INTERNAL_HANDOFF_GO_MAIN"; got != want {
+		t.Errorf("envVar() = %q, want %q", got, want)
+	}
+}
+
+func TestPublishHandoff(t *testing.T) {
+	layers, err := ioutil.TempDir("", "layers")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(layers)
+
+	ctx := newBuildContext(libcnb.BuildContext{
+		Buildpack: libcnb.Buildpack{Info: libcnb.BuildpackInfo{ID: "my-id"}},
+		Layers:    libcnb.Layers{Path: layers},
+	})
+	l := ctx.Layer("gen")
+	path := HandoffPath(l, HandoffNginxConfig, "nginx.conf")
+	if want := filepath.Join(l.Path, "handoff", "nginx-config", "nginx.conf"); path != want {
+		t.Errorf("HandoffPath() = %q, want %q", path, want)
+	}
+
+	ctx.PublishHandoff(l, HandoffNginxConfig, path)
+
+	if !l.Build {
+		t.Error("PublishHandoff() did not make the layer a build layer")
+	}
+	if got := l.BuildEnvironment["GOOGLE_INTERNAL_HANDOFF_NGINX_CONFIG.override"]; got != path {
+		t.Errorf("build env var = %q, want %q", got, path)
+	}
+	if got := l.Metadata["handoff.nginx-config"]; got != "my-id" {
+		t.Errorf("layer metadata = %v, want %q", got, "my-id")
+	}
+	if ctx.buildResult.Plan == nil || len(ctx.buildResult.Plan.Entries) != 1 || ctx.buildResult.Plan.Entries[0].Name != "handoff.nginx-config" {
+		t.Errorf("buildpack plan = %v, want a handoff.nginx-config entry", ctx.buildResult.Plan)
+	}
+}
+
+func TestHandoff(t *testing.T) {
+	dir, err := ioutil.TempDir("", "handoff")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	existing := filepath.Join(dir, "main.go")
+	if err := ioutil.WriteFile(existing, nil, 0644); err != nil {
+		t.Fatalf("writing file: %v", err)
+	}
+
+	testCases := []struct {
+		name   string
+		value  string
+		want   string
+		wantOK bool
+	}{
+		{
+			name: "not published",
+		},
+		{
+			name:   "published",
+			value:  existing,
+			want:   existing,
+			wantOK: true,
+		},
+		{
+			name:  "missing file",
+			value: filepath.Join(dir, "missing.go"),
+			want:  filepath.Join(dir, "missing.go"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer os.Unsetenv("GOOGLE_INTERNAL_HANDOFF_GO_MAIN")
+			if tc.value != "" {
+				os.Setenv("GOOGLE_INTERNAL_HANDOFF_GO_MAIN", tc.value)
+			}
+			ctx := NewContext(libcnb.BuildpackInfo{})
+
+			got, ok := ctx.Handoff(HandoffGoMain)
+
+			if got != tc.want || ok != tc.wantOK {
+				t.Errorf("Handoff() = %q, %t, want %q, %t", got, ok, tc.want, tc.wantOK)
+			}
+		})
+	}
+}
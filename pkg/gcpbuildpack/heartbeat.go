@@ -0,0 +1,93 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+var (
+	// defaultHeartbeatInterval is used when env.ExecHeartbeat is not set.
+	defaultHeartbeatInterval = time.Minute
+)
+
+// heartbeatInterval returns how long a command may run without output before a heartbeat is
+// logged, or 0 if heartbeats are disabled.
+func (ctx *Context) heartbeatInterval() time.Duration {
+	v, ok := os.LookupEnv(env.ExecHeartbeat)
+	if !ok {
+		return defaultHeartbeatInterval
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		ctx.Warnf("Ignoring invalid %s=%q, using %v", env.ExecHeartbeat, v, defaultHeartbeatInterval)
+		return defaultHeartbeatInterval
+	}
+	return d
+}
+
+// activity records when a command last produced visible output.
+type activity struct {
+	sync.Mutex
+	last time.Time
+}
+
+func (a *activity) touch() {
+	a.Lock()
+	defer a.Unlock()
+	a.last = time.Now()
+}
+
+func (a *activity) since() time.Duration {
+	a.Lock()
+	defer a.Unlock()
+	return time.Since(a.last)
+}
+
+// startHeartbeat logs a "still running" line whenever the command has been silent for interval,
+// so that platforms with no-output timeouts do not kill long-running commands. Output logged by
+// the command must be recorded in act. The returned function stops the heartbeat.
+func (ctx *Context) startHeartbeat(cmd string, start time.Time, act *activity, interval time.Duration) func() {
+	if interval <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if act.since() >= interval {
+					ctx.Logf("Still running: %s (%v)", cmd, time.Since(start).Round(time.Second))
+					act.touch()
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+func TestExecHeartbeat(t *testing.T) {
+	testCases := []struct {
+		name     string
+		cmd      []string
+		interval string
+		want     bool
+	}{
+		{
+			name:     "silent command",
+			cmd:      []string{"sleep", "0.5"},
+			interval: "100ms",
+			want:     true,
+		},
+		{
+			name:     "fast command",
+			cmd:      []string{"true"},
+			interval: "100ms",
+		},
+		{
+			name:     "disabled",
+			cmd:      []string{"sleep", "0.5"},
+			interval: "0",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cleanUp := simpleContext(t)
+			defer cleanUp()
+			var buf bytes.Buffer
+			logger.SetOutput(&buf)
+			defer logger.SetOutput(os.Stderr)
+			os.Setenv(env.ExecHeartbeat, tc.interval)
+			defer os.Unsetenv(env.ExecHeartbeat)
+
+			if _, err := ctx.ExecWithErr(tc.cmd); err != nil {
+				t.Fatalf("ExecWithErr(%v) got error: %v", tc.cmd, err)
+			}
+
+			want := "Still running: " + strings.Join(tc.cmd, " ")
+			if got := strings.Contains(buf.String(), want); got != tc.want {
+				t.Errorf("output contains %q = %t, want %t; output:\n%s", want, got, tc.want, buf.String())
+			}
+		})
+	}
+}
+
+func TestHeartbeatInterval(t *testing.T) {
+	testCases := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "30s", want: 30 * time.Second},
+		{value: "0", want: 0},
+		{value: "soon", want: defaultHeartbeatInterval},
+		{value: "-1s", want: defaultHeartbeatInterval},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			ctx, cleanUp := simpleContext(t)
+			defer cleanUp()
+			os.Setenv(env.ExecHeartbeat, tc.value)
+			defer os.Unsetenv(env.ExecHeartbeat)
+
+			if got := ctx.heartbeatInterval(); got != tc.want {
+				t.Errorf("heartbeatInterval() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// httpCacheLayer is the cache layer holding the responses of FetchMetadata.
+	httpCacheLayer = "http-cache"
+)
+
+var (
+	// httpClient is shared by all requests the buildpack makes, so that connections are reused.
+	httpClient = &http.Client{Timeout: 30 * time.Second}
+)
+
+// cachedResponse is a response of FetchMetadata stored in the cache layer.
+type cachedResponse struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	Body         []byte `json:"body"`
+}
+
+// FetchMetadata returns the body of a small document at url, such as a version manifest. Responses
+// are cached in a layer and revalidated with their ETag or Last-Modified date, so that the document
+// is only downloaded again when it changed, and the cached response is used when url cannot be
+// reached or fails with a server error.
+func (ctx *Context) FetchMetadata(url string) ([]byte, error) {
+	path := ctx.httpCachePath(url)
+	cached := ctx.readCachedResponse(path)
+	if cached != nil && ctx.workstation {
+		ctx.Debugf("Using cached response for %s without revalidation in workstation mode", url)
+		return cached.Body, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request for %s: %v", url, err)
+	}
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		if cached != nil {
+			ctx.Warnf("Using cached response for %s: %v", url, err)
+			return cached.Body, nil
+		}
+		return nil, fmt.Errorf("fetching %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		ctx.Debugf("Using cached response for %s, which has not changed", url)
+		return cached.Body, nil
+	case resp.StatusCode >= http.StatusInternalServerError && cached != nil:
+		ctx.Warnf("Using cached response for %s: status %d", url, resp.StatusCode)
+		return cached.Body, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("fetching %s: status %d", url, resp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response from %s: %v", url, err)
+	}
+	ctx.writeCachedResponse(path, cachedResponse{
+		URL:          url,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Body:         body,
+	})
+	return body, nil
+}
+
+// httpCachePath returns the path of the cached response for url, or "" if there are no layers.
+func (ctx *Context) httpCachePath(url string) string {
+	if ctx.buildContext.Layers.Path == "" {
+		return ""
+	}
+	if ctx.httpCache == nil {
+		ctx.httpCache = ctx.Layer(httpCacheLayer, CacheLayer)
+	}
+	return filepath.Join(ctx.httpCache.Path, fmt.Sprintf("%x.json", sha256.Sum256([]byte(url))))
+}
+
+func (ctx *Context) readCachedResponse(path string) *cachedResponse {
+	if path == "" {
+		return nil
+	}
+	content, err := ctx.fs.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var cr cachedResponse
+	if err := json.Unmarshal(content, &cr); err != nil {
+		ctx.Debugf("Ignoring cached response %s: %v", path, err)
+		return nil
+	}
+	return &cr
+}
+
+// writeCachedResponse stores cr at path. Failing to cache a response does not fail the build.
+func (ctx *Context) writeCachedResponse(path string, cr cachedResponse) {
+	if path == "" {
+		return
+	}
+	content, err := json.Marshal(cr)
+	if err != nil {
+		ctx.Warnf("Not caching response from %s: %v", cr.URL, err)
+		return
+	}
+	if err := ctx.fs.WriteFile(path, content, 0644); err != nil {
+		ctx.Warnf("Not caching response from %s: %v", cr.URL, err)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/buildpacks/libcnb"
+)
+
+func TestFetchMetadata(t *testing.T) {
+	layers, err := ioutil.TempDir("", "layers")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(layers)
+	body := "1.15.6"
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := `"` + body + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads++
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(body))
+	}))
+	url := server.URL + "/latest.version"
+
+	// fetch uses a new context for each build, restoring the cache layer from the previous build.
+	fetch := func() (string, error) {
+		ctx := newBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layers}})
+		got, err := ctx.FetchMetadata(url)
+		return string(got), err
+	}
+
+	testCases := []struct {
+		name          string
+		setup         func()
+		want          string
+		wantDownloads int
+	}{
+		{name: "first build downloads", want: "1.15.6", wantDownloads: 1},
+		{name: "unchanged document is revalidated", want: "1.15.6", wantDownloads: 1},
+		{name: "changed document is downloaded", setup: func() { body = "1.15.7" }, want: "1.15.7", wantDownloads: 2},
+		{name: "offline uses cached response", setup: server.Close, want: "1.15.7", wantDownloads: 2},
+	}
+	for _, tc := range testCases {
+		if tc.setup != nil {
+			tc.setup()
+		}
+		got, err := fetch()
+		if err != nil {
+			t.Fatalf("%s: FetchMetadata() got error: %v", tc.name, err)
+		}
+		if got != tc.want {
+			t.Errorf("%s: FetchMetadata() = %q, want %q", tc.name, got, tc.want)
+		}
+		if downloads != tc.wantDownloads {
+			t.Errorf("%s: downloads = %d, want %d", tc.name, downloads, tc.wantDownloads)
+		}
+	}
+}
+
+func TestFetchMetadataFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	layers, err := ioutil.TempDir("", "layers")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(layers)
+	ctx := newBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layers}})
+
+	if _, err := ctx.FetchMetadata(server.URL); err == nil {
+		t.Error("FetchMetadata() got nil error, want error")
+	}
+}
@@ -15,14 +15,13 @@
 package gcpbuildpack
 
 import (
-	"io/ioutil"
 	"os"
 	"path/filepath"
 )
 
 // TempDir creates a temp directory, returning the directory name. exiting on any error. It is the caller's responsibility to remove the created directory.
 func (ctx *Context) TempDir(dir, prefix string) string {
-	tmp, err := ioutil.TempDir(dir, prefix)
+	tmp, err := ctx.fs.TempDir(dir, prefix)
 	if err != nil {
 		ctx.Exit(1, Errorf(StatusInternal, "creating temp dir: %v", err))
 	}
@@ -31,14 +30,14 @@ func (ctx *Context) TempDir(dir, prefix string) string {
 
 // WriteFile invokes ioutil.WriteFile, exiting on any error.
 func (ctx *Context) WriteFile(filename string, data []byte, perm os.FileMode) {
-	if err := ioutil.WriteFile(filename, data, perm); err != nil {
+	if err := ctx.fs.WriteFile(filename, data, perm); err != nil {
 		ctx.Exit(1, Errorf(StatusInternal, "writing file %q: %v", filename, err))
 	}
 }
 
 // ReadFile invokes ioutil.ReadFile, exiting on any error.
 func (ctx *Context) ReadFile(filename string) []byte {
-	data, err := ioutil.ReadFile(filename)
+	data, err := ctx.fs.ReadFile(filename)
 	if err != nil {
 		ctx.Exit(1, Errorf(StatusInternal, "reading file %q: %v", filename, err))
 	}
@@ -48,7 +47,7 @@ func (ctx *Context) ReadFile(filename string) []byte {
 // ReadDir invokes ioutil.ReadDir, exiting on any error.
 func (ctx *Context) ReadDir(elem ...string) []os.FileInfo {
 	n := filepath.Join(elem...)
-	files, err := ioutil.ReadDir(n)
+	files, err := ctx.fs.ReadDir(n)
 	if err != nil {
 		ctx.Exit(1, Errorf(StatusInternal, "reading directory %q: %v", n, err))
 	}
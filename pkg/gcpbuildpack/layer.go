@@ -16,6 +16,9 @@ package gcpbuildpack
 
 import (
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	"github.com/buildpacks/libcnb"
@@ -23,6 +26,12 @@ import (
 
 const (
 	layerMode os.FileMode = 0755
+	execDMode os.FileMode = 0755
+
+	// execDDir is the directory within a layer whose executables are run by the launcher before the process starts.
+	execDDir = "exec.d"
+	// execDMinAPI is the oldest buildpack API for which the launcher runs exec.d executables.
+	execDMinAPI = "0.5"
 )
 
 type layerOption func(ctx *Context, l *libcnb.Layer)
@@ -61,6 +70,7 @@ func (ctx *Context) Layer(name string, opts ...layerOption) *libcnb.Layer {
 	if l.Metadata == nil {
 		l.Metadata = make(map[string]interface{})
 	}
+	ctx.decryptLayer(&l)
 	ctx.buildResult.Layers = append(ctx.buildResult.Layers, layerContributor{&l})
 	return &l
 }
@@ -102,3 +112,32 @@ func (ctx *Context) GetMetadata(l *libcnb.Layer, key string) string {
 	}
 	return s
 }
+
+// AddExecD copies the executable at src into the exec.d directory of the layer.
+// The launcher runs exec.d executables, in lexical order, before starting the application process;
+// a non-zero exit status prevents the process from starting. The launcher ignores exec.d executables
+// of buildpacks that declare an API older than 0.5, so an error is returned for them instead.
+func (ctx *Context) AddExecD(l *libcnb.Layer, src string) error {
+	if api := ctx.buildContext.Buildpack.API; api != "" && !apiAtLeast(api, execDMinAPI) {
+		return InternalErrorf("%s declares buildpack API %s, but exec.d executables require API %s or later", ctx.BuildpackID(), api, execDMinAPI)
+	}
+	dir := filepath.Join(l.Path, execDDir)
+	ctx.MkdirAll(dir, layerMode)
+	ctx.WriteFile(filepath.Join(dir, filepath.Base(src)), ctx.ReadFile(src), execDMode)
+	return nil
+}
+
+// apiAtLeast returns true if the buildpack API version api, such as 0.5, is at least min.
+func apiAtLeast(api, min string) bool {
+	a, b := apiVersion(api), apiVersion(min)
+	return a[0] > b[0] || a[0] == b[0] && a[1] >= b[1]
+}
+
+// apiVersion returns the major and minor version of a buildpack API version, with unparsable parts as 0.
+func apiVersion(api string) [2]int {
+	var v [2]int
+	for i, part := range strings.SplitN(api, ".", 2) {
+		v[i], _ = strconv.Atoi(part)
+	}
+	return v
+}
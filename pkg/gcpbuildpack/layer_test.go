@@ -0,0 +1,78 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/buildpacks/libcnb"
+)
+
+func TestAddExecD(t *testing.T) {
+	testCases := []struct {
+		api     string
+		wantErr bool
+	}{
+		{api: ""},
+		{api: "0.5"},
+		{api: "0.6"},
+		{api: "1.0"},
+		{api: "0.2", wantErr: true},
+		{api: "0.4", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.api, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "execd")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			src := filepath.Join(dir, "helper")
+			if err := ioutil.WriteFile(src, []byte("#!/bin/sh\n"), 0644); err != nil {
+				t.Fatalf("writing %s: %v", src, err)
+			}
+			exiter := &fakeExiter{}
+			ctx := newBuildContext(libcnb.BuildContext{Buildpack: libcnb.Buildpack{Info: libcnb.BuildpackInfo{ID: "my-id"}, API: tc.api}}, WithExiter(exiter), WithLogger(log.New(ioutil.Discard, "", 0)))
+			l := &libcnb.Layer{Path: filepath.Join(dir, "layer")}
+
+			err = ctx.AddExecD(l, src)
+
+			if exiter.called {
+				t.Fatalf("AddExecD() exited: %v", exiter.err)
+			}
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("AddExecD() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				// A buildpack that cannot use exec.d must not leave an executable the launcher ignores.
+				if _, err := os.Stat(filepath.Join(l.Path, "exec.d")); !os.IsNotExist(err) {
+					t.Errorf("exec.d directory created for API %s", tc.api)
+				}
+				return
+			}
+			fi, err := os.Stat(filepath.Join(l.Path, "exec.d", "helper"))
+			if err != nil {
+				t.Fatalf("stat exec.d executable: %v", err)
+			}
+			if fi.Mode().Perm() != execDMode {
+				t.Errorf("exec.d executable mode = %v, want %v", fi.Mode().Perm(), execDMode)
+			}
+		})
+	}
+}
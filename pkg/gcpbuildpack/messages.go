@@ -0,0 +1,153 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+// MessageID identifies a user-facing message in the message catalog.
+type MessageID string
+
+// Messages in the catalog. Each message must have an English format, which is used for locales
+// without a translation and to generate error IDs, so that IDs do not depend on the locale.
+const (
+	// MsgFunctionTargetRequired is reported when a functions buildpack runs without env.FunctionTarget.
+	MsgFunctionTargetRequired MessageID = "function-target-required"
+	// MsgSourceSubdirNotRelative is reported when env.SourceSubdir leaves the source.
+	MsgSourceSubdirNotRelative MessageID = "source-subdir-not-relative"
+	// MsgSourceSubdirNotFound is reported when env.SourceSubdir does not exist.
+	MsgSourceSubdirNotFound MessageID = "source-subdir-not-found"
+	// MsgSourceSubdirNotDir is reported when env.SourceSubdir is not a directory.
+	MsgSourceSubdirNotDir MessageID = "source-subdir-not-dir"
+	// MsgGoModRequired is reported when a Go function has no go.mod on Go versions that require one.
+	MsgGoModRequired MessageID = "go-mod-required"
+	// MsgGoModNotWritable is reported when a Go function's go.mod cannot be updated.
+	MsgGoModNotWritable MessageID = "go-mod-not-writable"
+	// MsgBuildFailed is the tip logged when the build fails.
+	MsgBuildFailed MessageID = "build-failed"
+	// MsgReadDocs is the tip that points to the documentation when the build fails.
+	MsgReadDocs MessageID = "read-docs"
+	// MsgReportIssue is the tip that points to the issue tracker when the build fails.
+	MsgReportIssue MessageID = "report-issue"
+)
+
+const defaultLocale = "en"
+
+// catalog maps message IDs to their formats by lowercase BCP 47 language tag. Translations must
+// take the same arguments, in the same order, as the English format.
+var catalog = map[MessageID]map[string]string{
+	MsgFunctionTargetRequired: {
+		"en": "required env var %s not found",
+		"es": "no se encontró la variable de entorno obligatoria %s",
+		"ja": "必須の環境変数 %s が見つかりません",
+	},
+	MsgSourceSubdirNotRelative: {
+		"en": "%s=%q must be a relative path within the source",
+		"es": "%s=%q debe ser una ruta relativa dentro del código fuente",
+		"ja": "%s=%q はソース内の相対パスである必要があります",
+	},
+	MsgSourceSubdirNotFound: {
+		"en": "%s=%q does not exist in the source",
+		"es": "%s=%q no existe en el código fuente",
+		"ja": "%s=%q はソース内に存在しません",
+	},
+	MsgSourceSubdirNotDir: {
+		"en": "%s=%q is not a directory",
+		"es": "%s=%q no es un directorio",
+		"ja": "%s=%q はディレクトリではありません",
+	},
+	MsgGoModRequired: {
+		"en": "function build requires go.mod file",
+		"es": "la compilación de la función requiere un archivo go.mod",
+		"ja": "関数のビルドには go.mod ファイルが必要です",
+	},
+	MsgGoModNotWritable: {
+		"en": "go.mod exists but is not writable",
+		"es": "go.mod existe pero no se puede escribir",
+		"ja": "go.mod は存在しますが書き込みできません",
+	},
+	MsgBuildFailed: {
+		"en": "Sorry your project couldn't be built.",
+		"es": "Lo sentimos, no se pudo compilar tu proyecto.",
+		"ja": "申し訳ありません。プロジェクトをビルドできませんでした。",
+	},
+	MsgReadDocs: {
+		"en": "Our documentation explains ways to configure Buildpacks to better recognise your project:",
+		"es": "Nuestra documentación explica cómo configurar Buildpacks para que reconozca mejor tu proyecto:",
+		"ja": "Buildpacks がプロジェクトを認識しやすくなるように構成する方法は、ドキュメントをご覧ください:",
+	},
+	MsgReportIssue: {
+		"en": "If you think you've found an issue, please report it:",
+		"es": "Si crees que encontraste un problema, infórmalo:",
+		"ja": "問題が見つかった場合は、報告してください:",
+	},
+}
+
+// Locale returns the locale selected with env.BuildLocale as a lowercase BCP 47 language tag, such
+// as ja-jp, or the default locale if none is selected. POSIX locales such as ja_JP.UTF-8 are accepted.
+func Locale() string {
+	v := os.Getenv(env.BuildLocale)
+	// Drop the encoding and modifier of POSIX locales.
+	if i := strings.IndexAny(v, ".@"); i >= 0 {
+		v = v[:i]
+	}
+	v = strings.ToLower(strings.ReplaceAll(v, "_", "-"))
+	if v == "" || v == "c" || v == "posix" {
+		return defaultLocale
+	}
+	return v
+}
+
+// messageFormat returns the format of id in locale, falling back to the locale's language and then
+// to English.
+func messageFormat(id MessageID, locale string) string {
+	formats, ok := catalog[id]
+	if !ok {
+		// Unknown IDs are a programming error, but should not hide the error being reported.
+		return string(id)
+	}
+	if f, ok := formats[locale]; ok {
+		return f
+	}
+	if i := strings.Index(locale, "-"); i >= 0 {
+		if f, ok := formats[locale[:i]]; ok {
+			return f
+		}
+	}
+	return formats[defaultLocale]
+}
+
+// Message returns the message id in the locale selected with env.BuildLocale.
+func Message(id MessageID, args ...interface{}) string {
+	return fmt.Sprintf(messageFormat(id, Locale()), args...)
+}
+
+// UserErrorMsg constructs a user-attributed Error from the message id in the selected locale. The
+// error ID is generated from the English message, so that it is the same in every locale.
+func UserErrorMsg(id MessageID, args ...interface{}) *Error {
+	be := UserErrorf("%s", Message(id, args...))
+	be.ID = generateErrorID(fmt.Sprintf(messageFormat(id, defaultLocale), args...))
+	return be
+}
+
+// WarnMsg emits a structured logging line for the warning id in the selected locale.
+func (ctx *Context) WarnMsg(id MessageID, args ...interface{}) {
+	ctx.Warnf("%s", Message(id, args...))
+}
@@ -0,0 +1,128 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+func TestLocale(t *testing.T) {
+	testCases := []struct {
+		value string
+		want  string
+	}{
+		{value: "", want: "en"},
+		{value: "C", want: "en"},
+		{value: "ja", want: "ja"},
+		{value: "ja-JP", want: "ja-jp"},
+		{value: "ja_JP.UTF-8", want: "ja-jp"},
+		{value: "es_ES@euro", want: "es-es"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			defer os.Unsetenv(env.BuildLocale)
+			os.Setenv(env.BuildLocale, tc.value)
+
+			if got := Locale(); got != tc.want {
+				t.Errorf("Locale() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMessage(t *testing.T) {
+	testCases := []struct {
+		name   string
+		locale string
+		want   string
+	}{
+		{
+			name: "default",
+			want: "required env var GOOGLE_FUNCTION_TARGET not found",
+		},
+		{
+			name:   "translated",
+			locale: "ja",
+			want:   "必須の環境変数 GOOGLE_FUNCTION_TARGET が見つかりません",
+		},
+		{
+			name:   "region falls back to language",
+			locale: "es_MX.UTF-8",
+			want:   "no se encontró la variable de entorno obligatoria GOOGLE_FUNCTION_TARGET",
+		},
+		{
+			name:   "untranslated falls back to english",
+			locale: "fr",
+			want:   "required env var GOOGLE_FUNCTION_TARGET not found",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer os.Unsetenv(env.BuildLocale)
+			os.Setenv(env.BuildLocale, tc.locale)
+
+			if got := Message(MsgFunctionTargetRequired, env.FunctionTarget); got != tc.want {
+				t.Errorf("Message() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUserErrorMsgIDIsLocaleIndependent(t *testing.T) {
+	defer os.Unsetenv(env.BuildLocale)
+	want := UserErrorMsg(MsgSourceSubdirNotFound, env.SourceSubdir, "api")
+
+	os.Setenv(env.BuildLocale, "ja")
+	got := UserErrorMsg(MsgSourceSubdirNotFound, env.SourceSubdir, "api")
+
+	if got.Message == want.Message {
+		t.Errorf("UserErrorMsg() message = %q in both locales, want a translation", got.Message)
+	}
+	if got.ID != want.ID {
+		t.Errorf("UserErrorMsg() ID = %q, want %q", got.ID, want.ID)
+	}
+	if got.Status != StatusUnknown {
+		t.Errorf("UserErrorMsg() status = %v, want %v", got.Status, StatusUnknown)
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	verbs := regexp.MustCompile(`%[-+# 0]*[a-zA-Z]`)
+	for id, formats := range catalog {
+		en, ok := formats[defaultLocale]
+		if !ok {
+			t.Errorf("message %q has no %q format", id, defaultLocale)
+			continue
+		}
+		want := verbs.FindAllString(en, -1)
+		for locale, f := range formats {
+			got := verbs.FindAllString(f, -1)
+			if len(got) != len(want) {
+				t.Errorf("message %q in %q has verbs %q, want %q", id, locale, got, want)
+				continue
+			}
+			for i := range got {
+				if got[i] != want[i] {
+					t.Errorf("message %q in %q has verbs %q, want %q", id, locale, got, want)
+					break
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/buildpacks/libcnb"
+)
+
+// FileSystem is the file system on which the Context file helpers operate.
+type FileSystem interface {
+	Rename(oldpath, newpath string) error
+	Create(name string) (*os.File, error)
+	MkdirAll(path string, perm os.FileMode) error
+	RemoveAll(path string) error
+	Symlink(oldname, newname string) error
+	Chmod(name string, mode os.FileMode) error
+	Stat(name string) (os.FileInfo, error)
+	TempDir(dir, prefix string) (string, error)
+	WriteFile(filename string, data []byte, perm os.FileMode) error
+	ReadFile(filename string) ([]byte, error)
+	ReadDir(dirname string) ([]os.FileInfo, error)
+	Glob(pattern string) ([]string, error)
+	Walk(root string, walkFn filepath.WalkFunc) error
+}
+
+// Executor runs the commands executed by ctx.Exec and its variants. Run returns the exit code of
+// the command, and an error only if the command could not be run at all.
+type Executor interface {
+	Run(cmd *exec.Cmd) (int, error)
+}
+
+// ContextOption configures the Context passed to detect and build functions.
+type ContextOption func(*Context)
+
+// WithFileSystem replaces the file system used by the Context file helpers.
+func WithFileSystem(fs FileSystem) ContextOption {
+	return func(ctx *Context) {
+		ctx.fs = fs
+	}
+}
+
+// WithExecutor replaces how commands are run.
+func WithExecutor(e Executor) ContextOption {
+	return func(ctx *Context) {
+		ctx.executor = e
+	}
+}
+
+// WithLogger replaces the logger, which writes to stderr by default. Output of logged commands is
+// written to the logger's writer.
+func WithLogger(l *log.Logger) ContextOption {
+	return func(ctx *Context) {
+		ctx.logger = l
+	}
+}
+
+// WithExiter replaces how the buildpack exits, including when it opts in or out of detection.
+func WithExiter(e Exiter) ContextOption {
+	return func(ctx *Context) {
+		ctx.exiter = e
+	}
+}
+
+// WithPlatform replaces the platform that the buildpack builds for, which is the one it runs on by default.
+func WithPlatform(p Platform) ContextOption {
+	return func(ctx *Context) {
+		ctx.platform = p
+	}
+}
+
+// RunDetect runs detectFn as /bin/detect would, without reading arguments or writing results.
+// Unless replaced with WithExiter, exiting the buildpack, e.g. by opting out, exits the process.
+func RunDetect(ldctx libcnb.DetectContext, detectFn DetectFn, opts ...ContextOption) (libcnb.DetectResult, error) {
+	return gcpdetector{detectFn: detectFn, opts: opts}.Detect(ldctx)
+}
+
+// RunBuild runs buildFn as /bin/build would, without reading arguments or writing results.
+// Unless replaced with WithExiter, exiting the buildpack, e.g. on failure, exits the process.
+func RunBuild(lbctx libcnb.BuildContext, buildFn BuildFn, opts ...ContextOption) (libcnb.BuildResult, error) {
+	return gcpbuilder{buildFn: buildFn, opts: opts}.Build(lbctx)
+}
+
+type osFileSystem struct{}
+
+func (osFileSystem) Rename(oldpath, newpath string) error         { return os.Rename(oldpath, newpath) }
+func (osFileSystem) Create(name string) (*os.File, error)         { return os.Create(name) }
+func (osFileSystem) MkdirAll(path string, perm os.FileMode) error { return os.MkdirAll(path, perm) }
+func (osFileSystem) RemoveAll(path string) error                  { return os.RemoveAll(path) }
+func (osFileSystem) Symlink(oldname, newname string) error        { return os.Symlink(oldname, newname) }
+func (osFileSystem) Chmod(name string, mode os.FileMode) error    { return os.Chmod(name, mode) }
+func (osFileSystem) Stat(name string) (os.FileInfo, error)        { return os.Stat(name) }
+func (osFileSystem) TempDir(dir, prefix string) (string, error)   { return ioutil.TempDir(dir, prefix) }
+func (osFileSystem) WriteFile(filename string, data []byte, perm os.FileMode) error {
+	return ioutil.WriteFile(filename, data, perm)
+}
+func (osFileSystem) ReadFile(filename string) ([]byte, error)      { return ioutil.ReadFile(filename) }
+func (osFileSystem) ReadDir(dirname string) ([]os.FileInfo, error) { return ioutil.ReadDir(dirname) }
+func (osFileSystem) Glob(pattern string) ([]string, error)         { return filepath.Glob(pattern) }
+func (osFileSystem) Walk(root string, walkFn filepath.WalkFunc) error {
+	return filepath.Walk(root, walkFn)
+}
+
+type osExecutor struct{}
+
+func (osExecutor) Run(cmd *exec.Cmd) (int, error) {
+	if err := cmd.Run(); err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			// The command returned a non-zero result.
+			return ee.ExitCode(), nil
+		}
+		return 0, err
+	}
+	return 0, nil
+}
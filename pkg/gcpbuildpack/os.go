@@ -15,20 +15,24 @@
 package gcpbuildpack
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"syscall"
 )
 
 // Rename renames the old path to the new path, exiting on any error.
 func (ctx *Context) Rename(old, new string) {
-	if err := os.Rename(old, new); err != nil {
+	if err := ctx.fs.Rename(old, new); err != nil {
 		ctx.Exit(1, Errorf(StatusInternal, "renaming %s to %s: %v", old, new, err))
 	}
 }
 
 // CreateFile creates the specified file, return the File object, exiting on any error.
 func (ctx *Context) CreateFile(file string) *os.File {
-	f, err := os.Create(file)
+	f, err := ctx.fs.Create(file)
 	if err != nil {
 		ctx.Exit(1, Errorf(StatusInternal, "creating %s: %v", file, err))
 	}
@@ -37,7 +41,7 @@ func (ctx *Context) CreateFile(file string) *os.File {
 
 // MkdirAll creates all necessary directories for the given path, exiting on any error.
 func (ctx *Context) MkdirAll(path string, perm os.FileMode) {
-	if err := os.MkdirAll(path, perm); err != nil {
+	if err := ctx.fs.MkdirAll(path, perm); err != nil {
 		ctx.Exit(1, Errorf(StatusInternal, "creating %s: %v", path, err))
 	}
 }
@@ -45,14 +49,117 @@ func (ctx *Context) MkdirAll(path string, perm os.FileMode) {
 // RemoveAll removes the given path, exiting on any error.
 func (ctx *Context) RemoveAll(elem ...string) {
 	path := filepath.Join(elem...)
-	if err := os.RemoveAll(path); err != nil {
+	if err := ctx.fs.RemoveAll(path); err != nil {
 		ctx.Exit(1, Errorf(StatusInternal, "removing %s: %v", path, err))
 	}
 }
 
+// MoveContents moves the entries of directory src into directory dst, except those whose names match
+// one of the exclude patterns, in the syntax of filepath.Match, exiting on any error. If dst is inside
+// src, it is not moved into itself. Entries are renamed, or copied and removed if dst is on another
+// device, and are never overwritten.
+func (ctx *Context) MoveContents(src, dst string, exclude ...string) {
+	if err := moveContents(ctx.fs, src, dst, exclude); err != nil {
+		ctx.Exit(1, Errorf(StatusInternal, "moving the contents of %s to %s: %v", src, dst, err))
+	}
+}
+
+func moveContents(fs FileSystem, src, dst string, exclude []string) error {
+	for _, p := range exclude {
+		if _, err := filepath.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid exclude pattern %q: %v", p, err)
+		}
+	}
+	absDst, err := filepath.Abs(dst)
+	if err != nil {
+		return err
+	}
+	entries, err := fs.ReadDir(src)
+	if err != nil {
+		return err
+	}
+entries:
+	for _, e := range entries {
+		for _, p := range exclude {
+			if ok, _ := filepath.Match(p, e.Name()); ok {
+				continue entries
+			}
+		}
+		from := filepath.Join(src, e.Name())
+		if abs, err := filepath.Abs(from); err == nil && abs == absDst {
+			continue
+		}
+		to := filepath.Join(dst, e.Name())
+		if _, err := os.Lstat(to); err == nil {
+			return fmt.Errorf("%s already exists", to)
+		}
+		err := fs.Rename(from, to)
+		var le *os.LinkError
+		if errors.As(err, &le) && le.Err == syscall.EXDEV {
+			if err = copyTree(from, to); err == nil {
+				err = fs.RemoveAll(from)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("moving %s: %v", e.Name(), err)
+		}
+	}
+	return nil
+}
+
+// copyTree copies the file, directory or symlink at src to dst, preserving permissions.
+func copyTree(src, dst string) error {
+	fi, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	switch {
+	case fi.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+	case fi.IsDir():
+		if err := os.Mkdir(dst, fi.Mode().Perm()); err != nil {
+			return err
+		}
+		f, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		names, err := f.Readdirnames(-1)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		for _, n := range names {
+			if err := copyTree(filepath.Join(src, n), filepath.Join(dst, n)); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		in, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, fi.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	}
+}
+
 // Symlink creates newname as a symbolic name to oldname, exiting on any error.
 func (ctx *Context) Symlink(oldname string, newname string) {
-	if err := os.Symlink(oldname, newname); err != nil {
+	if err := ctx.fs.Symlink(oldname, newname); err != nil {
 		ctx.Exit(1, Errorf(StatusInternal, "symlinking from %q to %q: %v", oldname, newname, err))
 	}
 }
@@ -60,7 +167,7 @@ func (ctx *Context) Symlink(oldname string, newname string) {
 // FileExists returns true if a file exists at the path joined by elem, exiting on any error.
 func (ctx *Context) FileExists(elem ...string) bool {
 	path := filepath.Join(elem...)
-	if _, err := os.Stat(path); os.IsNotExist(err) {
+	if _, err := ctx.fs.Stat(path); os.IsNotExist(err) {
 		return false
 	} else if err != nil {
 		ctx.Exit(1, Errorf(StatusInternal, "stat %q: %v", path, err))
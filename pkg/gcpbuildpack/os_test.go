@@ -0,0 +1,134 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestMoveContents(t *testing.T) {
+	src, err := ioutil.TempDir("", "app")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(src)
+	files := []string{"main.go", "file with spaces.go", "$weird'name\".go", "pkg/sub/util.go", ".googlebuild/config", ".gitignore"}
+	for _, f := range files {
+		writeTestFile(t, filepath.Join(src, f), 0644)
+	}
+	dst := filepath.Join(src, "serverless_function_source_code")
+	if err := os.Mkdir(dst, 0755); err != nil {
+		t.Fatalf("creating %s: %v", dst, err)
+	}
+
+	if err := moveContents(osFileSystem{}, src, dst, []string{".google*"}); err != nil {
+		t.Fatalf("moveContents() got error: %v", err)
+	}
+
+	if got, want := listFiles(t, src), []string{
+		".googlebuild/config",
+		"serverless_function_source_code/$weird'name\".go",
+		"serverless_function_source_code/.gitignore",
+		"serverless_function_source_code/file with spaces.go",
+		"serverless_function_source_code/main.go",
+		"serverless_function_source_code/pkg/sub/util.go",
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("files after moveContents() = %q, want %q", got, want)
+	}
+}
+
+func TestMoveContentsErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		exclude  []string
+		existing string
+	}{
+		{name: "existing destination", existing: "main.go"},
+		{name: "bad pattern", exclude: []string{"[.google"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			src, err := ioutil.TempDir("", "src")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(src)
+			dst, err := ioutil.TempDir("", "dst")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dst)
+			writeTestFile(t, filepath.Join(src, "main.go"), 0644)
+			if tc.existing != "" {
+				writeTestFile(t, filepath.Join(dst, tc.existing), 0644)
+			}
+
+			if err := moveContents(osFileSystem{}, src, dst, tc.exclude); err == nil {
+				t.Error("moveContents() got nil error, want error")
+			}
+		})
+	}
+}
+
+func TestCopyTree(t *testing.T) {
+	src, err := ioutil.TempDir("", "src")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(src)
+	dst, err := ioutil.TempDir("", "dst")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dst)
+	writeTestFile(t, filepath.Join(src, "app", "bin", "run"), 0755)
+	if err := os.Symlink("bin/run", filepath.Join(src, "app", "run")); err != nil {
+		t.Fatalf("symlinking: %v", err)
+	}
+
+	if err := copyTree(filepath.Join(src, "app"), filepath.Join(dst, "app")); err != nil {
+		t.Fatalf("copyTree() got error: %v", err)
+	}
+
+	if fi, err := os.Stat(filepath.Join(dst, "app", "bin", "run")); err != nil || fi.Mode().Perm() != 0755 {
+		t.Errorf("copied bin/run: %v, %v, want mode %v", fi, err, os.FileMode(0755))
+	}
+	if target, err := os.Readlink(filepath.Join(dst, "app", "run")); err != nil || target != "bin/run" {
+		t.Errorf("copied symlink points to %q (%v), want %q", target, err, "bin/run")
+	}
+}
+
+func listFiles(t *testing.T, root string) []string {
+	t.Helper()
+	var files []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		files = append(files, rel)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("walking %s: %v", root, err)
+	}
+	sort.Strings(files)
+	return files
+}
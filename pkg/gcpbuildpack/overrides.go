@@ -0,0 +1,145 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// OverridesFile is the name of the file at the application root that enables or disables
+	// optional buildpacks within the detected group.
+	OverridesFile = "buildpacks.yaml"
+)
+
+// overridable lists the optional buildpacks a project may toggle. The value reports whether the
+// buildpack may also be enabled, which skips its detection; any listed buildpack may be disabled.
+var overridable = map[string]bool{
+	"google.config.validation":      false,
+	"google.go.clear_source":        true,
+	"google.java.clear_source":      true,
+	"google.nodejs.framework-build": false,
+	"google.php.framework":          false,
+	"google.python.django":          false,
+	"google.utils.config-render":    true,
+	"google.utils.git-submodules":   false,
+}
+
+// overrides is the parsed content of OverridesFile.
+type overrides struct {
+	Enable  []string `yaml:"enable"`
+	Disable []string `yaml:"disable"`
+	DevMode *bool    `yaml:"devmode"`
+}
+
+// readOverrides reads and validates OverridesFile in dir. It returns nil if the file does not exist.
+func readOverrides(dir string) (*overrides, *Error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, OverridesFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, InternalErrorf("reading %s: %v", OverridesFile, err)
+	}
+	return parseOverrides(data)
+}
+
+func parseOverrides(data []byte) (*overrides, *Error) {
+	var o overrides
+	if err := yaml.UnmarshalStrict(data, &o); err != nil {
+		return nil, UserErrorf("parsing %s: %v", OverridesFile, err)
+	}
+	disabled := make(map[string]bool)
+	for _, id := range o.Disable {
+		if _, ok := overridable[id]; !ok {
+			return nil, UserErrorf("%s cannot be disabled in %s, must be one of %s", id, OverridesFile, overridableIDs(false))
+		}
+		disabled[id] = true
+	}
+	for _, id := range o.Enable {
+		if !overridable[id] {
+			return nil, UserErrorf("%s cannot be enabled in %s, must be one of %s", id, OverridesFile, overridableIDs(true))
+		}
+		if disabled[id] {
+			return nil, UserErrorf("%s is both enabled and disabled in %s", id, OverridesFile)
+		}
+	}
+	return &o, nil
+}
+
+// detectOverride returns whether the buildpack is forced on (true) or off (false) by the overrides,
+// and whether any override applies to it at all.
+func (o *overrides) detectOverride(id string) (pass, ok bool) {
+	if o == nil {
+		return false, false
+	}
+	for _, d := range o.Disable {
+		if d == id {
+			return false, true
+		}
+	}
+	for _, e := range o.Enable {
+		if e == id {
+			return true, true
+		}
+	}
+	return false, false
+}
+
+// applyDevMode sets env.DevMode from the overrides unless the platform has already set it, so
+// that all buildpacks in the group observe the same mode.
+func (o *overrides) applyDevMode() *Error {
+	if o == nil || o.DevMode == nil {
+		return nil
+	}
+	if _, ok := os.LookupEnv(env.DevMode); ok {
+		return nil
+	}
+	if err := os.Setenv(env.DevMode, strconv.FormatBool(*o.DevMode)); err != nil {
+		return InternalErrorf("setting %s: %v", env.DevMode, err)
+	}
+	return nil
+}
+
+func overridableIDs(enable bool) string {
+	var ids []string
+	for id, canEnable := range overridable {
+		if !enable || canEnable {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, ", ")
+}
+
+// loadOverrides reads the overrides of the application and applies the dev mode setting.
+func (ctx *Context) loadOverrides() (*overrides, *Error) {
+	o, err := readOverrides(ctx.ApplicationRoot())
+	if err != nil {
+		return nil, err
+	}
+	if err := o.applyDevMode(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
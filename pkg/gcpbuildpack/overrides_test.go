@@ -0,0 +1,131 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"os"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+func TestParseOverrides(t *testing.T) {
+	testCases := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			data: "enable:\n- google.go.clear_source\ndisable:\n- google.nodejs.framework-build\ndevmode: true\n",
+		},
+		{
+			name: "empty",
+		},
+		{
+			name:    "unknown field",
+			data:    "skip:\n- google.nodejs.framework-build\n",
+			wantErr: true,
+		},
+		{
+			name:    "disable required buildpack",
+			data:    "disable:\n- google.nodejs.runtime\n",
+			wantErr: true,
+		},
+		{
+			name:    "enable buildpack that may only be disabled",
+			data:    "enable:\n- google.python.django\n",
+			wantErr: true,
+		},
+		{
+			name:    "enabled and disabled",
+			data:    "enable:\n- google.utils.config-render\ndisable:\n- google.utils.config-render\n",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseOverrides([]byte(tc.data))
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("parseOverrides() got error: %v, want error: %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestDetectOverride(t *testing.T) {
+	o := &overrides{Enable: []string{"google.go.clear_source"}, Disable: []string{"google.nodejs.framework-build"}}
+	testCases := []struct {
+		o        *overrides
+		id       string
+		wantPass bool
+		wantOK   bool
+	}{
+		{o: o, id: "google.go.clear_source", wantPass: true, wantOK: true},
+		{o: o, id: "google.nodejs.framework-build", wantPass: false, wantOK: true},
+		{o: o, id: "google.nodejs.runtime"},
+		{o: nil, id: "google.go.clear_source"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.id, func(t *testing.T) {
+			pass, ok := tc.o.detectOverride(tc.id)
+			if pass != tc.wantPass || ok != tc.wantOK {
+				t.Errorf("detectOverride(%q) = %t, %t, want %t, %t", tc.id, pass, ok, tc.wantPass, tc.wantOK)
+			}
+		})
+	}
+}
+
+func TestApplyDevMode(t *testing.T) {
+	enabled := true
+	testCases := []struct {
+		name    string
+		o       *overrides
+		env     string
+		wantEnv string
+	}{
+		{
+			name:    "set from overrides",
+			o:       &overrides{DevMode: &enabled},
+			wantEnv: "true",
+		},
+		{
+			name:    "platform takes precedence",
+			o:       &overrides{DevMode: &enabled},
+			env:     "false",
+			wantEnv: "false",
+		},
+		{
+			name: "not set",
+			o:    &overrides{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			os.Unsetenv(env.DevMode)
+			defer os.Unsetenv(env.DevMode)
+			if tc.env != "" {
+				os.Setenv(env.DevMode, tc.env)
+			}
+
+			if err := tc.o.applyDevMode(); err != nil {
+				t.Fatalf("applyDevMode() got error: %v", err)
+			}
+			if got := os.Getenv(env.DevMode); got != tc.wantEnv {
+				t.Errorf("%s = %q, want %q", env.DevMode, got, tc.wantEnv)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+const (
+	// permSetuid strips setuid and setgid bits from files.
+	permSetuid = "setuid"
+	// permReadOnly removes write bits from files.
+	permReadOnly = "readonly"
+	// permDirs sets directory permissions to 0755.
+	permDirs = "dirs"
+	// permAll enables every rule.
+	permAll = "all"
+
+	normalizedDirMode = 0755
+)
+
+// permissionRules is the set of rules selected with env.NormalizePermissions.
+type permissionRules map[string]bool
+
+// parsePermissionRules parses the value of env.NormalizePermissions.
+func parsePermissionRules(v string) (permissionRules, *Error) {
+	rules := permissionRules{}
+	for _, r := range strings.Split(v, ",") {
+		switch r = strings.TrimSpace(strings.ToLower(r)); r {
+		case "":
+		case permAll:
+			rules[permSetuid], rules[permReadOnly], rules[permDirs] = true, true, true
+		case permSetuid, permReadOnly, permDirs:
+			rules[r] = true
+		default:
+			return nil, UserErrorf("invalid %s rule %q, must be %s, %s, %s or %s", env.NormalizePermissions, r, permSetuid, permReadOnly, permDirs, permAll)
+		}
+	}
+	return rules, nil
+}
+
+// normalizedMode returns the mode of a file or directory after applying the rules.
+func (rules permissionRules) normalizedMode(mode os.FileMode) os.FileMode {
+	if mode.IsDir() {
+		if rules[permDirs] {
+			return mode&^(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky) | normalizedDirMode
+		}
+		return mode
+	}
+	if rules[permSetuid] {
+		mode &^= os.ModeSetuid | os.ModeSetgid
+	}
+	if rules[permReadOnly] {
+		mode &^= 0222
+	}
+	return mode
+}
+
+// normalizePermissions applies the rules selected with env.NormalizePermissions to the launch
+// layers of the build. Files in layers that are also cached stay writable, so that the next build
+// can update them. Symlinks are not followed.
+func (ctx *Context) normalizePermissions() *Error {
+	v := os.Getenv(env.NormalizePermissions)
+	if v == "" {
+		return nil
+	}
+	rules, be := parsePermissionRules(v)
+	if be != nil {
+		return be
+	}
+
+	var names []string
+	for _, lc := range ctx.buildResult.Layers {
+		c, ok := lc.(layerContributor)
+		if !ok || !c.l.Launch {
+			continue
+		}
+		layerRules := rules
+		if c.l.Cache && rules[permReadOnly] {
+			layerRules = permissionRules{permSetuid: rules[permSetuid], permDirs: rules[permDirs]}
+		}
+		changed := 0
+		err := ctx.fs.Walk(c.l.Path, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.Mode()&os.ModeSymlink != 0 {
+				return nil
+			}
+			if want := layerRules.normalizedMode(info.Mode()); want != info.Mode() {
+				changed++
+				return ctx.fs.Chmod(path, want)
+			}
+			return nil
+		})
+		if err != nil {
+			return InternalErrorf("normalizing permissions of layer %s: %v", c.l.Name, err)
+		}
+		if changed > 0 {
+			names = append(names, c.l.Name)
+			ctx.Debugf("Normalized permissions of %d file(s) in layer %s", changed, c.l.Name)
+		}
+	}
+	if len(names) > 0 {
+		sort.Strings(names)
+		ctx.Logf("Normalized file permissions in launch layers: %s", strings.Join(names, ", "))
+	}
+	return nil
+}
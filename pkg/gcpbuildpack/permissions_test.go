@@ -0,0 +1,152 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestParsePermissionRules(t *testing.T) {
+	testCases := []struct {
+		value   string
+		want    permissionRules
+		wantErr bool
+	}{
+		{value: "setuid", want: permissionRules{permSetuid: true}},
+		{value: "setuid, Dirs", want: permissionRules{permSetuid: true, permDirs: true}},
+		{value: "all", want: permissionRules{permSetuid: true, permReadOnly: true, permDirs: true}},
+		{value: "setuid,chown", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			got, err := parsePermissionRules(tc.value)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("parsePermissionRules(%q) got error: %v, want error: %t", tc.value, err, tc.wantErr)
+			}
+			if !tc.wantErr && !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parsePermissionRules(%q) = %v, want %v", tc.value, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNormalizedMode(t *testing.T) {
+	all := permissionRules{permSetuid: true, permReadOnly: true, permDirs: true}
+	testCases := []struct {
+		name  string
+		rules permissionRules
+		mode  os.FileMode
+		want  os.FileMode
+	}{
+		{
+			name:  "setuid binary",
+			rules: permissionRules{permSetuid: true},
+			mode:  os.ModeSetuid | os.ModeSetgid | 0755,
+			want:  0755,
+		},
+		{
+			name:  "writable file",
+			rules: permissionRules{permReadOnly: true},
+			mode:  0666,
+			want:  0444,
+		},
+		{
+			name:  "world-writable directory",
+			rules: all,
+			mode:  os.ModeDir | os.ModeSticky | 0777,
+			want:  os.ModeDir | 0755,
+		},
+		{
+			name:  "private directory",
+			rules: all,
+			mode:  os.ModeDir | 0700,
+			want:  os.ModeDir | 0755,
+		},
+		{
+			name:  "directory without dirs rule",
+			rules: permissionRules{permReadOnly: true},
+			mode:  os.ModeDir | 0777,
+			want:  os.ModeDir | 0777,
+		},
+		{
+			name:  "no rules",
+			rules: permissionRules{},
+			mode:  os.ModeSetuid | 0777,
+			want:  os.ModeSetuid | 0777,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.rules.normalizedMode(tc.mode); got != tc.want {
+				t.Errorf("normalizedMode(%v) = %v, want %v", tc.mode, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNormalizePermissions(t *testing.T) {
+	layers, err := ioutil.TempDir("", "layers")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(layers)
+	defer os.Setenv(env.NormalizePermissions, os.Getenv(env.NormalizePermissions))
+	os.Setenv(env.NormalizePermissions, "readonly,dirs")
+
+	ctx := newBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layers}})
+	launch := ctx.Layer("launch", LaunchLayer)
+	cached := ctx.Layer("cached", LaunchLayer, CacheLayer)
+	build := ctx.Layer("build", BuildLayer)
+	for _, l := range []*libcnb.Layer{launch, cached, build} {
+		if err := os.Mkdir(filepath.Join(l.Path, "dir"), 0777); err != nil {
+			t.Fatalf("creating dir: %v", err)
+		}
+		if err := os.Chmod(filepath.Join(l.Path, "dir"), 0777); err != nil {
+			t.Fatalf("chmod dir: %v", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(l.Path, "dir", "file"), nil, 0644); err != nil {
+			t.Fatalf("writing file: %v", err)
+		}
+	}
+
+	if err := ctx.normalizePermissions(); err != nil {
+		t.Fatalf("normalizePermissions() got error: %v", err)
+	}
+
+	wantModes := map[string]os.FileMode{
+		filepath.Join(launch.Path, "dir"):         os.ModeDir | 0755,
+		filepath.Join(launch.Path, "dir", "file"): 0444,
+		filepath.Join(cached.Path, "dir"):         os.ModeDir | 0755,
+		filepath.Join(cached.Path, "dir", "file"): 0644,
+		filepath.Join(build.Path, "dir"):          os.ModeDir | 0777,
+		filepath.Join(build.Path, "dir", "file"):  0644,
+	}
+	for path, want := range wantModes {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("stat %s: %v", path, err)
+		}
+		if got := info.Mode(); got != want {
+			t.Errorf("mode of %s = %v, want %v", path, got, want)
+		}
+	}
+}
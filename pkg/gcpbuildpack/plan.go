@@ -0,0 +1,125 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// planFilename is the file in the builder output directory to which buildpacks append their plans.
+	planFilename = "plan"
+
+	defaultMissReason = "no cached layer matches the current inputs"
+)
+
+// layerDecision records whether a buildpack reuses a cached layer or rebuilds it, and why.
+type layerDecision struct {
+	BuildpackID string `json:"buildpackId"`
+	Layer       string `json:"layer"`
+	Rebuild     bool   `json:"rebuild"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// ExplainCacheMiss records why the next CacheMiss of tag happens, for the build plan.
+func (ctx *Context) ExplainCacheMiss(tag, format string, args ...interface{}) {
+	if ctx.missReasons == nil {
+		ctx.missReasons = map[string]string{}
+	}
+	ctx.missReasons[tag] = fmt.Sprintf(format, args...)
+}
+
+// planCacheHit records that the layer tag is reused.
+func (ctx *Context) planCacheHit(tag string) {
+	ctx.decisions = append(ctx.decisions, layerDecision{BuildpackID: ctx.BuildpackID(), Layer: tag, Reason: "cached layer matches the current inputs"})
+}
+
+// planCacheMiss records that the layer tag is rebuilt. In the plan phase, it ends the build, since the
+// buildpack would go on to rebuild the layer.
+func (ctx *Context) planCacheMiss(tag string) {
+	reason := ctx.missReasons[tag]
+	if reason == "" {
+		reason = defaultMissReason
+	}
+	delete(ctx.missReasons, tag)
+	ctx.decisions = append(ctx.decisions, layerDecision{BuildpackID: ctx.BuildpackID(), Layer: tag, Rebuild: true, Reason: reason})
+	if ctx.plan {
+		ctx.finishPlan("the layers after it are rebuilt or planned when the build is applied")
+		ctx.Exit(0, nil)
+	}
+}
+
+// finishPlan logs the plan of the buildpack and appends it to the builder output plan file. note,
+// if not empty, explains why the plan ended early.
+func (ctx *Context) finishPlan(note string) {
+	for _, d := range ctx.decisions {
+		if d.Rebuild {
+			ctx.Logf("Plan: rebuild layer %q: %s", d.Layer, d.Reason)
+		} else {
+			ctx.Logf("Plan: reuse layer %q: %s", d.Layer, d.Reason)
+		}
+	}
+	if note != "" {
+		ctx.Logf("Plan: stopped planning %s: %s", ctx.BuildpackID(), note)
+	}
+	if err := ctx.savePlan(); err != nil {
+		ctx.Warnf("Failed to save the build plan: %v", err)
+	}
+}
+
+// savePlan appends the decisions of the buildpack to the plan file, one JSON object per line, if the
+// platform provides a builder output directory.
+func (ctx *Context) savePlan() error {
+	dir := os.Getenv(builderOutputEnv)
+	if dir == "" || len(ctx.decisions) == 0 {
+		return nil
+	}
+	fname := filepath.Join(dir, planFilename)
+	f, err := os.OpenFile(fname, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("opening %s: %v", fname, err)
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	for _, d := range ctx.decisions {
+		if err := enc.Encode(d); err != nil {
+			return fmt.Errorf("writing %s: %v", fname, err)
+		}
+	}
+	return nil
+}
+
+// planExiter ends buildpacks that fail in the plan phase successfully, since a failure to plan, for
+// example because an earlier buildpack did not install a tool when planning, must not fail the plan.
+type planExiter struct {
+	ctx  *Context
+	next Exiter
+}
+
+func (e planExiter) Exit(exitCode int, be *Error) {
+	if exitCode == 0 {
+		e.next.Exit(exitCode, be)
+		return
+	}
+	reason := fmt.Sprintf("exit code %d", exitCode)
+	if be != nil {
+		reason = be.Message
+	}
+	e.ctx.finishPlan("the build cannot be planned further (" + reason + ")")
+	e.next.Exit(0, nil)
+}
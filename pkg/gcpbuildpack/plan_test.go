@@ -0,0 +1,137 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/buildpacks/libcnb"
+)
+
+func TestPlanDecisions(t *testing.T) {
+	testCases := []struct {
+		name      string
+		plan      bool
+		wantExit  bool
+		wantSaved []layerDecision
+	}{
+		{
+			name: "apply",
+		},
+		{
+			name:     "plan",
+			plan:     true,
+			wantExit: true,
+			wantSaved: []layerDecision{
+				{BuildpackID: "my-id", Layer: "runtime", Reason: "cached layer matches the current inputs"},
+				{BuildpackID: "my-id", Layer: "deps", Rebuild: true, Reason: "Node.js version changed from v12 to v14"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "plan")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			os.Setenv(builderOutputEnv, dir)
+			defer os.Unsetenv(builderOutputEnv)
+			exiter := &fakeExiter{}
+			ctx := newBuildContext(libcnb.BuildContext{Buildpack: libcnb.Buildpack{Info: libcnb.BuildpackInfo{ID: "my-id"}}}, WithExiter(exiter), WithLogger(log.New(ioutil.Discard, "", 0)))
+			ctx.plan = tc.plan
+
+			ctx.CacheHit("runtime")
+			ctx.ExplainCacheMiss("deps", "Node.js version changed from %s to %s", "v12", "v14")
+			ctx.CacheMiss("deps")
+
+			want := []layerDecision{
+				{BuildpackID: "my-id", Layer: "runtime", Reason: "cached layer matches the current inputs"},
+				{BuildpackID: "my-id", Layer: "deps", Rebuild: true, Reason: "Node.js version changed from v12 to v14"},
+			}
+			if !reflect.DeepEqual(ctx.decisions, want) {
+				t.Errorf("decisions = %+v, want %+v", ctx.decisions, want)
+			}
+			if exiter.called != tc.wantExit || exiter.code != 0 {
+				t.Errorf("exited = %t with code %d, want exited = %t with code 0", exiter.called, exiter.code, tc.wantExit)
+			}
+			if got := readPlan(t, dir); !reflect.DeepEqual(got, tc.wantSaved) {
+				t.Errorf("saved plan = %+v, want %+v", got, tc.wantSaved)
+			}
+		})
+	}
+}
+
+func TestCacheMissDefaultReason(t *testing.T) {
+	ctx := newBuildContext(libcnb.BuildContext{}, WithLogger(log.New(ioutil.Discard, "", 0)))
+	ctx.ExplainCacheMiss("other", "unrelated")
+
+	ctx.CacheMiss("deps")
+
+	if got := ctx.decisions[0].Reason; got != defaultMissReason {
+		t.Errorf("reason = %q, want %q", got, defaultMissReason)
+	}
+}
+
+func TestPlanExiter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plan")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv(builderOutputEnv, dir)
+	defer os.Unsetenv(builderOutputEnv)
+	next := &fakeExiter{}
+	ctx := newBuildContext(libcnb.BuildContext{}, WithLogger(log.New(ioutil.Discard, "", 0)))
+	ctx.decisions = []layerDecision{{Layer: "runtime"}}
+
+	planExiter{ctx: ctx, next: next}.Exit(1, UserErrorf("node: command not found"))
+
+	if !next.called || next.code != 0 || next.err != nil {
+		t.Errorf("Exit(1) exited with code %d and error %v, want code 0 and no error", next.code, next.err)
+	}
+	if got := readPlan(t, dir); len(got) != 1 {
+		t.Errorf("saved plan = %+v, want 1 decision", got)
+	}
+}
+
+func readPlan(t *testing.T, dir string) []layerDecision {
+	t.Helper()
+	f, err := os.Open(filepath.Join(dir, planFilename))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatalf("opening plan: %v", err)
+	}
+	defer f.Close()
+	var decisions []layerDecision
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var d layerDecision
+		if err := json.Unmarshal(s.Bytes(), &d); err != nil {
+			t.Fatalf("unmarshalling %q: %v", s.Text(), err)
+		}
+		decisions = append(decisions, d)
+	}
+	return decisions
+}
@@ -0,0 +1,104 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/warmcache"
+)
+
+// Platform is the operating system of the stack that a buildpack builds for. Buildpack binaries
+// are compiled for the operating system of their stack, so it is the one they run on.
+type Platform string
+
+const (
+	// Linux is the platform of the Linux stacks, such as google.
+	Linux Platform = "linux"
+	// Windows is the platform of Windows Server stacks.
+	Windows Platform = "windows"
+)
+
+// platformOf returns the platform of the given GOOS; only Linux and Windows stacks are supported.
+func platformOf(goos string) Platform {
+	if goos == "windows" {
+		return Windows
+	}
+	return Linux
+}
+
+// hostPlatform is the platform that the buildpack runs on.
+var hostPlatform = platformOf(runtime.GOOS)
+
+// Platform returns the platform that the buildpack builds for.
+func (ctx *Context) Platform() Platform {
+	return ctx.platform
+}
+
+// Executable returns the file name of the executable called name, such as node.exe on Windows.
+func (p Platform) Executable(name string) string {
+	if p == Windows {
+		return name + ".exe"
+	}
+	return name
+}
+
+// ShellCommand returns the command that runs script in the platform's shell.
+func (p Platform) ShellCommand(script string) []string {
+	if p == Windows {
+		return []string{"cmd", "/c", script}
+	}
+	return []string{"bash", "-c", script}
+}
+
+// InstallArchive downloads the archive at url, reading it from the warm cache when possible, and
+// extracts it into dir, removing strip leading path elements from its entries. Archives are .tar.gz
+// or .tar.xz files on Linux and .zip files on Windows, which are extracted with the tar.exe that
+// ships with Windows Server 2019 and later. Downloads are streamed into tar on Linux, unless their
+// progress is reported to env.ProgressPipe, which requires downloading them to a file first.
+func (ctx *Context) InstallArchive(url, dir string, strip int) {
+	flag := "z"
+	if strings.HasSuffix(url, ".tar.xz") {
+		flag = "J"
+	}
+	archive := warmcache.ArchivePath(warmcache.Dir(), url)
+	cached := ctx.FileExists(archive)
+	limitArgs, release := ctx.DownloadSlot(url)
+	defer release()
+	if ctx.platform != Windows && (cached || ctx.progress == nil) {
+		command := fmt.Sprintf("%s | tar x%s --directory %s --strip-components=%d", warmcache.DownloadCommand(url, limitArgs...), flag, dir, strip)
+		ctx.Exec(ctx.platform.ShellCommand(command), WithUserAttribution)
+		return
+	}
+
+	if !cached {
+		tmp := ctx.TempDir("", "archive")
+		defer ctx.RemoveAll(tmp)
+		archive = filepath.Join(tmp, path.Base(url))
+		stop := ctx.reportDownload(url, archive)
+		curl := append([]string{ctx.platform.Executable("curl"), "--fail", "--show-error", "--silent", "--location", "--retry", "3"}, limitArgs...)
+		ctx.Exec(append(curl, "--output", archive, url), WithUserAttribution)
+		stop()
+	}
+	if ctx.platform == Windows {
+		ctx.Exec([]string{"tar.exe", "-xf", archive, "-C", dir, fmt.Sprintf("--strip-components=%d", strip)}, WithUserAttribution)
+		return
+	}
+	ctx.Exec([]string{"tar", "x" + flag, "--file", archive, "--directory", dir, fmt.Sprintf("--strip-components=%d", strip)}, WithUserAttribution)
+}
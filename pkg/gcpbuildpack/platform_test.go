@@ -0,0 +1,112 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/buildpacks/libcnb"
+)
+
+// recordingExecutor records the commands it is asked to run without running them.
+type recordingExecutor struct {
+	commands [][]string
+}
+
+func (e *recordingExecutor) Run(cmd *exec.Cmd) (int, error) {
+	e.commands = append(e.commands, cmd.Args)
+	return 0, nil
+}
+
+func TestPlatformOf(t *testing.T) {
+	testCases := []struct {
+		goos string
+		want Platform
+	}{
+		{goos: "linux", want: Linux},
+		{goos: "windows", want: Windows},
+		{goos: "darwin", want: Linux},
+	}
+	for _, tc := range testCases {
+		if got := platformOf(tc.goos); got != tc.want {
+			t.Errorf("platformOf(%q) = %q, want %q", tc.goos, got, tc.want)
+		}
+	}
+}
+
+func TestPlatformExecutable(t *testing.T) {
+	if got, want := Linux.Executable("node"), "node"; got != want {
+		t.Errorf("Linux.Executable() = %q, want %q", got, want)
+	}
+	if got, want := Windows.Executable("node"), "node.exe"; got != want {
+		t.Errorf("Windows.Executable() = %q, want %q", got, want)
+	}
+}
+
+func TestInstallArchive(t *testing.T) {
+	testCases := []struct {
+		name     string
+		platform Platform
+		url      string
+		want     [][]string
+	}{
+		{
+			name:     "linux tar.gz",
+			platform: Linux,
+			url:      "https://example.com/sdk.tar.gz",
+			want:     [][]string{{"bash", "-c", "curl --fail --show-error --silent --location --retry 3 https://example.com/sdk.tar.gz | tar xz --directory /layer --strip-components=1"}},
+		},
+		{
+			name:     "linux tar.xz",
+			platform: Linux,
+			url:      "https://example.com/node.tar.xz",
+			want:     [][]string{{"bash", "-c", "curl --fail --show-error --silent --location --retry 3 https://example.com/node.tar.xz | tar xJ --directory /layer --strip-components=1"}},
+		},
+		{
+			name:     "windows zip",
+			platform: Windows,
+			url:      "https://example.com/node.zip",
+			want: [][]string{
+				{"curl.exe", "--fail", "--show-error", "--silent", "--location", "--retry", "3", "--output", "node.zip", "https://example.com/node.zip"},
+				{"tar.exe", "-xf", "node.zip", "-C", "/layer", "--strip-components=1"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			x := &recordingExecutor{}
+			ctx := NewContext(libcnb.BuildpackInfo{})
+			WithExecutor(x)(ctx)
+			WithPlatform(tc.platform)(ctx)
+
+			ctx.InstallArchive(tc.url, "/layer", 1)
+
+			// The archive is downloaded to a temporary directory, whose name is not deterministic.
+			for _, args := range x.commands {
+				for i, a := range args {
+					if i > 0 && (args[i-1] == "--output" || args[i-1] == "-xf") {
+						args[i] = filepath.Base(a)
+					}
+				}
+			}
+			if !reflect.DeepEqual(x.commands, tc.want) {
+				t.Errorf("InstallArchive() ran %q, want %q", x.commands, tc.want)
+			}
+		})
+	}
+}
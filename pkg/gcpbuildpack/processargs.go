@@ -0,0 +1,134 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+)
+
+// appendProcessArgs appends the arguments set with env.ProcessArgs to the processes of the build.
+func (ctx *Context) appendProcessArgs() *Error {
+	for i, p := range ctx.buildResult.Processes {
+		name := processArgsEnv(p.Type)
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		args, err := splitArgs(v)
+		if err != nil {
+			return UserErrorf("parsing %s: %v", name, err)
+		}
+		if len(args) == 0 {
+			continue
+		}
+		ctx.Logf("Appending %q from %s to the %s process", args, name, p.Type)
+		ctx.buildResult.Processes[i] = processWithArgs(p, args)
+	}
+	return nil
+}
+
+// processArgsEnv returns the name of the env var holding the arguments of processes of type t.
+func processArgsEnv(t string) string {
+	return fmt.Sprintf(env.ProcessArgs, strings.ToUpper(strings.ReplaceAll(t, "-", "_")))
+}
+
+// processWithArgs returns p with args appended to its command. Commands run by a shell, such as
+// `bash -c "<script>"` processes, get the quoted arguments appended to the script instead, since
+// the shell would not pass arguments after the script to the command.
+func processWithArgs(p libcnb.Process, args []string) libcnb.Process {
+	if !p.Direct {
+		p.Command += " " + shellJoin(args)
+		return p
+	}
+	if isShell(p.Command) && len(p.Arguments) >= 2 && p.Arguments[0] == "-c" {
+		p.Arguments = append([]string{}, p.Arguments...)
+		p.Arguments[1] += " " + shellJoin(args)
+		return p
+	}
+	p.Arguments = append(append([]string{}, p.Arguments...), args...)
+	return p
+}
+
+func isShell(cmd string) bool {
+	switch filepath.Base(cmd) {
+	case "bash", "sh":
+		return true
+	}
+	return false
+}
+
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = shellQuote(a)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// splitArgs splits s into arguments the way a shell would, honoring single quotes, double quotes and
+// backslashes. It does not expand variables.
+func splitArgs(s string) ([]string, error) {
+	var (
+		args    []string
+		cur     strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range s {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if escaped {
+		return nil, fmt.Errorf("trailing backslash in %q", s)
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote in %q", quote, s)
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/buildpacks/libcnb"
+)
+
+func TestSplitArgs(t *testing.T) {
+	testCases := []struct {
+		args    string
+		want    []string
+		wantErr bool
+	}{
+		{args: "", want: nil},
+		{args: "  --workers   4 ", want: []string{"--workers", "4"}},
+		{args: `--name "my app" --greeting='hello world'`, want: []string{"--name", "my app", "--greeting=hello world"}},
+		{args: `a\ b "c\"d" 'e\f'`, want: []string{"a b", `c"d`, `e\f`}},
+		{args: `""`, want: []string{""}},
+		{args: `"unterminated`, wantErr: true},
+		{args: `trailing\`, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.args, func(t *testing.T) {
+			got, err := splitArgs(tc.args)
+
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("splitArgs(%q) got error: %v, want error: %t", tc.args, err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("splitArgs(%q) = %q, want %q", tc.args, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestProcessWithArgs(t *testing.T) {
+	args := []string{"--log-level", "it's"}
+	testCases := []struct {
+		name    string
+		process libcnb.Process
+		want    libcnb.Process
+	}{
+		{
+			name:    "direct",
+			process: libcnb.Process{Type: "web", Command: "/layers/bin/main", Arguments: []string{"-port", "8080"}, Direct: true},
+			want:    libcnb.Process{Type: "web", Command: "/layers/bin/main", Arguments: []string{"-port", "8080", "--log-level", "it's"}, Direct: true},
+		},
+		{
+			name:    "bash script",
+			process: libcnb.Process{Type: "web", Command: "/bin/bash", Arguments: []string{"-c", "gunicorn main:app"}, Direct: true},
+			want:    libcnb.Process{Type: "web", Command: "/bin/bash", Arguments: []string{"-c", `gunicorn main:app '--log-level' 'it'\''s'`}, Direct: true},
+		},
+		{
+			name:    "shell",
+			process: libcnb.Process{Type: "web", Command: "npm start"},
+			want:    libcnb.Process{Type: "web", Command: `npm start '--log-level' 'it'\''s'`},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := processWithArgs(tc.process, args); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("processWithArgs() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAppendProcessArgs(t *testing.T) {
+	ctx := NewContext(libcnb.BuildpackInfo{})
+	ctx.AddWebProcess([]string{"/layers/bin/main"})
+	ctx.buildResult.Processes = append(ctx.buildResult.Processes, libcnb.Process{Type: "task-runner", Command: "/layers/bin/task", Direct: true})
+	os.Setenv("GOOGLE_PROCESS_WEB_ARGS", "--verbose")
+	defer os.Unsetenv("GOOGLE_PROCESS_WEB_ARGS")
+	os.Setenv("GOOGLE_PROCESS_TASK_RUNNER_ARGS", "--once")
+	defer os.Unsetenv("GOOGLE_PROCESS_TASK_RUNNER_ARGS")
+
+	if err := ctx.appendProcessArgs(); err != nil {
+		t.Fatalf("appendProcessArgs() got error: %v", err)
+	}
+
+	var got [][]string
+	for _, p := range ctx.buildResult.Processes {
+		got = append(got, p.Arguments)
+	}
+	if want := [][]string{{"--verbose"}, {"--once"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("process arguments = %q, want %q", got, want)
+	}
+}
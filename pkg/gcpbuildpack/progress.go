@@ -0,0 +1,214 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+const (
+	// maxProgressEventBytes keeps each event within PIPE_BUF, so that events of concurrent writers are
+	// not interleaved in the pipe.
+	maxProgressEventBytes = 4096
+
+	progressPhaseStarted  = "phaseStarted"
+	progressPhaseFinished = "phaseFinished"
+	progressDownload      = "download"
+	progressWarning       = "warning"
+)
+
+var (
+	// downloadProgressInterval is how often the size of a download in progress is reported.
+	downloadProgressInterval = 2 * time.Second
+)
+
+// progressEvent is written to env.ProgressPipe, one JSON object per line.
+type progressEvent struct {
+	Time        string `json:"time"`
+	Type        string `json:"type"`
+	BuildpackID string `json:"buildpackId"`
+	Phase       string `json:"phase,omitempty"`
+	Status      string `json:"status,omitempty"`
+	DurationMs  int64  `json:"durationMs,omitempty"`
+	Message     string `json:"message,omitempty"`
+	URL         string `json:"url,omitempty"`
+	Bytes       int64  `json:"bytes,omitempty"`
+	TotalBytes  int64  `json:"totalBytes,omitempty"`
+	// Percent is an estimate from the size of the download so far; -1 if the total size is unknown.
+	Percent *int `json:"percent,omitempty"`
+}
+
+// progressStream writes progress events to the platform. A nil stream discards them.
+type progressStream struct {
+	mu    sync.Mutex
+	f     *os.File
+	phase string
+	start time.Time
+}
+
+// openProgress opens env.ProgressPipe, if set, for writing progress events. The pipe is opened
+// without blocking so that builds do not hang when no platform reads it; events are then dropped.
+func (ctx *Context) openProgress() {
+	path := os.Getenv(env.ProgressPipe)
+	if path == "" {
+		return
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE|syscall.O_NONBLOCK, 0644)
+	if err != nil {
+		ctx.Debugf("Not writing progress events to %s: %v", path, err)
+		return
+	}
+	ctx.progress = &progressStream{f: f}
+}
+
+// emitProgress writes e to the progress stream, if any. Failing to write progress never fails the
+// build; the stream is closed instead.
+func (ctx *Context) emitProgress(e progressEvent) {
+	p := ctx.progress
+	if p == nil {
+		return
+	}
+	e.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	e.BuildpackID = ctx.BuildpackID()
+	line, err := json.Marshal(e)
+	if err == nil && len(line) >= maxProgressEventBytes {
+		// Keep the start of long messages, leaving room for the newline and the ellipsis.
+		if excess := len(line) - maxProgressEventBytes + 1 + len("..."); excess < len(e.Message) {
+			e.Message = strings.ToValidUTF8(e.Message[:len(e.Message)-excess], "") + "..."
+			line, err = json.Marshal(e)
+		}
+		if err == nil && len(line) >= maxProgressEventBytes {
+			err = fmt.Errorf("event of %d bytes exceeds %d bytes", len(line), maxProgressEventBytes)
+		}
+	}
+	if err != nil {
+		ctx.Debugf("Dropping progress event %+v: %v", e, err)
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.f == nil {
+		return
+	}
+	if _, err := p.f.Write(append(line, '\n')); err != nil {
+		ctx.Debugf("Not writing further progress events: %v", err)
+		p.f.Close()
+		p.f = nil
+	}
+}
+
+// startPhase reports that the buildpack started the given lifecycle phase.
+func (ctx *Context) startPhase(phase string) {
+	if ctx.progress == nil {
+		return
+	}
+	ctx.progress.phase = phase
+	ctx.progress.start = time.Now()
+	ctx.emitProgress(progressEvent{Type: progressPhaseStarted, Phase: phase})
+}
+
+// finishPhase reports that the phase started with startPhase finished with status, and closes the
+// stream. It may be called more than once, e.g. both when exiting and returning; only the first
+// call is reported.
+func (ctx *Context) finishPhase(status Status) {
+	p := ctx.progress
+	if p == nil || p.phase == "" {
+		return
+	}
+	phase := p.phase
+	p.phase = ""
+	ctx.emitProgress(progressEvent{Type: progressPhaseFinished, Phase: phase, Status: status.String(), DurationMs: time.Since(p.start).Milliseconds()})
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.f != nil {
+		p.f.Close()
+		p.f = nil
+	}
+}
+
+// reportDownload reports the progress of downloading url to path until the returned function is
+// called. The percentage is estimated from the size of path and the Content-Length of url, if the
+// server reports it.
+func (ctx *Context) reportDownload(url, path string) func() {
+	if ctx.progress == nil {
+		return func() {}
+	}
+	var total int64
+	if res, err := httpClient.Head(url); err == nil {
+		res.Body.Close()
+		if res.StatusCode == 200 && res.ContentLength > 0 {
+			total = res.ContentLength
+		}
+	}
+	event := func(size int64) progressEvent {
+		percent := -1
+		if total > 0 {
+			percent = int(size * 100 / total)
+		}
+		return progressEvent{Type: progressDownload, URL: url, Bytes: size, TotalBytes: total, Percent: &percent}
+	}
+	size := func() int64 {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return 0
+		}
+		return fi.Size()
+	}
+
+	ctx.emitProgress(event(0))
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(downloadProgressInterval)
+		defer ticker.Stop()
+		last := int64(0)
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				// Downloads are retried, so the size may shrink; report it regardless.
+				if s := size(); s != last {
+					last = s
+					e := event(s)
+					// The estimate never reaches 100% before the download completes.
+					if *e.Percent > 99 {
+						*e.Percent = 99
+					}
+					ctx.emitProgress(e)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+		e := event(size())
+		*e.Percent = 100
+		ctx.emitProgress(e)
+	}
+}
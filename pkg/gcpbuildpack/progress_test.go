@@ -0,0 +1,189 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+)
+
+// progressFile points env.ProgressPipe at a file and returns its path and a clean-up function.
+func progressFile(t *testing.T) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "progress")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	path := filepath.Join(dir, "progress")
+	os.Setenv(env.ProgressPipe, path)
+	return path, func() {
+		os.Unsetenv(env.ProgressPipe)
+		os.RemoveAll(dir)
+	}
+}
+
+func readProgress(t *testing.T, path string) []progressEvent {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("opening %s: %v", path, err)
+	}
+	defer f.Close()
+	var events []progressEvent
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var e progressEvent
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			t.Fatalf("parsing event %q: %v", s.Text(), err)
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestProgressEvents(t *testing.T) {
+	path, cleanUp := progressFile(t)
+	defer cleanUp()
+	ctx := NewContext(libcnb.BuildpackInfo{ID: "my-id"})
+
+	ctx.openProgress()
+	ctx.startPhase("build")
+	ctx.Warnf("careful with %s", "that")
+	ctx.finishPhase(StatusInvalidArgument)
+	ctx.finishPhase(StatusOk)
+	ctx.Warnf("after the build")
+
+	var got []string
+	events := readProgress(t, path)
+	for _, e := range events {
+		if e.BuildpackID != "my-id" {
+			t.Errorf("event %+v has buildpack ID %q, want my-id", e, e.BuildpackID)
+		}
+		got = append(got, e.Type+" "+e.Phase+e.Status+e.Message)
+	}
+	want := []string{"phaseStarted build", "warning careful with that", "phaseFinished buildINVALID_ARGUMENT"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("progress events = %q, want %q", got, want)
+	}
+}
+
+func TestProgressEventsDisabled(t *testing.T) {
+	ctx := NewContext(libcnb.BuildpackInfo{ID: "my-id"})
+
+	ctx.openProgress()
+	ctx.startPhase("build")
+	ctx.Warnf("careful")
+	ctx.finishPhase(StatusOk)
+
+	if ctx.progress != nil {
+		t.Errorf("progress stream opened without %s", env.ProgressPipe)
+	}
+}
+
+func TestProgressEventTruncated(t *testing.T) {
+	path, cleanUp := progressFile(t)
+	defer cleanUp()
+	ctx := NewContext(libcnb.BuildpackInfo{ID: "my-id"})
+	ctx.openProgress()
+
+	ctx.Warnf(strings.Repeat("é", maxProgressEventBytes))
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+	if len(raw) > maxProgressEventBytes {
+		t.Errorf("event is %d bytes, want at most %d", len(raw), maxProgressEventBytes)
+	}
+	events := readProgress(t, path)
+	if len(events) != 1 || !strings.HasSuffix(events[0].Message, "é...") {
+		t.Errorf("progress events = %+v, want one truncated warning", events)
+	}
+}
+
+func TestInstallArchiveReportsDownload(t *testing.T) {
+	path, cleanUp := progressFile(t)
+	defer cleanUp()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "200")
+	}))
+	defer server.Close()
+	x := &recordingExecutor{}
+	ctx := NewContext(libcnb.BuildpackInfo{ID: "my-id"})
+	WithExecutor(x)(ctx)
+	WithPlatform(Linux)(ctx)
+	ctx.openProgress()
+	url := server.URL + "/sdk.tar.xz"
+
+	ctx.InstallArchive(url, "/layer", 1)
+
+	var tools []string
+	for _, args := range x.commands {
+		tools = append(tools, args[0]+" "+args[1])
+	}
+	if want := []string{"curl --fail", "tar xJ"}; !reflect.DeepEqual(tools, want) {
+		t.Errorf("InstallArchive() ran %q, want %q", x.commands, want)
+	}
+	events := readProgress(t, path)
+	if len(events) != 2 {
+		t.Fatalf("progress events = %+v, want 2 download events", events)
+	}
+	for i, want := range []int{0, 100} {
+		if e := events[i]; e.Type != progressDownload || e.URL != url || e.TotalBytes != 200 || e.Percent == nil || *e.Percent != want {
+			t.Errorf("progress event %d = %+v, want download of %s at %d%% of 200 bytes", i, e, url, want)
+		}
+	}
+}
+
+func TestReportDownload(t *testing.T) {
+	path, cleanUp := progressFile(t)
+	defer cleanUp()
+	defer func(d time.Duration) { downloadProgressInterval = d }(downloadProgressInterval)
+	downloadProgressInterval = 10 * time.Millisecond
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+	}))
+	defer server.Close()
+	archive := filepath.Join(filepath.Dir(path), "archive")
+	ctx := NewContext(libcnb.BuildpackInfo{ID: "my-id"})
+	ctx.openProgress()
+
+	stop := ctx.reportDownload(server.URL, archive)
+	if err := ioutil.WriteFile(archive, make([]byte, 50), 0644); err != nil {
+		t.Fatalf("writing %s: %v", archive, err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	stop()
+
+	var got []int
+	for _, e := range readProgress(t, path) {
+		got = append(got, *e.Percent)
+	}
+	if want := []int{0, 50, 100}; !reflect.DeepEqual(got, want) {
+		t.Errorf("download progress = %v, want %v", got, want)
+	}
+}
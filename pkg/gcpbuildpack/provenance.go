@@ -0,0 +1,212 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// provenanceLayer is the launch layer in which each buildpack records what it wrote outside its layers.
+	provenanceLayer = "provenance"
+	provenanceFile  = "provenance.json"
+)
+
+// provenance records what a buildpack contributed to the image where its layer paths do not tell.
+type provenance struct {
+	// Buildpack is the id@version of the buildpack.
+	Buildpack string `json:"buildpack"`
+	// Application lists the files created or modified in the application directory.
+	Application []string `json:"application,omitempty"`
+	// Executables maps launch layers to the executables they add to PATH.
+	Executables map[string][]string `json:"executables,omitempty"`
+}
+
+// fileStamp identifies the content of a file without reading it.
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// appFiles returns the stamps of the regular files in the application directory.
+func (ctx *Context) appFiles() (map[string]fileStamp, error) {
+	files := map[string]fileStamp{}
+	root := ctx.ApplicationRoot()
+	err := ctx.fs.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files[rel] = fileStamp{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	return files, err
+}
+
+// recordProvenance writes the provenance of this buildpack to a launch layer, given the application
+// files from before the build, and warns about files that earlier buildpacks also wrote.
+func (ctx *Context) recordProvenance(before map[string]fileStamp) error {
+	if ctx.buildContext.Layers.Path == "" || before == nil {
+		return nil
+	}
+	after, err := ctx.appFiles()
+	if err != nil {
+		return fmt.Errorf("listing application files: %v", err)
+	}
+	p := provenance{
+		Buildpack:   fmt.Sprintf("%s@%s", ctx.BuildpackID(), ctx.BuildpackVersion()),
+		Executables: map[string][]string{},
+	}
+	for path, stamp := range after {
+		if prev, ok := before[path]; !ok || prev.size != stamp.size || !prev.modTime.Equal(stamp.modTime) {
+			p.Application = append(p.Application, path)
+		}
+	}
+	sort.Strings(p.Application)
+	for _, lc := range ctx.buildResult.Layers {
+		c, ok := lc.(layerContributor)
+		if !ok || !c.l.Launch || c.l.Name == provenanceLayer {
+			continue
+		}
+		if exes := ctx.pathExecutables(c.l.Path, c.l.LaunchEnvironment, c.l.SharedEnvironment); len(exes) > 0 {
+			p.Executables[c.l.Name] = exes
+		}
+	}
+	if len(p.Application) == 0 && len(p.Executables) == 0 {
+		return nil
+	}
+
+	earlier, err := ctx.earlierProvenance()
+	if err != nil {
+		return err
+	}
+	for _, c := range provenanceConflicts(earlier, p) {
+		ctx.Warnf("%s", c)
+	}
+
+	content, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling provenance: %v", err)
+	}
+	l := ctx.Layer(provenanceLayer, LaunchLayer)
+	fname := filepath.Join(l.Path, provenanceFile)
+	if err := ctx.fs.WriteFile(fname, content, 0644); err != nil {
+		return fmt.Errorf("writing %s: %v", fname, err)
+	}
+	return nil
+}
+
+// pathExecutables returns the names of the executables that a layer adds to PATH: those in its bin
+// directory, which the lifecycle adds to PATH, and in its own directories added to PATH explicitly.
+func (ctx *Context) pathExecutables(layerPath string, envs ...map[string]string) []string {
+	dirs := []string{filepath.Join(layerPath, "bin")}
+	for _, e := range envs {
+		for _, suffix := range []string{"", ".prepend", ".append", ".override"} {
+			for _, d := range filepath.SplitList(e["PATH"+suffix]) {
+				if d != dirs[0] && strings.HasPrefix(d+string(filepath.Separator), layerPath+string(filepath.Separator)) {
+					dirs = append(dirs, d)
+				}
+			}
+		}
+	}
+	var exes []string
+	for _, d := range dirs {
+		infos, err := ctx.fs.ReadDir(d)
+		if err != nil {
+			continue
+		}
+		for _, info := range infos {
+			if !info.IsDir() && info.Mode()&0111 != 0 {
+				exes = append(exes, info.Name())
+			}
+		}
+	}
+	sort.Strings(exes)
+	return exes
+}
+
+// earlierProvenance reads the provenance recorded by the buildpacks that ran before this one.
+func (ctx *Context) earlierProvenance() ([]provenance, error) {
+	pattern := filepath.Join(filepath.Dir(ctx.buildContext.Layers.Path), "*", provenanceLayer, provenanceFile)
+	paths, err := ctx.fs.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("finding provenance of other buildpacks: %v", err)
+	}
+	var result []provenance
+	for _, path := range paths {
+		if strings.HasPrefix(path, ctx.buildContext.Layers.Path+string(filepath.Separator)) {
+			continue
+		}
+		content, err := ctx.fs.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", path, err)
+		}
+		var p provenance
+		if err := json.Unmarshal(content, &p); err != nil {
+			ctx.Debugf("Ignoring unreadable provenance %s: %v", path, err)
+			continue
+		}
+		result = append(result, p)
+	}
+	return result, nil
+}
+
+// provenanceConflicts describes the application files and executables on PATH that both an earlier
+// buildpack and the current one contributed.
+func provenanceConflicts(earlier []provenance, current provenance) []string {
+	var conflicts []string
+	for _, e := range earlier {
+		files := map[string]bool{}
+		for _, f := range e.Application {
+			files[f] = true
+		}
+		for _, f := range current.Application {
+			if files[f] {
+				conflicts = append(conflicts, fmt.Sprintf("%s overwrote %s, which %s also wrote", current.Buildpack, f, e.Buildpack))
+			}
+		}
+		exes := map[string]string{}
+		for layer, names := range e.Executables {
+			for _, n := range names {
+				exes[n] = layer
+			}
+		}
+		var layers []string
+		for layer := range current.Executables {
+			layers = append(layers, layer)
+		}
+		sort.Strings(layers)
+		for _, layer := range layers {
+			for _, n := range current.Executables[layer] {
+				if other, ok := exes[n]; ok {
+					conflicts = append(conflicts, fmt.Sprintf("%s (layer %s) and %s (layer %s) both add %s to PATH", current.Buildpack, layer, e.Buildpack, other, n))
+				}
+			}
+		}
+	}
+	return conflicts
+}
@@ -0,0 +1,127 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/buildpacks/libcnb"
+)
+
+func TestProvenanceConflicts(t *testing.T) {
+	earlier := []provenance{
+		{Buildpack: "a@1", Application: []string{"node_modules/x.js", "out.txt"}},
+		{Buildpack: "b@1", Executables: map[string][]string{"bin": {"node", "tool"}}},
+	}
+	current := provenance{
+		Buildpack:   "c@1",
+		Application: []string{"other.txt", "out.txt"},
+		Executables: map[string][]string{"tools": {"tool"}},
+	}
+	want := []string{
+		"c@1 overwrote out.txt, which a@1 also wrote",
+		"c@1 (layer tools) and b@1 (layer bin) both add tool to PATH",
+	}
+	if got := provenanceConflicts(earlier, current); !reflect.DeepEqual(got, want) {
+		t.Errorf("provenanceConflicts() = %q, want %q", got, want)
+	}
+}
+
+func TestRecordProvenance(t *testing.T) {
+	root, err := ioutil.TempDir("", "provenance")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(root)
+	app := filepath.Join(root, "workspace")
+	layers := filepath.Join(root, "layers")
+	writeTestFile(t, filepath.Join(app, "unchanged.txt"), 0644)
+	writeTestFile(t, filepath.Join(app, "out.txt"), 0644)
+	earlier, err := json.Marshal(provenance{Buildpack: "earlier@1", Application: []string{"out.txt"}})
+	if err != nil {
+		t.Fatalf("marshalling provenance: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(layers, "earlier", provenanceLayer), 0755); err != nil {
+		t.Fatalf("creating dir: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(layers, "earlier", provenanceLayer, provenanceFile), earlier, 0644); err != nil {
+		t.Fatalf("writing provenance: %v", err)
+	}
+
+	var logs bytes.Buffer
+	ctx := newBuildContext(libcnb.BuildContext{
+		Application: libcnb.Application{Path: app},
+		Buildpack:   libcnb.Buildpack{Info: libcnb.BuildpackInfo{ID: "current", Version: "2"}},
+		Layers:      libcnb.Layers{Path: filepath.Join(layers, "current")},
+	}, WithLogger(log.New(&logs, "", 0)))
+	before, err := ctx.appFiles()
+	if err != nil {
+		t.Fatalf("appFiles() got error: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(app, "out.txt"), []byte("rewritten"), 0644); err != nil {
+		t.Fatalf("writing file: %v", err)
+	}
+	writeTestFile(t, filepath.Join(app, "dist", "new.js"), 0644)
+	bin := ctx.Layer("bin", LaunchLayer)
+	writeTestFile(t, filepath.Join(bin.Path, "main"), 0755)
+	bin.LaunchEnvironment.PrependPath("PATH", bin.Path)
+	tools := ctx.Layer("tools", LaunchLayer)
+	writeTestFile(t, filepath.Join(tools.Path, "bin", "tool"), 0755)
+	writeTestFile(t, filepath.Join(tools.Path, "bin", "README"), 0644)
+	build := ctx.Layer("build", BuildLayer)
+	writeTestFile(t, filepath.Join(build.Path, "bin", "compiler"), 0755)
+
+	if err := ctx.recordProvenance(before); err != nil {
+		t.Fatalf("recordProvenance() got error: %v", err)
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(layers, "current", provenanceLayer, provenanceFile))
+	if err != nil {
+		t.Fatalf("reading provenance: %v", err)
+	}
+	var got provenance
+	if err := json.Unmarshal(content, &got); err != nil {
+		t.Fatalf("unmarshalling provenance: %v", err)
+	}
+	want := provenance{
+		Buildpack:   "current@2",
+		Application: []string{"dist/new.js", "out.txt"},
+		Executables: map[string][]string{"bin": {"main"}, "tools": {"tool"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("provenance = %+v, want %+v", got, want)
+	}
+	if want := "current@2 overwrote out.txt, which earlier@1 also wrote"; !strings.Contains(logs.String(), want) {
+		t.Errorf("logs do not contain %q:\n%s", want, logs.String())
+	}
+}
+
+func writeTestFile(t *testing.T, path string, mode os.FileMode) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("creating dir: %v", err)
+	}
+	if err := ioutil.WriteFile(path, []byte(path), mode); err != nil {
+		t.Fatalf("writing file: %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// usage is the CPU time and peak memory used by a command, so that users can size build machines.
+type usage struct {
+	cpu time.Duration
+	// peakRSS is the peak resident set size in bytes, or 0 if it is not available on the platform.
+	peakRSS int64
+}
+
+// processUsage returns the resources used by an exited process. It is zero for commands that were not
+// run as processes, such as those of fake executors in tests.
+func processUsage(ps *os.ProcessState) usage {
+	if ps == nil {
+		return usage{}
+	}
+	return usage{cpu: ps.UserTime() + ps.SystemTime(), peakRSS: maxRSS(ps)}
+}
+
+func (u usage) String() string {
+	if u.peakRSS == 0 {
+		return fmt.Sprintf("CPU %v", u.cpu.Round(time.Millisecond))
+	}
+	return fmt.Sprintf("CPU %v, peak memory %s", u.cpu.Round(time.Millisecond), formatBytes(u.peakRSS))
+}
+
+// addUsage adds the CPU time of command cmd to the totals and records its peak memory if it is the
+// highest so far.
+func (s *stats) addUsage(cmd string, u usage) {
+	s.cpu += u.cpu
+	if u.peakRSS > s.peakRSS {
+		s.peakRSS = u.peakRSS
+		s.peakRSSCmd = cmd
+	}
+}
+
+// reportUsage logs the CPU time and peak memory of the commands run by the buildpack.
+func (ctx *Context) reportUsage() {
+	if ctx.stats.cpu == 0 {
+		return
+	}
+	if ctx.stats.peakRSS == 0 {
+		ctx.Logf("Commands used %v of CPU time", ctx.stats.cpu.Round(time.Millisecond))
+		return
+	}
+	ctx.Logf("Commands used %v of CPU time; peak memory %s (%s)", ctx.stats.cpu.Round(time.Millisecond), formatBytes(ctx.stats.peakRSS), ctx.stats.peakRSSCmd)
+}
+
+// formatBytes formats n bytes in binary units.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 3; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGT"[exp])
+}
@@ -0,0 +1,71 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestFormatBytes(t *testing.T) {
+	testCases := []struct {
+		n    int64
+		want string
+	}{
+		{n: 512, want: "512 B"},
+		{n: 1536, want: "1.5 KiB"},
+		{n: 300 * 1024 * 1024, want: "300.0 MiB"},
+		{n: 3 * 1024 * 1024 * 1024, want: "3.0 GiB"},
+	}
+	for _, tc := range testCases {
+		if got := formatBytes(tc.n); got != tc.want {
+			t.Errorf("formatBytes(%d) = %q, want %q", tc.n, got, tc.want)
+		}
+	}
+}
+
+func TestAddUsage(t *testing.T) {
+	var s stats
+	s.addUsage("go build", usage{cpu: 3 * time.Second, peakRSS: 400})
+	s.addUsage("npm ci", usage{cpu: 2 * time.Second, peakRSS: 900})
+	s.addUsage("cp", usage{cpu: time.Second, peakRSS: 100})
+
+	if s.cpu != 6*time.Second {
+		t.Errorf("cpu = %v, want %v", s.cpu, 6*time.Second)
+	}
+	if s.peakRSS != 900 || s.peakRSSCmd != "npm ci" {
+		t.Errorf("peak memory = %d (%s), want 900 (npm ci)", s.peakRSS, s.peakRSSCmd)
+	}
+}
+
+func TestProcessUsage(t *testing.T) {
+	if got := processUsage(nil); got != (usage{}) {
+		t.Errorf("processUsage(nil) = %v, want zero usage", got)
+	}
+
+	cmd := exec.Command("sh", "-c", "i=0; while [ $i -lt 20000 ]; do i=$((i+1)); done")
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("running command: %v", err)
+	}
+	got := processUsage(cmd.ProcessState)
+	if got.cpu <= 0 {
+		t.Errorf("processUsage() cpu = %v, want > 0", got.cpu)
+	}
+	if runtime.GOOS == "linux" && got.peakRSS <= 0 {
+		t.Errorf("processUsage() peak memory = %d, want > 0", got.peakRSS)
+	}
+}
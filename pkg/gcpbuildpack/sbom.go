@@ -0,0 +1,134 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// sbomSuffix is the suffix of the CycloneDX SBOM of a layer, next to the layer's TOML file, as
+	// defined by the buildpack specification.
+	sbomSuffix = ".sbom.cdx.json"
+	// toolsLayer is the launch layer that holds the SBOM of the tools in launch layers, so that it is
+	// part of the image.
+	toolsLayer = "sbom"
+	toolsFile  = "tools.cdx.json"
+)
+
+// tool is a tool, such as a language runtime, that a buildpack installed in a layer.
+type tool struct {
+	layer   *libcnb.Layer
+	name    string
+	version string
+}
+
+// cycloneDX is a CycloneDX 1.3 bill of materials.
+type cycloneDX struct {
+	BOMFormat   string               `json:"bomFormat"`
+	SpecVersion string               `json:"specVersion"`
+	Version     int                  `json:"version"`
+	Metadata    cycloneDXMetadata    `json:"metadata"`
+	Components  []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXMetadata struct {
+	Tools []cycloneDXTool `json:"tools"`
+}
+
+type cycloneDXTool struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type cycloneDXComponent struct {
+	Type       string              `json:"type"`
+	Name       string              `json:"name"`
+	Version    string              `json:"version"`
+	PURL       string              `json:"purl"`
+	Properties []cycloneDXProperty `json:"properties,omitempty"`
+}
+
+type cycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// AddTool records that the buildpack installed version of the tool name, such as go or npm, in layer
+// l, for the software bill of materials of the build tooling. It must also be called when the layer
+// is restored from the cache.
+func (ctx *Context) AddTool(l *libcnb.Layer, name, version string) {
+	ctx.tools = append(ctx.tools, tool{layer: l, name: name, version: version})
+}
+
+// writeSBOMs writes the SBOM of the tools in each layer next to the layer, and the SBOM of the tools
+// in launch layers to a launch layer.
+func (ctx *Context) writeSBOMs() error {
+	if len(ctx.tools) == 0 || ctx.buildContext.Layers.Path == "" {
+		return nil
+	}
+	byLayer := map[string][]tool{}
+	var launch []tool
+	for _, t := range ctx.tools {
+		byLayer[t.layer.Name] = append(byLayer[t.layer.Name], t)
+		if t.layer.Launch {
+			launch = append(launch, t)
+		}
+	}
+	for name, tools := range byLayer {
+		if err := ctx.writeSBOM(filepath.Join(ctx.buildContext.Layers.Path, name+sbomSuffix), tools); err != nil {
+			return err
+		}
+	}
+	if len(launch) == 0 {
+		return nil
+	}
+	l := ctx.Layer(toolsLayer, LaunchLayer)
+	return ctx.writeSBOM(filepath.Join(l.Path, toolsFile), launch)
+}
+
+func (ctx *Context) writeSBOM(path string, tools []tool) error {
+	bom := cycloneDX{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.3",
+		Version:     1,
+		Metadata:    cycloneDXMetadata{Tools: []cycloneDXTool{{Name: ctx.BuildpackID(), Version: ctx.BuildpackVersion()}}},
+	}
+	for _, t := range tools {
+		bom.Components = append(bom.Components, cycloneDXComponent{
+			Type:       "application",
+			Name:       t.name,
+			Version:    t.version,
+			PURL:       fmt.Sprintf("pkg:generic/%s@%s", t.name, t.version),
+			Properties: []cycloneDXProperty{{Name: "layer", Value: t.layer.Path}},
+		})
+	}
+	sort.Slice(bom.Components, func(i, j int) bool {
+		return bom.Components[i].Name < bom.Components[j].Name
+	})
+	content, err := json.MarshalIndent(bom, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling SBOM: %v", err)
+	}
+	if err := ctx.fs.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("writing %s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/buildpacks/libcnb"
+)
+
+func TestWriteSBOMs(t *testing.T) {
+	layers, err := ioutil.TempDir("", "layers")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(layers)
+	ctx := newBuildContext(libcnb.BuildContext{
+		Buildpack: libcnb.Buildpack{Info: libcnb.BuildpackInfo{ID: "my-buildpack", Version: "1.0.0"}},
+		Layers:    libcnb.Layers{Path: layers},
+	})
+	runtime := ctx.Layer("runtime", BuildLayer, LaunchLayer)
+	tools := ctx.Layer("tools", BuildLayer)
+	ctx.AddTool(runtime, "node", "14.15.0")
+	ctx.AddTool(runtime, "npm", "6.14.8")
+	ctx.AddTool(tools, "esbuild", "0.8.57")
+
+	if err := ctx.writeSBOMs(); err != nil {
+		t.Fatalf("writeSBOMs() got error: %v", err)
+	}
+
+	testCases := []struct {
+		path string
+		want []string
+	}{
+		{path: filepath.Join(layers, "runtime"+sbomSuffix), want: []string{"pkg:generic/node@14.15.0", "pkg:generic/npm@6.14.8"}},
+		{path: filepath.Join(layers, "tools"+sbomSuffix), want: []string{"pkg:generic/esbuild@0.8.57"}},
+		{path: filepath.Join(layers, toolsLayer, toolsFile), want: []string{"pkg:generic/node@14.15.0", "pkg:generic/npm@6.14.8"}},
+	}
+	for _, tc := range testCases {
+		content, err := ioutil.ReadFile(tc.path)
+		if err != nil {
+			t.Fatalf("reading SBOM: %v", err)
+		}
+		var bom cycloneDX
+		if err := json.Unmarshal(content, &bom); err != nil {
+			t.Fatalf("unmarshalling %s: %v", tc.path, err)
+		}
+		var got []string
+		for _, c := range bom.Components {
+			got = append(got, c.PURL)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s components = %q, want %q", tc.path, got, tc.want)
+		}
+		if want := []cycloneDXTool{{Name: "my-buildpack", Version: "1.0.0"}}; !reflect.DeepEqual(bom.Metadata.Tools, want) {
+			t.Errorf("%s tools = %v, want %v", tc.path, bom.Metadata.Tools, want)
+		}
+	}
+}
+
+func TestWriteSBOMsWithoutTools(t *testing.T) {
+	layers, err := ioutil.TempDir("", "layers")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(layers)
+	ctx := newBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layers}})
+	ctx.Layer("runtime", LaunchLayer)
+
+	if err := ctx.writeSBOMs(); err != nil {
+		t.Fatalf("writeSBOMs() got error: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(layers, "*"+sbomSuffix))
+	if err != nil {
+		t.Fatalf("listing SBOMs: %v", err)
+	}
+	if len(files) != 0 {
+		t.Errorf("writeSBOMs() wrote %v, want no SBOMs", files)
+	}
+	if _, err := os.Stat(filepath.Join(layers, toolsLayer)); !os.IsNotExist(err) {
+		t.Errorf("writeSBOMs() created layer %s, want none", toolsLayer)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+// Severity determines whether the failure of a build step fails the build.
+type Severity int
+
+const (
+	// Critical steps fail the build when they fail.
+	Critical Severity = iota
+	// Optional steps, such as SBOM generation, telemetry or license scans, only log a warning when they
+	// fail, unless strict mode is enabled with env.Strict.
+	Optional
+)
+
+func (s Severity) String() string {
+	if s == Optional {
+		return "optional"
+	}
+	return "critical"
+}
+
+// RunStep runs fn, which implements the named build step. Errors of critical steps, and of optional
+// steps in strict mode, are returned; errors of other optional steps are logged and recorded so that
+// the build can report what it skipped. Optional steps must report failures by returning an error
+// rather than calling Exit, for example by using ExecWithErr instead of Exec.
+func (ctx *Context) RunStep(name string, sev Severity, fn func() error) error {
+	if be := ctx.runStep(name, sev, fn); be != nil {
+		return be
+	}
+	return nil
+}
+
+func (ctx *Context) runStep(name string, sev Severity, fn func() error) *Error {
+	err := fn()
+	if err == nil {
+		return nil
+	}
+	if sev == Critical || ctx.strict {
+		var be *Error
+		if errors.As(err, &be) {
+			return be
+		}
+		return InternalErrorf("%s: %v", name, err)
+	}
+	ctx.Warnf("Skipping %s, which failed: %v (set %s=true to fail the build instead)", name, err, env.Strict)
+	ctx.stats.skipped = append(ctx.stats.skipped, name)
+	return nil
+}
+
+// reportSkippedSteps logs the optional steps that failed during the build.
+func (ctx *Context) reportSkippedSteps() {
+	if len(ctx.stats.skipped) == 0 {
+		return
+	}
+	ctx.Warnf("Build succeeded without %d optional step(s) that failed: %s", len(ctx.stats.skipped), strings.Join(ctx.stats.skipped, ", "))
+}
@@ -0,0 +1,97 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/buildpacks/libcnb"
+)
+
+func TestRunStep(t *testing.T) {
+	testCases := []struct {
+		name        string
+		severity    Severity
+		strict      bool
+		err         error
+		wantErr     bool
+		wantStatus  Status
+		wantSkipped []string
+	}{
+		{
+			name:     "critical success",
+			severity: Critical,
+		},
+		{
+			name:       "critical failure",
+			severity:   Critical,
+			err:        fmt.Errorf("boom"),
+			wantErr:    true,
+			wantStatus: StatusInternal,
+		},
+		{
+			name:       "critical failure keeps status",
+			severity:   Critical,
+			err:        UserErrorf("boom"),
+			wantErr:    true,
+			wantStatus: StatusUnknown,
+		},
+		{
+			name:     "optional success",
+			severity: Optional,
+		},
+		{
+			name:        "optional failure",
+			severity:    Optional,
+			err:         fmt.Errorf("boom"),
+			wantSkipped: []string{"my step"},
+		},
+		{
+			name:       "optional failure in strict mode",
+			severity:   Optional,
+			strict:     true,
+			err:        fmt.Errorf("boom"),
+			wantErr:    true,
+			wantStatus: StatusInternal,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := NewContext(libcnb.BuildpackInfo{ID: "id", Version: "version", Name: "name"})
+			ctx.strict = tc.strict
+
+			err := ctx.RunStep("my step", tc.severity, func() error { return tc.err })
+
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("RunStep() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				var be *Error
+				if !errors.As(err, &be) {
+					t.Fatalf("RunStep() got error %T, want *Error", err)
+				}
+				if be.Status != tc.wantStatus {
+					t.Errorf("RunStep() got status %v, want %v", be.Status, tc.wantStatus)
+				}
+			}
+			if !reflect.DeepEqual(ctx.stats.skipped, tc.wantSkipped) {
+				t.Errorf("skipped steps = %v, want %v", ctx.stats.skipped, tc.wantSkipped)
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// snapshotLayer is the cache layer in which the inputs of the previous build are recorded.
+	snapshotLayer = "build-snapshot"
+	snapshotFile  = "snapshot.json"
+)
+
+var (
+	// snapshotEnvPrefixes select the env vars recorded in a build snapshot.
+	snapshotEnvPrefixes = []string{"GOOGLE_", "GAE_"}
+
+	// snapshotManifests are the dependency manifests recorded in a build snapshot, relative to the application root.
+	snapshotManifests = []string{
+		"Gemfile", "Gemfile.lock", "Pipfile.lock", "build.gradle", "build.gradle.kts", "composer.json", "composer.lock",
+		"go.mod", "go.sum", "package-lock.json", "package.json", "pom.xml", "requirements.txt", "yarn.lock",
+	}
+
+	// manifestEntryParsers extract individual entries from manifests for which a count of changes is more useful than "changed".
+	manifestEntryParsers = map[string]entryParser{
+		"go.sum":           {noun: "module", parse: goSumEntries},
+		"requirements.txt": {noun: "requirement", parse: requirementsEntries},
+	}
+)
+
+type entryParser struct {
+	noun  string
+	parse func([]byte) []string
+}
+
+// buildSnapshot is a normalized record of the inputs of a build. Env var values and manifest entries
+// may hold credentials, such as tokens in index URLs, so only their SHA-256 is recorded.
+type buildSnapshot struct {
+	Buildpack string `json:"buildpack"`
+	// Env maps the recorded env vars to the SHA-256 of their values. It is nil for snapshots taken
+	// before values were hashed, which are not compared.
+	Env       map[string]string           `json:"env_sha256"`
+	Manifests map[string]manifestSnapshot `json:"manifests"`
+}
+
+type manifestSnapshot struct {
+	SHA256 string `json:"sha256"`
+	// Entries holds the SHA-256 of each entry of the manifest, if its entries are parsed.
+	Entries []string `json:"entry_sha256s,omitempty"`
+}
+
+// takeSnapshot records the inputs of the current build.
+func (ctx *Context) takeSnapshot() buildSnapshot {
+	s := buildSnapshot{
+		Buildpack: fmt.Sprintf("%s@%s", ctx.BuildpackID(), ctx.BuildpackVersion()),
+		Env:       map[string]string{},
+		Manifests: map[string]manifestSnapshot{},
+	}
+	for _, e := range os.Environ() {
+		kv := strings.SplitN(e, "=", 2)
+		for _, p := range snapshotEnvPrefixes {
+			if strings.HasPrefix(kv[0], p) && len(kv) == 2 {
+				s.Env[kv[0]] = sha256Hex([]byte(kv[1]))
+			}
+		}
+	}
+	for _, m := range snapshotManifests {
+		content, err := ioutil.ReadFile(filepath.Join(ctx.ApplicationRoot(), m))
+		if err != nil {
+			continue
+		}
+		ms := manifestSnapshot{SHA256: sha256Hex(content)}
+		if p, ok := manifestEntryParsers[m]; ok {
+			for _, e := range p.parse(content) {
+				ms.Entries = append(ms.Entries, sha256Hex([]byte(e)))
+			}
+		}
+		s.Manifests[m] = ms
+	}
+	return s
+}
+
+// reportSnapshotDiff logs how the inputs of the current build differ from those of the previous one,
+// naming changed env vars without their values. It is a no-op on the first build or when no layers
+// directory is available.
+func (ctx *Context) reportSnapshotDiff(current buildSnapshot) {
+	if ctx.buildContext.Layers.Path == "" {
+		return
+	}
+	content, err := ioutil.ReadFile(filepath.Join(ctx.buildContext.Layers.Path, snapshotLayer, snapshotFile))
+	if err != nil {
+		return
+	}
+	var previous buildSnapshot
+	if err := json.Unmarshal(content, &previous); err != nil {
+		ctx.Debugf("Ignoring unreadable build snapshot: %v", err)
+		return
+	}
+	diffs := diffSnapshots(previous, current)
+	if len(diffs) == 0 {
+		ctx.Debugf("Build inputs unchanged since the previous build")
+		return
+	}
+	ctx.Logf("Build inputs changed since the previous build:")
+	for _, d := range diffs {
+		ctx.Logf("  %s", d)
+	}
+}
+
+// saveSnapshot stores the snapshot in a cache layer for the next build to compare against.
+func (ctx *Context) saveSnapshot(s buildSnapshot) error {
+	if ctx.buildContext.Layers.Path == "" {
+		return nil
+	}
+	content, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("marshalling build snapshot: %v", err)
+	}
+	l := ctx.Layer(snapshotLayer, CacheLayer)
+	fname := filepath.Join(l.Path, snapshotFile)
+	if err := ctx.fs.WriteFile(fname, content, 0644); err != nil {
+		return fmt.Errorf("writing %s: %v", fname, err)
+	}
+	return nil
+}
+
+// diffSnapshots returns a human-readable, sorted list of differences between two snapshots.
+func diffSnapshots(previous, current buildSnapshot) []string {
+	var diffs []string
+	if previous.Buildpack != current.Buildpack {
+		diffs = append(diffs, fmt.Sprintf("buildpack changed %s→%s", previous.Buildpack, current.Buildpack))
+	}
+
+	var envDiffs []string
+	for k, v := range current.Env {
+		if previous.Env == nil {
+			break
+		}
+		prev, ok := previous.Env[k]
+		switch {
+		case !ok:
+			envDiffs = append(envDiffs, fmt.Sprintf("%s set", k))
+		case prev != v:
+			envDiffs = append(envDiffs, fmt.Sprintf("%s changed", k))
+		}
+	}
+	for k := range previous.Env {
+		if _, ok := current.Env[k]; !ok {
+			envDiffs = append(envDiffs, fmt.Sprintf("%s unset", k))
+		}
+	}
+	sort.Strings(envDiffs)
+	diffs = append(diffs, envDiffs...)
+
+	var manifestDiffs []string
+	for name, m := range current.Manifests {
+		prev, ok := previous.Manifests[name]
+		switch {
+		case !ok:
+			manifestDiffs = append(manifestDiffs, fmt.Sprintf("%s added", name))
+		case prev.SHA256 != m.SHA256:
+			manifestDiffs = append(manifestDiffs, describeManifestChange(name, prev, m))
+		}
+	}
+	for name := range previous.Manifests {
+		if _, ok := current.Manifests[name]; !ok {
+			manifestDiffs = append(manifestDiffs, fmt.Sprintf("%s removed", name))
+		}
+	}
+	sort.Strings(manifestDiffs)
+	return append(diffs, manifestDiffs...)
+}
+
+// describeManifestChange summarizes a changed manifest, counting added and removed entries when they are known.
+func describeManifestChange(name string, previous, current manifestSnapshot) string {
+	p, ok := manifestEntryParsers[name]
+	if !ok || previous.Entries == nil {
+		return fmt.Sprintf("%s changed", name)
+	}
+	prevSet := map[string]bool{}
+	for _, e := range previous.Entries {
+		prevSet[e] = true
+	}
+	curSet := map[string]bool{}
+	added := 0
+	for _, e := range current.Entries {
+		curSet[e] = true
+		if !prevSet[e] {
+			added++
+		}
+	}
+	removed := 0
+	for _, e := range previous.Entries {
+		if !curSet[e] {
+			removed++
+		}
+	}
+	var counts []string
+	if added > 0 {
+		counts = append(counts, "+"+pluralize(added, p.noun))
+	}
+	if removed > 0 {
+		counts = append(counts, "-"+pluralize(removed, p.noun))
+	}
+	if len(counts) == 0 {
+		return fmt.Sprintf("%s changed", name)
+	}
+	return fmt.Sprintf("%s changed: %s", name, strings.Join(counts, ", "))
+}
+
+func pluralize(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// goSumEntries returns the sorted "module version" pairs in a go.sum file, ignoring go.mod-only hashes.
+func goSumEntries(content []byte) []string {
+	var entries []string
+	for _, fields := range lineFields(content) {
+		if len(fields) < 2 || strings.HasSuffix(fields[1], "/go.mod") {
+			continue
+		}
+		entries = append(entries, fields[0]+" "+fields[1])
+	}
+	sort.Strings(entries)
+	return entries
+}
+
+// requirementsEntries returns the sorted, non-comment lines of a requirements.txt file.
+func requirementsEntries(content []byte) []string {
+	var entries []string
+	for _, fields := range lineFields(content) {
+		line := strings.Join(fields, " ")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	sort.Strings(entries)
+	return entries
+}
+
+func sha256Hex(content []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(content))
+}
+
+func lineFields(content []byte) [][]string {
+	var lines [][]string
+	s := bufio.NewScanner(bytes.NewReader(content))
+	for s.Scan() {
+		lines = append(lines, strings.Fields(s.Text()))
+	}
+	return lines
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+)
+
+// sourceSubdir returns the directory selected with env.SourceSubdir within the uploaded source root,
+// or root if none is selected.
+func sourceSubdir(root string) (string, *Error) {
+	sub := os.Getenv(env.SourceSubdir)
+	if sub == "" {
+		return root, nil
+	}
+	clean := filepath.Clean(sub)
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", UserErrorMsg(MsgSourceSubdirNotRelative, env.SourceSubdir, sub)
+	}
+	dir := filepath.Join(root, clean)
+	fi, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return "", UserErrorMsg(MsgSourceSubdirNotFound, env.SourceSubdir, sub)
+	}
+	if err != nil {
+		return "", Errorf(StatusInternal, "checking %s: %v", dir, err)
+	}
+	if !fi.IsDir() {
+		return "", UserErrorMsg(MsgSourceSubdirNotDir, env.SourceSubdir, sub)
+	}
+	return dir, nil
+}
+
+// useSourceSubdir makes the directory selected with env.SourceSubdir the application root and the
+// working directory, so that buildpacks that use either build only that directory.
+func (ctx *Context) useSourceSubdir() *Error {
+	dir, err := sourceSubdir(ctx.applicationRoot)
+	if err != nil {
+		return err
+	}
+	if dir == ctx.applicationRoot {
+		return nil
+	}
+	if err := os.Chdir(dir); err != nil {
+		return Errorf(StatusInternal, "changing directory to %s: %v", dir, err)
+	}
+	ctx.Debugf("Using %s as the application root", dir)
+	ctx.applicationRoot = dir
+	return nil
+}
+
+// startProcessesInSourceSubdir makes the processes of the build start in the application root when it
+// is a subdirectory of the source, since the launcher starts them in the source root.
+func (ctx *Context) startProcessesInSourceSubdir() {
+	if os.Getenv(env.SourceSubdir) == "" {
+		return
+	}
+	for i, p := range ctx.buildResult.Processes {
+		ctx.buildResult.Processes[i] = processInDir(p, ctx.applicationRoot)
+	}
+}
+
+// processInDir returns a process that changes to dir before running p. Processes of this buildpack
+// API have no working directory, so the process requires bash in the run image.
+func processInDir(p libcnb.Process, dir string) libcnb.Process {
+	if !p.Direct {
+		p.Command = "cd " + shellQuote(dir) + " && " + p.Command
+		return p
+	}
+	// bash sets $0 to the first argument after the script and "$@" to the rest.
+	p.Arguments = append([]string{"-c", `cd "$0" && exec "$@"`, dir, p.Command}, p.Arguments...)
+	p.Command = "/bin/bash"
+	return p
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,104 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestSourceSubdir(t *testing.T) {
+	root, err := ioutil.TempDir("", "source")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(root)
+	if err := os.MkdirAll(filepath.Join(root, "services", "api"), 0755); err != nil {
+		t.Fatalf("creating dir: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "go.mod"), []byte("module example.com/repo"), 0644); err != nil {
+		t.Fatalf("writing file: %v", err)
+	}
+
+	testCases := []struct {
+		name    string
+		subdir  string
+		want    string
+		wantErr bool
+	}{
+		{name: "unset", want: root},
+		{name: "subdir", subdir: "services/api", want: filepath.Join(root, "services", "api")},
+		{name: "unclean", subdir: "./services//api/", want: filepath.Join(root, "services", "api")},
+		{name: "parent", subdir: "../other", wantErr: true},
+		{name: "escapes", subdir: "services/../../other", wantErr: true},
+		{name: "absolute", subdir: "/services/api", wantErr: true},
+		{name: "missing", subdir: "services/web", wantErr: true},
+		{name: "file", subdir: "go.mod", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.subdir != "" {
+				os.Setenv(env.SourceSubdir, tc.subdir)
+				defer os.Unsetenv(env.SourceSubdir)
+			}
+
+			got, err := sourceSubdir(root)
+
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("sourceSubdir(%q) got error: %v, want error: %t", tc.subdir, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("sourceSubdir(%q) = %q, want %q", tc.subdir, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestProcessInDir(t *testing.T) {
+	testCases := []struct {
+		name    string
+		process libcnb.Process
+		want    libcnb.Process
+	}{
+		{
+			name:    "direct",
+			process: libcnb.Process{Type: "web", Command: "/layers/bin/main", Arguments: []string{"-port", "8080"}, Direct: true},
+			want: libcnb.Process{
+				Type:      "web",
+				Command:   "/bin/bash",
+				Arguments: []string{"-c", `cd "$0" && exec "$@"`, "/workspace/api", "/layers/bin/main", "-port", "8080"},
+				Direct:    true,
+			},
+		},
+		{
+			name:    "shell",
+			process: libcnb.Process{Type: "web", Command: "npm start"},
+			want:    libcnb.Process{Type: "web", Command: "cd '/workspace/api' && npm start"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := processInDir(tc.process, "/workspace/api"); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("processInDir() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/warmcache"
+)
+
+const (
+	// downloadLimitsFile is the file in env.DownloadSlotsDir holding the worker-wide download limits.
+	downloadLimitsFile = "limits.json"
+)
+
+var (
+	// slotPollInterval is how often a throttled download checks for a free slot.
+	slotPollInterval = 500 * time.Millisecond
+	// slotTimeout is how long a download waits for a free slot before it proceeds without one, so that
+	// a misconfigured slots dir slows builds down instead of failing them.
+	slotTimeout = 10 * time.Minute
+)
+
+// downloadLimits are the limits applied to the downloads of a build.
+type downloadLimits struct {
+	// slotsDir is the directory holding the slot lock files shared by the builds on the worker.
+	slotsDir string
+	// concurrency is the number of downloads that may run at once, or 0 if unlimited.
+	concurrency int
+	// rate is the bandwidth of each download in bytes per second, or 0 if unlimited.
+	rate int64
+}
+
+// workerLimits is the content of downloadLimitsFile.
+type workerLimits struct {
+	MaxConcurrency int    `json:"maxConcurrency"`
+	RateLimit      string `json:"rateLimit"`
+}
+
+// readDownloadLimits returns the worker-wide download limits lowered by the per-build overrides.
+func readDownloadLimits() (downloadLimits, *Error) {
+	var l downloadLimits
+	l.slotsDir = os.Getenv(env.DownloadSlotsDir)
+	if l.slotsDir != "" {
+		fname := filepath.Join(l.slotsDir, downloadLimitsFile)
+		raw, err := ioutil.ReadFile(fname)
+		if err != nil && !os.IsNotExist(err) {
+			return l, InternalErrorf("reading %s: %v", fname, err)
+		}
+		if err == nil {
+			var w workerLimits
+			if err := json.Unmarshal(raw, &w); err != nil {
+				return l, InternalErrorf("parsing %s: %v", fname, err)
+			}
+			if w.MaxConcurrency < 0 {
+				return l, InternalErrorf("invalid maxConcurrency %d in %s, must not be negative", w.MaxConcurrency, fname)
+			}
+			l.concurrency = w.MaxConcurrency
+			if w.RateLimit != "" {
+				if l.rate, err = parseRate(w.RateLimit); err != nil {
+					return l, InternalErrorf("parsing rateLimit in %s: %v", fname, err)
+				}
+			}
+		}
+	}
+
+	if v, ok := os.LookupEnv(env.DownloadConcurrency); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return l, UserErrorf("invalid %s %q, must be a positive integer", env.DownloadConcurrency, v)
+		}
+		if l.concurrency == 0 || n < l.concurrency {
+			l.concurrency = n
+		}
+	}
+	if v, ok := os.LookupEnv(env.DownloadRateLimit); ok {
+		rate, err := parseRate(v)
+		if err != nil {
+			return l, UserErrorf("parsing %s: %v", env.DownloadRateLimit, err)
+		}
+		if l.rate == 0 || rate < l.rate {
+			l.rate = rate
+		}
+	}
+	return l, nil
+}
+
+// parseRate parses a bandwidth in bytes per second with an optional K, M or G suffix, in the format
+// accepted by curl --limit-rate.
+func parseRate(s string) (int64, error) {
+	v := strings.TrimSpace(s)
+	mult := int64(1)
+	if v != "" {
+		switch strings.ToUpper(v[len(v)-1:]) {
+		case "K":
+			mult = 1 << 10
+		case "M":
+			mult = 1 << 20
+		case "G":
+			mult = 1 << 30
+		}
+		if mult > 1 {
+			v = v[:len(v)-1]
+		}
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid rate %q, must be a positive number of bytes per second with an optional K, M or G suffix", s)
+	}
+	return n * mult, nil
+}
+
+// DownloadSlot waits until the worker-wide download limits allow url to be downloaded. It returns the
+// curl arguments that limit the bandwidth of the download, and a function that must be called when the
+// download finishes. Archives in the warm cache are read without waiting.
+func (ctx *Context) DownloadSlot(url string) ([]string, func()) {
+	if ctx.FileExists(warmcache.ArchivePath(warmcache.Dir(), url)) {
+		return nil, func() {}
+	}
+	if ctx.downloadLimits == nil {
+		l, err := readDownloadLimits()
+		if err != nil {
+			ctx.Exit(1, err)
+		}
+		if l.concurrency > 0 && l.slotsDir == "" {
+			ctx.Warnf("Ignoring %s because %s is not set", env.DownloadConcurrency, env.DownloadSlotsDir)
+			l.concurrency = 0
+		}
+		ctx.downloadLimits = &l
+	}
+	l := ctx.downloadLimits
+	ctx.stats.downloads++
+
+	var args []string
+	if l.rate > 0 {
+		args = []string{"--limit-rate", strconv.FormatInt(l.rate, 10)}
+	}
+	if l.concurrency == 0 {
+		return args, func() {}
+	}
+
+	start := time.Now()
+	deadline := start.Add(slotTimeout)
+	waited := false
+	for {
+		for i := 0; i < l.concurrency; i++ {
+			release, ok, err := tryLock(filepath.Join(l.slotsDir, fmt.Sprintf("slot-%d.lock", i)))
+			if err != nil {
+				ctx.Warnf("Downloading %s without a download slot: %v", url, err)
+				return args, func() {}
+			}
+			if ok {
+				if waited {
+					wait := time.Since(start)
+					ctx.stats.throttled++
+					ctx.stats.downloadWait += wait
+					ctx.Debugf("Waited %v for a download slot for %s", wait.Round(time.Millisecond), url)
+				}
+				return args, release
+			}
+		}
+		if time.Now().After(deadline) {
+			ctx.Warnf("Downloading %s without a download slot after waiting %v", url, slotTimeout)
+			ctx.stats.throttled++
+			ctx.stats.downloadWait += time.Since(start)
+			return args, func() {}
+		}
+		time.Sleep(slotPollInterval)
+		waited = true
+	}
+}
+
+// reportDownloadThrottling logs how long downloads waited for the worker-wide download limits.
+func (ctx *Context) reportDownloadThrottling() {
+	if ctx.stats.throttled == 0 {
+		return
+	}
+	ctx.Logf("Waited %v for download slots for %d of %d download(s)", ctx.stats.downloadWait.Round(time.Millisecond), ctx.stats.throttled, ctx.stats.downloads)
+}
@@ -0,0 +1,193 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestParseRate(t *testing.T) {
+	testCases := []struct {
+		rate    string
+		want    int64
+		wantErr bool
+	}{
+		{rate: "2048", want: 2048},
+		{rate: "100K", want: 100 << 10},
+		{rate: "10m", want: 10 << 20},
+		{rate: "1G", want: 1 << 30},
+		{rate: "", wantErr: true},
+		{rate: "M", wantErr: true},
+		{rate: "0", wantErr: true},
+		{rate: "fast", wantErr: true},
+	}
+	for _, tc := range testCases {
+		got, err := parseRate(tc.rate)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("parseRate(%q) got error: %v, want error: %t", tc.rate, err, tc.wantErr)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("parseRate(%q) = %d, want %d", tc.rate, got, tc.want)
+		}
+	}
+}
+
+func TestReadDownloadLimits(t *testing.T) {
+	testCases := []struct {
+		name    string
+		limits  string
+		env     map[string]string
+		want    downloadLimits
+		wantErr bool
+	}{
+		{
+			name: "unlimited",
+		},
+		{
+			name:   "worker limits",
+			limits: `{"maxConcurrency": 4, "rateLimit": "20M"}`,
+			want:   downloadLimits{concurrency: 4, rate: 20 << 20},
+		},
+		{
+			name:   "overrides lower worker limits",
+			limits: `{"maxConcurrency": 4, "rateLimit": "20M"}`,
+			env:    map[string]string{env.DownloadConcurrency: "2", env.DownloadRateLimit: "5M"},
+			want:   downloadLimits{concurrency: 2, rate: 5 << 20},
+		},
+		{
+			name:   "overrides do not raise worker limits",
+			limits: `{"maxConcurrency": 4, "rateLimit": "20M"}`,
+			env:    map[string]string{env.DownloadConcurrency: "8", env.DownloadRateLimit: "1G"},
+			want:   downloadLimits{concurrency: 4, rate: 20 << 20},
+		},
+		{
+			name: "overrides without worker limits",
+			env:  map[string]string{env.DownloadConcurrency: "1", env.DownloadRateLimit: "100K"},
+			want: downloadLimits{concurrency: 1, rate: 100 << 10},
+		},
+		{
+			name:    "invalid concurrency",
+			env:     map[string]string{env.DownloadConcurrency: "0"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid rate",
+			env:     map[string]string{env.DownloadRateLimit: "fast"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid limits file",
+			limits:  `{"maxConcurrency": "four"}`,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "slots")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			if tc.limits != "" {
+				if err := ioutil.WriteFile(filepath.Join(dir, downloadLimitsFile), []byte(tc.limits), 0644); err != nil {
+					t.Fatalf("writing limits: %v", err)
+				}
+			}
+			vars := map[string]string{env.DownloadSlotsDir: dir}
+			for k, v := range tc.env {
+				vars[k] = v
+			}
+			for k, v := range vars {
+				defer os.Unsetenv(k)
+				if err := os.Setenv(k, v); err != nil {
+					t.Fatalf("setting %s: %v", k, err)
+				}
+			}
+
+			got, lerr := readDownloadLimits()
+			if gotErr := lerr != nil; gotErr != tc.wantErr {
+				t.Fatalf("readDownloadLimits() got error: %v, want error: %t", lerr, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			tc.want.slotsDir = dir
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("readDownloadLimits() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDownloadSlotWaitsForFreeSlot(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("downloads are only coordinated on Linux")
+	}
+	dir, err := ioutil.TempDir("", "slots")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Unsetenv(env.DownloadSlotsDir)
+	defer os.Unsetenv(env.DownloadRateLimit)
+	os.Setenv(env.DownloadSlotsDir, dir)
+	os.Setenv(env.DownloadRateLimit, "1M")
+	if err := ioutil.WriteFile(filepath.Join(dir, downloadLimitsFile), []byte(`{"maxConcurrency": 1}`), 0644); err != nil {
+		t.Fatalf("writing limits: %v", err)
+	}
+	defer func(d time.Duration) { slotPollInterval = d }(slotPollInterval)
+	slotPollInterval = 10 * time.Millisecond
+
+	// Another build holds the only slot for a while.
+	release, ok, err := tryLock(filepath.Join(dir, "slot-0.lock"))
+	if err != nil || !ok {
+		t.Fatalf("tryLock() = %t, %v, want true, nil", ok, err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		release()
+	}()
+
+	ctx := NewContext(libcnb.BuildpackInfo{ID: "my-id"})
+	args, done := ctx.DownloadSlot("https://example.com/runtime.tar.gz")
+	done()
+
+	if want := []string{"--limit-rate", "1048576"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("DownloadSlot() args = %q, want %q", args, want)
+	}
+	if ctx.stats.downloads != 1 || ctx.stats.throttled != 1 {
+		t.Errorf("downloads = %d, throttled = %d, want 1, 1", ctx.stats.downloads, ctx.stats.throttled)
+	}
+	if ctx.stats.downloadWait <= 0 {
+		t.Errorf("downloadWait = %v, want > 0", ctx.stats.downloadWait)
+	}
+
+	// The slot is free again, so the next download does not wait.
+	_, done = ctx.DownloadSlot("https://example.com/other.tar.gz")
+	done()
+	if ctx.stats.downloads != 2 || ctx.stats.throttled != 1 {
+		t.Errorf("downloads = %d, throttled = %d, want 2, 1", ctx.stats.downloads, ctx.stats.throttled)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"regexp"
+	"time"
+)
+
+const (
+	// maxTransientRetries is the number of times a command is retried after a transient error.
+	maxTransientRetries = 3
+)
+
+var (
+	// transientErrorRegexps match output of package managers and proxies that indicates a
+	// transient network or registry failure, as opposed to a problem with the user's code.
+	transientErrorRegexps = []*regexp.Regexp{
+		// Generic HTTP and network failures.
+		regexp.MustCompile(`\b(502 Bad Gateway|503 Service Unavailable|504 Gateway Time-?out)\b`),
+		regexp.MustCompile(`(?i)\b(connection reset by peer|TLS handshake timeout|i/o timeout|temporary failure in name resolution)\b`),
+		// npm and yarn.
+		regexp.MustCompile(`\b(ECONNRESET|ETIMEDOUT|EAI_AGAIN|ESOCKETTIMEDOUT)\b`),
+		regexp.MustCompile(`npm ERR! (code E50[234]|.*socket hang up)`),
+		// pip.
+		regexp.MustCompile(`\b(ReadTimeoutError|ConnectTimeoutError)\b`),
+		regexp.MustCompile(`Read timed out`),
+		// Go module proxy.
+		regexp.MustCompile(`reading https://proxy\.golang\.org/.*: 410 Gone`),
+		// A connection closed mid-response; unexpected EOF alone also ends deterministic failures such
+		// as syntax errors and truncated archives.
+		regexp.MustCompile(`(?:fetch|reading|Get) "?https?://\S+"?: .*unexpected EOF`),
+	}
+
+	// retryBackoff returns the time to wait before the given retry attempt (starting at 1).
+	// It can be overridden for testing.
+	retryBackoff = func(attempt int) time.Duration {
+		return time.Duration(attempt*attempt) * time.Second
+	}
+)
+
+// isTransientError returns true if the output of a failed command indicates a transient error.
+func isTransientError(output string) bool {
+	for _, re := range transientErrorRegexps {
+		if re.MatchString(output) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"testing"
+)
+
+func TestIsTransientError(t *testing.T) {
+	testCases := []struct {
+		name   string
+		output string
+		want   bool
+	}{
+		{
+			name:   "npm 503",
+			output: "npm ERR! code E503\nnpm ERR! 503 Service Unavailable - GET https://registry.npmjs.org/express",
+			want:   true,
+		},
+		{
+			name:   "npm connection reset",
+			output: "npm ERR! code ECONNRESET\nnpm ERR! network aborted",
+			want:   true,
+		},
+		{
+			name:   "pip read timeout",
+			output: "pip._vendor.urllib3.exceptions.ReadTimeoutError: HTTPSConnectionPool(host='files.pythonhosted.org', port=443): Read timed out.",
+			want:   true,
+		},
+		{
+			name:   "go proxy 410",
+			output: "go: example.com/foo@v1.0.0: reading https://proxy.golang.org/example.com/foo/@v/v1.0.0.mod: 410 Gone",
+			want:   true,
+		},
+		{
+			name:   "go tls timeout",
+			output: "go: github.com/foo/bar@v1.2.3: Get \"https://proxy.golang.org/github.com/foo/bar/@v/v1.2.3.zip\": net/http: TLS handshake timeout",
+			want:   true,
+		},
+		{
+			name:   "go download unexpected EOF",
+			output: "go: github.com/foo/bar@v1.2.3: Get \"https://proxy.golang.org/github.com/foo/bar/@v/v1.2.3.zip\": unexpected EOF",
+			want:   true,
+		},
+		{
+			name:   "go reading unexpected EOF",
+			output: "go: example.com/foo@v1.0.0: reading https://proxy.golang.org/example.com/foo/@v/v1.0.0.mod: unexpected EOF",
+			want:   true,
+		},
+		{
+			name:   "npm missing package",
+			output: "npm ERR! code E404\nnpm ERR! 404 Not Found - GET https://registry.npmjs.org/does-not-exist",
+			want:   false,
+		},
+		{
+			name:   "pip no matching distribution",
+			output: "ERROR: No matching distribution found for flask==99.0",
+			want:   false,
+		},
+		{
+			name:   "compile error",
+			output: "./main.go:10:2: undefined: foo",
+			want:   false,
+		},
+		{
+			name:   "syntax error unexpected EOF",
+			output: "./main.go:12:1: syntax error: unexpected EOF, expecting }",
+			want:   false,
+		},
+		{
+			name:   "truncated json",
+			output: "SyntaxError: Unexpected end of JSON input\nerror decoding package.json: unexpected EOF",
+			want:   false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isTransientError(tc.output); got != tc.want {
+				t.Errorf("isTransientError(%q) = %t, want %t", tc.output, got, tc.want)
+			}
+		})
+	}
+}
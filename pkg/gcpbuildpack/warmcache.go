@@ -0,0 +1,35 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"os"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/warmcache"
+)
+
+// useWarmCache points package managers at artifacts pre-populated in the builder image.
+// The environment is inherited by every command the buildpack executes.
+func (ctx *Context) useWarmCache() {
+	for _, e := range warmcache.Env(warmcache.Dir()) {
+		kv := strings.SplitN(e, "=", 2)
+		if err := os.Setenv(kv[0], kv[1]); err != nil {
+			ctx.Warnf("Not using warm cache for %s: %v", kv[0], err)
+			continue
+		}
+		ctx.Debugf("Using warm cache: %s", e)
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	defaultWorkstationCacheDir = "/var/cache/google-workstation"
+	detectMemoDir              = "detect"
+	// maxHashedFileSize is the size of the largest application file whose content is part of the
+	// detection key. Larger files, which detection does not read, are identified by their size.
+	maxHashedFileSize = 64 << 10
+)
+
+// detectMemo is the detection result of a buildpack in an earlier build of the same application
+// files and env vars, kept in the workstation cache directory.
+type detectMemo struct {
+	path string
+	key  string
+}
+
+// memoContent is the content of a detectMemo file.
+type memoContent struct {
+	Key   string             `json:"key"`
+	Pass  bool               `json:"pass"`
+	Plans []libcnb.BuildPlan `json:"plans,omitempty"`
+}
+
+// newDetectMemo returns the detection memo of the buildpack in workstation mode, or nil if workstation
+// mode is disabled or the cache directory is not mounted.
+func (ctx *Context) newDetectMemo() *detectMemo {
+	if !ctx.workstation {
+		return nil
+	}
+	dir := os.Getenv(env.WorkstationCacheDir)
+	if dir == "" {
+		dir = defaultWorkstationCacheDir
+	}
+	if _, err := ctx.fs.Stat(dir); err != nil {
+		ctx.Debugf("Not reusing detection results, %s is not available: %v", dir, err)
+		return nil
+	}
+	key, err := ctx.detectKey()
+	if err != nil {
+		ctx.Debugf("Not reusing detection results: %v", err)
+		return nil
+	}
+	return &detectMemo{path: filepath.Join(dir, detectMemoDir, ctx.info.ID+".json"), key: key}
+}
+
+// detectKey identifies what detection depends on: the buildpack, the GOOGLE_* env vars and the
+// application files.
+func (ctx *Context) detectKey() (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s@%s\n", ctx.info.ID, ctx.info.Version)
+	var vars []string
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, "GOOGLE_") {
+			vars = append(vars, kv)
+		}
+	}
+	sort.Strings(vars)
+	for _, kv := range vars {
+		fmt.Fprintln(h, kv)
+	}
+	root := ctx.ApplicationRoot()
+	// Walk visits files in lexical order.
+	err := ctx.fs.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			fmt.Fprintf(h, "%s %v\n", rel, info.Mode()&os.ModeType)
+			return nil
+		}
+		fmt.Fprintf(h, "%s %d\n", rel, info.Size())
+		if info.Size() <= maxHashedFileSize {
+			content, err := ctx.fs.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			h.Write(content)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("listing application files: %v", err)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// read returns the memoized detection result if it was recorded for the same key.
+func (m *detectMemo) read(ctx *Context) (libcnb.DetectResult, bool) {
+	if m == nil {
+		return libcnb.DetectResult{}, false
+	}
+	data, err := ctx.fs.ReadFile(m.path)
+	if err != nil {
+		return libcnb.DetectResult{}, false
+	}
+	var c memoContent
+	if err := json.Unmarshal(data, &c); err != nil {
+		ctx.Debugf("Ignoring detection result %s: %v", m.path, err)
+		return libcnb.DetectResult{}, false
+	}
+	if c.Key != m.key {
+		return libcnb.DetectResult{}, false
+	}
+	return libcnb.DetectResult{Pass: c.Pass, Plans: c.Plans}, true
+}
+
+// write memoizes the detection result. Failing to write it does not fail detection.
+func (m *detectMemo) write(ctx *Context, r libcnb.DetectResult) {
+	if m == nil {
+		return
+	}
+	data, err := json.Marshal(memoContent{Key: m.key, Pass: r.Pass, Plans: r.Plans})
+	if err == nil {
+		err = ctx.fs.MkdirAll(filepath.Dir(m.path), 0755)
+	}
+	if err == nil {
+		err = ctx.fs.WriteFile(m.path, data, 0644)
+	}
+	if err != nil {
+		ctx.Debugf("Not reusing the detection result in later builds: %v", err)
+	}
+}
+
+// detectMemoExiter memoizes the detection results of buildpacks that opt in or out by exiting.
+type detectMemoExiter struct {
+	ctx  *Context
+	memo *detectMemo
+	next Exiter
+}
+
+func (e detectMemoExiter) Exit(exitCode int, be *Error) {
+	if be == nil && (exitCode == passStatusCode || exitCode == failStatusCode) {
+		// Plans are not written when detection exits, so none are memoized.
+		e.memo.write(e.ctx, libcnb.DetectResult{Pass: exitCode == passStatusCode})
+	}
+	e.next.Exit(exitCode, be)
+}
@@ -0,0 +1,103 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestDetectMemo(t *testing.T) {
+	app, err := ioutil.TempDir("", "app")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(app)
+	cache, err := ioutil.TempDir("", "workstation")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(cache)
+	if err := ioutil.WriteFile(filepath.Join(app, "go.mod"), []byte("module example.com/app\n"), 0644); err != nil {
+		t.Fatalf("writing go.mod: %v", err)
+	}
+	for k, v := range map[string]string{env.WorkstationMode: "true", env.WorkstationCacheDir: cache} {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	runs := 0
+	detectFn := func(ctx *Context) error {
+		runs++
+		ctx.detectResult.Plans = []libcnb.BuildPlan{{Provides: []libcnb.BuildPlanProvide{{Name: "go"}}}}
+		return nil
+	}
+	detect := func() libcnb.DetectResult {
+		t.Helper()
+		ldctx := libcnb.DetectContext{
+			Application: libcnb.Application{Path: app},
+			Buildpack:   libcnb.Buildpack{Info: libcnb.BuildpackInfo{ID: "my-id", Version: "0.0.1"}},
+		}
+		r, err := RunDetect(ldctx, detectFn, WithLogger(log.New(ioutil.Discard, "", 0)))
+		if err != nil {
+			t.Fatalf("RunDetect() got error: %v", err)
+		}
+		return r
+	}
+
+	detect()
+	r := detect()
+	if runs != 1 {
+		t.Errorf("detect function ran %d times for unchanged files, want 1", runs)
+	}
+	if !r.Pass || len(r.Plans) != 1 || len(r.Plans[0].Provides) != 1 {
+		t.Errorf("memoized result = %+v, want a pass that provides go", r)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(app, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("writing main.go: %v", err)
+	}
+	detect()
+	if runs != 2 {
+		t.Errorf("detect function ran %d times after adding a file, want 2", runs)
+	}
+}
+
+func TestDetectMemoExiter(t *testing.T) {
+	cache, err := ioutil.TempDir("", "workstation")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(cache)
+	ctx := NewContext(libcnb.BuildpackInfo{ID: "my-id"})
+	memo := &detectMemo{path: filepath.Join(cache, "detect", "my-id.json"), key: "key"}
+	next := &fakeExiter{}
+
+	detectMemoExiter{ctx: ctx, memo: memo, next: next}.Exit(failStatusCode, nil)
+
+	if !next.called || next.code != failStatusCode {
+		t.Errorf("next exiter called = %t with code %d, want called with code %d", next.called, next.code, failStatusCode)
+	}
+	r, ok := memo.read(ctx)
+	if !ok || r.Pass {
+		t.Errorf("memo.read() = %+v, %t, want an opt out", r, ok)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// analysisLayer caches the staticcheck binary and the results of earlier analyses.
+	analysisLayer = "analysis"
+	// staticcheckModule is the package of the staticcheck command.
+	staticcheckModule = "honnef.co/go/tools/cmd/staticcheck"
+	// defaultStaticcheckVersion is the staticcheck release installed unless env.GoStaticcheckVersion is set.
+	defaultStaticcheckVersion = "2024.1.1"
+
+	analysisGoVersionKey  = "go_version"
+	staticcheckVersionKey = "staticcheck_version"
+)
+
+// Analyze runs `go vet` and staticcheck, as requested with env.GoVet and env.GoStaticcheck, on the
+// packages of the module in dir, and fails the build with user attribution on any finding. The
+// analyses run with buildEnv, the env vars of the build, so that they see the same files as the
+// build; the analysis layer provides GOCACHE unless buildEnv sets it.
+func Analyze(ctx *gcp.Context, dir string, buildEnv []string) error {
+	vet, err := env.Bool(env.GoVet, false)
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	staticcheck, err := env.Bool(env.GoStaticcheck, false)
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	if !vet && !staticcheck {
+		return nil
+	}
+
+	// The staticcheck binary and the cached results are only valid for the Go version that produced them.
+	l := ctx.Layer(analysisLayer, gcp.CacheLayer)
+	version := GoVersion(ctx)
+	if metaVersion := ctx.GetMetadata(l, analysisGoVersionKey); metaVersion != version {
+		if metaVersion != "" {
+			ctx.ExplainCacheMiss(analysisLayer, "Go version changed from %s to %s", metaVersion, version)
+		}
+		ctx.ClearLayer(l)
+		ctx.SetMetadata(l, analysisGoVersionKey, version)
+	}
+	cacheEnv := []string{
+		"GOCACHE=" + filepath.Join(l.Path, "gocache"),
+		// staticcheck caches its results in the user cache directory.
+		"XDG_CACHE_HOME=" + filepath.Join(l.Path, "cache"),
+	}
+	// Later env vars take precedence, so the GOCACHE of the build is used if it is set.
+	analysisEnv := append(append([]string{}, cacheEnv...), buildEnv...)
+
+	if vet {
+		ctx.Logf("Running go vet")
+		ctx.Exec([]string{"go", "vet", "./..."}, gcp.WithEnv(analysisEnv...), gcp.WithWorkDir(dir), gcp.WithCombinedTail, gcp.WithUserAttribution)
+	}
+	if staticcheck {
+		bin := installStaticcheck(ctx, l, cacheEnv)
+		ctx.Logf("Running staticcheck")
+		ctx.Exec([]string{bin, "./..."}, gcp.WithEnv(analysisEnv...), gcp.WithWorkDir(dir), gcp.WithCombinedTail, gcp.WithUserAttribution)
+	}
+	return nil
+}
+
+// installStaticcheck installs the staticcheck release requested with env.GoStaticcheckVersion into
+// the analysis layer l, unless it is cached, and returns the path of the binary.
+func installStaticcheck(ctx *gcp.Context, l *libcnb.Layer, cacheEnv []string) string {
+	version := os.Getenv(env.GoStaticcheckVersion)
+	if version == "" {
+		version = defaultStaticcheckVersion
+	}
+	bin := filepath.Join(l.Path, "bin", "staticcheck")
+	if ctx.GetMetadata(l, staticcheckVersionKey) == version && ctx.FileExists(bin) {
+		ctx.CacheHit(analysisLayer)
+		return bin
+	}
+	ctx.CacheMiss(analysisLayer)
+	ctx.Logf("Installing staticcheck %s", version)
+	// The tool is built for the build image, in the layer rather than the module, whatever the target of the app.
+	installEnv := append(append([]string{}, cacheEnv...), "GOBIN="+filepath.Dir(bin), "GOOS=", "GOARCH=", "GOFLAGS=", "CGO_ENABLED=0")
+	ctx.Exec([]string{"go", "install", staticcheckModule + "@" + version}, gcp.WithEnv(installEnv...), gcp.WithWorkDir(l.Path), gcp.WithTransientRetry, gcp.WithUserAttribution)
+	ctx.SetMetadata(l, staticcheckVersionKey, version)
+	return bin
+}
@@ -0,0 +1,150 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runner"
+	"github.com/buildpacks/libcnb"
+)
+
+// envExecutor records the commands it is asked to run and the GOCACHE they run with.
+type envExecutor struct {
+	commands [][]string
+	gocache  []string
+}
+
+func (e *envExecutor) Run(cmd *exec.Cmd) (int, error) {
+	e.commands = append(e.commands, cmd.Args)
+	// As with exec.Cmd, the last value of an env var takes precedence.
+	gocache := ""
+	for _, kv := range cmd.Env {
+		if strings.HasPrefix(kv, "GOCACHE=") {
+			gocache = strings.TrimPrefix(kv, "GOCACHE=")
+		}
+	}
+	e.gocache = append(e.gocache, gocache)
+	return 0, nil
+}
+
+func TestAnalyze(t *testing.T) {
+	testCases := []struct {
+		name         string
+		env          map[string]string
+		buildEnv     []string
+		cached       string
+		wantCommands []string
+		wantGOCACHE  string
+		wantErr      bool
+	}{
+		{
+			name: "disabled",
+		},
+		{
+			name:         "vet",
+			env:          map[string]string{"GOOGLE_GO_VET": "true"},
+			buildEnv:     []string{"GOCACHE=/layers/gocache"},
+			wantCommands: []string{"go vet ./..."},
+			wantGOCACHE:  "/layers/gocache",
+		},
+		{
+			name:         "staticcheck",
+			env:          map[string]string{"GOOGLE_GO_STATICCHECK": "1"},
+			wantCommands: []string{"go install honnef.co/go/tools/cmd/staticcheck@2024.1.1", "staticcheck ./..."},
+		},
+		{
+			name:         "cached staticcheck",
+			env:          map[string]string{"GOOGLE_GO_VET": "true", "GOOGLE_GO_STATICCHECK": "true"},
+			cached:       "2024.1.1",
+			wantCommands: []string{"go vet ./...", "staticcheck ./..."},
+		},
+		{
+			name:         "staticcheck version changed",
+			env:          map[string]string{"GOOGLE_GO_STATICCHECK": "true", "GOOGLE_GO_STATICCHECK_VERSION": "2023.1.7"},
+			cached:       "2024.1.1",
+			wantCommands: []string{"go install honnef.co/go/tools/cmd/staticcheck@2023.1.7", "staticcheck ./..."},
+		},
+		{
+			name:    "invalid",
+			env:     map[string]string{"GOOGLE_GO_VET": "always"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, k := range []string{"GOOGLE_GO_VET", "GOOGLE_GO_STATICCHECK", "GOOGLE_GO_STATICCHECK_VERSION"} {
+				defer os.Unsetenv(k)
+				os.Unsetenv(k)
+			}
+			for k, v := range tc.env {
+				os.Setenv(k, v)
+			}
+			defer func(fn func(*gcp.Context) string) { readGoVersion = fn }(readGoVersion)
+			readGoVersion = func(*gcp.Context) string { return "go version go1.22.3 linux/amd64" }
+			layers, err := ioutil.TempDir("", "layers")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(layers)
+			if tc.cached != "" {
+				toml := "cache = true\n\n[metadata]\n  go_version = \"1.22.3\"\n  staticcheck_version = \"" + tc.cached + "\"\n"
+				if err := ioutil.WriteFile(filepath.Join(layers, analysisLayer+".toml"), []byte(toml), 0644); err != nil {
+					t.Fatalf("writing layer metadata: %v", err)
+				}
+				bin := filepath.Join(layers, analysisLayer, "bin", "staticcheck")
+				if err := os.MkdirAll(filepath.Dir(bin), 0755); err != nil {
+					t.Fatalf("creating layer: %v", err)
+				}
+				if err := ioutil.WriteFile(bin, nil, 0755); err != nil {
+					t.Fatalf("writing staticcheck: %v", err)
+				}
+			}
+			executor := &envExecutor{}
+
+			_, err = runner.Build(runner.Config{
+				Buildpack:  libcnb.BuildpackInfo{ID: "google.go.build", Version: "0.0.1"},
+				LayersRoot: layers,
+				Executor:   executor,
+				Logger:     log.New(ioutil.Discard, "", 0),
+			}, func(ctx *gcp.Context) error {
+				return Analyze(ctx, "/workspace", tc.buildEnv)
+			})
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("Analyze() got error: %v, want error: %t", err, tc.wantErr)
+			}
+
+			var got []string
+			for _, c := range executor.commands {
+				c[0] = filepath.Base(c[0])
+				got = append(got, strings.Join(c, " "))
+			}
+			if !reflect.DeepEqual(got, tc.wantCommands) {
+				t.Errorf("Analyze() ran %q, want %q", got, tc.wantCommands)
+			}
+			if tc.wantGOCACHE != "" && executor.gocache[0] != tc.wantGOCACHE {
+				t.Errorf("Analyze() ran with GOCACHE=%q, want %q", executor.gocache[0], tc.wantGOCACHE)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	cgoLayer = "cgo"
+	// cgoToolchainURL is an archive of gcc, the libc headers and pkg-config built for a stack, with
+	// bin, include and lib directories at its root.
+	cgoToolchainURL = "https://storage.googleapis.com/gcp-buildpacks/cgo/cgo-toolchain-%s.tar.gz"
+	defaultStack    = "google"
+	stackKey        = "stack"
+)
+
+var (
+	// cgoTools are the tools that cgo runs to compile and link C code.
+	cgoTools = []string{"gcc", "pkg-config"}
+	// lookPath finds tools in the build image; tests replace it.
+	lookPath = exec.LookPath
+)
+
+// CgoSetting returns the value of CGO_ENABLED and whether it is set.
+func CgoSetting() (enabled, set bool, err error) {
+	_, set = os.LookupEnv("CGO_ENABLED")
+	enabled, err = env.Bool("CGO_ENABLED", false)
+	if err != nil {
+		return false, false, gcp.UserErrorf("%v", err)
+	}
+	return enabled, set, nil
+}
+
+// ProvisionCToolchain makes sure that the C toolchain required by cgo is available and returns the
+// env vars with which to run go build. The toolchain of the build image is used if it is complete;
+// otherwise, a toolchain built for the stack is installed into a cached build layer.
+func ProvisionCToolchain(ctx *gcp.Context) ([]string, error) {
+	var missing []string
+	for _, tool := range cgoTools {
+		if _, err := lookPath(tool); err != nil {
+			missing = append(missing, tool)
+		}
+	}
+	if len(missing) == 0 {
+		ctx.Debugf("Using the C toolchain of the build image")
+		return nil, nil
+	}
+
+	stack := os.Getenv("CNB_STACK_ID")
+	if stack == "" {
+		stack = defaultStack
+	}
+	l := ctx.Layer(cgoLayer, gcp.BuildLayer, gcp.CacheLayer)
+	if ctx.GetMetadata(l, stackKey) == stack {
+		ctx.CacheHit(cgoLayer)
+	} else {
+		ctx.CacheMiss(cgoLayer)
+		ctx.ClearLayer(l)
+		archiveURL := fmt.Sprintf(cgoToolchainURL, stack)
+		if code := ctx.HTTPStatus(archiveURL); code != http.StatusOK {
+			return nil, gcp.UserErrorf("the build image lacks %s, which cgo requires, and no C toolchain is available for stack %q at %s (status %d); use a builder image with gcc and pkg-config, or set CGO_ENABLED=0", strings.Join(missing, ", "), stack, archiveURL, code)
+		}
+		ctx.Logf("Installing a C toolchain for cgo, as the build image lacks %s", strings.Join(missing, ", "))
+		ctx.InstallArchive(archiveURL, l.Path, 1)
+		ctx.SetMetadata(l, stackKey, stack)
+	}
+
+	bin := filepath.Join(l.Path, "bin")
+	lib := filepath.Join(l.Path, "lib")
+	return []string{
+		"PATH=" + prependList(bin, os.Getenv("PATH")),
+		"CC=" + filepath.Join(bin, "gcc"),
+		"CPATH=" + prependList(filepath.Join(l.Path, "include"), os.Getenv("CPATH")),
+		"LIBRARY_PATH=" + prependList(lib, os.Getenv("LIBRARY_PATH")),
+		"PKG_CONFIG_PATH=" + prependList(filepath.Join(lib, "pkgconfig"), os.Getenv("PKG_CONFIG_PATH")),
+	}, nil
+}
+
+// prependList prepends dir to the path list list, which may be empty.
+func prependList(dir, list string) string {
+	if list == "" {
+		return dir
+	}
+	return dir + string(os.PathListSeparator) + list
+}
@@ -0,0 +1,148 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runner"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestCgoSetting(t *testing.T) {
+	testCases := []struct {
+		name        string
+		value       string
+		set         bool
+		wantEnabled bool
+		wantErr     bool
+	}{
+		{
+			name: "unset",
+		},
+		{
+			name:        "enabled",
+			value:       "1",
+			set:         true,
+			wantEnabled: true,
+		},
+		{
+			name:  "disabled",
+			value: "0",
+			set:   true,
+		},
+		{
+			name:    "invalid",
+			value:   "yes",
+			set:     true,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.set {
+				defer os.Unsetenv("CGO_ENABLED")
+				os.Setenv("CGO_ENABLED", tc.value)
+			}
+
+			enabled, set, err := CgoSetting()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("CgoSetting() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if enabled != tc.wantEnabled || set != tc.set {
+				t.Errorf("CgoSetting() = %t, %t, want %t, %t", enabled, set, tc.wantEnabled, tc.set)
+			}
+		})
+	}
+}
+
+func TestProvisionCToolchain(t *testing.T) {
+	testCases := []struct {
+		name       string
+		missing    bool
+		cachedFor  string
+		wantCached bool
+	}{
+		{
+			name: "build image toolchain",
+		},
+		{
+			name:       "cached toolchain",
+			missing:    true,
+			cachedFor:  defaultStack,
+			wantCached: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			layers, err := ioutil.TempDir("", "layers")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(layers)
+			if tc.cachedFor != "" {
+				if err := ioutil.WriteFile(filepath.Join(layers, cgoLayer+".toml"), []byte("[metadata]\nstack = \""+tc.cachedFor+"\"\n"), 0644); err != nil {
+					t.Fatalf("writing layer metadata: %v", err)
+				}
+			}
+			defer func(f func(string) (string, error)) { lookPath = f }(lookPath)
+			lookPath = func(tool string) (string, error) {
+				if tc.missing {
+					return "", errors.New("not found")
+				}
+				return "/usr/bin/" + tool, nil
+			}
+			executor := &goRootExecutor{}
+
+			var got []string
+			_, err = runner.Build(runner.Config{
+				Buildpack:  libcnb.BuildpackInfo{ID: "google.go.build", Version: "0.0.1"},
+				LayersRoot: layers,
+				Executor:   executor,
+				Logger:     log.New(ioutil.Discard, "", 0),
+			}, func(ctx *gcp.Context) error {
+				var err error
+				got, err = ProvisionCToolchain(ctx)
+				return err
+			})
+			if err != nil {
+				t.Fatalf("ProvisionCToolchain() got error: %v", err)
+			}
+
+			if len(executor.commands) != 0 {
+				t.Errorf("ProvisionCToolchain() ran %q, want no commands", executor.commands)
+			}
+			if !tc.wantCached {
+				if got != nil {
+					t.Errorf("ProvisionCToolchain() = %q, want no env vars", got)
+				}
+				return
+			}
+			cc := "CC=" + filepath.Join(layers, cgoLayer, "bin", "gcc")
+			if len(got) < 2 || got[1] != cc {
+				t.Errorf("ProvisionCToolchain() = %q, want %q", got, cc)
+			}
+		})
+	}
+}
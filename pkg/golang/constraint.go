@@ -0,0 +1,190 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+var (
+	// constraintTermRegexp parses one term of a version constraint, such as >=1.21, ~1.22 or 1.22.x.
+	constraintTermRegexp = regexp.MustCompile(`^(>=|<=|>|<|=|~|\^)?((?:\d+|x|\*)(?:\.(?:\d+|x|\*)){0,2})$`)
+
+	// operatorSpaceRegexp matches the spaces between an operator and its version, e.g. in ">= 1.21".
+	operatorSpaceRegexp = regexp.MustCompile(`([<>=~^]+)\s+`)
+)
+
+// bound is one comparison of a version constraint.
+type bound struct {
+	op      string
+	version string
+}
+
+func (b bound) check(v string) bool {
+	switch b.op {
+	case ">=":
+		return !VersionLess(v, b.version)
+	case ">":
+		return VersionLess(b.version, v)
+	case "<=":
+		return !VersionLess(b.version, v)
+	case "<":
+		return VersionLess(v, b.version)
+	}
+	return !VersionLess(v, b.version) && !VersionLess(b.version, v)
+}
+
+// Constraint is a set of Go releases, such as ~1.22 or >=1.21 <1.23.
+type Constraint struct {
+	expr   string
+	bounds []bound
+}
+
+func (c Constraint) String() string {
+	return c.expr
+}
+
+// Check returns true if the Go release v satisfies every term of the constraint. Pre-releases never
+// satisfy a constraint.
+func (c Constraint) Check(v string) bool {
+	if p := parseRelease(v); p == nil || releaseRegexp.FindStringSubmatch(v)[4] != "" {
+		return false
+	}
+	for _, b := range c.bounds {
+		if !b.check(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsConstraint returns true if s is a version constraint rather than an exact Go version.
+func IsConstraint(s string) bool {
+	return strings.ContainsAny(s, "<>=~^xX* ")
+}
+
+// ParseConstraint parses space-separated terms that must all hold. A term is a version prefixed with
+// one of >=, >, <=, < or =; ~1.22 (1.22 patch releases from 1.22.0); ^1.22 (1.x releases from 1.22.0);
+// or a wildcard such as 1.22.x or 1.x.
+func ParseConstraint(s string) (Constraint, error) {
+	c := Constraint{expr: s}
+	fields := strings.Fields(operatorSpaceRegexp.ReplaceAllString(s, "$1"))
+	if len(fields) == 0 {
+		return Constraint{}, fmt.Errorf("empty version constraint")
+	}
+	for _, f := range fields {
+		m := constraintTermRegexp.FindStringSubmatch(strings.ToLower(f))
+		if m == nil {
+			return Constraint{}, fmt.Errorf("invalid term %q in version constraint %q", f, s)
+		}
+		bs, err := termBounds(m[1], m[2])
+		if err != nil {
+			return Constraint{}, fmt.Errorf("invalid term %q in version constraint %q: %v", f, s, err)
+		}
+		c.bounds = append(c.bounds, bs...)
+	}
+	return c, nil
+}
+
+// termBounds returns the comparisons equivalent to a constraint term.
+func termBounds(op, version string) ([]bound, error) {
+	parts := strings.Split(version, ".")
+	wildcard := -1
+	for i, p := range parts {
+		if p == "x" || p == "*" {
+			wildcard = i
+			break
+		}
+	}
+	if wildcard >= 0 {
+		if op != "" && op != "=" {
+			return nil, fmt.Errorf("wildcards cannot be used with %s", op)
+		}
+		if wildcard == 0 {
+			// Any release.
+			return nil, nil
+		}
+		return rangeBounds(parts[:wildcard]), nil
+	}
+	switch op {
+	case "~":
+		if len(parts) == 1 {
+			return rangeBounds(parts), nil
+		}
+		return []bound{{">=", fullVersion(parts)}, {"<", nextVersion(parts[:2])}}, nil
+	case "^":
+		return []bound{{">=", fullVersion(parts)}, {"<", nextVersion(parts[:1])}}, nil
+	case "", "=":
+		if len(parts) < 3 {
+			// A partial version matches its patch releases, e.g. 1.22 matches 1.22.3.
+			return rangeBounds(parts), nil
+		}
+		return []bound{{"=", version}}, nil
+	}
+	return []bound{{op, fullVersion(parts)}}, nil
+}
+
+// rangeBounds returns the comparisons matching every release that starts with prefix.
+func rangeBounds(prefix []string) []bound {
+	return []bound{{">=", fullVersion(prefix)}, {"<", nextVersion(prefix)}}
+}
+
+// fullVersion pads a version to its patch version, e.g. 1.22 to 1.22.0.
+func fullVersion(parts []string) string {
+	p := append([]string{}, parts...)
+	for len(p) < 3 {
+		p = append(p, "0")
+	}
+	return strings.Join(p, ".")
+}
+
+// nextVersion returns the first version after every release that starts with prefix, e.g. 1.23.0 for 1.22.
+func nextVersion(prefix []string) string {
+	p := append([]string{}, prefix...)
+	var n int
+	fmt.Sscan(p[len(p)-1], &n)
+	p[len(p)-1] = fmt.Sprint(n + 1)
+	return fullVersion(p)
+}
+
+// BestMatch returns the newest of versions that satisfies c.
+func BestMatch(c Constraint, versions []string) (string, bool) {
+	best := ""
+	for _, v := range versions {
+		if c.Check(v) && (best == "" || VersionLess(best, v)) {
+			best = v
+		}
+	}
+	return best, best != ""
+}
+
+// GoModConstraint returns the Go releases to choose from to build the module in dir: the patch
+// releases of the language version of its go directive from the release it requires, e.g. >=1.22.0
+// <1.23.0 for `go 1.22`. If the toolchain directive requires a newer release, it returns that exact
+// version instead, which is not a constraint. It returns an empty string if the module declares
+// neither.
+func GoModConstraint(ctx *gcp.Context, dir string) string {
+	goVersion, toolchain := GoModDirectives(ctx, dir)
+	required := RequiredVersion(ctx, dir)
+	if goVersion == "" || required == toolchain {
+		return required
+	}
+	parts := strings.Split(goVersion, ".")
+	return fmt.Sprintf(">=%s <%s", required, nextVersion(parts[:2]))
+}
@@ -0,0 +1,141 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+var releases = []string{"1.23rc1", "1.22.3", "1.22.2", "1.22.0", "1.22rc1", "1.21.10", "1.21.0", "1.20.14", "1.14.15", "1.14"}
+
+func TestBestMatch(t *testing.T) {
+	testCases := []struct {
+		constraint string
+		want       string
+		wantErr    bool
+	}{
+		{constraint: "~1.22", want: "1.22.3"},
+		{constraint: "~1.21.5", want: "1.21.10"},
+		{constraint: "^1.20", want: "1.22.3"},
+		{constraint: "1.21.x", want: "1.21.10"},
+		{constraint: "1.x", want: "1.22.3"},
+		{constraint: "*", want: "1.22.3"},
+		{constraint: ">=1.21 <1.22", want: "1.21.10"},
+		{constraint: ">= 1.14, <1.15", wantErr: true},
+		{constraint: ">= 1.14 < 1.15", want: "1.14.15"},
+		{constraint: "<=1.22.2 >1.21", want: "1.22.2"},
+		{constraint: "=1.22.0", want: "1.22.0"},
+		{constraint: "~1.19", want: ""},
+		{constraint: ">=1.x", wantErr: true},
+		{constraint: "latest", wantErr: true},
+		{constraint: " ", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.constraint, func(t *testing.T) {
+			c, err := ParseConstraint(tc.constraint)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ParseConstraint(%q) got error: %v, want error: %t", tc.constraint, err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			got, ok := BestMatch(c, releases)
+			if got != tc.want || ok != (tc.want != "") {
+				t.Errorf("BestMatch(%q) = %q, %t, want %q", tc.constraint, got, ok, tc.want)
+			}
+		})
+	}
+}
+
+func TestIsConstraint(t *testing.T) {
+	testCases := []struct {
+		v    string
+		want bool
+	}{
+		{v: "1.14", want: false},
+		{v: "1.22.3", want: false},
+		{v: "1.22rc1", want: false},
+		{v: "~1.22", want: true},
+		{v: "1.22.x", want: true},
+		{v: ">=1.21 <1.23", want: true},
+	}
+	for _, tc := range testCases {
+		if got := IsConstraint(tc.v); got != tc.want {
+			t.Errorf("IsConstraint(%q) = %t, want %t", tc.v, got, tc.want)
+		}
+	}
+}
+
+func TestGoModConstraint(t *testing.T) {
+	testCases := []struct {
+		name  string
+		gomod string
+		want  string
+	}{
+		{
+			name:  "no directives",
+			gomod: "module example.com/fn\n",
+			want:  "",
+		},
+		{
+			name:  "go before 1.21",
+			gomod: "module example.com/fn\n\ngo 1.14\n",
+			want:  ">=1.14 <1.15.0",
+		},
+		{
+			name:  "go language version",
+			gomod: "module example.com/fn\n\ngo 1.22\n",
+			want:  ">=1.22.0 <1.23.0",
+		},
+		{
+			name:  "go release",
+			gomod: "module example.com/fn\n\ngo 1.22.2\n",
+			want:  ">=1.22.2 <1.23.0",
+		},
+		{
+			name:  "toolchain",
+			gomod: "module example.com/fn\n\ngo 1.21\n\ntoolchain go1.22.3\n",
+			want:  "1.22.3",
+		},
+		{
+			name:  "toolchain only",
+			gomod: "module example.com/fn\n\ntoolchain go1.22.3\n",
+			want:  "1.22.3",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "constraint")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte(tc.gomod), 0644); err != nil {
+				t.Fatalf("writing go.mod: %v", err)
+			}
+			ctx := gcp.NewContextForTests(libcnb.BuildpackInfo{}, dir)
+
+			if got := GoModConstraint(ctx, dir); got != tc.want {
+				t.Errorf("GoModConstraint() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+var (
+	boolValues = []string{"", "true", "false"}
+
+	// allowedGoFlags lists the flags that may be set in GOFLAGS and env.GoFlags, with the values they
+	// accept, or nil if they accept any value. Other flags, such as -o or -toolexec, would conflict
+	// with the flags that the buildpacks pass to the go command or change what they build.
+	allowedGoFlags = map[string][]string{
+		"asmflags":   nil,
+		"buildmode":  {"exe", "pie"},
+		"buildvcs":   {"true", "false", "auto"},
+		"gcflags":    nil,
+		"mod":        {"mod", "readonly", "vendor"},
+		"modcacherw": boolValues,
+		"p":          nil,
+		"pgo":        nil,
+		"tags":       nil,
+		"trimpath":   boolValues,
+		"v":          boolValues,
+		"x":          boolValues,
+	}
+
+	// goFlagHints explain how to get what some of the flags that are not allowed do.
+	goFlagHints = map[string]string{
+		"ldflags": "set linker flags with " + env.GoLinkerFlags + " instead, as the buildpacks pass their own -ldflags",
+		"o":       "the buildpacks choose where binaries are written; set " + env.Buildables + " to build several binaries",
+		"race":    "the race detector requires cgo and slows down the app",
+	}
+)
+
+// ValidateGoFlags returns a user error naming the flags of goFlags, the value of the env var name, that
+// are not in the allowlist of flags that builds may set.
+func ValidateGoFlags(name, goFlags string) error {
+	var problems []string
+	for _, f := range strings.Fields(goFlags) {
+		flag, value := splitGoFlag(f)
+		values, ok := allowedGoFlags[flag]
+		if !ok {
+			p := fmt.Sprintf("%s is not supported", f)
+			if hint, ok := goFlagHints[flag]; ok {
+				p += "; " + hint
+			}
+			problems = append(problems, p)
+			continue
+		}
+		if values != nil && !contains(values, value) {
+			var want []string
+			for _, v := range values {
+				if v == "" {
+					want = append(want, "-"+flag)
+				} else {
+					want = append(want, "-"+flag+"="+v)
+				}
+			}
+			problems = append(problems, fmt.Sprintf("%s must be one of %s", f, strings.Join(want, ", ")))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	var supported []string
+	for flag := range allowedGoFlags {
+		supported = append(supported, "-"+flag)
+	}
+	sort.Strings(supported)
+	return gcp.UserErrorf("invalid %s: %s; the supported flags are %s", name, strings.Join(problems, "; "), strings.Join(supported, ", "))
+}
+
+// GoFlagValue returns the value of the last flag named flag in goFlags, the value of GOFLAGS, and
+// whether it is set.
+func GoFlagValue(goFlags, flag string) (string, bool) {
+	value, found := "", false
+	for _, f := range strings.Fields(goFlags) {
+		if name, v := splitGoFlag(f); name == flag {
+			value, found = v, true
+		}
+	}
+	return value, found
+}
+
+// splitGoFlag returns the name and value of f, an element of GOFLAGS such as -mod=vendor.
+func splitGoFlag(f string) (string, string) {
+	f = strings.TrimPrefix(strings.TrimPrefix(f, "-"), "-")
+	if i := strings.Index(f, "="); i >= 0 {
+		return f[:i], f[i+1:]
+	}
+	return f, ""
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
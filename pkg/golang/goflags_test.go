@@ -0,0 +1,98 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateGoFlags(t *testing.T) {
+	testCases := []struct {
+		name    string
+		flags   string
+		wantErr string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:  "allowed",
+			flags: "-trimpath -buildvcs=false -mod=readonly --tags=netgo,prod -gcflags=all=-N -modcacherw=true",
+		},
+		{
+			name:    "not allowed",
+			flags:   "-trimpath -toolexec=/bin/sh",
+			wantErr: "-toolexec=/bin/sh is not supported",
+		},
+		{
+			name:    "hint",
+			flags:   "-ldflags=-s",
+			wantErr: "set linker flags with GOOGLE_GO_LDFLAGS",
+		},
+		{
+			name:    "invalid value",
+			flags:   "-mod=foo",
+			wantErr: "-mod=foo must be one of -mod=mod, -mod=readonly, -mod=vendor",
+		},
+		{
+			name:    "invalid bool",
+			flags:   "-trimpath=yes",
+			wantErr: "-trimpath=yes must be one of -trimpath, -trimpath=true, -trimpath=false",
+		},
+		{
+			name:    "value required",
+			flags:   "-buildmode",
+			wantErr: "-buildmode must be one of -buildmode=exe, -buildmode=pie",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateGoFlags("GOFLAGS", tc.flags)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateGoFlags(%q) got error: %v, want nil", tc.flags, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("ValidateGoFlags(%q) got error: %v, want error containing %q", tc.flags, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestGoFlagValue(t *testing.T) {
+	testCases := []struct {
+		flags     string
+		flag      string
+		want      string
+		wantFound bool
+	}{
+		{flags: "", flag: "mod"},
+		{flags: "-trimpath", flag: "mod"},
+		{flags: "-mod=readonly", flag: "mod", want: "readonly", wantFound: true},
+		{flags: "-mod=mod -trimpath --mod=vendor", flag: "mod", want: "vendor", wantFound: true},
+		{flags: "-trimpath", flag: "trimpath", wantFound: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.flags, func(t *testing.T) {
+			got, found := GoFlagValue(tc.flags, tc.flag)
+			if got != tc.want || found != tc.wantFound {
+				t.Errorf("GoFlagValue(%q, %q) = %q, %t, want %q, %t", tc.flags, tc.flag, got, found, tc.want, tc.wantFound)
+			}
+		})
+	}
+}
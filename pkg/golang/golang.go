@@ -36,6 +36,9 @@ var (
 
 	// goModVersionRegexp is used to get correct declaration of Go version from go.mod file.
 	goModVersionRegexp = regexp.MustCompile(`(?m)^\s*go\s+(\d+(\.\d+){1,2})\s*$`)
+
+	// goModModuleRegexp is used to get the module path from go.mod file.
+	goModModuleRegexp = regexp.MustCompile(`(?m)^\s*module\s+"?([^\s"]+)"?\s*(//.*)?$`)
 )
 
 // SupportsNoGoMod only returns true for Go version 1.11 and 1.13.
@@ -122,6 +125,25 @@ func GoModVersion(ctx *gcp.Context) string {
 	return match[1]
 }
 
+// ModulePath reads the module path from a go.mod file if present.
+// If not present or if the module path isn't there returns an empty string.
+func ModulePath(ctx *gcp.Context) string {
+	match := goModModuleRegexp.FindStringSubmatch(readGoMod(ctx))
+	if len(match) < 2 {
+		return ""
+	}
+	return match[1]
+}
+
+// PurgeModCache removes the module cache of gopath, whose files are read-only, so that a command
+// re-run with gcp.WithCorruptCacheRetry downloads its modules again.
+func PurgeModCache(ctx *gcp.Context, gopath string) error {
+	if _, err := ctx.ExecWithErr([]string{"go", "clean", "-modcache"}, gcp.WithEnv("GOPATH="+gopath)); err != nil {
+		return err
+	}
+	return nil
+}
+
 // readGoVersion returns the output of `go version`.
 // It can be overridden for testing.
 var readGoVersion = func(ctx *gcp.Context) string {
@@ -307,6 +307,50 @@ module dir
 	}
 }
 
+func TestModulePath(t *testing.T) {
+	testCases := []struct {
+		name  string
+		gomod string
+		want  string
+	}{
+		{
+			name:  "simple",
+			gomod: "module example.com/app\n\ngo 1.14\n",
+			want:  "example.com/app",
+		},
+		{
+			name:  "quoted with comment",
+			gomod: "// The app.\nmodule \"example.com/app/v2\" // v2\n",
+			want:  "example.com/app/v2",
+		},
+		{
+			name:  "no module",
+			gomod: "go 1.14\n",
+			want:  "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "modulepath")
+			if err != nil {
+				t.Fatalf("failing to create temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+
+			ctx := gcp.NewContextForTests(libcnb.BuildpackInfo{}, dir)
+
+			if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte(tc.gomod), 0644); err != nil {
+				t.Fatalf("writing go.mod: %v", err)
+			}
+
+			if got := ModulePath(ctx); got != tc.want {
+				t.Errorf("ModulePath() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
 func TestSupportsNoGoMod(t *testing.T) {
 	testCases := []struct {
 		goVersion string
@@ -0,0 +1,131 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// StampPackageEnv is an environment variable that buildpacks generating the main package use to
+	// name the package of the user's code, whose variables are stamped in addition to those of main.
+	StampPackageEnv = "GOOGLE_INTERNAL_GO_STAMP_PACKAGE"
+
+	// The string variables set by stamping, if the stamped packages declare them.
+	stampRevision         = "BuildRevision"
+	stampTime             = "BuildTime"
+	stampBuildpackVersion = "BuildpackVersion"
+)
+
+var (
+	// allowedLinkerFlags are the linker flags accepted in env.GoLinkerFlags, and whether they take a
+	// separate argument. Flags such as -extld, -linkmode or -H change how the binary is linked, or
+	// run other programs, so they are only available through env.GoLDFlags.
+	allowedLinkerFlags = map[string]bool{
+		"-s":             false,
+		"-w":             false,
+		"-X":             true,
+		"-buildid":       true,
+		"-compressdwarf": false,
+	}
+)
+
+// LinkerFlags returns the flags of env.GoLinkerFlags, split into arguments, after checking that
+// they only contain allowed flags. Arguments cannot contain spaces.
+func LinkerFlags() ([]string, error) {
+	fields := strings.Fields(os.Getenv(env.GoLinkerFlags))
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	for i := 0; i < len(fields); i++ {
+		flag, value, hasValue := fields[i], "", false
+		if j := strings.Index(flag, "="); j > 0 {
+			flag, value, hasValue = flag[:j], flag[j+1:], true
+		}
+		// Like other Go flags, linker flags may start with one or two dashes.
+		name := "-" + strings.TrimPrefix(strings.TrimPrefix(flag, "-"), "-")
+		takesArg, ok := allowedLinkerFlags[name]
+		if !ok || !strings.HasPrefix(flag, "-") {
+			return nil, gcp.UserErrorf("%s does not allow %q; allowed flags are -s, -w, -X importpath.name=value, -buildid and -compressdwarf, or use %s to pass other flags", env.GoLinkerFlags, fields[i], env.GoLDFlags)
+		}
+		if takesArg && !hasValue {
+			if i+1 == len(fields) {
+				return nil, gcp.UserErrorf("%s: %s requires an argument", env.GoLinkerFlags, flag)
+			}
+			i++
+			value = fields[i]
+		}
+		if name == "-X" && !strings.Contains(value, "=") {
+			return nil, gcp.UserErrorf("%s: -X %q must be of the form importpath.name=value", env.GoLinkerFlags, value)
+		}
+	}
+	return fields, nil
+}
+
+// StampFlags returns the -X linker flags that set the BuildRevision, BuildTime and BuildpackVersion
+// string variables of the main package, and of the package named by StampPackageEnv, to the VCS
+// revision of the source, the build time and the version of the buildpack. Variables that a package
+// does not declare are ignored by the linker. The revision is omitted if the source is not a git
+// checkout, and the build time is SOURCE_DATE_EPOCH, if set, for reproducible builds.
+func StampFlags(ctx *gcp.Context) ([]string, error) {
+	values := map[string]string{stampBuildpackVersion: ctx.BuildpackVersion()}
+	if rev := sourceRevision(ctx); rev != "" {
+		values[stampRevision] = rev
+	}
+	t := time.Now()
+	if v := os.Getenv("SOURCE_DATE_EPOCH"); v != "" {
+		sec, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, gcp.UserErrorf("parsing SOURCE_DATE_EPOCH: %v", err)
+		}
+		t = time.Unix(sec, 0)
+	}
+	values[stampTime] = t.UTC().Format(time.RFC3339)
+
+	pkgs := []string{"main"}
+	if p := os.Getenv(StampPackageEnv); p != "" {
+		pkgs = append(pkgs, p)
+	}
+	var flags []string
+	for _, p := range pkgs {
+		for _, name := range []string{stampRevision, stampTime, stampBuildpackVersion} {
+			if v, ok := values[name]; ok {
+				flags = append(flags, "-X", fmt.Sprintf("%s.%s=%s", p, name, v))
+			}
+		}
+	}
+	return flags, nil
+}
+
+// sourceRevision returns the git commit of the application source, or an empty string if it is not
+// a git checkout.
+func sourceRevision(ctx *gcp.Context) string {
+	if !ctx.FileExists(".git") {
+		return ""
+	}
+	result, err := ctx.ExecWithErr([]string{"git", "rev-parse", "HEAD"})
+	if err != nil {
+		ctx.Debugf("Not stamping the VCS revision: %v", err)
+		return ""
+	}
+	return strings.TrimSpace(result.Stdout)
+}
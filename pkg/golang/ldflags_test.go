@@ -0,0 +1,142 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestLinkerFlags(t *testing.T) {
+	testCases := []struct {
+		name    string
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "unset",
+		},
+		{
+			name:  "strip",
+			value: "-s -w",
+			want:  []string{"-s", "-w"},
+		},
+		{
+			name:  "set variables",
+			value: "-X main.env=prod --X=example.com/fn.region=us-central1",
+			want:  []string{"-X", "main.env=prod", "--X=example.com/fn.region=us-central1"},
+		},
+		{
+			name:  "build id",
+			value: "-buildid= -compressdwarf=false",
+			want:  []string{"-buildid=", "-compressdwarf=false"},
+		},
+		{
+			name:    "external linker",
+			value:   "-extld /tmp/ld",
+			wantErr: true,
+		},
+		{
+			name:    "link mode",
+			value:   "-s -linkmode=external",
+			wantErr: true,
+		},
+		{
+			name:    "missing -X argument",
+			value:   "-w -X",
+			wantErr: true,
+		},
+		{
+			name:    "invalid -X argument",
+			value:   "-X main.env",
+			wantErr: true,
+		},
+		{
+			name:    "not a flag",
+			value:   "main.env=prod",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer os.Unsetenv("GOOGLE_GO_LDFLAGS")
+			os.Setenv("GOOGLE_GO_LDFLAGS", tc.value)
+
+			got, err := LinkerFlags()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("LinkerFlags() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if !tc.wantErr && !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("LinkerFlags() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestStampFlags(t *testing.T) {
+	testCases := []struct {
+		name string
+		pkg  string
+		want []string
+	}{
+		{
+			name: "main",
+			want: []string{
+				"-X", "main.BuildTime=2020-05-06T07:33:34Z",
+				"-X", "main.BuildpackVersion=1.2.3",
+			},
+		},
+		{
+			name: "function package",
+			pkg:  "example.com/fn",
+			want: []string{
+				"-X", "main.BuildTime=2020-05-06T07:33:34Z",
+				"-X", "main.BuildpackVersion=1.2.3",
+				"-X", "example.com/fn.BuildTime=2020-05-06T07:33:34Z",
+				"-X", "example.com/fn.BuildpackVersion=1.2.3",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "stamp")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			defer os.Unsetenv("SOURCE_DATE_EPOCH")
+			os.Setenv("SOURCE_DATE_EPOCH", "1588750414")
+			if tc.pkg != "" {
+				defer os.Unsetenv(StampPackageEnv)
+				os.Setenv(StampPackageEnv, tc.pkg)
+			}
+			ctx := gcp.NewContextForTests(libcnb.BuildpackInfo{ID: "google.go.build", Version: "1.2.3"}, dir)
+
+			got, err := StampFlags(ctx)
+			if err != nil {
+				t.Fatalf("StampFlags() got error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("StampFlags() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
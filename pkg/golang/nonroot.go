@@ -0,0 +1,71 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// NonRootUID is the UID and GID of the user that compiled Go apps run as with env.GoNonRoot.
+	// It matches the nonroot user of distroless images.
+	NonRootUID = 65532
+	// NonRootUser is the name of the user that compiled Go apps run as with env.GoNonRoot.
+	NonRootUser = "nonroot"
+
+	nonRootLayer = "nonroot"
+	// nonRootHome is writable by any user, since the run image does not have a home directory for the user.
+	nonRootHome = "/tmp"
+
+	// runAsUserLabel and runAsNonRootLabel let platforms run the container as the user, for example
+	// with the runAsUser and runAsNonRoot security context fields of Kubernetes.
+	runAsUserLabel    = "run_as_user"
+	runAsNonRootLabel = "run_as_non_root"
+)
+
+// ConfigureNonRoot writes a minimal passwd and group with the non-root user into a launch layer, for
+// run images that lack the user, and labels the image so that platforms can run it as the user.
+// Programs that look up the current user find the entries through nss_wrapper, which reads the
+// NSS_WRAPPER_* env vars.
+func ConfigureNonRoot(ctx *gcp.Context) {
+	l := ctx.Layer(nonRootLayer, gcp.LaunchLayer)
+	etc := filepath.Join(l.Path, "etc")
+	ctx.MkdirAll(etc, 0755)
+	passwd := filepath.Join(etc, "passwd")
+	group := filepath.Join(etc, "group")
+	ctx.WriteFile(passwd, []byte(passwdContent()), 0644)
+	ctx.WriteFile(group, []byte(groupContent()), 0644)
+
+	l.LaunchEnvironment.Override("NSS_WRAPPER_PASSWD", passwd)
+	l.LaunchEnvironment.Override("NSS_WRAPPER_GROUP", group)
+	l.LaunchEnvironment.Default("USER", NonRootUser)
+	l.LaunchEnvironment.Default("HOME", nonRootHome)
+
+	ctx.AddLabel(runAsUserLabel, strconv.Itoa(NonRootUID))
+	ctx.AddLabel(runAsNonRootLabel, "true")
+	ctx.Logf("Configured the app to run as %s (UID %d)", NonRootUser, NonRootUID)
+}
+
+func passwdContent() string {
+	return fmt.Sprintf("root:x:0:0:root:/root:/sbin/nologin\n%s:x:%d:%d:%s:%s:/sbin/nologin\n", NonRootUser, NonRootUID, NonRootUID, NonRootUser, nonRootHome)
+}
+
+func groupContent() string {
+	return fmt.Sprintf("root:x:0:\n%s:x:%d:\n", NonRootUser, NonRootUID)
+}
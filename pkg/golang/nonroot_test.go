@@ -0,0 +1,74 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runner"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestConfigureNonRoot(t *testing.T) {
+	layers, err := ioutil.TempDir("", "layers")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(layers)
+
+	result, err := runner.Build(runner.Config{
+		Buildpack:  libcnb.BuildpackInfo{ID: "google.go.build", Version: "0.0.1"},
+		LayersRoot: layers,
+		Logger:     log.New(ioutil.Discard, "", 0),
+	}, func(ctx *gcp.Context) error {
+		ConfigureNonRoot(ctx)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ConfigureNonRoot() got error: %v", err)
+	}
+
+	passwd, err := ioutil.ReadFile(filepath.Join(layers, nonRootLayer, "etc", "passwd"))
+	if err != nil {
+		t.Fatalf("reading passwd: %v", err)
+	}
+	if want := "nonroot:x:65532:65532:nonroot:/tmp:/sbin/nologin\n"; !strings.HasSuffix(string(passwd), want) {
+		t.Errorf("passwd = %q, want suffix %q", passwd, want)
+	}
+	group, err := ioutil.ReadFile(filepath.Join(layers, nonRootLayer, "etc", "group"))
+	if err != nil {
+		t.Fatalf("reading group: %v", err)
+	}
+	if want := "nonroot:x:65532:\n"; !strings.HasSuffix(string(group), want) {
+		t.Errorf("group = %q, want suffix %q", group, want)
+	}
+
+	labels := map[string]string{}
+	for _, l := range result.Labels {
+		labels[l.Key] = l.Value
+	}
+	if got := labels["google.run-as-user"]; got != "65532" {
+		t.Errorf("google.run-as-user label = %q, want %q", got, "65532")
+	}
+	if got := labels["google.run-as-non-root"]; got != "true" {
+		t.Errorf("google.run-as-non-root label = %q, want %q", got, "true")
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// netrcBindingType is the binding type providing a .netrc file under the netrcKey key, with which
+	// the go command and git authenticate to the hosts of private modules.
+	netrcBindingType = "netrc"
+	netrcKey         = ".netrc"
+	// gitCredentialsBindingType is the binding type providing Git credentials, in git-credential
+	// format under the gitCredentialsKey key, as read by the utils/git-submodules buildpack.
+	gitCredentialsBindingType = "git-credentials"
+	gitCredentialsKey         = "credentials"
+)
+
+// UsePrivateModuleCredentials makes the credentials of netrc and git-credentials bindings, which
+// platforms provide from build secrets, available to the go commands run by the buildpack. The
+// credentials are copied to a temporary home directory outside of the application and layers, so
+// they never end up in the image; the returned func removes them and restores the environment.
+// Modules matching GOPRIVATE are fetched directly from their repositories with these credentials.
+func UsePrivateModuleCredentials(ctx *gcp.Context) func() {
+	netrc, hasNetrc := bindingSecret(ctx.Bindings(), netrcBindingType, netrcKey)
+	creds, hasCreds := bindingSecret(ctx.Bindings(), gitCredentialsBindingType, gitCredentialsKey)
+	if !hasNetrc && !hasCreds {
+		return func() {}
+	}
+	if private := os.Getenv("GOPRIVATE"); private != "" {
+		ctx.Logf("Using the provided credentials for private modules matching GOPRIVATE=%s", private)
+	} else {
+		ctx.Warnf("Credentials for private modules were provided, but GOPRIVATE is not set. Set GOPRIVATE to the paths of the private modules so that they are not looked up in the public module proxy and checksum database.")
+	}
+
+	home := ctx.TempDir("", "gohome")
+	vars := map[string]string{
+		"HOME":                home,
+		"GIT_TERMINAL_PROMPT": "0",
+	}
+	if hasNetrc {
+		// git reads ~/.netrc, and the go command reads $NETRC.
+		path := filepath.Join(home, ".netrc")
+		ctx.WriteFile(path, ctx.ReadFile(netrc), 0600)
+		vars["NETRC"] = path
+	}
+	if hasCreds {
+		ctx.WriteFile(filepath.Join(home, ".gitconfig"), []byte(gitCredentialsConfig(creds)), 0600)
+	}
+
+	restore := map[string]*string{}
+	for k, v := range vars {
+		if old, ok := os.LookupEnv(k); ok {
+			restore[k] = &old
+		} else {
+			restore[k] = nil
+		}
+		ctx.Setenv(k, v)
+	}
+	return func() {
+		ctx.RemoveAll(home)
+		for k, old := range restore {
+			if old == nil {
+				os.Unsetenv(k)
+			} else {
+				ctx.Setenv(k, *old)
+			}
+		}
+	}
+}
+
+// gitCredentialsConfig returns a git config that answers credential requests with the contents of
+// the git-credential file at path.
+func gitCredentialsConfig(path string) string {
+	return fmt.Sprintf("[credential]\n\thelper = \"!f() { test \\\"$1\\\" = get && cat '%s'; }; f\"\n", path)
+}
+
+// bindingSecret returns the path of the secret key of the first binding of type typ that has it.
+func bindingSecret(bindings libcnb.Bindings, typ, key string) (string, bool) {
+	for _, b := range bindings {
+		if b.Type != typ {
+			continue
+		}
+		if _, ok := b.Secret[key]; ok {
+			return filepath.Join(b.Path, key), true
+		}
+	}
+	return "", false
+}
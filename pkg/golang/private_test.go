@@ -0,0 +1,89 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runner"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestUsePrivateModuleCredentials(t *testing.T) {
+	layers, err := ioutil.TempDir("", "layers")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(layers)
+	bindings, err := ioutil.TempDir("", "bindings")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(bindings)
+	netrc := "machine git.example.com login me password secret\n"
+	if err := ioutil.WriteFile(filepath.Join(bindings, ".netrc"), []byte(netrc), 0600); err != nil {
+		t.Fatalf("writing .netrc: %v", err)
+	}
+	oldHome := os.Getenv("HOME")
+
+	var home, gotNetrc string
+	_, err = runner.Build(runner.Config{
+		Buildpack:  libcnb.BuildpackInfo{ID: "google.go.gomod", Version: "0.0.1"},
+		LayersRoot: layers,
+		Bindings: libcnb.Bindings{
+			{Name: "other", Type: "ca-certificates", Path: "/other", Secret: map[string]string{"ca.crt": ""}},
+			{Name: "netrc", Type: "netrc", Path: bindings, Secret: map[string]string{".netrc": netrc}},
+		},
+		Logger: log.New(ioutil.Discard, "", 0),
+	}, func(ctx *gcp.Context) error {
+		remove := UsePrivateModuleCredentials(ctx)
+		home = os.Getenv("HOME")
+		gotNetrc = string(ctx.ReadFile(os.Getenv("NETRC")))
+		remove()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UsePrivateModuleCredentials() got error: %v", err)
+	}
+
+	if gotNetrc != netrc {
+		t.Errorf("$NETRC contents = %q, want %q", gotNetrc, netrc)
+	}
+	if strings.HasPrefix(home, layers) {
+		t.Errorf("HOME = %q, want a directory outside of the layers", home)
+	}
+	if _, err := os.Stat(home); !os.IsNotExist(err) {
+		t.Errorf("credentials directory %s still exists after removal: %v", home, err)
+	}
+	if got := os.Getenv("HOME"); got != oldHome {
+		t.Errorf("HOME = %q after removal, want %q", got, oldHome)
+	}
+	if _, ok := os.LookupEnv("NETRC"); ok {
+		t.Errorf("NETRC is set after removal")
+	}
+}
+
+func TestGitCredentialsConfig(t *testing.T) {
+	want := "[credential]\n\thelper = \"!f() { test \\\"$1\\\" = get && cat '/bindings/git/credentials'; }; f\"\n"
+	if got := gitCredentialsConfig("/bindings/git/credentials"); got != want {
+		t.Errorf("gitCredentialsConfig() = %q, want %q", got, want)
+	}
+}
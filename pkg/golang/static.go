@@ -0,0 +1,83 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	staticLayer = "static"
+	// staticLabel tells platforms that the image only needs the launch layers, so that it can be
+	// rebased onto a scratch-like run image.
+	staticLabel = "go_static"
+	// runImageLabel names the run image that the image must be exported with or rebased onto.
+	runImageLabel = "run_image"
+
+	// MinimalRunImage is the run image of minimal mode. It is based on distroless/static, which has
+	// no shell or libc, so it only runs fully static binaries.
+	MinimalRunImage = "gcr.io/buildpacks/gcp/run-minimal:v1"
+)
+
+var (
+	// caCertsPath is the CA certificate bundle of the build image.
+	caCertsPath = "/etc/ssl/certs/ca-certificates.crt"
+	// staticTags select the pure Go implementations of the net and os/user packages, which otherwise
+	// use the libc resolver and user database when cgo is available.
+	staticTags = []string{"netgo", "osusergo"}
+)
+
+// StaticTagsFlag returns the -tags flag of a fully static build: the build tags set in GOFLAGS,
+// which a -tags flag on the command line replaces, followed by netgo and osusergo.
+func StaticTagsFlag() string {
+	return "-tags=" + strings.Join(mergeTags(goFlagsTags(os.Getenv("GOFLAGS")), staticTags), ",")
+}
+
+// CgoPackages returns the non-standard packages that buildables depend on and that use cgo, which
+// cannot be built into a static binary. Standard packages, such as net, fall back to pure Go.
+func CgoPackages(ctx *gcp.Context, dir string, buildables ...string) []string {
+	cmd := []string{"go", "list", "-deps", "-f", `{{if and .CgoFiles (not .Standard)}}{{.ImportPath}}{{end}}`}
+	result := ctx.Exec(append(cmd, buildables...), gcp.WithEnv("CGO_ENABLED=1"), gcp.WithWorkDir(dir), gcp.WithUserAttribution)
+	return strings.Fields(result.Stdout)
+}
+
+// ConfigureStaticImage copies the CA certificates of the build image and the time zone database of
+// the Go installation into a launch layer, the only files besides the binary that a static Go binary
+// reads from the image, and labels the image as static.
+func ConfigureStaticImage(ctx *gcp.Context) {
+	l := ctx.Layer(staticLayer, gcp.LaunchLayer)
+	if ctx.FileExists(caCertsPath) {
+		certs := filepath.Join(l.Path, "ca-certificates.crt")
+		ctx.Exec([]string{"cp", caCertsPath, certs})
+		l.LaunchEnvironment.Default("SSL_CERT_FILE", certs)
+	} else {
+		ctx.Warnf("Not adding CA certificates to the image: %s does not exist in the build image", caCertsPath)
+	}
+	addZoneInfo(ctx, l)
+	ctx.AddLabel(staticLabel, "true")
+	ctx.Logf("Configured a static image: the app only needs its launch layers to run")
+}
+
+// ConfigureMinimalImage configures a static image, see ConfigureStaticImage, and labels it with the
+// minimal run image, which platforms export the image with or rebase it onto.
+func ConfigureMinimalImage(ctx *gcp.Context) {
+	ConfigureStaticImage(ctx)
+	ctx.AddLabel(runImageLabel, MinimalRunImage)
+	ctx.Logf("The app only runs on the minimal run image %s; build it with `pack build --run-image %s`", MinimalRunImage, MinimalRunImage)
+}
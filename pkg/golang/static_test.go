@@ -0,0 +1,113 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runner"
+	"github.com/buildpacks/libcnb"
+)
+
+// goRootExecutor answers `go env GOROOT` with goRoot and records the other commands.
+type goRootExecutor struct {
+	goRoot   string
+	commands [][]string
+}
+
+func (e *goRootExecutor) Run(cmd *exec.Cmd) (int, error) {
+	if len(cmd.Args) == 3 && cmd.Args[1] == "env" {
+		fmt.Fprintln(cmd.Stdout, e.goRoot)
+		return 0, nil
+	}
+	e.commands = append(e.commands, cmd.Args)
+	return 0, nil
+}
+
+func TestConfigureStaticImage(t *testing.T) {
+	layers, err := ioutil.TempDir("", "layers")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(layers)
+	goRoot, err := ioutil.TempDir("", "goroot")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(goRoot)
+	zoneinfo := filepath.Join(goRoot, "lib", "time", "zoneinfo.zip")
+	if err := os.MkdirAll(filepath.Dir(zoneinfo), 0755); err != nil {
+		t.Fatalf("creating GOROOT: %v", err)
+	}
+	if err := ioutil.WriteFile(zoneinfo, []byte("zip"), 0644); err != nil {
+		t.Fatalf("writing zoneinfo.zip: %v", err)
+	}
+	defer func(p string) { caCertsPath = p }(caCertsPath)
+	caCertsPath = filepath.Join(goRoot, "missing.crt")
+	executor := &goRootExecutor{goRoot: goRoot}
+
+	result, err := runner.Build(runner.Config{
+		Buildpack:  libcnb.BuildpackInfo{ID: "google.go.build", Version: "0.0.1"},
+		LayersRoot: layers,
+		Executor:   executor,
+		Logger:     log.New(ioutil.Discard, "", 0),
+	}, func(ctx *gcp.Context) error {
+		ConfigureStaticImage(ctx)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ConfigureStaticImage() got error: %v", err)
+	}
+
+	tz := filepath.Join(layers, staticLayer, "zoneinfo.zip")
+	if len(executor.commands) != 1 || executor.commands[0][1] != zoneinfo || executor.commands[0][2] != tz {
+		t.Errorf("commands = %q, want only a copy of %s to %s", executor.commands, zoneinfo, tz)
+	}
+	labels := map[string]string{}
+	for _, l := range result.Labels {
+		labels[l.Key] = l.Value
+	}
+	if got := labels["google.go-static"]; got != "true" {
+		t.Errorf("google.go-static label = %q, want %q", got, "true")
+	}
+}
+
+func TestStaticTagsFlag(t *testing.T) {
+	testCases := []struct {
+		goFlags string
+		want    string
+	}{
+		{want: "-tags=netgo,osusergo"},
+		{goFlags: "-mod=vendor", want: "-tags=netgo,osusergo"},
+		{goFlags: "-tags=jsoniter -trimpath", want: "-tags=jsoniter,netgo,osusergo"},
+		{goFlags: "--tags=netgo", want: "-tags=netgo,osusergo"},
+	}
+	defer os.Unsetenv("GOFLAGS")
+	for _, tc := range testCases {
+		if err := os.Setenv("GOFLAGS", tc.goFlags); err != nil {
+			t.Fatalf("setting GOFLAGS: %v", err)
+		}
+		if got := StaticTagsFlag(); got != tc.want {
+			t.Errorf("StaticTagsFlag() with GOFLAGS=%q = %q, want %q", tc.goFlags, got, tc.want)
+		}
+	}
+}
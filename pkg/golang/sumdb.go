@@ -0,0 +1,168 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// sumDBFailure is a checksum verification failure reported by the go command.
+type sumDBFailure struct {
+	// marker identifies the failure in the output of the go command.
+	marker string
+	// remediation tells users how to fix the failure.
+	remediation string
+}
+
+var sumDBFailures = []sumDBFailure{
+	{
+		marker:      "SECURITY ERROR",
+		remediation: "The downloaded code of a module does not match its checksum in go.sum, which means the module was modified after go.sum was written or a version was re-tagged. Verify the module source, then run `go clean -modcache && go mod tidy` and commit go.sum.",
+	},
+	{
+		marker:      "checksum mismatch",
+		remediation: "The downloaded code of a module does not match its checksum in go.sum or the checksum database. Verify the module source, then run `go clean -modcache && go mod tidy` and commit go.sum.",
+	},
+	{
+		marker:      "missing go.sum entry",
+		remediation: "go.sum is missing the checksum of a module. Run `go mod tidy` and commit go.sum.",
+	},
+	{
+		marker:      "sum.golang.org/lookup",
+		remediation: "The checksum database has no entry for a module, which is typical of private modules. Add the module path to GONOSUMDB or GOPRIVATE for the build; for public modules, check that the version exists.",
+	},
+}
+
+// CheckSumDB warns about settings that bypass the checksum database for modules of the go.sum in dir,
+// and fails in strict mode.
+func CheckSumDB(ctx *gcp.Context, dir string) error {
+	strict, err := env.Bool(env.GoSumDBStrict, false)
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	var modules []string
+	if goSum := filepath.Join(dir, "go.sum"); ctx.FileExists(goSum) {
+		modules = goSumModules(string(ctx.ReadFile(goSum)))
+	}
+	bypasses := sumDBBypasses(os.Getenv, modules)
+	if len(bypasses) == 0 {
+		return nil
+	}
+	if strict {
+		return gcp.UserErrorf("the checksum database is bypassed, which %s forbids:\n  %s", env.GoSumDBStrict, strings.Join(bypasses, "\n  "))
+	}
+	for _, b := range bypasses {
+		ctx.Warnf("The checksum database is bypassed: %s", b)
+	}
+	return nil
+}
+
+// sumDBBypasses returns a description of every setting in getenv that bypasses the checksum database
+// for any of modules, with how to fix it.
+func sumDBBypasses(getenv func(string) string, modules []string) []string {
+	var bypasses []string
+	if v := getenv("GOSUMDB"); v == "off" {
+		bypasses = append(bypasses, "GOSUMDB=off disables verification of every module; unset GOSUMDB")
+	}
+	if v := getenv("GONOSUMCHECK"); v != "" && v != "0" {
+		bypasses = append(bypasses, fmt.Sprintf("GONOSUMCHECK=%s disables verification of every module; unset GONOSUMCHECK", v))
+	}
+	for _, name := range []string{"GONOSUMDB", "GOPRIVATE", "GOINSECURE"} {
+		patterns := getenv(name)
+		if patterns == "" {
+			continue
+		}
+		if matched := matchingModules(patterns, modules); len(matched) > 0 {
+			bypasses = append(bypasses, fmt.Sprintf("%s=%s skips verification of %s; only list private modules in %s", name, patterns, strings.Join(matched, ", "), name))
+		}
+	}
+	for _, f := range strings.Fields(getenv("GOFLAGS")) {
+		switch f {
+		case "-mod=mod":
+			bypasses = append(bypasses, "GOFLAGS=-mod=mod lets the build add modules missing from go.sum; run `go mod tidy`, commit go.sum and remove -mod=mod")
+		case "-insecure":
+			bypasses = append(bypasses, "GOFLAGS=-insecure skips verification of modules; remove -insecure")
+		}
+	}
+	return bypasses
+}
+
+// goSumModules returns the sorted module paths with a checksum in go.sum.
+func goSumModules(goSum string) []string {
+	seen := map[string]bool{}
+	var modules []string
+	for key := range parseGoSum(goSum) {
+		mod := strings.SplitN(key, " ", 2)[0]
+		if !seen[mod] {
+			seen[mod] = true
+			modules = append(modules, mod)
+		}
+	}
+	sort.Strings(modules)
+	return modules
+}
+
+// matchingModules returns the modules that match the comma-separated glob patterns the way the go
+// command matches GOPRIVATE, where a pattern matches any module whose path starts with matching
+// path elements.
+func matchingModules(patterns string, modules []string) []string {
+	var matched []string
+	for _, mod := range modules {
+		for _, p := range strings.Split(patterns, ",") {
+			if p = strings.TrimSpace(p); p != "" && matchPrefix(p, mod) {
+				matched = append(matched, mod)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+func matchPrefix(pattern, mod string) bool {
+	n := strings.Count(pattern, "/") + 1
+	elems := strings.Split(mod, "/")
+	if len(elems) < n {
+		return false
+	}
+	ok, err := path.Match(pattern, strings.Join(elems[:n], "/"))
+	return err == nil && ok
+}
+
+// SumDBMessage returns the output of a failed go command with how to fix the checksum verification
+// failure it reports, if any.
+func SumDBMessage(result *gcp.ExecResult) (string, bool) {
+	for _, f := range sumDBFailures {
+		if strings.Contains(result.Stderr, f.marker) {
+			return fmt.Sprintf("%s\n%s", gcp.KeepStderrTail(result), f.remediation), true
+		}
+	}
+	return "", false
+}
+
+// KeepStderrTailWithSumDBHelp is a gcp.MessageProducer that explains checksum verification failures.
+func KeepStderrTailWithSumDBHelp(result *gcp.ExecResult) string {
+	if msg, ok := SumDBMessage(result); ok {
+		return msg
+	}
+	return gcp.KeepStderrTail(result)
+}
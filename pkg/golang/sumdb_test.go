@@ -0,0 +1,130 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestSumDBBypasses(t *testing.T) {
+	modules := []string{"example.com/private/lib", "github.com/google/uuid", "golang.org/x/net"}
+	testCases := []struct {
+		name string
+		env  map[string]string
+		want []string
+	}{
+		{
+			name: "default",
+		},
+		{
+			name: "sumdb off",
+			env:  map[string]string{"GOSUMDB": "off"},
+			want: []string{"GOSUMDB=off"},
+		},
+		{
+			name: "private modules",
+			env:  map[string]string{"GOPRIVATE": "example.com/private,*.corp.example.com"},
+			want: []string{"GOPRIVATE=example.com/private,*.corp.example.com skips verification of example.com/private/lib;"},
+		},
+		{
+			name: "no matching modules",
+			env:  map[string]string{"GONOSUMDB": "*.corp.example.com"},
+		},
+		{
+			name: "public modules excluded",
+			env:  map[string]string{"GONOSUMDB": "github.com/google,example.com/*"},
+			want: []string{"GONOSUMDB=github.com/google,example.com/* skips verification of example.com/private/lib, github.com/google/uuid"},
+		},
+		{
+			name: "goflags",
+			env:  map[string]string{"GOFLAGS": "-mod=mod -trimpath"},
+			want: []string{"GOFLAGS=-mod=mod"},
+		},
+		{
+			name: "nosumcheck",
+			env:  map[string]string{"GONOSUMCHECK": "1", "GOINSECURE": "golang.org"},
+			want: []string{"GONOSUMCHECK=1", "GOINSECURE=golang.org skips verification of golang.org/x/net"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := sumDBBypasses(func(k string) string { return tc.env[k] }, modules)
+
+			if len(got) != len(tc.want) {
+				t.Fatalf("sumDBBypasses() = %q, want %d bypasses starting with %q", got, len(tc.want), tc.want)
+			}
+			for i, w := range tc.want {
+				if !strings.HasPrefix(got[i], w) {
+					t.Errorf("sumDBBypasses()[%d] = %q, want prefix %q", i, got[i], w)
+				}
+			}
+		})
+	}
+}
+
+func TestGoSumModules(t *testing.T) {
+	goSum := `github.com/google/uuid v1.1.2 h1:EVhdT+1Kseyi1/pUmXKaFxYsDNy9RQYkMWRH68J/W7Y=
+github.com/google/uuid v1.1.2/go.mod h1:TIyPZe4MgqvfeYDBFedMoGGpEw/LqOeaOT+nhxU+yHo=
+golang.org/x/net v0.0.0-20200822124328-c89045814202/go.mod h1:/O7V0waA8r7cgGh81Ro3o1hOxt32SMVPicZroKQ2sZA=
+`
+	want := []string{"github.com/google/uuid", "golang.org/x/net"}
+	if got := goSumModules(goSum); !reflect.DeepEqual(got, want) {
+		t.Errorf("goSumModules() = %q, want %q", got, want)
+	}
+}
+
+func TestSumDBMessage(t *testing.T) {
+	testCases := []struct {
+		name   string
+		stderr string
+		want   string
+	}{
+		{
+			name:   "security error",
+			stderr: "verifying github.com/google/uuid@v1.1.2: checksum mismatch\n\tdownloaded: h1:abc\n\tgo.sum:     h1:def\n\nSECURITY ERROR\nThis download does NOT match an earlier download recorded in go.sum.",
+			want:   "was re-tagged",
+		},
+		{
+			name:   "missing entry",
+			stderr: "main.go:4:2: missing go.sum entry for module providing package github.com/google/uuid",
+			want:   "Run `go mod tidy` and commit go.sum.",
+		},
+		{
+			name:   "private module",
+			stderr: "verifying example.com/private/lib@v1.0.0: reading https://sum.golang.org/lookup/example.com/private/lib@v1.0.0: 410 Gone",
+			want:   "GONOSUMDB",
+		},
+		{
+			name:   "compile error",
+			stderr: "./main.go:10:2: undefined: foo",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := SumDBMessage(&gcp.ExecResult{ExitCode: 1, Stderr: tc.stderr})
+
+			if ok != (tc.want != "") {
+				t.Fatalf("SumDBMessage() ok = %t, want %t", ok, tc.want != "")
+			}
+			if !strings.Contains(got, tc.want) || (ok && !strings.Contains(got, tc.stderr[:20])) {
+				t.Errorf("SumDBMessage() = %q, want the output and %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+var (
+	// buildTagRegexp matches the build tags accepted by the go command.
+	buildTagRegexp = regexp.MustCompile(`^[A-Za-z0-9_.]+$`)
+)
+
+// BuildTags returns the build tags requested with env.GoBuildTags, which are separated by commas or
+// spaces, as with the -tags flag.
+func BuildTags() ([]string, error) {
+	v := os.Getenv(env.GoBuildTags)
+	if strings.TrimSpace(v) == "" {
+		return nil, nil
+	}
+	tags := strings.FieldsFunc(v, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+	for _, tag := range tags {
+		if !buildTagRegexp.MatchString(tag) {
+			return nil, gcp.UserErrorf("invalid build tag %q in %s; build tags may only contain letters, digits, '_' and '.'", tag, env.GoBuildTags)
+		}
+	}
+	return tags, nil
+}
+
+// withTags returns goFlags with a single -tags flag that enables tags in addition to the build tags
+// already set in goFlags. The go command only uses the last -tags flag, so appending one would drop
+// the tags that goFlags sets.
+func withTags(goFlags string, tags []string) string {
+	var flags []string
+	for _, f := range strings.Fields(goFlags) {
+		if !isTagsFlag(f) {
+			flags = append(flags, f)
+		}
+	}
+	merged := mergeTags(goFlagsTags(goFlags), tags)
+	return strings.Join(append(flags, "-tags="+strings.Join(merged, ",")), " ")
+}
+
+// goFlagsTags returns the build tags set in goFlags, the value of GOFLAGS.
+func goFlagsTags(goFlags string) []string {
+	var tags []string
+	for _, f := range strings.Fields(goFlags) {
+		if isTagsFlag(f) {
+			tags = strings.Split(f[strings.Index(f, "=")+1:], ",")
+		}
+	}
+	return tags
+}
+
+// isTagsFlag returns true if f, an element of GOFLAGS, is a -tags flag.
+func isTagsFlag(f string) bool {
+	f = strings.TrimPrefix(f, "-")
+	return strings.HasPrefix(f, "-tags=") || strings.HasPrefix(f, "tags=")
+}
+
+// mergeTags returns tags followed by the extra tags that it does not contain.
+func mergeTags(tags, extra []string) []string {
+	for _, t := range extra {
+		found := false
+		for _, tag := range tags {
+			found = found || tag == t
+		}
+		if !found {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
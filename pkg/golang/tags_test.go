@@ -0,0 +1,67 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+func TestBuildTags(t *testing.T) {
+	testCases := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{},
+		{value: "prod", want: []string{"prod"}},
+		{value: "timetzdata,prod", want: []string{"timetzdata", "prod"}},
+		{value: " timetzdata prod, go1.x ", want: []string{"timetzdata", "prod", "go1.x"}},
+		{value: "prod,-race", wantErr: true},
+		{value: "prod=true", wantErr: true},
+	}
+	defer os.Unsetenv(env.GoBuildTags)
+	for _, tc := range testCases {
+		os.Setenv(env.GoBuildTags, tc.value)
+		got, err := BuildTags()
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("BuildTags() with %s=%q got error: %v, want error: %t", env.GoBuildTags, tc.value, err, tc.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("BuildTags() with %s=%q = %q, want %q", env.GoBuildTags, tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestWithTags(t *testing.T) {
+	testCases := []struct {
+		goFlags string
+		tags    []string
+		want    string
+	}{
+		{tags: []string{"prod"}, want: "-tags=prod"},
+		{goFlags: "-mod=vendor", tags: []string{"prod"}, want: "-mod=vendor -tags=prod"},
+		{goFlags: "-tags=a -trimpath --tags=b,prod", tags: []string{"prod", "c"}, want: "-trimpath -tags=b,prod,c"},
+	}
+	for _, tc := range testCases {
+		if got := withTags(tc.goFlags, tc.tags); got != tc.want {
+			t.Errorf("withTags(%q, %q) = %q, want %q", tc.goFlags, tc.tags, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"os"
+	goruntime "runtime"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// The platform sets these env vars to the target of the image it builds.
+	cnbTargetOS          = "CNB_TARGET_OS"
+	cnbTargetArch        = "CNB_TARGET_ARCH"
+	cnbTargetArchVariant = "CNB_TARGET_ARCH_VARIANT"
+
+	// targetLabel records the target of the compiled app.
+	targetLabel = "go_target"
+)
+
+// Target is the operating system and architecture that Go apps are compiled for.
+type Target struct {
+	OS   string
+	Arch string
+	// ARM is the GOARM version, such as 7, for the arm architecture.
+	ARM string
+}
+
+func (t Target) String() string {
+	if t.ARM != "" {
+		return t.OS + "/" + t.Arch + "/v" + t.ARM
+	}
+	return t.OS + "/" + t.Arch
+}
+
+// Env returns the env vars that make the go command compile for the target.
+func (t Target) Env() []string {
+	e := []string{"GOOS=" + t.OS, "GOARCH=" + t.Arch}
+	if t.ARM != "" {
+		e = append(e, "GOARM="+t.ARM)
+	}
+	return e
+}
+
+// Cross returns true if binaries compiled for the target cannot run on the build host.
+func (t Target) Cross() bool {
+	return t.OS != goruntime.GOOS || t.Arch != goruntime.GOARCH
+}
+
+// BuildTarget returns the target requested with env.GoOS and env.GoArch, or else the target of the
+// image set by the platform, or else the build host. Requesting a different target than the
+// platform is a user error, since the binary would not run on the run image it selected.
+func BuildTarget() (Target, error) {
+	platform := Target{
+		OS:   os.Getenv(cnbTargetOS),
+		Arch: os.Getenv(cnbTargetArch),
+		ARM:  strings.TrimPrefix(os.Getenv(cnbTargetArchVariant), "v"),
+	}
+	t := Target{OS: os.Getenv(env.GoOS), Arch: os.Getenv(env.GoArch)}
+	if t.OS != "" && platform.OS != "" && t.OS != platform.OS {
+		return Target{}, gcp.UserErrorf("%s=%s does not match the target OS of the image, %s", env.GoOS, t.OS, platform.OS)
+	}
+	if t.Arch != "" && platform.Arch != "" && t.Arch != platform.Arch {
+		return Target{}, gcp.UserErrorf("%s=%s does not match the target architecture of the image, %s", env.GoArch, t.Arch, platform.Arch)
+	}
+	if t.OS == "" {
+		t.OS = platform.OS
+	}
+	if t.Arch == "" {
+		t.Arch = platform.Arch
+		if t.Arch == "arm" {
+			t.ARM = platform.ARM
+		}
+	}
+	if t.OS == "" {
+		t.OS = goruntime.GOOS
+	}
+	if t.Arch == "" {
+		t.Arch = goruntime.GOARCH
+	}
+	return t, nil
+}
+
+// LabelTarget labels the image with the target of a cross-compiled app.
+func LabelTarget(ctx *gcp.Context, t Target) {
+	ctx.AddLabel(targetLabel, t.String())
+}
@@ -0,0 +1,86 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"os"
+	goruntime "runtime"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+func TestBuildTarget(t *testing.T) {
+	testCases := []struct {
+		name    string
+		env     map[string]string
+		want    Target
+		wantErr bool
+	}{
+		{
+			name: "host",
+			want: Target{OS: goruntime.GOOS, Arch: goruntime.GOARCH},
+		},
+		{
+			name: "env",
+			env:  map[string]string{env.GoArch: "arm64"},
+			want: Target{OS: goruntime.GOOS, Arch: "arm64"},
+		},
+		{
+			name: "platform",
+			env:  map[string]string{cnbTargetOS: "linux", cnbTargetArch: "arm", cnbTargetArchVariant: "v7"},
+			want: Target{OS: "linux", Arch: "arm", ARM: "7"},
+		},
+		{
+			name: "env matches platform",
+			env:  map[string]string{env.GoOS: "linux", env.GoArch: "arm64", cnbTargetOS: "linux", cnbTargetArch: "arm64"},
+			want: Target{OS: "linux", Arch: "arm64"},
+		},
+		{
+			name:    "env conflicts with platform",
+			env:     map[string]string{env.GoArch: "arm64", cnbTargetArch: "amd64"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, k := range []string{env.GoOS, env.GoArch, cnbTargetOS, cnbTargetArch, cnbTargetArchVariant} {
+				os.Unsetenv(k)
+			}
+			for k, v := range tc.env {
+				os.Setenv(k, v)
+				defer os.Unsetenv(k)
+			}
+
+			got, err := BuildTarget()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("BuildTarget() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("BuildTarget() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTargetEnv(t *testing.T) {
+	got := Target{OS: "linux", Arch: "arm", ARM: "7"}
+	if s := got.String(); s != "linux/arm/v7" {
+		t.Errorf("String() = %q, want %q", s, "linux/arm/v7")
+	}
+	if e := got.Env(); len(e) != 3 || e[2] != "GOARM=7" {
+		t.Errorf("Env() = %q, want GOOS, GOARCH and GOARM=7", e)
+	}
+}
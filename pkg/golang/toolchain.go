@@ -0,0 +1,127 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// minToolchainSwitchVersion is the first Go release that downloads the toolchain required by go.mod.
+	minToolchainSwitchVersion = "1.21.0"
+	// languageReleaseVersion is the first Go language version whose first release has a patch
+	// version, e.g. 1.21.0 rather than 1.21.
+	languageReleaseVersion = "1.21"
+)
+
+var (
+	// goModToolchainRegexp is used to get the toolchain directive from go.mod file.
+	goModToolchainRegexp = regexp.MustCompile(`(?m)^\s*toolchain\s+go(\d+(\.\d+){1,2}((rc|beta)\d+)?)\s*(//.*)?$`)
+
+	// releaseRegexp parses Go versions such as 1.14, 1.21.0 and 1.22rc1.
+	releaseRegexp = regexp.MustCompile(`^(\d+)\.(\d+)(?:\.(\d+))?(?:(rc|beta)(\d+))?$`)
+)
+
+// GoModDirectives returns the versions of the go and toolchain directives of the go.mod in dir, e.g.
+// 1.21 and 1.22.3 for `go 1.21` and `toolchain go1.22.3`. Missing directives are empty.
+func GoModDirectives(ctx *gcp.Context, dir string) (goVersion, toolchain string) {
+	path := filepath.Join(dir, "go.mod")
+	if !ctx.FileExists(path) {
+		return "", ""
+	}
+	content := ctx.ReadFile(path)
+	if m := goModVersionRegexp.FindSubmatch(content); m != nil {
+		goVersion = string(m[1])
+	}
+	if m := goModToolchainRegexp.FindSubmatch(content); m != nil {
+		toolchain = string(m[1])
+	}
+	return goVersion, toolchain
+}
+
+// RequiredVersion returns the oldest Go release that builds the module in dir: the release of its
+// toolchain directive, or else the first release of the language version of its go directive. It
+// returns an empty string if the module declares neither.
+func RequiredVersion(ctx *gcp.Context, dir string) string {
+	goVersion, toolchain := GoModDirectives(ctx, dir)
+	if goVersion == "" {
+		return toolchain
+	}
+	release := goVersion
+	// From Go 1.21, a language version such as 1.21 is not a release: the first release is 1.21.0.
+	if !VersionLess(goVersion, languageReleaseVersion) && releaseRegexp.FindStringSubmatch(goVersion)[3] == "" {
+		release = goVersion + ".0"
+	}
+	if toolchain != "" && VersionLess(release, toolchain) {
+		return toolchain
+	}
+	return release
+}
+
+// RequiredToolchain returns the Go release required by the module in dir if the installed Go is
+// older, so that the go command must download it, or an empty string if the installed Go builds the
+// module. It fails early if the installed Go cannot download toolchains, rather than letting the
+// build fail with "go.mod requires go >= X".
+func RequiredToolchain(ctx *gcp.Context, dir string) (string, error) {
+	required := RequiredVersion(ctx, dir)
+	if required == "" {
+		return "", nil
+	}
+	installed := GoVersion(ctx)
+	if !VersionLess(installed, required) {
+		return "", nil
+	}
+	if VersionLess(installed, minToolchainSwitchVersion) || os.Getenv("GOTOOLCHAIN") == "local" {
+		return "", gcp.UserErrorf("go.mod requires Go %s, but Go %s is installed and cannot download newer toolchains; set %s=%s", required, installed, env.RuntimeVersion, required)
+	}
+	return required, nil
+}
+
+// VersionLess returns true if the Go version a is older than b. Pre-releases, such as 1.22rc1, are
+// older than the release of the same version, 1.22.0. Unparsable versions are older than any other.
+func VersionLess(a, b string) bool {
+	pa, pb := parseRelease(a), parseRelease(b)
+	if pa == nil || pb == nil {
+		return pa == nil && pb != nil
+	}
+	for i := range pa {
+		if pa[i] != pb[i] {
+			return pa[i] < pb[i]
+		}
+	}
+	return false
+}
+
+// parseRelease returns the major, minor and patch versions, and the pre-release kind and number of a
+// Go version. Releases rank above release candidates, which rank above betas.
+func parseRelease(v string) []int {
+	m := releaseRegexp.FindStringSubmatch(v)
+	if m == nil {
+		return nil
+	}
+	kind := map[string]int{"beta": 0, "rc": 1, "": 2}[m[4]]
+	var r []int
+	for _, s := range []string{m[1], m[2], m[3], strconv.Itoa(kind), m[5]} {
+		n, _ := strconv.Atoi(s)
+		r = append(r, n)
+	}
+	return r
+}
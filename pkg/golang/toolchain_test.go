@@ -0,0 +1,130 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestRequiredToolchain(t *testing.T) {
+	testCases := []struct {
+		name        string
+		gomod       string
+		installed   string
+		wantVersion string
+		want        string
+		wantErr     bool
+	}{
+		{
+			name:        "no directives",
+			gomod:       "module example.com/fn\n",
+			installed:   "1.21.0",
+			wantVersion: "",
+		},
+		{
+			name:        "go before 1.21",
+			gomod:       "module example.com/fn\n\ngo 1.16\n",
+			installed:   "1.16.5",
+			wantVersion: "1.16",
+		},
+		{
+			name:        "go language version",
+			gomod:       "module example.com/fn\n\ngo 1.22\n",
+			installed:   "1.21.5",
+			wantVersion: "1.22.0",
+			want:        "1.22.0",
+		},
+		{
+			name:        "toolchain",
+			gomod:       "module example.com/fn\n\ngo 1.21\n\ntoolchain go1.22.3\n",
+			installed:   "1.21.0",
+			wantVersion: "1.22.3",
+			want:        "1.22.3",
+		},
+		{
+			name:        "toolchain older than go",
+			gomod:       "module example.com/fn\n\ngo 1.22.1\n\ntoolchain go1.22rc1\n",
+			installed:   "1.22.1",
+			wantVersion: "1.22.1",
+		},
+		{
+			name:        "installed is newer",
+			gomod:       "module example.com/fn\n\ngo 1.21.4\n\ntoolchain go1.21.6\n",
+			installed:   "1.22.0",
+			wantVersion: "1.21.6",
+		},
+		{
+			name:        "installed cannot switch",
+			gomod:       "module example.com/fn\n\ngo 1.18\n",
+			installed:   "1.16.15",
+			wantVersion: "1.18",
+			wantErr:     true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "toolchain")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte(tc.gomod), 0644); err != nil {
+				t.Fatalf("writing go.mod: %v", err)
+			}
+			ctx := gcp.NewContextForTests(libcnb.BuildpackInfo{}, dir)
+			defer func(f func(*gcp.Context) string) { readGoVersion = f }(readGoVersion)
+			readGoVersion = func(*gcp.Context) string { return "go version go" + tc.installed + " linux/amd64" }
+
+			if got := RequiredVersion(ctx, dir); got != tc.wantVersion {
+				t.Errorf("RequiredVersion() = %q, want %q", got, tc.wantVersion)
+			}
+			got, err := RequiredToolchain(ctx, dir)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("RequiredToolchain() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("RequiredToolchain() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestVersionLess(t *testing.T) {
+	testCases := []struct {
+		a, b string
+		want bool
+	}{
+		{a: "1.14", b: "1.14.0", want: false},
+		{a: "1.14.1", b: "1.14", want: false},
+		{a: "1.14", b: "1.14.1", want: true},
+		{a: "1.9", b: "1.14", want: true},
+		{a: "1.22rc1", b: "1.22.0", want: true},
+		{a: "1.22beta1", b: "1.22rc1", want: true},
+		{a: "1.22rc2", b: "1.22rc1", want: false},
+		{a: "", b: "1.14", want: true},
+		{a: "1.14", b: "", want: false},
+	}
+	for _, tc := range testCases {
+		if got := VersionLess(tc.a, tc.b); got != tc.want {
+			t.Errorf("VersionLess(%q, %q) = %t, want %t", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
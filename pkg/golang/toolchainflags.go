@@ -0,0 +1,118 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/blang/semver"
+)
+
+const (
+	// toolchainFlagsLayer holds the toolchain flags in its build environment. It is not cached, so
+	// that flags removed by the user do not linger in later builds.
+	toolchainFlagsLayer = "toolchain-flags"
+)
+
+var (
+	// minGoExperimentVersion is the first Go version whose go command reads GOEXPERIMENT; earlier
+	// versions had to be compiled with the experiments.
+	minGoExperimentVersion = semver.MustParse("1.18.0")
+)
+
+// AppendGoFlags returns flags appended to the GOFLAGS of the build, so that flags set by buildpacks
+// do not drop those requested by the user.
+func AppendGoFlags(flags string) string {
+	return strings.TrimSpace(os.Getenv("GOFLAGS") + " " + flags)
+}
+
+// ConfigureToolchainFlags validates env.GoExperiment, env.GoFlags and env.GoBuildTags with the Go
+// version installed in goRoot, and sets them as GOEXPERIMENT and GOFLAGS in the build environment of
+// later buildpacks, so that they apply to apps, functions and the mains generated for functions.
+// GOFLAGS set by the user is validated against the same allowlist as env.GoFlags and kept.
+func ConfigureToolchainFlags(ctx *gcp.Context, goRoot, version string) error {
+	experiment, flags, err := toolchainFlags(ctx, goRoot, version)
+	if err != nil {
+		return err
+	}
+	if experiment == "" && flags == "" {
+		if v := strings.TrimSpace(os.Getenv("GOFLAGS")); v != "" {
+			ctx.Logf("Building with GOFLAGS=%s", v)
+		}
+		return nil
+	}
+	l := ctx.Layer(toolchainFlagsLayer, gcp.BuildLayer)
+	if experiment != "" {
+		l.BuildEnvironment.Override("GOEXPERIMENT", experiment)
+		ctx.Logf("Building with GOEXPERIMENT=%s", experiment)
+	}
+	if flags != "" {
+		l.BuildEnvironment.Override("GOFLAGS", flags)
+		ctx.Logf("Building with GOFLAGS=%s", flags)
+	}
+	return nil
+}
+
+// toolchainFlags returns the GOEXPERIMENT and GOFLAGS of the build with env.GoExperiment,
+// env.GoFlags and the -tags of env.GoBuildTags appended, or empty strings if none is set.
+func toolchainFlags(ctx *gcp.Context, goRoot, version string) (string, string, error) {
+	if err := ValidateGoFlags("GOFLAGS", os.Getenv("GOFLAGS")); err != nil {
+		return "", "", err
+	}
+	experiment := strings.TrimSpace(os.Getenv(env.GoExperiment))
+	flags := strings.TrimSpace(os.Getenv(env.GoFlags))
+	if err := ValidateGoFlags(env.GoFlags, flags); err != nil {
+		return "", "", err
+	}
+	tags, err := BuildTags()
+	if err != nil {
+		return "", "", err
+	}
+	if experiment == "" && flags == "" && len(tags) == 0 {
+		return "", "", nil
+	}
+
+	var e []string
+	if experiment != "" {
+		v, err := semver.ParseTolerant(version)
+		if err != nil {
+			return "", "", gcp.InternalErrorf("parsing Go version %q: %v", version, err)
+		}
+		if v.LT(minGoExperimentVersion) {
+			return "", "", gcp.UserErrorf("%s requires Go 1.18 or later, found Go %s; set %s to a later version", env.GoExperiment, version, env.RuntimeVersion)
+		}
+		if v := os.Getenv("GOEXPERIMENT"); v != "" {
+			experiment = v + "," + experiment
+		}
+		e = append(e, "GOEXPERIMENT="+experiment)
+	}
+	if flags != "" || len(tags) > 0 {
+		flags = AppendGoFlags(flags)
+		if len(tags) > 0 {
+			flags = withTags(flags, tags)
+		}
+		e = append(e, "GOFLAGS="+flags)
+	}
+
+	// The go command rejects unknown experiments and flags before running any command.
+	if _, err := ctx.ExecWithErr([]string{filepath.Join(goRoot, "bin", "go"), "version"}, gcp.WithEnv(e...), gcp.WithUserAttribution); err != nil {
+		return "", "", gcp.UserErrorf("%s and %s must be supported by Go %s: %v", env.GoExperiment, env.GoFlags, version, err)
+	}
+	return experiment, flags, nil
+}
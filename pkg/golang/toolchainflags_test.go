@@ -0,0 +1,143 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestToolchainFlags(t *testing.T) {
+	out, err := exec.Command("go", "env", "GOROOT").Output()
+	if err != nil {
+		t.Skipf("Go is not installed: %v", err)
+	}
+	goRoot := strings.TrimSpace(string(out))
+
+	testCases := []struct {
+		name           string
+		experiment     string
+		flags          string
+		tags           string
+		version        string
+		userFlags      string
+		wantExperiment string
+		wantFlags      string
+		wantErr        bool
+	}{
+		{
+			name:    "unset",
+			version: "1.18",
+		},
+		{
+			name:           "experiment",
+			experiment:     "boringcrypto",
+			version:        "1.18",
+			wantExperiment: "boringcrypto",
+		},
+		{
+			name:      "flags appended to GOFLAGS",
+			flags:     "-trimpath",
+			userFlags: "-tags=netgo",
+			version:   "1.14",
+			wantFlags: "-tags=netgo -trimpath",
+		},
+		{
+			name:      "build tags merged with GOFLAGS",
+			flags:     "-trimpath -tags=jsoniter",
+			tags:      "prod,timetzdata",
+			userFlags: "-tags=netgo -mod=vendor",
+			version:   "1.14",
+			wantFlags: "-mod=vendor -trimpath -tags=jsoniter,prod,timetzdata",
+		},
+		{
+			name:      "build tags only",
+			tags:      "prod",
+			version:   "1.14",
+			wantFlags: "-tags=prod",
+		},
+		{
+			name:    "invalid build tag",
+			tags:    "prod,-race",
+			version: "1.14",
+			wantErr: true,
+		},
+		{
+			name:       "unknown experiment",
+			experiment: "nosuchexperiment",
+			version:    "1.18",
+			wantErr:    true,
+		},
+		{
+			name:    "unknown flag",
+			flags:   "-nosuchflag",
+			version: "1.18",
+			wantErr: true,
+		},
+		{
+			name:    "unsupported flag",
+			flags:   "-trimpath -toolexec=/bin/true",
+			version: "1.18",
+			wantErr: true,
+		},
+		{
+			name:      "unsupported user flag",
+			userFlags: "-ldflags=-s",
+			version:   "1.18",
+			wantErr:   true,
+		},
+		{
+			name:      "user flags only",
+			userFlags: "-mod=readonly -buildvcs=false",
+			version:   "1.18",
+		},
+		{
+			name:       "experiment before Go 1.18",
+			experiment: "boringcrypto",
+			version:    "1.16.5",
+			wantErr:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range map[string]string{env.GoExperiment: tc.experiment, env.GoFlags: tc.flags, env.GoBuildTags: tc.tags, "GOFLAGS": tc.userFlags, "GOEXPERIMENT": ""} {
+				defer os.Setenv(k, os.Getenv(k))
+				os.Setenv(k, v)
+			}
+			ctx := gcp.NewContext(libcnb.BuildpackInfo{ID: "google.go.runtime"})
+			gcp.WithLogger(log.New(ioutil.Discard, "", 0))(ctx)
+
+			gotExperiment, gotFlags, err := toolchainFlags(ctx, goRoot, tc.version)
+
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("toolchainFlags() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if gotExperiment != tc.wantExperiment {
+				t.Errorf("toolchainFlags() GOEXPERIMENT = %q, want %q", gotExperiment, tc.wantExperiment)
+			}
+			if gotFlags != tc.wantFlags {
+				t.Errorf("toolchainFlags() GOFLAGS = %q, want %q", gotFlags, tc.wantFlags)
+			}
+		})
+	}
+}
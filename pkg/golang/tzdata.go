@@ -0,0 +1,86 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	tzdataLayer = "tzdata"
+)
+
+var (
+	// loadLocationRegexp matches calls that read the time zone database of the image.
+	loadLocationRegexp = regexp.MustCompile(`\btime\.LoadLocation\(`)
+	// embeddedTZDataRegexp matches imports of time/tzdata, which embeds the database in the binary.
+	embeddedTZDataRegexp = regexp.MustCompile(`"time/tzdata"`)
+)
+
+// UsesTimeZones returns true if the Go files in dir, including vendored packages, load time zones by
+// name without embedding the time zone database. Such apps fail with "unknown time zone" on run
+// images without tzdata.
+func UsesTimeZones(ctx *gcp.Context, dir string) (bool, error) {
+	uses, embeds := false, false
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && strings.HasPrefix(info.Name(), ".") && path != dir {
+			return filepath.SkipDir
+		}
+		if info.IsDir() || filepath.Ext(path) != ".go" || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		content := ctx.ReadFile(path)
+		uses = uses || loadLocationRegexp.Match(content)
+		embeds = embeds || embeddedTZDataRegexp.Match(content)
+		return nil
+	})
+	if err != nil {
+		return false, gcp.InternalErrorf("searching %s for time zone usage: %v", dir, err)
+	}
+	return uses && !embeds, nil
+}
+
+// AddTimeZoneData copies the time zone database of the Go installation into a launch layer, so that
+// time.LoadLocation works on run images without tzdata.
+func AddTimeZoneData(ctx *gcp.Context) {
+	l := ctx.Layer(tzdataLayer, gcp.LaunchLayer)
+	if addZoneInfo(ctx, l) {
+		ctx.Logf("Added the time zone database to the image, as the app loads time zones")
+	}
+}
+
+// addZoneInfo copies zoneinfo.zip of the Go installation into l and points ZONEINFO to it, unless the
+// app sets it. It returns false if the Go installation has no time zone database.
+func addZoneInfo(ctx *gcp.Context, l *libcnb.Layer) bool {
+	goRoot := strings.TrimSpace(ctx.Exec([]string{"go", "env", "GOROOT"}).Stdout)
+	zoneinfo := filepath.Join(goRoot, "lib", "time", "zoneinfo.zip")
+	if !ctx.FileExists(zoneinfo) {
+		ctx.Warnf("Not adding the time zone database to the image: %s does not exist", zoneinfo)
+		return false
+	}
+	tz := filepath.Join(l.Path, "zoneinfo.zip")
+	ctx.Exec([]string{"cp", zoneinfo, tz})
+	l.LaunchEnvironment.Default("ZONEINFO", tz)
+	return true
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestUsesTimeZones(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  bool
+	}{
+		{
+			name:  "no time zones",
+			files: map[string]string{"main.go": "package main\n\nfunc main() { time.Now() }\n"},
+		},
+		{
+			name:  "load location",
+			files: map[string]string{"main.go": "package main\n\nfunc main() { time.LoadLocation(\"Europe/Paris\") }\n"},
+			want:  true,
+		},
+		{
+			name: "load location in vendored package",
+			files: map[string]string{
+				"main.go":                     "package main\n",
+				"vendor/example.com/tz/tz.go": "package tz\n\nvar loc, _ = time.LoadLocation(\"UTC\")\n",
+			},
+			want: true,
+		},
+		{
+			name: "embedded tzdata",
+			files: map[string]string{
+				"main.go": "package main\n\nimport _ \"time/tzdata\"\n\nfunc main() { time.LoadLocation(\"Europe/Paris\") }\n",
+			},
+		},
+		{
+			name:  "test file only",
+			files: map[string]string{"main_test.go": "package main\n\nvar loc, _ = time.LoadLocation(\"UTC\")\n"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "tzdata")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			for name, content := range tc.files {
+				path := filepath.Join(dir, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating %s: %v", filepath.Dir(path), err)
+				}
+				if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatalf("writing %s: %v", path, err)
+				}
+			}
+			ctx := gcp.NewContextForTests(libcnb.BuildpackInfo{}, dir)
+
+			got, err := UsesTimeZones(ctx, dir)
+			if err != nil {
+				t.Fatalf("UsesTimeZones() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("UsesTimeZones() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// CheckVendorConsistency verifies that the vendor directory in dir matches the module requirements
+// in go.mod and the checksums in go.sum. Divergence otherwise surfaces as confusing compile errors
+// part-way through the build. It returns nil if dir does not contain go.mod, go.sum and vendor/modules.txt.
+func CheckVendorConsistency(ctx *gcp.Context, dir string) error {
+	goMod := filepath.Join(dir, "go.mod")
+	goSum := filepath.Join(dir, "go.sum")
+	modulesTxt := filepath.Join(dir, "vendor", "modules.txt")
+	if !ctx.FileExists(goMod) || !ctx.FileExists(goSum) || !ctx.FileExists(modulesTxt) {
+		return nil
+	}
+
+	problems := vendorInconsistencies(string(ctx.ReadFile(goMod)), string(ctx.ReadFile(goSum)), string(ctx.ReadFile(modulesTxt)))
+	if len(problems) == 0 {
+		return nil
+	}
+	return gcp.UserErrorf("vendor directory is inconsistent with go.mod and go.sum:\n  %s\nRun `go mod tidy && go mod vendor` and commit the result.", strings.Join(problems, "\n  "))
+}
+
+// VendoredModuleVersion returns the version of module recorded in vendor/modules.txt in dir, and
+// whether the module is vendored. The version is empty if every version of the module is replaced.
+func VendoredModuleVersion(ctx *gcp.Context, dir, module string) (string, bool) {
+	modulesTxt := filepath.Join(dir, "vendor", "modules.txt")
+	if !ctx.FileExists(modulesTxt) {
+		return "", false
+	}
+	m, ok := parseModulesTxt(string(ctx.ReadFile(modulesTxt)))[module]
+	return m.version, ok
+}
+
+// vendorInconsistencies returns a description of every module whose vendored version diverges from
+// go.mod or is missing from go.sum.
+func vendorInconsistencies(goMod, goSum, modulesTxt string) []string {
+	vendored := parseModulesTxt(modulesTxt)
+	sums := parseGoSum(goSum)
+
+	var problems []string
+	for mod, want := range parseGoModRequires(goMod) {
+		v, ok := vendored[mod]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s %s: required by go.mod but not vendored", mod, want))
+		case v.version != "" && v.version != want:
+			problems = append(problems, fmt.Sprintf("%s: go.mod requires %s but %s is vendored", mod, want, v.version))
+		}
+	}
+	for mod, v := range vendored {
+		// Modules replaced by local directories have no checksum.
+		if v.sumKey == "" {
+			continue
+		}
+		hasZip, ok := sums[v.sumKey]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s: vendored but go.sum has no checksum for %s", mod, v.sumKey))
+		case !hasZip && v.hasPackages:
+			problems = append(problems, fmt.Sprintf("%s: vendored but go.sum only has the go.mod checksum for %s", mod, v.sumKey))
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// parseGoModRequires returns the module versions listed in go.mod require directives.
+func parseGoModRequires(goMod string) map[string]string {
+	requires := make(map[string]string)
+	inBlock := false
+	for _, line := range strings.Split(goMod, "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+			continue
+		case inBlock && fields[0] == ")":
+			inBlock = false
+		case inBlock && len(fields) == 2:
+			requires[fields[0]] = fields[1]
+		case fields[0] == "require" && len(fields) == 2 && fields[1] == "(":
+			inBlock = true
+		case fields[0] == "require" && len(fields) == 3:
+			requires[fields[1]] = fields[2]
+		}
+	}
+	return requires
+}
+
+// vendoredModule describes a module entry in vendor/modules.txt.
+type vendoredModule struct {
+	// version is the required version, or empty if all versions of the module are replaced.
+	version string
+	// sumKey is the "module version" pair whose checksum must be present in go.sum, or empty
+	// if the module is replaced by a local directory.
+	sumKey string
+	// hasPackages is whether any package of the module is vendored. go.sum only needs the checksum of
+	// the module zip if so.
+	hasPackages bool
+}
+
+// parseModulesTxt returns the modules recorded in vendor/modules.txt.
+func parseModulesTxt(modulesTxt string) map[string]vendoredModule {
+	vendored := make(map[string]vendoredModule)
+	current := ""
+	for _, line := range strings.Split(modulesTxt, "\n") {
+		// Package lines follow the line of the module that provides them.
+		if line != "" && !strings.HasPrefix(line, "#") {
+			if m, ok := vendored[current]; ok {
+				m.hasPackages = true
+				vendored[current] = m
+			}
+			continue
+		}
+		// Module lines look like:
+		//   # example.com/mod v1.2.3
+		//   # example.com/mod v1.2.3 => example.com/fork v1.2.4
+		//   # example.com/mod v1.2.3 => ../mod
+		//   # example.com/mod => ../mod
+		if !strings.HasPrefix(line, "# ") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, "# "))
+		var m vendoredModule
+		switch {
+		case len(fields) == 2:
+			m = vendoredModule{version: fields[1], sumKey: fields[0] + " " + fields[1]}
+		case len(fields) == 3 && fields[1] == "=>":
+			m = vendoredModule{}
+		case len(fields) == 4 && fields[2] == "=>":
+			m = vendoredModule{version: fields[1]}
+		case len(fields) == 5 && fields[2] == "=>":
+			m = vendoredModule{version: fields[1], sumKey: fields[3] + " " + fields[4]}
+		case len(fields) == 4 && fields[1] == "=>":
+			m = vendoredModule{sumKey: fields[2] + " " + fields[3]}
+		default:
+			current = ""
+			continue
+		}
+		vendored[fields[0]] = m
+		current = fields[0]
+	}
+	return vendored
+}
+
+// parseGoSum returns the "module version" pairs that have a checksum in go.sum, mapped to whether
+// go.sum has the checksum of the module zip. A pair that only has a "/go.mod" checksum maps to
+// false: its go.mod was consulted during module resolution but its source was never verified.
+func parseGoSum(goSum string) map[string]bool {
+	sums := make(map[string]bool)
+	for _, line := range strings.Split(goSum, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		version := strings.TrimSuffix(fields[1], "/go.mod")
+		key := fields[0] + " " + version
+		sums[key] = sums[key] || version == fields[1]
+	}
+	return sums
+}
@@ -0,0 +1,133 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestVendorInconsistencies(t *testing.T) {
+	goMod := `
+module example.com/app
+
+go 1.14
+
+require (
+	example.com/a v1.0.0
+	example.com/b v1.2.0 // indirect
+)
+
+require example.com/c v0.1.0
+`
+	goSum := `
+example.com/a v1.0.0 h1:aaa=
+example.com/a v1.0.0/go.mod h1:aaa=
+example.com/b v1.2.0 h1:bbb=
+example.com/b v1.2.0/go.mod h1:bbb=
+example.com/c v0.1.0 h1:ccc=
+example.com/fork v0.2.0 h1:fff=
+example.com/g v1.0.0/go.mod h1:ggg=
+`
+	testCases := []struct {
+		name       string
+		goMod      string
+		goSum      string
+		modulesTxt string
+		want       []string
+	}{
+		{
+			name:  "consistent",
+			goMod: goMod,
+			goSum: goSum,
+			modulesTxt: `# example.com/a v1.0.0
+## explicit
+example.com/a
+# example.com/b v1.2.0
+## explicit
+example.com/b/pkg
+# example.com/c v0.1.0
+## explicit
+example.com/c
+`,
+		},
+		{
+			name:  "version mismatch",
+			goMod: goMod,
+			goSum: goSum,
+			modulesTxt: `# example.com/a v0.9.0
+# example.com/b v1.2.0
+# example.com/c v0.1.0
+`,
+			want: []string{
+				"example.com/a: go.mod requires v1.0.0 but v0.9.0 is vendored",
+				"example.com/a: vendored but go.sum has no checksum for example.com/a v0.9.0",
+			},
+		},
+		{
+			name:  "not vendored",
+			goMod: goMod,
+			goSum: goSum,
+			modulesTxt: `# example.com/a v1.0.0
+# example.com/b v1.2.0
+`,
+			want: []string{"example.com/c v0.1.0: required by go.mod but not vendored"},
+		},
+		{
+			name:  "missing from go.sum",
+			goMod: "module example.com/app\n",
+			goSum: goSum,
+			modulesTxt: `# example.com/d v1.0.0
+`,
+			want: []string{"example.com/d: vendored but go.sum has no checksum for example.com/d v1.0.0"},
+		},
+		{
+			name:  "only go.mod checksum in go.sum",
+			goMod: "module example.com/app\n",
+			goSum: goSum,
+			modulesTxt: `# example.com/g v1.0.0
+## explicit
+example.com/g
+`,
+			want: []string{"example.com/g: vendored but go.sum only has the go.mod checksum for example.com/g v1.0.0"},
+		},
+		{
+			name:  "only go.mod checksum for module without packages",
+			goMod: "module example.com/app\n",
+			goSum: goSum,
+			modulesTxt: `# example.com/g v1.0.0
+## explicit
+`,
+		},
+		{
+			name:  "replacements",
+			goMod: goMod,
+			goSum: goSum,
+			modulesTxt: `# example.com/a v1.0.0
+# example.com/b v1.2.0 => ../b
+# example.com/c v0.1.0 => example.com/fork v0.2.0
+# example.com/e => ../e
+`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := vendorInconsistencies(tc.goMod, tc.goSum, tc.modulesTxt)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("vendorInconsistencies() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/blang/semver"
+)
+
+const (
+	// WorkFile is the name of the file that defines a Go workspace.
+	WorkFile = "go.work"
+	// WorkSumFile is the name of the file that holds the checksums of workspace dependencies that are
+	// not in the go.sum of any workspace module.
+	WorkSumFile = "go.work.sum"
+)
+
+// Workspace is a Go workspace, as printed by `go work edit -json`. Paths of modules on disk are
+// absolute.
+type Workspace struct {
+	Go      string
+	Use     []WorkspaceUse
+	Replace []WorkspaceReplace
+}
+
+// WorkspaceUse is a use directive of a workspace.
+type WorkspaceUse struct {
+	DiskPath string
+}
+
+// WorkspaceReplace is a replace directive of a workspace.
+type WorkspaceReplace struct {
+	Old ModuleVersion
+	New ModuleVersion
+}
+
+// ModuleVersion is a module path with an optional version. Without a version, Path may be a directory.
+type ModuleVersion struct {
+	Path    string
+	Version string
+}
+
+// String returns the module version in the form accepted by `go mod edit -replace`.
+func (m ModuleVersion) String() string {
+	if m.Version == "" {
+		return m.Path
+	}
+	return m.Path + "@" + m.Version
+}
+
+// SupportsWorkspaces returns true if the installed Go runtime supports go.work, Go 1.18 and later.
+func SupportsWorkspaces(ctx *gcp.Context) bool {
+	v := GoVersion(ctx)
+	version, err := semver.ParseTolerant(v)
+	if err != nil {
+		ctx.Exit(1, gcp.InternalErrorf("unable to parse go version string %q: %s", v, err))
+	}
+	return semver.MustParseRange(">=1.18.0")(version)
+}
+
+// FindWorkspace returns the path of the go.work file that applies to the module in dir, the first one
+// found in dir and its parents up to root, or an empty string if there is none or workspaces are
+// disabled with GOWORK=off.
+func FindWorkspace(dir, root string) string {
+	if os.Getenv("GOWORK") == "off" {
+		return ""
+	}
+	dir, root = filepath.Clean(dir), filepath.Clean(root)
+	for {
+		if fi, err := os.Stat(filepath.Join(dir, WorkFile)); err == nil && !fi.IsDir() {
+			return filepath.Join(dir, WorkFile)
+		}
+		if dir == root || !strings.HasPrefix(dir, root+string(filepath.Separator)) {
+			return ""
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// ReadWorkspace reads the go.work file at path.
+func ReadWorkspace(ctx *gcp.Context, path string) (*Workspace, error) {
+	res, err := ctx.ExecWithErr([]string{"go", "work", "edit", "-json", path}, gcp.WithUserAttribution)
+	if err != nil {
+		return nil, gcp.UserErrorf("reading %s: %v", path, err)
+	}
+	return parseWorkspace([]byte(res.Stdout), filepath.Dir(path))
+}
+
+// parseWorkspace parses the JSON form of the go.work file in dir and resolves the paths of modules
+// on disk relative to dir.
+func parseWorkspace(data []byte, dir string) (*Workspace, error) {
+	var ws Workspace
+	if err := json.Unmarshal(data, &ws); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", WorkFile, err)
+	}
+	for i, u := range ws.Use {
+		ws.Use[i].DiskPath = resolvePath(u.DiskPath, dir)
+	}
+	for i, r := range ws.Replace {
+		if r.New.Version == "" && isLocalPath(r.New.Path) {
+			ws.Replace[i].New.Path = resolvePath(r.New.Path, dir)
+		}
+	}
+	return &ws, nil
+}
+
+// Uses returns true if the workspace uses the module in dir.
+func (ws *Workspace) Uses(dir string) bool {
+	for _, u := range ws.Use {
+		if u.DiskPath == filepath.Clean(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+func resolvePath(path, dir string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(dir, path)
+}
+
+// isLocalPath returns true if path is a directory rather than a module path, following the rules of
+// replace directives.
+func isLocalPath(path string) bool {
+	return filepath.IsAbs(path) || path == "." || path == ".." || strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../")
+}
@@ -0,0 +1,122 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindWorkspace(t *testing.T) {
+	root, err := ioutil.TempDir("", "source")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(root)
+	for _, dir := range []string{"ws/fn", "ws/lib", "other"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatalf("creating %s: %v", dir, err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "ws", WorkFile), []byte("go 1.18\n"), 0644); err != nil {
+		t.Fatalf("writing go.work: %v", err)
+	}
+
+	testCases := []struct {
+		name   string
+		dir    string
+		gowork string
+		want   string
+	}{
+		{name: "workspace root", dir: "ws", want: "ws/go.work"},
+		{name: "workspace module", dir: "ws/fn", want: "ws/go.work"},
+		{name: "outside workspace", dir: "other"},
+		{name: "source root", dir: "."},
+		{name: "GOWORK=off", dir: "ws/fn", gowork: "off"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.gowork != "" {
+				os.Setenv("GOWORK", tc.gowork)
+				defer os.Unsetenv("GOWORK")
+			}
+			want := ""
+			if tc.want != "" {
+				want = filepath.Join(root, tc.want)
+			}
+			if got := FindWorkspace(filepath.Join(root, tc.dir), root); got != want {
+				t.Errorf("FindWorkspace(%q) = %q, want %q", tc.dir, got, want)
+			}
+		})
+	}
+}
+
+func TestFindWorkspaceStopsAtRoot(t *testing.T) {
+	parent, err := ioutil.TempDir("", "parent")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(parent)
+	root := filepath.Join(parent, "source")
+	if err := os.MkdirAll(filepath.Join(root, "fn"), 0755); err != nil {
+		t.Fatalf("creating fn: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(parent, WorkFile), []byte("go 1.18\n"), 0644); err != nil {
+		t.Fatalf("writing go.work: %v", err)
+	}
+
+	if got := FindWorkspace(filepath.Join(root, "fn"), root); got != "" {
+		t.Errorf("FindWorkspace() = %q, want no workspace outside the source", got)
+	}
+}
+
+func TestParseWorkspace(t *testing.T) {
+	data := `{
+	"Go": "1.18",
+	"Use": [{"DiskPath": "./fn"}, {"DiskPath": "."}, {"DiskPath": "/abs/lib"}],
+	"Replace": [
+		{"Old": {"Path": "example.com/a"}, "New": {"Path": "../a"}},
+		{"Old": {"Path": "example.com/b", "Version": "v1.0.0"}, "New": {"Path": "example.com/c", "Version": "v1.2.0"}}
+	]
+}`
+	got, err := parseWorkspace([]byte(data), "/src/ws")
+	if err != nil {
+		t.Fatalf("parseWorkspace() got error: %v", err)
+	}
+
+	want := &Workspace{
+		Go:  "1.18",
+		Use: []WorkspaceUse{{DiskPath: "/src/ws/fn"}, {DiskPath: "/src/ws"}, {DiskPath: "/abs/lib"}},
+		Replace: []WorkspaceReplace{
+			{Old: ModuleVersion{Path: "example.com/a"}, New: ModuleVersion{Path: "/src/a"}},
+			{Old: ModuleVersion{Path: "example.com/b", Version: "v1.0.0"}, New: ModuleVersion{Path: "example.com/c", Version: "v1.2.0"}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseWorkspace() = %+v, want %+v", got, want)
+	}
+	if !got.Uses("/src/ws/fn/") {
+		t.Errorf("Uses(%q) = false, want true", "/src/ws/fn/")
+	}
+	if got.Uses("/src/ws/other") {
+		t.Errorf("Uses(%q) = true, want false", "/src/ws/other")
+	}
+	if s := got.Replace[1].New.String(); s != "example.com/c@v1.2.0" {
+		t.Errorf("New.String() = %q, want %q", s, "example.com/c@v1.2.0")
+	}
+}
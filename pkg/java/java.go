@@ -72,6 +72,21 @@ func ExecutableJar(ctx *gcp.Context) (string, error) {
 	return "", gcp.UserErrorf("did not find any jar files with a Main-Class manifest entry")
 }
 
+// ExportJar exports the executable jar built by Maven or Gradle with gcpbuildpack.ExportArtifacts.
+// Builds without exactly one executable jar, such as libraries, are not exported.
+func ExportJar(ctx *gcp.Context) error {
+	dir, err := gcp.ArtifactDir()
+	if err != nil || dir == "" {
+		return err
+	}
+	jar, err := ExecutableJar(ctx)
+	if err != nil {
+		ctx.Warnf("Not exporting a jar: %v.", err)
+		return nil
+	}
+	return ctx.ExportArtifacts(jar)
+}
+
 func filterExecutables(ctx *gcp.Context, jars []string) []string {
 	var executables []string
 	for _, jar := range jars {
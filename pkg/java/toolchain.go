@@ -0,0 +1,134 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+var (
+	// mavenReleaseRegexps match the Java release set with the maven.compiler.release property or the
+	// maven-compiler-plugin release and target parameters, in order of precedence.
+	mavenReleaseRegexps = []*regexp.Regexp{
+		regexp.MustCompile(`<maven\.compiler\.release>\s*([^<\s]+)\s*</maven\.compiler\.release>`),
+		regexp.MustCompile(`<release>\s*([^<\s]+)\s*</release>`),
+		regexp.MustCompile(`<maven\.compiler\.target>\s*([^<\s]+)\s*</maven\.compiler\.target>`),
+	}
+	// mavenToolchainRegexp matches the JDK version required with the maven-toolchains-plugin.
+	mavenToolchainRegexp = regexp.MustCompile(`<toolchains>\s*<jdk>\s*<version>\s*([^<\s]+)\s*</version>`)
+	// mavenPropertyRegexp matches a reference to a Maven property, such as ${java.version}.
+	mavenPropertyRegexp = regexp.MustCompile(`^\$\{([^}]+)\}$`)
+
+	// gradleReleaseRegexps match the Java release set with options.release or targetCompatibility, in
+	// order of precedence, in Groovy and Kotlin build scripts.
+	gradleReleaseRegexps = []*regexp.Regexp{
+		regexp.MustCompile(`release(?:\s*=\s*|\.set\(\s*)["']?(\d+)`),
+		regexp.MustCompile(`targetCompatibility\s*=\s*(?:JavaVersion\.VERSION_)?["']?([0-9][0-9_.]*)`),
+	}
+	// gradleToolchainRegexp matches the Java toolchain required with java.toolchain.languageVersion.
+	gradleToolchainRegexp = regexp.MustCompile(`languageVersion(?:\s*=\s*|\.set\(\s*)JavaLanguageVersion\.of\(\s*["']?(\d+)`)
+)
+
+// Toolchain describes the Java versions that a Maven or Gradle build requires.
+type Toolchain struct {
+	// Release is the Java feature version that the build compiles classes for, if set.
+	Release string
+	// Compiler is the feature version of the JDK that the build compiles with, if set.
+	Compiler string
+}
+
+// ReadToolchain returns the Java versions required by the pom.xml, build.gradle or build.gradle.kts
+// in dir. Versions that are not set are left empty.
+func ReadToolchain(dir string) (Toolchain, error) {
+	for _, f := range []string{"pom.xml", "build.gradle", "build.gradle.kts"} {
+		content, err := ioutil.ReadFile(filepath.Join(dir, f))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return Toolchain{}, gcp.InternalErrorf("reading %s: %v", f, err)
+		}
+		if f == "pom.xml" {
+			return mavenToolchain(string(content)), nil
+		}
+		return gradleToolchain(string(content)), nil
+	}
+	return Toolchain{}, nil
+}
+
+func mavenToolchain(pom string) Toolchain {
+	var tc Toolchain
+	for _, re := range mavenReleaseRegexps {
+		if m := re.FindStringSubmatch(pom); m != nil {
+			tc.Release = FeatureVersion(mavenProperty(pom, m[1]))
+			break
+		}
+	}
+	if m := mavenToolchainRegexp.FindStringSubmatch(pom); m != nil {
+		tc.Compiler = FeatureVersion(mavenProperty(pom, m[1]))
+	}
+	return tc
+}
+
+// mavenProperty resolves a reference to a property defined in the pom.
+func mavenProperty(pom, v string) string {
+	m := mavenPropertyRegexp.FindStringSubmatch(v)
+	if m == nil {
+		return v
+	}
+	name := regexp.QuoteMeta(m[1])
+	if p := regexp.MustCompile(`<` + name + `>\s*([^<\s]+)\s*</` + name + `>`).FindStringSubmatch(pom); p != nil {
+		return p[1]
+	}
+	return ""
+}
+
+func gradleToolchain(script string) Toolchain {
+	var tc Toolchain
+	if m := gradleToolchainRegexp.FindStringSubmatch(script); m != nil {
+		tc.Compiler = FeatureVersion(m[1])
+	}
+	for _, re := range gradleReleaseRegexps {
+		if m := re.FindStringSubmatch(script); m != nil {
+			tc.Release = FeatureVersion(strings.ReplaceAll(m[1], "_", "."))
+			break
+		}
+	}
+	// Without an explicit release, Gradle compiles classes for the toolchain version.
+	if tc.Release == "" {
+		tc.Release = tc.Compiler
+	}
+	return tc
+}
+
+// FeatureVersion returns the Java feature version of v, such as 8 for 1.8 and 11 for 11.0.6, or an
+// empty string if v is not a Java version.
+func FeatureVersion(v string) string {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "1.")
+	if i := strings.IndexAny(v, ".+"); i >= 0 {
+		v = v[:i]
+	}
+	if _, err := strconv.Atoi(v); err != nil {
+		return ""
+	}
+	return v
+}
@@ -0,0 +1,141 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadToolchain(t *testing.T) {
+	testCases := []struct {
+		name  string
+		file  string
+		build string
+		want  Toolchain
+	}{
+		{
+			name: "no build file",
+		},
+		{
+			name:  "maven without release",
+			file:  "pom.xml",
+			build: `<project><artifactId>app</artifactId></project>`,
+		},
+		{
+			name:  "maven release property",
+			file:  "pom.xml",
+			build: `<project><properties><maven.compiler.release>17</maven.compiler.release></properties></project>`,
+			want:  Toolchain{Release: "17"},
+		},
+		{
+			name:  "maven target property",
+			file:  "pom.xml",
+			build: `<project><properties><maven.compiler.target>1.8</maven.compiler.target></properties></project>`,
+			want:  Toolchain{Release: "8"},
+		},
+		{
+			name: "maven plugin release and toolchain",
+			file: "pom.xml",
+			build: `<project>
+  <properties><java.version>17</java.version></properties>
+  <build><plugins>
+    <plugin>
+      <artifactId>maven-compiler-plugin</artifactId>
+      <configuration><release>${java.version}</release></configuration>
+    </plugin>
+    <plugin>
+      <artifactId>maven-toolchains-plugin</artifactId>
+      <configuration>
+        <toolchains>
+          <jdk>
+            <version>21</version>
+          </jdk>
+        </toolchains>
+      </configuration>
+    </plugin>
+  </plugins></build>
+</project>`,
+			want: Toolchain{Release: "17", Compiler: "21"},
+		},
+		{
+			name:  "gradle toolchain",
+			file:  "build.gradle",
+			build: "java {\n  toolchain {\n    languageVersion = JavaLanguageVersion.of(21)\n  }\n}\n",
+			want:  Toolchain{Release: "21", Compiler: "21"},
+		},
+		{
+			name:  "gradle toolchain and release",
+			file:  "build.gradle",
+			build: "java {\n  toolchain {\n    languageVersion = JavaLanguageVersion.of(21)\n  }\n}\ntasks.withType(JavaCompile) {\n  options.release = 17\n}\n",
+			want:  Toolchain{Release: "17", Compiler: "21"},
+		},
+		{
+			name:  "gradle target compatibility",
+			file:  "build.gradle",
+			build: "sourceCompatibility = JavaVersion.VERSION_1_8\ntargetCompatibility = JavaVersion.VERSION_1_8\n",
+			want:  Toolchain{Release: "8"},
+		},
+		{
+			name:  "kotlin toolchain and release",
+			file:  "build.gradle.kts",
+			build: "java {\n  toolchain {\n    languageVersion.set(JavaLanguageVersion.of(21))\n  }\n}\ntasks.withType<JavaCompile> {\n  options.release.set(17)\n}\n",
+			want:  Toolchain{Release: "17", Compiler: "21"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "toolchain")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			if tc.file != "" {
+				if err := ioutil.WriteFile(filepath.Join(dir, tc.file), []byte(tc.build), 0644); err != nil {
+					t.Fatalf("writing %s: %v", tc.file, err)
+				}
+			}
+
+			got, err := ReadToolchain(dir)
+			if err != nil {
+				t.Fatalf("ReadToolchain() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("ReadToolchain() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFeatureVersion(t *testing.T) {
+	testCases := []struct {
+		version string
+		want    string
+	}{
+		{version: "17", want: "17"},
+		{version: "1.8", want: "8"},
+		{version: "11.0.6+10", want: "11"},
+		{version: "1", want: "1"},
+		{version: "${java.version}", want: ""},
+		{version: "", want: ""},
+	}
+	for _, tc := range testCases {
+		if got := FeatureVersion(tc.version); got != tc.want {
+			t.Errorf("FeatureVersion(%q) = %q, want %q", tc.version, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kms encrypts and decrypts small payloads, such as data encryption keys, with Cloud KMS keys.
+package kms
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/gcpapi"
+)
+
+const defaultCryptoURL = "https://cloudkms.googleapis.com/v1/%s:%s"
+
+var (
+	keyNameRegexp = regexp.MustCompile(`^projects/[^/]+/locations/[^/]+/keyRings/[^/]+/cryptoKeys/[^/]+$`)
+)
+
+// ValidateKeyName returns an error if name is not the resource name of a crypto key.
+func ValidateKeyName(name string) error {
+	if !keyNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid key name %q, expected projects/<project>/locations/<location>/keyRings/<key ring>/cryptoKeys/<key>", name)
+	}
+	return nil
+}
+
+// Client calls Cloud KMS using the ambient credentials of the metadata server.
+type Client struct {
+	api       *gcpapi.Client
+	cryptoURL string
+}
+
+// NewClient creates a client using the default service account of the instance.
+func NewClient() *Client {
+	return &Client{
+		api:       gcpapi.NewClient(),
+		cryptoURL: defaultCryptoURL,
+	}
+}
+
+// Encrypt encrypts plaintext with the primary version of the crypto key named key.
+func (c *Client) Encrypt(key string, plaintext []byte) ([]byte, error) {
+	var resp struct {
+		Ciphertext string `json:"ciphertext"`
+	}
+	if err := c.call(key, "encrypt", map[string]string{"plaintext": base64.StdEncoding.EncodeToString(plaintext)}, &resp); err != nil {
+		return nil, err
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(resp.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("decoding ciphertext from %s: %w", key, err)
+	}
+	return ciphertext, nil
+}
+
+// Decrypt decrypts ciphertext that was encrypted with the crypto key named key.
+func (c *Client) Decrypt(key string, ciphertext []byte) ([]byte, error) {
+	var resp struct {
+		Plaintext string `json:"plaintext"`
+	}
+	if err := c.call(key, "decrypt", map[string]string{"ciphertext": base64.StdEncoding.EncodeToString(ciphertext)}, &resp); err != nil {
+		return nil, err
+	}
+	plaintext, err := base64.StdEncoding.DecodeString(resp.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("decoding plaintext from %s: %w", key, err)
+	}
+	return plaintext, nil
+}
+
+// call sends body to the method of the crypto key named key and decodes the response into v.
+func (c *Client) call(key, method string, body map[string]string, v interface{}) error {
+	if err := ValidateKeyName(key); err != nil {
+		return err
+	}
+	if err := c.api.Post(fmt.Sprintf(c.cryptoURL, key, method), body, v); err != nil {
+		return fmt.Errorf("calling %s on %s: %w", method, key, err)
+	}
+	return nil
+}
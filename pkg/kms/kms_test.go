@@ -0,0 +1,121 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kms
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testKey = "projects/p/locations/global/keyRings/r/cryptoKeys/k"
+
+func TestValidateKeyName(t *testing.T) {
+	testCases := []struct {
+		name    string
+		wantErr bool
+	}{
+		{name: testKey},
+		{name: "projects/p/locations/global/keyRings/r", wantErr: true},
+		{name: testKey + "/cryptoKeyVersions/1", wantErr: true},
+		{name: "", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateKeyName(tc.name)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("ValidateKeyName(%q) got error: %v, want error: %t", tc.name, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	tokenRequests := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			http.Error(w, "missing Metadata-Flavor", http.StatusForbidden)
+			return
+		}
+		tokenRequests++
+		fmt.Fprint(w, `{"access_token": "tok", "expires_in": 3600}`)
+	})
+	// The fake key "encrypts" by reversing the payload.
+	mux.HandleFunc("/v1/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			http.Error(w, "unauthenticated", http.StatusUnauthorized)
+			return
+		}
+		var req map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var in, out string
+		switch r.URL.Path {
+		case "/v1/" + testKey + ":encrypt":
+			in, out = "plaintext", "ciphertext"
+		case "/v1/" + testKey + ":decrypt":
+			in, out = "ciphertext", "plaintext"
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		b, err := base64.StdEncoding.DecodeString(req[in])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+			b[i], b[j] = b[j], b[i]
+		}
+		json.NewEncoder(w).Encode(map[string]string{out: base64.StdEncoding.EncodeToString(b)})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c := NewClient()
+	c.api.TokenURL = server.URL + "/token"
+	c.cryptoURL = server.URL + "/v1/%s:%s"
+
+	ciphertext, err := c.Encrypt(testKey, []byte("data key"))
+	if err != nil {
+		t.Fatalf("Encrypt() returned error: %v", err)
+	}
+	if got, want := string(ciphertext), "yek atad"; got != want {
+		t.Errorf("Encrypt() = %q, want %q", got, want)
+	}
+	plaintext, err := c.Decrypt(testKey, ciphertext)
+	if err != nil {
+		t.Fatalf("Decrypt() returned error: %v", err)
+	}
+	if got, want := string(plaintext), "data key"; got != want {
+		t.Errorf("Decrypt() = %q, want %q", got, want)
+	}
+	if tokenRequests != 1 {
+		t.Errorf("Encrypt() and Decrypt() fetched %d tokens, want 1", tokenRequests)
+	}
+
+	if _, err := c.Decrypt("projects/p/locations/global/keyRings/r/cryptoKeys/missing", ciphertext); err == nil {
+		t.Errorf("Decrypt() with missing key returned nil error")
+	}
+	if _, err := c.Encrypt("invalid", nil); err == nil {
+		t.Errorf("Encrypt() with invalid key name returned nil error")
+	}
+}
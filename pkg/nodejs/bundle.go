@@ -0,0 +1,140 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// esbuildVersion is the version of esbuild used to bundle functions.
+	esbuildVersion = "0.8.57"
+	esbuildLayer   = "esbuild"
+	bundleLayer    = "bundle"
+	// bundleFile is the name of the bundle, which the functions framework loads from its directory.
+	bundleFile = "index.js"
+
+	esbuildVersionKey = "esbuild_version"
+	bundleSizeKey     = "bundle_size"
+)
+
+// frameworkPackage is never bundled, so that functions that register themselves with the functions
+// framework share the instance that serves them.
+const frameworkPackage = "@google-cloud/functions-framework"
+
+// Bundle bundles entry, relative to the application root, and its dependencies into a single minified
+// file in a launch layer, points the functions framework at it, and returns the directory containing it.
+// Packages with native addons and the functions framework stay external and are loaded from
+// node_modules at runtime.
+func Bundle(ctx *gcp.Context, entry string) (string, error) {
+	esbuild := installEsbuild(ctx)
+	addons, err := NativeAddons(ctx.ApplicationRoot())
+	if err != nil {
+		return "", fmt.Errorf("finding native addons: %w", err)
+	}
+	external := append([]string{frameworkPackage}, addons...)
+	if len(addons) > 0 {
+		ctx.Logf("Not bundling packages with native addons: %s", strings.Join(addons, ", "))
+	}
+
+	l := ctx.Layer(bundleLayer, gcp.LaunchLayer)
+	out := filepath.Join(l.Path, bundleFile)
+	args := []string{esbuild, entry, "--bundle", "--minify", "--platform=node", "--format=cjs", "--target=" + esbuildTarget(NodeVersion(ctx)), "--outfile=" + out}
+	for _, e := range external {
+		args = append(args, "--external:"+e)
+	}
+	ctx.Exec(args, gcp.WithUserAttribution)
+
+	fi, err := os.Stat(out)
+	if err != nil {
+		return "", gcp.InternalErrorf("reading bundle: %v", err)
+	}
+	ctx.Logf("Bundled %s into %s (%d bytes)", entry, out, fi.Size())
+	ctx.SetMetadata(l, bundleSizeKey, strconv.FormatInt(fi.Size(), 10))
+	l.LaunchEnvironment.Override(env.FunctionSourceLaunch, l.Path)
+	return l.Path, nil
+}
+
+// installEsbuild installs esbuild in a cached build layer and returns the path to its executable.
+func installEsbuild(ctx *gcp.Context) string {
+	l := ctx.Layer(esbuildLayer, gcp.BuildLayer, gcp.CacheLayer)
+	ctx.AddTool(l, "esbuild", esbuildVersion)
+	if ctx.GetMetadata(l, esbuildVersionKey) == esbuildVersion {
+		ctx.CacheHit(esbuildLayer)
+	} else {
+		ctx.CacheMiss(esbuildLayer)
+		ctx.ClearLayer(l)
+		ctx.Logf("Installing esbuild v%s", esbuildVersion)
+		ctx.Exec([]string{"npm", "install", "--quiet", "--no-save", "--prefix", l.Path, "esbuild@" + esbuildVersion}, gcp.WithTransientRetry, gcp.WithUserAttribution)
+		ctx.SetMetadata(l, esbuildVersionKey, esbuildVersion)
+	}
+	return filepath.Join(l.Path, "node_modules", ".bin", "esbuild")
+}
+
+// esbuildTarget returns the esbuild target for the output of `node -v`, such as node12 for v12.18.3.
+func esbuildTarget(nodeVersion string) string {
+	v := strings.TrimPrefix(strings.TrimSpace(nodeVersion), "v")
+	if i := strings.Index(v, "."); i >= 0 {
+		v = v[:i]
+	}
+	return "node" + v
+}
+
+// NativeAddons returns the top-level packages in the node_modules of root that contain native
+// addons, which esbuild cannot bundle.
+func NativeAddons(root string) ([]string, error) {
+	nm := filepath.Join(root, "node_modules")
+	found := map[string]bool{}
+	err := filepath.Walk(nm, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == nm {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || (info.Name() != "binding.gyp" && filepath.Ext(path) != ".node") {
+			return nil
+		}
+		rel, err := filepath.Rel(nm, path)
+		if err != nil {
+			return err
+		}
+		parts := strings.Split(rel, string(filepath.Separator))
+		switch {
+		case strings.HasPrefix(parts[0], "@") && len(parts) > 2:
+			found[parts[0]+"/"+parts[1]] = true
+		case !strings.HasPrefix(parts[0], "@") && !strings.HasPrefix(parts[0], ".") && len(parts) > 1:
+			found[parts[0]] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var pkgs []string
+	for p := range found {
+		pkgs = append(pkgs, p)
+	}
+	sort.Strings(pkgs)
+	return pkgs, nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNativeAddons(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files []string
+		want  []string
+	}{
+		{
+			name: "no node_modules",
+		},
+		{
+			name:  "pure javascript",
+			files: []string{"node_modules/express/index.js", "node_modules/express/package.json"},
+		},
+		{
+			name: "addons",
+			files: []string{
+				"node_modules/express/index.js",
+				"node_modules/bcrypt/binding.gyp",
+				"node_modules/sharp/build/Release/sharp.node",
+				"node_modules/@grpc/grpc-js/index.js",
+				"node_modules/@scope/native/build/Release/addon.node",
+				"node_modules/a/node_modules/nested/binding.gyp",
+				"node_modules/.bin/binding.gyp",
+			},
+			want: []string{"@scope/native", "a", "bcrypt", "sharp"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root, err := ioutil.TempDir("", "test-native-addons-")
+			if err != nil {
+				t.Fatalf("Failed to create temp dir: %v", err)
+			}
+			defer os.RemoveAll(root)
+			for _, f := range tc.files {
+				path := filepath.Join(root, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("Failed to create dir: %v", err)
+				}
+				if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+					t.Fatalf("Failed to write %s: %v", f, err)
+				}
+			}
+
+			got, err := NativeAddons(root)
+			if err != nil {
+				t.Fatalf("NativeAddons() got error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("NativeAddons() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEsbuildTarget(t *testing.T) {
+	testCases := []struct {
+		version string
+		want    string
+	}{
+		{version: "v12.18.3\n", want: "node12"},
+		{version: "v10.0.0", want: "node10"},
+		{version: "14", want: "node14"},
+	}
+	for _, tc := range testCases {
+		if got := esbuildTarget(tc.version); got != tc.want {
+			t.Errorf("esbuildTarget(%q) = %q, want %q", tc.version, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+var (
+	// intlRegexp matches uses of the Intl API, which needs locale data beyond English.
+	intlRegexp = regexp.MustCompile(`\bIntl\.|\.toLocale(Date|Time)?String\(|\.localeCompare\(`)
+	// sourceExts are the extensions of the app files searched for locale usage.
+	sourceExts = map[string]bool{".js": true, ".mjs": true, ".cjs": true, ".ts": true}
+	// errIntlFound stops the search at the first use of the Intl API.
+	errIntlFound = errors.New("found a use of the Intl API")
+)
+
+// UsesIntl returns true if the app sources in root, excluding node_modules, use the Intl API or
+// locale-sensitive formatting.
+func UsesIntl(root string) (bool, error) {
+	uses := false
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && path != root && (info.Name() == "node_modules" || strings.HasPrefix(info.Name(), ".")) {
+			return filepath.SkipDir
+		}
+		if info.IsDir() || !sourceExts[filepath.Ext(path)] {
+			return nil
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if intlRegexp.Match(content) {
+			uses = true
+			return errIntlFound
+		}
+		return nil
+	})
+	if err != nil && err != errIntlFound {
+		return false, err
+	}
+	return uses, nil
+}
+
+// SmallICU returns true if node was built with English-only ICU data, as Node.js releases before 13
+// were, in which case other locales silently fall back to English.
+func SmallICU(ctx *gcp.Context, node string) bool {
+	result, err := ctx.ExecWithErr([]string{node, "-p", "process.config.variables.icu_small"})
+	if err != nil {
+		ctx.Debugf("Assuming full ICU data: %v", err)
+		return false
+	}
+	return strings.TrimSpace(result.Stdout) == "true"
+}
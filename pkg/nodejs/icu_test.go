@@ -0,0 +1,83 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUsesIntl(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  bool
+	}{
+		{
+			name:  "no locales",
+			files: map[string]string{"index.js": "console.log(new Date().toISOString());\n"},
+		},
+		{
+			name:  "Intl",
+			files: map[string]string{"index.js": "const f = new Intl.NumberFormat('de-DE');\n"},
+			want:  true,
+		},
+		{
+			name:  "toLocaleDateString",
+			files: map[string]string{"src/date.mjs": "export const d = new Date().toLocaleDateString('fr');\n"},
+			want:  true,
+		},
+		{
+			name:  "localeCompare in TypeScript",
+			files: map[string]string{"sort.ts": "names.sort((a, b) => a.localeCompare(b));\n"},
+			want:  true,
+		},
+		{
+			name: "node_modules only",
+			files: map[string]string{
+				"index.js":                  "require('dep');\n",
+				"node_modules/dep/index.js": "new Intl.DateTimeFormat();\n",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "icu")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			for name, content := range tc.files {
+				path := filepath.Join(dir, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating %s: %v", filepath.Dir(path), err)
+				}
+				if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatalf("writing %s: %v", path, err)
+				}
+			}
+
+			got, err := UsesIntl(dir)
+			if err != nil {
+				t.Fatalf("UsesIntl() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("UsesIntl() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
@@ -37,12 +38,18 @@ const (
 	dependencyHashKey = "dependency_hash"
 )
 
+var (
+	// buildOutputDirs are the directories to which build scripts conventionally write their output.
+	buildOutputDirs = []string{"dist", "build"}
+)
+
 type packageEnginesJSON struct {
 	Node string `json:"node"`
 }
 
 type packageScriptsJSON struct {
 	Start    string `json:"start"`
+	Build    string `json:"build"`
 	GCPBuild string `json:"gcp-build"`
 }
 
@@ -103,8 +110,14 @@ func CheckCache(ctx *gcp.Context, l *libcnb.Layer, opts ...cache.Option) (bool,
 		return true, nil
 	}
 
-	if metaDependencyHash == "" {
+	switch metaNodeVersion := ctx.GetMetadata(l, nodeVersionKey); {
+	case metaDependencyHash == "":
 		ctx.Debugf("No metadata found from a previous build, skipping cache.")
+		ctx.ExplainCacheMiss(l.Name, "no cached dependencies from a previous build")
+	case metaNodeVersion != currentNodeVersion:
+		ctx.ExplainCacheMiss(l.Name, "Node.js version changed from %s to %s", strings.TrimSpace(metaNodeVersion), strings.TrimSpace(currentNodeVersion))
+	default:
+		ctx.ExplainCacheMiss(l.Name, "dependencies or their configuration changed")
 	}
 	ctx.Logf("Installing application dependencies.")
 
@@ -114,3 +127,17 @@ func CheckCache(ctx *gcp.Context, l *libcnb.Layer, opts ...cache.Option) (bool,
 
 	return false, nil
 }
+
+// ExportBuildOutput exports the output of the build script, in the first of the conventional output
+// directories that exists, with gcpbuildpack.ExportArtifacts.
+func ExportBuildOutput(ctx *gcp.Context) error {
+	for _, dir := range buildOutputDirs {
+		if ctx.FileExists(dir) {
+			return ctx.ExportArtifacts(dir)
+		}
+	}
+	if dir, err := gcp.ArtifactDir(); err == nil && dir != "" {
+		ctx.Warnf("Not exporting the build output: none of %v exists.", buildOutputDirs)
+	}
+	return nil
+}
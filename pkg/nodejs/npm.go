@@ -15,8 +15,10 @@
 package nodejs
 
 import (
+	"os"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 )
 
@@ -36,11 +38,20 @@ func EnsureLockfile(ctx *gcp.Context) string {
 	if !ctx.FileExists(PackageLock) {
 		ctx.Logf("Generating %s.", PackageLock)
 		ctx.Warnf("*** Improve build performance by generating and committing %s.", PackageLock)
-		ctx.Exec([]string{"npm", "install", "--package-lock-only", "--quiet"}, gcp.WithUserAttribution)
+		ctx.Exec([]string{"npm", "install", "--package-lock-only", "--quiet"}, gcp.WithTransientRetry, gcp.WithUserAttribution)
 	}
 	return PackageLock
 }
 
+// PurgeNPMCache removes the npm cache, so that an install re-run with gcp.WithCorruptCacheRetry
+// downloads its packages again.
+func PurgeNPMCache(ctx *gcp.Context) error {
+	if _, err := ctx.ExecWithErr([]string{"npm", "cache", "clean", "--force"}); err != nil {
+		return err
+	}
+	return nil
+}
+
 // NPMInstallCommand returns the correct install command based on the version of Node.js.
 func NPMInstallCommand(ctx *gcp.Context) string {
 	// HACK: For backwards compatibility on App Engine Node.js 10, always use `npm install`.
@@ -49,3 +60,25 @@ func NPMInstallCommand(ctx *gcp.Context) string {
 	}
 	return "ci"
 }
+
+// AllowedScripts returns the packages whose install scripts run despite env.NPMIgnoreScripts.
+func AllowedScripts() []string {
+	var pkgs []string
+	for _, p := range strings.Split(os.Getenv(env.NPMAllowScripts), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			pkgs = append(pkgs, p)
+		}
+	}
+	return pkgs
+}
+
+// RebuildAllowed runs the install scripts of the allowed packages that were installed with
+// --ignore-scripts, so that native modules still work.
+func RebuildAllowed(ctx *gcp.Context, nodeEnv string) {
+	pkgs := AllowedScripts()
+	if len(pkgs) == 0 {
+		return
+	}
+	ctx.Logf("Running install scripts of %s", strings.Join(pkgs, ", "))
+	ctx.Exec(append([]string{"npm", "rebuild", "--quiet"}, pkgs...), gcp.WithEnv("NODE_ENV="+nodeEnv), gcp.WithUserAttribution)
+}
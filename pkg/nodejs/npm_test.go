@@ -0,0 +1,41 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+func TestAllowedScripts(t *testing.T) {
+	testCases := []struct {
+		value string
+		want  []string
+	}{
+		{value: "", want: nil},
+		{value: "bcrypt", want: []string{"bcrypt"}},
+		{value: " bcrypt, @scope/sharp ,", want: []string{"bcrypt", "@scope/sharp"}},
+	}
+	defer os.Unsetenv(env.NPMAllowScripts)
+	for _, tc := range testCases {
+		os.Setenv(env.NPMAllowScripts, tc.value)
+		if got := AllowedScripts(); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("AllowedScripts() with %q = %v, want %v", tc.value, got, tc.want)
+		}
+	}
+}
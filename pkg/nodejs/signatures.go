@@ -0,0 +1,148 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// VerifyWarn logs packages whose signatures or attestations cannot be verified.
+	VerifyWarn = "warn"
+	// VerifyFail fails the build for packages whose signatures or attestations cannot be verified.
+	VerifyFail = "fail"
+)
+
+// unverifiedPackage is a package reported by `npm audit signatures --json`.
+type unverifiedPackage struct {
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	Location string `json:"location"`
+	Registry string `json:"registry"`
+	Code     string `json:"code"`
+}
+
+// signatureAudit is the output of `npm audit signatures --json`: packages with invalid signatures or
+// attestations, and packages from registries that sign packages but that are missing a signature.
+type signatureAudit struct {
+	Invalid []unverifiedPackage `json:"invalid"`
+	Missing []unverifiedPackage `json:"missing"`
+}
+
+// VerifySignaturesMode returns the verification requested with env.NodeJSVerifySignatures, VerifyWarn
+// or VerifyFail, or "" if signatures are not verified.
+func VerifySignaturesMode() (string, error) {
+	switch v := os.Getenv(env.NodeJSVerifySignatures); v {
+	case "", VerifyWarn, VerifyFail:
+		return v, nil
+	default:
+		return "", gcp.UserErrorf("invalid %s %q, must be %s or %s", env.NodeJSVerifySignatures, v, VerifyWarn, VerifyFail)
+	}
+}
+
+// VerifySignatures verifies the registry signatures and provenance attestations of the packages
+// installed in node_modules with `npm audit signatures`, if requested with env.NodeJSVerifySignatures.
+// Packages installed with yarn are verified too, since npm reads the installed tree. Failing to run the
+// verification only fails the build in VerifyFail mode or strict mode.
+func VerifySignatures(ctx *gcp.Context) error {
+	mode, err := VerifySignaturesMode()
+	if err != nil || mode == "" {
+		return err
+	}
+	sev := gcp.Optional
+	if mode == VerifyFail {
+		sev = gcp.Critical
+	}
+	var unverified []string
+	if err := ctx.RunStep("npm signature verification", sev, func() error {
+		// npm audit signatures exits with an error when a package cannot be verified.
+		result, err := ctx.ExecWithErr([]string{"npm", "audit", "signatures", "--json"}, gcp.WithUserAttribution)
+		if result == nil {
+			return err
+		}
+		audit, perr := parseSignatureAudit(result.Stdout)
+		if perr != nil {
+			if err != nil {
+				return err
+			}
+			return perr
+		}
+		unverified = reportUnverified(ctx, audit, criticalPackages())
+		return nil
+	}); err != nil {
+		return err
+	}
+	if mode == VerifyFail && len(unverified) > 0 {
+		return gcp.UserErrorf("npm packages failed signature verification: %s; see https://docs.npmjs.com/verifying-registry-signatures", strings.Join(unverified, ", "))
+	}
+	return nil
+}
+
+func parseSignatureAudit(out string) (*signatureAudit, error) {
+	var audit signatureAudit
+	if err := json.Unmarshal([]byte(out), &audit); err != nil {
+		return nil, fmt.Errorf("parsing npm audit signatures output: %v", err)
+	}
+	return &audit, nil
+}
+
+// criticalPackages returns the packages listed in env.NodeJSCriticalPackages, or nil if every package
+// is critical.
+func criticalPackages() map[string]bool {
+	v := os.Getenv(env.NodeJSCriticalPackages)
+	if v == "" {
+		return nil
+	}
+	pkgs := map[string]bool{}
+	for _, p := range strings.Split(v, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			pkgs[p] = true
+		}
+	}
+	return pkgs
+}
+
+// reportUnverified logs the unverified packages of audit and returns those that are critical, as
+// name@version. A nil critical map makes every package critical.
+func reportUnverified(ctx *gcp.Context, audit *signatureAudit, critical map[string]bool) []string {
+	var result []string
+	report := func(pkgs []unverifiedPackage, problem string) {
+		for _, p := range pkgs {
+			id := p.Name + "@" + p.Version
+			if p.Code != "" {
+				ctx.Warnf("npm package %s %s: %s", id, problem, p.Code)
+			} else {
+				ctx.Warnf("npm package %s %s", id, problem)
+			}
+			if critical == nil || critical[p.Name] {
+				result = append(result, id)
+			}
+		}
+	}
+	report(audit.Invalid, "has an invalid registry signature or provenance attestation")
+	report(audit.Missing, "is missing a registry signature")
+	if len(audit.Invalid)+len(audit.Missing) == 0 {
+		ctx.Logf("Verified the registry signatures of the installed npm packages")
+	}
+	sort.Strings(result)
+	return result
+}
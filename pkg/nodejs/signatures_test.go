@@ -0,0 +1,88 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runner"
+	"github.com/buildpacks/libcnb"
+)
+
+// auditExecutor writes the output of npm audit signatures instead of running it.
+type auditExecutor struct {
+	output   string
+	exitCode int
+}
+
+func (e auditExecutor) Run(cmd *exec.Cmd) (int, error) {
+	io.WriteString(cmd.Stdout, e.output)
+	return e.exitCode, nil
+}
+
+func TestVerifySignatures(t *testing.T) {
+	unverified := `{"invalid":[{"name":"left-pad","version":"1.3.0","location":"node_modules/left-pad","code":"EINTEGRITYSIGNATURE"}],"missing":[]}`
+	testCases := []struct {
+		name     string
+		mode     string
+		critical string
+		output   string
+		exitCode int
+		wantErr  bool
+	}{
+		{name: "disabled", output: "not json", exitCode: 1},
+		{name: "verified", mode: VerifyFail, output: `{"invalid":[],"missing":[]}`},
+		{name: "warn", mode: VerifyWarn, output: unverified, exitCode: 1},
+		{name: "fail", mode: VerifyFail, output: unverified, exitCode: 1, wantErr: true},
+		{name: "fail other critical package", mode: VerifyFail, critical: "express", output: unverified, exitCode: 1},
+		{name: "fail critical package", mode: VerifyFail, critical: "express, left-pad", output: unverified, exitCode: 1, wantErr: true},
+		{name: "warn unsupported npm", mode: VerifyWarn, output: "Unknown command", exitCode: 1},
+		{name: "fail unsupported npm", mode: VerifyFail, output: "Unknown command", exitCode: 1, wantErr: true},
+		{name: "invalid mode", mode: "sometimes", wantErr: true},
+	}
+	defer os.Unsetenv(env.NodeJSVerifySignatures)
+	defer os.Unsetenv(env.NodeJSCriticalPackages)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			os.Setenv(env.NodeJSVerifySignatures, tc.mode)
+			os.Setenv(env.NodeJSCriticalPackages, tc.critical)
+			layers, err := ioutil.TempDir("", "layers")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(layers)
+
+			_, err = runner.Build(runner.Config{
+				Buildpack:  libcnb.BuildpackInfo{ID: "google.nodejs.npm", Version: "0.0.1"},
+				LayersRoot: layers,
+				Executor:   auditExecutor{output: tc.output, exitCode: tc.exitCode},
+				Logger:     log.New(ioutil.Discard, "", 0),
+			}, func(ctx *gcp.Context) error {
+				return VerifySignatures(ctx)
+			})
+
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("VerifySignatures() got error: %v, want error: %t", err, tc.wantErr)
+			}
+		})
+	}
+}
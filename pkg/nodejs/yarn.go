@@ -34,3 +34,12 @@ func LockfileFlag(ctx *gcp.Context) string {
 
 	return "--frozen-lockfile"
 }
+
+// PurgeYarnCache removes the yarn cache, so that an install re-run with gcp.WithCorruptCacheRetry
+// downloads its packages again.
+func PurgeYarnCache(ctx *gcp.Context) error {
+	if _, err := ctx.ExecWithErr([]string{"yarn", "cache", "clean"}); err != nil {
+		return err
+	}
+	return nil
+}
@@ -56,8 +56,18 @@ func InstallRequirements(ctx *gcp.Context, l *libcnb.Layer, req string) (string,
 	path := strings.TrimSpace(result.Stdout)
 	l.SharedEnvironment.PrependPath("PYTHONPATH", path)
 
+	installer, err := Installer()
+	if err != nil {
+		return "", err
+	}
+	opts := []cache.Option{cache.WithFiles(req)}
+	if installer != InstallerPip {
+		// Dependencies installed with pip keep their cache key, so that builds that do not choose an installer stay cached.
+		opts = append(opts, cache.WithStrings(installer))
+	}
+
 	// Check if we can use the cached-layer as is without reinstalling dependencies.
-	cached, err := checkCache(ctx, l, cache.WithFiles(req))
+	cached, err := checkCache(ctx, l, opts...)
 	if err != nil {
 		return "", fmt.Errorf("checking cache: %w", err)
 	}
@@ -67,6 +77,11 @@ func InstallRequirements(ctx *gcp.Context, l *libcnb.Layer, req string) (string,
 	}
 	ctx.CacheMiss(l.Name)
 
+	if installer == InstallerUV {
+		uvInstallRequirements(ctx, req, l.Path)
+		return path, nil
+	}
+
 	// pip install --target has several subtle issues:
 	// We cannot use --upgrade: https://github.com/pypa/pip/issues/8799.
 	// We also cannot _not_ use --upgrade, see the requirements_bin_conflict acceptance test.
@@ -87,6 +102,7 @@ func InstallRequirements(ctx *gcp.Context, l *libcnb.Layer, req string) (string,
 		"--prefix", l.Path,
 	},
 		gcp.WithEnv("PIP_CACHE_DIR="+cl.Path),
+		gcp.WithTransientRetry,
 		gcp.WithUserAttribution)
 
 	return path, nil
@@ -113,8 +129,16 @@ func checkCache(ctx *gcp.Context, l *libcnb.Layer, opts ...cache.Option) (bool,
 		return true, nil
 	}
 
-	if metaDependencyHash == "" {
+	switch metaPythonVersion := ctx.GetMetadata(l, pythonVersionKey); {
+	case metaDependencyHash == "":
 		ctx.Debugf("No metadata found from a previous build, skipping cache.")
+		ctx.ExplainCacheMiss(l.Name, "no cached dependencies from a previous build")
+	case metaPythonVersion != currentPythonVersion:
+		ctx.ExplainCacheMiss(l.Name, "Python version changed from %s to %s", metaPythonVersion, currentPythonVersion)
+	case currentDependencyHash != metaDependencyHash:
+		ctx.ExplainCacheMiss(l.Name, "requirements or their configuration changed")
+	default:
+		ctx.ExplainCacheMiss(l.Name, "cached dependencies expired, refreshing unpinned versions")
 	}
 
 	ctx.ClearLayer(l)
@@ -0,0 +1,82 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// InstallerPip installs dependencies with pip.
+	InstallerPip = "pip"
+	// InstallerUV installs dependencies with uv.
+	InstallerUV = "uv"
+
+	// uvVersion is the version of uv installed when dependencies are installed with uv.
+	uvVersion    = "0.4.30"
+	uvLayer      = "uv"
+	uvCacheName  = "uvcache"
+	uvVersionKey = "uv_version"
+)
+
+// Installer returns the tool that installs dependencies, as selected with env.PythonInstaller.
+func Installer() (string, error) {
+	switch v := os.Getenv(env.PythonInstaller); v {
+	case "", InstallerPip:
+		return InstallerPip, nil
+	case InstallerUV:
+		return InstallerUV, nil
+	default:
+		return "", gcp.UserErrorf("invalid %s %q, must be %s or %s", env.PythonInstaller, v, InstallerPip, InstallerUV)
+	}
+}
+
+// installUV installs uv in a cached build layer and returns the path to its executable.
+func installUV(ctx *gcp.Context) string {
+	l := ctx.Layer(uvLayer, gcp.BuildLayer, gcp.CacheLayer)
+	ctx.AddTool(l, "uv", uvVersion)
+	if ctx.GetMetadata(l, uvVersionKey) == uvVersion {
+		ctx.CacheHit(uvLayer)
+	} else {
+		ctx.CacheMiss(uvLayer)
+		ctx.ClearLayer(l)
+		ctx.Logf("Installing uv v%s", uvVersion)
+		ctx.Exec([]string{"python3", "-m", "pip", "install", "--quiet", "--ignore-installed", "--no-warn-script-location", "--prefix", l.Path, "uv==" + uvVersion}, gcp.WithTransientRetry, gcp.WithUserAttribution)
+		ctx.SetMetadata(l, uvVersionKey, uvVersion)
+	}
+	return filepath.Join(l.Path, "bin", "uv")
+}
+
+// uvInstallRequirements installs the dependencies in req into prefix with uv, which keeps its
+// cache in a cached layer.
+func uvInstallRequirements(ctx *gcp.Context, req, prefix string) {
+	uv := installUV(ctx)
+	cl := ctx.Layer(uvCacheName, gcp.CacheLayer)
+	ctx.Exec([]string{
+		uv, "pip", "install",
+		"--requirement", req,
+		"--python", "python3",
+		"--prefix", prefix,
+		// The cache and the dependencies are in different layers, which cannot share hard links.
+		"--link-mode", "copy",
+	},
+		gcp.WithEnv("UV_CACHE_DIR="+cl.Path),
+		gcp.WithTransientRetry,
+		gcp.WithUserAttribution)
+}
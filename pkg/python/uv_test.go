@@ -0,0 +1,53 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"os"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+func TestInstaller(t *testing.T) {
+	testCases := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "default", want: InstallerPip},
+		{name: "pip", value: "pip", want: InstallerPip},
+		{name: "uv", value: "uv", want: InstallerUV},
+		{name: "unknown", value: "poetry", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.value != "" {
+				os.Setenv(env.PythonInstaller, tc.value)
+				defer os.Unsetenv(env.PythonInstaller)
+			}
+
+			got, err := Installer()
+
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("Installer() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("Installer() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
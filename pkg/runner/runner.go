@@ -0,0 +1,146 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package runner runs buildpack detect and build functions in-process, so that other tools, such
+// as local CLIs and test harnesses, can embed buildpacks without spawning their binaries through
+// a lifecycle.
+package runner
+
+import (
+	"fmt"
+	"log"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// optOutCode is the exit code with which a buildpack opts out of detection.
+	optOutCode = 100
+)
+
+// Config describes the buildpack to run and the environment to run it in.
+type Config struct {
+	// Buildpack identifies the buildpack.
+	Buildpack libcnb.BuildpackInfo
+	// BuildpackRoot is the directory containing buildpack.toml, if any.
+	BuildpackRoot string
+	// ApplicationRoot is the directory containing the application source.
+	ApplicationRoot string
+	// LayersRoot is the directory in which the build creates layers. It is required by Build.
+	LayersRoot string
+	// Plan is the buildpack plan passed to the build.
+	Plan libcnb.BuildpackPlan
+	// Bindings are the service bindings, such as build secrets, passed to the build.
+	Bindings libcnb.Bindings
+
+	// FileSystem, if set, replaces the file system used by the Context file helpers.
+	FileSystem gcp.FileSystem
+	// Executor, if set, replaces how commands are run.
+	Executor gcp.Executor
+	// Logger, if set, replaces logging to stderr.
+	Logger *log.Logger
+}
+
+// exit is the panic value with which the buildpack exits.
+type exit struct {
+	code int
+	err  *gcp.Error
+}
+
+// panicExiter unwinds the detect or build function instead of exiting the process.
+type panicExiter struct{}
+
+func (panicExiter) Exit(exitCode int, be *gcp.Error) {
+	panic(exit{code: exitCode, err: be})
+}
+
+func (c Config) options() []gcp.ContextOption {
+	opts := []gcp.ContextOption{gcp.WithExiter(panicExiter{})}
+	if c.FileSystem != nil {
+		opts = append(opts, gcp.WithFileSystem(c.FileSystem))
+	}
+	if c.Executor != nil {
+		opts = append(opts, gcp.WithExecutor(c.Executor))
+	}
+	if c.Logger != nil {
+		opts = append(opts, gcp.WithLogger(c.Logger))
+	}
+	return opts
+}
+
+// Detect runs detectFn and reports whether the buildpack passed detection. Opting out is not an error.
+func Detect(c Config, detectFn gcp.DetectFn) (result libcnb.DetectResult, err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		e, ok := r.(exit)
+		if !ok {
+			panic(r)
+		}
+		switch {
+		case e.code == 0:
+			result.Pass = true
+		case e.code == optOutCode && e.err == nil:
+			result.Pass = false
+		default:
+			err = exitError(e)
+		}
+	}()
+
+	ldctx := libcnb.DetectContext{
+		Application: libcnb.Application{Path: c.ApplicationRoot},
+		Buildpack:   libcnb.Buildpack{Info: c.Buildpack, Path: c.BuildpackRoot},
+	}
+	return gcp.RunDetect(ldctx, detectFn, c.options()...)
+}
+
+// Build runs buildFn and returns the layers, processes and labels it contributed. Layer metadata is
+// not written to LayersRoot; callers that need it must write it themselves.
+func Build(c Config, buildFn gcp.BuildFn) (result libcnb.BuildResult, err error) {
+	if c.LayersRoot == "" {
+		return libcnb.BuildResult{}, fmt.Errorf("running build of %s: layers root is required", c.Buildpack.ID)
+	}
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		e, ok := r.(exit)
+		if !ok {
+			panic(r)
+		}
+		if e.code != 0 {
+			err = exitError(e)
+		}
+	}()
+
+	lbctx := libcnb.BuildContext{
+		Application: libcnb.Application{Path: c.ApplicationRoot},
+		Buildpack:   libcnb.Buildpack{Info: c.Buildpack, Path: c.BuildpackRoot},
+		Layers:      libcnb.Layers{Path: c.LayersRoot},
+		Plan:        c.Plan,
+		Platform:    libcnb.Platform{Bindings: c.Bindings},
+	}
+	return gcp.RunBuild(lbctx, buildFn, c.options()...)
+}
+
+func exitError(e exit) error {
+	if e.err != nil {
+		return e.err
+	}
+	return fmt.Errorf("buildpack exited with code %d", e.code)
+}
@@ -0,0 +1,173 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+// fakeExecutor records the commands it is asked to run and writes output instead of running them.
+type fakeExecutor struct {
+	output   string
+	exitCode int
+	commands [][]string
+}
+
+func (e *fakeExecutor) Run(cmd *exec.Cmd) (int, error) {
+	e.commands = append(e.commands, cmd.Args)
+	io.WriteString(cmd.Stdout, e.output)
+	return e.exitCode, nil
+}
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name     string
+		detectFn gcp.DetectFn
+		wantPass bool
+		wantErr  bool
+	}{
+		{
+			name:     "pass",
+			detectFn: func(*gcp.Context) error { return nil },
+			wantPass: true,
+		},
+		{
+			name:     "opt in",
+			detectFn: func(ctx *gcp.Context) error { ctx.OptIn("found it"); return nil },
+			wantPass: true,
+		},
+		{
+			name:     "opt out",
+			detectFn: func(ctx *gcp.Context) error { ctx.OptOut("not found"); return nil },
+		},
+		{
+			name:     "error",
+			detectFn: func(*gcp.Context) error { return gcp.UserErrorf("broken") },
+			wantErr:  true,
+		},
+		{
+			name: "exit",
+			detectFn: func(ctx *gcp.Context) error {
+				ctx.Exit(1, gcp.UserErrorf("broken"))
+				return nil
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "app")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			var buf bytes.Buffer
+
+			got, err := Detect(Config{
+				Buildpack:       libcnb.BuildpackInfo{ID: "my-buildpack", Version: "0.0.1"},
+				ApplicationRoot: dir,
+				Logger:          log.New(&buf, "", 0),
+			}, tc.detectFn)
+
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("Detect() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if !tc.wantErr && got.Pass != tc.wantPass {
+				t.Errorf("Detect() pass = %t, want %t; logs:\n%s", got.Pass, tc.wantPass, buf.String())
+			}
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	layers, err := ioutil.TempDir("", "layers")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(layers)
+	var buf bytes.Buffer
+	executor := &fakeExecutor{output: "go version go1.14.4 linux/amd64"}
+
+	var stdout string
+	got, err := Build(Config{
+		Buildpack:  libcnb.BuildpackInfo{ID: "my-buildpack", Version: "0.0.1"},
+		LayersRoot: layers,
+		Executor:   executor,
+		Logger:     log.New(&buf, "", 0),
+	}, func(ctx *gcp.Context) error {
+		ctx.Layer("bin", gcp.LaunchLayer)
+		stdout = ctx.Exec([]string{"go", "version"}).Stdout
+		ctx.AddWebProcess([]string{"/layers/bin/main"})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Build() got error: %v", err)
+	}
+
+	if want := executor.output; stdout != want {
+		t.Errorf("Exec() stdout = %q, want %q", stdout, want)
+	}
+	if want := [][]string{{"go", "version"}}; !reflect.DeepEqual(executor.commands, want) {
+		t.Errorf("executed commands = %q, want %q", executor.commands, want)
+	}
+	if len(got.Processes) != 1 {
+		t.Errorf("Build() processes = %v, want 1 process", got.Processes)
+	}
+	if !strings.Contains(buf.String(), "my-buildpack") {
+		t.Errorf("logs do not mention the buildpack:\n%s", buf.String())
+	}
+}
+
+func TestBuildFailure(t *testing.T) {
+	layers, err := ioutil.TempDir("", "layers")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(layers)
+
+	_, err = Build(Config{
+		Buildpack:  libcnb.BuildpackInfo{ID: "my-buildpack", Version: "0.0.1"},
+		LayersRoot: layers,
+		Executor:   &fakeExecutor{exitCode: 2},
+		Logger:     log.New(ioutil.Discard, "", 0),
+	}, func(ctx *gcp.Context) error {
+		ctx.Exec([]string{"go", "build"})
+		t.Error("Exec() returned after a failed command")
+		return nil
+	})
+
+	var be *gcp.Error
+	if !errors.As(err, &be) {
+		t.Fatalf("Build() got error: %v, want a buildpack error", err)
+	}
+}
+
+func TestBuildRequiresLayers(t *testing.T) {
+	if _, err := Build(Config{}, func(*gcp.Context) error { return nil }); err == nil {
+		t.Error("Build() got nil error, want error")
+	}
+}
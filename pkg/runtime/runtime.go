@@ -21,6 +21,29 @@ import (
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/blang/semver"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// ChannelStable is the default channel of runtime versions, the stable releases.
+	ChannelStable = "stable"
+	// ChannelBeta is the channel of pre-release versions, such as release candidates.
+	ChannelBeta = "beta"
+	// ChannelNightly is the channel of nightly builds.
+	ChannelNightly = "nightly"
+
+	// PatchPolicyAuto, the default patch policy, installs the latest patch release of resolved versions.
+	PatchPolicyAuto = "auto"
+	// PatchPolicyPin keeps the patch release installed by the previous build.
+	PatchPolicyPin = "pin"
+
+	channelLabel = "runtime_channel"
+
+	// versionMetadata is the layer metadata in which runtime buildpacks record the installed version,
+	// and versionRequestMetadata the one in which ApplyPatchPolicy records what it was resolved from.
+	versionMetadata        = "version"
+	versionRequestMetadata = "version_request"
 )
 
 // CheckOverride checks GOOGLE_RUNTIME and opts in or opts out as appropriate. If GOOGLE_RUNTIME is not set, or invalid, no action is taken.
@@ -35,3 +58,88 @@ func CheckOverride(ctx *gcp.Context, wantRuntime string) {
 	}
 	ctx.OptIn("Opting in: %s set to %q.", env.Runtime, wantRuntime)
 }
+
+// SmokeTest runs cmd, a trivial program of the runtime that was just installed, and returns an error
+// attributed to the platform if it fails. A runtime that installed but cannot run such a program is
+// broken by the builder, e.g. by shared libraries missing from the stack, rather than by the user.
+func SmokeTest(ctx *gcp.Context, runtime string, cmd []string) error {
+	if _, err := ctx.ExecWithErr(cmd, gcp.WithCombinedTail); err != nil {
+		return gcp.InternalErrorf("the installed %s runtime failed to run %q, which indicates a problem with the builder rather than the application: %s", runtime, strings.Join(cmd, " "), err.Message)
+	}
+	ctx.Logf("Verified that the installed %s runtime runs", runtime)
+	return nil
+}
+
+// Channel returns the channel requested with env.RuntimeChannel, ChannelStable if it is not set.
+func Channel() (string, error) {
+	c := strings.ToLower(strings.TrimSpace(os.Getenv(env.RuntimeChannel)))
+	switch c {
+	case "":
+		return ChannelStable, nil
+	case ChannelStable, ChannelBeta, ChannelNightly:
+		return c, nil
+	}
+	return "", gcp.UserErrorf("unsupported %s %q, must be one of %s, %s, %s", env.RuntimeChannel, c, ChannelStable, ChannelBeta, ChannelNightly)
+}
+
+// ChannelConfig returns the source of the runtime version that sets it to channel unless it is
+// ChannelStable. The caller resolves the latest version of the channel if the source is chosen.
+func ChannelConfig(channel string) gcp.ConfigSource {
+	return gcp.ConfigSource{Name: env.RuntimeChannel, Value: func(*gcp.Context) (string, bool, error) {
+		return channel, channel != ChannelStable, nil
+	}}
+}
+
+// UsePrerelease warns that version of the runtime was resolved from a pre-release channel, and labels
+// the image with the channel, so that images built for testing are recognizable.
+func UsePrerelease(ctx *gcp.Context, runtime, channel, version string) {
+	ctx.Warnf("Using %s %s from the %s channel. Pre-release runtimes are not supported; use them to test applications against upcoming versions, never in production.", runtime, version, channel)
+	ctx.AddLabel(channelLabel, channel)
+}
+
+// PatchPolicy returns the policy requested with env.RuntimePatchPolicy, PatchPolicyAuto if it is not set.
+func PatchPolicy() (string, error) {
+	p := strings.ToLower(strings.TrimSpace(os.Getenv(env.RuntimePatchPolicy)))
+	switch p {
+	case "":
+		return PatchPolicyAuto, nil
+	case PatchPolicyAuto, PatchPolicyPin:
+		return p, nil
+	}
+	return "", gcp.UserErrorf("unsupported %s %q, must be one of %s, %s", env.RuntimePatchPolicy, p, PatchPolicyAuto, PatchPolicyPin)
+}
+
+// ApplyPatchPolicy returns the version of runtime to install in l, the layer caching the runtime,
+// given resolved, the version that the build resolved from request, such as a version range or
+// "latest". request is empty for exact versions, which are always installed. With PatchPolicyPin, the
+// version installed by the previous build is kept if it was resolved from the same request and only
+// differs from resolved in its patch version. With PatchPolicyAuto, resolved is installed, with a
+// warning if it is a newer patch release than the version installed by the previous build.
+func ApplyPatchPolicy(ctx *gcp.Context, l *libcnb.Layer, runtime, policy, request, resolved string) string {
+	cached := ctx.GetMetadata(l, versionMetadata)
+	cachedRequest := ctx.GetMetadata(l, versionRequestMetadata)
+	if request == "" {
+		delete(l.Metadata, versionRequestMetadata)
+	} else {
+		ctx.SetMetadata(l, versionRequestMetadata, request)
+	}
+	if request == "" || cached == "" || cached == resolved {
+		return resolved
+	}
+	r, err := semver.ParseTolerant(resolved)
+	if err != nil {
+		return resolved
+	}
+	c, err := semver.ParseTolerant(cached)
+	if err != nil || r.Major != c.Major || r.Minor != c.Minor {
+		return resolved
+	}
+	if policy == PatchPolicyPin && request == cachedRequest {
+		ctx.Logf("Keeping %s %s, installed by the previous build, instead of %s, as %s=%s", runtime, cached, resolved, env.RuntimePatchPolicy, PatchPolicyPin)
+		return cached
+	}
+	if r.GT(c) {
+		ctx.Warnf("%s was upgraded from %s to %s since the previous build. Set %s=%s to keep the patch version of the previous build.", runtime, cached, resolved, env.RuntimePatchPolicy, PatchPolicyPin)
+	}
+	return resolved
+}
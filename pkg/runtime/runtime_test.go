@@ -0,0 +1,165 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestSmokeTest(t *testing.T) {
+	testCases := []struct {
+		name    string
+		cmd     []string
+		wantErr bool
+	}{
+		{
+			name: "runs",
+			cmd:  []string{"true"},
+		},
+		{
+			name:    "fails",
+			cmd:     []string{"bash", "-c", "echo 'error while loading shared libraries' >&2; exit 127"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := gcp.NewContextForTests(libcnb.BuildpackInfo{}, ".")
+
+			err := SmokeTest(ctx, "Test", tc.cmd)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("SmokeTest() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if !tc.wantErr {
+				return
+			}
+			be, ok := err.(*gcp.Error)
+			if !ok || be.Status != gcp.StatusInternal {
+				t.Errorf("SmokeTest() got error: %#v, want an error with status %v", err, gcp.StatusInternal)
+			}
+		})
+	}
+}
+
+func TestChannel(t *testing.T) {
+	testCases := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "", want: ChannelStable},
+		{value: "stable", want: ChannelStable},
+		{value: "Beta", want: ChannelBeta},
+		{value: " nightly ", want: ChannelNightly},
+		{value: "alpha", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			os.Setenv("GOOGLE_RUNTIME_CHANNEL", tc.value)
+			defer os.Unsetenv("GOOGLE_RUNTIME_CHANNEL")
+
+			got, err := Channel()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("Channel() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("Channel() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPatchPolicy(t *testing.T) {
+	testCases := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "", want: PatchPolicyAuto},
+		{value: "auto", want: PatchPolicyAuto},
+		{value: " Pin ", want: PatchPolicyPin},
+		{value: "exact", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			os.Setenv("GOOGLE_RUNTIME_PATCH_POLICY", tc.value)
+			defer os.Unsetenv("GOOGLE_RUNTIME_PATCH_POLICY")
+
+			got, err := PatchPolicy()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("PatchPolicy() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("PatchPolicy() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestApplyPatchPolicy(t *testing.T) {
+	testCases := []struct {
+		name          string
+		policy        string
+		request       string
+		resolved      string
+		cached        string
+		cachedRequest string
+		want          string
+		wantWarn      bool
+	}{
+		{name: "first build", policy: PatchPolicyPin, request: "20.x", resolved: "20.11.1", want: "20.11.1"},
+		{name: "unchanged", policy: PatchPolicyAuto, request: "20.x", resolved: "20.11.1", cached: "20.11.1", cachedRequest: "20.x", want: "20.11.1"},
+		{name: "auto upgrade", policy: PatchPolicyAuto, request: "20.x", resolved: "20.11.1", cached: "20.11.0", cachedRequest: "20.x", want: "20.11.1", wantWarn: true},
+		{name: "pin", policy: PatchPolicyPin, request: "20.x", resolved: "20.11.1", cached: "20.11.0", cachedRequest: "20.x", want: "20.11.0"},
+		{name: "pin go", policy: PatchPolicyPin, request: "latest", resolved: "1.22.1", cached: "1.22", cachedRequest: "latest", want: "1.22"},
+		{name: "pin changed request", policy: PatchPolicyPin, request: ">=20.11.1", resolved: "20.11.1", cached: "20.11.0", cachedRequest: "20.x", want: "20.11.1", wantWarn: true},
+		{name: "pin exact", policy: PatchPolicyPin, resolved: "20.11.1", cached: "20.11.0", cachedRequest: "20.x", want: "20.11.1"},
+		{name: "pin minor upgrade", policy: PatchPolicyPin, request: "20.x", resolved: "20.12.0", cached: "20.11.0", cachedRequest: "20.x", want: "20.12.0"},
+		{name: "auto minor upgrade", policy: PatchPolicyAuto, request: "latest", resolved: "3.12.0", cached: "3.11.8", cachedRequest: "latest", want: "3.12.0"},
+		{name: "auto downgrade", policy: PatchPolicyAuto, request: "20.x", resolved: "20.11.0", cached: "20.11.1", cachedRequest: "20.x", want: "20.11.0"},
+		{name: "unparsable", policy: PatchPolicyPin, request: "latest", resolved: "1.23rc1", cached: "1.23.0", cachedRequest: "latest", want: "1.23rc1"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			ctx := gcp.NewContextForTests(libcnb.BuildpackInfo{}, ".")
+			gcp.WithLogger(log.New(&buf, "", 0))(ctx)
+			l := &libcnb.Layer{Metadata: map[string]interface{}{}}
+			if tc.cached != "" {
+				l.Metadata[versionMetadata] = tc.cached
+				l.Metadata[versionRequestMetadata] = tc.cachedRequest
+			}
+
+			got := ApplyPatchPolicy(ctx, l, "Node.js", tc.policy, tc.request, tc.resolved)
+
+			if got != tc.want {
+				t.Errorf("ApplyPatchPolicy(%q, %q, %q) = %q, want %q", tc.policy, tc.request, tc.resolved, got, tc.want)
+			}
+			if gotWarn := strings.Contains(buf.String(), "WARNING"); gotWarn != tc.wantWarn {
+				t.Errorf("ApplyPatchPolicy(%q, %q, %q) warned: %t, want %t; logs:\n%s", tc.policy, tc.request, tc.resolved, gotWarn, tc.wantWarn, buf.String())
+			}
+			if got, _ := l.Metadata[versionRequestMetadata].(string); got != tc.request {
+				t.Errorf("ApplyPatchPolicy() recorded request %q, want %q", got, tc.request)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package secretmanager resolves Secret Manager references in environment variables.
+package secretmanager
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/gcpapi"
+)
+
+const (
+	// ReferencePrefix marks env var values that refer to a Secret Manager secret version.
+	// Example: `sm://projects/my-project/secrets/db-password/versions/3`; the version defaults to `latest`.
+	ReferencePrefix = "sm://"
+
+	defaultAccessURL = "https://secretmanager.googleapis.com/v1/%s:access"
+)
+
+var (
+	referenceRegexp = regexp.MustCompile(`^projects/[^/]+/secrets/[^/]+(/versions/[^/]+)?$`)
+)
+
+// ParseReference returns the secret version resource name referred to by value.
+func ParseReference(value string) (string, error) {
+	name := strings.TrimPrefix(value, ReferencePrefix)
+	if !referenceRegexp.MatchString(name) {
+		return "", fmt.Errorf("invalid secret reference %q, expected %sprojects/<project>/secrets/<secret>[/versions/<version>]", value, ReferencePrefix)
+	}
+	if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+	return name, nil
+}
+
+// References returns the secret version referred to by each env var in environ whose value starts with ReferencePrefix.
+func References(environ []string) (map[string]string, error) {
+	refs := map[string]string{}
+	var problems []string
+	for _, e := range environ {
+		kv := strings.SplitN(e, "=", 2)
+		if len(kv) != 2 || !strings.HasPrefix(kv[1], ReferencePrefix) {
+			continue
+		}
+		name, err := ParseReference(kv[1])
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", kv[0], err))
+			continue
+		}
+		refs[kv[0]] = name
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return refs, nil
+}
+
+// Client accesses secret versions using the ambient credentials of the metadata server.
+type Client struct {
+	api       *gcpapi.Client
+	accessURL string
+}
+
+// NewClient creates a client using the default service account of the instance.
+func NewClient() *Client {
+	return &Client{
+		api:       gcpapi.NewClient(),
+		accessURL: defaultAccessURL,
+	}
+}
+
+// Resolve returns the plaintext of the secret version referred to by each env var in refs.
+func (c *Client) Resolve(refs map[string]string) (map[string]string, error) {
+	values := map[string]string{}
+	for k, name := range refs {
+		v, err := c.access(name)
+		if err != nil {
+			return nil, fmt.Errorf("resolving %s: %w", k, err)
+		}
+		values[k] = v
+	}
+	return values, nil
+}
+
+func (c *Client) access(name string) (string, error) {
+	var resp struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := c.api.Get(fmt.Sprintf(c.accessURL, name), &resp); err != nil {
+		return "", fmt.Errorf("accessing %s: %w", name, err)
+	}
+	data, err := base64.StdEncoding.DecodeString(resp.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("decoding payload of %s: %w", name, err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secretmanager
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	testCases := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "sm://projects/p/secrets/s", want: "projects/p/secrets/s/versions/latest"},
+		{value: "sm://projects/p/secrets/s/versions/3", want: "projects/p/secrets/s/versions/3"},
+		{value: "sm://projects/p/secrets", wantErr: true},
+		{value: "sm://projects/p/secrets/s/versions/", wantErr: true},
+		{value: "sm://s", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			got, err := ParseReference(tc.value)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ParseReference(%q) got error: %v, want error: %t", tc.value, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("ParseReference(%q) = %q, want %q", tc.value, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReferences(t *testing.T) {
+	got, err := References([]string{"PORT=8080", "DB_PASSWORD=sm://projects/p/secrets/db", "API_KEY=sm://projects/p/secrets/api/versions/2", "EMPTY="})
+	if err != nil {
+		t.Fatalf("References() returned error: %v", err)
+	}
+	want := map[string]string{
+		"DB_PASSWORD": "projects/p/secrets/db/versions/latest",
+		"API_KEY":     "projects/p/secrets/api/versions/2",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("References() = %v, want %v", got, want)
+	}
+
+	if _, err := References([]string{"BAD=sm://nope"}); err == nil {
+		t.Errorf("References() with invalid reference returned nil error")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tokenRequests := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			http.Error(w, "missing Metadata-Flavor", http.StatusForbidden)
+			return
+		}
+		tokenRequests++
+		fmt.Fprint(w, `{"access_token": "tok", "expires_in": 3600}`)
+	})
+	mux.HandleFunc("/v1/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			http.Error(w, "unauthenticated", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/projects/p/secrets/db/versions/latest:access":
+			fmt.Fprint(w, `{"payload": {"data": "aHVudGVyMg=="}}`) // hunter2
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c := NewClient()
+	c.api.TokenURL = server.URL + "/token"
+	c.accessURL = server.URL + "/v1/%s:access"
+
+	got, err := c.Resolve(map[string]string{"A": "projects/p/secrets/db/versions/latest", "B": "projects/p/secrets/db/versions/latest"})
+	if err != nil {
+		t.Fatalf("Resolve() returned error: %v", err)
+	}
+	if want := map[string]string{"A": "hunter2", "B": "hunter2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Resolve() = %v, want %v", got, want)
+	}
+	if tokenRequests != 1 {
+		t.Errorf("Resolve() fetched %d tokens, want 1", tokenRequests)
+	}
+
+	if _, err := c.Resolve(map[string]string{"C": "projects/p/secrets/missing/versions/latest"}); err == nil {
+		t.Errorf("Resolve() of missing secret returned nil error")
+	}
+}
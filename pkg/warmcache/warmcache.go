@@ -0,0 +1,149 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package warmcache locates artifacts that were pre-populated in the builder image so that first
+// builds on fresh workers do not have to download them.
+package warmcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// DefaultDir is where the warm cache lives in the builder image unless overridden with env.WarmCacheDir.
+	DefaultDir = "/var/cache/google-buildpacks"
+
+	// ArchivesDir holds downloaded archives, named after the SHA-256 of their URL.
+	ArchivesDir = "archives"
+	// GoPathDir is used as GOPATH when downloading Go modules; the module cache below it doubles as a GOPROXY.
+	GoPathDir = "go"
+	// PipDir holds downloaded Python distributions and is used as a pip --find-links directory.
+	PipDir = "pip"
+	// NpmDir is used as the npm cache.
+	NpmDir = "npm"
+
+	defaultGoProxy = "https://proxy.golang.org,direct"
+)
+
+// Manifest lists the artifacts to pre-populate in the warm cache.
+type Manifest struct {
+	// Archives are URLs downloaded verbatim, e.g. runtime tarballs.
+	Archives []string `yaml:"archives"`
+	// GoModules are module queries passed to `go get`, e.g. github.com/GoogleCloudPlatform/functions-framework-go@v1.1.0.
+	GoModules []string `yaml:"go_modules"`
+	// PipPackages are requirement specifiers passed to `pip download`, e.g. gunicorn==20.0.4.
+	PipPackages []string `yaml:"pip_packages"`
+	// NpmPackages are package specs passed to `npm cache add`, e.g. express@4.17.1.
+	NpmPackages []string `yaml:"npm_packages"`
+}
+
+// ReadManifest reads and validates the manifest at path.
+func ReadManifest(path string) (*Manifest, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", path, err)
+	}
+	var m Manifest
+	if err := yaml.UnmarshalStrict(raw, &m); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	for _, u := range m.Archives {
+		if !strings.HasPrefix(u, "https://") && !strings.HasPrefix(u, "http://") {
+			return nil, fmt.Errorf("archive %q in %s is not an http(s) URL", u, path)
+		}
+	}
+	for _, mod := range m.GoModules {
+		if !strings.Contains(mod, "@") {
+			return nil, fmt.Errorf("Go module %q in %s must have a version, e.g. %s@v1.0.0", mod, path, mod)
+		}
+	}
+	return &m, nil
+}
+
+// Dir returns the warm cache directory.
+func Dir() string {
+	if d := os.Getenv(env.WarmCacheDir); d != "" {
+		return d
+	}
+	return DefaultDir
+}
+
+// ArchivePath returns the path at which the archive downloaded from url is stored in dir.
+func ArchivePath(dir, url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(dir, ArchivesDir, hex.EncodeToString(sum[:]))
+}
+
+// DownloadCommand returns a shell command that writes the contents of url to stdout, reading it
+// from the warm cache when possible. curlArgs are passed to curl when url is downloaded.
+func DownloadCommand(url string, curlArgs ...string) string {
+	if p := ArchivePath(Dir(), url); isFile(p) {
+		return fmt.Sprintf("cat %s", p)
+	}
+	args := append([]string{"--fail", "--show-error", "--silent", "--location", "--retry", "3"}, curlArgs...)
+	return fmt.Sprintf("curl %s %s", strings.Join(args, " "), url)
+}
+
+// GoProxyDir returns the module cache download directory below dir, which can be served as a GOPROXY.
+func GoProxyDir(dir string) string {
+	return filepath.Join(dir, GoPathDir, "pkg", "mod", "cache", "download")
+}
+
+// Env returns the environment variables that point package managers at the warm cache in dir.
+// Package managers without warmed artifacts are left untouched, as are values set by the user.
+func Env(dir string) []string {
+	var e []string
+	if p := GoProxyDir(dir); isDir(p) {
+		proxy, ok := os.LookupEnv("GOPROXY")
+		if !ok || proxy == "" {
+			proxy = defaultGoProxy
+		}
+		// GOPROXY=off disables all module downloads, including from the local proxy.
+		if proxy != "off" && !strings.Contains(proxy, "file://") {
+			e = append(e, fmt.Sprintf("GOPROXY=file://%s,%s", p, proxy))
+		}
+	}
+	if p := filepath.Join(dir, PipDir); isDir(p) {
+		links := p
+		if v := os.Getenv("PIP_FIND_LINKS"); v != "" {
+			links = v + " " + p
+		}
+		e = append(e, "PIP_FIND_LINKS="+links)
+	}
+	if p := filepath.Join(dir, NpmDir); isDir(p) {
+		if _, ok := os.LookupEnv("npm_config_cache"); !ok {
+			e = append(e, "npm_config_cache="+p)
+		}
+	}
+	return e
+}
+
+func isFile(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.Mode().IsRegular()
+}
+
+func isDir(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
@@ -0,0 +1,197 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package warmcache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+func TestReadManifest(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		want    *Manifest
+		wantErr bool
+	}{
+		{
+			name: "all sections",
+			content: `archives:
+- https://dl.google.com/go/go1.14.4.linux-amd64.tar.gz
+go_modules:
+- github.com/GoogleCloudPlatform/functions-framework-go@v1.1.0
+pip_packages:
+- gunicorn==20.0.4
+npm_packages:
+- express@4.17.1
+`,
+			want: &Manifest{
+				Archives:    []string{"https://dl.google.com/go/go1.14.4.linux-amd64.tar.gz"},
+				GoModules:   []string{"github.com/GoogleCloudPlatform/functions-framework-go@v1.1.0"},
+				PipPackages: []string{"gunicorn==20.0.4"},
+				NpmPackages: []string{"express@4.17.1"},
+			},
+		},
+		{
+			name:    "empty",
+			content: "",
+			want:    &Manifest{},
+		},
+		{
+			name:    "unknown section",
+			content: "gems:\n- rails\n",
+			wantErr: true,
+		},
+		{
+			name:    "archive is not a url",
+			content: "archives:\n- /tmp/go.tar.gz\n",
+			wantErr: true,
+		},
+		{
+			name:    "go module without version",
+			content: "go_modules:\n- github.com/GoogleCloudPlatform/functions-framework-go\n",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "warmcache")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "manifest.yaml")
+			if err := ioutil.WriteFile(path, []byte(tc.content), 0644); err != nil {
+				t.Fatalf("writing manifest: %v", err)
+			}
+
+			got, err := ReadManifest(path)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ReadManifest() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if !tc.wantErr && !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ReadManifest() = %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDownloadCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "warmcache")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Unsetenv(env.WarmCacheDir)
+	if err := os.Setenv(env.WarmCacheDir, dir); err != nil {
+		t.Fatalf("setting %s: %v", env.WarmCacheDir, err)
+	}
+
+	const cached = "https://example.com/cached.tar.gz"
+	p := ArchivePath(dir, cached)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatalf("creating archives dir: %v", err)
+	}
+	if err := ioutil.WriteFile(p, []byte("archive"), 0644); err != nil {
+		t.Fatalf("writing archive: %v", err)
+	}
+
+	if got, want := DownloadCommand(cached), "cat "+p; got != want {
+		t.Errorf("DownloadCommand(%q) = %q, want %q", cached, got, want)
+	}
+	const uncached = "https://example.com/uncached.tar.gz"
+	if got := DownloadCommand(uncached); !strings.HasPrefix(got, "curl ") || !strings.HasSuffix(got, " "+uncached) {
+		t.Errorf("DownloadCommand(%q) = %q, want a curl command", uncached, got)
+	}
+}
+
+func TestEnv(t *testing.T) {
+	testCases := []struct {
+		name string
+		dirs []string
+		env  map[string]string
+		want []string
+	}{
+		{
+			name: "empty cache",
+		},
+		{
+			name: "all package managers",
+			dirs: []string{"go/pkg/mod/cache/download", "pip", "npm"},
+			want: []string{
+				"GOPROXY=file://{dir}/go/pkg/mod/cache/download,https://proxy.golang.org,direct",
+				"PIP_FIND_LINKS={dir}/pip",
+				"npm_config_cache={dir}/npm",
+			},
+		},
+		{
+			name: "user settings are preserved",
+			dirs: []string{"go/pkg/mod/cache/download", "pip", "npm"},
+			env: map[string]string{
+				"GOPROXY":          "https://goproxy.example.com",
+				"PIP_FIND_LINKS":   "/wheels",
+				"npm_config_cache": "/tmp/npm",
+			},
+			want: []string{
+				"GOPROXY=file://{dir}/go/pkg/mod/cache/download,https://goproxy.example.com",
+				"PIP_FIND_LINKS=/wheels {dir}/pip",
+			},
+		},
+		{
+			name: "goproxy off",
+			dirs: []string{"go/pkg/mod/cache/download"},
+			env:  map[string]string{"GOPROXY": "off"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "warmcache")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			for _, d := range tc.dirs {
+				if err := os.MkdirAll(filepath.Join(dir, d), 0755); err != nil {
+					t.Fatalf("creating %s: %v", d, err)
+				}
+			}
+			for _, k := range []string{"GOPROXY", "PIP_FIND_LINKS", "npm_config_cache"} {
+				if v, ok := os.LookupEnv(k); ok {
+					defer os.Setenv(k, v)
+				} else {
+					defer os.Unsetenv(k)
+				}
+				os.Unsetenv(k)
+			}
+			for k, v := range tc.env {
+				os.Setenv(k, v)
+			}
+
+			var want []string
+			for _, w := range tc.want {
+				want = append(want, strings.ReplaceAll(w, "{dir}", dir))
+			}
+			if got := Env(dir); !reflect.DeepEqual(got, want) {
+				t.Errorf("Env() = %q, want %q", got, want)
+			}
+		})
+	}
+}
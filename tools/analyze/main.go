@@ -0,0 +1,289 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The main binary reports whether a source tree would build with a builder, without building it.
+// It runs the detect step of the buildpacks of the builder against the source, the way the
+// lifecycle does, and writes a JSON report of the buildpack group that would build the source, the
+// env vars that buildpacks asked for, and the likely reasons a build would fail. It runs in the
+// builder image, so that consoles and CLIs can validate deployments before staging source.
+//
+// Usage:
+//
+//	CGO_ENABLED=0 go build -o analyze ./tools/analyze
+//	docker run --rm -v $PWD:/workspace -v $PWD/analyze:/analyze --entrypoint /analyze gcr.io/buildpacks/builder:v1 -source /workspace
+//	analyze -source . -cnb-dir /cnb -env GOOGLE_FUNCTION_TARGET=HelloWorld
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+const (
+	// passCode and optOutCode are the exit codes with which buildpacks pass or opt out of detection.
+	passCode   = 0
+	optOutCode = 100
+	// maxOutputBytes is the amount of detect output included in the report for each buildpack.
+	maxOutputBytes = 2048
+)
+
+var (
+	source   = flag.String("source", ".", "Directory containing the application source.")
+	cnbDir   = flag.String("cnb-dir", "/cnb", "Directory containing the buildpacks and order.toml of the builder.")
+	envFlags envList
+	// envRegexp matches env vars that buildpacks report missing when they opt out, e.g.
+	// "GOOGLE_FUNCTION_TARGET not set".
+	envRegexp = regexp.MustCompile(`\b([A-Z][A-Z0-9_]*) (?:not set|is not set|must be set|is required)`)
+)
+
+// envList collects repeated -env flags.
+type envList []string
+
+func (e *envList) String() string {
+	return strings.Join(*e, ",")
+}
+
+func (e *envList) Set(v string) error {
+	if !strings.Contains(v, "=") {
+		return fmt.Errorf("%q must be of the form KEY=VALUE", v)
+	}
+	*e = append(*e, v)
+	return nil
+}
+
+// orderFile is the order.toml of a builder.
+type orderFile struct {
+	Order []struct {
+		Group []groupEntry `toml:"group"`
+	} `toml:"order"`
+}
+
+// groupEntry is a buildpack of an order group.
+type groupEntry struct {
+	ID       string `toml:"id"`
+	Version  string `toml:"version"`
+	Optional bool   `toml:"optional"`
+}
+
+// Report is the JSON output of the analyzer.
+type Report struct {
+	// Pass is true if a buildpack group passed detection, so the source would build with it.
+	Pass bool `json:"pass"`
+	// Group lists the buildpacks of the group that passed detection and take part in the build.
+	Group []BuildpackResult `json:"group,omitempty"`
+	// RequiredEnv lists env vars that opted-out buildpacks reported missing. Setting them may select
+	// a different group, such as the functions group instead of the application group.
+	RequiredEnv []string `json:"requiredEnv,omitempty"`
+	// Failures lists the likely reasons for a failed build: detect errors and, if no group passed,
+	// why the required buildpacks of each group opted out.
+	Failures []BuildpackResult `json:"failures,omitempty"`
+}
+
+// BuildpackResult is the outcome of the detect step of a buildpack.
+type BuildpackResult struct {
+	ID      string `json:"id"`
+	Version string `json:"version,omitempty"`
+	// Pass is true if the buildpack passed detection.
+	Pass bool `json:"pass"`
+	// ExitCode is the exit code of the detect step.
+	ExitCode int `json:"exitCode"`
+	// Output is the tail of the output of the detect step, which explains an opt-out or error.
+	Output string `json:"output,omitempty"`
+}
+
+func main() {
+	flag.Var(&envFlags, "env", "Build env var in KEY=VALUE form; may be repeated.")
+	flag.Parse()
+
+	var order orderFile
+	if _, err := toml.DecodeFile(filepath.Join(*cnbDir, "order.toml"), &order); err != nil {
+		log.Fatalf("Error reading the builder order: %v", err)
+	}
+	app, err := filepath.Abs(*source)
+	if err != nil {
+		log.Fatalf("Error resolving %s: %v", *source, err)
+	}
+	var groups [][]groupEntry
+	for _, o := range order.Order {
+		groups = append(groups, o.Group)
+	}
+
+	d := &detector{cnbDir: *cnbDir, app: app, env: envFlags, results: map[string]BuildpackResult{}}
+	report, err := analyze(d, groups)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		log.Fatalf("Error encoding report: %v", err)
+	}
+}
+
+// analyze runs the detect step of the buildpacks of groups in order, as the lifecycle does, and
+// reports the first group in which every required buildpack passes.
+func analyze(d *detector, groups [][]groupEntry) (Report, error) {
+	var r Report
+	env := map[string]bool{}
+	var optedOut []BuildpackResult
+	for _, group := range groups {
+		var passed []BuildpackResult
+		pass := true
+		for _, e := range group {
+			res, err := d.detect(e)
+			if err != nil {
+				return Report{}, err
+			}
+			switch {
+			case res.Pass:
+				passed = append(passed, res)
+				continue
+			case res.ExitCode != optOutCode:
+				// Errors fail the build whether the buildpack is optional or not.
+				r.Failures = appendResult(r.Failures, res)
+			}
+			for _, m := range envRegexp.FindAllStringSubmatch(res.Output, -1) {
+				env[m[1]] = true
+			}
+			if !e.Optional {
+				pass = false
+				optedOut = appendResult(optedOut, res)
+				// The lifecycle does not run the remaining buildpacks of a failed group.
+				break
+			}
+		}
+		if pass && len(passed) > 0 {
+			r.Pass = true
+			r.Group = passed
+			break
+		}
+	}
+	if !r.Pass {
+		r.Failures = append(r.Failures, optedOut...)
+	}
+	for k := range env {
+		r.RequiredEnv = append(r.RequiredEnv, k)
+	}
+	sort.Strings(r.RequiredEnv)
+	return r, nil
+}
+
+// appendResult appends res to results unless a result of the same buildpack is already present.
+func appendResult(results []BuildpackResult, res BuildpackResult) []BuildpackResult {
+	for _, r := range results {
+		if r.ID == res.ID {
+			return results
+		}
+	}
+	return append(results, res)
+}
+
+// detector runs the detect step of buildpacks against the application, once per buildpack.
+type detector struct {
+	cnbDir  string
+	app     string
+	env     []string
+	results map[string]BuildpackResult
+}
+
+// detect runs bin/detect of the buildpack of e with the lifecycle's arguments: a platform directory
+// holding the env vars and a path to which the build plan is written.
+func (d *detector) detect(e groupEntry) (BuildpackResult, error) {
+	if res, ok := d.results[e.ID]; ok {
+		return res, nil
+	}
+	dir, err := d.buildpackDir(e)
+	if err != nil {
+		return BuildpackResult{}, err
+	}
+	work, err := ioutil.TempDir("", "analyze")
+	if err != nil {
+		return BuildpackResult{}, err
+	}
+	defer os.RemoveAll(work)
+	platform := filepath.Join(work, "platform")
+	if err := writePlatformEnv(platform, d.env); err != nil {
+		return BuildpackResult{}, err
+	}
+
+	var out bytes.Buffer
+	cmd := exec.Command(filepath.Join(dir, "bin", "detect"), platform, filepath.Join(work, "plan.toml"))
+	cmd.Dir = d.app
+	cmd.Env = append(append(os.Environ(), "CNB_BUILDPACK_DIR="+dir), d.env...)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	code := passCode
+	if err := cmd.Run(); err != nil {
+		ee, ok := err.(*exec.ExitError)
+		if !ok {
+			return BuildpackResult{}, fmt.Errorf("running detect of %s: %v", e.ID, err)
+		}
+		code = ee.ExitCode()
+	}
+	res := BuildpackResult{ID: e.ID, Version: e.Version, Pass: code == passCode, ExitCode: code, Output: tail(strings.TrimSpace(out.String()))}
+	d.results[e.ID] = res
+	return res, nil
+}
+
+// buildpackDir returns the directory of the buildpack of e in the builder, in which slashes in
+// buildpack IDs are replaced with underscores. Entries without a version use the only version.
+func (d *detector) buildpackDir(e groupEntry) (string, error) {
+	dir := filepath.Join(d.cnbDir, "buildpacks", strings.ReplaceAll(e.ID, "/", "_"))
+	if e.Version != "" {
+		return filepath.Join(dir, e.Version), nil
+	}
+	versions, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("finding buildpack %s: %v", e.ID, err)
+	}
+	if len(versions) != 1 {
+		return "", fmt.Errorf("buildpack %s has %d versions in %s, want 1", e.ID, len(versions), dir)
+	}
+	return filepath.Join(dir, versions[0].Name()), nil
+}
+
+// writePlatformEnv writes env vars of the form KEY=VALUE to the env directory of the platform
+// directory dir, as the lifecycle does.
+func writePlatformEnv(dir string, env []string) error {
+	envDir := filepath.Join(dir, "env")
+	if err := os.MkdirAll(envDir, 0755); err != nil {
+		return err
+	}
+	for _, kv := range env {
+		parts := strings.SplitN(kv, "=", 2)
+		if err := ioutil.WriteFile(filepath.Join(envDir, parts[0]), []byte(parts[1]), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func tail(s string) string {
+	if len(s) > maxOutputBytes {
+		return "..." + s[len(s)-maxOutputBytes:]
+	}
+	return s
+}
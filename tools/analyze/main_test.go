@@ -0,0 +1,111 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// fakeBuildpacks creates buildpacks in cnbDir whose detect step prints the given output and exits
+// with the given code.
+func fakeBuildpacks(t *testing.T, cnbDir string, detect map[string]string) {
+	t.Helper()
+	for id, script := range detect {
+		bin := filepath.Join(cnbDir, "buildpacks", id, "0.0.1", "bin")
+		if err := os.MkdirAll(bin, 0755); err != nil {
+			t.Fatalf("creating %s: %v", bin, err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(bin, "detect"), []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+			t.Fatalf("writing detect: %v", err)
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	cnbDir, err := ioutil.TempDir("", "cnb")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(cnbDir)
+	fakeBuildpacks(t, cnbDir, map[string]string{
+		"functions": "echo 'GOOGLE_FUNCTION_TARGET not set'; exit 100",
+		"runtime":   "echo 'found main.go'; exit 0",
+		"optional":  "echo 'nothing to do'; exit 100",
+		"broken":    "echo 'invalid project.toml'; exit 1",
+		"missing":   "echo 'requirements.txt not found'; exit 100",
+	})
+
+	testCases := []struct {
+		name   string
+		groups [][]groupEntry
+		want   Report
+	}{
+		{
+			name: "second group passes",
+			groups: [][]groupEntry{
+				{{ID: "functions"}, {ID: "runtime"}},
+				{{ID: "optional", Optional: true}, {ID: "runtime"}},
+			},
+			want: Report{
+				Pass:        true,
+				Group:       []BuildpackResult{{ID: "runtime", Pass: true, Output: "found main.go"}},
+				RequiredEnv: []string{"GOOGLE_FUNCTION_TARGET"},
+			},
+		},
+		{
+			name: "optional buildpack error",
+			groups: [][]groupEntry{
+				{{ID: "broken", Optional: true}, {ID: "runtime"}},
+			},
+			want: Report{
+				Pass:     true,
+				Group:    []BuildpackResult{{ID: "runtime", Pass: true, Output: "found main.go"}},
+				Failures: []BuildpackResult{{ID: "broken", ExitCode: 1, Output: "invalid project.toml"}},
+			},
+		},
+		{
+			name: "no group passes",
+			groups: [][]groupEntry{
+				{{ID: "functions"}},
+				{{ID: "missing"}, {ID: "runtime"}},
+			},
+			want: Report{
+				RequiredEnv: []string{"GOOGLE_FUNCTION_TARGET"},
+				Failures: []BuildpackResult{
+					{ID: "functions", ExitCode: 100, Output: "GOOGLE_FUNCTION_TARGET not set"},
+					{ID: "missing", ExitCode: 100, Output: "requirements.txt not found"},
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &detector{cnbDir: cnbDir, app: cnbDir, results: map[string]BuildpackResult{}}
+
+			got, err := analyze(d, tc.groups)
+
+			if err != nil {
+				t.Fatalf("analyze() got error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("analyze() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
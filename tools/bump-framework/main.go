@@ -0,0 +1,310 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The main binary updates the functions framework version pinned by the functions buildpacks of a
+// language, regenerates the files derived from the pin, such as lockfiles, and runs the template
+// and compatibility tests, and optionally the acceptance tests, of the language's function builders
+// against the new version.
+//
+// Usage:
+//
+//	go run ./tools/bump-framework -list
+//	go run ./tools/bump-framework -language go -version v1.7.0
+//	go run ./tools/bump-framework -language nodejs -version 1.7.1 -acceptance
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver"
+)
+
+var (
+	repo       = flag.String("repo", ".", "Root of the buildpacks repository.")
+	lang       = flag.String("language", "", "Language whose functions framework version is updated: go, java, nodejs, php or python.")
+	version    = flag.String("version", "", "Functions framework version to pin, e.g. v1.7.0 for Go or 1.7.1 for Node.js.")
+	list       = flag.Bool("list", false, "List the pinned versions of every language and exit.")
+	runTests   = flag.Bool("test", true, "Run the template and compatibility tests of the functions buildpack after updating the pin.")
+	acceptance = flag.Bool("acceptance", false, "Run the acceptance tests of the function builders of the language with Bazel, which requires Docker and pack.")
+)
+
+// pin is a framework version pinned in a file of the repository.
+type pin struct {
+	// path is the file holding the pin, relative to the repository root.
+	path string
+	// re matches the pin; its first group is the version.
+	re *regexp.Regexp
+}
+
+// command is a command run in dir, relative to the repository root.
+type command struct {
+	dir  string
+	args []string
+}
+
+// language describes how the functions buildpacks of a language pin the framework.
+type language struct {
+	pins []pin
+	// modulePrefix is true if versions start with v, as Go module versions do.
+	modulePrefix bool
+	// regenerate are the commands that update files derived from the pins, such as lockfiles.
+	regenerate []command
+	// testPackage is the Go package of the functions buildpack, and testArgs returns the arguments
+	// with which its tests check the templates against a framework version.
+	testPackage string
+	testArgs    func(version string) []string
+}
+
+var languages = map[string]language{
+	"go": {
+		pins: []pin{
+			{path: "cmd/go/functions_framework/main.go", re: regexp.MustCompile(`functionsFrameworkVersion\s*=\s*"(v[^"]+)"`)},
+			{path: "tools/warmcache/manifest.yaml", re: regexp.MustCompile(`functions-framework-go@(v[^\s]+)`)},
+		},
+		modulePrefix: true,
+		testPackage:  "./cmd/go/functions_framework",
+		// The compatibility matrix compiles the generated mains against the framework.
+		testArgs: func(version string) []string { return []string{"-framework-version=" + version} },
+	},
+	"java": {
+		pins: []pin{
+			{path: "cmd/java/functions_framework/main.go", re: regexp.MustCompile(`defaultFrameworkVersion\s*=\s*"([^"]+)"`)},
+		},
+		testPackage: "./cmd/java/functions_framework",
+	},
+	"nodejs": {
+		pins: []pin{
+			{path: "cmd/nodejs/functions_framework/converter/without-framework/package.json", re: regexp.MustCompile(`"@google-cloud/functions-framework":\s*"\^([^"]+)"`)},
+		},
+		regenerate: []command{
+			{dir: "cmd/nodejs/functions_framework/converter/without-framework", args: []string{"npm", "install", "--package-lock-only", "--ignore-scripts", "--no-audit"}},
+		},
+		testPackage: "./cmd/nodejs/functions_framework",
+	},
+	"php": {
+		pins: []pin{
+			{path: "cmd/php/functions_framework/converter/composer.json", re: regexp.MustCompile(`"google/cloud-functions-framework":\s*"\^([^"]+)"`)},
+		},
+		regenerate: []command{
+			{dir: "cmd/php/functions_framework/converter", args: []string{"composer", "update", "--no-install", "--no-scripts", "google/cloud-functions-framework"}},
+		},
+		testPackage: "./cmd/php/functions_framework",
+	},
+	"python": {
+		pins: []pin{
+			{path: "cmd/python/functions_framework/converter/requirements.txt", re: regexp.MustCompile(`functions-framework==(\S+)`)},
+		},
+		testPackage: "./cmd/python/functions_framework",
+	},
+}
+
+func main() {
+	flag.Parse()
+	if *list {
+		if err := printVersions(*repo); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	}
+	l, ok := languages[*lang]
+	if !ok {
+		log.Fatalf("Usage: bump-framework -language <%s> -version <version>", strings.Join(languageNames(), "|"))
+	}
+	v, err := normalizeVersion(l, *version)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	changed, err := bump(*repo, l, v)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if len(changed) == 0 {
+		log.Printf("The %s functions framework is already pinned to %s", *lang, v)
+	}
+	for _, p := range changed {
+		log.Printf("Updated %s to %s", p, v)
+	}
+	for _, c := range l.regenerate {
+		if err := run(filepath.Join(*repo, c.dir), c.args...); err != nil {
+			log.Fatalf("Error regenerating files in %s: %v", c.dir, err)
+		}
+	}
+	if *runTests {
+		args := append([]string{"go", "test", "-count=1", l.testPackage}, testArgs(l, v)...)
+		if err := run(*repo, args...); err != nil {
+			log.Fatalf("Error: the tests of the %s functions buildpack fail with %s: %v", *lang, v, err)
+		}
+	}
+	if *acceptance {
+		targets, err := acceptanceTargets(*repo, *lang)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if err := run(*repo, append([]string{"bazel", "test"}, targets...)...); err != nil {
+			log.Fatalf("Error: the acceptance tests of the %s function builders fail with %s: %v", *lang, v, err)
+		}
+	}
+	log.Printf("Pinned the %s functions framework to %s; review and commit the changes", *lang, v)
+}
+
+func languageNames() []string {
+	var names []string
+	for name := range languages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func testArgs(l language, version string) []string {
+	if l.testArgs == nil {
+		return nil
+	}
+	return l.testArgs(version)
+}
+
+// normalizeVersion validates v and returns it with a v prefix for Go modules, and without one for
+// the packages of other languages.
+func normalizeVersion(l language, v string) (string, error) {
+	if v == "" {
+		return "", fmt.Errorf("-version is required")
+	}
+	if _, err := semver.ParseTolerant(v); err != nil {
+		return "", fmt.Errorf("invalid version %q: %v", v, err)
+	}
+	v = strings.TrimPrefix(v, "v")
+	if l.modulePrefix {
+		v = "v" + v
+	}
+	return v, nil
+}
+
+// pinnedVersions returns the versions pinned by l, in the order of its pins.
+func pinnedVersions(root string, l language) ([]string, error) {
+	var versions []string
+	for _, p := range l.pins {
+		content, err := ioutil.ReadFile(filepath.Join(root, p.path))
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", p.path, err)
+		}
+		m := p.re.FindSubmatch(content)
+		if m == nil {
+			return nil, fmt.Errorf("%s does not match %s; update the pins of bump-framework", p.path, p.re)
+		}
+		versions = append(versions, string(m[1]))
+	}
+	return versions, nil
+}
+
+// bump pins version in every file of l, and returns the files that changed.
+func bump(root string, l language, version string) ([]string, error) {
+	// Check every pin before changing any, so that a failure does not leave the pins inconsistent.
+	current, err := pinnedVersions(root, l)
+	if err != nil {
+		return nil, err
+	}
+	var changed []string
+	for i, p := range l.pins {
+		if current[i] == version {
+			continue
+		}
+		path := filepath.Join(root, p.path)
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", p.path, err)
+		}
+		loc := p.re.FindSubmatchIndex(content)
+		updated := append(append(append([]byte{}, content[:loc[2]]...), version...), content[loc[3]:]...)
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("stat %s: %v", p.path, err)
+		}
+		if err := ioutil.WriteFile(path, updated, fi.Mode()); err != nil {
+			return nil, fmt.Errorf("writing %s: %v", p.path, err)
+		}
+		changed = append(changed, p.path)
+	}
+	return changed, nil
+}
+
+// printVersions prints the versions pinned by every language, and flags languages whose pins differ.
+func printVersions(root string) error {
+	for _, name := range languageNames() {
+		l := languages[name]
+		versions, err := pinnedVersions(root, l)
+		if err != nil {
+			return err
+		}
+		var paths []string
+		for _, p := range l.pins {
+			paths = append(paths, p.path)
+		}
+		line := fmt.Sprintf("%s: %s (%s)", name, versions[0], strings.Join(paths, ", "))
+		for _, v := range versions[1:] {
+			if v != versions[0] {
+				line += fmt.Sprintf(" INCONSISTENT: %s", strings.Join(versions, ", "))
+				break
+			}
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// acceptanceTargets returns the Bazel targets of the acceptance tests of the function builders of
+// lang: those of its GCF builders, and its function tests of the GCP builder.
+func acceptanceTargets(root, lang string) ([]string, error) {
+	builds, err := filepath.Glob(filepath.Join(root, "builders", "gcf", lang+"[0-9]*", "acceptance", "BUILD.bazel"))
+	if err != nil {
+		return nil, err
+	}
+	var targets []string
+	for _, b := range builds {
+		rel, err := filepath.Rel(root, filepath.Dir(b))
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, "//"+filepath.ToSlash(rel)+":acceptance_test")
+	}
+	content, err := ioutil.ReadFile(filepath.Join(root, "builders", "gcp", "base", "acceptance", "BUILD.bazel"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if strings.Contains(string(content), fmt.Sprintf("name = %q", lang+"_fn_test")) {
+		targets = append(targets, "//builders/gcp/base/acceptance:"+lang+"_fn_test")
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no acceptance tests found for %s", lang)
+	}
+	return targets, nil
+}
+
+func run(dir string, args ...string) error {
+	log.Printf("Running %s in %s", strings.Join(args, " "), dir)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,139 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestNormalizeVersion(t *testing.T) {
+	testCases := []struct {
+		lang    string
+		version string
+		want    string
+		wantErr bool
+	}{
+		{lang: "go", version: "v1.7.0", want: "v1.7.0"},
+		{lang: "go", version: "1.7.0", want: "v1.7.0"},
+		{lang: "nodejs", version: "v1.7.1", want: "1.7.1"},
+		{lang: "python", version: "3.0.0", want: "3.0.0"},
+		{lang: "java", version: "", wantErr: true},
+		{lang: "java", version: "latest", wantErr: true},
+	}
+	for _, tc := range testCases {
+		got, err := normalizeVersion(languages[tc.lang], tc.version)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("normalizeVersion(%s, %q) got error: %v, want error: %t", tc.lang, tc.version, err, tc.wantErr)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("normalizeVersion(%s, %q) = %q, want %q", tc.lang, tc.version, got, tc.want)
+		}
+	}
+}
+
+func TestBump(t *testing.T) {
+	root, err := ioutil.TempDir("", "repo")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(root)
+	files := map[string]string{
+		"main.go":       "const (\n\tfunctionsFrameworkVersion = \"v1.1.0\"\n\tother = \"v1.1.0\"\n)\n",
+		"manifest.yaml": "go_modules:\n- github.com/GoogleCloudPlatform/functions-framework-go@v1.1.0\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatalf("writing %s: %v", name, err)
+		}
+	}
+	l := language{pins: []pin{
+		{path: "main.go", re: regexp.MustCompile(`functionsFrameworkVersion\s*=\s*"(v[^"]+)"`)},
+		{path: "manifest.yaml", re: regexp.MustCompile(`functions-framework-go@(v[^\s]+)`)},
+	}}
+
+	changed, err := bump(root, l, "v1.7.0")
+	if err != nil {
+		t.Fatalf("bump() got error: %v", err)
+	}
+	if want := []string{"main.go", "manifest.yaml"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("bump() changed %v, want %v", changed, want)
+	}
+	main, err := ioutil.ReadFile(filepath.Join(root, "main.go"))
+	if err != nil {
+		t.Fatalf("reading main.go: %v", err)
+	}
+	if want := "const (\n\tfunctionsFrameworkVersion = \"v1.7.0\"\n\tother = \"v1.1.0\"\n)\n"; string(main) != want {
+		t.Errorf("main.go = %q, want %q", main, want)
+	}
+	if got, err := pinnedVersions(root, l); err != nil || !reflect.DeepEqual(got, []string{"v1.7.0", "v1.7.0"}) {
+		t.Errorf("pinnedVersions() = %v, %v, want [v1.7.0 v1.7.0], nil", got, err)
+	}
+
+	// Bumping again changes nothing.
+	if changed, err := bump(root, l, "v1.7.0"); err != nil || len(changed) != 0 {
+		t.Errorf("bump() again = %v, %v, want no changes", changed, err)
+	}
+
+	// A pin that no longer matches fails before any file is changed.
+	l.pins = append(l.pins, pin{path: "main.go", re: regexp.MustCompile(`missing = "(.*)"`)})
+	if _, err := bump(root, l, "v1.8.0"); err == nil {
+		t.Errorf("bump() with a missing pin got nil error, want error")
+	}
+	if got, _ := pinnedVersions(root, language{pins: l.pins[:2]}); !reflect.DeepEqual(got, []string{"v1.7.0", "v1.7.0"}) {
+		t.Errorf("pinnedVersions() after failed bump = %v, want unchanged pins", got)
+	}
+}
+
+// TestRepositoryPins checks that the pins of every language match the files of the repository.
+func TestRepositoryPins(t *testing.T) {
+	for name, l := range languages {
+		if _, err := pinnedVersions(repoRoot(t), l); err != nil {
+			t.Errorf("pinnedVersions(%s) got error: %v", name, err)
+		}
+	}
+}
+
+func TestAcceptanceTargets(t *testing.T) {
+	got, err := acceptanceTargets(repoRoot(t), "nodejs")
+	if err != nil {
+		t.Fatalf("acceptanceTargets() got error: %v", err)
+	}
+	want := []string{
+		"//builders/gcf/nodejs10/acceptance:acceptance_test",
+		"//builders/gcf/nodejs12/acceptance:acceptance_test",
+		"//builders/gcf/nodejs14/acceptance:acceptance_test",
+		"//builders/gcp/base/acceptance:nodejs_fn_test",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("acceptanceTargets() = %v, want %v", got, want)
+	}
+}
+
+// repoRoot returns the root of the repository, and skips the test if the repository is not
+// available, as in the Bazel sandbox.
+func repoRoot(t *testing.T) string {
+	t.Helper()
+	root := filepath.Join("..", "..")
+	if _, err := os.Stat(filepath.Join(root, "go.mod")); err != nil {
+		t.Skipf("repository root not found: %v", err)
+	}
+	return root
+}
@@ -0,0 +1,72 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The main binary validates builder descriptors against the buildpacks in this repository and
+// generates single-language builders from them.
+//
+// Usage:
+//
+//	composebuilder -builder builders/gcp/base/builder.toml -check
+//	composebuilder -builder builders/gcp/base/builder.toml -language python > builder.toml
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildermetadata"
+)
+
+var (
+	root      = flag.String("root", ".", "Root of the buildpacks repository.")
+	builder   = flag.String("builder", "", "Path to the builder.toml to start from.")
+	language  = flag.String("language", "", "If set, keep only the order groups for this language, e.g. python.")
+	lifecycle = flag.String("lifecycle", "", "If set, override the lifecycle version.")
+	check     = flag.Bool("check", false, "Only validate the builder and exit.")
+)
+
+func main() {
+	flag.Parse()
+	if *builder == "" {
+		log.Fatalf("-builder is required")
+	}
+
+	r, err := buildermetadata.LoadRegistry(*root, "cmd")
+	if err != nil {
+		log.Fatalf("Error loading buildpacks: %v", err)
+	}
+	b, err := buildermetadata.ReadBuilder(*builder)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if *lifecycle != "" {
+		b.Lifecycle.Version = *lifecycle
+	}
+	if err := b.Validate(r); err != nil {
+		log.Fatalf("Error validating %s: %v", *builder, err)
+	}
+	if *check {
+		log.Printf("%s is valid", *builder)
+		return
+	}
+	if *language != "" {
+		if b, err = b.Slim(r, *language); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	}
+	if err := b.Encode(os.Stdout); err != nil {
+		log.Fatalf("Error encoding builder: %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+#!/bin/bash
+# Copyright 2020 Google LLC
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# The create-buildpackage.sh script packages a single buildpack as an OCI
+# buildpackage.
+#
+# Usage:
+#   ./create-buildpackage.sh <buildpack tgz> <output> [os]
+#
+# If <output> ends in .cnb, the buildpackage is written to that file. Otherwise
+# <output> is an image reference, e.g.
+# us-docker.pkg.dev/my-project/buildpacks/google/python/pip:0.9.1, and the
+# buildpackage is published to it. <os> is the target platform of the
+# buildpackage and defaults to linux.
+
+set -euo pipefail
+
+readonly tgz="${1:?buildpack tgz missing}"
+readonly output="${2:?output file or image missing}"
+readonly os="${3:-linux}"
+
+# Blaze does not set $HOME which is required by pack.
+readonly temp="$(mktemp -d)"
+trap "rm -rf $temp" EXIT
+export HOME="$temp/home"
+mkdir "$HOME"
+
+cat > "${temp}/package.toml" <<EOT
+[buildpack]
+uri = "$(realpath "$tgz")"
+
+[platform]
+os = "${os}"
+EOT
+
+if [[ "$output" == *.cnb ]]; then
+  pack package-buildpack "$(realpath -m "$output")" --config="${temp}/package.toml" --format=file
+else
+  pack package-buildpack "$output" --config="${temp}/package.toml" --publish
+fi
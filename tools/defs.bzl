@@ -11,6 +11,9 @@ def buildpack(name, executables, descriptor = "buildpack.toml", srcs = None, ext
     As this is a macro, the actual target name for the buildpack is `name.extension`.
     The builder.toml spec allows either tar or tgz archives.
 
+    The macro also creates a `name.cnb` target, an OCI buildpackage of the
+    buildpack for the linux platform that can be published on its own.
+
     Args:
       name: the base name of the tar archive
       descriptor: path to the `buildpack.toml`
@@ -42,6 +45,24 @@ def buildpack(name, executables, descriptor = "buildpack.toml", srcs = None, ext
         visibility = visibility,
     )
 
+    # `name.cnb` rule.
+    native.genrule(
+        name = name + "_buildpackage",
+        srcs = [name + "." + extension],
+        outs = [name + ".cnb"],
+        local = 1,
+        tools = [
+            "//tools/checktools:main",
+            "//tools:create_buildpackage",
+        ],
+        cmd = """$(execpath {check_script}) && $(execpath {create_script}) $(execpath {archive}) $@""".format(
+            archive = name + "." + extension,
+            check_script = "//tools/checktools:main",
+            create_script = "//tools:create_buildpackage",
+        ),
+        visibility = visibility,
+    )
+
 def builder(name, image, descriptor = "builder.toml", buildpacks = None, groups = None, visibility = None):
     """Macro to create a set of targets for a builder with specified buildpacks.
 
@@ -0,0 +1,307 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The main binary builds an application or function locally the way Google Cloud builds it: it
+// runs pack with the Google Cloud builder, translates app.yaml and function flags into buildpack
+// env vars, and verifies that the built image starts and serves HTTP.
+//
+// Usage:
+//
+//	gcpbuild -source . my-app
+//	gcpbuild -source . -function-target=HelloWorld my-function
+//	gcpbuild -source . -artifacts ./out -artifacts-only my-cli
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/checktools"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/golang"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	defaultBuilder = "gcr.io/buildpacks/builder:v1"
+	// trustedBuilderPrefix matches builders published by this repository, whose lifecycle may run with
+	// registry credentials.
+	trustedBuilderPrefix = "gcr.io/buildpacks/"
+	appYAMLFile          = "app.yaml"
+	port                 = "8080"
+	// artifactMount is where the -artifacts directory is mounted in the build container.
+	artifactMount = "/artifacts"
+)
+
+var (
+	source         = flag.String("source", ".", "Directory containing the application source.")
+	builder        = flag.String("builder", defaultBuilder, "Builder image to build with.")
+	runImage       = flag.String("run-image", "", "Run image to export the image with. Defaults to the minimal run image with GOOGLE_GO_MINIMAL, and to the run image of the builder otherwise.")
+	appYAML        = flag.String("app-yaml", "", "Path to an App Engine app.yaml to translate into env vars. Defaults to app.yaml in -source, if present.")
+	functionTarget = flag.String("function-target", "", "Name of the function to build, as passed to `gcloud functions deploy --entry-point`.")
+	signatureType  = flag.String("function-signature-type", "", "Signature type of the function: http, event or cloudevent.")
+	functionSource = flag.String("function-source", "", "Path of the function source relative to -source.")
+	publish        = flag.Bool("publish", false, "Publish the image to its registry instead of the local Docker daemon.")
+	verify         = flag.Bool("verify", true, "Run the built image and wait for it to serve HTTP. Ignored with -publish.")
+	verifyTimeout  = flag.Duration("verify-timeout", 30*time.Second, "How long to wait for the built image to serve HTTP.")
+	artifacts      = flag.String("artifacts", "", "Directory to which the compiled artifacts, such as binaries and jars, are exported.")
+	artifactsOnly  = flag.Bool("artifacts-only", false, "Remove the image after exporting the artifacts to -artifacts. Implies -verify=false.")
+	envFlags       envList
+	runtimeRegexp  = regexp.MustCompile(`^([a-z]+)[0-9]*$`)
+)
+
+// envList collects repeated -env flags.
+type envList []string
+
+func (e *envList) String() string {
+	return strings.Join(*e, ",")
+}
+
+func (e *envList) Set(v string) error {
+	if !strings.Contains(v, "=") {
+		return fmt.Errorf("%q must be of the form KEY=VALUE", v)
+	}
+	*e = append(*e, v)
+	return nil
+}
+
+// appConfig holds the app.yaml fields that affect the build.
+type appConfig struct {
+	Runtime    string            `yaml:"runtime"`
+	Entrypoint string            `yaml:"entrypoint"`
+	Main       string            `yaml:"main"`
+	BuildEnv   map[string]string `yaml:"build_env_variables"`
+}
+
+func main() {
+	flag.Var(&envFlags, "env", "Build env var in KEY=VALUE form; may be repeated. Takes precedence over translated env vars.")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		log.Fatalf("Usage: gcpbuild [flags] <image>")
+	}
+	image := flag.Arg(0)
+	if *artifactsOnly && (*artifacts == "" || *publish) {
+		log.Fatalf("Error: -artifacts-only requires -artifacts and cannot be used with -publish")
+	}
+
+	if err := checktools.PackVersion(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	path := *appYAML
+	if path == "" {
+		if p := filepath.Join(*source, appYAMLFile); fileExists(p) {
+			path = p
+		}
+	}
+	var app *appConfig
+	if path != "" {
+		var err error
+		if app, err = readAppConfig(path); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		log.Printf("Using %s", path)
+	}
+
+	buildEnv := translateEnv(app, function{target: *functionTarget, signatureType: *signatureType, source: *functionSource}, envFlags)
+	artifactDir := ""
+	if *artifacts != "" {
+		var err error
+		if artifactDir, err = prepareArtifactDir(*artifacts); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		buildEnv[env.ArtifactDir] = artifactMount
+	}
+	args := packArgs(image, *source, *builder, selectRunImage(*runImage, buildEnv), buildEnv, *publish, artifactDir)
+	log.Printf("Running pack %s", strings.Join(args, " "))
+	cmd := exec.Command("pack", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		log.Fatalf("Error building %s: %v", image, err)
+	}
+
+	if *artifactsOnly {
+		if out, err := exec.Command("docker", "rmi", image).CombinedOutput(); err != nil {
+			log.Fatalf("Error removing %s: %v\n%s", image, err, out)
+		}
+		log.Printf("Exported artifacts to %s", artifactDir)
+		return
+	}
+	if *verify && !*publish {
+		if err := verifyImage(image, *verifyTimeout); err != nil {
+			log.Fatalf("Error verifying %s: %v", image, err)
+		}
+		log.Printf("Verified that %s serves HTTP", image)
+	}
+	if artifactDir != "" {
+		log.Printf("Exported artifacts to %s", artifactDir)
+	}
+	log.Printf("Built %s", image)
+}
+
+type function struct {
+	target        string
+	signatureType string
+	source        string
+}
+
+func readAppConfig(path string) (*appConfig, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", path, err)
+	}
+	// app.yaml has many fields that do not affect the build, so unknown fields are allowed.
+	var app appConfig
+	if err := yaml.Unmarshal(raw, &app); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	return &app, nil
+}
+
+// translateEnv returns the build env vars equivalent to app.yaml and the function flags, overridden by extra.
+func translateEnv(app *appConfig, fn function, extra []string) map[string]string {
+	e := map[string]string{}
+	if app != nil {
+		// Runtimes such as python38 are provided by different builders on App Engine; the general
+		// builder only needs the language and installs the latest version unless told otherwise.
+		if m := runtimeRegexp.FindStringSubmatch(app.Runtime); m != nil {
+			e[env.Runtime] = m[1]
+		}
+		if app.Entrypoint != "" {
+			e[env.Entrypoint] = app.Entrypoint
+		}
+		if app.Main != "" {
+			e[env.GAEMain] = app.Main
+		}
+		for k, v := range app.BuildEnv {
+			e[k] = v
+		}
+	}
+	if fn.target != "" {
+		e[env.FunctionTarget] = fn.target
+	}
+	if fn.signatureType != "" {
+		e[env.FunctionSignatureType] = fn.signatureType
+	}
+	if fn.source != "" {
+		e[env.FunctionSource] = fn.source
+	}
+	for _, kv := range extra {
+		parts := strings.SplitN(kv, "=", 2)
+		e[parts[0]] = parts[1]
+	}
+	return e
+}
+
+// selectRunImage returns the run image to export the image with: runImage if set, the minimal run
+// image if buildEnv enables minimal mode, as minimal Go binaries only run on it, or "" to use the run
+// image of the builder.
+func selectRunImage(runImage string, buildEnv map[string]string) string {
+	if runImage != "" {
+		return runImage
+	}
+	if minimal, err := strconv.ParseBool(buildEnv[env.GoMinimal]); err == nil && minimal {
+		return golang.MinimalRunImage
+	}
+	return ""
+}
+
+// packArgs returns the arguments of the pack build command. A non-empty artifactDir is mounted at
+// artifactMount.
+func packArgs(image, source, builder, runImage string, buildEnv map[string]string, publish bool, artifactDir string) []string {
+	args := []string{"build", image, "--path", source, "--builder", builder}
+	if strings.HasPrefix(builder, trustedBuilderPrefix) {
+		args = append(args, "--trust-builder")
+	}
+	if runImage != "" {
+		args = append(args, "--run-image", runImage)
+	}
+	if publish {
+		args = append(args, "--publish")
+	}
+	if artifactDir != "" {
+		args = append(args, "--volume", artifactDir+":"+artifactMount+":rw")
+	}
+	keys := make([]string, 0, len(buildEnv))
+	for k := range buildEnv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, "--env", k+"="+buildEnv[k])
+	}
+	return args
+}
+
+// prepareArtifactDir creates dir and returns its absolute path. The directory is made writable by
+// everyone because buildpacks run as the unprivileged user of the builder image.
+func prepareArtifactDir(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("resolving %s: %v", dir, err)
+	}
+	if err := os.MkdirAll(abs, 0777); err != nil {
+		return "", fmt.Errorf("creating %s: %v", abs, err)
+	}
+	if err := os.Chmod(abs, 0777); err != nil {
+		return "", fmt.Errorf("making %s writable: %v", abs, err)
+	}
+	return abs, nil
+}
+
+// verifyImage runs image and waits until it responds to an HTTP request on $PORT.
+func verifyImage(image string, timeout time.Duration) error {
+	out, err := exec.Command("docker", "run", "--detach", "--env", "PORT="+port, "--publish", "127.0.0.1::"+port, image).Output()
+	if err != nil {
+		return fmt.Errorf("starting container: %v", err)
+	}
+	id := strings.TrimSpace(string(out))
+	defer exec.Command("docker", "rm", "--force", id).Run()
+
+	out, err = exec.Command("docker", "port", id, port).Output()
+	if err != nil {
+		return fmt.Errorf("finding container port: %v", err)
+	}
+	addr := strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0])
+
+	client := http.Client{Timeout: time.Second}
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		// Any response, even an error status, shows that the application started and serves HTTP.
+		if resp, err := client.Get("http://" + addr + "/"); err == nil {
+			resp.Body.Close()
+			return nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	logs, _ := exec.Command("docker", "logs", id).CombinedOutput()
+	return fmt.Errorf("no HTTP response on port %s within %v; container logs:\n%s", port, timeout, logs)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,189 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadAppConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcpbuild")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.yaml")
+	content := `runtime: python38
+entrypoint: gunicorn -b :$PORT main:app
+instance_class: F2
+build_env_variables:
+  GOOGLE_DJANGO_CHECK_DEPLOY: "true"
+`
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("writing app.yaml: %v", err)
+	}
+
+	got, err := readAppConfig(path)
+	if err != nil {
+		t.Fatalf("readAppConfig() got error: %v", err)
+	}
+	want := &appConfig{
+		Runtime:    "python38",
+		Entrypoint: "gunicorn -b :$PORT main:app",
+		BuildEnv:   map[string]string{"GOOGLE_DJANGO_CHECK_DEPLOY": "true"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readAppConfig() = %#v, want %#v", got, want)
+	}
+}
+
+func TestTranslateEnv(t *testing.T) {
+	testCases := []struct {
+		name  string
+		app   *appConfig
+		fn    function
+		extra []string
+		want  map[string]string
+	}{
+		{
+			name: "nothing",
+			want: map[string]string{},
+		},
+		{
+			name: "app.yaml",
+			app: &appConfig{
+				Runtime:    "go114",
+				Entrypoint: "./server",
+				Main:       "./cmd/server",
+				BuildEnv:   map[string]string{"GOOGLE_GOLDFLAGS": "-s -w"},
+			},
+			want: map[string]string{
+				"GOOGLE_RUNTIME":    "go",
+				"GOOGLE_ENTRYPOINT": "./server",
+				"GAE_YAML_MAIN":     "./cmd/server",
+				"GOOGLE_GOLDFLAGS":  "-s -w",
+			},
+		},
+		{
+			name: "function",
+			fn:   function{target: "HelloWorld", signatureType: "event", source: "fn"},
+			want: map[string]string{
+				"GOOGLE_FUNCTION_TARGET":         "HelloWorld",
+				"GOOGLE_FUNCTION_SIGNATURE_TYPE": "event",
+				"GOOGLE_FUNCTION_SOURCE":         "fn",
+			},
+		},
+		{
+			name:  "extra env takes precedence",
+			app:   &appConfig{Runtime: "nodejs12", Entrypoint: "node index.js"},
+			extra: []string{"GOOGLE_ENTRYPOINT=npm start", "DEBUG=a=b"},
+			want: map[string]string{
+				"GOOGLE_RUNTIME":    "nodejs",
+				"GOOGLE_ENTRYPOINT": "npm start",
+				"DEBUG":             "a=b",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := translateEnv(tc.app, tc.fn, tc.extra); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("translateEnv() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPackArgs(t *testing.T) {
+	testCases := []struct {
+		name      string
+		builder   string
+		runImage  string
+		env       map[string]string
+		publish   bool
+		artifacts string
+		want      []string
+	}{
+		{
+			name:    "trusted builder",
+			builder: defaultBuilder,
+			env:     map[string]string{"B": "2", "A": "1"},
+			want:    []string{"build", "my-app", "--path", ".", "--builder", defaultBuilder, "--trust-builder", "--env", "A=1", "--env", "B=2"},
+		},
+		{
+			name:    "custom builder",
+			builder: "my-builder",
+			publish: true,
+			want:    []string{"build", "my-app", "--path", ".", "--builder", "my-builder", "--publish"},
+		},
+		{
+			name:     "run image",
+			builder:  "my-builder",
+			runImage: "my-run-image",
+			want:     []string{"build", "my-app", "--path", ".", "--builder", "my-builder", "--run-image", "my-run-image"},
+		},
+		{
+			name:      "artifacts",
+			builder:   "my-builder",
+			artifacts: "/tmp/out",
+			want:      []string{"build", "my-app", "--path", ".", "--builder", "my-builder", "--volume", "/tmp/out:/artifacts:rw"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := packArgs("my-app", ".", tc.builder, tc.runImage, tc.env, tc.publish, tc.artifacts); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("packArgs() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSelectRunImage(t *testing.T) {
+	testCases := []struct {
+		name     string
+		runImage string
+		env      map[string]string
+		want     string
+	}{
+		{
+			name: "builder run image",
+		},
+		{
+			name: "minimal",
+			env:  map[string]string{"GOOGLE_GO_MINIMAL": "true"},
+			want: "gcr.io/buildpacks/gcp/run-minimal:v1",
+		},
+		{
+			name: "minimal disabled",
+			env:  map[string]string{"GOOGLE_GO_MINIMAL": "false"},
+		},
+		{
+			name:     "flag",
+			runImage: "my-run-image",
+			env:      map[string]string{"GOOGLE_GO_MINIMAL": "true"},
+			want:     "my-run-image",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := selectRunImage(tc.runImage, tc.env); got != tc.want {
+				t.Errorf("selectRunImage(%q, %v) = %q, want %q", tc.runImage, tc.env, got, tc.want)
+			}
+		})
+	}
+}
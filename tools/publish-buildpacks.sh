@@ -0,0 +1,41 @@
+#!/bin/bash
+# Copyright 2020 Google LLC
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# The publish-buildpacks.sh script publishes every buildpack in this repository
+# as an individual buildpackage.
+#
+# Usage:
+#   ./tools/publish-buildpacks.sh <registry> [os]
+#
+# Each buildpack is published to <registry>/<id>:<version>, where dots in the
+# buildpack id are replaced with slashes, e.g.
+# us-docker.pkg.dev/my-project/buildpacks/google/python/pip:0.9.1.
+
+set -euo pipefail
+
+readonly registry="${1:?registry missing}"
+readonly os="${2:-linux}"
+
+cd "$(dirname "$0")/.."
+
+for descriptor in cmd/*/*/buildpack.toml; do
+  dir="$(dirname "$descriptor")"
+  name="$(basename "$dir")"
+  id="$(sed -n 's/^id = "\(.*\)"$/\1/p' "$descriptor" | head -1)"
+  version="$(sed -n 's/^version = "\(.*\)"$/\1/p' "$descriptor" | head -1)"
+
+  bazel build "//${dir}:${name}.tgz"
+  tools/create-buildpackage.sh "bazel-bin/${dir}/${name}.tgz" "${registry}/${id//.//}:${version}" "$os"
+done
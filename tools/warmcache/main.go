@@ -0,0 +1,187 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The main binary pre-populates the warm cache of a builder image with the artifacts listed in a
+// manifest, so that first builds on fresh workers do not have to download them. It is meant to
+// run while building the builder image, as root.
+//
+// Usage:
+//
+//	warmcache -manifest tools/warmcache/manifest.yaml
+//
+// Go modules, pip packages and npm packages are only warmed when go, python3 and npm,
+// respectively, are available on PATH.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/warmcache"
+)
+
+var (
+	manifest = flag.String("manifest", "", "Path to the manifest listing the artifacts to warm.")
+	dir      = flag.String("dir", warmcache.DefaultDir, "Directory to populate.")
+)
+
+func main() {
+	flag.Parse()
+	if *manifest == "" {
+		log.Fatalf("-manifest is required")
+	}
+	m, err := warmcache.ReadManifest(*manifest)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	for _, u := range m.Archives {
+		if err := fetchArchive(*dir, u); err != nil {
+			log.Fatalf("Error warming %s: %v", u, err)
+		}
+	}
+	if err := warmGoModules(*dir, m.GoModules); err != nil {
+		log.Fatalf("Error warming Go modules: %v", err)
+	}
+	if err := warmTool(*dir, m.PipPackages, "python3", func(pkg string) []string {
+		return []string{"python3", "-m", "pip", "download", "--no-cache-dir", "--dest", filepath.Join(*dir, warmcache.PipDir), pkg}
+	}); err != nil {
+		log.Fatalf("Error warming pip packages: %v", err)
+	}
+	if err := warmTool(*dir, m.NpmPackages, "npm", func(pkg string) []string {
+		return []string{"npm", "cache", "add", "--cache", filepath.Join(*dir, warmcache.NpmDir), pkg}
+	}); err != nil {
+		log.Fatalf("Error warming npm packages: %v", err)
+	}
+
+	// Buildpacks run as an unprivileged user and npm writes to its cache.
+	if err := makeWritable(*dir); err != nil {
+		log.Fatalf("Error setting permissions on %s: %v", *dir, err)
+	}
+	log.Printf("Warmed %s", *dir)
+}
+
+func fetchArchive(dir, url string) error {
+	path := warmcache.ArchivePath(dir, url)
+	if _, err := os.Stat(path); err == nil {
+		log.Printf("Already warm: %s", url)
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	log.Printf("Downloading %s", url)
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	// Write to a temporary file first so that an interrupted download is never mistaken for a warm archive.
+	f, err := ioutil.TempFile(filepath.Dir(path), "download")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// warmGoModules downloads modules and their dependencies into a module cache that buildpacks serve as a GOPROXY.
+func warmGoModules(dir string, mods []string) error {
+	if len(mods) == 0 {
+		return nil
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		log.Printf("go not found on PATH, skipping %d Go module(s)", len(mods))
+		return nil
+	}
+	tmp, err := ioutil.TempDir("", "warmcache")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	env := append(os.Environ(), "GOPATH="+filepath.Join(dir, warmcache.GoPathDir), "GOFLAGS=-modcacherw", "GO111MODULE=on")
+	cmds := [][]string{{"go", "mod", "init", "warmcache"}}
+	for _, mod := range mods {
+		cmds = append(cmds, []string{"go", "get", "-d", mod})
+	}
+	cmds = append(cmds, []string{"go", "mod", "download"})
+	for _, c := range cmds {
+		if err := run(c, tmp, env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// warmTool runs the command returned by args for each package, if tool is available.
+func warmTool(dir string, pkgs []string, tool string, args func(pkg string) []string) error {
+	if len(pkgs) == 0 {
+		return nil
+	}
+	if _, err := exec.LookPath(tool); err != nil {
+		log.Printf("%s not found on PATH, skipping %d package(s)", tool, len(pkgs))
+		return nil
+	}
+	for _, pkg := range pkgs {
+		if err := run(args(pkg), "", os.Environ()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func run(args []string, workdir string, env []string) error {
+	log.Printf("Running %q", args)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = workdir
+	cmd.Env = env
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("running %q: %v", args, err)
+	}
+	return nil
+}
+
+func makeWritable(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		mode := info.Mode().Perm() | 0666
+		if info.IsDir() {
+			mode |= 0111
+		}
+		return os.Chmod(path, mode)
+	})
+}